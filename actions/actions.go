@@ -0,0 +1,226 @@
+// Package actions integrates with GitHub Actions, reporting errors as
+// workflow annotations, with the file and line of configuration errors,
+// writing step summaries of deploys, and mapping failures to distinct
+// exit codes so workflows need not scrape logs.
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apex/apex/config"
+	"github.com/apex/apex/function"
+	"github.com/apex/apex/lock"
+	"github.com/apex/apex/project"
+)
+
+// Exit codes by failure class.
+const (
+	// ExitFailure is the exit code of failures of no other class.
+	ExitFailure = 1
+
+	// ExitPartial is the exit code when some, but not all, functions failed.
+	ExitPartial = 2
+
+	// ExitInvalid is the exit code of invalid configuration.
+	ExitInvalid = 3
+
+	// ExitPermissions is the exit code when the credentials lack permissions.
+	ExitPermissions = 4
+
+	// ExitLocked is the exit code when a function is locked by another deploy.
+	ExitLocked = 5
+
+	// ExitTooLarge is the exit code when a zip or payload exceeds Lambda's limits.
+	ExitTooLarge = 6
+)
+
+// Enabled returns true when running in a GitHub Actions workflow.
+func Enabled() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// ExitCode returns the exit code of `err`. Failures of many functions
+// exit with ExitPartial when some succeeded, or with the class shared
+// by every failure when all failed.
+func ExitCode(err error) int {
+	var errs *project.Errors
+
+	if errors.As(err, &errs) {
+		if errs.Partial() {
+			return ExitPartial
+		}
+
+		code := 0
+		for _, f := range errs.Failed {
+			switch c := ExitCode(f.Err); {
+			case code == 0:
+				code = c
+			case code != c:
+				return ExitFailure
+			}
+		}
+
+		if code == 0 {
+			return ExitFailure
+		}
+
+		return code
+	}
+
+	var invalid *function.ErrValidation
+	var denied *project.ErrPermissions
+	var locked *lock.ErrLocked
+	var large *function.ErrTooLarge
+
+	switch {
+	case errors.As(err, &invalid):
+		return ExitInvalid
+	case errors.As(err, &denied):
+		return ExitPermissions
+	case errors.As(err, &locked):
+		return ExitLocked
+	case errors.As(err, &large):
+		return ExitTooLarge
+	default:
+		return ExitFailure
+	}
+}
+
+// Annotation is a workflow error or warning, optionally of a file location.
+type Annotation struct {
+	Level   string
+	File    string
+	Line    int
+	Column  int
+	Title   string
+	Message string
+}
+
+// String returns the workflow command of the annotation.
+func (a *Annotation) String() string {
+	var props []string
+
+	if a.File != "" {
+		props = append(props, "file="+escapeProperty(a.File))
+	}
+
+	if a.Line > 0 {
+		props = append(props, fmt.Sprintf("line=%d", a.Line))
+	}
+
+	if a.Column > 0 {
+		props = append(props, fmt.Sprintf("col=%d", a.Column))
+	}
+
+	if a.Title != "" {
+		props = append(props, "title="+escapeProperty(a.Title))
+	}
+
+	cmd := a.Level
+	if len(props) > 0 {
+		cmd += " " + strings.Join(props, ",")
+	}
+
+	return fmt.Sprintf("::%s::%s", cmd, escapeData(a.Message))
+}
+
+// Annotations returns the error annotations of `err`, one per failed
+// function of *project.Errors, and one per configuration error located
+// in a file, relative to the workspace.
+func Annotations(err error) (list []*Annotation) {
+	var errs *project.Errors
+
+	if errors.As(err, &errs) {
+		for _, f := range errs.Failed {
+			for _, a := range Annotations(f.Err) {
+				if a.Title == "" {
+					a.Title = fmt.Sprintf("function %s failed", f.Function)
+				}
+				list = append(list, a)
+			}
+		}
+		return
+	}
+
+	var invalid *function.ErrValidation
+
+	if !errors.As(err, &invalid) || located(invalid.Err) == nil {
+		return []*Annotation{{Level: "error", Message: err.Error()}}
+	}
+
+	for _, e := range located(invalid.Err) {
+		msg := e.Message
+		if e.Field != "" {
+			msg = e.Field + ": " + msg
+		}
+
+		list = append(list, &Annotation{
+			Level:   "error",
+			File:    workspacePath(e.File),
+			Line:    e.Line,
+			Column:  e.Column,
+			Title:   fmt.Sprintf("invalid function %s", invalid.Function),
+			Message: msg,
+		})
+	}
+
+	return
+}
+
+// located returns the configuration errors of `err`, if any.
+func located(err error) config.Errors {
+	var list config.Errors
+	var one *config.Error
+
+	switch {
+	case errors.As(err, &list):
+		return list
+	case errors.As(err, &one):
+		return config.Errors{one}
+	default:
+		return nil
+	}
+}
+
+// Annotate writes the error annotations of `err` to `w`.
+func Annotate(w io.Writer, err error) {
+	for _, a := range Annotations(err) {
+		fmt.Fprintln(w, a)
+	}
+}
+
+// workspacePath returns `path` relative to GITHUB_WORKSPACE
+// when within it, so annotations are shown on the file.
+func workspacePath(path string) string {
+	root := os.Getenv("GITHUB_WORKSPACE")
+	if root == "" || path == "" {
+		return path
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// escapeData escapes the message of workflow commands.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes the property values of workflow commands.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
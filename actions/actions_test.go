@@ -0,0 +1,94 @@
+package actions
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/apex/apex/config"
+	"github.com/apex/apex/function"
+	"github.com/apex/apex/lock"
+	"github.com/apex/apex/project"
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/discard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	invalid := &function.ErrValidation{Function: "api", Err: errors.New("Memory: must be set")}
+	locked := &lock.ErrLocked{Name: "app_api", Owner: "tj"}
+
+	assert.Equal(t, ExitFailure, ExitCode(errors.New("boom")))
+	assert.Equal(t, ExitInvalid, ExitCode(invalid))
+	assert.Equal(t, ExitInvalid, ExitCode(fmt.Errorf("opening: %w", invalid)))
+	assert.Equal(t, ExitPermissions, ExitCode(&project.ErrPermissions{Denied: []string{"lambda:CreateFunction on *"}}))
+	assert.Equal(t, ExitLocked, ExitCode(locked))
+	assert.Equal(t, ExitTooLarge, ExitCode(&function.ErrTooLarge{Function: "api"}))
+
+	assert.Equal(t, ExitPartial, ExitCode(&project.Errors{
+		Total:  2,
+		Failed: []*project.FunctionError{{Function: "api", Err: locked}},
+	}))
+
+	assert.Equal(t, ExitLocked, ExitCode(&project.Errors{
+		Total:  2,
+		Failed: []*project.FunctionError{{Function: "api", Err: locked}, {Function: "worker", Err: locked}},
+	}))
+
+	assert.Equal(t, ExitFailure, ExitCode(&project.Errors{
+		Total:  2,
+		Failed: []*project.FunctionError{{Function: "api", Err: locked}, {Function: "worker", Err: errors.New("boom")}},
+	}))
+}
+
+func TestAnnotations(t *testing.T) {
+	wd, _ := os.Getwd()
+	os.Setenv("GITHUB_WORKSPACE", wd)
+	defer os.Unsetenv("GITHUB_WORKSPACE")
+
+	err := &project.Errors{
+		Total: 2,
+		Failed: []*project.FunctionError{
+			{Function: "api", Err: &function.ErrValidation{Function: "api", Err: config.Errors{
+				{File: "functions/api/function.json", Line: 3, Column: 13, Field: "memory", Message: "expected a number, got a string"},
+			}}},
+			{Function: "worker", Err: errors.New("throttled,\nretry later")},
+		},
+	}
+
+	var buf bytes.Buffer
+	Annotate(&buf, err)
+
+	assert.Equal(t, "::error file=functions/api/function.json,line=3,col=13,title=invalid function api::memory: expected a number, got a string\n"+
+		"::error title=function worker failed::throttled,%0Aretry later\n", buf.String())
+}
+
+func TestSummary(t *testing.T) {
+	s := Summary("Deploy", []Result{
+		{Function: "api", Version: "3"},
+		{Function: "worker", Err: errors.New("a | b")},
+	})
+
+	assert.Equal(t, "### Deploy\n\n"+
+		"| Function | Status | Version |\n"+
+		"| --- | --- | --- |\n"+
+		"| api | succeeded | 3 |\n"+
+		"| worker | failed: a \\| b | - |\n", s)
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	l := &log.Logger{
+		Handler: &Handler{Writer: &buf, Next: discard.New()},
+		Level:   log.DebugLevel,
+	}
+
+	l.Info("deploying")
+	l.WithField("function", "api").Warn("quota nearly exceeded")
+	l.Error("deploy failed")
+
+	assert.Equal(t, "::warning::quota nearly exceeded function=api\n::error::deploy failed\n", buf.String())
+}
@@ -0,0 +1,48 @@
+package actions
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/apex/log"
+)
+
+// Handler is a log handler writing warnings and errors as workflow
+// annotations, and debug entries as workflow debug messages, passing
+// other entries to the Next handler.
+type Handler struct {
+	Writer io.Writer
+	Next   log.Handler
+	mu     sync.Mutex
+}
+
+// HandleLog implements log.Handler.
+func (h *Handler) HandleLog(e *log.Entry) error {
+	var level string
+
+	switch e.Level {
+	case log.DebugLevel:
+		level = "debug"
+	case log.WarnLevel:
+		level = "warning"
+	case log.ErrorLevel, log.FatalLevel:
+		level = "error"
+	default:
+		return h.Next.HandleLog(e)
+	}
+
+	msg := e.Message
+	for _, name := range e.Fields.Names() {
+		msg += fmt.Sprintf(" %s=%v", name, e.Fields.Get(name))
+	}
+
+	a := &Annotation{Level: level, Message: strings.TrimSpace(msg)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err := fmt.Fprintln(h.Writer, a)
+	return err
+}
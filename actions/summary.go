@@ -0,0 +1,101 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/apex/apex/project"
+)
+
+// Result is the outcome of an operation on a function.
+type Result struct {
+	Function string
+	Version  string
+	Err      error
+}
+
+// Results returns the results of an operation on functions `names` which
+// returned `err`, with the versions published by deploys. Functions fail
+// with `err` itself unless it is *project.Errors.
+func Results(p *project.Project, names []string, err error) []Result {
+	failed := make(map[string]error)
+
+	var errs *project.Errors
+	if errors.As(err, &errs) {
+		for _, f := range errs.Failed {
+			failed[f.Function] = f.Err
+		}
+	}
+
+	var list []Result
+
+	for _, name := range names {
+		r := Result{Function: name, Err: failed[name]}
+
+		if errs == nil && err != nil {
+			r.Err = err
+		}
+
+		if fn, e := p.FunctionByName(name); e == nil && r.Err == nil {
+			r.Version, _ = fn.Published()
+		}
+
+		list = append(list, r)
+	}
+
+	return list
+}
+
+// Summary returns a markdown summary of `results` titled `title`.
+func Summary(title string, results []Result) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "### %s\n\n", title)
+	fmt.Fprintf(&b, "| Function | Status | Version |\n")
+	fmt.Fprintf(&b, "| --- | --- | --- |\n")
+
+	for _, r := range results {
+		status := "succeeded"
+		if r.Err != nil {
+			status = "failed: " + cell(r.Err.Error())
+		}
+
+		version := r.Version
+		if version == "" {
+			version = "-"
+		}
+
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", cell(r.Function), status, version)
+	}
+
+	return b.String()
+}
+
+// WriteSummary appends markdown `s` to the step summary, when
+// GITHUB_STEP_SUMMARY is set.
+func WriteSummary(s string) error {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(s + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// cell escapes `s` for a markdown table cell.
+func cell(s string) string {
+	s = strings.Replace(s, "|", `\|`, -1)
+	return strings.Replace(s, "\n", "<br>", -1)
+}
@@ -4,6 +4,9 @@
 package apex
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"io"
 	"log"
@@ -37,12 +40,56 @@ type Context struct {
 	ClientContext            json.RawMessage `json:"clientContext"`
 }
 
-// Handle Lambda events with the given handler.
+// IdempotencyKey returns the idempotency key set in the client context by
+// callers retrying invocations, or an empty string. Attempts of the same
+// invocation share the key.
+func (c *Context) IdempotencyKey() string {
+	var cc struct {
+		Custom map[string]interface{} `json:"custom"`
+	}
+
+	if err := json.Unmarshal(c.ClientContext, &cc); err != nil {
+		return ""
+	}
+
+	key, _ := cc.Custom["idempotencyKey"].(string)
+	return key
+}
+
+// Error is a typed error which handlers may return, surfacing its
+// code, retryable flag and metadata to callers of the function,
+// rather than only the message.
+type Error struct {
+	Code      string                 `json:"code,omitempty"`
+	Message   string                 `json:"message"`
+	Retryable bool                   `json:"retryable,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Error message.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Framings of messages exchanged with the node shim, chosen
+// by the shim's "framing" option.
+const (
+	// FramingLine is newline-delimited JSON.
+	FramingLine = "line"
+
+	// FramingLength is JSON prefixed by its 4-byte big-endian length.
+	FramingLength = "length"
+)
+
+// Handle Lambda events with the given handler. Events are handled
+// concurrently until stdin is closed, such as when the shim shuts
+// down, and pending events are completed before returning.
 func Handle(h Handler) {
 	m := &manager{
 		Reader:  os.Stdin,
 		Writer:  os.Stdout,
 		Handler: h,
+		Framing: os.Getenv("APEX_SHIM_FRAMING"),
 	}
 
 	m.Start()
@@ -55,14 +102,17 @@ func HandleFunc(h HandlerFunc) {
 
 // input for the node shim.
 type input struct {
+	ID      string          `json:"id,omitempty"`
 	Event   json.RawMessage `json:"event"`
 	Context *Context        `json:"context"`
 }
 
 // output from the node shim.
 type output struct {
-	Error string      `json:"error,omitempty"`
-	Value interface{} `json:"value,omitempty"`
+	ID      string      `json:"id,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Details *Error      `json:"errorDetails,omitempty"`
+	Value   interface{} `json:"value,omitempty"`
 }
 
 // manager for operating over stdio.
@@ -70,6 +120,7 @@ type manager struct {
 	Reader  io.Reader
 	Writer  io.Writer
 	Handler Handler
+	Framing string
 }
 
 // Start the manager.
@@ -79,7 +130,7 @@ func (m *manager) Start() {
 
 // input reads from the Reader and decodes JSON messages.
 func (m *manager) input() <-chan *input {
-	dec := json.NewDecoder(m.Reader)
+	dec := m.decoder()
 	ch := make(chan *input)
 
 	go func() {
@@ -87,7 +138,7 @@ func (m *manager) input() <-chan *input {
 
 		for {
 			msg := new(input)
-			err := dec.Decode(msg)
+			err := dec(msg)
 
 			if err == io.EOF {
 				break
@@ -129,24 +180,94 @@ func (m *manager) handle(in <-chan *input) <-chan *output {
 	return ch
 }
 
-// invoke calls the handler with `msg`.
+// warmSource is the "source" of keep-warm events.
+const warmSource = "apex.warm"
+
+// invoke calls the handler with `msg`, acknowledging
+// keep-warm events without invoking the handler.
 func (m *manager) invoke(msg *input) *output {
+	if isWarm(msg.Event) {
+		return &output{ID: msg.ID}
+	}
+
 	v, err := m.Handler.Handle(msg.Event, msg.Context)
 
+	if e, ok := err.(*Error); ok {
+		return &output{ID: msg.ID, Error: e.Message, Details: e}
+	}
+
 	if err != nil {
-		return &output{Error: err.Error()}
+		return &output{ID: msg.ID, Error: err.Error()}
 	}
 
-	return &output{Value: v}
+	return &output{ID: msg.ID, Value: v}
+}
+
+// isWarm returns true if `event` is a keep-warm ping.
+func isWarm(event json.RawMessage) bool {
+	if !bytes.Contains(event, []byte(warmSource)) {
+		return false
+	}
+
+	var v struct {
+		Source string `json:"source"`
+	}
+
+	return json.Unmarshal(event, &v) == nil && v.Source == warmSource
 }
 
 // output encodes the JSON messages and writes to the Writer.
 func (m *manager) output(ch <-chan *output) {
-	enc := json.NewEncoder(m.Writer)
+	enc := m.encoder()
 
 	for msg := range ch {
-		if err := enc.Encode(msg); err != nil {
+		if err := enc(msg); err != nil {
 			log.Printf("error encoding output: %s", err)
 		}
 	}
 }
+
+// decoder returns a function decoding framed messages from the Reader.
+func (m *manager) decoder() func(interface{}) error {
+	if m.Framing != FramingLength {
+		return json.NewDecoder(m.Reader).Decode
+	}
+
+	r := bufio.NewReader(m.Reader)
+
+	return func(v interface{}) error {
+		var size uint32
+
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return err
+		}
+
+		b := make([]byte, size)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return err
+		}
+
+		return json.Unmarshal(b, v)
+	}
+}
+
+// encoder returns a function encoding framed messages to the Writer.
+func (m *manager) encoder() func(interface{}) error {
+	if m.Framing != FramingLength {
+		return json.NewEncoder(m.Writer).Encode
+	}
+
+	return func(v interface{}) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.BigEndian, uint32(len(b)))
+		buf.Write(b)
+
+		_, err = m.Writer.Write(buf.Bytes())
+		return err
+	}
+}
@@ -0,0 +1,88 @@
+package apex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// frame returns `s` prefixed by its length.
+func frame(s string) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint32(len(s)))
+	buf.WriteString(s)
+	return buf.Bytes()
+}
+
+func TestManager_framingLength(t *testing.T) {
+	var in, out bytes.Buffer
+	in.Write(frame(`{"id":"1","event":{"name":"tj"},"context":{}}`))
+	in.Write(frame(`{"id":"2","event":{},"context":{}}`))
+
+	m := &manager{
+		Reader:  &in,
+		Writer:  &out,
+		Framing: FramingLength,
+		Handler: HandlerFunc(func(event json.RawMessage, ctx *Context) (interface{}, error) {
+			var v struct{ Name string }
+			json.Unmarshal(event, &v)
+
+			if v.Name == "" {
+				return nil, errors.New("name required")
+			}
+
+			return "hello " + v.Name, nil
+		}),
+	}
+
+	m.Start()
+
+	dec := (&manager{Reader: &out, Framing: FramingLength}).decoder()
+
+	var replies []string
+	for i := 0; i < 2; i++ {
+		var msg output
+		assert.Nil(t, dec(&msg))
+		b, _ := json.Marshal(msg)
+		replies = append(replies, string(b))
+	}
+	sort.Strings(replies)
+
+	assert.Equal(t, []string{
+		`{"id":"1","value":"hello tj"}`,
+		`{"id":"2","error":"name required"}`,
+	}, replies)
+}
+
+func TestManager_invoke_typedError(t *testing.T) {
+	m := &manager{
+		Handler: HandlerFunc(func(event json.RawMessage, ctx *Context) (interface{}, error) {
+			return nil, &Error{
+				Code:      "Throttled",
+				Message:   "too many requests",
+				Retryable: true,
+				Metadata:  map[string]interface{}{"retryAfter": 5},
+			}
+		}),
+	}
+
+	b, err := json.Marshal(m.invoke(&input{ID: "1", Event: json.RawMessage(`{}`)}))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":"1","error":"too many requests","errorDetails":{"code":"Throttled","message":"too many requests","retryable":true,"metadata":{"retryAfter":5}}}`, string(b))
+}
+
+func TestContext_IdempotencyKey(t *testing.T) {
+	ctx := &Context{ClientContext: json.RawMessage(`{"custom":{"idempotencyKey":"order-42"}}`)}
+	assert.Equal(t, "order-42", ctx.IdempotencyKey())
+
+	ctx = &Context{ClientContext: json.RawMessage(`{}`)}
+	assert.Equal(t, "", ctx.IdempotencyKey())
+
+	ctx = &Context{}
+	assert.Equal(t, "", ctx.IdempotencyKey())
+}
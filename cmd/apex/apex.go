@@ -4,39 +4,97 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	_ "github.com/apex/apex/runtime/bun"
+	_ "github.com/apex/apex/runtime/deno"
+	_ "github.com/apex/apex/runtime/dotnet"
 	_ "github.com/apex/apex/runtime/golang"
 	_ "github.com/apex/apex/runtime/nodejs"
 	_ "github.com/apex/apex/runtime/python"
+	_ "github.com/apex/apex/runtime/typescript"
 
+	"github.com/apex/apex/actions"
+	"github.com/apex/apex/cost"
+	"github.com/apex/apex/dashboard"
 	"github.com/apex/apex/dryrun"
+	"github.com/apex/apex/env"
 	"github.com/apex/apex/function"
 	"github.com/apex/apex/help"
 	"github.com/apex/apex/logs"
+	"github.com/apex/apex/offline"
 	"github.com/apex/apex/project"
+	"github.com/apex/apex/readonly"
+	"github.com/apex/apex/record"
+	"github.com/apex/apex/repl"
+	"github.com/apex/apex/runtime"
+	"github.com/apex/apex/server"
+	"github.com/apex/apex/shadow"
+	"github.com/apex/apex/sqs"
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/cli"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/appsync"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/signer"
+	"github.com/aws/aws-sdk-go/service/sns"
+	awssqs "github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/mattn/go-isatty"
 	"github.com/segmentio/go-prompt"
 	"github.com/tj/docopt"
+	"gopkg.in/yaml.v3"
 )
 
 var version = "0.4.1"
 
 const usage = `
   Usage:
-    apex deploy [options] [<name>...] [--env name=val]...
-    apex delete [options] [<name>...]
-    apex invoke [options] <name> [--async] [-v]
+    apex deploy [options] [<name>...] [--group name]... [--env name=val]... [--override-budget] [--no-publish]
+    apex deploy [options] <name> --artifact path
+    apex promote [options] [<name>...] [--group name]... --from stage [--from-region region] [--from-profile name]
+    apex copy [options] <name> [--to name] [--to-region region] [--to-profile name] [--to-role arn]
+    apex snapshot [options] <name> --output path
+    apex restore [options] <name> --snapshot path
+    apex configure [options] [<name>...] [--group name]... [--runtime id] [--add-layer arn]... [--remove-layer arn]... [--memory mb] [--timeout s] [--log-retention days] [--plan]
+    apex delete [options] [<name>...] [--group name]... [--resources] [--role]
+    apex invoke [options] <name> [--async] [-v] [--raw] [--stream] [--full-logs] [--record dest]
+    apex replay [options] <name> --recordings dest
+    apex compare [options] <name> --qualifier name [--recordings dest]
+    apex repl [options] [<name>]
     apex rollback [options] <name> [<version>]
-    apex logs [options] <name> [--filter pattern]
-    apex build [options] <name>
+    apex unlock [options] <name>...
+    apex apply [options] --offline dir
+    apex history [options] <name> [--limit n]
+    apex logs [options] [<name>...] [--group name]... [--filter pattern]
+    apex poll [options] <name> --queue url [--command cmd]
+    apex query [options] <name> <query> [--since d]
+    apex build [options] <name> [--output path]
+    apex build [options] <name> --targets --output dir
+    apex test [options] [<name>...] [--group name]...
+    apex audit [options] [<name>...] [--group name]... [--level level]
     apex list [options]
+    apex info [options] [<name>...] [--group name]... [--format fmt] [--indent n]
+    apex cost [options] [<name>...] [--group name]... [--days n]
+    apex serve [options] [--listen addr]
+    apex dashboard [options]
     apex help [<topic>]
     apex -h | --help
     apex --version
@@ -44,11 +102,52 @@ const usage = `
   Options:
     -e, --env name=val      Environment variable
     -D, --dry-run           Perform a dry-run
+    -R, --read-only         Refuse all changes, outputting those planned
+    --offline dir           Queue deploys, deletes, rollbacks and configuration changes to dir
     -F, --filter pattern    Filter logs with pattern [default: ]
+    -g, --group name        Select the functions of a group
     -l, --log-level level   Log severity level [default: info]
     -a, --async             Async invocation
     -C, --chdir path        Working directory
+    -s, --stage name        Stage name, selecting .env.<stage> files
+    -d, --days n            Days of metrics used for estimates [default: 30]
+    -n, --limit n           Number of releases to output [default: 10]
+    -q, --qualifier name    Version or alias to invoke, defaulting to the function's alias
+    -o, --output path       Write the zip or snapshot to path instead of stdout
+    --artifact path         Deploy a prebuilt zip, s3://bucket/key or sha256:checksum of a stored zip
+    --override-budget       Deploy memory and timeouts beyond budget ceilings
+    --no-publish            Update $LATEST without publishing a version
+    --from stage            Stage the code is promoted from
+    --from-region region    Region the code is promoted from
+    --from-profile name     AWS profile of the account the code is promoted from
+    --to name               Name of the copy, defaulting to the function's name
+    --to-region region      Region the function is copied to
+    --to-profile name       AWS profile of the account the function is copied to
+    --to-role arn           Execution role of the copy, required in another account
+    --snapshot path         Snapshot the function is restored from
+    --runtime id            Lambda runtime the functions are changed to
+    --add-layer arn         Layer version added, replacing other versions of the layer
+    --remove-layer arn      Layer removed, of any version when unversioned
+    --memory mb             Memory the functions are changed to
+    --timeout s             Timeout in seconds the functions are changed to
+    --log-retention days    Log retention the functions are changed to
+    --plan                  Output the changes planned without applying them
+    --targets               Build a zip per target architecture
+    --queue url             SQS queue URL to poll
+    --since d               Duration of logs queried [default: 1h]
+    --level level           Minimum severity of vulnerabilities [default: high]
+    --command cmd           Local command invoked in place of the function
+    --listen addr           Address the API is served on [default: localhost:8080]
+    --format fmt            Format of function info, json or yaml [default: json]
+    --indent n              Spaces of indentation of function info, 0 for compact json [default: 2]
     -y, --yes               Automatic yes to prompts
+    --raw                   Invoke with stdin as the raw payload
+    --stream                Stream the response of a response-streaming function
+    --full-logs             Output the complete logs of the invocation
+    --record dest           Record invocations to a directory or s3://bucket/prefix
+    --recordings dest       Directory or s3://bucket/prefix of recorded invocations
+    --resources             Delete aliases, event sources, rules, alarms and log groups
+    --role                  Delete the execution role created for the function
     -h, --help              Output help information
     -v, --verbose           Output verbose logs
     -V, --version           Output version
@@ -60,27 +159,124 @@ const usage = `
     Deploy specific functions
     $ apex deploy foo bar
 
+    Deploy functions matching a pattern, or in a group
+    $ apex deploy 'user_*'
+    $ apex deploy --group workers
+
+    Deploy a function with a zip built elsewhere
+    $ apex deploy foo --artifact s3://builds/foo.zip
+
+    Deploy a function with a zip in the store, by the checksum of a release
+    $ apex deploy foo --artifact sha256:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=
+
+    Bump the runtime of all functions, without deploying their code
+    $ apex configure --runtime nodejs22.x
+
+    Preview adding a layer to the functions of a group
+    $ apex configure --group workers --add-layer arn:aws:lambda:us-west-2:123456789012:layer:deps:4 --plan
+
     Delete all functions
     $ apex delete
 
     Delete specified functions
     $ apex delete foo bar
 
+    Delete a function and its associated resources
+    $ apex delete foo --resources
+
     Invoke a function with input json
     $ apex invoke foo < request.json
 
+    Invoke a function with a binary payload
+    $ apex invoke foo --raw < image.png
+
+    Record invocations of a function, and replay them against a new version
+    $ apex invoke foo --record s3://tests/recordings < requests.json
+    $ apex replay foo --recordings s3://tests/recordings -q 7
+
+    Compare a candidate version with the current alias, using recorded events
+    $ apex compare foo -q 8 --recordings s3://tests/recordings
+
+    Invoke a function interactively
+    $ apex repl foo
+
     Rollback a function to the previous version
     $ apex rollback foo
 
     Rollback a function to the specified version
     $ apex rollback bar 3
 
+    Release a stale deploy lock
+    $ apex unlock foo
+
+    Output the release history of a function
+    $ apex history foo
+
+    Tail the logs of all functions
+    $ apex logs
+
+    Query the errors of a function in the last day, also
+    slowest, cold-starts, memory, recent or a Logs Insights query
+    $ apex query foo errors --since 24h
+
+    Invoke a function with messages from an SQS queue
+    $ apex poll foo --queue https://sqs.us-west-2.amazonaws.com/123456789012/jobs
+
+    Deploy all functions with production .env.production files
+    $ apex deploy --stage production
+
+    Deploy a function without publishing a version, and invoke it
+    $ apex deploy foo --no-publish
+    $ apex invoke foo -q '$LATEST' < request.json
+
+    Promote the code tested in staging to production
+    $ apex promote --from staging --stage production
+
+    Copy a function to another region without rebuilding it
+    $ apex copy foo --to-region eu-west-1
+
+    Output the versions the aliases of a function route to
+    $ apex info foo --indent 0 | jq .aliases
+
+    Snapshot a function, and restore it after its deletion
+    $ apex snapshot foo --output foo.snapshot
+    $ apex restore foo --snapshot foo.snapshot
+
+    Build and queue a deploy on a machine without credentials, and apply it later
+    $ apex deploy --offline ./queue
+    $ apex apply --offline ./queue
+
+    Plan a deploy with read-only access, checking permissions first
+    $ apex deploy --read-only
+
     Deploy functions in a different project
     $ apex deploy -C ~/dev/myapp
 
+    Estimate monthly cost of all functions
+    $ apex cost
+
+    Serve deploys, invocations, rollbacks and logs over HTTP
+    $ APEX_SERVER_TOKEN=secret apex serve --listen :8080
+    $ curl -X POST -H 'Authorization: Bearer secret' localhost:8080/functions/foo/deploy
+
+    Watch versions, errors and logs of all functions, deploying and invoking them
+    $ apex dashboard
+
     Build zip output for a function
     $ apex build foo > /tmp/out.zip
 
+    Build zip output for a function to a file
+    $ apex build foo --output /tmp/out.zip
+
+    Build zips for each target architecture of a function
+    $ apex build foo --targets --output /tmp/builds
+
+    Run the tests of all functions
+    $ apex test
+
+    Scan the dependencies of all functions for critical vulnerabilities
+    $ apex audit --level critical
+
     Output help topics
     $ apex help
 
@@ -94,8 +290,14 @@ func main() {
 		log.Fatalf("error: %s", err)
 	}
 
+	function.Builder = "apex " + version
+
 	log.SetHandler(cli.Default)
 
+	if actions.Enabled() {
+		log.SetHandler(&actions.Handler{Writer: os.Stdout, Next: cli.Default})
+	}
+
 	if l, err := log.ParseLevel(args["--log-level"].(string)); err == nil {
 		log.SetLevel(l)
 	}
@@ -105,19 +307,68 @@ func main() {
 		return
 	}
 
+	if err := runtime.LoadPlugins(); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
 	session := session.New(aws.NewConfig())
 
 	project := &project.Project{
-		Log:  log.Log,
-		Path: ".",
+		Log:       log.Log,
+		Path:      ".",
+		Region:    aws.StringValue(session.Config.Region),
+		Decrypter: &env.KMS{Service: kms.New(session)},
 	}
 
+	readOnly := args["--read-only"].(bool)
+
+	if readOnly {
+		readonly.Guard(session, log.Log)
+	}
+
+	edge := session.Copy(aws.NewConfig().WithRegion(function.EdgeRegion))
+
 	if args["--dry-run"].(bool) {
 		log.SetLevel(log.WarnLevel)
 		project.Service = dryrun.New(session)
+		project.EdgeService = dryrun.New(edge)
 		project.Concurrency = 1
 	} else {
 		project.Service = lambda.New(session)
+		project.EdgeService = lambda.New(edge)
+		project.AppSync = appsync.New(session)
+		project.CloudFront = cloudfront.New(session)
+		project.CloudWatch = cloudwatch.New(session)
+		project.CloudWatchLogs = cloudwatchlogs.New(session)
+		project.EventBridge = eventbridge.New(session)
+		project.IAM = iam.New(session)
+		project.DynamoDB = dynamodb.New(session)
+		project.S3 = s3.New(session)
+		project.Signer = signer.New(session)
+		project.StepFunctions = sfn.New(session)
+		project.SQS = awssqs.New(session)
+		project.SSM = ssm.New(session)
+		project.SNS = sns.New(session)
+		project.STS = sts.New(session)
+
+		if readOnly {
+			project.Service = dryrun.New(session)
+			project.EdgeService = dryrun.New(edge)
+			project.Concurrency = 1
+		}
+	}
+
+	if stage, ok := args["--stage"].(string); ok {
+		project.Stage = stage
+	}
+
+	project.OverrideBudget = args["--override-budget"].(bool)
+	project.NoPublish = args["--no-publish"].(bool)
+
+	queue := offlineQueue(args)
+
+	if queue != nil && !args["apply"].(bool) {
+		project.Offline = queue
 	}
 
 	if dir, ok := args["--chdir"].(string); ok {
@@ -127,25 +378,83 @@ func main() {
 	}
 
 	if err := project.Open(); err != nil {
-		log.Fatalf("error opening project: %s", err)
+		fatal(err)
+	}
+
+	if project.Offline != nil && !(args["deploy"].(bool) && args["--artifact"] == nil || args["delete"].(bool) || args["rollback"].(bool) || args["configure"].(bool)) {
+		log.Fatalf("error: --offline is only supported by deploy, delete, rollback and configure")
 	}
 
 	switch {
 	case args["list"].(bool):
 		list(project)
+	case args["info"].(bool):
+		info(project, selectFunctions(project, args), args["--format"].(string), args["--indent"].(string))
+	case args["deploy"].(bool) && args["--artifact"] != nil:
+		deployArtifact(project, args["<name>"].([]string)[0], args["--artifact"].(string))
 	case args["deploy"].(bool):
-		deploy(project, args["<name>"].([]string), args["--env"].([]string))
+		deploy(project, selectFunctions(project, args), args["--env"].([]string))
+	case args["promote"].(bool):
+		promote(project, selectFunctions(project, args), args["--from"].(string), args["--from-region"], args["--from-profile"])
+	case args["copy"].(bool):
+		copyFunction(project, args["<name>"].([]string)[0], args["--to"], args["--to-region"], args["--to-profile"], args["--to-role"], args["--dry-run"].(bool) || readOnly)
+	case args["snapshot"].(bool):
+		snapshot(project, args["<name>"].([]string)[0], args["--output"].(string))
+	case args["restore"].(bool):
+		restore(project, args["<name>"].([]string)[0], args["--snapshot"].(string))
+	case args["configure"].(bool):
+		configure(project, selectFunctions(project, args), configChange(args), args["--plan"].(bool) || readOnly, args["--yes"].(bool))
 	case args["delete"].(bool):
-		delete(project, args["<name>"].([]string), args["--yes"].(bool))
+		delete(project, selectFunctions(project, args), args["--yes"].(bool), function.DeleteOptions{
+			Resources: args["--resources"].(bool),
+			Role:      args["--role"].(bool),
+		})
 	case args["invoke"].(bool):
-		invoke(project, args["<name>"].([]string), args["--verbose"].(bool), args["--async"].(bool))
+		invoke(project, args["<name>"].([]string), args["--qualifier"], args["--verbose"].(bool), args["--async"].(bool), args["--raw"].(bool), args["--stream"].(bool), args["--full-logs"].(bool), args["--record"])
+	case args["replay"].(bool):
+		replay(project, args["<name>"].([]string), args["--recordings"].(string), args["--qualifier"])
+	case args["compare"].(bool):
+		compare(project, args["<name>"].([]string), args["--qualifier"].(string), args["--recordings"])
+	case args["repl"].(bool):
+		interactive(project, args["<name>"].([]string))
 	case args["rollback"].(bool):
 		rollback(project, args["<name>"].([]string), args["<version>"])
+	case args["history"].(bool):
+		history(project, args["<name>"].([]string), args["--limit"].(string))
+	case args["unlock"].(bool):
+		unlock(project, args["<name>"].([]string))
+	case args["apply"].(bool):
+		apply(project, queue, args["--yes"].(bool))
 	case args["build"].(bool):
-		build(project, args["<name>"].([]string))
+		build(project, args["<name>"].([]string), args["--output"], args["--targets"].(bool))
+	case args["test"].(bool):
+		test(project, selectFunctions(project, args))
+	case args["audit"].(bool):
+		audit(project, selectFunctions(project, args), args["--level"].(string))
 	case args["logs"].(bool):
-		tail(project, args["<name>"].([]string), args["--filter"].(string))
+		tail(project, selectFunctions(project, args), args["--filter"].(string))
+	case args["query"].(bool):
+		query(project, args["<name>"].([]string), args["<query>"].(string), args["--since"].(string))
+	case args["poll"].(bool):
+		poll(project, session, args["<name>"].([]string), args["--queue"].(string), args["--command"])
+	case args["cost"].(bool):
+		estimate(project, session, selectFunctions(project, args), args["--days"].(string))
+	case args["serve"].(bool):
+		serve(project, args["--listen"].(string))
+	case args["dashboard"].(bool):
+		watch(project)
+	}
+}
+
+// selectFunctions returns the names of the functions selected by
+// name or glob pattern and --group, defaulting to all functions.
+func selectFunctions(project *project.Project, args map[string]interface{}) []string {
+	names, err := project.Select(args["<name>"].([]string), args["--group"].([]string))
+	if err != nil {
+		log.Fatalf("error: %s", err)
 	}
+
+	return names
 }
 
 // list functions.
@@ -154,17 +463,87 @@ func list(project *project.Project) {
 	fmt.Println()
 	for _, fn := range project.Functions {
 		fmt.Printf("  - %s (%s)\n", fn.Name, fn.Runtime)
+		listSignature(fn)
 	}
 	fmt.Println()
 }
 
-// invoke reads request json from stdin and outputs the responses.
-func invoke(project *project.Project, name []string, verbose, async bool) {
+// info outputs the deployed state of functions `names` as json or yaml,
+// indented by `indent` spaces.
+func info(project *project.Project, names []string, format, indent string) {
+	n, err := strconv.Atoi(indent)
+	if err != nil || n < 0 {
+		log.Fatalf("error: invalid indent %q", indent)
+	}
+
+	var encode func(v interface{}) error
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", strings.Repeat(" ", n))
+		encode = enc.Encode
+	case "yaml":
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(n)
+		defer enc.Close()
+		encode = enc.Encode
+	default:
+		log.Fatalf("error: unsupported format %q", format)
+	}
+
+	for _, name := range names {
+		fn, err := project.FunctionByName(name)
+		if err != nil {
+			log.Fatalf("error: %s", err)
+		}
+
+		i, err := fn.Info()
+		if err != nil {
+			log.Fatalf("error: %s: %s", name, err)
+		}
+
+		if err := encode(i); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+	}
+}
+
+// listSignature outputs the verified signature of functions with a code signing config.
+func listSignature(fn *function.Function) {
+	sig, err := fn.Signature()
+
+	if sig != nil {
+		fmt.Printf("    signed by %s (%s)", sig.Profile, sig.ProfileVersionArn)
+		if !sig.Expires.IsZero() {
+			fmt.Printf(", expires %s", sig.Expires.Format("2006-01-02"))
+		}
+		fmt.Println()
+	}
+
+	if err != nil {
+		fmt.Printf("    signature invalid: %s\n", err)
+	}
+}
+
+// invoke reads request json from stdin and outputs the responses. When
+// raw all of stdin is sent as a single payload. Invocations are recorded
+// to the `recordTo` destination, if any.
+func invoke(project *project.Project, name []string, qualifier interface{}, verbose, async, raw, stream, fullLogs bool, recordTo interface{}) {
 	dec := json.NewDecoder(os.Stdin)
-	kind := function.RequestResponse
+
+	opts := function.InvokeOptions{
+		Type:     function.RequestResponse,
+		NoLogs:   !verbose && !fullLogs,
+		FullLogs: fullLogs,
+	}
+
+	if q, ok := qualifier.(string); ok {
+		opts.Qualifier = q
+	}
 
 	if async {
-		kind = function.Event
+		opts.Type = function.Event
 	}
 
 	fn, err := project.FunctionByName(name[0])
@@ -172,6 +551,28 @@ func invoke(project *project.Project, name []string, verbose, async bool) {
 		log.Fatalf("error: %s", err)
 	}
 
+	if verbose && fn.Manifest {
+		provenance(fn, opts.Qualifier)
+	}
+
+	if dest, ok := recordTo.(string); ok {
+		store, err := record.Open(dest, project.S3)
+		if err != nil {
+			log.Fatalf("error: %s", err)
+		}
+		fn.Recorder = store
+	}
+
+	if raw {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("error reading stdin: %s", err)
+		}
+
+		invokeOutput(fn, b, opts, stream)
+		return
+	}
+
 	for {
 		var v struct {
 			Event   interface{}
@@ -188,43 +589,294 @@ func invoke(project *project.Project, name []string, verbose, async bool) {
 			log.Fatalf("error parsing response: %s", err)
 		}
 
-		reply, logs, err := fn.Invoke(v.Event, v.Context, kind)
+		b, err := json.Marshal(v.Event)
+		if err != nil {
+			log.Fatalf("error: %s", err)
+		}
+
+		opts.Context = v.Context
+		invokeOutput(fn, b, opts, stream)
+		fmt.Fprintf(os.Stdout, "\n")
+	}
+}
+
+// replay invokes `qualifier` of the function, defaulting to its alias,
+// with the events recorded in `dest`, outputting the invocations whose
+// reply or error differs from that recorded.
+func replay(project *project.Project, name []string, dest string, qualifier interface{}) {
+	fn, err := project.FunctionByName(name[0])
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	store, err := record.Open(dest, project.S3)
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	recorded, err := store.List(fn.Name)
+	if err != nil {
+		log.Fatalf("error listing recordings: %s", err)
+	}
+
+	if len(recorded) == 0 {
+		log.Fatalf("error: no recorded invocations of %s in %s", fn.Name, dest)
+	}
+
+	q, _ := qualifier.(string)
+
+	results, err := record.Replay(fn, recorded, q)
+	if err != nil {
+		log.Fatalf("error replaying: %s", err)
+	}
+
+	failed := 0
+
+	fmt.Println()
+	for _, r := range results {
+		if r.Match() {
+			continue
+		}
+
+		failed++
+		fmt.Printf("  - mismatch of %s (version %s)\n", r.Recorded.Time.Format(time.RFC3339), r.Recorded.Version)
+		fmt.Printf("    event:    %s\n", r.Recorded.Event)
+		fmt.Printf("    recorded: %s\n", replyOrError(r.Recorded.Reply, r.Recorded.Error))
+		fmt.Printf("    replayed: %s\n", replyOrError(r.Reply, r.Error))
+		fmt.Println()
+	}
+
+	fmt.Printf("  %d of %d invocations matched\n\n", len(results)-failed, len(results))
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// compare invokes the current alias and `candidate` version of the function
+// with the events recorded in `recordings`, or read from stdin as with
+// invoke, outputting their latency, memory usage and differing replies.
+func compare(project *project.Project, name []string, candidate string, recordings interface{}) {
+	fn, err := project.FunctionByName(name[0])
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	var events [][]byte
+
+	if dest, ok := recordings.(string); ok {
+		store, err := record.Open(dest, project.S3)
+		if err != nil {
+			log.Fatalf("error: %s", err)
+		}
+
+		recorded, err := store.List(fn.Name)
+		if err != nil {
+			log.Fatalf("error listing recordings: %s", err)
+		}
+
+		for _, i := range recorded {
+			events = append(events, i.Event)
+		}
+	} else {
+		dec := json.NewDecoder(os.Stdin)
+
+		for {
+			var v struct {
+				Event json.RawMessage
+			}
+
+			err := dec.Decode(&v)
+
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				log.Fatalf("error parsing event: %s", err)
+			}
+
+			events = append(events, v.Event)
+		}
+	}
+
+	if len(events) == 0 {
+		log.Fatalf("error: no events to compare %s with", fn.Name)
+	}
+
+	report, err := shadow.Compare(fn, events, candidate)
+	if err != nil {
+		log.Fatalf("error comparing: %s", err)
+	}
+
+	fmt.Println()
+	for _, s := range []struct {
+		name  string
+		stats shadow.Stats
+	}{
+		{report.Current, report.CurrentStats()},
+		{report.Candidate, report.CandidateStats()},
+	} {
+		fmt.Printf("  %-12s p50 %-10s p99 %-10s %4dMB max memory  %d errors\n", s.name, s.stats.P50, s.stats.P99, s.stats.MaxMemory, s.stats.Errors)
+	}
+	fmt.Println()
+
+	mismatches := report.Mismatches()
+
+	for _, c := range mismatches {
+		fmt.Printf("  - mismatch\n")
+		fmt.Printf("    event:     %s\n", c.Event)
+		fmt.Printf("    current:   %s\n", replyOrError(c.Current.Reply, c.Current.Error))
+		fmt.Printf("    candidate: %s\n", replyOrError(c.Candidate.Reply, c.Candidate.Error))
+		fmt.Println()
+	}
+
+	fmt.Printf("  %d of %d replies differ\n\n", len(mismatches), len(report.Comparisons))
+
+	if len(mismatches) > 0 {
+		os.Exit(1)
+	}
+}
+
+// replyOrError returns the reply, or the error when the invocation failed.
+func replyOrError(reply []byte, err string) string {
+	if err != "" {
+		return "error: " + err
+	}
+	return string(reply)
+}
+
+// invokeOutput invokes the function with `payload`, writing the logs to
+// stderr unless disabled, and the reply to stdout. When streaming the
+// reply is written as it arrives, followed by the logs.
+func invokeOutput(fn *function.Function, payload []byte, opts function.InvokeOptions, stream bool) {
+	if stream {
+		s, err := fn.InvokeStream(payload, opts)
 		if err != nil {
 			log.Fatalf("error response: %s", err)
 		}
+		defer s.Close()
 
-		// TODO(tj) rename flag to --with-logs or --logs
-		if verbose {
-			io.Copy(os.Stderr, logs)
+		if _, err := io.Copy(os.Stdout, s); err != nil {
+			log.Fatalf("error response: %s", err)
 		}
 
-		io.Copy(os.Stdout, reply)
-		fmt.Fprintf(os.Stdout, "\n")
+		if !opts.NoLogs {
+			outputLogs(s.Logs())
+		}
+
+		return
+	}
+
+	reply, logs, err := fn.InvokeWithOptions(payload, opts)
+	if err != nil {
+		log.Fatalf("error response: %s", err)
+	}
+
+	// TODO(tj) rename flag to --with-logs or --logs
+	if !opts.NoLogs {
+		outputLogs(logs)
+	}
+
+	io.Copy(os.Stdout, reply)
+}
+
+// provenance logs the provenance of the code deployed to `qualifier`,
+// defaulting to the function's alias, from its verified manifest, warning
+// when it was built from a different commit than the working tree.
+func provenance(fn *function.Function, qualifier string) {
+	if qualifier == "" {
+		qualifier = fn.AliasName()
+	}
+
+	m, err := fn.DeployedManifest(qualifier)
+	if err != nil {
+		log.Warnf("unverified code: %s", err)
+		return
+	}
+
+	log.Infof("running %s built by %s at %s", m.Commit, m.Builder, m.Built.Format(time.RFC3339))
+
+	if fn.Git != nil && fn.Git.Commit != m.Commit {
+		log.Warnf("deployed commit %s differs from local commit %s", m.Commit, fn.Git.Commit)
 	}
 }
 
+// interactive starts a REPL, with the optional function selected.
+func interactive(project *project.Project, name []string) {
+	r := &repl.REPL{
+		Project: project,
+		Out:     os.Stdout,
+		Color:   isatty.IsTerminal(os.Stdout.Fd()),
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		r.HistoryFile = filepath.Join(home, ".apex_history")
+	}
+
+	if len(name) > 0 {
+		fn, err := project.FunctionByName(name[0])
+		if err != nil {
+			log.Fatalf("error: %s", err)
+		}
+		r.Function = fn
+	}
+
+	if err := r.Start(); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+}
+
+// outputLogs writes the prettified log tail to stderr.
+func outputLogs(r io.Reader) {
+	f := logs.NewFormatter(os.Stderr, isatty.IsTerminal(os.Stderr.Fd()))
+	io.Copy(f, r)
+	f.Flush()
+}
+
 // deploy code and config changes.
 func deploy(project *project.Project, names []string, env []string) {
 	for _, s := range env {
-		parts := strings.Split(s, "=")
+		parts := strings.SplitN(s, "=", 2)
 		project.SetEnv(parts[0], parts[1])
 	}
 
-	if len(names) == 0 {
-		names = project.FunctionNames()
+	err := project.DeployAndClean(names)
+
+	if actions.Enabled() {
+		if err := actions.WriteSummary(actions.Summary("Deploy", actions.Results(project, names, err))); err != nil {
+			log.Warnf("error writing step summary: %s", err)
+		}
 	}
 
-	if err := project.DeployAndClean(names); err != nil {
-		log.Fatalf("error: %s", err)
+	if err != nil {
+		fatal(err)
+	}
+
+	for _, name := range names {
+		fn, err := project.FunctionByName(name)
+		if err != nil || fn.Endpoint() == "" {
+			continue
+		}
+
+		fmt.Printf("  %s: curl -X POST -d '{}' %s\n", fn.Name, fn.Endpoint())
 	}
 }
 
-// delete the functions.
-func delete(project *project.Project, names []string, force bool) {
-	if len(names) == 0 {
-		names = project.FunctionNames()
+// deployArtifact deploys a prebuilt zip for function `name`.
+func deployArtifact(project *project.Project, name, path string) {
+	fn, err := project.FunctionByName(name)
+	if err != nil {
+		log.Fatalf("error: %s", err)
 	}
 
+	if err := fn.DeployArtifact(path); err != nil && err != function.ErrUnchanged {
+		log.Fatalf("error: %s", err)
+	}
+}
+
+// delete the functions.
+func delete(project *project.Project, names []string, force bool, opts function.DeleteOptions) {
 	if !force && len(names) > 1 {
 		fmt.Printf("The following will be deleted:\n\n")
 		for _, name := range names {
@@ -237,36 +889,299 @@ func delete(project *project.Project, names []string, force bool) {
 		return
 	}
 
-	if err := project.Delete(names); err != nil {
+	opts.Force = true
+
+	if err := project.Delete(names, opts); err != nil {
+		fatal(err)
+	}
+}
+
+// configChange returns the configuration change of the configure command.
+func configChange(args map[string]interface{}) *function.Change {
+	c := &function.Change{
+		AddLayers:    args["--add-layer"].([]string),
+		RemoveLayers: args["--remove-layer"].([]string),
+	}
+
+	if s, ok := args["--runtime"].(string); ok {
+		c.Runtime = s
+	}
+
+	for flag, v := range map[string]*int64{"--memory": &c.Memory, "--timeout": &c.Timeout, "--log-retention": &c.LogRetention} {
+		s, ok := args[flag].(string)
+		if !ok {
+			continue
+		}
+
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			log.Fatalf("error: %s must be a number", flag)
+		}
+
+		*v = n
+	}
+
+	return c
+}
+
+// configure outputs the plan of change `c` to functions `names`, and
+// applies it when confirmed, unless `plan` is set.
+func configure(project *project.Project, names []string, c *function.Change, plan, force bool) {
+	if project.Offline != nil {
+		if err := project.ApplyChange(names, c); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	updates, err := project.PlanChange(names, c)
+	if err != nil {
+		fatal(err)
+	}
+
+	if len(updates) == 0 {
+		log.Info("configuration unchanged")
+		return
+	}
+
+	fmt.Printf("The following will be changed:\n\n")
+	for _, name := range names {
+		if len(updates[name]) == 0 {
+			continue
+		}
+
+		fmt.Printf("  %s\n", name)
+		for _, u := range updates[name] {
+			fmt.Printf("    %s\n", u)
+		}
+	}
+	fmt.Printf("\n")
+
+	if plan || !force && !prompt.Confirm("Are you sure? (yes/no)") {
+		return
+	}
+
+	if err := project.ApplyChange(names, c); err != nil {
+		fatal(err)
+	}
+}
+
+// offlineQueue returns the queue of --offline, if any, resolved before
+// changing directory.
+func offlineQueue(args map[string]interface{}) *offline.Queue {
+	dir, ok := args["--offline"].(string)
+	if !ok {
+		return nil
+	}
+
+	path, err := filepath.Abs(dir)
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	return &offline.Queue{Path: path}
+}
+
+// apply outputs the operations queued in `queue`, and applies them when confirmed.
+func apply(project *project.Project, queue *offline.Queue, force bool) {
+	ops, err := queue.List()
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	if len(ops) == 0 {
+		log.Info("no queued operations")
+		return
+	}
+
+	fmt.Printf("The following will be applied:\n\n")
+	for _, op := range ops {
+		fmt.Printf("  - %s\n", op)
+	}
+	fmt.Printf("\n")
+
+	if !force && !prompt.Confirm("Are you sure? (yes/no)") {
+		return
+	}
+
+	if err := project.Apply(queue); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+}
+
+// promote deploys the code serving stage `from`, optionally in another
+// region or account, to the functions of the `target` project's stage.
+func promote(target *project.Project, names []string, from string, region, profile interface{}) {
+	config := aws.NewConfig()
+	if r, ok := region.(string); ok {
+		config = config.WithRegion(r)
+	}
+
+	opts := session.Options{Config: *config, SharedConfigState: session.SharedConfigEnable}
+	if p, ok := profile.(string); ok {
+		opts.Profile = p
+	}
+
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	source := &project.Project{
+		Log:     log.Log,
+		Path:    ".",
+		Stage:   from,
+		Region:  aws.StringValue(sess.Config.Region),
+		Service: lambda.New(sess),
+	}
+
+	if err := source.Open(); err != nil {
+		log.Fatalf("error opening project: %s", err)
+	}
+
+	if err := target.Promote(names, source); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+}
+
+// copyFunction copies the code and configuration of function `name` to
+// `to`, optionally in another region or account, without rebuilding it.
+func copyFunction(project *project.Project, name string, to, region, profile, role interface{}, dryRun bool) {
+	fn, err := project.FunctionByName(name)
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	config := aws.NewConfig()
+	if r, ok := region.(string); ok {
+		config = config.WithRegion(r)
+	}
+
+	opts := session.Options{Config: *config, SharedConfigState: session.SharedConfigEnable}
+	if p, ok := profile.(string); ok {
+		opts.Profile = p
+	}
+
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	target := &function.CopyTarget{Service: lambda.New(sess)}
+	target.FunctionName, _ = to.(string)
+	target.Role, _ = role.(string)
+
+	if dryRun {
+		target.Service = dryrun.New(sess)
+	}
+
+	if _, ok := profile.(string); ok && !dryRun {
+		res, err := sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+		if err != nil {
+			log.Fatalf("error: %s", err)
+		}
+		target.Account = aws.StringValue(res.Account)
+	}
+
+	if err := fn.CopyTo(target); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+}
+
+// snapshot writes the remote state of function `name` to `path`.
+func snapshot(project *project.Project, name, path string) {
+	fn, err := project.FunctionByName(name)
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	if err := fn.Snapshot(path); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+}
+
+// restore recreates function `name` from the snapshot at `path`.
+func restore(project *project.Project, name, path string) {
+	fn, err := project.FunctionByName(name)
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	if err := fn.Restore(path); err != nil {
 		log.Fatalf("error: %s", err)
 	}
 }
 
 // rollback the function with optional version.
 func rollback(project *project.Project, name []string, version interface{}) {
-	fn, err := project.FunctionByName(name[0])
-	if err != nil {
+	v, _ := version.(string)
+
+	if err := project.Rollback(name[0], v); err != nil {
 		log.Fatalf("error: %s", err)
 	}
+}
 
-	if version == nil {
-		err = fn.Rollback()
-	} else {
-		err = fn.RollbackVersion(version.(string))
+// history outputs recorded releases of a function.
+func history(project *project.Project, name []string, limit string) {
+	n, err := strconv.Atoi(limit)
+	if err != nil {
+		log.Fatalf("error: invalid --limit %q", limit)
 	}
 
+	releases, err := project.History(name[0], n)
 	if err != nil {
 		log.Fatalf("error: %s", err)
 	}
+
+	fmt.Println()
+	for _, r := range releases {
+		fmt.Printf("  %-5s %s  %-10s %-7.7s", r.Version, r.Deployed.Format("2006-01-02 15:04:05"), r.Author, r.Commit)
+		if r.CodeSha256 != "" {
+			fmt.Printf("  %s%s", function.ChecksumPrefix, r.CodeSha256)
+		}
+		fmt.Println()
+		for _, c := range r.Changes {
+			fmt.Printf("        %s\n", c)
+		}
+	}
+	fmt.Println()
+}
+
+// unlock releases deploy locks.
+func unlock(project *project.Project, names []string) {
+	if err := project.Unlock(names); err != nil {
+		log.Fatalf("error: %s", err)
+	}
 }
 
-// build outputs the generated archive to stdout.
-func build(project *project.Project, name []string) {
+// build outputs the generated archive to stdout, or to `output` when set,
+// writing one archive per target architecture to the `output` directory
+// when `targets` is set.
+func build(project *project.Project, name []string, output interface{}, targets bool) {
 	fn, err := project.FunctionByName(name[0])
 	if err != nil {
 		log.Fatalf("error: %s", err)
 	}
 
+	if targets {
+		paths, err := fn.PackageTargets(output.(string))
+		if err != nil {
+			log.Fatalf("error: %s", err)
+		}
+
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+		return
+	}
+
+	if path, ok := output.(string); ok {
+		if err := fn.Package(path); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+		return
+	}
+
 	zip, err := fn.Zip()
 	if err != nil {
 		log.Fatalf("error: %s", err)
@@ -278,30 +1193,152 @@ func build(project *project.Project, name []string) {
 	}
 }
 
-// tail outputs logs with optional filter pattern.
-func tail(project *project.Project, name []string, filter string) {
+// test runs the tests of functions.
+func test(project *project.Project, names []string) {
+	if err := project.Test(names); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+}
+
+// audit scans the dependencies of functions for vulnerabilities.
+func audit(project *project.Project, names []string, level string) {
+	if err := project.Audit(names, level); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+}
+
+// tail outputs logs with optional filter pattern, interleaving
+// the logs of each function when there are several.
+func tail(project *project.Project, names []string, filter string) {
 	service := cloudwatchlogs.New(session.New(aws.NewConfig()))
+	color := isatty.IsTerminal(os.Stdout.Fd())
 
-	// TODO(tj): refactor logs.Logs to take Project so this hack
-	// can be removed, it'll also make multi-function tailing easier
-	group := fmt.Sprintf("/aws/lambda/%s_%s", project.Name, name[0])
+	m := &logs.Multi{Logs: make(map[string]*logs.Logs)}
+	width := 0
 
-	l := logs.Logs{
-		LogGroupName:  group,
-		FilterPattern: filter,
-		Service:       service,
-		Log:           log.Log,
+	for _, name := range names {
+		fn, err := project.FunctionByName(name)
+		if err != nil {
+			log.Fatalf("error: %s %q", err, name)
+		}
+
+		m.Logs[name] = &logs.Logs{
+			LogGroupName:  fn.LogGroupName(),
+			FilterPattern: filter,
+			Service:       service,
+			Log:           log.Log,
+		}
+
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	formatters := make(map[string]*logs.Formatter)
+
+	for i, name := range m.Names() {
+		f := logs.NewFormatter(os.Stdout, color)
+		if len(names) > 1 {
+			f.SetPrefix(fmt.Sprintf("%-*s", width, name), i)
+		}
+		formatters[name] = f
 	}
 
-	for event := range l.Tail() {
-		fmt.Printf("%s", *event.Message)
+	for event := range m.Tail() {
+		fmt.Fprintf(formatters[event.Name], "%s", *event.Message)
 	}
 
-	if err := l.Err(); err != nil {
+	for _, f := range formatters {
+		f.Flush()
+	}
+
+	if err := m.Err(); err != nil {
 		log.Fatalf("error: %s", err)
 	}
 }
 
+// query outputs the rows of a Logs Insights query as JSON lines.
+func query(project *project.Project, name []string, q string, since string) {
+	fn, err := project.FunctionByName(name[0])
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	d, err := time.ParseDuration(since)
+	if err != nil {
+		log.Fatalf("error parsing --since: %s", err)
+	}
+
+	rows, err := fn.Query(q, d)
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, row := range rows {
+		enc.Encode(row)
+	}
+}
+
+// poll invokes the function with messages from an SQS queue, or the
+// local `command` when set.
+func poll(project *project.Project, session *session.Session, name []string, queue string, command interface{}) {
+	fn, err := project.FunctionByName(name[0])
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	p := &sqs.Poller{
+		Service:  awssqs.New(session),
+		QueueURL: queue,
+		Invoker:  &sqs.FunctionInvoker{Function: fn, Logs: logs.NewFormatter(os.Stderr, isatty.IsTerminal(os.Stderr.Fd()))},
+		Log:      log.Log,
+	}
+
+	if command, ok := command.(string); ok {
+		p.Invoker = &sqs.CommandInvoker{Command: command, Dir: fn.Path}
+	}
+
+	if err := p.Start(); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+}
+
+// estimate outputs monthly cost estimates for the functions.
+func estimate(project *project.Project, session *session.Session, names []string, days string) {
+	n, err := strconv.Atoi(days)
+	if err != nil || n <= 0 {
+		log.Fatalf("error: invalid --days %q", days)
+	}
+
+	var fns []*function.Function
+	for _, name := range names {
+		fn, err := project.FunctionByName(name)
+		if err != nil {
+			log.Fatalf("error: %s", err)
+		}
+		fns = append(fns, fn)
+	}
+
+	c := &cost.Cost{
+		Service:   cloudwatch.New(session),
+		Log:       log.Log,
+		Days:      n,
+		Partition: project.Partition,
+	}
+
+	report, err := c.Functions(fns)
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	fmt.Println()
+	for _, e := range report.Estimates {
+		fmt.Printf("  %-20s %6dMB %-7s %10.0f invocations  %s\n", e.Name, e.Memory, e.Architecture, e.Invocations, c.Pricing.Format(e.Total()))
+	}
+	fmt.Printf("\n  %-20s %s/month\n\n", "total", c.Pricing.Format(report.Total()))
+}
+
 // showHelp outputs help pulled from the GitHub wiki.
 func showHelp(topic interface{}) {
 	var err error
@@ -316,3 +1353,71 @@ func showHelp(topic interface{}) {
 		log.Fatalf("error: %s", err)
 	}
 }
+
+// fatal outputs `err` and exits with the status of its failure class, see
+// actions.ExitCode. Each failure of a project operation is listed, and in
+// GitHub Actions they are output as annotations instead, with the file and
+// line of configuration errors.
+func fatal(err error) {
+	defer os.Exit(actions.ExitCode(err))
+
+	if actions.Enabled() {
+		actions.Annotate(os.Stdout, err)
+		return
+	}
+
+	e, ok := err.(*project.Errors)
+	if !ok {
+		log.Errorf("error: %s", err)
+		return
+	}
+
+	for _, f := range e.Failed {
+		log.WithError(f.Err).Errorf("function %s failed", f.Function)
+	}
+
+	if e.Partial() {
+		log.Errorf("%d of %d functions failed", len(e.Failed), e.Total)
+		return
+	}
+
+	log.Errorf("all %d functions failed", e.Total)
+}
+
+// serve the project's operations over HTTP, authenticated
+// with the APEX_SERVER_TOKEN bearer token.
+func serve(project *project.Project, addr string) {
+	token := os.Getenv("APEX_SERVER_TOKEN")
+	if token == "" {
+		log.Fatalf("error: APEX_SERVER_TOKEN must be set")
+	}
+
+	s := &server.Server{
+		Project: project,
+		Log:     log.Log,
+		Token:   token,
+	}
+
+	log.Infof("serving on %s", addr)
+
+	if err := http.ListenAndServe(addr, s); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+}
+
+// watch the project's functions in an interactive dashboard.
+func watch(project *project.Project) {
+	d := &dashboard.Dashboard{
+		Project: project,
+		In:      os.Stdin,
+		Out:     os.Stdout,
+	}
+
+	log.SetHandler(d)
+	err := d.Start()
+	log.SetHandler(cli.Default)
+
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+}
@@ -0,0 +1,444 @@
+// Package config implements decoding and schema validation of project
+// and function configuration files, reporting unknown keys, type
+// mismatches and missing values with file and line context.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Parser parses the contents of configuration `file` into a node tree.
+type Parser func(file string, b []byte) (*Node, error)
+
+// Registered parsers by file extension.
+var parsers = map[string]Parser{
+	".json": ParseJSON,
+}
+
+// RegisterParser registers parser `p` for files with extension `ext`.
+func RegisterParser(ext string, p Parser) {
+	parsers[ext] = p
+}
+
+// Extensions returns the sorted registered file extensions.
+func Extensions() (list []string) {
+	for ext := range parsers {
+		list = append(list, ext)
+	}
+
+	sort.Strings(list)
+	return list
+}
+
+// Find returns the path of configuration file `name` in `dir` with
+// any registered extension, or a not-exist error. More than one match
+// is an error, as it's ambiguous which should be used.
+func Find(dir, name string) (string, error) {
+	var found []string
+
+	for _, ext := range Extensions() {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			found = append(found, path)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return "", &os.PathError{Op: "open", Path: filepath.Join(dir, name+".json"), Err: os.ErrNotExist}
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("multiple configuration files found: %s", strings.Join(found, ", "))
+	}
+}
+
+// Error is a configuration error.
+type Error struct {
+	File    string
+	Line    int
+	Column  int
+	Field   string
+	Message string
+}
+
+// Error message.
+func (e *Error) Error() string {
+	var s string
+
+	if e.File != "" {
+		s += e.File + ":"
+	}
+
+	if e.Line > 0 {
+		s += fmt.Sprintf("%d:", e.Line)
+	}
+
+	if e.Column > 0 {
+		s += fmt.Sprintf("%d:", e.Column)
+	}
+
+	if s != "" {
+		s += " "
+	}
+
+	if e.Field != "" {
+		s += e.Field + ": "
+	}
+
+	return s + e.Message
+}
+
+// Errors is a list of configuration errors.
+type Errors []*Error
+
+// Error message.
+func (e Errors) Error() string {
+	var lines []string
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// err returns nil when empty.
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Schema validates configuration against the json fields of a struct.
+// Top-level keys prefixed with "x-" are ignored, allowing YAML anchors
+// and other extensions to be defined alongside the configuration.
+type Schema struct {
+	// Enums maps json keys to their valid values, used
+	// for validation and hints in error messages.
+	Enums map[string][]string
+}
+
+// Load reads `path` with the parser for its extension, validates
+// it against `v`'s fields and decodes into `v`.
+func (s *Schema) Load(path string, v interface{}) error {
+	parse, ok := parsers[filepath.Ext(path)]
+	if !ok {
+		return fmt.Errorf("unsupported configuration format %q", filepath.Ext(path))
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	n, err := parse(path, b)
+	if err != nil {
+		return err
+	}
+
+	return s.DecodeNode(path, n, v)
+}
+
+// Decode parses json `b`, validates it against `v`'s fields and decodes into `v`.
+func (s *Schema) Decode(file string, b []byte, v interface{}) error {
+	n, err := ParseJSON(file, b)
+	if err != nil {
+		return err
+	}
+
+	return s.DecodeNode(file, n, v)
+}
+
+// DecodeNode validates node `n` against `v`'s fields and decodes into `v`.
+func (s *Schema) DecodeNode(file string, n *Node, v interface{}) error {
+	var errs Errors
+	var fields []*Field
+	for _, f := range n.Fields {
+		if !strings.HasPrefix(f.Key, "x-") {
+			fields = append(fields, f)
+		}
+	}
+	n.Fields = fields
+
+	s.check(file, n, reflect.TypeOf(v), "", &errs)
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	b, err := json.Marshal(n.Interface())
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// Validate checks that fields tagged `validate:"nonzero"` in `v` are set.
+func (s *Schema) Validate(file string, v interface{}) error {
+	var errs Errors
+
+	val := reflect.Indirect(reflect.ValueOf(v))
+	s.required(file, val, &errs)
+
+	return errs.err()
+}
+
+// required appends errors for zero-valued required fields of `val`.
+func (s *Schema) required(file string, val reflect.Value, errs *Errors) {
+	t := val.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			s.required(file, val.Field(i), errs)
+			continue
+		}
+
+		if f.Tag.Get("validate") != "nonzero" {
+			continue
+		}
+
+		if !isZero(val.Field(i)) {
+			continue
+		}
+
+		msg := "zero value"
+		if values, ok := s.Enums[key(f)]; ok {
+			msg += ", must be one of " + strings.Join(values, ", ")
+		}
+
+		*errs = append(*errs, &Error{
+			File:    file,
+			Field:   f.Name,
+			Message: msg,
+		})
+	}
+}
+
+// unmarshaler is the json.Unmarshaler interface, types implementing
+// it accept any input and are responsible for their own validation.
+var unmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// check validates node `n` against type `t`.
+func (s *Schema) check(file string, n *Node, t reflect.Type, name string, errs *Errors) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if n.Kind == Null {
+		return
+	}
+
+	if reflect.PtrTo(t).Implements(unmarshaler) {
+		return
+	}
+
+	want := kindOf(t)
+	if want != Null && n.Kind != want {
+		*errs = append(*errs, &Error{
+			File:    file,
+			Line:    n.Line,
+			Column:  n.Column,
+			Field:   name,
+			Message: fmt.Sprintf("expected %s, got %s", want, n.Kind),
+		})
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := fieldsOf(t)
+
+		for _, f := range n.Fields {
+			sf, ok := fields[f.Key]
+			if !ok {
+				*errs = append(*errs, &Error{
+					File:    file,
+					Line:    f.Line,
+					Column:  f.Column,
+					Message: unknown(f.Key, fields),
+				})
+				continue
+			}
+
+			s.check(file, f.Value, sf.Type, sf.Name, errs)
+			s.enum(file, f, sf.Name, errs)
+		}
+	case reflect.Map:
+		for _, f := range n.Fields {
+			s.check(file, f.Value, t.Elem(), f.Key, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for _, item := range n.Items {
+			s.check(file, item, t.Elem(), name, errs)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if _, err := n.Value.(json.Number).Int64(); err != nil {
+			*errs = append(*errs, &Error{
+				File:    file,
+				Line:    n.Line,
+				Column:  n.Column,
+				Field:   name,
+				Message: fmt.Sprintf("expected integer, got %s", n.Value),
+			})
+		}
+	}
+}
+
+// enum validates field `f` against its valid values, if any.
+func (s *Schema) enum(file string, f *Field, name string, errs *Errors) {
+	values, ok := s.Enums[f.Key]
+	if !ok || f.Value.Kind != String {
+		return
+	}
+
+	v := f.Value.Value.(string)
+	if v == "" {
+		return
+	}
+
+	for _, valid := range values {
+		if v == valid {
+			return
+		}
+	}
+
+	*errs = append(*errs, &Error{
+		File:    file,
+		Line:    f.Value.Line,
+		Column:  f.Value.Column,
+		Field:   name,
+		Message: fmt.Sprintf("invalid value %q, must be one of %s", v, strings.Join(values, ", ")),
+	})
+}
+
+// unknown returns an unknown key message with a suggestion when a close match exists.
+func unknown(k string, fields map[string]reflect.StructField) string {
+	msg := fmt.Sprintf("unknown field %q", k)
+
+	var keys []string
+	for name := range fields {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+
+	best, dist := "", 3
+	for _, name := range keys {
+		if d := distance(strings.ToLower(k), strings.ToLower(name)); d < dist {
+			best, dist = name, d
+		}
+	}
+
+	if best != "" {
+		msg += fmt.Sprintf(", did you mean %q?", best)
+	}
+
+	return msg
+}
+
+// fieldsOf returns the struct fields of `t` by json key, flattening embedded structs.
+func fieldsOf(t reflect.Type) map[string]reflect.StructField {
+	m := make(map[string]reflect.StructField)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			for k, v := range fieldsOf(f.Type) {
+				m[k] = v
+			}
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if k := key(f); k != "-" {
+			m[k] = f
+		}
+	}
+
+	return m
+}
+
+// key returns the json key of field `f`.
+func key(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// kindOf returns the node kind expected for type `t`, or Null for any.
+func kindOf(t reflect.Type) Kind {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return Object
+	case reflect.Slice, reflect.Array:
+		return Array
+	case reflect.String:
+		return String
+	case reflect.Bool:
+		return Bool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return Number
+	default:
+		return Null
+	}
+}
+
+// isZero returns true if `v` is the zero value.
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// distance returns the Levenshtein distance between `a` and `b`.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
+
+// min3 returns the smallest of `a`, `b` and `c`.
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
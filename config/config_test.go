@@ -0,0 +1,91 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/apex/apex/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type Config struct {
+	Runtime string            `json:"runtime" validate:"nonzero"`
+	Memory  int64             `json:"memory" validate:"nonzero"`
+	Env     map[string]string `json:"env"`
+}
+
+var schema = &config.Schema{
+	Enums: map[string][]string{
+		"runtime": {"golang", "nodejs", "python"},
+	},
+}
+
+func TestSchema_Decode(t *testing.T) {
+	var c Config
+	err := schema.Decode("function.json", []byte(`{ "runtime": "nodejs", "memory": 128, "env": { "a": "b" } }`), &c)
+	assert.Nil(t, err)
+	assert.Equal(t, "nodejs", c.Runtime)
+	assert.Equal(t, int64(128), c.Memory)
+	assert.Equal(t, "b", c.Env["a"])
+}
+
+func TestSchema_Decode_unknownKey(t *testing.T) {
+	var c Config
+	err := schema.Decode("function.json", []byte("{\n  \"runtime\": \"nodejs\",\n  \"memroy\": 128\n}"), &c)
+	assert.EqualError(t, err, `function.json:3:3: unknown field "memroy", did you mean "memory"?`)
+}
+
+func TestSchema_Decode_typeMismatch(t *testing.T) {
+	var c Config
+	err := schema.Decode("function.json", []byte("{\n  \"memory\": \"128\"\n}"), &c)
+	assert.EqualError(t, err, `function.json:2:13: Memory: expected number, got string`)
+}
+
+func TestSchema_Decode_invalidEnum(t *testing.T) {
+	var c Config
+	err := schema.Decode("function.json", []byte(`{"runtime": "nodej"}`), &c)
+	assert.EqualError(t, err, `function.json:1:13: Runtime: invalid value "nodej", must be one of golang, nodejs, python`)
+}
+
+func TestSchema_Decode_syntaxError(t *testing.T) {
+	var c Config
+	err := schema.Decode("function.json", []byte("{\n  \"memory\": 1,\n}"), &c)
+	assert.Contains(t, err.Error(), "function.json:2:")
+}
+
+func TestSchema_Validate(t *testing.T) {
+	err := schema.Validate("function.json", &Config{})
+	assert.EqualError(t, err, "function.json: Runtime: zero value, must be one of golang, nodejs, python\nfunction.json: Memory: zero value")
+}
+
+func TestSchema_Load_yaml(t *testing.T) {
+	var c Config
+	err := schema.Load("_fixtures/anchors/function.yaml", &c)
+	assert.Nil(t, err)
+	assert.Equal(t, "nodejs", c.Runtime)
+	assert.Equal(t, int64(512), c.Memory)
+	assert.Equal(t, map[string]string{"LOG_LEVEL": "debug", "REGION": "us-west-2"}, c.Env)
+}
+
+func TestSchema_Load_yamlUnknownKey(t *testing.T) {
+	var c Config
+	err := schema.Load("_fixtures/typo/function.yml", &c)
+	assert.EqualError(t, err, `_fixtures/typo/function.yml:3:1: unknown field "memroy", did you mean "memory"?`)
+}
+
+func TestSchema_Load_toml(t *testing.T) {
+	var c Config
+	err := schema.Load("_fixtures/toml/function.toml", &c)
+	assert.Nil(t, err)
+	assert.Equal(t, "python", c.Runtime)
+	assert.Equal(t, int64(256), c.Memory)
+	assert.Equal(t, "1", c.Env["DEBUG"])
+}
+
+func TestFind(t *testing.T) {
+	path, err := config.Find("_fixtures/toml", "function")
+	assert.Nil(t, err)
+	assert.Equal(t, "_fixtures/toml/function.toml", path)
+
+	_, err = config.Find("_fixtures/ambiguous", "function")
+	assert.Contains(t, err.Error(), "multiple configuration files found")
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Kind of a node.
+type Kind int
+
+// Node kinds.
+const (
+	Null Kind = iota
+	Object
+	Array
+	String
+	Number
+	Bool
+)
+
+// String representation of the kind.
+func (k Kind) String() string {
+	switch k {
+	case Object:
+		return "object"
+	case Array:
+		return "array"
+	case String:
+		return "string"
+	case Number:
+		return "number"
+	case Bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+// Node is a decoded configuration value with its source position.
+type Node struct {
+	Kind   Kind
+	Value  interface{}
+	Fields []*Field
+	Items  []*Node
+	Line   int
+	Column int
+}
+
+// Field is an object member with the position of its key.
+type Field struct {
+	Key    string
+	Value  *Node
+	Line   int
+	Column int
+}
+
+// Interface returns the node as plain Go values suitable for json.Marshal.
+func (n *Node) Interface() interface{} {
+	switch n.Kind {
+	case Object:
+		m := make(map[string]interface{}, len(n.Fields))
+		for _, f := range n.Fields {
+			m[f.Key] = f.Value.Interface()
+		}
+		return m
+	case Array:
+		s := make([]interface{}, len(n.Items))
+		for i, item := range n.Items {
+			s[i] = item.Interface()
+		}
+		return s
+	default:
+		return n.Value
+	}
+}
+
+// ParseJSON parses `b` into a node tree.
+func ParseJSON(file string, b []byte) (*Node, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	p := &jsonParser{dec: dec, src: b}
+	n, err := p.value()
+
+	if err == io.EOF {
+		return &Node{Kind: Object}, nil
+	}
+
+	if e, ok := err.(*json.SyntaxError); ok {
+		line, col := position(b, int(e.Offset))
+		return nil, &Error{File: file, Line: line, Column: col, Message: e.Error()}
+	}
+
+	return n, err
+}
+
+// jsonParser builds a node tree from a json token stream.
+type jsonParser struct {
+	dec *json.Decoder
+	src []byte
+}
+
+// pos returns the line and column of the next token.
+func (p *jsonParser) pos() (int, int) {
+	off := int(p.dec.InputOffset())
+
+	for off < len(p.src) {
+		switch p.src[off] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			off++
+			continue
+		}
+		break
+	}
+
+	return position(p.src, off)
+}
+
+// value parses the next value.
+func (p *jsonParser) value() (*Node, error) {
+	line, col := p.pos()
+
+	tok, err := p.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Node{Line: line, Column: col, Value: tok}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		n.Value = nil
+		switch t {
+		case '{':
+			n.Kind = Object
+			for p.dec.More() {
+				line, col := p.pos()
+
+				key, err := p.dec.Token()
+				if err != nil {
+					return nil, err
+				}
+
+				v, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+
+				n.Fields = append(n.Fields, &Field{
+					Key:    key.(string),
+					Value:  v,
+					Line:   line,
+					Column: col,
+				})
+			}
+		case '[':
+			n.Kind = Array
+			for p.dec.More() {
+				v, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+
+				n.Items = append(n.Items, v)
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %q", t)
+		}
+
+		if _, err := p.dec.Token(); err != nil {
+			return nil, err
+		}
+	case string:
+		n.Kind = String
+	case json.Number:
+		n.Kind = Number
+	case bool:
+		n.Kind = Bool
+	case nil:
+		n.Kind = Null
+	}
+
+	return n, nil
+}
+
+// position returns the 1-based line and column of offset `off` in `b`.
+func position(b []byte, off int) (line, col int) {
+	if off > len(b) {
+		off = len(b)
+	}
+
+	line = 1 + bytes.Count(b[:off], []byte("\n"))
+	col = off - bytes.LastIndex(b[:off], []byte("\n"))
+	return line, col
+}
+
+// FromValue returns a node tree for plain Go value `v`, as produced
+// by decoders without position information.
+func FromValue(v interface{}) *Node {
+	switch v := v.(type) {
+	case nil:
+		return &Node{Kind: Null}
+	case string:
+		return &Node{Kind: String, Value: v}
+	case bool:
+		return &Node{Kind: Bool, Value: v}
+	case int:
+		return &Node{Kind: Number, Value: json.Number(strconv.Itoa(v))}
+	case int64:
+		return &Node{Kind: Number, Value: json.Number(strconv.FormatInt(v, 10))}
+	case uint64:
+		return &Node{Kind: Number, Value: json.Number(strconv.FormatUint(v, 10))}
+	case float64:
+		return &Node{Kind: Number, Value: json.Number(strconv.FormatFloat(v, 'f', -1, 64))}
+	case time.Time:
+		return &Node{Kind: String, Value: v.Format(time.RFC3339)}
+	case map[string]interface{}:
+		n := &Node{Kind: Object}
+
+		var keys []string
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			n.Fields = append(n.Fields, &Field{Key: k, Value: FromValue(v[k])})
+		}
+
+		return n
+	case []map[string]interface{}:
+		n := &Node{Kind: Array}
+		for _, item := range v {
+			n.Items = append(n.Items, FromValue(item))
+		}
+		return n
+	case []interface{}:
+		n := &Node{Kind: Array}
+		for _, item := range v {
+			n.Items = append(n.Items, FromValue(item))
+		}
+		return n
+	default:
+		return &Node{Kind: String, Value: fmt.Sprint(v)}
+	}
+}
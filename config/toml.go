@@ -0,0 +1,28 @@
+package config
+
+import (
+	"errors"
+
+	"github.com/BurntSushi/toml"
+)
+
+func init() {
+	RegisterParser(".toml", ParseTOML)
+}
+
+// ParseTOML parses `b` into a node tree. TOML decoding does not
+// expose key positions, so only parse errors carry line context.
+func ParseTOML(file string, b []byte) (*Node, error) {
+	var v map[string]interface{}
+
+	if _, err := toml.Decode(string(b), &v); err != nil {
+		var e toml.ParseError
+		if errors.As(err, &e) {
+			return nil, &Error{File: file, Line: e.Position.Line, Message: e.Message}
+		}
+
+		return nil, &Error{File: file, Message: err.Error()}
+	}
+
+	return FromValue(v), nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	RegisterParser(".yaml", ParseYAML)
+	RegisterParser(".yml", ParseYAML)
+}
+
+// ParseYAML parses `b` into a node tree, resolving aliases and merge keys.
+func ParseYAML(file string, b []byte) (*Node, error) {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, &Error{File: file, Message: err.Error()}
+	}
+
+	if len(doc.Content) == 0 {
+		return &Node{Kind: Object}, nil
+	}
+
+	return fromYAML(file, doc.Content[0])
+}
+
+// fromYAML converts yaml node `y` to a node.
+func fromYAML(file string, y *yaml.Node) (*Node, error) {
+	switch y.Kind {
+	case yaml.AliasNode:
+		return fromYAML(file, y.Alias)
+	case yaml.MappingNode:
+		n := &Node{Kind: Object, Line: y.Line, Column: y.Column}
+		seen := make(map[string]bool)
+		var merged []*Field
+
+		for i := 0; i+1 < len(y.Content); i += 2 {
+			k, v := y.Content[i], y.Content[i+1]
+
+			val, err := fromYAML(file, v)
+			if err != nil {
+				return nil, err
+			}
+
+			if k.Tag == "!!merge" {
+				merged = append(merged, mergeFields(val)...)
+				continue
+			}
+
+			seen[k.Value] = true
+			n.Fields = append(n.Fields, &Field{
+				Key:    k.Value,
+				Value:  val,
+				Line:   k.Line,
+				Column: k.Column,
+			})
+		}
+
+		// explicit keys take precedence over merged keys
+		for _, f := range merged {
+			if !seen[f.Key] {
+				seen[f.Key] = true
+				n.Fields = append(n.Fields, f)
+			}
+		}
+
+		return n, nil
+	case yaml.SequenceNode:
+		n := &Node{Kind: Array, Line: y.Line, Column: y.Column}
+
+		for _, item := range y.Content {
+			v, err := fromYAML(file, item)
+			if err != nil {
+				return nil, err
+			}
+
+			n.Items = append(n.Items, v)
+		}
+
+		return n, nil
+	default:
+		var v interface{}
+
+		if err := y.Decode(&v); err != nil {
+			return nil, &Error{File: file, Line: y.Line, Column: y.Column, Message: err.Error()}
+		}
+
+		n := FromValue(v)
+		n.Line = y.Line
+		n.Column = y.Column
+		return n, nil
+	}
+}
+
+// mergeFields returns the fields of a merge key value, which
+// may be a single mapping or a sequence of mappings.
+func mergeFields(n *Node) (fields []*Field) {
+	if n.Kind == Object {
+		return n.Fields
+	}
+
+	for _, item := range n.Items {
+		fields = append(fields, item.Fields...)
+	}
+
+	return fields
+}
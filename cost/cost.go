@@ -0,0 +1,191 @@
+// Package cost implements monthly cost estimation for Lambda functions
+// based on CloudWatch invocation and duration metrics.
+package cost
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apex/apex/function"
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+// DefaultDays is the number of days of metrics used for an estimate.
+const DefaultDays = 30
+
+// Pricing for Lambda requests and compute time.
+type Pricing struct {
+	// Currency of the prices, such as "USD".
+	Currency string
+
+	// Request is the cost of a single request.
+	Request float64
+
+	// GBSecond is the cost of a GB-second of compute by architecture.
+	GBSecond map[string]float64
+}
+
+// DefaultPricing is the current public Lambda pricing in us-east-1.
+var DefaultPricing = PricingOf(function.PartitionAWS)
+
+// PricingOf returns the current public Lambda pricing of `partition`.
+func PricingOf(partition string) Pricing {
+	p := function.PriceOf(partition)
+
+	return Pricing{
+		Currency: p.Currency,
+		Request:  p.Request,
+		GBSecond: p.GBSecond,
+	}
+}
+
+// Format returns `amount` in the currency of the pricing.
+func (p Pricing) Format(amount float64) string {
+	switch p.Currency {
+	case "", "USD":
+		return fmt.Sprintf("$%.2f", amount)
+	case "CNY":
+		return fmt.Sprintf("¥%.2f", amount)
+	default:
+		return fmt.Sprintf("%.2f %s", amount, p.Currency)
+	}
+}
+
+// Estimate is the monthly cost estimate for a single function.
+type Estimate struct {
+	Name         string
+	Architecture string
+	Memory       int64
+	Invocations  float64
+	Duration     time.Duration
+	RequestCost  float64
+	ComputeCost  float64
+}
+
+// Total cost of the function.
+func (e *Estimate) Total() float64 {
+	return e.RequestCost + e.ComputeCost
+}
+
+// Report is a collection of estimates.
+type Report struct {
+	Estimates []*Estimate
+}
+
+// Total cost of all functions in the report.
+func (r *Report) Total() (total float64) {
+	for _, e := range r.Estimates {
+		total += e.Total()
+	}
+	return total
+}
+
+// Cost implements cost estimation from CloudWatch metrics.
+type Cost struct {
+	Service cloudwatchiface.CloudWatchAPI
+	Log     log.Interface
+	Pricing Pricing
+	Days    int
+
+	// Partition whose public pricing is used unless Pricing is set,
+	// defaulting to "aws".
+	Partition string
+}
+
+// defaults applies configuration defaults.
+func (c *Cost) defaults() {
+	if c.Pricing.GBSecond == nil {
+		c.Pricing = PricingOf(c.Partition)
+	}
+
+	if c.Days == 0 {
+		c.Days = DefaultDays
+	}
+}
+
+// Functions returns a report for `fns`.
+func (c *Cost) Functions(fns []*function.Function) (*Report, error) {
+	report := new(Report)
+
+	for _, fn := range fns {
+		e, err := c.Function(fn)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Estimates = append(report.Estimates, e)
+	}
+
+	return report, nil
+}
+
+// Function returns the monthly estimate for `fn`, extrapolated
+// from the last Days worth of metrics.
+func (c *Cost) Function(fn *function.Function) (*Estimate, error) {
+	c.defaults()
+	c.Log.Debugf("estimating cost of %s", fn.FunctionName)
+
+	invocations, err := c.sum(fn.FunctionName, "Invocations")
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := c.sum(fn.FunctionName, "Duration")
+	if err != nil {
+		return nil, err
+	}
+
+	scale := 30 / float64(c.Days)
+	e := c.estimate(fn.Memory, fn.Arch(), invocations*scale, duration*scale)
+	e.Name = fn.Name
+	return e, nil
+}
+
+// estimate returns the cost of `invocations` totalling `ms` milliseconds.
+func (c *Cost) estimate(memory int64, arch string, invocations, ms float64) *Estimate {
+	gbSeconds := (ms / 1000) * (float64(memory) / 1024)
+
+	return &Estimate{
+		Architecture: arch,
+		Memory:       memory,
+		Invocations:  invocations,
+		Duration:     time.Duration(ms) * time.Millisecond,
+		RequestCost:  invocations * c.Pricing.Request,
+		ComputeCost:  gbSeconds * c.Pricing.GBSecond[arch],
+	}
+}
+
+// sum returns the sum of `metric` for function `name` over Days.
+func (c *Cost) sum(name, metric string) (float64, error) {
+	end := time.Now()
+	start := end.AddDate(0, 0, -c.Days)
+
+	res, err := c.Service.GetMetricStatistics(&cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/Lambda"),
+		MetricName: &metric,
+		StartTime:  &start,
+		EndTime:    &end,
+		Period:     aws.Int64(86400),
+		Statistics: []*string{aws.String("Sum")},
+		Dimensions: []*cloudwatch.Dimension{
+			{
+				Name:  aws.String("FunctionName"),
+				Value: &name,
+			},
+		},
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	var sum float64
+	for _, p := range res.Datapoints {
+		sum += *p.Sum
+	}
+
+	return sum, nil
+}
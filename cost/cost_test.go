@@ -0,0 +1,39 @@
+package cost
+
+import (
+	"testing"
+
+	"github.com/apex/apex/function"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCost_estimate(t *testing.T) {
+	c := &Cost{}
+	c.defaults()
+
+	e := c.estimate(1024, function.X86_64, 1e6, 1e6*1000)
+	assert.InDelta(t, 0.20, e.RequestCost, 0.0001)
+	assert.InDelta(t, 16.6667, e.ComputeCost, 0.0001)
+	assert.InDelta(t, 16.8667, e.Total(), 0.0001)
+}
+
+func TestCost_estimate_arm64(t *testing.T) {
+	c := &Cost{}
+	c.defaults()
+
+	x86 := c.estimate(512, function.X86_64, 1000, 1000*200)
+	arm := c.estimate(512, function.Arm64, 1000, 1000*200)
+	assert.True(t, arm.ComputeCost < x86.ComputeCost)
+	assert.Equal(t, x86.RequestCost, arm.RequestCost)
+}
+
+func TestCost_estimate_partition(t *testing.T) {
+	c := &Cost{Partition: function.PartitionChina}
+	c.defaults()
+
+	e := c.estimate(1024, function.X86_64, 1e6, 1e6*1000)
+	assert.InDelta(t, 1.36, e.RequestCost, 0.0001)
+	assert.InDelta(t, 113.477, e.ComputeCost, 0.0001)
+	assert.Equal(t, "¥114.84", c.Pricing.Format(e.Total()))
+	assert.Equal(t, "$16.87", DefaultPricing.Format(16.8667))
+}
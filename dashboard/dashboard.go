@@ -0,0 +1,395 @@
+// Package dashboard implements an interactive terminal dashboard of the
+// functions of a project, showing their deployed versions, recent errors
+// and live logs, with keybindings to deploy, roll back and invoke them.
+package dashboard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/apex/function"
+	"github.com/apex/apex/logs"
+	"github.com/apex/apex/project"
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/chzyer/readline"
+)
+
+// maxLines is the number of log lines kept per function, and of activity.
+const maxLines = 200
+
+// row is the state of a function.
+type row struct {
+	fn      *function.Function
+	version string
+	errors  int
+	status  string
+	logs    []string
+}
+
+// Dashboard of the functions of Project. The dashboard is also a log
+// handler, showing the log entries of operations as activity rather
+// than writing over the dashboard.
+type Dashboard struct {
+	// Project containing the functions.
+	Project *project.Project
+
+	// In is the terminal read for keys.
+	In *os.File
+
+	// Out is where the dashboard is drawn.
+	Out io.Writer
+
+	// Refresh is the interval versions and errors are refreshed
+	// at, defaulting to 30 seconds.
+	Refresh time.Duration
+
+	mu       sync.Mutex
+	rows     []*row
+	selected int
+	activity []string
+	confirm  func()
+	message  string
+	width    int
+	height   int
+	redraw   chan struct{}
+}
+
+// HandleLog implements log.Handler.
+func (d *Dashboard) HandleLog(e *log.Entry) error {
+	d.mu.Lock()
+	d.activity = appendLine(d.activity, fmt.Sprintf("%s %s", e.Level, e.Message))
+	d.mu.Unlock()
+	d.update()
+	return nil
+}
+
+// Start the dashboard, returning when the user quits.
+func (d *Dashboard) Start() error {
+	fd := int(d.In.Fd())
+
+	if !readline.IsTerminal(fd) {
+		return errors.New("the dashboard requires a terminal")
+	}
+
+	state, err := readline.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer readline.Restore(fd, state)
+
+	if d.Refresh == 0 {
+		d.Refresh = 30 * time.Second
+	}
+
+	d.init()
+	d.resize()
+
+	fmt.Fprint(d.Out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(d.Out, "\x1b[?25h\x1b[?1049l")
+
+	go d.refresh()
+	go d.tail()
+
+	keys := make(chan []byte)
+	go d.read(keys)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		d.draw()
+
+		select {
+		case key, ok := <-keys:
+			if !ok || !d.key(key) {
+				return nil
+			}
+		case <-d.redraw:
+		case <-ticker.C:
+			d.resize()
+		}
+	}
+}
+
+// init the rows of the project's functions.
+func (d *Dashboard) init() {
+	d.redraw = make(chan struct{}, 1)
+	d.rows = nil
+
+	for _, fn := range d.Project.Functions {
+		d.rows = append(d.rows, &row{fn: fn, errors: -1})
+	}
+}
+
+// update requests a redraw.
+func (d *Dashboard) update() {
+	select {
+	case d.redraw <- struct{}{}:
+	default:
+	}
+}
+
+// resize to the terminal size.
+func (d *Dashboard) resize() {
+	w, h, err := readline.GetSize(int(d.In.Fd()))
+	if err != nil || w == 0 || h == 0 {
+		w, h = 80, 24
+	}
+
+	d.mu.Lock()
+	d.width, d.height = w, h
+	d.mu.Unlock()
+}
+
+// draw the dashboard.
+func (d *Dashboard) draw() {
+	d.mu.Lock()
+	s := d.render()
+	d.mu.Unlock()
+
+	io.WriteString(d.Out, "\x1b[H\x1b[2J"+strings.Replace(s, "\n", "\r\n", -1))
+}
+
+// read keys from In.
+func (d *Dashboard) read(ch chan<- []byte) {
+	defer close(ch)
+	buf := make([]byte, 16)
+
+	for {
+		n, err := d.In.Read(buf)
+		if err != nil {
+			return
+		}
+
+		ch <- append([]byte(nil), buf[:n]...)
+	}
+}
+
+// key handles a key press, returning false to quit.
+func (d *Dashboard) key(b []byte) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if confirm := d.confirm; confirm != nil {
+		d.confirm = nil
+		d.message = ""
+
+		if string(b) == "y" {
+			go confirm()
+		}
+
+		return true
+	}
+
+	switch string(b) {
+	case "q", "\x03":
+		return false
+	case "k", "\x1b[A":
+		if d.selected > 0 {
+			d.selected--
+		}
+	case "j", "\x1b[B":
+		if d.selected < len(d.rows)-1 {
+			d.selected++
+		}
+	case "d":
+		d.ask("deploy", d.deploy)
+	case "r":
+		d.ask("roll back", d.rollback)
+	case "i":
+		if r := d.current(); r != nil {
+			go d.invoke(r)
+		}
+	}
+
+	return true
+}
+
+// current returns the selected row, if any.
+func (d *Dashboard) current() *row {
+	if d.selected < len(d.rows) {
+		return d.rows[d.selected]
+	}
+	return nil
+}
+
+// ask for confirmation to perform `action` of the selected function.
+func (d *Dashboard) ask(action string, fn func(*row)) {
+	r := d.current()
+	if r == nil {
+		return
+	}
+
+	d.message = fmt.Sprintf("%s %s? (y/n)", action, r.fn.Name)
+	d.confirm = func() { fn(r) }
+}
+
+// deploy the function of `r`.
+func (d *Dashboard) deploy(r *row) {
+	d.setStatus(r, "deploying")
+
+	if err := d.Project.DeployAndClean([]string{r.fn.Name}); err != nil {
+		d.setStatus(r, "deploy failed: "+err.Error())
+		return
+	}
+
+	version, _ := r.fn.Published()
+	d.setStatus(r, "deployed")
+	d.setVersion(r, version)
+}
+
+// rollback the function of `r` to its previous version.
+func (d *Dashboard) rollback(r *row) {
+	d.setStatus(r, "rolling back")
+
+	if err := d.Project.Rollback(r.fn.Name, ""); err != nil {
+		d.setStatus(r, "rollback failed: "+err.Error())
+		return
+	}
+
+	d.setStatus(r, "rolled back")
+	d.version(r)
+}
+
+// invoke the function of `r` with an empty event, showing the reply.
+func (d *Dashboard) invoke(r *row) {
+	d.setStatus(r, "invoking")
+
+	reply, _, err := r.fn.InvokeWithOptions([]byte("{}"), function.InvokeOptions{NoLogs: true})
+	if err != nil {
+		d.setStatus(r, "invoke failed: "+err.Error())
+		return
+	}
+
+	b, err := ioutil.ReadAll(reply)
+	if err != nil {
+		d.setStatus(r, "invoke failed: "+err.Error())
+		return
+	}
+
+	d.setStatus(r, "replied "+string(bytes.TrimSpace(b)))
+}
+
+// refresh versions and errors every Refresh interval.
+func (d *Dashboard) refresh() {
+	for {
+		for _, r := range d.rows {
+			d.version(r)
+			d.errorCount(r)
+		}
+
+		time.Sleep(d.Refresh)
+	}
+}
+
+// version fetches the version of the function of `r`.
+func (d *Dashboard) version(r *row) {
+	v, err := r.fn.Output(function.OutputVersion)
+	if err != nil {
+		v = "-"
+	}
+	d.setVersion(r, v)
+}
+
+// errorCount fetches the number of errors of the function of `r` in the last hour.
+func (d *Dashboard) errorCount(r *row) {
+	if r.fn.CloudWatch == nil {
+		return
+	}
+
+	end := time.Now()
+
+	res, err := r.fn.CloudWatch.GetMetricStatistics(&cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/Lambda"),
+		MetricName: aws.String("Errors"),
+		Dimensions: []*cloudwatch.Dimension{
+			{Name: aws.String("FunctionName"), Value: aws.String(r.fn.FunctionName)},
+		},
+		StartTime:  aws.Time(end.Add(-time.Hour)),
+		EndTime:    aws.Time(end),
+		Period:     aws.Int64(3600),
+		Statistics: aws.StringSlice([]string{"Sum"}),
+	})
+
+	if err != nil {
+		return
+	}
+
+	var n float64
+	for _, p := range res.Datapoints {
+		n += aws.Float64Value(p.Sum)
+	}
+
+	d.mu.Lock()
+	r.errors = int(n)
+	d.mu.Unlock()
+	d.update()
+}
+
+// tail the logs of every function.
+func (d *Dashboard) tail() {
+	m := &logs.Multi{Logs: make(map[string]*logs.Logs)}
+
+	for _, r := range d.rows {
+		if r.fn.CloudWatchLogs == nil {
+			continue
+		}
+
+		m.Logs[r.fn.Name] = &logs.Logs{
+			Service:      r.fn.CloudWatchLogs,
+			Log:          log.Log,
+			LogGroupName: r.fn.LogGroupName(),
+		}
+	}
+
+	if len(m.Logs) == 0 {
+		return
+	}
+
+	for e := range m.Tail() {
+		d.mu.Lock()
+		for _, r := range d.rows {
+			if r.fn.Name == e.Name {
+				r.logs = appendLine(r.logs, strings.TrimRight(aws.StringValue(e.Message), "\n"))
+			}
+		}
+		d.mu.Unlock()
+		d.update()
+	}
+}
+
+// setStatus sets the status of `r`.
+func (d *Dashboard) setStatus(r *row, status string) {
+	d.mu.Lock()
+	r.status = status
+	d.mu.Unlock()
+	d.update()
+}
+
+// setVersion sets the version of `r`.
+func (d *Dashboard) setVersion(r *row, version string) {
+	d.mu.Lock()
+	r.version = version
+	d.mu.Unlock()
+	d.update()
+}
+
+// appendLine appends `line` to `lines` as a single
+// line, keeping the last maxLines.
+func appendLine(lines []string, line string) []string {
+	line = strings.NewReplacer("\r", "", "\n", " ", "\t", "  ").Replace(line)
+	lines = append(lines, line)
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	return lines
+}
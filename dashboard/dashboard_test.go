@@ -0,0 +1,98 @@
+package dashboard
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apex/apex/function"
+	"github.com/apex/apex/project"
+	"github.com/apex/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func testDashboard() *Dashboard {
+	d := &Dashboard{
+		Project: &project.Project{
+			Config: project.Config{Name: "app"},
+			Functions: []*function.Function{
+				{Name: "foo"},
+				{Name: "bar"},
+			},
+		},
+		width:  60,
+		height: 20,
+	}
+
+	d.init()
+	return d
+}
+
+func TestDashboard_render(t *testing.T) {
+	d := testDashboard()
+	d.rows[0].version = "3"
+	d.rows[0].errors = 2
+	d.rows[0].logs = []string{"START", "hello"}
+	d.HandleLog(&log.Entry{Level: log.InfoLevel, Message: "deploying"})
+
+	s := d.render()
+	lines := strings.Split(s, "\n")
+
+	assert.Equal(t, "apex dashboard: app", lines[0])
+	assert.Len(t, lines, 20)
+	assert.Contains(t, s, "\x1b[7m  foo       3         2")
+	assert.Contains(t, s, "  bar       ...       -")
+	assert.Contains(t, s, "Logs of foo")
+	assert.Contains(t, s, "  hello")
+	assert.Contains(t, s, "  info deploying")
+	assert.Equal(t, help, lines[len(lines)-1])
+
+	for _, l := range lines {
+		assert.True(t, len([]rune(strings.TrimSuffix(strings.TrimPrefix(l, "\x1b[7m"), "\x1b[0m"))) <= 60)
+	}
+}
+
+func TestDashboard_key(t *testing.T) {
+	t.Run("select", func(t *testing.T) {
+		d := testDashboard()
+
+		assert.True(t, d.key([]byte("j")))
+		assert.Equal(t, 1, d.selected)
+
+		assert.True(t, d.key([]byte("\x1b[B")))
+		assert.Equal(t, 1, d.selected)
+
+		assert.True(t, d.key([]byte("k")))
+		assert.Equal(t, 0, d.selected)
+	})
+
+	t.Run("confirm", func(t *testing.T) {
+		d := testDashboard()
+
+		d.key([]byte("j"))
+		d.key([]byte("d"))
+		assert.Equal(t, "deploy bar? (y/n)", d.message)
+		assert.NotNil(t, d.confirm)
+
+		d.key([]byte("n"))
+		assert.Empty(t, d.message)
+		assert.Nil(t, d.confirm)
+		assert.Empty(t, d.rows[1].status)
+	})
+
+	t.Run("quit", func(t *testing.T) {
+		d := testDashboard()
+		assert.False(t, d.key([]byte("q")))
+		assert.False(t, d.key([]byte("\x03")))
+	})
+}
+
+func TestAppendLine(t *testing.T) {
+	var lines []string
+
+	for i := 0; i < maxLines+10; i++ {
+		lines = appendLine(lines, "a\nb\r\n")
+	}
+
+	assert.Len(t, lines, maxLines)
+	assert.Equal(t, "a b ", lines[0])
+}
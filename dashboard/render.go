@@ -0,0 +1,107 @@
+package dashboard
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// activityLines is the number of activity lines shown.
+const activityLines = 5
+
+// help is the key help line.
+const help = "j/k select  d deploy  r rollback  i invoke  q quit"
+
+// render the dashboard to fit the terminal size.
+func (d *Dashboard) render() string {
+	var lines []string
+
+	add := func(format string, args ...interface{}) {
+		lines = append(lines, truncate(fmt.Sprintf(format, args...), d.width))
+	}
+
+	add("apex dashboard: %s", d.Project.Name)
+	add("")
+
+	name := 8
+	for _, r := range d.rows {
+		if n := len(r.fn.Name); n > name {
+			name = n
+		}
+	}
+
+	add("  %-*s  %-8s  %-10s  %s", name, "FUNCTION", "VERSION", "ERRORS 1H", "STATUS")
+
+	for i, r := range d.rows {
+		version := r.version
+		if version == "" {
+			version = "..."
+		}
+
+		errors := "-"
+		if r.errors >= 0 {
+			errors = strconv.Itoa(r.errors)
+		}
+
+		line := truncate(fmt.Sprintf("  %-*s  %-8s  %-10s  %s", name, r.fn.Name, version, errors, r.status), d.width)
+
+		if i == d.selected {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+
+		lines = append(lines, line)
+	}
+
+	add("")
+
+	// the remaining height is shared by logs, activity and the help line
+	rest := d.height - len(lines) - activityLines - 3
+
+	if r := d.current(); r != nil {
+		add("Logs of %s", r.fn.Name)
+		for _, l := range last(r.logs, rest) {
+			add("  %s", l)
+		}
+		for i := len(last(r.logs, rest)); i < rest; i++ {
+			add("")
+		}
+	}
+
+	add("Activity")
+	for _, l := range last(d.activity, activityLines) {
+		add("  %s", l)
+	}
+	for i := len(last(d.activity, activityLines)); i < activityLines; i++ {
+		add("")
+	}
+
+	if d.message != "" {
+		add("%s", d.message)
+	} else {
+		add("%s", help)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// last returns the last `n` lines.
+func last(lines []string, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+
+	if len(lines) > n {
+		return lines[len(lines)-n:]
+	}
+
+	return lines
+}
+
+// truncate `s` to `width` characters.
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if width > 0 && len(r) > width {
+		return string(r[:width])
+	}
+	return s
+}
@@ -3,10 +3,13 @@ package dryrun
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/apex/apex/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/dustin/go-humanize"
@@ -72,12 +75,24 @@ func (l *Lambda) UpdateFunctionCode(in *lambda.UpdateFunctionCodeInput) (*lambda
 	}
 
 	out := &lambda.FunctionConfiguration{
-		Version: aws.String("$LATEST"),
+		Version:     aws.String("$LATEST"),
+		FunctionArn: res.Configuration.FunctionArn,
+		CodeSha256:  &checksum,
 	}
 
 	return out, nil
 }
 
+// CreateFunctionWithContext stub.
+func (l *Lambda) CreateFunctionWithContext(ctx aws.Context, in *lambda.CreateFunctionInput, opts ...request.Option) (*lambda.FunctionConfiguration, error) {
+	return l.CreateFunction(in)
+}
+
+// UpdateFunctionCodeWithContext stub.
+func (l *Lambda) UpdateFunctionCodeWithContext(ctx aws.Context, in *lambda.UpdateFunctionCodeInput, opts ...request.Option) (*lambda.FunctionConfiguration, error) {
+	return l.UpdateFunctionCode(in)
+}
+
 // UpdateFunctionConfiguration stub.
 func (l *Lambda) UpdateFunctionConfiguration(in *lambda.UpdateFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
 	res, err := l.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
@@ -96,26 +111,52 @@ func (l *Lambda) UpdateFunctionConfiguration(in *lambda.UpdateFunctionConfigurat
 
 	m := make(map[string]interface{})
 
-	if *in.Description != *res.Description {
+	if in.Description != nil && *in.Description != *res.Description {
 		m["description"] = fmt.Sprintf("%q -> %q", *res.Description, *in.Description)
 	}
 
-	if *in.Handler != *res.Handler {
+	if in.Handler != nil && *in.Handler != *res.Handler {
 		m["handler"] = fmt.Sprintf("%s -> %s", *res.Handler, *in.Handler)
 	}
 
-	if *in.MemorySize != *res.MemorySize {
+	if in.MemorySize != nil && *in.MemorySize != *res.MemorySize {
 		m["memory"] = fmt.Sprintf("%v -> %v", *res.MemorySize, *in.MemorySize)
 	}
 
-	if *in.Role != *res.Role {
+	if in.Role != nil && *in.Role != *res.Role {
 		m["role"] = fmt.Sprintf("%v -> %v", *res.Role, *in.Role)
 	}
 
-	if *in.Timeout != *res.Timeout {
+	if in.Timeout != nil && *in.Timeout != *res.Timeout {
 		m["timeout"] = fmt.Sprintf("%v -> %v", *res.Timeout, *in.Timeout)
 	}
 
+	if in.Environment != nil {
+		var before map[string]*string
+		if res.Environment != nil {
+			before = res.Environment.Variables
+		}
+
+		if changes := environmentChanges(before, in.Environment.Variables); changes != "" {
+			m["environment"] = changes
+		}
+	}
+
+	if c := in.LoggingConfig; c != nil {
+		current := res.LoggingConfig
+		if current == nil {
+			current = &lambda.LoggingConfig{}
+		}
+
+		if c.LogFormat != nil && *c.LogFormat != aws.StringValue(current.LogFormat) {
+			m["log format"] = fmt.Sprintf("%s -> %s", aws.StringValue(current.LogFormat), *c.LogFormat)
+		}
+
+		if c.LogGroup != nil && *c.LogGroup != aws.StringValue(current.LogGroup) {
+			m["log group"] = fmt.Sprintf("%s -> %s", aws.StringValue(current.LogGroup), *c.LogGroup)
+		}
+	}
+
 	if len(m) > 0 {
 		l.update("config", *in.FunctionName, m)
 	}
@@ -123,12 +164,106 @@ func (l *Lambda) UpdateFunctionConfiguration(in *lambda.UpdateFunctionConfigurat
 	return nil, nil
 }
 
+// environmentChanges returns the names of the variables added, changed
+// and removed from `before` to `after`, or an empty string when none
+// are. Values are omitted, as they may be secrets.
+func environmentChanges(before, after map[string]*string) string {
+	var added, changed, removed []string
+
+	for k, v := range after {
+		if prev, ok := before[k]; !ok {
+			added = append(added, k)
+		} else if aws.StringValue(prev) != aws.StringValue(v) {
+			changed = append(changed, k)
+		}
+	}
+
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+
+	var parts []string
+	for _, c := range []struct {
+		verb  string
+		names []string
+	}{
+		{"added", added},
+		{"changed", changed},
+		{"removed", removed},
+	} {
+		if len(c.names) > 0 {
+			sort.Strings(c.names)
+			parts = append(parts, c.verb+" "+strings.Join(c.names, ", "))
+		}
+	}
+
+	return strings.Join(parts, "; ")
+}
+
 // DeleteFunction stub.
 func (l *Lambda) DeleteFunction(in *lambda.DeleteFunctionInput) (*lambda.DeleteFunctionOutput, error) {
 	l.remove("function", *in.FunctionName, nil)
 	return nil, nil
 }
 
+// PublishVersion stub.
+func (l *Lambda) PublishVersion(in *lambda.PublishVersionInput) (*lambda.FunctionConfiguration, error) {
+	l.create("version", *in.FunctionName, map[string]interface{}{
+		"description": aws.StringValue(in.Description),
+	})
+
+	out := &lambda.FunctionConfiguration{
+		Version: aws.String("$LATEST"),
+	}
+
+	return out, nil
+}
+
+// WaitUntilFunctionActive stub.
+func (l *Lambda) WaitUntilFunctionActive(in *lambda.GetFunctionConfigurationInput) error {
+	return nil
+}
+
+// WaitUntilFunctionUpdated stub.
+func (l *Lambda) WaitUntilFunctionUpdated(in *lambda.GetFunctionConfigurationInput) error {
+	return nil
+}
+
+// TagResource stub.
+func (l *Lambda) TagResource(in *lambda.TagResourceInput) (*lambda.TagResourceOutput, error) {
+	m := make(map[string]interface{})
+	for k, v := range in.Tags {
+		m[k] = aws.StringValue(v)
+	}
+
+	l.update("tags", aws.StringValue(in.Resource), m)
+	return nil, nil
+}
+
+// PutFunctionCodeSigningConfig stub.
+func (l *Lambda) PutFunctionCodeSigningConfig(in *lambda.PutFunctionCodeSigningConfigInput) (*lambda.PutFunctionCodeSigningConfigOutput, error) {
+	l.update("code signing config", *in.FunctionName, map[string]interface{}{
+		"arn": *in.CodeSigningConfigArn,
+	})
+	return nil, nil
+}
+
+// PutRuntimeManagementConfig stub.
+func (l *Lambda) PutRuntimeManagementConfig(in *lambda.PutRuntimeManagementConfigInput) (*lambda.PutRuntimeManagementConfigOutput, error) {
+	m := map[string]interface{}{
+		"update on": *in.UpdateRuntimeOn,
+	}
+
+	if in.RuntimeVersionArn != nil {
+		m["version"] = *in.RuntimeVersionArn
+	}
+
+	l.update("runtime management", *in.FunctionName, m)
+	return nil, nil
+}
+
 // CreateAlias stub.
 func (l *Lambda) CreateAlias(in *lambda.CreateAliasInput) (*lambda.AliasConfiguration, error) {
 	l.create("alias", *in.FunctionName, map[string]interface{}{
@@ -147,6 +282,108 @@ func (l *Lambda) UpdateAlias(in *lambda.UpdateAliasInput) (*lambda.AliasConfigur
 	return nil, nil
 }
 
+// DeleteAlias stub.
+func (l *Lambda) DeleteAlias(in *lambda.DeleteAliasInput) (*lambda.DeleteAliasOutput, error) {
+	l.remove("alias", *in.FunctionName, map[string]interface{}{
+		"alias": *in.Name,
+	})
+	return nil, nil
+}
+
+// DeleteEventSourceMapping stub.
+func (l *Lambda) DeleteEventSourceMapping(in *lambda.DeleteEventSourceMappingInput) (*lambda.EventSourceMappingConfiguration, error) {
+	l.remove("event source mapping", *in.UUID, nil)
+	return nil, nil
+}
+
+// CreateEventSourceMapping stub.
+func (l *Lambda) CreateEventSourceMapping(in *lambda.CreateEventSourceMappingInput) (*lambda.EventSourceMappingConfiguration, error) {
+	name := aws.StringValue(in.EventSourceArn)
+	if name == "" && in.SelfManagedEventSource != nil {
+		name = strings.Join(aws.StringValueSlice(in.SelfManagedEventSource.Endpoints["KAFKA_BOOTSTRAP_SERVERS"]), ",")
+	}
+
+	if len(in.Topics) > 0 {
+		name += " " + *in.Topics[0]
+	}
+
+	l.create("event source mapping", name, eventSourceOptions(in.BatchSize, in.MaximumBatchingWindowInSeconds, in.ParallelizationFactor, in.FilterCriteria))
+	return nil, nil
+}
+
+// UpdateEventSourceMapping stub.
+func (l *Lambda) UpdateEventSourceMapping(in *lambda.UpdateEventSourceMappingInput) (*lambda.EventSourceMappingConfiguration, error) {
+	if in.Enabled != nil {
+		l.update("event source mapping", *in.UUID, map[string]interface{}{
+			"enabled": *in.Enabled,
+		})
+		return nil, nil
+	}
+
+	l.update("event source mapping", *in.UUID, eventSourceOptions(in.BatchSize, in.MaximumBatchingWindowInSeconds, in.ParallelizationFactor, in.FilterCriteria))
+	return nil, nil
+}
+
+// eventSourceOptions returns the options shown for event source mappings.
+func eventSourceOptions(batchSize, window, parallelization *int64, filters *lambda.FilterCriteria) map[string]interface{} {
+	m := map[string]interface{}{
+		"batching window": aws.Int64Value(window),
+	}
+
+	if batchSize != nil {
+		m["batch size"] = *batchSize
+	}
+
+	if parallelization != nil {
+		m["parallelization"] = *parallelization
+	}
+
+	if filters != nil {
+		m["filters"] = len(filters.Filters)
+	}
+
+	return m
+}
+
+// AddPermission stub.
+func (l *Lambda) AddPermission(in *lambda.AddPermissionInput) (*lambda.AddPermissionOutput, error) {
+	l.create("permission", *in.FunctionName, map[string]interface{}{
+		"statement": *in.StatementId,
+		"principal": *in.Principal,
+	})
+	return nil, nil
+}
+
+// RemovePermission stub.
+func (l *Lambda) RemovePermission(in *lambda.RemovePermissionInput) (*lambda.RemovePermissionOutput, error) {
+	l.remove("permission", *in.FunctionName, map[string]interface{}{
+		"statement": *in.StatementId,
+	})
+	return nil, nil
+}
+
+// CreateFunctionUrlConfig stub.
+func (l *Lambda) CreateFunctionUrlConfig(in *lambda.CreateFunctionUrlConfigInput) (*lambda.CreateFunctionUrlConfigOutput, error) {
+	l.create("url", *in.FunctionName, map[string]interface{}{
+		"auth": *in.AuthType,
+	})
+	return nil, nil
+}
+
+// UpdateFunctionUrlConfig stub.
+func (l *Lambda) UpdateFunctionUrlConfig(in *lambda.UpdateFunctionUrlConfigInput) (*lambda.UpdateFunctionUrlConfigOutput, error) {
+	l.update("url", *in.FunctionName, map[string]interface{}{
+		"auth": *in.AuthType,
+	})
+	return nil, nil
+}
+
+// DeleteFunctionUrlConfig stub.
+func (l *Lambda) DeleteFunctionUrlConfig(in *lambda.DeleteFunctionUrlConfigInput) (*lambda.DeleteFunctionUrlConfigOutput, error) {
+	l.remove("url", *in.FunctionName, nil)
+	return nil, nil
+}
+
 func (l *Lambda) log(kind, name string, m map[string]interface{}, symbol rune, color int) {
 	fmt.Printf("  \033[%dm%c %s\033[0m \033[%dm%s\033[0m\n", color, symbol, kind, blue, name)
 	for k, v := range m {
@@ -0,0 +1,96 @@
+package dryrun
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/stretchr/testify/assert"
+)
+
+// configuration of the deployed function.
+const configuration = `{
+  "FunctionName": "api",
+  "Description": "",
+  "Handler": "index.handle",
+  "MemorySize": 128,
+  "Role": "arn:aws:iam::123456789012:role/api",
+  "Timeout": 5,
+  "Environment": { "Variables": { "STAGE": "prod", "TOKEN": "secret", "DEBUG": "1" } }
+}`
+
+// service returns a dry-run service of a Lambda API serving the configuration.
+func service(t *testing.T) (*Lambda, func()) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(configuration))
+	}))
+
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:    aws.String(s.URL),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	assert.Nil(t, err)
+
+	return &Lambda{Lambda: lambda.New(sess)}, s.Close
+}
+
+// stdout returns the output of `fn` to stdout.
+func stdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+
+	orig := os.Stdout
+	os.Stdout = w
+	fn()
+	os.Stdout = orig
+	w.Close()
+
+	b, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	return string(b)
+}
+
+func TestLambda_UpdateFunctionConfiguration_environment(t *testing.T) {
+	l, close := service(t)
+	defer close()
+
+	out := stdout(t, func() {
+		_, err := l.UpdateFunctionConfiguration(&lambda.UpdateFunctionConfigurationInput{
+			FunctionName: aws.String("api"),
+			Environment: &lambda.Environment{
+				Variables: aws.StringMap(map[string]string{"STAGE": "prod", "TOKEN": "rotated", "REGION": "eu-west-1"}),
+			},
+		})
+		assert.Nil(t, err)
+	})
+
+	assert.Contains(t, out, "environment")
+	assert.Contains(t, out, "added REGION; changed TOKEN; removed DEBUG")
+	assert.True(t, !strings.Contains(out, "rotated"))
+}
+
+func TestLambda_UpdateFunctionConfiguration_unchangedEnvironment(t *testing.T) {
+	l, close := service(t)
+	defer close()
+
+	out := stdout(t, func() {
+		_, err := l.UpdateFunctionConfiguration(&lambda.UpdateFunctionConfigurationInput{
+			FunctionName: aws.String("api"),
+			Timeout:      aws.Int64(5),
+			Environment: &lambda.Environment{
+				Variables: aws.StringMap(map[string]string{"STAGE": "prod", "TOKEN": "secret", "DEBUG": "1"}),
+			},
+		})
+		assert.Nil(t, err)
+	})
+
+	assert.Equal(t, "", out)
+}
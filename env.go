@@ -6,6 +6,8 @@ import (
 	"os"
 )
 
+// init loads the .env.json file deployed by apex in the "file" envMode,
+// its variables taking precedence over the native environment.
 func init() {
 	env := make(map[string]string)
 
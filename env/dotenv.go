@@ -0,0 +1,124 @@
+package env
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// key pattern for variable names.
+var key = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// ParseFile parses the dotenv file at `path`.
+func ParseFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	vars, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%s", path, err)
+	}
+
+	return vars, nil
+}
+
+// Parse dotenv formatted variables from `r`. Lines may be prefixed
+// with "export", values may be single quoted (literal) or double
+// quoted (supporting \n, \t, \" and \\ escapes), and quoted values may
+// span multiple lines. Unquoted values are trimmed and may be followed
+// by a " #" comment.
+func Parse(r io.Reader) (map[string]string, error) {
+	vars := make(map[string]string)
+	s := bufio.NewScanner(r)
+	line := 0
+
+	for s.Scan() {
+		line++
+		text := strings.TrimSpace(s.Text())
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		text = strings.TrimPrefix(text, "export ")
+
+		i := strings.Index(text, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("%d: missing '=' in %q", line, text)
+		}
+
+		name := strings.TrimSpace(text[:i])
+		if !key.MatchString(name) {
+			return nil, fmt.Errorf("%d: invalid variable name %q", line, name)
+		}
+
+		value := strings.TrimSpace(text[i+1:])
+		start := line
+
+		if q := quote(value); q != 0 {
+			value = value[1:]
+
+			// consume lines until the closing quote
+			for !closed(value, q) {
+				if !s.Scan() {
+					return nil, fmt.Errorf("%d: unterminated quoted value for %s", start, name)
+				}
+				line++
+				value += "\n" + s.Text()
+			}
+
+			end := strings.LastIndexByte(value, q)
+			if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, fmt.Errorf("%d: unexpected %q after quoted value", line, rest)
+			}
+
+			value = value[:end]
+			if q == '"' {
+				value = unescape(value)
+			}
+		} else if i := strings.Index(value, " #"); i != -1 {
+			value = strings.TrimSpace(value[:i])
+		}
+
+		vars[name] = value
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return vars, nil
+}
+
+// quote returns the opening quote of `s`, if any.
+func quote(s string) byte {
+	if len(s) > 0 && (s[0] == '"' || s[0] == '\'') {
+		return s[0]
+	}
+	return 0
+}
+
+// closed returns true if `s` contains an unescaped closing quote `q`.
+func closed(s string, q byte) bool {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && q == '"':
+			i++
+		case s[i] == q:
+			return true
+		}
+	}
+	return false
+}
+
+// unescape double quoted value `s`.
+func unescape(s string) string {
+	r := strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\r`, "\r", `\"`, `"`, `\\`, `\`)
+	return r.Replace(s)
+}
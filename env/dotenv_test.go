@@ -0,0 +1,47 @@
+package env_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apex/apex/env"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	vars, err := env.Parse(strings.NewReader(`
+# comment
+FOO=bar
+export BAZ = qux # trailing comment
+EMPTY=
+SINGLE='literal \n $value'
+DOUBLE="tab\there \"quoted\""
+MULTI="line one
+line two"
+KEY='-----BEGIN-----
+abc
+-----END-----'
+`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"FOO":    "bar",
+		"BAZ":    "qux",
+		"EMPTY":  "",
+		"SINGLE": `literal \n $value`,
+		"DOUBLE": "tab\there \"quoted\"",
+		"MULTI":  "line one\nline two",
+		"KEY":    "-----BEGIN-----\nabc\n-----END-----",
+	}, vars)
+}
+
+func TestParse_errors(t *testing.T) {
+	_, err := env.Parse(strings.NewReader("FOO=bar\nBAZ"))
+	assert.EqualError(t, err, `2: missing '=' in "BAZ"`)
+
+	_, err = env.Parse(strings.NewReader("FOO=\"bar\n"))
+	assert.EqualError(t, err, `1: unterminated quoted value for FOO`)
+
+	_, err = env.Parse(strings.NewReader("1FOO=bar"))
+	assert.EqualError(t, err, `1: invalid variable name "1FOO"`)
+}
@@ -0,0 +1,101 @@
+// Package env implements loading of function environment variables,
+// including encrypted environment files.
+package env
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// Decrypter decrypts the contents of an encrypted env file.
+type Decrypter interface {
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// Decrypt returns the variables of encrypted env file contents `b`,
+// which must decrypt to a JSON object of strings.
+func Decrypt(d Decrypter, b []byte) (map[string]string, error) {
+	plain, err := d.Decrypt(b)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting env: %s", err)
+	}
+
+	vars := make(map[string]string)
+	if err := json.Unmarshal(plain, &vars); err != nil {
+		return nil, fmt.Errorf("decoding decrypted env: %s", err)
+	}
+
+	return vars, nil
+}
+
+// KMS decrypts base64 encoded KMS ciphertext, as output by:
+//
+//	aws kms encrypt --key-id <key> --plaintext fileb://.env.json \
+//	  --output text --query CiphertextBlob > .env.enc
+type KMS struct {
+	Service kmsiface.KMSAPI
+}
+
+// Decrypt implements Decrypter.
+func (k *KMS) Decrypt(ciphertext []byte) ([]byte, error) {
+	blob, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(ciphertext)))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := k.Service.Decrypt(&kms.DecryptInput{
+		CiphertextBlob: blob,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Plaintext, nil
+}
+
+// Encrypt returns base64 encoded ciphertext of `plaintext` using key `keyID`.
+func (k *KMS) Encrypt(keyID string, plaintext []byte) ([]byte, error) {
+	res, err := k.Service.Encrypt(&kms.EncryptInput{
+		KeyId:     aws.String(keyID),
+		Plaintext: plaintext,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(base64.StdEncoding.EncodeToString(res.CiphertextBlob)), nil
+}
+
+// Command decrypts by piping ciphertext through an external program,
+// for example []string{"sops", "-d", "--input-type", "json", "--output-type", "json", "/dev/stdin"}
+// or []string{"age", "-d", "-i", "key.txt"}.
+type Command []string
+
+// Decrypt implements Decrypter.
+func (c Command) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(c) == 0 {
+		return nil, fmt.Errorf("empty decrypt command")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(c[0], c[1:]...)
+	cmd.Stdin = bytes.NewReader(ciphertext)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,60 @@
+package env_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/apex/apex/env"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type kmsService struct {
+	kmsiface.KMSAPI
+}
+
+func (s *kmsService) Decrypt(in *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	if string(in.CiphertextBlob) != "ciphertext" {
+		return nil, errors.New("invalid ciphertext")
+	}
+	return &kms.DecryptOutput{Plaintext: []byte(`{"TOKEN":"secret"}`)}, nil
+}
+
+func (s *kmsService) Encrypt(in *kms.EncryptInput) (*kms.EncryptOutput, error) {
+	return &kms.EncryptOutput{CiphertextBlob: []byte("ciphertext")}, nil
+}
+
+func TestKMS(t *testing.T) {
+	k := &env.KMS{Service: &kmsService{}}
+
+	b, err := k.Encrypt("alias/apex", []byte(`{"TOKEN":"secret"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("ciphertext")), string(b))
+
+	vars, err := env.Decrypt(k, append(b, '\n'))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"TOKEN": "secret"}, vars)
+
+	_, err = env.Decrypt(k, []byte(base64.StdEncoding.EncodeToString([]byte("other"))))
+	assert.EqualError(t, err, "decrypting env: invalid ciphertext")
+
+	_, err = env.Decrypt(k, []byte("not base64!"))
+	assert.Error(t, err)
+}
+
+func TestCommand(t *testing.T) {
+	vars, err := env.Decrypt(env.Command{"cat"}, []byte(`{"TOKEN":"secret"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"TOKEN": "secret"}, vars)
+
+	_, err = env.Decrypt(env.Command{"cat"}, []byte(`TOKEN=secret`))
+	assert.Error(t, err)
+
+	_, err = env.Decrypt(env.Command{"sh", "-c", "echo bad key >&2; exit 1"}, nil)
+	assert.EqualError(t, err, "decrypting env: exit status 1: bad key")
+
+	_, err = env.Decrypt(env.Command{}, nil)
+	assert.EqualError(t, err, "decrypting env: empty decrypt command")
+}
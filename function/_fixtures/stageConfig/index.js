@@ -0,0 +1 @@
+exports.handle = function(e, ctx) { ctx.succeed() }
@@ -0,0 +1,279 @@
+package function
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// Alarm types.
+const (
+	AlarmErrors    = "errors"
+	AlarmErrorRate = "errorRate"
+	AlarmThrottles = "throttles"
+	AlarmDuration  = "duration"
+)
+
+// Alarm is a CloudWatch alarm on one of the function's metrics.
+type Alarm struct {
+	// Name of the alarm, defaulting to Type.
+	Name string `json:"name"`
+
+	// Type of alarm, one of "errors", "errorRate" (percent),
+	// "throttles" or "duration" (milliseconds).
+	Type string `json:"type"`
+
+	// Statistic used for duration alarms, defaulting to "p99".
+	Statistic string `json:"statistic"`
+
+	// Threshold triggering the alarm, defaulting to 1 for counts,
+	// 5 for errorRate and 80% of the timeout for duration.
+	Threshold float64 `json:"threshold"`
+
+	// Period in seconds, defaulting to 300.
+	Period int64 `json:"period"`
+
+	// EvaluationPeriods defaulting to 1.
+	EvaluationPeriods int64 `json:"evaluationPeriods"`
+
+	// Actions are the SNS topic ARNs notified on state changes.
+	Actions []string `json:"actions"`
+}
+
+// alarmPrefix returns the prefix of alarm names managed for the function.
+func (f *Function) alarmPrefix() string {
+	return fmt.Sprintf("apex/%s/", f.FunctionName)
+}
+
+// validateAlarms checks alarm types and names are valid.
+func (f *Function) validateAlarms() error {
+	names := make(map[string]bool)
+
+	for _, a := range f.Alarms {
+		switch a.Type {
+		case AlarmErrors, AlarmErrorRate, AlarmThrottles, AlarmDuration:
+		default:
+			return fmt.Errorf("Alarms: invalid type %q, must be one of %s, %s, %s, %s", a.Type, AlarmErrors, AlarmErrorRate, AlarmThrottles, AlarmDuration)
+		}
+
+		name := a.name()
+		if strings.HasPrefix(name, "budget-") {
+			return fmt.Errorf("Alarms: alarm name %q is reserved for budgets", name)
+		}
+
+		if names[name] {
+			return fmt.Errorf("Alarms: duplicate alarm %q", name)
+		}
+		names[name] = true
+	}
+
+	return nil
+}
+
+// name returns the alarm name.
+func (a *Alarm) name() string {
+	if a.Name == "" {
+		return a.Type
+	}
+	return a.Name
+}
+
+// DeployAlarms creates or updates the configured alarms and budget alarms,
+// and removes previously deployed alarms which are no longer configured.
+func (f *Function) DeployAlarms() error {
+	if f.CloudWatch == nil {
+		f.Log.Debug("skipping alarms, no CloudWatch service")
+		return nil
+	}
+
+	existing, err := f.alarmNames()
+	if err != nil {
+		return err
+	}
+
+	var inputs []*cloudwatch.PutMetricAlarmInput
+
+	for _, a := range f.Alarms {
+		inputs = append(inputs, f.alarmInput(f.alarmPrefix()+a.name(), a))
+	}
+
+	for _, in := range append(inputs, f.budgetAlarms()...) {
+		f.Log.Debugf("deploying alarm %s", *in.AlarmName)
+
+		if _, err := f.CloudWatch.PutMetricAlarm(in); err != nil {
+			return err
+		}
+
+		delete(existing, *in.AlarmName)
+	}
+
+	var stale []*string
+	for name := range existing {
+		f.Log.Infof("deleting alarm %s", name)
+		stale = append(stale, aws.String(name))
+	}
+
+	return f.deleteAlarms(stale)
+}
+
+// alarmNames returns the names of deployed alarms managed for the function.
+func (f *Function) alarmNames() (map[string]bool, error) {
+	names := make(map[string]bool)
+
+	err := f.CloudWatch.DescribeAlarmsPages(&cloudwatch.DescribeAlarmsInput{
+		AlarmNamePrefix: aws.String(f.alarmPrefix()),
+	}, func(page *cloudwatch.DescribeAlarmsOutput, last bool) bool {
+		for _, a := range page.MetricAlarms {
+			names[*a.AlarmName] = true
+		}
+		return true
+	})
+
+	return names, err
+}
+
+// deleteAlarms removes alarms `names`.
+func (f *Function) deleteAlarms(names []*string) error {
+	// DeleteAlarms accepts up to 100 names per call
+	for len(names) > 0 {
+		n := len(names)
+		if n > 100 {
+			n = 100
+		}
+
+		_, err := f.CloudWatch.DeleteAlarms(&cloudwatch.DeleteAlarmsInput{
+			AlarmNames: names[:n],
+		})
+
+		if err != nil {
+			return err
+		}
+
+		names = names[n:]
+	}
+
+	return nil
+}
+
+// removeAlarms removes all alarms managed for the function.
+func (f *Function) removeAlarms() error {
+	if f.CloudWatch == nil {
+		f.Log.Debug("skipping alarms, no CloudWatch service")
+		return nil
+	}
+
+	existing, err := f.alarmNames()
+	if err != nil {
+		return err
+	}
+
+	var names []*string
+	for name := range existing {
+		f.Log.Infof("deleting alarm %s", name)
+		names = append(names, aws.String(name))
+	}
+
+	return f.deleteAlarms(names)
+}
+
+// alarmInput returns the PutMetricAlarm input for alarm `a`.
+func (f *Function) alarmInput(name string, a *Alarm) *cloudwatch.PutMetricAlarmInput {
+	period := a.Period
+	if period == 0 {
+		period = 300
+	}
+
+	evaluations := a.EvaluationPeriods
+	if evaluations == 0 {
+		evaluations = 1
+	}
+
+	in := &cloudwatch.PutMetricAlarmInput{
+		AlarmName:          &name,
+		AlarmDescription:   aws.String(fmt.Sprintf("%s %s alarm managed by apex", f.FunctionName, a.Type)),
+		AlarmActions:       aws.StringSlice(a.Actions),
+		OKActions:          aws.StringSlice(a.Actions),
+		ComparisonOperator: aws.String("GreaterThanOrEqualToThreshold"),
+		EvaluationPeriods:  &evaluations,
+		Threshold:          aws.Float64(a.Threshold),
+		TreatMissingData:   aws.String("notBreaching"),
+	}
+
+	dimensions := []*cloudwatch.Dimension{
+		{
+			Name:  aws.String("FunctionName"),
+			Value: &f.FunctionName,
+		},
+	}
+
+	metric := func(id, metric string) *cloudwatch.MetricDataQuery {
+		return &cloudwatch.MetricDataQuery{
+			Id:         aws.String(id),
+			ReturnData: aws.Bool(false),
+			MetricStat: &cloudwatch.MetricStat{
+				Period: &period,
+				Stat:   aws.String("Sum"),
+				Metric: &cloudwatch.Metric{
+					Namespace:  aws.String("AWS/Lambda"),
+					MetricName: aws.String(metric),
+					Dimensions: dimensions,
+				},
+			},
+		}
+	}
+
+	switch a.Type {
+	case AlarmErrorRate:
+		if a.Threshold == 0 {
+			in.Threshold = aws.Float64(5)
+		}
+
+		in.Metrics = []*cloudwatch.MetricDataQuery{
+			metric("errors", "Errors"),
+			metric("invocations", "Invocations"),
+			{
+				Id:         aws.String("rate"),
+				Label:      aws.String("Error rate"),
+				Expression: aws.String("100 * errors / invocations"),
+				ReturnData: aws.Bool(true),
+			},
+		}
+
+		return in
+	case AlarmDuration:
+		if a.Threshold == 0 {
+			in.Threshold = aws.Float64(float64(f.Timeout) * 1000 * 0.8)
+		}
+
+		in.MetricName = aws.String("Duration")
+
+		stat := a.Statistic
+		if stat == "" {
+			stat = "p99"
+		}
+
+		if strings.HasPrefix(stat, "p") {
+			in.ExtendedStatistic = aws.String(stat)
+		} else {
+			in.Statistic = aws.String(stat)
+		}
+	case AlarmThrottles:
+		in.MetricName = aws.String("Throttles")
+		in.Statistic = aws.String("Sum")
+	default:
+		in.MetricName = aws.String("Errors")
+		in.Statistic = aws.String("Sum")
+	}
+
+	if a.Threshold == 0 && a.Type != AlarmDuration {
+		in.Threshold = aws.Float64(1)
+	}
+
+	in.Namespace = aws.String("AWS/Lambda")
+	in.Dimensions = dimensions
+	in.Period = &period
+
+	return in
+}
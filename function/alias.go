@@ -0,0 +1,81 @@
+package function
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// aliasName pattern for valid alias names, which must not be all digits.
+var aliasName = regexp.MustCompile(`^[A-Za-z0-9\-_]*[A-Za-z\-_][A-Za-z0-9\-_]*$`)
+
+// AliasName returns the name of the alias deployments are served from,
+// defaulting to CurrentAlias.
+func (f *Function) AliasName() string {
+	if f.Alias == "" {
+		return CurrentAlias
+	}
+	return f.Alias
+}
+
+// validateAlias checks the alias name is valid and not reserved.
+func (f *Function) validateAlias() error {
+	if f.Alias == "" {
+		return nil
+	}
+
+	if !aliasName.MatchString(f.Alias) || len(f.Alias) > 128 {
+		return fmt.Errorf("Alias: invalid name %q", f.Alias)
+	}
+
+	if f.Alias == PreviousAlias || f.Alias == verifyAlias {
+		return fmt.Errorf("Alias: name %q is reserved", f.Alias)
+	}
+
+	return nil
+}
+
+// migrateAlias creates the alias pointing at the version of CurrentAlias
+// when a newly configured alias does not yet exist, so that existing
+// deployments keep serving the same version from the new alias. The
+// CurrentAlias is left in place for clients still referencing it.
+func (f *Function) migrateAlias() error {
+	if f.AliasName() == CurrentAlias {
+		return nil
+	}
+
+	_, err := f.Service.GetAlias(&lambda.GetAliasInput{
+		FunctionName: &f.FunctionName,
+		Name:         aws.String(f.AliasName()),
+	})
+
+	if e, ok := err.(awserr.Error); !ok || e.Code() != "ResourceNotFoundException" {
+		return err
+	}
+
+	current, err := f.Service.GetAlias(&lambda.GetAliasInput{
+		FunctionName: &f.FunctionName,
+		Name:         aws.String(CurrentAlias),
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == "ResourceNotFoundException" {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	f.Log.Infof("migrating alias %s to %s at version %s", CurrentAlias, f.AliasName(), *current.FunctionVersion)
+
+	_, err = f.Service.CreateAlias(&lambda.CreateAliasInput{
+		FunctionName:    &f.FunctionName,
+		Name:            aws.String(f.AliasName()),
+		FunctionVersion: current.FunctionVersion,
+	})
+
+	return err
+}
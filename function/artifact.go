@@ -0,0 +1,151 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// DeployArtifact creates or updates the function with a zip built elsewhere,
+// such as by a CI pipeline, skipping the build. The `path` may be a local
+// file, an "s3://bucket/key" URI, optionally with a "?versionId=" query,
+// or the "sha256:" checksum of a zip in the Store. ErrUnchanged is
+// returned when the code is already deployed.
+func (f *Function) DeployArtifact(path string) error {
+	if f.Locker != nil {
+		if err := f.Locker.Lock(f.FunctionName); err != nil {
+			return err
+		}
+		defer f.unlock()
+	}
+
+	if err := f.deployArtifact(path); err != nil {
+		return err
+	}
+
+	return f.record()
+}
+
+// DeployPackage deploys the function as Deploy does, with the zip at `path`
+// written by Package in place of building it, such as a zip prepared offline.
+func (f *Function) DeployPackage(path string) error {
+	return f.deploy(func() error {
+		return f.deployArtifact(path)
+	})
+}
+
+// deployArtifact creates or updates the function with the artifact at `path`.
+func (f *Function) deployArtifact(path string) error {
+	f.Log.Infof("deploying artifact %s", path)
+
+	if strings.HasPrefix(path, "s3://") {
+		return f.deployS3Artifact(path)
+	}
+
+	if strings.HasPrefix(path, ChecksumPrefix) {
+		return f.deployStored(strings.TrimPrefix(path, ChecksumPrefix))
+	}
+
+	zip, err := openBundle(path)
+	if err != nil {
+		return err
+	}
+	defer zip.Close()
+
+	return f.deployZip(zip)
+}
+
+// deployS3Artifact creates or updates the function with the zip at s3 `uri`.
+func (f *Function) deployS3Artifact(uri string) error {
+	if f.Signing != nil {
+		return errors.New("signing requires a local artifact")
+	}
+
+	code, err := parseS3URI(uri)
+	if err != nil {
+		return err
+	}
+
+	return f.deployS3Code(code, "")
+}
+
+// deployS3Code creates or updates the function with the zip at `code`,
+// whose checksum `sum` is fetched from S3 when empty.
+func (f *Function) deployS3Code(code *lambda.FunctionCode, sum string) error {
+	info, err := f.getFunction()
+
+	if err == nil {
+		if sum == "" {
+			sum = f.artifactHash(code)
+		}
+
+		if sum == *info.Configuration.CodeSha256 {
+			f.Log.Info("unchanged")
+			return ErrUnchanged
+		}
+
+		if err := f.checkSigning(); err != nil {
+			return err
+		}
+
+		return f.update(code, 0)
+	}
+
+	if notFound(err) == ErrFunctionNotFound {
+		return f.create(code, 0)
+	}
+
+	return err
+}
+
+// artifactHash returns the SHA256 checksum S3 stores for `code`, which is
+// only present when the object was uploaded with a SHA256 checksum.
+func (f *Function) artifactHash(code *lambda.FunctionCode) string {
+	if f.S3 == nil {
+		return ""
+	}
+
+	res, err := f.S3.HeadObject(&s3.HeadObjectInput{
+		Bucket:       code.S3Bucket,
+		Key:          code.S3Key,
+		VersionId:    code.S3ObjectVersion,
+		ChecksumMode: aws.String(s3.ChecksumModeEnabled),
+	})
+
+	if err != nil {
+		f.Log.Debugf("error fetching artifact checksum: %s", err)
+		return ""
+	}
+
+	return aws.StringValue(res.ChecksumSHA256)
+}
+
+// parseS3URI returns the code location of s3 `uri`.
+func parseS3URI(uri string) (*lambda.FunctionCode, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	key := strings.TrimPrefix(u.Path, "/")
+
+	if u.Scheme != "s3" || u.Host == "" || key == "" {
+		return nil, fmt.Errorf("invalid s3 uri %q", uri)
+	}
+
+	code := &lambda.FunctionCode{
+		S3Bucket: aws.String(u.Host),
+		S3Key:    aws.String(key),
+	}
+
+	if v := u.Query().Get("versionId"); v != "" {
+		code.S3ObjectVersion = aws.String(v)
+	}
+
+	return code, nil
+}
@@ -0,0 +1,143 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// PreviousAlias is pinned to the version replaced by a blue/green
+// deploy, so that rolling back is an instant alias update.
+const PreviousAlias = "previous"
+
+// verifyAlias is the temporary alias of the version being verified.
+const verifyAlias = "apex-verify"
+
+// BlueGreen configures blue/green deploys, in which a newly published
+// version is verified before the current alias is switched to it.
+type BlueGreen struct {
+	// Verify are the events invoked against the new version, any error
+	// aborts the deploy leaving the current alias untouched.
+	Verify []json.RawMessage `json:"verify"`
+}
+
+// switchAlias points the current alias at `version`, verifying it first and
+// pinning the replaced version to PreviousAlias when BlueGreen is enabled.
+func (f *Function) switchAlias(version string) error {
+	if f.BlueGreen != nil {
+		if err := f.verify(version); err != nil {
+			return err
+		}
+
+		if err := f.pinPrevious(); err != nil {
+			return err
+		}
+	}
+
+	f.Log.Info("updating alias")
+
+	_, err := f.Service.UpdateAlias(&lambda.UpdateAliasInput{
+		FunctionName:    &f.FunctionName,
+		Name:            aws.String(f.AliasName()),
+		FunctionVersion: &version,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	f.emit(AliasUpdated{Function: f.Name, Alias: f.AliasName(), Version: version})
+	return nil
+}
+
+// verify invokes the BlueGreen Verify events against `version`
+// through a temporary alias, which is removed afterwards. Nothing
+// is verified when no version was published, as in dry-runs.
+func (f *Function) verify(version string) error {
+	if len(f.BlueGreen.Verify) == 0 || version == "$LATEST" {
+		return nil
+	}
+
+	if err := f.putAlias(verifyAlias, version); err != nil {
+		return err
+	}
+
+	defer func() {
+		_, err := f.Service.DeleteAlias(&lambda.DeleteAliasInput{
+			FunctionName: &f.FunctionName,
+			Name:         aws.String(verifyAlias),
+		})
+
+		if err != nil {
+			f.Log.Warnf("error removing alias %s: %s", verifyAlias, err)
+		}
+	}()
+
+	for i, event := range f.BlueGreen.Verify {
+		f.Log.Infof("verifying version %s (%d/%d)", version, i+1, len(f.BlueGreen.Verify))
+
+		_, _, err := f.InvokeWithOptions(event, InvokeOptions{
+			Qualifier: verifyAlias,
+			NoLogs:    true,
+		})
+
+		if err != nil {
+			return fmt.Errorf("verifying version %s: %s", version, err)
+		}
+	}
+
+	return nil
+}
+
+// pinPrevious points PreviousAlias at the version of the current alias.
+func (f *Function) pinPrevious() error {
+	alias, err := f.Service.GetAlias(&lambda.GetAliasInput{
+		FunctionName: &f.FunctionName,
+		Name:         aws.String(f.AliasName()),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	f.Log.Debugf("pinning version %s to alias %s", *alias.FunctionVersion, PreviousAlias)
+	return f.putAlias(PreviousAlias, *alias.FunctionVersion)
+}
+
+// previousVersion returns the version of PreviousAlias, if any.
+func (f *Function) previousVersion() string {
+	alias, err := f.Service.GetAlias(&lambda.GetAliasInput{
+		FunctionName: &f.FunctionName,
+		Name:         aws.String(PreviousAlias),
+	})
+
+	if err != nil {
+		return ""
+	}
+
+	return aws.StringValue(alias.FunctionVersion)
+}
+
+// putAlias creates or updates alias `name` pointing at `version`.
+func (f *Function) putAlias(name, version string) error {
+	_, err := f.Service.UpdateAlias(&lambda.UpdateAliasInput{
+		FunctionName:    &f.FunctionName,
+		Name:            &name,
+		FunctionVersion: &version,
+	})
+
+	if e, ok := err.(awserr.Error); !ok || e.Code() != "ResourceNotFoundException" {
+		return err
+	}
+
+	_, err = f.Service.CreateAlias(&lambda.CreateAliasInput{
+		FunctionName:    &f.FunctionName,
+		Name:            &name,
+		FunctionVersion: &version,
+	})
+
+	return err
+}
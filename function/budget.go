@@ -0,0 +1,160 @@
+package function
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// RequestPrice is the public Lambda price of a request in us-east-1.
+const RequestPrice = 0.20 / 1e6
+
+// GBSecondPrice is the public Lambda price of a GB-second
+// of compute in us-east-1 by architecture.
+var GBSecondPrice = map[string]float64{
+	X86_64: 0.0000166667,
+	Arm64:  0.0000133334,
+}
+
+// Budget is the monthly budget of a function, alarmed on daily at a
+// thirtieth of the monthly amounts, with ceilings on memory and timeout.
+type Budget struct {
+	// Invocations is the monthly invocation budget.
+	Invocations float64 `json:"invocations"`
+
+	// Cost is the monthly cost budget in the currency of the partition,
+	// USD or CNY in China, estimated from invocations and duration.
+	Cost float64 `json:"cost"`
+
+	// MaxMemory is the memory ceiling in MB.
+	MaxMemory int64 `json:"maxMemory"`
+
+	// MaxTimeout is the timeout ceiling in seconds.
+	MaxTimeout int64 `json:"maxTimeout"`
+
+	// Actions are the SNS topic ARNs notified when over budget.
+	Actions []string `json:"actions"`
+}
+
+// budget alarm names.
+const (
+	budgetInvocations = "budget-invocations"
+	budgetCost        = "budget-cost"
+)
+
+// validateBudget checks budget amounts are not negative.
+func (f *Function) validateBudget() error {
+	b := f.Budget
+	if b == nil {
+		return nil
+	}
+
+	if b.Invocations < 0 || b.Cost < 0 || b.MaxMemory < 0 || b.MaxTimeout < 0 {
+		return fmt.Errorf("Budget: amounts must not be negative")
+	}
+
+	return nil
+}
+
+// checkBudget returns an error when Memory or Timeout exceed
+// the budget ceilings, unless OverrideBudget is set.
+func (f *Function) checkBudget() error {
+	return f.checkCeilings(f.Memory, f.Timeout)
+}
+
+// checkCeilings returns an error when `memory` or `timeout` exceed the
+// budget ceilings, unless OverrideBudget is set. Zero values are not checked.
+func (f *Function) checkCeilings(memory, timeout int64) error {
+	b := f.Budget
+	if b == nil {
+		return nil
+	}
+
+	if f.OverrideBudget {
+		f.Log.Warn("overriding budget ceilings")
+		return nil
+	}
+
+	if b.MaxMemory > 0 && memory > b.MaxMemory {
+		return fmt.Errorf("Memory: %dMB exceeds the budget ceiling of %dMB", memory, b.MaxMemory)
+	}
+
+	if b.MaxTimeout > 0 && timeout > b.MaxTimeout {
+		return fmt.Errorf("Timeout: %ds exceeds the budget ceiling of %ds", timeout, b.MaxTimeout)
+	}
+
+	return nil
+}
+
+// budgetAlarms returns the PutMetricAlarm inputs of the budget alarms.
+func (f *Function) budgetAlarms() []*cloudwatch.PutMetricAlarmInput {
+	b := f.Budget
+	if b == nil {
+		return nil
+	}
+
+	var list []*cloudwatch.PutMetricAlarmInput
+
+	alarm := func(kind string, threshold float64) *cloudwatch.PutMetricAlarmInput {
+		return &cloudwatch.PutMetricAlarmInput{
+			AlarmName:          aws.String(f.alarmPrefix() + kind),
+			AlarmDescription:   aws.String(fmt.Sprintf("%s daily %s managed by apex", f.FunctionName, kind)),
+			AlarmActions:       aws.StringSlice(b.Actions),
+			OKActions:          aws.StringSlice(b.Actions),
+			ComparisonOperator: aws.String("GreaterThanThreshold"),
+			EvaluationPeriods:  aws.Int64(1),
+			Threshold:          aws.Float64(threshold / 30),
+			TreatMissingData:   aws.String("notBreaching"),
+		}
+	}
+
+	metric := func(id, metric string) *cloudwatch.MetricDataQuery {
+		return &cloudwatch.MetricDataQuery{
+			Id:         aws.String(id),
+			ReturnData: aws.Bool(false),
+			MetricStat: &cloudwatch.MetricStat{
+				Period: aws.Int64(86400),
+				Stat:   aws.String("Sum"),
+				Metric: &cloudwatch.Metric{
+					Namespace:  aws.String("AWS/Lambda"),
+					MetricName: aws.String(metric),
+					Dimensions: []*cloudwatch.Dimension{
+						{
+							Name:  aws.String("FunctionName"),
+							Value: &f.FunctionName,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	if b.Invocations > 0 {
+		in := alarm(budgetInvocations, b.Invocations)
+		in.Metrics = []*cloudwatch.MetricDataQuery{metric("invocations", "Invocations")}
+		in.Metrics[0].ReturnData = aws.Bool(true)
+		list = append(list, in)
+	}
+
+	if b.Cost > 0 {
+		gb := float64(f.Memory) / 1024
+		price := PriceOf(f.partition())
+		expr := fmt.Sprintf("invocations * %g + duration / 1000 * %g * %g", price.Request, gb, price.GBSecond[f.Arch()])
+
+		in := alarm(budgetCost, b.Cost)
+		in.Metrics = []*cloudwatch.MetricDataQuery{
+			metric("invocations", "Invocations"),
+			metric("duration", "Duration"),
+			{
+				Id:         aws.String("cost"),
+				Label:      aws.String("Estimated cost"),
+				Expression: aws.String(expr),
+				ReturnData: aws.Bool(true),
+			},
+		}
+		list = append(list, in)
+	}
+
+	return list
+}
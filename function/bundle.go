@@ -0,0 +1,136 @@
+package function
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// bundle is a zip with its size and SHA256 checksum, read from a file
+// rather than held in memory, so that large zips can be built, checked
+// and uploaded by runners with little memory.
+type bundle struct {
+	r    io.ReaderAt
+	size int64
+	sum  []byte
+	file *os.File
+	temp bool
+}
+
+// newBundle returns a bundle of zip `b` held in memory.
+func newBundle(b []byte) *bundle {
+	sum := sha256.Sum256(b)
+
+	return &bundle{
+		r:    bytes.NewReader(b),
+		size: int64(len(b)),
+		sum:  sum[:],
+	}
+}
+
+// spool returns a bundle of the zip written by `write` to a temporary
+// file, computing its checksum as it is written.
+func spool(write func(w io.Writer) error) (*bundle, error) {
+	file, err := ioutil.TempFile("", "apex-*.zip")
+	if err != nil {
+		return nil, err
+	}
+
+	b := &bundle{r: file, file: file, temp: true}
+	h := sha256.New()
+
+	if err := write(io.MultiWriter(file, h)); err != nil {
+		b.Close()
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+
+	b.size = info.Size()
+	b.sum = h.Sum(nil)
+	return b, nil
+}
+
+// openBundle returns a bundle of the zip at `path`, reading it once to compute its checksum.
+func openBundle(path string) (*bundle, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+
+	n, err := io.Copy(h, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &bundle{r: file, file: file, size: n, sum: h.Sum(nil)}, nil
+}
+
+// checksum returns the base64 checksum, as reported by Lambda for deployed code.
+func (b *bundle) checksum() string {
+	return base64.StdEncoding.EncodeToString(b.sum)
+}
+
+// hexChecksum returns the hex checksum.
+func (b *bundle) hexChecksum() string {
+	return hex.EncodeToString(b.sum)
+}
+
+// reader returns a reader of the zip from its start.
+func (b *bundle) reader() *io.SectionReader {
+	return io.NewSectionReader(b.r, 0, b.size)
+}
+
+// section returns a reader of `n` bytes of the zip from `offset`, which
+// may be read concurrently with other sections.
+func (b *bundle) section(offset, n int64) *io.SectionReader {
+	return io.NewSectionReader(b.r, offset, n)
+}
+
+// read reads the zip into memory, for the APIs only accepting it inline.
+func (b *bundle) read() ([]byte, error) {
+	return ioutil.ReadAll(b.reader())
+}
+
+// save writes the zip to `path`.
+func (b *bundle) save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, b.reader()); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Close closes the file of the bundle, removing it when temporary.
+func (b *bundle) Close() error {
+	if b.file == nil {
+		return nil
+	}
+
+	err := b.file.Close()
+
+	if b.temp {
+		if e := os.Remove(b.file.Name()); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,323 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Change is a change of configuration applied to deployed functions by
+// ApplyChange, such as bumping their runtime or adding a layer, without
+// building or deploying their code. Zero values are left unchanged.
+//
+// Changes are made to the deployed functions only, and are reverted by
+// deploys of a function.json configuring otherwise.
+type Change struct {
+	// Runtime is the Lambda runtime identifier, such as "nodejs22.x".
+	Runtime string `json:"runtime,omitempty"`
+
+	// AddLayers are versioned layer ARNs, replacing other versions of the
+	// same layer, if any, and appended otherwise.
+	AddLayers []string `json:"addLayers,omitempty"`
+
+	// RemoveLayers are layer ARNs removed, of any version when unversioned.
+	RemoveLayers []string `json:"removeLayers,omitempty"`
+
+	// Memory in MB.
+	Memory int64 `json:"memory,omitempty"`
+
+	// Timeout in seconds.
+	Timeout int64 `json:"timeout,omitempty"`
+
+	// LogRetention in days, one of LogRetentionDays.
+	LogRetention int64 `json:"logRetention,omitempty"`
+}
+
+// Validate checks the change changes something, and its values against
+// Lambda's limits.
+func (c *Change) Validate() error {
+	if c.Runtime == "" && len(c.AddLayers) == 0 && len(c.RemoveLayers) == 0 && c.Memory == 0 && c.Timeout == 0 && c.LogRetention == 0 {
+		return errors.New("no configuration change given")
+	}
+
+	if c.Memory != 0 && (c.Memory < MinMemory || c.Memory > MaxMemory) {
+		return fmt.Errorf("Memory: %dMB is outside the valid range of %d to %dMB", c.Memory, MinMemory, MaxMemory)
+	}
+
+	if c.Timeout < 0 || c.Timeout > MaxTimeout {
+		return fmt.Errorf("Timeout: %ds is outside the valid range of 1 to %ds", c.Timeout, MaxTimeout)
+	}
+
+	f := &Function{Config: Config{LogRetention: c.LogRetention}}
+	if err := f.validateLogRetention(); err != nil {
+		return err
+	}
+
+	for _, arn := range append(c.AddLayers, c.RemoveLayers...) {
+		if !strings.Contains(arn, ":layer:") {
+			return fmt.Errorf("Layers: %s is not a layer ARN", arn)
+		}
+	}
+
+	for _, arn := range c.AddLayers {
+		if layerName(arn) == arn {
+			return fmt.Errorf("Layers: %s is not a layer version ARN", arn)
+		}
+	}
+
+	return nil
+}
+
+// Update is a field of the deployed configuration of a function changed
+// by a Change.
+type Update struct {
+	// Field is the name of the field in function.json.
+	Field string
+
+	// From is the deployed value, empty when unset.
+	From string
+
+	// To is the value after the change.
+	To string
+}
+
+// String returns the field with its deployed and changed value.
+func (u *Update) String() string {
+	from, to := u.From, u.To
+	if from == "" {
+		from = "none"
+	}
+	if to == "" {
+		to = "none"
+	}
+	return fmt.Sprintf("%s: %s -> %s", u.Field, from, to)
+}
+
+// changePlan is the application of a Change to a function.
+type changePlan struct {
+	updates   []*Update
+	config    *lambda.UpdateFunctionConfigurationInput
+	retention int64
+}
+
+// PlanChange returns the updates change `c` makes to the deployed
+// configuration of the function, without applying them.
+func (f *Function) PlanChange(c *Change) ([]*Update, error) {
+	plan, err := f.planChange(c)
+	if err != nil {
+		return nil, err
+	}
+	return plan.updates, nil
+}
+
+// ApplyChange applies change `c` to the deployed configuration of the
+// function, publishing a version with the unchanged code and switching
+// the alias to it unless NoPublish is set, and returns the updates made.
+// ErrUnchanged is returned when the function already has the configuration.
+func (f *Function) ApplyChange(c *Change) ([]*Update, error) {
+	if f.Locker != nil {
+		if err := f.Locker.Lock(f.FunctionName); err != nil {
+			return nil, err
+		}
+		defer f.unlock()
+	}
+
+	plan, err := f.planChange(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(plan.updates) == 0 {
+		f.Log.Info("configuration unchanged")
+		return nil, ErrUnchanged
+	}
+
+	for _, u := range plan.updates {
+		f.Log.Infof("updating %s", u)
+	}
+
+	if plan.config != nil {
+		var updated *lambda.FunctionConfiguration
+
+		err := f.retryConflict(func() (err error) {
+			updated, err = f.Service.UpdateFunctionConfiguration(plan.config)
+			return err
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := f.waitReady(updated); err != nil {
+			return nil, err
+		}
+
+		if err := f.publishConfig(updated, "configuration"); err != nil {
+			return nil, err
+		}
+	}
+
+	if plan.retention != 0 {
+		f.LogRetention = plan.retention
+		if err := f.DeployLogGroup(); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan.updates, nil
+}
+
+// planChange compares the configuration after change `c` with that
+// deployed, returning the updates and the requests applying them.
+func (f *Function) planChange(c *Change) (*changePlan, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	cfg, err := f.Service.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+		FunctionName: &f.FunctionName,
+	})
+
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	if err := f.checkCeilings(c.Memory, c.Timeout); err != nil {
+		return nil, err
+	}
+
+	plan := &changePlan{}
+	in := &lambda.UpdateFunctionConfigurationInput{FunctionName: &f.FunctionName}
+
+	update := func(field, from, to string) bool {
+		if from == to {
+			return false
+		}
+		plan.updates = append(plan.updates, &Update{Field: field, From: from, To: to})
+		plan.config = in
+		return true
+	}
+
+	if c.Runtime != "" && update("runtime", aws.StringValue(cfg.Runtime), c.Runtime) {
+		in.Runtime = &c.Runtime
+	}
+
+	deployed := aws.StringValueSlice(layerArns(cfg.Layers))
+	layers := changeLayers(deployed, c.AddLayers, c.RemoveLayers)
+
+	if update("layers", strings.Join(deployed, ", "), strings.Join(layers, ", ")) {
+		in.Layers = aws.StringSlice(layers)
+	}
+
+	if c.Memory != 0 && update("memory", formatInt(cfg.MemorySize), strconv.FormatInt(c.Memory, 10)) {
+		in.MemorySize = &c.Memory
+	}
+
+	if c.Timeout != 0 && update("timeout", formatInt(cfg.Timeout), strconv.FormatInt(c.Timeout, 10)) {
+		in.Timeout = &c.Timeout
+	}
+
+	if c.LogRetention == 0 {
+		return plan, nil
+	}
+
+	if f.CloudWatchLogs == nil {
+		f.Log.Debug("skipping log retention, no CloudWatchLogs service")
+		return plan, nil
+	}
+
+	retention, err := f.logRetention()
+	if err != nil {
+		return nil, err
+	}
+
+	if retention != c.LogRetention {
+		plan.updates = append(plan.updates, &Update{
+			Field: "logRetention",
+			From:  formatInt(&retention),
+			To:    strconv.FormatInt(c.LogRetention, 10),
+		})
+		plan.retention = c.LogRetention
+	}
+
+	return plan, nil
+}
+
+// logRetention returns the retention in days of the log group, or 0
+// when it does not exist or never expires.
+func (f *Function) logRetention() (int64, error) {
+	name := f.LogGroupName()
+
+	res, err := f.CloudWatchLogs.DescribeLogGroups(&cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePrefix: &name,
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, g := range res.LogGroups {
+		if aws.StringValue(g.LogGroupName) == name {
+			return aws.Int64Value(g.RetentionInDays), nil
+		}
+	}
+
+	return 0, nil
+}
+
+// changeLayers returns the layer ARNs `deployed` with `add` replacing the
+// versions of the same layers or appended, and without `remove`.
+func changeLayers(deployed, add, remove []string) []string {
+	layers := []string{}
+
+	for _, arn := range deployed {
+		if !removesLayer(remove, arn) {
+			layers = append(layers, arn)
+		}
+	}
+
+outer:
+	for _, arn := range add {
+		for i, l := range layers {
+			if layerName(l) == layerName(arn) {
+				layers[i] = arn
+				continue outer
+			}
+		}
+		layers = append(layers, arn)
+	}
+
+	return layers
+}
+
+// removesLayer returns true if `remove` lists `arn`, or its layer unversioned.
+func removesLayer(remove []string, arn string) bool {
+	for _, r := range remove {
+		if r == arn || r == layerName(arn) {
+			return true
+		}
+	}
+	return false
+}
+
+// layerName returns layer version ARN `arn` without its version.
+func layerName(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) != 8 {
+		return arn
+	}
+	return strings.Join(parts[:7], ":")
+}
+
+// formatInt returns `n` formatted, or empty when unset or 0.
+func formatInt(n *int64) string {
+	if aws.Int64Value(n) == 0 {
+		return ""
+	}
+	return strconv.FormatInt(*n, 10)
+}
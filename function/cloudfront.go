@@ -0,0 +1,372 @@
+package function
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+)
+
+// MaxCloudFrontFunctionSize is the maximum size of CloudFront Function code.
+const MaxCloudFrontFunctionSize = 10 << 10
+
+// CloudFrontFunction deploys the function as a CloudFront Function in
+// place of Lambda, a small JavaScript handler run by CloudFront for the
+// viewer requests or responses of a distribution, where Lambda@Edge
+// is not needed. Lambda configuration such as the role is ignored.
+type CloudFrontFunction struct {
+	// File is the handler source, defaulting to "index.js".
+	File string `json:"file"`
+
+	// Runtime is "cloudfront-js-2.0" (the default) or "cloudfront-js-1.0".
+	Runtime string `json:"runtime"`
+
+	// Distribution id.
+	Distribution string `json:"distribution"`
+
+	// PathPattern of the cache behavior, defaulting to the default behavior.
+	PathPattern string `json:"pathPattern"`
+
+	// EventType is "viewer-request" or "viewer-response".
+	EventType string `json:"eventType"`
+
+	// KeyValueStore is the ARN of a CloudFront KeyValueStore
+	// available to "cloudfront-js-2.0" functions.
+	KeyValueStore string `json:"keyValueStore"`
+}
+
+// cloudFrontFunctionName pattern for valid CloudFront Function names.
+var cloudFrontFunctionName = regexp.MustCompile(`^[a-zA-Z0-9\-_]{1,64}$`)
+
+// file returns the handler source file.
+func (c *CloudFrontFunction) file() string {
+	if c.File == "" {
+		return "index.js"
+	}
+	return c.File
+}
+
+// runtime returns the runtime.
+func (c *CloudFrontFunction) runtime() string {
+	if c.Runtime == "" {
+		return cloudfront.FunctionRuntimeCloudfrontJs20
+	}
+	return c.Runtime
+}
+
+// validateCloudFrontFunction checks the CloudFront Function is associated
+// with a viewer event and its code is within the size limit.
+func (f *Function) validateCloudFrontFunction() error {
+	c := f.CloudFrontFunction
+
+	switch c.runtime() {
+	case cloudfront.FunctionRuntimeCloudfrontJs10, cloudfront.FunctionRuntimeCloudfrontJs20:
+	default:
+		return fmt.Errorf("CloudFrontFunction: invalid runtime %q, must be one of %s, %s", c.Runtime, cloudfront.FunctionRuntimeCloudfrontJs20, cloudfront.FunctionRuntimeCloudfrontJs10)
+	}
+
+	switch c.EventType {
+	case cloudfront.EventTypeViewerRequest, cloudfront.EventTypeViewerResponse:
+	default:
+		return fmt.Errorf("CloudFrontFunction: invalid eventType %q, must be one of viewer-request, viewer-response", c.EventType)
+	}
+
+	if c.Distribution == "" {
+		return fmt.Errorf("CloudFrontFunction: distribution is required")
+	}
+
+	if c.KeyValueStore != "" && c.runtime() != cloudfront.FunctionRuntimeCloudfrontJs20 {
+		return fmt.Errorf("CloudFrontFunction: keyValueStore requires the %s runtime", cloudfront.FunctionRuntimeCloudfrontJs20)
+	}
+
+	if f.Edge != nil {
+		return fmt.Errorf("CloudFrontFunction: cannot be combined with edge")
+	}
+
+	if !cloudFrontFunctionName.MatchString(f.FunctionName) {
+		return fmt.Errorf("CloudFrontFunction: invalid name %q, must be up to 64 letters, digits, hyphens or underscores", f.FunctionName)
+	}
+
+	_, err := f.cloudFrontFunctionCode()
+	return err
+}
+
+// cloudFrontFunctionCode returns the code of the CloudFront Function.
+func (f *Function) cloudFrontFunctionCode() ([]byte, error) {
+	b, err := ioutil.ReadFile(filepath.Join(f.Path, f.CloudFrontFunction.file()))
+	if err != nil {
+		return nil, fmt.Errorf("CloudFrontFunction: %s", err)
+	}
+
+	if len(b) > MaxCloudFrontFunctionSize {
+		return nil, &ErrTooLarge{Function: f.Name, Size: len(b), Limit: MaxCloudFrontFunctionSize}
+	}
+
+	return b, nil
+}
+
+// DeployCloudFrontFunction creates or updates the CloudFront Function
+// unless its live code is unchanged, publishes it, and associates it
+// with the cache behavior of the distribution.
+func (f *Function) DeployCloudFrontFunction() error {
+	if f.CloudFront == nil {
+		f.Log.Debug("skipping CloudFront Function, no CloudFront service")
+		return nil
+	}
+
+	code, err := f.cloudFrontFunctionCode()
+	if err != nil {
+		return err
+	}
+
+	c := f.CloudFrontFunction
+
+	config := &cloudfront.FunctionConfig{
+		Comment: aws.String(fmt.Sprintf("%s managed by apex", f.Name)),
+		Runtime: aws.String(c.runtime()),
+	}
+
+	if c.KeyValueStore != "" {
+		config.KeyValueStoreAssociations = &cloudfront.KeyValueStoreAssociations{
+			Quantity: aws.Int64(1),
+			Items:    []*cloudfront.KeyValueStoreAssociation{{KeyValueStoreARN: &c.KeyValueStore}},
+		}
+	}
+
+	live, err := f.CloudFront.DescribeFunction(&cloudfront.DescribeFunctionInput{
+		Name:  &f.FunctionName,
+		Stage: aws.String(cloudfront.FunctionStageLive),
+	})
+
+	switch e, ok := err.(awserr.Error); {
+	case ok && e.Code() == cloudfront.ErrCodeNoSuchFunctionExists:
+		f.Log.Info("creating CloudFront Function")
+
+		_, err := f.CloudFront.CreateFunction(&cloudfront.CreateFunctionInput{
+			Name:           &f.FunctionName,
+			FunctionCode:   code,
+			FunctionConfig: config,
+		})
+
+		if err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		res, err := f.CloudFront.GetFunction(&cloudfront.GetFunctionInput{
+			Name:  &f.FunctionName,
+			Stage: aws.String(cloudfront.FunctionStageLive),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(res.FunctionCode, code) && aws.StringValue(live.FunctionSummary.FunctionConfig.Runtime) == c.runtime() {
+			f.Log.Info("unchanged")
+			return f.associateCloudFrontFunction(aws.StringValue(live.FunctionSummary.FunctionMetadata.FunctionARN))
+		}
+
+		dev, err := f.CloudFront.DescribeFunction(&cloudfront.DescribeFunctionInput{
+			Name:  &f.FunctionName,
+			Stage: aws.String(cloudfront.FunctionStageDevelopment),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		f.Log.Info("updating CloudFront Function")
+
+		_, err = f.CloudFront.UpdateFunction(&cloudfront.UpdateFunctionInput{
+			Name:           &f.FunctionName,
+			IfMatch:        dev.ETag,
+			FunctionCode:   code,
+			FunctionConfig: config,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// the ETag of UpdateFunction responses is not decoded by the SDK
+	dev, err := f.CloudFront.DescribeFunction(&cloudfront.DescribeFunctionInput{
+		Name:  &f.FunctionName,
+		Stage: aws.String(cloudfront.FunctionStageDevelopment),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	f.Log.Info("publishing CloudFront Function")
+
+	res, err := f.CloudFront.PublishFunction(&cloudfront.PublishFunctionInput{
+		Name:    &f.FunctionName,
+		IfMatch: dev.ETag,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return f.associateCloudFrontFunction(aws.StringValue(res.FunctionSummary.FunctionMetadata.FunctionARN))
+}
+
+// associateCloudFrontFunction associates CloudFront Function `arn` with
+// the event type of the cache behavior of the distribution.
+func (f *Function) associateCloudFrontFunction(arn string) error {
+	c := f.CloudFrontFunction
+
+	return f.updateBehavior(c.Distribution, c.PathPattern, func(b *behavior) (bool, error) {
+		for _, a := range b.Functions.Items {
+			if aws.StringValue(a.EventType) != c.EventType {
+				continue
+			}
+
+			current := aws.StringValue(a.FunctionARN)
+			if current == arn {
+				f.Log.Debugf("%s of distribution %s unchanged", c.EventType, c.Distribution)
+				return false, nil
+			}
+
+			return false, fmt.Errorf("%s of distribution %s is associated with %s", c.EventType, c.Distribution, current)
+		}
+
+		f.Log.Infof("associating with %s of distribution %s", c.EventType, c.Distribution)
+		b.Functions.Items = append(b.Functions.Items, &cloudfront.FunctionAssociation{
+			EventType:   aws.String(c.EventType),
+			FunctionARN: &arn,
+		})
+		return true, nil
+	})
+}
+
+// deleteCloudFrontFunction disassociates the CloudFront Function from
+// the distribution and deletes it.
+func (f *Function) deleteCloudFrontFunction() error {
+	c := f.CloudFrontFunction
+
+	res, err := f.CloudFront.DescribeFunction(&cloudfront.DescribeFunctionInput{
+		Name: &f.FunctionName,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	arn := aws.StringValue(res.FunctionSummary.FunctionMetadata.FunctionARN)
+
+	err = f.updateBehavior(c.Distribution, c.PathPattern, func(b *behavior) (bool, error) {
+		var items []*cloudfront.FunctionAssociation
+
+		for _, a := range b.Functions.Items {
+			if aws.StringValue(a.FunctionARN) == arn {
+				f.Log.Infof("disassociating %s of distribution %s", aws.StringValue(a.EventType), c.Distribution)
+				continue
+			}
+			items = append(items, a)
+		}
+
+		changed := len(items) != len(b.Functions.Items)
+		b.Functions.Items = items
+		return changed, nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = f.CloudFront.DeleteFunction(&cloudfront.DeleteFunctionInput{
+		Name:    &f.FunctionName,
+		IfMatch: res.ETag,
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == cloudfront.ErrCodeFunctionInUse {
+		return fmt.Errorf("%s is still in use by distribution %s, retry the delete once it is deployed", f.FunctionName, c.Distribution)
+	}
+
+	return err
+}
+
+// behavior is the function associations of a cache behavior.
+type behavior struct {
+	Lambda    *cloudfront.LambdaFunctionAssociations
+	Functions *cloudfront.FunctionAssociations
+}
+
+// updateBehavior applies `fn` to the function associations of the cache
+// behavior of distribution `id` with `pattern`, or the default behavior,
+// updating the distribution when it returns true.
+func (f *Function) updateBehavior(id, pattern string, fn func(*behavior) (bool, error)) error {
+	res, err := f.CloudFront.GetDistributionConfig(&cloudfront.GetDistributionConfigInput{
+		Id: &id,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	b, err := findBehavior(res.DistributionConfig, pattern)
+	if err != nil {
+		return fmt.Errorf("distribution %s %s", id, err)
+	}
+
+	changed, err := fn(b)
+	if err != nil || !changed {
+		return err
+	}
+
+	b.Lambda.Quantity = aws.Int64(int64(len(b.Lambda.Items)))
+	b.Functions.Quantity = aws.Int64(int64(len(b.Functions.Items)))
+
+	_, err = f.CloudFront.UpdateDistribution(&cloudfront.UpdateDistributionInput{
+		Id:                 &id,
+		IfMatch:            res.ETag,
+		DistributionConfig: res.DistributionConfig,
+	})
+
+	return err
+}
+
+// findBehavior returns the function associations of the cache
+// behavior of `cfg` with `pattern`, or of the default behavior.
+func findBehavior(cfg *cloudfront.DistributionConfig, pattern string) (*behavior, error) {
+	var lambdas **cloudfront.LambdaFunctionAssociations
+	var functions **cloudfront.FunctionAssociations
+
+	if pattern == "" && cfg.DefaultCacheBehavior != nil {
+		lambdas = &cfg.DefaultCacheBehavior.LambdaFunctionAssociations
+		functions = &cfg.DefaultCacheBehavior.FunctionAssociations
+	}
+
+	if pattern != "" && cfg.CacheBehaviors != nil {
+		for _, b := range cfg.CacheBehaviors.Items {
+			if aws.StringValue(b.PathPattern) == pattern {
+				lambdas = &b.LambdaFunctionAssociations
+				functions = &b.FunctionAssociations
+			}
+		}
+	}
+
+	if lambdas == nil {
+		return nil, fmt.Errorf("has no %q cache behavior", pattern)
+	}
+
+	if *lambdas == nil {
+		*lambdas = &cloudfront.LambdaFunctionAssociations{Quantity: aws.Int64(0)}
+	}
+
+	if *functions == nil {
+		*functions = &cloudfront.FunctionAssociations{Quantity: aws.Int64(0)}
+	}
+
+	return &behavior{Lambda: *lambdas, Functions: *functions}, nil
+}
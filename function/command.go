@@ -0,0 +1,124 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/apex/apex/runtime"
+)
+
+// Command is a command specified as a string run with "sh -c",
+// or as an array of the program and its arguments.
+type Command []string
+
+// UnmarshalJSON implementation.
+func (c *Command) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*c = Command{"sh", "-c", s}
+		return nil
+	}
+
+	var args []string
+	if err := json.Unmarshal(b, &args); err != nil {
+		return errors.New("expected a command string or array of strings")
+	}
+
+	if len(args) == 0 {
+		return errors.New("command must not be empty")
+	}
+
+	*c = args
+	return nil
+}
+
+// runBuild runs the Build command in the function directory in place
+// of the runtime's build, with APEX_FUNCTION_NAME and APEX_ARCH set.
+func (f *Function) runBuild(ctx context.Context, arch string) error {
+	f.Log.Debugf("running build command %q", []string(f.Build))
+	return f.runContext(ctx, f.Build, "APEX_ARCH="+arch)
+}
+
+// Test runs the Test command, or the runtime's native test command such as
+// "go test" or "npm test", in the function directory. Functions without
+// tests are skipped.
+func (f *Function) Test() error {
+	args := []string(f.TestCommand)
+
+	if r, ok := f.runtime.(runtime.TestRuntime); ok && len(args) == 0 {
+		args = r.TestCommand(f.Path)
+	}
+
+	if len(args) == 0 {
+		f.Log.Debug("no tests")
+		return nil
+	}
+
+	f.Log.Infof("testing with %q", args)
+
+	if err := f.run(args); err != nil {
+		return fmt.Errorf("tests failed: %s", err)
+	}
+
+	return nil
+}
+
+// Audit scans the function's dependencies for vulnerabilities of at least
+// severity `level` with the runtime's scanner, such as "npm audit" or
+// govulncheck, returning an error on findings. Runtimes without a
+// scanner are skipped.
+func (f *Function) Audit(level string) error {
+	r, ok := f.runtime.(runtime.AuditRuntime)
+	if !ok {
+		f.Log.Debugf("no vulnerability scanner for runtime %s", f.Runtime)
+		return nil
+	}
+
+	args := r.AuditCommand(f.Path, level)
+	if len(args) == 0 {
+		f.Log.Debug("no dependencies to scan")
+		return nil
+	}
+
+	f.Log.Infof("scanning dependencies with %q", args)
+
+	if err := f.run(args); err != nil {
+		return fmt.Errorf("vulnerabilities found: %s", err)
+	}
+
+	return nil
+}
+
+// run `args` in the function directory with APEX_FUNCTION_NAME,
+// the git metadata and `env` added to the environment.
+func (f *Function) run(args []string, env ...string) error {
+	return f.runContext(context.Background(), args, env...)
+}
+
+// runContext runs `args` as run does, killing the command when `ctx` is done.
+func (f *Function) runContext(ctx context.Context, args []string, env ...string) error {
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = f.Path
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), f.commandEnv(env...)...)
+	return cmd.Run()
+}
+
+// commandEnv returns APEX_FUNCTION_NAME, the git metadata and `env`,
+// added to the environment of commands run for the function.
+func (f *Function) commandEnv(env ...string) []string {
+	vars := append([]string{"APEX_FUNCTION_NAME=" + f.Name}, env...)
+
+	if f.Git != nil {
+		for k, v := range f.Git.Env() {
+			vars = append(vars, k+"="+v)
+		}
+	}
+
+	return vars
+}
@@ -0,0 +1,330 @@
+package function
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	goruntime "runtime"
+	"sync"
+)
+
+// DefaultCompressionLevel is the deflate level of zips, as used by archive/zip.
+const DefaultCompressionLevel = 5
+
+// maxBuffered is the size of the largest file compressed in parallel into
+// memory. Larger files are compressed as they are written to the zip, so
+// the memory used is bounded regardless of the size of the files.
+const maxBuffered = 8 << 20
+
+// Compression configures the compression of zips, whose files are
+// compressed in parallel. Zips are identical regardless of concurrency.
+type Compression struct {
+	// Level of deflate compression, from 1 for the fastest to 9 for
+	// the smallest zips, defaulting to 5.
+	Level int `json:"level"`
+
+	// Store lists patterns of files stored without compression, such as
+	// already compressed assets like "*.png" or "*.gz". Patterns without a
+	// slash match the base name of files in any directory, so "*" stores
+	// every file.
+	Store []string `json:"store"`
+
+	// Concurrency is the number of files compressed in parallel,
+	// defaulting to the number of CPUs.
+	Concurrency int `json:"concurrency"`
+}
+
+// level returns the deflate level.
+func (c *Compression) level() int {
+	if c == nil || c.Level == 0 {
+		return DefaultCompressionLevel
+	}
+	return c.Level
+}
+
+// concurrency returns the number of files compressed in parallel.
+func (c *Compression) concurrency() int {
+	if c == nil || c.Concurrency == 0 {
+		return goruntime.NumCPU()
+	}
+	return c.Concurrency
+}
+
+// stored returns true if the file `name` is stored without compression.
+func (c *Compression) stored(name string) bool {
+	return c != nil && matchAny(c.Store, name)
+}
+
+// validateCompression checks the level, Store patterns and concurrency.
+func (f *Function) validateCompression() error {
+	c := f.Compression
+	if c == nil {
+		return nil
+	}
+
+	if c.Level < 0 || c.Level > flate.BestCompression {
+		return fmt.Errorf("Compression: level must be between 1 and %d", flate.BestCompression)
+	}
+
+	for _, pattern := range c.Store {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Compression: invalid store pattern %q", pattern)
+		}
+	}
+
+	if c.Concurrency < 0 {
+		return fmt.Errorf("Compression: concurrency must not be negative")
+	}
+
+	return nil
+}
+
+// zipWriter writes a zip, compressing its files in parallel and writing
+// them in the order they were added.
+type zipWriter struct {
+	zip     *zip.Writer
+	options *Compression
+	sem     chan struct{}
+	queue   chan *zipEntry
+	done    chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// zipEntry is a file of a zip, compressed into memory unless streamed.
+type zipEntry struct {
+	header *zip.FileHeader
+	open   func() (io.ReadCloser, error)
+	stream bool
+	body   bytes.Buffer
+	ready  chan struct{}
+	err    error
+}
+
+// newZipWriter returns a writer of a zip to `w` compressed with `c`, which may be nil.
+func newZipWriter(w io.Writer, c *Compression) *zipWriter {
+	z := &zipWriter{
+		zip:     zip.NewWriter(w),
+		options: c,
+		sem:     make(chan struct{}, c.concurrency()),
+		queue:   make(chan *zipEntry, c.concurrency()),
+		done:    make(chan struct{}),
+	}
+
+	z.zip.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, c.level())
+	})
+
+	go z.write()
+	return z
+}
+
+// AddBytes adds a file `name` with contents `b`.
+func (z *zipWriter) AddBytes(name string, b []byte) error {
+	return z.add(&zip.FileHeader{Name: name}, int64(len(b)), openBytes(b))
+}
+
+// AddInfoBytes adds a file `name` with the mode and time of `info` and contents `b`.
+func (z *zipWriter) AddInfoBytes(name string, info os.FileInfo, b []byte) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+
+	header.Name = name
+	return z.add(header, int64(len(b)), openBytes(b))
+}
+
+// AddFile adds a file `name` with the mode and time of `info`
+// and the contents of `path`, which is read when compressed.
+func (z *zipWriter) AddFile(name, path string, info os.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+
+	header.Name = name
+
+	return z.add(header, info.Size(), func() (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+}
+
+// AddDir adds the files of `dir`, relative to it, except those whose
+// relative name `skip` returns true for, when non-nil.
+func (z *zipWriter) AddDir(dir string, skip func(name string) bool) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(rel)
+		if skip != nil && skip(name) {
+			return nil
+		}
+
+		return z.AddFile(name, path, info)
+	})
+}
+
+// add a file with `header` and `size` bytes read from `open`, compressing
+// it in parallel when small enough, returning the first error of the zip.
+func (z *zipWriter) add(header *zip.FileHeader, size int64, open func() (io.ReadCloser, error)) error {
+	if err := z.failed(); err != nil {
+		return err
+	}
+
+	header.Method = zip.Deflate
+	if z.options.stored(header.Name) {
+		header.Method = zip.Store
+	}
+
+	e := &zipEntry{
+		header: header,
+		open:   open,
+		stream: size > maxBuffered,
+		ready:  make(chan struct{}),
+	}
+
+	if e.stream {
+		close(e.ready)
+	} else {
+		z.sem <- struct{}{}
+
+		go func() {
+			defer func() { <-z.sem }()
+			defer close(e.ready)
+			e.err = e.compress(z.options.level())
+		}()
+	}
+
+	z.queue <- e
+	return nil
+}
+
+// write the entries in the order they were added, until the queue is closed.
+func (z *zipWriter) write() {
+	defer close(z.done)
+
+	for e := range z.queue {
+		<-e.ready
+
+		if z.failed() != nil {
+			continue
+		}
+
+		if err := z.writeEntry(e); err != nil {
+			z.mu.Lock()
+			z.err = fmt.Errorf("adding %s: %s", e.header.Name, err)
+			z.mu.Unlock()
+		}
+	}
+}
+
+// writeEntry writes `e` to the zip, compressing it now when streamed.
+func (z *zipWriter) writeEntry(e *zipEntry) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	if !e.stream {
+		w, err := z.zip.CreateRaw(e.header)
+		if err != nil {
+			return err
+		}
+
+		_, err = e.body.WriteTo(w)
+		return err
+	}
+
+	w, err := z.zip.CreateHeader(e.header)
+	if err != nil {
+		return err
+	}
+
+	r, err := e.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// failed returns the first error writing the zip, if any.
+func (z *zipWriter) failed() error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.err
+}
+
+// Close waits for the files to be written and finishes the zip.
+func (z *zipWriter) Close() error {
+	close(z.queue)
+	<-z.done
+
+	if err := z.failed(); err != nil {
+		return err
+	}
+
+	return z.zip.Close()
+}
+
+// compress the contents of the entry into memory at deflate `level`,
+// or as is when stored, setting the checksum and sizes of its header.
+func (e *zipEntry) compress(level int) error {
+	r, err := e.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var w io.Writer = &e.body
+	var fw *flate.Writer
+
+	if e.header.Method == zip.Deflate {
+		if fw, err = flate.NewWriter(&e.body, level); err != nil {
+			return err
+		}
+		w = fw
+	}
+
+	crc := crc32.NewIEEE()
+
+	n, err := io.Copy(io.MultiWriter(w, crc), r)
+	if err != nil {
+		return err
+	}
+
+	if fw != nil {
+		if err := fw.Close(); err != nil {
+			return err
+		}
+	}
+
+	e.header.CRC32 = crc.Sum32()
+	e.header.UncompressedSize64 = uint64(n)
+	e.header.CompressedSize64 = uint64(e.body.Len())
+	return nil
+}
+
+// openBytes returns a function opening a reader of `b`.
+func openBytes(b []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+}
@@ -0,0 +1,52 @@
+package function
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// DefaultConflictTimeout is the default maximum wait for an in-progress
+// update to complete before an update is retried.
+const DefaultConflictTimeout = 5 * time.Minute
+
+// conflictTimeout returns the maximum wait for in-progress updates.
+func (f *Function) conflictTimeout() time.Duration {
+	if f.ConflictTimeout == 0 {
+		return DefaultConflictTimeout
+	}
+	return time.Duration(f.ConflictTimeout) * time.Second
+}
+
+// isConflict returns true if `err` reports an update already in progress.
+func isConflict(err error) bool {
+	e, ok := err.(awserr.Error)
+	return ok && e.Code() == "ResourceConflictException"
+}
+
+// retryConflict calls `fn`, retrying with backoff while Lambda reports a
+// previous update of the function is in progress, up to ConflictTimeout.
+// Once the deploy deadline has passed no further calls are made.
+func (f *Function) retryConflict(fn func() error) error {
+	deadline := time.Now().Add(f.conflictTimeout())
+	interval := readyInterval
+
+	for {
+		if err := f.deployExpired(); err != nil {
+			return err
+		}
+
+		err := fn()
+
+		if !isConflict(err) || time.Now().Add(interval).After(deadline) {
+			return err
+		}
+
+		f.Log.Infof("update in progress, retrying in %s", interval)
+		time.Sleep(interval)
+
+		if interval *= 2; interval > readyMaxInterval {
+			interval = readyMaxInterval
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package function
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+// CopyTarget is the function a function is copied to, in the same or
+// another region or account.
+type CopyTarget struct {
+	// FunctionName of the copy, defaulting to that of the function.
+	FunctionName string
+
+	// Service is the Lambda service of the region or account of the copy.
+	Service lambdaiface.LambdaAPI
+
+	// Account is the id of the account of the copy, when known. Copies to
+	// another account than the function's role require Role, as roles
+	// are not shared between accounts.
+	Account string
+
+	// Role of the copy, defaulting to the role of the function.
+	Role string
+}
+
+// CopyTo replicates the code and configuration serving the alias of the
+// function to `target`, creating or updating it, publishing a version
+// and pointing the alias of the same name at it. VPC, dead letter and
+// KMS configuration is specific to the network and account of the
+// function, and is not copied. Layers are copied by ARN, so must be
+// available to the target.
+func (f *Function) CopyTo(target *CopyTarget) error {
+	name := target.FunctionName
+	if name == "" {
+		name = f.FunctionName
+	}
+
+	if name == f.FunctionName && target.Service == f.Service {
+		return fmt.Errorf("cannot copy %s to itself", name)
+	}
+
+	f.Log.Infof("copying to %s", name)
+
+	res, err := f.Service.GetFunction(&lambda.GetFunctionInput{
+		FunctionName: &f.FunctionName,
+		Qualifier:    aws.String(f.AliasName()),
+	})
+
+	if err != nil {
+		return notFound(err)
+	}
+
+	cfg := res.Configuration
+
+	role, err := target.role(aws.StringValue(cfg.Role))
+	if err != nil {
+		return err
+	}
+
+	zip, err := f.fetch(res, f.AliasName())
+	if err != nil {
+		return err
+	}
+	defer zip.Close()
+
+	if zip.size > MaxZipSize {
+		return &ErrTooLarge{Function: f.Name, Size: int(zip.size), Limit: MaxZipSize}
+	}
+
+	b, err := zip.read()
+	if err != nil {
+		return err
+	}
+
+	copied := &Function{
+		Name:         f.Name,
+		FunctionName: name,
+		Service:      target.Service,
+		Log:          f.Log.WithField("copy", name),
+	}
+
+	_, err = target.Service.GetFunction(&lambda.GetFunctionInput{
+		FunctionName: &name,
+	})
+
+	switch notFound(err) {
+	case nil:
+		return copied.updateCopy(cfg, role, b, f.AliasName())
+	case ErrFunctionNotFound:
+		return copied.createCopy(cfg, role, b, f.AliasName())
+	default:
+		return err
+	}
+}
+
+// role returns the role of the copy of a function with `role`.
+func (t *CopyTarget) role(role string) (string, error) {
+	if t.Role != "" {
+		return t.Role, nil
+	}
+
+	if t.Account != "" && roleAccount(role) != t.Account {
+		return "", fmt.Errorf("copying to account %s requires a role, as %s is in another account", t.Account, role)
+	}
+
+	return role, nil
+}
+
+// roleAccount returns the account id of role ARN `arn`.
+func roleAccount(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 5 {
+		return ""
+	}
+	return parts[4]
+}
+
+// createCopy creates the function with `zip` and the configuration `cfg`
+// of the function it copies, publishing a version and creating `alias`.
+func (f *Function) createCopy(cfg *lambda.FunctionConfiguration, role string, zip []byte, alias string) error {
+	f.Log.Info("creating function")
+
+	in := &lambda.CreateFunctionInput{
+		FunctionName:     &f.FunctionName,
+		Description:      cfg.Description,
+		Handler:          cfg.Handler,
+		MemorySize:       cfg.MemorySize,
+		Timeout:          cfg.Timeout,
+		Runtime:          cfg.Runtime,
+		Role:             &role,
+		Architectures:    cfg.Architectures,
+		EphemeralStorage: cfg.EphemeralStorage,
+		LoggingConfig:    cfg.LoggingConfig,
+		Layers:           layerArns(cfg.Layers),
+		Publish:          aws.Bool(true),
+		Code:             &lambda.FunctionCode{ZipFile: zip},
+	}
+
+	if cfg.Environment != nil {
+		in.Environment = &lambda.Environment{Variables: cfg.Environment.Variables}
+	}
+
+	if cfg.TracingConfig != nil {
+		in.TracingConfig = &lambda.TracingConfig{Mode: cfg.TracingConfig.Mode}
+	}
+
+	created, err := f.Service.CreateFunction(in)
+	if err != nil {
+		return err
+	}
+
+	if err := f.waitReady(created); err != nil {
+		return err
+	}
+
+	f.Log.Infof("creating alias %s", alias)
+
+	_, err = f.Service.CreateAlias(&lambda.CreateAliasInput{
+		FunctionName:    &f.FunctionName,
+		FunctionVersion: created.Version,
+		Name:            &alias,
+	})
+
+	return err
+}
+
+// updateCopy updates the function with `zip` and the configuration `cfg`
+// of the function it copies, publishing a version and pointing `alias` at it.
+func (f *Function) updateCopy(cfg *lambda.FunctionConfiguration, role string, zip []byte, alias string) error {
+	f.Log.Info("updating function")
+
+	updated, err := f.Service.UpdateFunctionCode(&lambda.UpdateFunctionCodeInput{
+		FunctionName:  &f.FunctionName,
+		ZipFile:       zip,
+		Architectures: cfg.Architectures,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := f.waitReady(updated); err != nil {
+		return err
+	}
+
+	in := &lambda.UpdateFunctionConfigurationInput{
+		FunctionName:     &f.FunctionName,
+		Description:      cfg.Description,
+		Handler:          cfg.Handler,
+		MemorySize:       cfg.MemorySize,
+		Timeout:          cfg.Timeout,
+		Runtime:          cfg.Runtime,
+		Role:             &role,
+		EphemeralStorage: cfg.EphemeralStorage,
+		LoggingConfig:    cfg.LoggingConfig,
+		Layers:           layerArns(cfg.Layers),
+		Environment:      &lambda.Environment{Variables: map[string]*string{}},
+	}
+
+	if cfg.Environment != nil {
+		in.Environment.Variables = cfg.Environment.Variables
+	}
+
+	if cfg.TracingConfig != nil {
+		in.TracingConfig = &lambda.TracingConfig{Mode: cfg.TracingConfig.Mode}
+	}
+
+	if updated, err = f.Service.UpdateFunctionConfiguration(in); err != nil {
+		return err
+	}
+
+	if err := f.waitReady(updated); err != nil {
+		return err
+	}
+
+	f.Log.Info("publishing version")
+
+	v, err := f.Service.PublishVersion(&lambda.PublishVersionInput{
+		FunctionName: &f.FunctionName,
+		CodeSha256:   updated.CodeSha256,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	f.Log.Infof("updating alias %s to version %s", alias, aws.StringValue(v.Version))
+
+	_, err = f.Service.UpdateAlias(&lambda.UpdateAliasInput{
+		FunctionName:    &f.FunctionName,
+		FunctionVersion: v.Version,
+		Name:            &alias,
+	})
+
+	if notFound(err) == ErrFunctionNotFound {
+		_, err = f.Service.CreateAlias(&lambda.CreateAliasInput{
+			FunctionName:    &f.FunctionName,
+			FunctionVersion: v.Version,
+			Name:            &alias,
+		})
+	}
+
+	return err
+}
+
+// layerArns returns the ARNs of `layers`.
+func layerArns(layers []*lambda.Layer) (arns []*string) {
+	for _, l := range layers {
+		arns = append(arns, l.Arn)
+	}
+	return
+}
@@ -0,0 +1,132 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Deploy steps bounded by Deadlines.
+const (
+	StepBuild  = "build"
+	StepUpload = "upload"
+	StepWait   = "wait"
+	StepDeploy = "deploy"
+)
+
+// Deadlines bound the steps of deploys, in seconds, so that an unreliable
+// network or a wedged build cannot hang CI jobs indefinitely. Zero leaves
+// a step bounded only by the Deploy deadline, if any.
+type Deadlines struct {
+	// Build is the deadline of builds, whose commands are killed on
+	// expiry, cleaning the partial artifacts of compiled runtimes.
+	Build int64 `json:"build"`
+
+	// Upload is the deadline of each upload of code to Lambda.
+	Upload int64 `json:"upload"`
+
+	// Wait is the deadline of each wait for changes to propagate, such as
+	// the function becoming ready, defaulting to ReadyTimeout for Lambda.
+	Wait int64 `json:"wait"`
+
+	// Deploy is the deadline of the whole deploy of the function. The lock
+	// is released and event sources resumed when it is exceeded, as they
+	// are on any failed deploy.
+	Deploy int64 `json:"deploy"`
+}
+
+// limit returns the deadline of `step`, zero when unbounded.
+func (d *Deadlines) limit(step string) time.Duration {
+	if d == nil {
+		return 0
+	}
+
+	var n int64
+
+	switch step {
+	case StepBuild:
+		n = d.Build
+	case StepUpload:
+		n = d.Upload
+	case StepWait:
+		n = d.Wait
+	case StepDeploy:
+		n = d.Deploy
+	}
+
+	return time.Duration(n) * time.Second
+}
+
+// validateDeadlines checks deadlines are not negative.
+func (f *Function) validateDeadlines() error {
+	d := f.Deadlines
+	if d == nil {
+		return nil
+	}
+
+	for _, step := range []string{StepBuild, StepUpload, StepWait, StepDeploy} {
+		if d.limit(step) < 0 {
+			return fmt.Errorf("Deadlines: %s must not be negative", step)
+		}
+	}
+
+	return nil
+}
+
+// deadlineOf returns when `step` starting now must complete, after its
+// deadline or `d` by default, or at the deploy deadline if sooner, along
+// with the error returned on expiry. The time is zero when unbounded.
+func (f *Function) deadlineOf(step string, d time.Duration) (time.Time, *ErrDeadline) {
+	if n := f.Deadlines.limit(step); n > 0 {
+		d = n
+	}
+
+	var t time.Time
+	if d > 0 {
+		t = time.Now().Add(d)
+	}
+
+	if !f.deadline.IsZero() && (t.IsZero() || f.deadline.Before(t)) {
+		return f.deadline, &ErrDeadline{Function: f.Name, Step: StepDeploy, Limit: f.Deadlines.limit(StepDeploy)}
+	}
+
+	return t, &ErrDeadline{Function: f.Name, Step: step, Limit: d}
+}
+
+// stepContext returns a context of `step` cancelled at its deadline, along
+// with the error returned on expiry. The context has no deadline when
+// the step is unbounded.
+func (f *Function) stepContext(step string) (context.Context, context.CancelFunc, *ErrDeadline) {
+	t, expired := f.deadlineOf(step, 0)
+
+	if t.IsZero() {
+		ctx, cancel := context.WithCancel(context.Background())
+		return ctx, cancel, expired
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), t)
+	return ctx, cancel, expired
+}
+
+// bounded returns true if `ctx` has a deadline.
+func bounded(ctx context.Context) bool {
+	_, ok := ctx.Deadline()
+	return ok
+}
+
+// deadlineErr returns `expired` when `ctx` exceeded its deadline, otherwise `err`.
+func deadlineErr(ctx context.Context, err error, expired *ErrDeadline) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return expired
+	}
+	return err
+}
+
+// deployExpired returns an error when the deploy deadline has passed.
+func (f *Function) deployExpired() error {
+	if f.deadline.IsZero() || time.Now().Before(f.deadline) {
+		return nil
+	}
+
+	return &ErrDeadline{Function: f.Name, Step: StepDeploy, Limit: f.Deadlines.limit(StepDeploy)}
+}
@@ -0,0 +1,90 @@
+package function
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"text/template"
+	"time"
+
+	"github.com/apex/apex/git"
+)
+
+// MaxDescriptionLength is the maximum length of version descriptions.
+const MaxDescriptionLength = 256
+
+// Deploy is the data of VersionDescription templates, such as
+// "{{.Git.Short}} by {{.Deployer}} at {{.Time}}: {{.Git.Message}}".
+type Deploy struct {
+	Function *Function
+	Git      *git.Info
+	Deployer string
+	Time     string
+}
+
+// describesVersions returns true when published versions are described
+// by VersionDescription or git metadata.
+func (f *Function) describesVersions() bool {
+	return f.VersionDescription != "" || f.Git != nil
+}
+
+// validateVersionDescription checks VersionDescription is a valid template.
+func (f *Function) validateVersionDescription() error {
+	if f.VersionDescription == "" {
+		return nil
+	}
+
+	t, err := template.New("versionDescription").Option("missingkey=zero").Parse(f.VersionDescription)
+	if err != nil {
+		return fmt.Errorf("VersionDescription: %s", err)
+	}
+
+	f.versionDesc = t
+	return nil
+}
+
+// versionDescription returns the description of a published version,
+// rendered from VersionDescription, or describing the git commit.
+func (f *Function) versionDescription() (string, error) {
+	if f.versionDesc == nil {
+		return f.Git.String(), nil
+	}
+
+	data := Deploy{
+		Function: f,
+		Git:      f.Git,
+		Deployer: Deployer(),
+		Time:     time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if data.Git == nil {
+		data.Git = new(git.Info)
+	}
+
+	var buf bytes.Buffer
+	if err := f.versionDesc.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("VersionDescription: %s", err)
+	}
+
+	s := buf.String()
+	if len(s) > MaxDescriptionLength {
+		s = s[:MaxDescriptionLength]
+	}
+
+	return s, nil
+}
+
+// Deployer returns the name of the user deploying, from APEX_DEPLOYER
+// such as set by CI, or the current user.
+func Deployer() string {
+	if s := os.Getenv("APEX_DEPLOYER"); s != "" {
+		return s
+	}
+
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return ""
+}
@@ -0,0 +1,51 @@
+package function
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/apex/apex/runtime"
+)
+
+// dockerPlatforms maps architectures to docker platforms.
+var dockerPlatforms = map[string]string{
+	X86_64: "linux/amd64",
+	Arm64:  "linux/arm64",
+}
+
+// image returns the build image, defaulting to the runtime's image.
+func (f *Function) image(r runtime.ContainerRuntime) string {
+	if f.DockerImage == "" {
+		return r.BuildImage()
+	}
+	return f.DockerImage
+}
+
+// buildContainer runs the runtime's build command for `arch` in its build
+// image, with the function directory mounted as the working directory.
+func (f *Function) buildContainer(ctx context.Context, r runtime.ContainerRuntime, arch string) error {
+	dir, err := filepath.Abs(f.Path)
+	if err != nil {
+		return err
+	}
+
+	f.Log.Debugf("building for %s in %s", arch, f.image(r))
+
+	cmd := exec.CommandContext(ctx, "docker", f.dockerArgs(r, arch, dir)...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// dockerArgs returns the arguments of the docker command building for
+// `arch` with the absolute function directory `dir`.
+func (f *Function) dockerArgs(r runtime.ContainerRuntime, arch, dir string) []string {
+	return []string{"run", "--rm",
+		"--platform", dockerPlatforms[arch],
+		"-v", dir + ":/var/task",
+		"-w", "/var/task",
+		f.image(r),
+		"sh", "-c", r.BuildCommand(arch)}
+}
@@ -0,0 +1,186 @@
+package function
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// EdgeRegion is the region of Lambda@Edge functions, which
+// CloudFront replicates to the edge locations of distributions.
+const EdgeRegion = "us-east-1"
+
+// Limits imposed by Lambda@Edge.
+const (
+	MaxEdgeViewerZipSize = 1 << 20
+	MaxEdgeViewerTimeout = 5
+	MaxEdgeViewerMemory  = 128
+	MaxEdgeOriginTimeout = 30
+)
+
+// Edge associates the function with a CloudFront distribution as
+// Lambda@Edge. Edge functions are deployed to EdgeRegion, and the
+// version of the current alias is associated on each deploy.
+type Edge struct {
+	// Distribution id.
+	Distribution string `json:"distribution"`
+
+	// PathPattern of the cache behavior, defaulting to the default behavior.
+	PathPattern string `json:"pathPattern"`
+
+	// EventType is one of "viewer-request", "viewer-response",
+	// "origin-request" or "origin-response".
+	EventType string `json:"eventType"`
+
+	// IncludeBody exposes the request body to request functions.
+	IncludeBody bool `json:"includeBody"`
+}
+
+// viewer returns true for viewer events, which have lower limits.
+func (e *Edge) viewer() bool {
+	return strings.HasPrefix(e.EventType, "viewer-")
+}
+
+// validateEdge checks the function meets the constraints of Lambda@Edge.
+func (f *Function) validateEdge() error {
+	e := f.Edge
+	if e == nil {
+		return nil
+	}
+
+	switch e.EventType {
+	case cloudfront.EventTypeViewerRequest, cloudfront.EventTypeViewerResponse, cloudfront.EventTypeOriginRequest, cloudfront.EventTypeOriginResponse:
+	default:
+		return fmt.Errorf("Edge: invalid eventType %q, must be one of viewer-request, viewer-response, origin-request, origin-response", e.EventType)
+	}
+
+	if e.Distribution == "" {
+		return fmt.Errorf("Edge: distribution is required")
+	}
+
+	if e.IncludeBody && strings.HasSuffix(e.EventType, "-response") {
+		return fmt.Errorf("Edge: includeBody only applies to request events")
+	}
+
+	switch {
+	case f.partition() != PartitionAWS:
+		return fmt.Errorf("Edge: Lambda@Edge is not available in the %s partition", f.partition())
+	case f.nativeEnv():
+		return fmt.Errorf("Edge: Lambda@Edge does not support environment variables, use the %q envMode", EnvFile)
+	case len(f.Layers) > 0:
+		return fmt.Errorf("Edge: Lambda@Edge does not support layers")
+	case f.DockerImage != "":
+		return fmt.Errorf("Edge: Lambda@Edge does not support container images")
+	case f.URL != nil:
+		return fmt.Errorf("Edge: Lambda@Edge does not support function URLs")
+	case f.Arch() != X86_64:
+		return fmt.Errorf("Edge: Lambda@Edge only supports the %s architecture", X86_64)
+	case e.viewer() && f.Timeout > MaxEdgeViewerTimeout:
+		return fmt.Errorf("Edge: timeout of %ds exceeds the %ds limit of viewer events", f.Timeout, MaxEdgeViewerTimeout)
+	case e.viewer() && f.Memory > MaxEdgeViewerMemory:
+		return fmt.Errorf("Edge: memory of %dMB exceeds the %dMB limit of viewer events", f.Memory, MaxEdgeViewerMemory)
+	case f.Timeout > MaxEdgeOriginTimeout:
+		return fmt.Errorf("Edge: timeout of %ds exceeds the %ds limit of origin events", f.Timeout, MaxEdgeOriginTimeout)
+	}
+
+	return nil
+}
+
+// DeployEdge associates the version of the current alias with the cache
+// behavior of the distribution, replacing the function's previous version.
+func (f *Function) DeployEdge() error {
+	if f.Edge == nil {
+		return nil
+	}
+
+	if f.CloudFront == nil {
+		f.Log.Debug("skipping edge, no CloudFront service")
+		return nil
+	}
+
+	alias, err := f.Service.GetAlias(&lambda.GetAliasInput{
+		FunctionName: &f.FunctionName,
+		Name:         aws.String(f.AliasName()),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	version := aws.StringValue(alias.FunctionVersion)
+	if version == "$LATEST" {
+		return fmt.Errorf("edge functions require a published version, %s serves $LATEST", f.AliasName())
+	}
+
+	arn := strings.TrimSuffix(aws.StringValue(alias.AliasArn), ":"+f.AliasName()) + ":" + version
+
+	return f.updateBehavior(f.Edge.Distribution, f.Edge.PathPattern, func(b *behavior) (bool, error) {
+		assoc := b.Lambda
+
+		for _, a := range assoc.Items {
+			if aws.StringValue(a.EventType) != f.Edge.EventType {
+				continue
+			}
+
+			current := aws.StringValue(a.LambdaFunctionARN)
+			if current == arn && aws.BoolValue(a.IncludeBody) == f.Edge.IncludeBody {
+				f.Log.Debugf("edge %s unchanged", f.Edge.EventType)
+				return false, nil
+			}
+
+			if !strings.HasPrefix(current, strings.TrimSuffix(arn, version)) {
+				return false, fmt.Errorf("%s of distribution %s is associated with %s", f.Edge.EventType, f.Edge.Distribution, current)
+			}
+
+			f.Log.Infof("associating version %s with %s of distribution %s", version, f.Edge.EventType, f.Edge.Distribution)
+			a.LambdaFunctionARN = &arn
+			a.IncludeBody = aws.Bool(f.Edge.IncludeBody)
+			return true, nil
+		}
+
+		f.Log.Infof("associating version %s with %s of distribution %s", version, f.Edge.EventType, f.Edge.Distribution)
+		assoc.Items = append(assoc.Items, &cloudfront.LambdaFunctionAssociation{
+			EventType:         aws.String(f.Edge.EventType),
+			LambdaFunctionARN: &arn,
+			IncludeBody:       aws.Bool(f.Edge.IncludeBody),
+		})
+		return true, nil
+	})
+}
+
+// removeEdge disassociates the versions of function `arn` from the
+// cache behavior of the distribution, so that CloudFront removes
+// its replicas and the function may be deleted.
+func (f *Function) removeEdge(arn string) error {
+	if f.Edge == nil || f.CloudFront == nil {
+		return nil
+	}
+
+	return f.updateBehavior(f.Edge.Distribution, f.Edge.PathPattern, func(b *behavior) (bool, error) {
+		assoc := b.Lambda
+		var items []*cloudfront.LambdaFunctionAssociation
+
+		for _, a := range assoc.Items {
+			if strings.HasPrefix(aws.StringValue(a.LambdaFunctionARN), arn+":") {
+				f.Log.Infof("disassociating %s of distribution %s", aws.StringValue(a.EventType), f.Edge.Distribution)
+				continue
+			}
+			items = append(items, a)
+		}
+
+		changed := len(items) != len(assoc.Items)
+		assoc.Items = items
+		return changed, nil
+	})
+}
+
+// replicated returns true if `err` is due to the function
+// still being replicated by CloudFront.
+func replicated(err error) bool {
+	e, ok := err.(awserr.Error)
+	return ok && strings.Contains(e.Message(), "replicated function")
+}
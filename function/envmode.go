@@ -0,0 +1,221 @@
+package function
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Environment modes.
+const (
+	// EnvFile deploys environment variables in the .env.json file of the
+	// zip, loaded by the shim and the apex package, overriding the native
+	// environment variables of the same name.
+	EnvFile = "file"
+
+	// EnvNative deploys environment variables as the native environment
+	// of the function, without a .env.json file. The native environment
+	// is owned by apex, variables set outside of apex are removed.
+	EnvNative = "native"
+)
+
+// MaxNativeEnvSize is the maximum size of native environment variables.
+const MaxNativeEnvSize = 4096
+
+// reservedEnv are the environment variables reserved by Lambda.
+var reservedEnv = map[string]bool{
+	"_HANDLER":                        true,
+	"_X_AMZN_TRACE_ID":                true,
+	"AWS_ACCESS_KEY":                  true,
+	"AWS_ACCESS_KEY_ID":               true,
+	"AWS_DEFAULT_REGION":              true,
+	"AWS_EXECUTION_ENV":               true,
+	"AWS_LAMBDA_FUNCTION_MEMORY_SIZE": true,
+	"AWS_LAMBDA_FUNCTION_NAME":        true,
+	"AWS_LAMBDA_FUNCTION_VERSION":     true,
+	"AWS_LAMBDA_INITIALIZATION_TYPE":  true,
+	"AWS_LAMBDA_LOG_GROUP_NAME":       true,
+	"AWS_LAMBDA_LOG_STREAM_NAME":      true,
+	"AWS_LAMBDA_RUNTIME_API":          true,
+	"AWS_REGION":                      true,
+	"AWS_SECRET_ACCESS_KEY":           true,
+	"AWS_SESSION_TOKEN":               true,
+	"LAMBDA_RUNTIME_DIR":              true,
+	"LAMBDA_TASK_ROOT":                true,
+}
+
+// validateEnvMode checks EnvMode is a supported mode.
+func (f *Function) validateEnvMode() error {
+	switch f.EnvMode {
+	case "", EnvFile, EnvNative:
+		return nil
+	default:
+		return fmt.Errorf("EnvMode: invalid mode %q, must be one of %s, %s", f.EnvMode, EnvFile, EnvNative)
+	}
+}
+
+// nativeEnv returns true when environment variables are deployed natively.
+func (f *Function) nativeEnv() bool {
+	return f.EnvMode == EnvNative
+}
+
+// nativeEnvironment returns the native environment of the function,
+// checking no variables are reserved and their size is within limits.
+func (f *Function) nativeEnvironment() (map[string]string, error) {
+	vars, err := f.environment()
+	if err != nil {
+		return nil, err
+	}
+
+	size := 0
+	for k, v := range vars {
+		if reservedEnv[k] {
+			return nil, fmt.Errorf("environment variable %s is reserved by Lambda, use the %q envMode to override it", k, EnvFile)
+		}
+		size += len(k) + len(v)
+	}
+
+	if size > MaxNativeEnvSize {
+		return nil, fmt.Errorf("environment variables of %d bytes exceed the %d byte limit, use the %q envMode", size, MaxNativeEnvSize, EnvFile)
+	}
+
+	return vars, nil
+}
+
+// deployEnvironment updates the native environment of an existing function
+// in EnvNative mode, before its code so that the migration from .env.json
+// never publishes a version without its variables, returning the updated
+// configuration when changed.
+func (f *Function) deployEnvironment() (*lambda.FunctionConfiguration, error) {
+	if !f.nativeEnv() {
+		return nil, nil
+	}
+
+	info, err := f.getFunction()
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == "ResourceNotFoundException" {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	remote := remoteEnv(info.Configuration)
+
+	vars, err := f.nativeEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	if reflect.DeepEqual(vars, remote) || len(vars) == 0 && len(remote) == 0 {
+		f.Log.Debug("environment unchanged")
+		return nil, nil
+	}
+
+	for _, k := range sortedKeys(remote) {
+		if _, ok := vars[k]; !ok {
+			f.Log.Warnf("removing environment variable %s", k)
+		}
+	}
+
+	f.Log.Infof("updating environment (%d variables)", len(vars))
+
+	var updated *lambda.FunctionConfiguration
+
+	err = f.retryConflict(func() (err error) {
+		updated, err = f.Service.UpdateFunctionConfiguration(&lambda.UpdateFunctionConfigurationInput{
+			FunctionName: &f.FunctionName,
+			Environment:  &lambda.Environment{Variables: aws.StringMap(vars)},
+		})
+		return err
+	})
+
+	if err != nil || updated == nil {
+		return nil, err
+	}
+
+	return updated, f.waitReady(updated)
+}
+
+// publishConfig publishes a version of the unchanged code with the
+// updated `what` of configuration `cfg`, and switches the alias.
+func (f *Function) publishConfig(cfg *lambda.FunctionConfiguration, what string) error {
+	if f.NoPublish {
+		f.Log.Infof("updated $LATEST %s without publishing, %s is unchanged", what, f.AliasName())
+		return nil
+	}
+
+	var desc string
+
+	if f.describesVersions() {
+		var err error
+		if desc, err = f.versionDescription(); err != nil {
+			return err
+		}
+	}
+
+	f.Log.Infof("publishing version with the updated %s", what)
+
+	var v *lambda.FunctionConfiguration
+
+	err := f.retryConflict(func() (err error) {
+		v, err = f.Service.PublishVersion(&lambda.PublishVersionInput{
+			FunctionName: &f.FunctionName,
+			CodeSha256:   cfg.CodeSha256,
+			Description:  &desc,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	f.published = v
+	f.emit(VersionPublished{Function: f.Name, Version: aws.StringValue(v.Version)})
+	return f.switchAlias(aws.StringValue(v.Version))
+}
+
+// warnOverriddenEnv warns of the native environment variables of `cfg`
+// overridden by .env.json in EnvFile mode.
+func (f *Function) warnOverriddenEnv(cfg *lambda.FunctionConfiguration) error {
+	remote := remoteEnv(cfg)
+	if f.nativeEnv() || len(remote) == 0 {
+		return nil
+	}
+
+	vars, err := f.environment()
+	if err != nil {
+		return err
+	}
+
+	for _, k := range sortedKeys(remote) {
+		if _, ok := vars[k]; ok {
+			f.Log.Warnf("native environment variable %s is overridden by .env.json", k)
+		}
+	}
+
+	return nil
+}
+
+// remoteEnv returns the native environment variables of `cfg`.
+func remoteEnv(cfg *lambda.FunctionConfiguration) map[string]string {
+	if cfg == nil || cfg.Environment == nil {
+		return nil
+	}
+	return aws.StringValueMap(cfg.Environment.Variables)
+}
+
+// sortedKeys returns the sorted keys of `m`.
+func sortedKeys(m map[string]string) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
@@ -0,0 +1,112 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apex/apex/config"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/dustin/go-humanize"
+)
+
+// ErrFunctionNotFound is returned when the function does not exist.
+var ErrFunctionNotFound = errors.New("function not found")
+
+// ErrUnchanged is returned when an operation would not change the function,
+// such as deploying identical code or rolling back to the current version.
+var ErrUnchanged = errors.New("function unchanged")
+
+// ErrValidation is returned from Open when the function's
+// configuration is malformed or invalid.
+type ErrValidation struct {
+	// Function name.
+	Function string
+
+	// Fields which are invalid, if known.
+	Fields []string
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error message.
+func (e *ErrValidation) Error() string {
+	return fmt.Sprintf("error opening function %s: %s", e.Function, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ErrValidation) Unwrap() error {
+	return e.Err
+}
+
+// ErrTooLarge is returned when a zip or invocation payload exceeds Lambda's limits.
+type ErrTooLarge struct {
+	// Function name.
+	Function string
+
+	// Size in bytes.
+	Size int
+
+	// Limit in bytes.
+	Limit int
+}
+
+// Error message.
+func (e *ErrTooLarge) Error() string {
+	return fmt.Sprintf("function %s: %s exceeds the %s limit", e.Function, humanize.Bytes(uint64(e.Size)), humanize.Bytes(uint64(e.Limit)))
+}
+
+// ErrDeadline is returned when a deploy step exceeds its deadline.
+type ErrDeadline struct {
+	// Function name.
+	Function string
+
+	// Step which exceeded its deadline, such as StepBuild.
+	Step string
+
+	// Limit of the step.
+	Limit time.Duration
+
+	// Reason describes what the step was waiting on, if known.
+	Reason string
+}
+
+// Error message.
+func (e *ErrDeadline) Error() string {
+	s := fmt.Sprintf("function %s: %s exceeded its %s deadline", e.Function, e.Step, e.Limit)
+	if e.Reason != "" {
+		s += ", " + e.Reason
+	}
+	return s
+}
+
+// invalid returns a validation error wrapping `err`. Fields are taken from
+// config errors, or the "Field: message" prefix used by the validators.
+func (f *Function) invalid(err error) error {
+	e := &ErrValidation{Function: f.Name, Err: err}
+
+	switch v := err.(type) {
+	case config.Errors:
+		for _, c := range v {
+			if c.Field != "" {
+				e.Fields = append(e.Fields, c.Field)
+			}
+		}
+	default:
+		if i := strings.Index(err.Error(), ": "); i > 0 && !strings.ContainsAny(err.Error()[:i], " /") {
+			e.Fields = []string{err.Error()[:i]}
+		}
+	}
+
+	return e
+}
+
+// notFound maps Lambda's ResourceNotFoundException to ErrFunctionNotFound.
+func notFound(err error) error {
+	if e, ok := err.(awserr.Error); ok && e.Code() == "ResourceNotFoundException" {
+		return ErrFunctionNotFound
+	}
+	return err
+}
@@ -0,0 +1,347 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// EventRule is an EventBridge rule triggering the function, matching
+// either an event pattern or a schedule expression.
+type EventRule struct {
+	// Name of the rule, unique per function.
+	Name string `json:"name"`
+
+	// Pattern is the event pattern, for example:
+	// {"source": ["aws.s3"], "detail-type": ["Object Created"]}
+	Pattern map[string]interface{} `json:"pattern"`
+
+	// Schedule is a rate() or cron() expression.
+	Schedule string `json:"schedule"`
+
+	// Bus is the event bus name, defaulting to "default".
+	Bus string `json:"bus"`
+
+	// Input is passed to the function in place of the matched event.
+	Input map[string]interface{} `json:"input"`
+}
+
+// WarmSource is the "source" of keep-warm events, which the shim
+// and Go runtime acknowledge without invoking the handler.
+const WarmSource = "apex.warm"
+
+// warmRule is the name of the managed keep-warm rule.
+const warmRule = "warm"
+
+// ruleName pattern for valid event rule names.
+var ruleName = regexp.MustCompile(`^[\.\-_A-Za-z0-9]+$`)
+
+// maxRuleName is the maximum length of EventBridge rule names,
+// including the prefix of the function.
+const maxRuleName = 64
+
+// bus returns the event bus name.
+func (r *EventRule) bus() string {
+	if r.Bus == "" {
+		return "default"
+	}
+	return r.Bus
+}
+
+// rulePrefix returns the prefix of rule names managed for the function.
+func (f *Function) rulePrefix() string {
+	return fmt.Sprintf("apex-%s-", f.FunctionName)
+}
+
+// rules returns the configured event rules, including the
+// keep-warm schedule when Warm is enabled.
+func (f *Function) rules() []*EventRule {
+	if f.Warm == 0 {
+		return f.Events
+	}
+
+	schedule := fmt.Sprintf("rate(%d minutes)", f.Warm)
+	if f.Warm == 1 {
+		schedule = "rate(1 minute)"
+	}
+
+	return append(f.Events[:len(f.Events):len(f.Events)], &EventRule{
+		Name:     warmRule,
+		Schedule: schedule,
+		Input:    map[string]interface{}{"source": WarmSource},
+	})
+}
+
+// validateEvents checks event rules and the keep-warm interval are valid.
+func (f *Function) validateEvents() error {
+	names := make(map[string]bool)
+
+	if f.Warm < 0 {
+		return fmt.Errorf("Warm: invalid interval %d, must be a number of minutes", f.Warm)
+	}
+
+	if f.Warm > 0 {
+		names[warmRule] = true
+
+		if name := f.rulePrefix() + warmRule; len(name) > maxRuleName {
+			return fmt.Errorf("Warm: rule name %s exceeds %d characters", name, maxRuleName)
+		}
+	}
+
+	for _, r := range f.Events {
+		if !ruleName.MatchString(r.Name) {
+			return fmt.Errorf("Events: invalid rule name %q", r.Name)
+		}
+
+		if names[r.Name] && r.Name == warmRule {
+			return fmt.Errorf("Events: rule name %q is reserved for keep-warm", r.Name)
+		}
+
+		if names[r.Name] {
+			return fmt.Errorf("Events: duplicate rule %q", r.Name)
+		}
+		names[r.Name] = true
+
+		if name := f.rulePrefix() + r.Name; len(name) > maxRuleName {
+			return fmt.Errorf("Events: rule %q name %s exceeds %d characters", r.Name, name, maxRuleName)
+		}
+
+		if (r.Pattern == nil) == (r.Schedule == "") {
+			return fmt.Errorf("Events: rule %q requires either a pattern or a schedule", r.Name)
+		}
+
+		if r.Schedule != "" && r.Bus != "" && r.Bus != "default" {
+			return fmt.Errorf("Events: rule %q schedules must use the default bus", r.Name)
+		}
+	}
+
+	return nil
+}
+
+// DeployEvents creates or updates the configured event rules targeting
+// the current alias, including the keep-warm schedule, and removes
+// managed rules no longer configured, on any bus.
+func (f *Function) DeployEvents() error {
+	if f.EventBridge == nil {
+		f.Log.Debug("skipping events, no EventBridge service")
+		return nil
+	}
+
+	alias, err := f.Service.GetAlias(&lambda.GetAliasInput{
+		FunctionName: &f.FunctionName,
+		Name:         aws.String(f.AliasName()),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	buses := map[string]map[string]bool{"default": {}}
+
+	for _, r := range f.rules() {
+		if err := f.deployEventRule(r, *alias.AliasArn); err != nil {
+			return err
+		}
+
+		if buses[r.bus()] == nil {
+			buses[r.bus()] = make(map[string]bool)
+		}
+		buses[r.bus()][f.rulePrefix()+r.Name] = true
+	}
+
+	// rules are pruned on every bus, so that those of buses no
+	// longer configured are removed too
+	names, err := f.eventBuses()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if buses[name] == nil {
+			buses[name] = make(map[string]bool)
+		}
+	}
+
+	for bus, configured := range buses {
+		if err := f.pruneEventRules(bus, configured, *alias.AliasArn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deployEventRule puts rule `r` targeting `arn` and grants EventBridge permission to invoke it.
+func (f *Function) deployEventRule(r *EventRule, arn string) error {
+	name := f.rulePrefix() + r.Name
+	f.Log.Debugf("deploying event rule %s", name)
+
+	in := &eventbridge.PutRuleInput{
+		Name:         &name,
+		EventBusName: aws.String(r.bus()),
+		Description:  aws.String(fmt.Sprintf("%s %s rule managed by apex", f.FunctionName, r.Name)),
+		State:        aws.String("ENABLED"),
+	}
+
+	if r.Schedule != "" {
+		in.ScheduleExpression = &r.Schedule
+	} else {
+		pattern, err := f.interpolateValue(r.Pattern)
+		if err != nil {
+			return err
+		}
+
+		b, err := json.Marshal(pattern)
+		if err != nil {
+			return err
+		}
+		in.EventPattern = aws.String(string(b))
+	}
+
+	rule, err := f.EventBridge.PutRule(in)
+	if err != nil {
+		return err
+	}
+
+	target := &eventbridge.Target{
+		Id:  aws.String("apex"),
+		Arn: &arn,
+	}
+
+	if r.Input != nil {
+		input, err := f.interpolateValue(r.Input)
+		if err != nil {
+			return err
+		}
+
+		b, err := json.Marshal(input)
+		if err != nil {
+			return err
+		}
+		target.Input = aws.String(string(b))
+	}
+
+	_, err = f.EventBridge.PutTargets(&eventbridge.PutTargetsInput{
+		Rule:         &name,
+		EventBusName: aws.String(r.bus()),
+		Targets:      []*eventbridge.Target{target},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Service.AddPermission(&lambda.AddPermissionInput{
+		FunctionName: &f.FunctionName,
+		Qualifier:    aws.String(f.AliasName()),
+		StatementId:  aws.String(name),
+		Action:       aws.String("lambda:InvokeFunction"),
+		Principal:    aws.String("events.amazonaws.com"),
+		SourceArn:    rule.RuleArn,
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == "ResourceConflictException" {
+		return nil
+	}
+
+	return err
+}
+
+// pruneEventRules removes the managed rules on `bus` targeting `arn`
+// which are not in `configured`. Only rules targeting the function are
+// removed, as the prefix of its rules may prefix those of other functions.
+func (f *Function) pruneEventRules(bus string, configured map[string]bool, arn string) error {
+	var stale []*string
+
+	in := &eventbridge.ListRuleNamesByTargetInput{
+		TargetArn:    &arn,
+		EventBusName: &bus,
+	}
+
+	for {
+		res, err := f.EventBridge.ListRuleNamesByTarget(in)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range res.RuleNames {
+			if strings.HasPrefix(*name, f.rulePrefix()) && !configured[*name] {
+				stale = append(stale, name)
+			}
+		}
+
+		if res.NextToken == nil {
+			break
+		}
+
+		in.NextToken = res.NextToken
+	}
+
+	for _, name := range stale {
+		f.Log.Infof("deleting event rule %s", *name)
+
+		_, err := f.EventBridge.RemoveTargets(&eventbridge.RemoveTargetsInput{
+			Rule:         name,
+			EventBusName: &bus,
+			Ids:          []*string{aws.String("apex")},
+		})
+
+		if err != nil {
+			return err
+		}
+
+		_, err = f.EventBridge.DeleteRule(&eventbridge.DeleteRuleInput{
+			Name:         name,
+			EventBusName: &bus,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		_, err = f.Service.RemovePermission(&lambda.RemovePermissionInput{
+			FunctionName: &f.FunctionName,
+			Qualifier:    aws.String(f.AliasName()),
+			StatementId:  name,
+		})
+
+		if e, ok := err.(awserr.Error); ok && e.Code() == "ResourceNotFoundException" {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// eventBuses returns the names of the event buses of the account.
+func (f *Function) eventBuses() ([]string, error) {
+	var names []string
+	in := &eventbridge.ListEventBusesInput{}
+
+	for {
+		res, err := f.EventBridge.ListEventBuses(in)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, b := range res.EventBuses {
+			names = append(names, aws.StringValue(b.Name))
+		}
+
+		if res.NextToken == nil {
+			return names, nil
+		}
+
+		in.NextToken = res.NextToken
+	}
+}
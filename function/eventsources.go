@@ -0,0 +1,765 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// EventSource is an event source mapping polling a Kinesis stream,
+// DynamoDB stream, SQS queue or Kafka topic, and invoking the function's
+// alias with batches of records.
+type EventSource struct {
+	// ARN of the stream, queue or MSK cluster. Kinesis stream consumer ARNs,
+	// such as "arn:aws:kinesis:...:stream/name/consumer/apex:1234", read
+	// the stream with enhanced fan-out and a dedicated throughput.
+	ARN string `json:"arn"`
+
+	// Brokers are the bootstrap servers of a self-managed Kafka cluster,
+	// such as "kafka1.example.com:9092", in place of an ARN.
+	Brokers []string `json:"brokers"`
+
+	// Topic of the Kafka cluster.
+	Topic string `json:"topic"`
+
+	// ConsumerGroup is the Kafka consumer group id, defaulting to the
+	// UUID of the mapping. It cannot be changed once created.
+	ConsumerGroup string `json:"consumerGroup"`
+
+	// Auth is the Kafka authentication, "scram-512" or "tls", and also
+	// "scram-256" or "basic" for self-managed clusters, using the
+	// credentials of Secret. MSK clusters default to IAM authentication
+	// with the function's role.
+	Auth string `json:"auth"`
+
+	// Secret is the ARN of the Secrets Manager secret of the Kafka credentials.
+	Secret string `json:"secret"`
+
+	// BatchSize is the maximum number of records per batch.
+	BatchSize int64 `json:"batchSize"`
+
+	// BatchingWindow is the maximum number of seconds to gather
+	// records before invoking the function, up to 300.
+	BatchingWindow int64 `json:"batchingWindow"`
+
+	// StartingPosition of streams and topics, "LATEST" (the default) or "TRIM_HORIZON".
+	StartingPosition string `json:"startingPosition"`
+
+	// ParallelizationFactor is the number of batches processed
+	// concurrently per stream shard, from 1 to 10.
+	ParallelizationFactor int64 `json:"parallelizationFactor"`
+
+	// BisectOnError splits failed stream batches in two and retries each half.
+	BisectOnError bool `json:"bisectOnError"`
+
+	// TumblingWindow is the number of seconds of stream records
+	// aggregated with state across invocations, up to 900.
+	TumblingWindow int64 `json:"tumblingWindow"`
+
+	// MaxRetries of failed stream batches, -1 for unlimited retries.
+	MaxRetries *int64 `json:"maxRetries"`
+
+	// MaxRecordAge is the maximum age in seconds of stream records
+	// sent to the function, -1 for no maximum.
+	MaxRecordAge int64 `json:"maxRecordAge"`
+
+	// ReportFailures lets the function report the failed items of a
+	// batch, so that only those are retried.
+	ReportFailures bool `json:"reportFailures"`
+
+	// Filters are event patterns, at least one of which records must match
+	// to invoke the function, so that other records are discarded without
+	// invocations. Patterns match the record metadata, or the JSON "body"
+	// of SQS messages, "data" of Kinesis records and "dynamodb" changes,
+	// for example {"body": {"type": ["order"]}}.
+	Filters []map[string]interface{} `json:"filters"`
+}
+
+// Event source mapping states.
+const (
+	mappingEnabled  = "Enabled"
+	mappingDisabled = "Disabled"
+)
+
+// Event source services.
+const (
+	sourceSQS      = "sqs"
+	sourceKinesis  = "kinesis"
+	sourceDynamoDB = "dynamodb"
+	sourceKafka    = "kafka"
+)
+
+// kafkaAuth are the source access configuration types of Kafka
+// authentication methods, by name.
+var kafkaAuth = map[string]string{
+	"basic":     lambda.SourceAccessTypeBasicAuth,
+	"scram-256": lambda.SourceAccessTypeSaslScram256Auth,
+	"scram-512": lambda.SourceAccessTypeSaslScram512Auth,
+	"tls":       lambda.SourceAccessTypeClientCertificateTlsAuth,
+}
+
+// kafkaBrokers is the endpoints key of self-managed Kafka bootstrap servers.
+const kafkaBrokers = "KAFKA_BOOTSTRAP_SERVERS"
+
+// MaxEventSourceFilters is the maximum number of filters per event source.
+const MaxEventSourceFilters = 5
+
+// filterFields are the sorted record fields filters may match, by service.
+var filterFields = map[string][]string{
+	sourceSQS:      {"attributes", "awsRegion", "body", "eventSource", "eventSourceARN", "md5OfBody", "messageAttributes", "messageId", "receiptHandle"},
+	sourceKinesis:  {"approximateArrivalTimestamp", "data", "encryptionType", "kinesisSchemaVersion", "partitionKey", "sequenceNumber"},
+	sourceDynamoDB: {"awsRegion", "dynamodb", "eventID", "eventName", "eventSource", "eventSourceARN", "eventVersion", "userIdentity"},
+	sourceKafka:    {"headers", "key", "offset", "partition", "timestamp", "timestampType", "topic", "value"},
+}
+
+// service returns the service of the source's ARN, or "kafka"
+// for self-managed Kafka clusters.
+func (s *EventSource) service() string {
+	if s.ARN == "" && len(s.Brokers) > 0 {
+		return sourceKafka
+	}
+
+	parts := strings.SplitN(s.ARN, ":", 4)
+	if len(parts) < 4 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[2]
+}
+
+// stream returns true if the source is a Kinesis or DynamoDB stream.
+func (s *EventSource) stream() bool {
+	return s.service() == sourceKinesis || s.service() == sourceDynamoDB
+}
+
+// id returns the unique id of the source, its ARN or Kafka
+// brokers, and its topic if any.
+func (s *EventSource) id() string {
+	return sourceID(s.ARN, s.Brokers, s.Topic)
+}
+
+// sourceID returns the id of the source with `arn` or Kafka `brokers`, and `topic`.
+func sourceID(arn string, brokers []string, topic string) string {
+	id := arn
+	if id == "" {
+		id = strings.Join(brokers, ",")
+	}
+
+	if topic != "" {
+		id += " " + topic
+	}
+
+	return id
+}
+
+// mappingID returns the source id of mapping `m`.
+func mappingID(m *lambda.EventSourceMappingConfiguration) string {
+	var brokers []string
+	if m.SelfManagedEventSource != nil {
+		brokers = aws.StringValueSlice(m.SelfManagedEventSource.Endpoints[kafkaBrokers])
+	}
+
+	var topic string
+	if len(m.Topics) > 0 {
+		topic = aws.StringValue(m.Topics[0])
+	}
+
+	return sourceID(aws.StringValue(m.EventSourceArn), brokers, topic)
+}
+
+// streamOptions returns the names of stream options set on the source.
+func (s *EventSource) streamOptions() (names []string) {
+	if s.StartingPosition != "" && s.service() != sourceKafka {
+		names = append(names, "startingPosition")
+	}
+	if s.ParallelizationFactor != 0 {
+		names = append(names, "parallelizationFactor")
+	}
+	if s.BisectOnError {
+		names = append(names, "bisectOnError")
+	}
+	if s.TumblingWindow != 0 {
+		names = append(names, "tumblingWindow")
+	}
+	if s.MaxRetries != nil {
+		names = append(names, "maxRetries")
+	}
+	if s.MaxRecordAge != 0 {
+		names = append(names, "maxRecordAge")
+	}
+	return
+}
+
+// kafkaOptions returns the names of Kafka options set on the source.
+func (s *EventSource) kafkaOptions() (names []string) {
+	if len(s.Brokers) > 0 {
+		names = append(names, "brokers")
+	}
+	if s.Topic != "" {
+		names = append(names, "topic")
+	}
+	if s.ConsumerGroup != "" {
+		names = append(names, "consumerGroup")
+	}
+	if s.Auth != "" {
+		names = append(names, "auth")
+	}
+	if s.Secret != "" {
+		names = append(names, "secret")
+	}
+	return
+}
+
+// validateEventSources checks event sources are unique and their options
+// are within the limits of Lambda and supported by their service.
+func (f *Function) validateEventSources() error {
+	ids := make(map[string]bool)
+
+	for _, s := range f.EventSources {
+		switch s.service() {
+		case sourceSQS, sourceKinesis, sourceDynamoDB, sourceKafka:
+		case "":
+			return fmt.Errorf("EventSources: invalid ARN %q", s.id())
+		default:
+			return fmt.Errorf("EventSources: unsupported %s source %q, must be a Kinesis stream, DynamoDB stream, SQS queue or Kafka cluster", s.service(), s.id())
+		}
+
+		if ids[s.id()] {
+			return fmt.Errorf("EventSources: duplicate source %q", s.id())
+		}
+		ids[s.id()] = true
+
+		if s.service() == sourceKafka {
+			if err := s.validateKafka(); err != nil {
+				return fmt.Errorf("EventSources: %q %s", s.id(), err)
+			}
+		} else if names := s.kafkaOptions(); len(names) > 0 {
+			return fmt.Errorf("EventSources: %q %s only apply to Kafka", s.id(), strings.Join(names, ", "))
+		}
+
+		if s.BatchSize < 0 || s.BatchSize > 10000 {
+			return fmt.Errorf("EventSources: %q batchSize must be between 1 and 10000", s.id())
+		}
+
+		if s.BatchingWindow < 0 || s.BatchingWindow > 300 {
+			return fmt.Errorf("EventSources: %q batchingWindow must be between 0 and 300 seconds", s.id())
+		}
+
+		if s.service() == sourceSQS && s.BatchSize > 10 && s.BatchingWindow == 0 {
+			return fmt.Errorf("EventSources: %q batchSize over 10 requires a batchingWindow", s.id())
+		}
+
+		if err := s.validateFilters(); err != nil {
+			return fmt.Errorf("EventSources: %q %s", s.id(), err)
+		}
+
+		switch s.StartingPosition {
+		case "", lambda.EventSourcePositionLatest, lambda.EventSourcePositionTrimHorizon:
+		default:
+			return fmt.Errorf("EventSources: %q invalid startingPosition %q, must be LATEST or TRIM_HORIZON", s.id(), s.StartingPosition)
+		}
+
+		if !s.stream() {
+			if names := s.streamOptions(); len(names) > 0 {
+				return fmt.Errorf("EventSources: %q %s only apply to streams", s.id(), strings.Join(names, ", "))
+			}
+			continue
+		}
+
+		if s.ParallelizationFactor < 0 || s.ParallelizationFactor > 10 {
+			return fmt.Errorf("EventSources: %q parallelizationFactor must be between 1 and 10", s.id())
+		}
+
+		if s.TumblingWindow < 0 || s.TumblingWindow > 900 {
+			return fmt.Errorf("EventSources: %q tumblingWindow must be between 0 and 900 seconds", s.id())
+		}
+
+		if n := s.MaxRetries; n != nil && (*n < -1 || *n > 10000) {
+			return fmt.Errorf("EventSources: %q maxRetries must be between 0 and 10000, or -1 for unlimited", s.id())
+		}
+
+		if n := s.MaxRecordAge; n != 0 && n != -1 && (n < 60 || n > 604800) {
+			return fmt.Errorf("EventSources: %q maxRecordAge must be between 60 and 604800 seconds, or -1 for no maximum", s.id())
+		}
+	}
+
+	return nil
+}
+
+// validateKafka checks the source has a topic, and its authentication
+// is supported by the cluster and has credentials.
+func (s *EventSource) validateKafka() error {
+	if s.ARN != "" && len(s.Brokers) > 0 {
+		return fmt.Errorf("requires either an MSK cluster ARN or brokers")
+	}
+
+	if s.Topic == "" {
+		return fmt.Errorf("requires a topic")
+	}
+
+	if s.ReportFailures {
+		return fmt.Errorf("reportFailures is not supported by Kafka")
+	}
+
+	switch {
+	case s.Auth == "" && s.Secret != "":
+		return fmt.Errorf("secret requires an auth method")
+	case s.Auth == "":
+		return nil
+	case kafkaAuth[s.Auth] == "":
+		return fmt.Errorf("invalid auth %q, must be one of basic, scram-256, scram-512, tls", s.Auth)
+	case s.ARN != "" && (s.Auth == "basic" || s.Auth == "scram-256"):
+		return fmt.Errorf("auth %q is not supported by MSK, must be scram-512 or tls", s.Auth)
+	case s.Secret == "":
+		return fmt.Errorf("auth %q requires a secret", s.Auth)
+	}
+
+	return nil
+}
+
+// validateFilters checks the filters of the source match the fields of
+// its records, and their values are lists of values or matchers such as
+// {"prefix": "a"}, as with EventBridge patterns.
+func (s *EventSource) validateFilters() error {
+	if len(s.Filters) > MaxEventSourceFilters {
+		return fmt.Errorf("has %d filters, the maximum is %d", len(s.Filters), MaxEventSourceFilters)
+	}
+
+	fields := filterFields[s.service()]
+
+	for i, pattern := range s.Filters {
+		if len(pattern) == 0 {
+			return fmt.Errorf("filter %d is empty", i+1)
+		}
+
+		for _, k := range patternKeys(pattern) {
+			if j := sort.SearchStrings(fields, k); j == len(fields) || fields[j] != k {
+				return fmt.Errorf("filter %d field %q is not one of %s", i+1, k, strings.Join(fields, ", "))
+			}
+
+			if err := validatePattern(k, pattern[k]); err != nil {
+				return fmt.Errorf("filter %d %s", i+1, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validatePattern checks the value of filter pattern field `name`
+// is a list, or an object of nested fields.
+func validatePattern(name string, v interface{}) error {
+	switch v := v.(type) {
+	case []interface{}:
+		if len(v) == 0 {
+			return fmt.Errorf("field %q must match at least one value", name)
+		}
+		return nil
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return fmt.Errorf("field %q must not be empty", name)
+		}
+		for _, k := range patternKeys(v) {
+			if err := validatePattern(name+"."+k, v[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("field %q must be a list of values, such as [%s]", name, mustMarshal(v))
+	}
+}
+
+// patternKeys returns the sorted keys of pattern `m`.
+func patternKeys(m map[string]interface{}) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
+
+// mustMarshal returns the JSON of `v`.
+func mustMarshal(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// DeployEventSources creates or updates the event source mappings of the
+// configured sources and the queue deployed by DeployQueue, invoking the
+// current alias. Mappings of sources no longer configured are left in
+// place, as they may be managed elsewhere.
+func (f *Function) DeployEventSources() error {
+	sources := f.EventSources
+	if f.queueSource != nil {
+		sources = append(sources[:len(sources):len(sources)], f.queueSource)
+	}
+
+	if len(sources) == 0 {
+		return nil
+	}
+
+	mappings, err := f.eventSourceMappings()
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]*lambda.EventSourceMappingConfiguration)
+	for _, m := range mappings {
+		existing[mappingID(m)] = m
+	}
+
+	for _, s := range sources {
+		if err := f.deployEventSource(s, existing[s.id()]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deployEventSource creates the mapping of source `s`, or updates the
+// existing mapping `m` when its configuration differs.
+func (f *Function) deployEventSource(s *EventSource, m *lambda.EventSourceMappingConfiguration) error {
+	name := f.FunctionName + ":" + f.AliasName()
+
+	in, err := f.eventSourceInput(s)
+	if err != nil {
+		return err
+	}
+	in.FunctionName = &name
+
+	if m == nil {
+		f.Log.Infof("creating event source %s", s.id())
+		f.kafkaInput(s, in)
+
+		if s.ARN != "" {
+			in.EventSourceArn = &s.ARN
+		}
+
+		if s.stream() || s.service() == sourceKafka {
+			in.StartingPosition = aws.String(s.StartingPosition)
+			if s.StartingPosition == "" {
+				in.StartingPosition = aws.String(lambda.EventSourcePositionLatest)
+			}
+		}
+
+		return f.retryConflict(func() error {
+			_, err := f.Service.CreateEventSourceMapping(in)
+			return err
+		})
+	}
+
+	if s.service() == sourceKafka {
+		f.kafkaInput(s, in)
+
+		if group := kafkaConsumerGroup(m); s.ConsumerGroup != "" && s.ConsumerGroup != group {
+			f.Log.Warnf("event source %s consumer group %s cannot be changed to %s, delete the mapping to recreate it", s.id(), group, s.ConsumerGroup)
+		}
+	}
+
+	if !eventSourceChanged(m, in) {
+		f.Log.Debugf("event source %s unchanged", s.id())
+		return nil
+	}
+
+	f.Log.Infof("updating event source %s", s.id())
+
+	filters := in.FilterCriteria
+	if filters == nil {
+		filters = &lambda.FilterCriteria{Filters: []*lambda.Filter{}}
+	}
+
+	return f.retryConflict(func() error {
+		_, err := f.Service.UpdateEventSourceMapping(&lambda.UpdateEventSourceMappingInput{
+			UUID:                           m.UUID,
+			FunctionName:                   in.FunctionName,
+			BatchSize:                      in.BatchSize,
+			MaximumBatchingWindowInSeconds: in.MaximumBatchingWindowInSeconds,
+			ParallelizationFactor:          in.ParallelizationFactor,
+			BisectBatchOnFunctionError:     in.BisectBatchOnFunctionError,
+			TumblingWindowInSeconds:        in.TumblingWindowInSeconds,
+			MaximumRetryAttempts:           in.MaximumRetryAttempts,
+			MaximumRecordAgeInSeconds:      in.MaximumRecordAgeInSeconds,
+			FunctionResponseTypes:          in.FunctionResponseTypes,
+			FilterCriteria:                 filters,
+			SourceAccessConfigurations:     in.SourceAccessConfigurations,
+		})
+		return err
+	})
+}
+
+// eventSourceInput returns the mapping options of source `s`, leaving
+// those not configured to the defaults of Lambda.
+func (f *Function) eventSourceInput(s *EventSource) (*lambda.CreateEventSourceMappingInput, error) {
+	in := &lambda.CreateEventSourceMappingInput{
+		MaximumBatchingWindowInSeconds: aws.Int64(s.BatchingWindow),
+	}
+
+	if s.service() != sourceKafka {
+		in.FunctionResponseTypes = []*string{}
+	}
+
+	if s.BatchSize != 0 {
+		in.BatchSize = &s.BatchSize
+	}
+
+	if s.ReportFailures {
+		in.FunctionResponseTypes = aws.StringSlice([]string{lambda.FunctionResponseTypeReportBatchItemFailures})
+	}
+
+	if s.stream() {
+		in.ParallelizationFactor = aws.Int64(1)
+		if s.ParallelizationFactor != 0 {
+			in.ParallelizationFactor = &s.ParallelizationFactor
+		}
+
+		in.BisectBatchOnFunctionError = aws.Bool(s.BisectOnError)
+		in.TumblingWindowInSeconds = aws.Int64(s.TumblingWindow)
+		in.MaximumRetryAttempts = aws.Int64(-1)
+		if s.MaxRetries != nil {
+			in.MaximumRetryAttempts = s.MaxRetries
+		}
+
+		in.MaximumRecordAgeInSeconds = aws.Int64(-1)
+		if s.MaxRecordAge != 0 {
+			in.MaximumRecordAgeInSeconds = &s.MaxRecordAge
+		}
+	}
+
+	if len(s.Filters) > 0 {
+		in.FilterCriteria = &lambda.FilterCriteria{}
+
+		for _, pattern := range s.Filters {
+			v, err := f.interpolateValue(pattern)
+			if err != nil {
+				return nil, err
+			}
+
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+
+			in.FilterCriteria.Filters = append(in.FilterCriteria.Filters, &lambda.Filter{Pattern: aws.String(string(b))})
+		}
+	}
+
+	return in, nil
+}
+
+// kafkaInput sets the Kafka options of source `s` on `in`, the topic,
+// brokers and consumer group only applying to new mappings.
+func (f *Function) kafkaInput(s *EventSource, in *lambda.CreateEventSourceMappingInput) {
+	if s.service() != sourceKafka {
+		return
+	}
+
+	in.Topics = aws.StringSlice([]string{s.Topic})
+
+	if len(s.Brokers) > 0 {
+		in.SelfManagedEventSource = &lambda.SelfManagedEventSource{
+			Endpoints: map[string][]*string{kafkaBrokers: aws.StringSlice(s.Brokers)},
+		}
+	}
+
+	if s.ConsumerGroup != "" && len(s.Brokers) > 0 {
+		in.SelfManagedKafkaEventSourceConfig = &lambda.SelfManagedKafkaEventSourceConfig{ConsumerGroupId: &s.ConsumerGroup}
+	} else if s.ConsumerGroup != "" {
+		in.AmazonManagedKafkaEventSourceConfig = &lambda.AmazonManagedKafkaEventSourceConfig{ConsumerGroupId: &s.ConsumerGroup}
+	}
+
+	in.SourceAccessConfigurations = []*lambda.SourceAccessConfiguration{}
+
+	if s.Auth != "" {
+		in.SourceAccessConfigurations = append(in.SourceAccessConfigurations, &lambda.SourceAccessConfiguration{
+			Type: aws.String(kafkaAuth[s.Auth]),
+			URI:  &s.Secret,
+		})
+	}
+}
+
+// kafkaConsumerGroup returns the consumer group id of Kafka mapping `m`.
+func kafkaConsumerGroup(m *lambda.EventSourceMappingConfiguration) string {
+	switch {
+	case m.SelfManagedKafkaEventSourceConfig != nil:
+		return aws.StringValue(m.SelfManagedKafkaEventSourceConfig.ConsumerGroupId)
+	case m.AmazonManagedKafkaEventSourceConfig != nil:
+		return aws.StringValue(m.AmazonManagedKafkaEventSourceConfig.ConsumerGroupId)
+	default:
+		return ""
+	}
+}
+
+// eventSourceChanged returns true if mapping `m` differs from the options of `in`.
+func eventSourceChanged(m *lambda.EventSourceMappingConfiguration, in *lambda.CreateEventSourceMappingInput) bool {
+	changed := func(remote, local *int64) bool {
+		return local != nil && aws.Int64Value(remote) != *local
+	}
+
+	switch {
+	case aws.StringValue(m.FunctionArn) == "" || !strings.HasSuffix(aws.StringValue(m.FunctionArn), ":"+aws.StringValue(in.FunctionName)):
+		return true
+	case changed(m.BatchSize, in.BatchSize),
+		changed(m.MaximumBatchingWindowInSeconds, in.MaximumBatchingWindowInSeconds),
+		changed(m.ParallelizationFactor, in.ParallelizationFactor),
+		changed(m.TumblingWindowInSeconds, in.TumblingWindowInSeconds),
+		changed(m.MaximumRetryAttempts, in.MaximumRetryAttempts),
+		changed(m.MaximumRecordAgeInSeconds, in.MaximumRecordAgeInSeconds):
+		return true
+	case in.BisectBatchOnFunctionError != nil && aws.BoolValue(m.BisectBatchOnFunctionError) != *in.BisectBatchOnFunctionError:
+		return true
+	case in.FunctionResponseTypes != nil && !reflect.DeepEqual(aws.StringValueSlice(m.FunctionResponseTypes), aws.StringValueSlice(in.FunctionResponseTypes)):
+		return true
+	case in.SourceAccessConfigurations != nil && len(m.SourceAccessConfigurations)+len(in.SourceAccessConfigurations) > 0 && !reflect.DeepEqual(m.SourceAccessConfigurations, in.SourceAccessConfigurations):
+		return true
+	}
+
+	return !reflect.DeepEqual(filterPatterns(m.FilterCriteria), filterPatterns(in.FilterCriteria))
+}
+
+// filterPatterns returns the patterns of `c`.
+func filterPatterns(c *lambda.FilterCriteria) (patterns []string) {
+	if c == nil {
+		return nil
+	}
+
+	for _, filter := range c.Filters {
+		patterns = append(patterns, aws.StringValue(filter.Pattern))
+	}
+
+	return
+}
+
+// eventSourceMappings returns the event source mappings of the
+// function, and of its alias, which mappings usually target.
+func (f *Function) eventSourceMappings() ([]*lambda.EventSourceMappingConfiguration, error) {
+	var list []*lambda.EventSourceMappingConfiguration
+	seen := make(map[string]bool)
+
+	for _, name := range []string{f.FunctionName, f.FunctionName + ":" + f.AliasName()} {
+		in := &lambda.ListEventSourceMappingsInput{FunctionName: aws.String(name)}
+
+		err := f.Service.ListEventSourceMappingsPages(in, func(page *lambda.ListEventSourceMappingsOutput, last bool) bool {
+			for _, m := range page.EventSourceMappings {
+				if !seen[aws.StringValue(m.UUID)] {
+					seen[aws.StringValue(m.UUID)] = true
+					list = append(list, m)
+				}
+			}
+			return true
+		})
+
+		if e, ok := err.(awserr.Error); ok && e.Code() == "ResourceNotFoundException" {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+// pauseEventSources disables the enabled event source mappings of the
+// function, such as of SQS queues or Kinesis streams, so that no events
+// are processed by a mix of versions during the deploy, returning the
+// UUIDs of the paused mappings.
+func (f *Function) pauseEventSources() ([]string, error) {
+	mappings, err := f.eventSourceMappings()
+	if err != nil {
+		return nil, err
+	}
+
+	var paused []string
+
+	for _, m := range mappings {
+		if aws.StringValue(m.State) != mappingEnabled {
+			continue
+		}
+
+		f.Log.Infof("pausing event source %s", aws.StringValue(m.EventSourceArn))
+
+		if err := f.setEventSourceEnabled(aws.StringValue(m.UUID), false); err != nil {
+			f.resumeEventSources(paused)
+			return nil, err
+		}
+
+		paused = append(paused, aws.StringValue(m.UUID))
+	}
+
+	return paused, nil
+}
+
+// resumeEventSources enables the `paused` event source mappings,
+// attempting every mapping and returning the first error.
+func (f *Function) resumeEventSources(paused []string) error {
+	var first error
+
+	for _, uuid := range paused {
+		f.Log.Infof("resuming event source mapping %s", uuid)
+
+		if err := f.setEventSourceEnabled(uuid, true); err != nil {
+			f.Log.Errorf("error resuming event source mapping %s: %s", uuid, err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+
+	return first
+}
+
+// setEventSourceEnabled enables or disables event source mapping
+// `uuid`, waiting until the mapping's state has changed.
+func (f *Function) setEventSourceEnabled(uuid string, enabled bool) error {
+	var m *lambda.EventSourceMappingConfiguration
+
+	err := f.retryConflict(func() (err error) {
+		m, err = f.Service.UpdateEventSourceMapping(&lambda.UpdateEventSourceMappingInput{
+			UUID:    &uuid,
+			Enabled: &enabled,
+		})
+		return err
+	})
+
+	if err != nil || m == nil {
+		return err
+	}
+
+	want := mappingDisabled
+	if enabled {
+		want = mappingEnabled
+	}
+
+	deadline, expired := f.deadlineOf(StepWait, ReadyTimeout)
+	interval := readyInterval
+
+	for aws.StringValue(m.State) != want {
+		if time.Now().Add(interval).After(deadline) {
+			expired.Reason = fmt.Sprintf("waiting for event source mapping %s to be %s (state %s)", uuid, want, aws.StringValue(m.State))
+			return expired
+		}
+
+		f.Log.Debugf("waiting %s for event source mapping %s to be %s (state %s)", interval, uuid, want, aws.StringValue(m.State))
+		time.Sleep(interval)
+
+		if interval *= 2; interval > readyMaxInterval {
+			interval = readyMaxInterval
+		}
+
+		m, err = f.Service.GetEventSourceMapping(&lambda.GetEventSourceMappingInput{UUID: &uuid})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
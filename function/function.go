@@ -3,6 +3,7 @@ package function
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -11,20 +12,38 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
-	"gopkg.in/validator.v2"
-
+	"github.com/apex/apex/config"
+	"github.com/apex/apex/env"
+	"github.com/apex/apex/git"
+	"github.com/apex/apex/metrics"
 	"github.com/apex/apex/runtime"
 	"github.com/apex/apex/shim"
-	"github.com/apex/apex/utils"
 	"github.com/apex/log"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/appsync/appsynciface"
+	"github.com/aws/aws-sdk-go/service/cloudfront/cloudfrontiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/signer/signeriface"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
 	"github.com/dustin/go-humanize"
-	"github.com/jpillora/archive"
 )
 
 // InvocationType determines how an invocation request is made.
@@ -37,15 +56,34 @@ const (
 	DryRun                         = "DryRun"
 )
 
-// Current alias name.
+// CurrentAlias is the default name of the alias deployments are served from.
 const CurrentAlias = "current"
 
-// InvokeError records an error from an invocation.
+// EncryptedEnvFile is the name of the encrypted env file
+// merged into the function's environment variables.
+const EncryptedEnvFile = ".env.enc"
+
+// Architectures.
+const (
+	X86_64 = "x86_64"
+	Arm64  = "arm64"
+)
+
+// ShimErrorType is the error type of typed errors returned through
+// the shim, whose details are JSON-encoded in the error message.
+const ShimErrorType = "ApexError"
+
+// InvokeError records an error from an invocation. The code, retryable
+// flag and metadata are those of typed errors returned through the shim,
+// in which case Type is the code.
 type InvokeError struct {
-	Message string   `json:"errorMessage"`
-	Type    string   `json:"errorType"`
-	Stack   []string `json:"stackTrace"`
-	Handled bool
+	Message   string                 `json:"errorMessage"`
+	Type      string                 `json:"errorType"`
+	Stack     []string               `json:"stackTrace"`
+	Code      string                 `json:"-"`
+	Retryable bool                   `json:"-"`
+	Metadata  map[string]interface{} `json:"-"`
+	Handled   bool
 }
 
 // Error message.
@@ -53,46 +91,308 @@ func (e *InvokeError) Error() string {
 	return e.Message
 }
 
+// decodeDetails populates the details of typed errors returned
+// through the shim, leaving other errors untouched.
+func (e *InvokeError) decodeDetails() {
+	if e.Type != ShimErrorType {
+		return
+	}
+
+	var d struct {
+		Code      string                 `json:"code"`
+		Message   string                 `json:"message"`
+		Retryable bool                   `json:"retryable"`
+		Metadata  map[string]interface{} `json:"metadata"`
+	}
+
+	if err := json.Unmarshal([]byte(e.Message), &d); err != nil {
+		return
+	}
+
+	e.Message = d.Message
+	e.Code = d.Code
+	e.Retryable = d.Retryable
+	e.Metadata = d.Metadata
+
+	if d.Code != "" {
+		e.Type = d.Code
+	}
+}
+
+// Locker prevents concurrent deploys of the same function.
+type Locker interface {
+	Lock(name string) error
+	Unlock(name string) error
+}
+
+// Releases records deployed versions, and provides the previously
+// released version for rollbacks. Previous returns an empty string
+// when there is no earlier release.
+type Releases interface {
+	Record(f *Function, version, codeSha256 string) error
+	Previous(f *Function, version string) (string, error)
+}
+
 // Config for a Lambda function.
 type Config struct {
-	Description string `json:"description"`
-	Runtime     string `json:"runtime" validate:"nonzero"`
-	Memory      int64  `json:"memory" validate:"nonzero"`
-	Timeout     int64  `json:"timeout" validate:"nonzero"`
-	Role        string `json:"role" validate:"nonzero"`
+	Description  string            `json:"description"`
+	Runtime      string            `json:"runtime" validate:"nonzero"`
+	Handler      string            `json:"handler"`
+	Memory       int64             `json:"memory" validate:"nonzero"`
+	Timeout      int64             `json:"timeout" validate:"nonzero"`
+	Role         string            `json:"role" validate:"nonzero"`
+	Architecture string            `json:"architecture"`
+	Targets      []string          `json:"targets"`
+	Docker       bool              `json:"docker"`
+	DockerImage  string            `json:"dockerImage"`
+	LogRetention int64             `json:"logRetention"`
+	LogTags      map[string]string `json:"logTags"`
+	Environment  map[string]string `json:"environment"`
+	EnvMode      string            `json:"envMode"`
+	Alarms       []*Alarm          `json:"alarms"`
+	Events       []*EventRule      `json:"events"`
+	EventSources []*EventSource    `json:"eventSources"`
+	URL          *URLConfig        `json:"url"`
+	Edge         *Edge             `json:"edge"`
+	GraphQL      *GraphQL          `json:"graphql"`
+	Tables       []*Table          `json:"tables"`
+	Queue        *Queue            `json:"queue"`
+	Logging      *LoggingConfig    `json:"logging"`
+	Deadlines    *Deadlines        `json:"deadlines"`
+	Artifacts    *Artifacts        `json:"artifacts"`
+	Compression  *Compression      `json:"compression"`
+	Store        *Store            `json:"store"`
+	Permissions  []*Permission     `json:"permissions"`
+	Alias        string            `json:"alias"`
+	Budget       *Budget           `json:"budget"`
+	BlueGreen    *BlueGreen        `json:"blueGreen"`
+	Warm         int64             `json:"warm"`
+	Signing      *Signing          `json:"signing"`
+	Layers       []string          `json:"layers"`
+	Go           runtime.GoOptions `json:"go"`
+	Build        Command           `json:"build"`
+	TestCommand  Command           `json:"test"`
+	DependsOn    []string          `json:"dependsOn"`
+	Groups       []string          `json:"groups"`
+	ShimOptions  *shim.Options     `json:"shimOptions"`
+	Templates    []string          `json:"templates"`
+	Manifest     bool              `json:"manifest"`
+
+	CodeSigningConfigArn string              `json:"codeSigningConfigArn"`
+	CloudFrontFunction   *CloudFrontFunction `json:"cloudFrontFunction"`
+	LogSubscription      *LogSubscription    `json:"logSubscription"`
+	RuntimeManagement    *RuntimeManagement  `json:"runtimeManagement"`
+	PauseEventSources    bool                `json:"pauseEventSources"`
+	ConflictTimeout      int64               `json:"conflictTimeout"`
+	VersionDescription   string              `json:"versionDescription"`
+	RegionArchitectures  map[string]string   `json:"regionArchitectures"`
 }
 
+// LogRetentionDays are the valid log group retention periods.
+var LogRetentionDays = []int64{1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1096, 1827, 2192, 2557, 2922, 3288, 3653}
+
 // Function represents a Lambda function, with configuration loaded
 // from the "function.json" file on disk. Operations are performed
 // against the function directory as the CWD, so os.Chdir() first.
 type Function struct {
 	Config
-	Name         string
-	FunctionName string
-	Path         string
-	Service      lambdaiface.LambdaAPI
-	Log          log.Interface
-	runtime      runtime.Runtime
-	env          map[string]string
+	Name           string
+	FunctionName   string
+	Path           string
+	Stage          string
+	Region         string
+	Partition      string
+	ParameterPath  string
+	ShimPath       string
+	BuildCache     string
+	PreferRuntimes []string
+	OverrideBudget bool
+	NoPublish      bool
+	Service        lambdaiface.LambdaAPI
+	AppSync        appsynciface.AppSyncAPI
+	CloudFront     cloudfrontiface.CloudFrontAPI
+	CloudWatch     cloudwatchiface.CloudWatchAPI
+	CloudWatchLogs cloudwatchlogsiface.CloudWatchLogsAPI
+	EventBridge    eventbridgeiface.EventBridgeAPI
+	DynamoDB       dynamodbiface.DynamoDBAPI
+	IAM            iamiface.IAMAPI
+	S3             s3iface.S3API
+	Signer         signeriface.SignerAPI
+	SQS            sqsiface.SQSAPI
+	SSM            ssmiface.SSMAPI
+	Decrypter      env.Decrypter
+	Observer       DeployObserver
+	Metrics        *metrics.Metrics
+	Git            *git.Info
+	Locker         Locker
+	Releases       Releases
+	Recorder       Recorder
+	Resolver       Resolver
+	Log            log.Interface
+	runtime        runtime.Runtime
+	env            map[string]string
+	url            string
+	published      *lambda.FunctionConfiguration
+	queueSource    *EventSource
+	deadline       time.Time
+	vars           map[string]string
+	versionDesc    *template.Template
 }
 
-// Open the function.json file and prime the config.
+// Open the function.json file and prime the config. The function.yaml,
+// function.yml and function.toml formats are supported as alternatives.
+// The function.<stage>.json file of the active stage is merged over it,
+// objects such as "environment" by key, while other values replace those
+// of function.json. The Lambda configuration of CloudFront Functions is
+// not validated.
 func (f *Function) Open() error {
-	p, err := os.Open(filepath.Join(f.Path, "function.json"))
-	if err == nil {
-		if err := json.NewDecoder(p).Decode(&f.Config); err != nil {
-			return err
+	schema := &config.Schema{
+		Enums: map[string][]string{
+			"runtime":      runtime.Names(),
+			"architecture": {X86_64, Arm64},
+		},
+	}
+
+	path, err := config.Find(f.Path, "function")
+
+	switch {
+	case os.IsNotExist(err):
+		path = filepath.Join(f.Path, "function.json")
+	case err != nil:
+		return fmt.Errorf("error opening function %s: %s", f.Name, err)
+	default:
+		if err := schema.Load(path, &f.Config); err != nil {
+			return f.invalid(err)
+		}
+	}
+
+	if err := f.loadStageConfig(schema); err != nil {
+		return err
+	}
+
+	if f.CloudFrontFunction != nil {
+		if err := f.validateCloudFrontFunction(); err != nil {
+			return f.invalid(err)
 		}
+
+		f.Log = f.Log.WithField("function", f.Name)
+		return nil
 	}
 
 	if f.Runtime == "" {
-		if runtimeName, err := runtime.Detect(f.Path); err == nil {
-			f.Runtime = runtimeName
+		runtimeName, err := runtime.Detect(f.Path, f.PreferRuntimes...)
+
+		if e, ok := err.(*runtime.AmbiguousError); ok {
+			return f.invalid(fmt.Errorf("Runtime: %s, set \"runtime\" in function.json or \"preferRuntimes\" in project.json", e))
 		}
+
+		f.Runtime = runtimeName
+	}
+
+	if err := schema.Validate(path, &f.Config); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateLogRetention(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateAlarms(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateEvents(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateEventSources(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateURL(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validatePermissions(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateAlias(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateVersionDescription(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateBudget(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateSigning(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateTargets(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateEdge(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateGraphQL(); err != nil {
+		return f.invalid(err)
 	}
 
-	if err := validator.Validate(&f.Config); err != nil {
-		return fmt.Errorf("error opening function %s: %s", f.Name, err.Error())
+	if err := f.validateTables(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateQueue(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateLogSubscription(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateLogging(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateRuntimeManagement(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateTemplates(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateEnvMode(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateDeadlines(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateArtifacts(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateCompression(); err != nil {
+		return f.invalid(err)
+	}
+
+	if err := f.validateStore(); err != nil {
+		return f.invalid(err)
+	}
+
+	if o := f.ShimOptions; o != nil {
+		if err := o.Validate(); err != nil {
+			return f.invalid(fmt.Errorf("ShimOptions: %s", err))
+		}
 	}
 
 	r, err := runtime.ByName(f.Runtime)
@@ -101,12 +401,72 @@ func (f *Function) Open() error {
 	}
 	f.runtime = r
 
+	if r, ok := r.(runtime.GoRuntime); ok {
+		options := f.Go
+		options.LDFlags = os.Expand(options.LDFlags, f.buildVar)
+		r.Configure(f.Path, options)
+	}
+
+	if err := f.validateLimits(); err != nil {
+		return f.invalid(err)
+	}
+
 	f.Log = f.Log.WithField("function", f.Name)
 
 	return nil
 }
 
-// SetEnv sets environment variable `name` to `value`.
+// buildVar returns the value of build variable `name`, such as
+// APEX_GIT_COMMIT for version stamping, or environment variable `name`.
+func (f *Function) buildVar(name string) string {
+	if f.Git != nil {
+		if v, ok := f.Git.Env()[name]; ok {
+			return v
+		}
+	}
+	return os.Getenv(name)
+}
+
+// handler returns the configured Handler, defaulting to the runtime's handler.
+func (f *Function) handler() string {
+	if f.Handler == "" {
+		return f.runtime.Handler()
+	}
+	return f.Handler
+}
+
+// Arch returns the instruction set architecture, selected by Region from
+// RegionArchitectures when present, defaulting to x86_64.
+func (f *Function) Arch() string {
+	if arch, ok := f.RegionArchitectures[f.Region]; ok {
+		return arch
+	}
+
+	if f.Architecture == "" {
+		return X86_64
+	}
+	return f.Architecture
+}
+
+// validateLogRetention checks LogRetention is a supported period.
+func (f *Function) validateLogRetention() error {
+	if f.LogRetention == 0 {
+		return nil
+	}
+
+	var valid []string
+	for _, days := range LogRetentionDays {
+		if f.LogRetention == days {
+			return nil
+		}
+		valid = append(valid, strconv.FormatInt(days, 10))
+	}
+
+	return fmt.Errorf("LogRetention: invalid value %d, must be one of %s", f.LogRetention, strings.Join(valid, ", "))
+}
+
+// SetEnv sets environment variable `name` to `value`, taking
+// precedence over values loaded from env files.
 func (f *Function) SetEnv(name, value string) {
 	if f.env == nil {
 		f.env = make(map[string]string)
@@ -114,161 +474,1178 @@ func (f *Function) SetEnv(name, value string) {
 	f.env[name] = value
 }
 
-// Deploy code and then configuration.
+// Deploy tables, the queue, code, configuration, the log group and its
+// subscription, alarms, event rules, event source mappings, the URL,
+// GraphQL resolvers and then the Lambda@Edge association. Unchanged
+// code is not an error, the remaining steps still apply. The Locker, if
+// any, is held for the duration of the deploy, and newly published
+// versions are recorded with Releases. With PauseEventSources the event
+// source mappings of the function are disabled for the duration of the
+// deploy, and enabled again even when it fails. Builds, uploads and
+// waits are bounded by Deadlines. CloudFront Functions are deployed
+// with DeployCloudFrontFunction instead.
 func (f *Function) Deploy() error {
-	if err := f.DeployCode(); err != nil {
+	return f.deploy(f.DeployCode)
+}
+
+// deploy the function with `code` deploying its code.
+func (f *Function) deploy(code func() error) (err error) {
+	defer func(start time.Time) {
+		f.Metrics.Deploy(f.Name, time.Since(start), metrics.Status(err))
+	}(time.Now())
+
+	if d := f.Deadlines.limit(StepDeploy); d > 0 {
+		f.deadline = time.Now().Add(d)
+		defer func() { f.deadline = time.Time{} }()
+	}
+
+	if f.Locker != nil {
+		if err := f.Locker.Lock(f.FunctionName); err != nil {
+			return err
+		}
+		defer f.unlock()
+	}
+
+	if f.CloudFrontFunction != nil {
+		return f.DeployCloudFrontFunction()
+	}
+
+	vars, err := f.environment()
+	if err != nil {
+		return err
+	}
+
+	f.vars = vars
+	defer func() { f.vars = nil }()
+
+	if err := f.checkBudget(); err != nil {
+		return err
+	}
+
+	if err := f.migrateAlias(); err != nil {
+		return err
+	}
+
+	if err := f.DeployTables(); err != nil {
+		return err
+	}
+
+	if err := f.DeployQueue(); err != nil {
+		return err
+	}
+
+	if f.PauseEventSources {
+		var paused []string
+		if paused, err = f.pauseEventSources(); err != nil {
+			return err
+		}
+
+		defer func() {
+			// resumed even when the deploy deadline has passed
+			f.deadline = time.Time{}
+
+			if e := f.resumeEventSources(paused); err == nil {
+				err = e
+			}
+		}()
+	}
+
+	env, err := f.deployEnvironment()
+	if err != nil {
+		return err
+	}
+
+	switch err := code(); {
+	case err == ErrUnchanged && env != nil:
+		if err := f.publishConfig(env, "environment"); err != nil {
+			return err
+		}
+	case err != nil && err != ErrUnchanged:
+		return err
+	}
+
+	if err := f.DeployConfig(); err != nil {
+		return err
+	}
+
+	if err := f.DeployLogGroup(); err != nil {
+		return err
+	}
+
+	if err := f.DeployLogSubscription(); err != nil {
+		return err
+	}
+
+	if err := f.DeployAlarms(); err != nil {
+		return err
+	}
+
+	if err := f.DeployEvents(); err != nil {
+		return err
+	}
+
+	if err := f.DeployEventSources(); err != nil {
+		return err
+	}
+
+	if err := f.DeployURL(); err != nil {
+		return err
+	}
+
+	if err := f.DeployPermissions(); err != nil {
+		return err
+	}
+
+	if err := f.DeployGraphQL(); err != nil {
+		return err
+	}
+
+	if err := f.DeployParameters(); err != nil {
+		return err
+	}
+
+	if err := f.DeployEdge(); err != nil {
+		return err
+	}
+
+	return f.record()
+}
+
+// record the published version, if any, with Releases.
+func (f *Function) record() error {
+	if f.Releases == nil || f.published == nil {
+		return nil
+	}
+
+	f.Log.Debugf("recording release of version %s", aws.StringValue(f.published.Version))
+	return f.Releases.Record(f, aws.StringValue(f.published.Version), aws.StringValue(f.published.CodeSha256))
+}
+
+// Published returns the version and code checksum published by
+// the last deploy, which are empty when no version was published.
+func (f *Function) Published() (version, codeSha256 string) {
+	if f.published == nil {
+		return "", ""
+	}
+	return aws.StringValue(f.published.Version), aws.StringValue(f.published.CodeSha256)
+}
+
+// unlock releases the deploy lock.
+func (f *Function) unlock() {
+	if err := f.Locker.Unlock(f.FunctionName); err != nil {
+		f.Log.Warnf("error releasing lock: %s", err)
+	}
+}
+
+// DeployCode generates a zip and creates or updates the function, returning
+// ErrUnchanged when the code is already deployed or ErrTooLarge when the
+// zip exceeds MaxZipSize or MaxUnzippedSize.
+func (f *Function) DeployCode() error {
+	f.Log.Info("deploying")
+	f.emit(BuildStarted{Function: f.Name})
+
+	zip, err := f.bundleZip(f.Arch())
+	if err != nil {
+		return err
+	}
+	defer zip.Close()
+
+	f.emit(ZipCreated{Function: f.Name, Size: int(zip.size)})
+	return f.deployZip(zip)
+}
+
+// deployZip creates or updates the function with `zip` unless it is unchanged.
+func (f *Function) deployZip(zip *bundle) error {
+	if err := f.checkSize(zip); err != nil {
+		return err
+	}
+
+	info, err := f.getFunction()
+
+	if e, ok := err.(awserr.Error); ok {
+		if e.Code() == "ResourceNotFoundException" {
+			return f.createZip(zip)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := f.warnOverriddenEnv(info.Configuration); err != nil {
+		return err
+	}
+
+	if zip.checksum() == *info.Configuration.CodeSha256 {
+		f.Log.Info("unchanged")
+		return ErrUnchanged
+	}
+
+	if err := f.checkSigning(); err != nil {
+		return err
+	}
+
+	return f.updateZip(zip)
+}
+
+// DeployConfig deploys changes to configuration, including the code
+// signing config when CodeSigningConfigArn is set, and RuntimeManagement.
+func (f *Function) DeployConfig() error {
+	f.Log.Info("deploying config")
+
+	in := &lambda.UpdateFunctionConfigurationInput{
+		FunctionName:  &f.FunctionName,
+		MemorySize:    &f.Memory,
+		Timeout:       &f.Timeout,
+		Description:   &f.Description,
+		Role:          aws.String(f.Role),
+		Handler:       aws.String(f.handler()),
+		LoggingConfig: f.loggingConfig(),
+	}
+
+	if len(f.Layers) > 0 {
+		in.Layers = aws.StringSlice(f.Layers)
+	}
+
+	err := f.retryConflict(func() error {
+		_, err := f.Service.UpdateFunctionConfiguration(in)
 		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if f.CodeSigningConfigArn != "" {
+		err := f.retryConflict(func() error {
+			_, err := f.Service.PutFunctionCodeSigningConfig(&lambda.PutFunctionCodeSigningConfigInput{
+				FunctionName:         &f.FunctionName,
+				CodeSigningConfigArn: &f.CodeSigningConfigArn,
+			})
+			return err
+		})
+
+		if err != nil {
+			return err
+		}
 	}
 
-	return f.DeployConfig()
+	return f.deployRuntimeManagement()
+}
+
+// DeleteOptions configures Delete.
+type DeleteOptions struct {
+	// Confirm must match the function name unless Force is set.
+	Confirm string `json:"confirm,omitempty"`
+
+	// Force skips the confirmation check.
+	Force bool `json:"force,omitempty"`
+
+	// Resources removes aliases, event source mappings,
+	// scheduled rules and the log group of the function. Its
+	// alarms are always removed.
+	Resources bool `json:"resources,omitempty"`
+
+	// Role removes the function's execution role, when tagged by
+	// RoleTag as created for the function and unused by other functions.
+	Role bool `json:"role,omitempty"`
 }
 
-// DeployCode generates a zip and creates or updates the function.
-func (f *Function) DeployCode() error {
-	f.Log.Info("deploying")
+// DeployLogGroup creates the function's log group, so that it is not
+// created by Lambda with infinite retention, and applies LogRetention
+// and LogTags.
+func (f *Function) DeployLogGroup() error {
+	if f.CloudWatchLogs == nil {
+		f.Log.Debug("skipping log group, no CloudWatchLogs service")
+		return nil
+	}
+
+	name := f.LogGroupName()
+	f.Log.Debugf("deploying log group %s", name)
+
+	_, err := f.CloudWatchLogs.CreateLogGroup(&cloudwatchlogs.CreateLogGroupInput{
+		LogGroupName: &name,
+		Tags:         aws.StringMap(f.LogTags),
+	})
+
+	switch e, ok := err.(awserr.Error); {
+	case ok && e.Code() == "ResourceAlreadyExistsException":
+		if len(f.LogTags) > 0 {
+			_, err = f.CloudWatchLogs.TagLogGroup(&cloudwatchlogs.TagLogGroupInput{
+				LogGroupName: &name,
+				Tags:         aws.StringMap(f.LogTags),
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+	case err != nil:
+		return err
+	default:
+		f.Log.Infof("created log group %s", name)
+	}
+
+	if f.LogRetention == 0 {
+		return nil
+	}
+
+	f.Log.Debugf("setting log retention to %d days", f.LogRetention)
+
+	_, err = f.CloudWatchLogs.PutRetentionPolicy(&cloudwatchlogs.PutRetentionPolicyInput{
+		LogGroupName:    &name,
+		RetentionInDays: &f.LogRetention,
+	})
+
+	return err
+}
+
+// Delete the function including all its versions and alarms. The
+// function name must be passed as DeleteOptions.Confirm unless forced.
+// Edge functions and CloudFront Functions are disassociated from their
+// distribution first.
+func (f *Function) Delete(opts DeleteOptions) error {
+	if !opts.Force && opts.Confirm != f.FunctionName {
+		return fmt.Errorf("refusing to delete %s: confirmation %q does not match function name", f.FunctionName, opts.Confirm)
+	}
+
+	f.Log.Info("deleting")
+
+	if f.CloudFrontFunction != nil {
+		if f.CloudFront == nil {
+			f.Log.Debug("skipping CloudFront Function, no CloudFront service")
+			return nil
+		}
+		return f.deleteCloudFrontFunction()
+	}
+
+	var role string
+
+	if opts.Resources || opts.Role || f.Edge != nil {
+		info, err := f.getFunction()
+		if err != nil {
+			return notFound(err)
+		}
+		role = *info.Configuration.Role
+
+		if err := f.removeEdge(*info.Configuration.FunctionArn); err != nil {
+			return err
+		}
+
+		if opts.Resources {
+			if err := f.deleteResources(*info.Configuration.FunctionArn); err != nil {
+				return err
+			}
+
+			if err := f.deleteGraphQL(); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := f.Service.DeleteFunction(&lambda.DeleteFunctionInput{
+		FunctionName: &f.FunctionName,
+	})
+
+	if replicated(err) {
+		return fmt.Errorf("%s is still replicated to CloudFront edge locations, which may take a few hours after disassociating it, retry the delete later", f.FunctionName)
+	}
+
+	if err != nil {
+		return notFound(err)
+	}
+
+	if err := f.removeAlarms(); err != nil {
+		return err
+	}
+
+	if opts.Resources {
+		if err := f.deleteLogGroup(); err != nil {
+			return err
+		}
+	}
+
+	if opts.Role {
+		return f.deleteRole(role)
+	}
+
+	return nil
+}
+
+// deleteResources removes event source mappings, aliases and
+// rules targeting the function with ARN `arn`.
+func (f *Function) deleteResources(arn string) error {
+	mappings, err := f.eventSourceMappings()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range mappings {
+		f.Log.Infof("deleting event source mapping %s", *m.UUID)
+
+		_, err := f.Service.DeleteEventSourceMapping(&lambda.DeleteEventSourceMappingInput{
+			UUID: m.UUID,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	var aliases []*lambda.AliasConfiguration
+
+	err = f.Service.ListAliasesPages(&lambda.ListAliasesInput{
+		FunctionName: &f.FunctionName,
+	}, func(page *lambda.ListAliasesOutput, last bool) bool {
+		aliases = append(aliases, page.Aliases...)
+		return true
+	})
+
+	if err != nil {
+		return err
+	}
+
+	targets := []string{arn}
+
+	for _, a := range aliases {
+		targets = append(targets, *a.AliasArn)
+		f.Log.Infof("deleting alias %s", *a.Name)
+
+		_, err := f.Service.DeleteAlias(&lambda.DeleteAliasInput{
+			FunctionName: &f.FunctionName,
+			Name:         a.Name,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.EventBridge == nil {
+		f.Log.Debug("skipping rules, no EventBridge service")
+		return nil
+	}
+
+	for _, target := range targets {
+		if err := f.deleteRules(target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteRules removes `target` from the rules referencing it on every
+// event bus, deleting rules which have no remaining targets.
+func (f *Function) deleteRules(target string) error {
+	buses, err := f.eventBuses()
+	if err != nil {
+		return err
+	}
+
+	for _, bus := range buses {
+		if err := f.deleteBusRules(bus, target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteBusRules removes `target` from the rules of event bus `bus`
+// referencing it, deleting rules which have no remaining targets.
+func (f *Function) deleteBusRules(bus, target string) error {
+	var rules []*string
+
+	in := &eventbridge.ListRuleNamesByTargetInput{
+		TargetArn:    &target,
+		EventBusName: &bus,
+	}
+
+	for {
+		res, err := f.EventBridge.ListRuleNamesByTarget(in)
+		if err != nil {
+			return err
+		}
+
+		rules = append(rules, res.RuleNames...)
+
+		if res.NextToken == nil {
+			break
+		}
+
+		in.NextToken = res.NextToken
+	}
+
+	for _, rule := range rules {
+		targets, err := f.ruleTargets(bus, rule)
+		if err != nil {
+			return err
+		}
+
+		var ids []*string
+		for _, t := range targets {
+			if *t.Arn == target {
+				ids = append(ids, t.Id)
+			}
+		}
+
+		f.Log.Infof("removing rule %s target", *rule)
+
+		_, err = f.EventBridge.RemoveTargets(&eventbridge.RemoveTargetsInput{
+			Rule:         rule,
+			EventBusName: &bus,
+			Ids:          ids,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if len(ids) < len(targets) {
+			continue
+		}
+
+		f.Log.Infof("deleting rule %s", *rule)
+
+		_, err = f.EventBridge.DeleteRule(&eventbridge.DeleteRuleInput{
+			Name:         rule,
+			EventBusName: &bus,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ruleTargets returns the targets of rule `rule` on event bus `bus`.
+func (f *Function) ruleTargets(bus string, rule *string) ([]*eventbridge.Target, error) {
+	var targets []*eventbridge.Target
+
+	in := &eventbridge.ListTargetsByRuleInput{
+		Rule:         rule,
+		EventBusName: &bus,
+	}
+
+	for {
+		res, err := f.EventBridge.ListTargetsByRule(in)
+		if err != nil {
+			return nil, err
+		}
+
+		targets = append(targets, res.Targets...)
+
+		if res.NextToken == nil {
+			return targets, nil
+		}
+
+		in.NextToken = res.NextToken
+	}
+}
+
+// deleteLogGroup removes the function's log group, if present.
+// Custom log groups are retained, as they may be shared.
+func (f *Function) deleteLogGroup() error {
+	if f.CloudWatchLogs == nil {
+		f.Log.Debug("skipping log group, no CloudWatchLogs service")
+		return nil
+	}
+
+	if f.customLogGroup() {
+		f.Log.Infof("retaining custom log group %s", f.LogGroupName())
+		return nil
+	}
+
+	f.Log.Infof("deleting log group %s", f.LogGroupName())
+
+	_, err := f.CloudWatchLogs.DeleteLogGroup(&cloudwatchlogs.DeleteLogGroupInput{
+		LogGroupName: aws.String(f.LogGroupName()),
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == "ResourceNotFoundException" {
+		return nil
+	}
+
+	return err
+}
+
+// RoleTag is the tag of execution roles created by apex for a single
+// function, whose value is its FunctionName. Only such roles are removed
+// with the function, so that shared roles, such as that of the project,
+// are never deleted.
+const RoleTag = "apex:function"
+
+// deleteRole removes the role with ARN `arn` and its policies, when it is
+// tagged as created for the function by RoleTag and no other function
+// uses it. Roles already deleted are ignored.
+func (f *Function) deleteRole(arn string) error {
+	if f.IAM == nil {
+		return errors.New("cannot delete role, no IAM service")
+	}
+
+	name := arn[strings.LastIndex(arn, "/")+1:]
+
+	res, err := f.IAM.GetRole(&iam.GetRoleInput{
+		RoleName: &name,
+	})
+
+	if noSuchEntity(err) {
+		f.Log.Debugf("role %s already deleted", name)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !ownsRole(res.Role, f.FunctionName) {
+		f.Log.Warnf("keeping role %s, which is not tagged %s=%s", name, RoleTag, f.FunctionName)
+		return nil
+	}
+
+	user, err := f.roleUser(arn)
+	if err != nil {
+		return err
+	}
+
+	if user != "" {
+		f.Log.Warnf("keeping role %s, which is used by %s", name, user)
+		return nil
+	}
+
+	f.Log.Infof("deleting role %s", name)
+
+	attached, err := f.IAM.ListAttachedRolePolicies(&iam.ListAttachedRolePoliciesInput{
+		RoleName: &name,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, p := range attached.AttachedPolicies {
+		_, err := f.IAM.DetachRolePolicy(&iam.DetachRolePolicyInput{
+			RoleName:  &name,
+			PolicyArn: p.PolicyArn,
+		})
+
+		if err != nil && !noSuchEntity(err) {
+			return err
+		}
+	}
+
+	inline, err := f.IAM.ListRolePolicies(&iam.ListRolePoliciesInput{
+		RoleName: &name,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, policy := range inline.PolicyNames {
+		_, err := f.IAM.DeleteRolePolicy(&iam.DeleteRolePolicyInput{
+			RoleName:   &name,
+			PolicyName: policy,
+		})
+
+		if err != nil && !noSuchEntity(err) {
+			return err
+		}
+	}
+
+	_, err = f.IAM.DeleteRole(&iam.DeleteRoleInput{
+		RoleName: &name,
+	})
+
+	if noSuchEntity(err) {
+		return nil
+	}
+
+	return err
+}
+
+// ownsRole returns true if `role` is tagged as created for function `name`.
+func ownsRole(role *iam.Role, name string) bool {
+	for _, t := range role.Tags {
+		if aws.StringValue(t.Key) == RoleTag && aws.StringValue(t.Value) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// roleUser returns the name of another function using the role with ARN `arn`, if any.
+func (f *Function) roleUser(arn string) (user string, err error) {
+	err = f.Service.ListFunctionsPages(&lambda.ListFunctionsInput{}, func(page *lambda.ListFunctionsOutput, last bool) bool {
+		for _, fn := range page.Functions {
+			if aws.StringValue(fn.Role) == arn && aws.StringValue(fn.FunctionName) != f.FunctionName {
+				user = aws.StringValue(fn.FunctionName)
+				return false
+			}
+		}
+		return true
+	})
+
+	return user, err
+}
+
+// noSuchEntity returns true if `err` is an IAM not found error.
+func noSuchEntity(err error) bool {
+	e, ok := err.(awserr.Error)
+	return ok && e.Code() == iam.ErrCodeNoSuchEntityException
+}
+
+// LogGroupName returns the CloudWatch Logs group name of the function,
+// the custom log group of Logging if any.
+func (f *Function) LogGroupName() string {
+	if f.customLogGroup() {
+		return f.Logging.LogGroup
+	}
+	return fmt.Sprintf("/aws/lambda/%s", f.FunctionName)
+}
+
+// getFunction returns the configuration and code location of $LATEST.
+func (f *Function) getFunction() (*lambda.GetFunctionOutput, error) {
+	f.Log.Debug("fetching config")
+	return f.Service.GetFunction(&lambda.GetFunctionInput{
+		FunctionName: &f.FunctionName,
+	})
+}
+
+// Update the function with the given `zip`.
+func (f *Function) Update(zip []byte) error {
+	return f.updateZip(newBundle(zip))
+}
+
+// updateZip updates the function with `zip`.
+func (f *Function) updateZip(zip *bundle) error {
+	code, err := f.code(zip)
+	if err != nil {
+		return err
+	}
+
+	return f.update(code, int(zip.size))
+}
+
+// code returns the code for `zip`, signing it when configured, or
+// stored in the Store or uploaded to the Artifacts bucket when
+// configured. Otherwise the zip is read into memory, as Lambda only
+// accepts it inline.
+func (f *Function) code(zip *bundle) (*lambda.FunctionCode, error) {
+	signed, err := f.sign(zip)
+	if err != nil || signed != nil {
+		return signed, err
+	}
+
+	if f.Store != nil {
+		if f.S3 == nil {
+			f.Log.Debug("skipping artifact store, no S3 service")
+		} else {
+			return f.storeArtifact(zip)
+		}
+	}
+
+	if f.Artifacts != nil {
+		if f.S3 == nil {
+			f.Log.Debug("skipping artifact upload, no S3 service")
+		} else {
+			return f.uploadArtifact(zip)
+		}
+	}
+
+	b, err := zip.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return &lambda.FunctionCode{ZipFile: b}, nil
+}
+
+// update the function with `code` of `size` bytes, publishing a version
+// and pointing the alias at it unless NoPublish is set, in which case
+// only $LATEST is updated.
+func (f *Function) update(code *lambda.FunctionCode, size int) error {
+	f.Log.Info("updating function")
+	f.emit(UploadStarted{Function: f.Name, Size: size})
+
+	in := &lambda.UpdateFunctionCodeInput{
+		FunctionName:    &f.FunctionName,
+		Publish:         aws.Bool(!f.describesVersions() && !f.NoPublish),
+		ZipFile:         code.ZipFile,
+		S3Bucket:        code.S3Bucket,
+		S3Key:           code.S3Key,
+		S3ObjectVersion: code.S3ObjectVersion,
+		Architectures:   []*string{aws.String(f.Arch())},
+	}
+
+	ctx, cancel, expired := f.stepContext(StepUpload)
+	defer cancel()
+
+	var updated *lambda.FunctionConfiguration
+
+	err := f.retryConflict(func() (err error) {
+		if bounded(ctx) {
+			updated, err = f.Service.UpdateFunctionCodeWithContext(ctx, in)
+		} else {
+			updated, err = f.Service.UpdateFunctionCode(in)
+		}
+		return err
+	})
+
+	if err != nil {
+		return deadlineErr(ctx, err, expired)
+	}
+
+	if err := f.waitReady(updated); err != nil {
+		return err
+	}
+
+	if f.NoPublish {
+		f.Log.Infof("updated $LATEST without publishing, %s is unchanged", f.AliasName())
+		return nil
+	}
+
+	version, err := f.publish(updated)
+	if err != nil {
+		return err
+	}
+
+	if f.Git != nil {
+		_, err = f.Service.TagResource(&lambda.TagResourceInput{
+			Resource: updated.FunctionArn,
+			Tags:     aws.StringMap(f.Git.Tags()),
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	f.emit(VersionPublished{Function: f.Name, Version: aws.StringValue(version)})
+	return f.switchAlias(aws.StringValue(version))
+}
+
+// Create the function with the given `zip`.
+func (f *Function) Create(zip []byte) error {
+	return f.createZip(newBundle(zip))
+}
+
+// createZip creates the function with `zip`.
+func (f *Function) createZip(zip *bundle) error {
+	code, err := f.code(zip)
+	if err != nil {
+		return err
+	}
+
+	return f.create(code, int(zip.size))
+}
+
+// create the function with `code` of `size` bytes. The first version
+// is published even when NoPublish is set, so that the alias exists.
+// When the function does not become ready before its deadline it is
+// removed, so that the next deploy creates it again.
+func (f *Function) create(code *lambda.FunctionCode, size int) error {
+	f.Log.Info("creating function")
+	f.emit(UploadStarted{Function: f.Name, Size: size})
+
+	in := &lambda.CreateFunctionInput{
+		FunctionName:  &f.FunctionName,
+		Description:   &f.Description,
+		MemorySize:    &f.Memory,
+		Timeout:       &f.Timeout,
+		Runtime:       aws.String(f.runtime.Name()),
+		Handler:       aws.String(f.handler()),
+		Role:          aws.String(f.Role),
+		Publish:       aws.Bool(!f.describesVersions()),
+		Architectures: []*string{aws.String(f.Arch())},
+		LoggingConfig: f.loggingConfig(),
+		Code:          code,
+	}
+
+	if f.Git != nil {
+		in.Tags = aws.StringMap(f.Git.Tags())
+	}
+
+	if f.CodeSigningConfigArn != "" {
+		in.CodeSigningConfigArn = &f.CodeSigningConfigArn
+	}
+
+	if len(f.Layers) > 0 {
+		in.Layers = aws.StringSlice(f.Layers)
+	}
+
+	if f.nativeEnv() {
+		vars, err := f.nativeEnvironment()
+		if err != nil {
+			return err
+		}
+		in.Environment = &lambda.Environment{Variables: aws.StringMap(vars)}
+	}
+
+	ctx, cancel, expired := f.stepContext(StepUpload)
+	defer cancel()
+
+	var created *lambda.FunctionConfiguration
+	var err error
+
+	if bounded(ctx) {
+		created, err = f.Service.CreateFunctionWithContext(ctx, in)
+	} else {
+		created, err = f.Service.CreateFunction(in)
+	}
 
-	zip, err := f.ZipBytes()
 	if err != nil {
-		return err
+		return deadlineErr(ctx, err, expired)
 	}
 
-	info, err := f.Info()
-
-	if e, ok := err.(awserr.Error); ok {
-		if e.Code() == "ResourceNotFoundException" {
-			return f.Create(zip)
+	if err := f.waitReady(created); err != nil {
+		if _, ok := err.(*ErrDeadline); ok {
+			f.removeCreated()
 		}
+		return err
 	}
 
+	version, err := f.publish(created)
 	if err != nil {
 		return err
 	}
 
-	remoteHash := *info.Configuration.CodeSha256
-	localHash := utils.Sha256(zip)
+	f.emit(VersionPublished{Function: f.Name, Version: aws.StringValue(version)})
+	f.Log.Info("creating alias")
 
-	if localHash == remoteHash {
-		f.Log.Info("unchanged")
-		return nil
+	_, err = f.Service.CreateAlias(&lambda.CreateAliasInput{
+		FunctionName:    &f.FunctionName,
+		FunctionVersion: version,
+		Name:            aws.String(f.AliasName()),
+	})
+
+	if err != nil {
+		return err
 	}
 
-	return f.Update(zip)
+	f.emit(AliasUpdated{Function: f.Name, Alias: f.AliasName(), Version: aws.StringValue(version)})
+	return nil
 }
 
-// DeployConfig deploys changes to configuration.
-func (f *Function) DeployConfig() error {
-	f.Log.Info("deploying config")
+// removeCreated deletes the function created by a deploy which exceeded
+// its deadline. Failures are logged, as the deadline error is returned.
+func (f *Function) removeCreated() {
+	f.Log.Warn("deadline exceeded, removing the created function")
 
-	_, err := f.Service.UpdateFunctionConfiguration(&lambda.UpdateFunctionConfigurationInput{
+	_, err := f.Service.DeleteFunction(&lambda.DeleteFunctionInput{
 		FunctionName: &f.FunctionName,
-		MemorySize:   &f.Memory,
-		Timeout:      &f.Timeout,
-		Description:  &f.Description,
-		Role:         aws.String(f.Role),
-		Handler:      aws.String(f.runtime.Handler()),
 	})
 
-	return err
+	if err != nil {
+		f.Log.Warnf("error removing function: %s", err)
+	}
 }
 
-// Delete the function including all its versions
-func (f *Function) Delete() error {
-	f.Log.Info("deleting")
-	_, err := f.Service.DeleteFunction(&lambda.DeleteFunctionInput{
-		FunctionName: &f.FunctionName,
-	})
-	return err
-}
+// publish returns the version published with `cfg`. When versions are
+// described, by VersionDescription or git metadata, the code is not
+// published on upload, so a described version is published instead.
+func (f *Function) publish(cfg *lambda.FunctionConfiguration) (*string, error) {
+	if !f.describesVersions() {
+		f.published = cfg
+		return cfg.Version, nil
+	}
 
-// Info returns the function information.
-func (f *Function) Info() (*lambda.GetFunctionOutput, error) {
-	f.Log.Debug("fetching config")
-	return f.Service.GetFunction(&lambda.GetFunctionInput{
-		FunctionName: &f.FunctionName,
-	})
-}
+	desc, err := f.versionDescription()
+	if err != nil {
+		return nil, err
+	}
 
-// Update the function with the given `zip`.
-func (f *Function) Update(zip []byte) error {
-	f.Log.Info("updating function")
+	f.Log.Infof("publishing version %q", desc)
 
-	updated, err := f.Service.UpdateFunctionCode(&lambda.UpdateFunctionCodeInput{
-		FunctionName: &f.FunctionName,
-		Publish:      aws.Bool(true),
-		ZipFile:      zip,
+	var v *lambda.FunctionConfiguration
+
+	err = f.retryConflict(func() (err error) {
+		v, err = f.Service.PublishVersion(&lambda.PublishVersionInput{
+			FunctionName: &f.FunctionName,
+			CodeSha256:   cfg.CodeSha256,
+			Description:  &desc,
+		})
+		return err
 	})
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	f.Log.Info("updating alias")
+	f.published = v
+	return v.Version, nil
+}
+
+// InvokeOptions configures an invocation.
+type InvokeOptions struct {
+	// Type of invocation, defaulting to RequestResponse.
+	Type InvocationType
 
-	_, err = f.Service.UpdateAlias(&lambda.UpdateAliasInput{
-		FunctionName:    &f.FunctionName,
-		Name:            aws.String(CurrentAlias),
-		FunctionVersion: updated.Version,
-	})
+	// Qualifier is the version or alias invoked, defaulting to the function's alias.
+	Qualifier string
 
-	return err
-}
+	// Context is JSON encoded as the client context.
+	Context interface{}
 
-// Create the function with the given `zip`.
-func (f *Function) Create(zip []byte) error {
-	f.Log.Info("creating function")
+	// ClientContext is a base64 encoded client context passed
+	// through as-is, taking precedence over Context.
+	ClientContext string
 
-	created, err := f.Service.CreateFunction(&lambda.CreateFunctionInput{
-		FunctionName: &f.FunctionName,
-		Description:  &f.Description,
-		MemorySize:   &f.Memory,
-		Timeout:      &f.Timeout,
-		Runtime:      aws.String(f.runtime.Name()),
-		Handler:      aws.String(f.runtime.Handler()),
-		Role:         aws.String(f.Role),
-		Publish:      aws.Bool(true),
-		Code: &lambda.FunctionCode{
-			ZipFile: zip,
-		},
-	})
+	// NoLogs disables the log tail, useful for high-volume calls.
+	NoLogs bool
 
-	if err != nil {
-		return err
+	// Timeout of the call, zero for no timeout. With Retry
+	// the timeout applies to each attempt.
+	Timeout time.Duration
+
+	// FullLogs fetches the complete logs of the invocation from CloudWatch
+	// Logs, rather than returning the 4KB log tail.
+	FullLogs bool
+
+	// Retry retries invocations which timed out or were throttled,
+	// none are retried by default.
+	Retry *RetryPolicy
+
+	// IdempotencyKey is set as the "idempotencyKey" field of the "custom"
+	// client context object, so that the function may deduplicate the
+	// attempts of an invocation. It defaults to a random key with Retry.
+	IdempotencyKey string
+}
+
+// qualifier returns the qualifier, defaulting to `alias`.
+func (o *InvokeOptions) qualifier(alias string) string {
+	if o.Qualifier == "" {
+		return alias
 	}
+	return o.Qualifier
+}
 
-	f.Log.Info("creating alias")
+// clientContext returns the base64 encoded client context,
+// carrying the IdempotencyKey, if any.
+func (o *InvokeOptions) clientContext() (string, error) {
+	cc := o.ClientContext
 
-	_, err = f.Service.CreateAlias(&lambda.CreateAliasInput{
-		FunctionName:    &f.FunctionName,
-		FunctionVersion: created.Version,
-		Name:            aws.String(CurrentAlias),
-	})
+	if cc == "" {
+		b, err := json.Marshal(o.Context)
+		if err != nil {
+			return "", err
+		}
+		cc = base64.StdEncoding.EncodeToString(b)
+	}
 
-	return err
+	if o.IdempotencyKey == "" {
+		return cc, nil
+	}
+
+	return withIdempotencyKey(cc, o.IdempotencyKey)
 }
 
 // Invoke the remote Lambda function, returning the response and logs, if any.
+// ErrFunctionNotFound is returned if the function does not exist, and
+// ErrTooLarge if the event exceeds the request payload limit.
 func (f *Function) Invoke(event, context interface{}, kind InvocationType) (reply, logs io.Reader, err error) {
 	eventBytes, err := json.Marshal(event)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	contextBytes, err := json.Marshal(context)
+	return f.InvokeRaw(eventBytes, context, kind)
+}
+
+// InvokeRaw invokes the remote Lambda function with `payload` as-is,
+// allowing non-JSON events, returning the response and logs, if any.
+func (f *Function) InvokeRaw(payload []byte, context interface{}, kind InvocationType) (reply, logs io.Reader, err error) {
+	return f.InvokeWithOptions(payload, InvokeOptions{
+		Type:    kind,
+		Context: context,
+	})
+}
+
+// InvokeWithOptions invokes the remote Lambda function with `payload`
+// as-is, returning the response and logs, if any. Failed invocations are
+// retried with backoff according to the Retry policy, with the same
+// idempotency key.
+func (f *Function) InvokeWithOptions(payload []byte, opts InvokeOptions) (reply, logs io.Reader, err error) {
+	if opts.Retry != nil && opts.IdempotencyKey == "" {
+		opts.IdempotencyKey = newIdempotencyKey()
+	}
+
+	clientContext, err := opts.clientContext()
 	if err != nil {
 		return nil, nil, err
 	}
 
-	res, err := f.Service.Invoke(&lambda.InvokeInput{
-		ClientContext:  aws.String(base64.StdEncoding.EncodeToString(contextBytes)),
+	if opts.Type == "" {
+		opts.Type = RequestResponse
+	}
+
+	logType := "Tail"
+	if opts.NoLogs {
+		logType = "None"
+	}
+
+	in := &lambda.InvokeInput{
+		ClientContext:  &clientContext,
 		FunctionName:   &f.FunctionName,
-		InvocationType: aws.String(string(kind)),
-		LogType:        aws.String("Tail"),
-		Qualifier:      aws.String(CurrentAlias),
-		Payload:        eventBytes,
-	})
+		InvocationType: aws.String(string(opts.Type)),
+		LogType:        &logType,
+		Qualifier:      aws.String(opts.qualifier(f.AliasName())),
+		Payload:        payload,
+	}
+
+	backoff := opts.Retry.backoff()
+
+	for attempt := 1; ; attempt++ {
+		reply, logs, err = f.invoke(in, opts)
+
+		if err == nil || !opts.Retry.retry(attempt, err) {
+			return reply, logs, err
+		}
+
+		f.Log.Warnf("attempt %d failed, retrying in %s: %s", attempt, backoff, err)
+		time.Sleep(backoff)
+
+		if backoff *= 2; backoff > opts.Retry.maxBackoff() {
+			backoff = opts.Retry.maxBackoff()
+		}
+	}
+}
+
+// invoke the function once with `in`, returning the response and logs.
+func (f *Function) invoke(in *lambda.InvokeInput, opts InvokeOptions) (reply, logs io.Reader, err error) {
+	var res *lambda.InvokeOutput
+	var requestID string
+	start := time.Now()
+
+	if opts.Timeout > 0 || opts.FullLogs {
+		ctx := context.Background()
+
+		if opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+		}
+
+		res, err = f.Service.InvokeWithContext(ctx, in, request.WithGetResponseHeader("X-Amzn-Requestid", &requestID))
+	} else {
+		res, err = f.Service.Invoke(in)
+	}
+
+	status := metrics.Status(err)
+	if err == nil && res.FunctionError != nil {
+		status = metrics.Failure
+	}
+	f.Metrics.Invoke(f.Name, time.Since(start), status)
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == "RequestTooLargeException" {
+		return nil, nil, &ErrTooLarge{Function: f.Name, Size: len(in.Payload), Limit: MaxPayloadSize}
+	}
 
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, notFound(err)
 	}
 
 	if res.FunctionError != nil {
@@ -280,29 +1657,44 @@ func (f *Function) Invoke(event, context interface{}, kind InvocationType) (repl
 			return nil, nil, err
 		}
 
+		e.decodeDetails()
+		f.recordInvocation(in, res, e, start)
 		return nil, nil, e
 	}
 
-	if kind == Event {
+	if opts.Type == Event {
 		return bytes.NewReader(nil), bytes.NewReader(nil), nil
 	}
 
-	logs = base64.NewDecoder(base64.StdEncoding, strings.NewReader(*res.LogResult))
+	f.recordInvocation(in, res, nil, start)
+
+	logs = base64.NewDecoder(base64.StdEncoding, strings.NewReader(aws.StringValue(res.LogResult)))
 	reply = bytes.NewReader(res.Payload)
+
+	if opts.FullLogs {
+		if b, err := f.RequestLogs(requestID, start); err == nil {
+			logs = bytes.NewReader(b)
+		} else {
+			f.Log.Warnf("error fetching request logs, using the log tail: %s", err)
+		}
+	}
+
 	return reply, logs, nil
 }
 
-// Rollback the function to the previous.
+// Rollback the function to the previous version, preferring the version
+// pinned by a blue/green deploy, then the previous release recorded by
+// Releases, over the previously published version.
 func (f *Function) Rollback() error {
 	f.Log.Info("rolling back")
 
 	alias, err := f.Service.GetAlias(&lambda.GetAliasInput{
 		FunctionName: &f.FunctionName,
-		Name:         aws.String(CurrentAlias),
+		Name:         aws.String(f.AliasName()),
 	})
 
 	if err != nil {
-		return err
+		return notFound(err)
 	}
 
 	f.Log.Infof("current version: %s", *alias.FunctionVersion)
@@ -328,107 +1720,381 @@ func (f *Function) Rollback() error {
 		rollback = prev
 	}
 
+	if f.Releases != nil {
+		v, err := f.Releases.Previous(f, *alias.FunctionVersion)
+		if err != nil {
+			return err
+		}
+
+		if v != "" {
+			rollback = v
+		}
+	}
+
+	if f.BlueGreen != nil {
+		if v := f.previousVersion(); v != "" && v != *alias.FunctionVersion {
+			rollback = v
+		}
+	}
+
 	f.Log.Infof("rollback to version: %s", rollback)
 
 	_, err = f.Service.UpdateAlias(&lambda.UpdateAliasInput{
 		FunctionName:    &f.FunctionName,
-		Name:            aws.String(CurrentAlias),
+		Name:            aws.String(f.AliasName()),
 		FunctionVersion: &rollback,
 	})
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	f.emit(AliasUpdated{Function: f.Name, Alias: f.AliasName(), Version: rollback})
+	return nil
 }
 
-// RollbackVersion the function to the specified version.
+// RollbackVersion the function to the specified version, returning
+// ErrUnchanged if it is already the current version.
 func (f *Function) RollbackVersion(version string) error {
 	f.Log.Info("rolling back")
 
 	alias, err := f.Service.GetAlias(&lambda.GetAliasInput{
 		FunctionName: &f.FunctionName,
-		Name:         aws.String(CurrentAlias),
+		Name:         aws.String(f.AliasName()),
 	})
 
 	if err != nil {
-		return err
+		return notFound(err)
 	}
 
 	f.Log.Infof("current version: %s", *alias.FunctionVersion)
 
 	if version == *alias.FunctionVersion {
-		return errors.New("Specified version currently deployed.")
+		return ErrUnchanged
 	}
 
 	_, err = f.Service.UpdateAlias(&lambda.UpdateAliasInput{
 		FunctionName:    &f.FunctionName,
-		Name:            aws.String(CurrentAlias),
+		Name:            aws.String(f.AliasName()),
 		FunctionVersion: &version,
 	})
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	f.emit(AliasUpdated{Function: f.Name, Alias: f.AliasName(), Version: version})
+	return nil
 }
 
-// Clean removes build artifacts from compiled runtimes.
+// Clean removes build artifacts from compiled runtimes, those of
+// a custom Build command are left to the command.
 func (f *Function) Clean() error {
+	if len(f.Build) > 0 {
+		return nil
+	}
+
 	if r, ok := f.runtime.(runtime.CompiledRuntime); ok {
 		return r.Clean(f.Path)
 	}
 	return nil
 }
 
-// Zip returns the zipped contents of the function.
+// loadStageConfig merges the function.<stage>.json file of the active
+// stage, if any, over the config.
+func (f *Function) loadStageConfig(schema *config.Schema) error {
+	if f.Stage == "" {
+		return nil
+	}
+
+	path, err := config.Find(f.Path, "function."+f.Stage)
+
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return fmt.Errorf("error opening function %s: %s", f.Name, err)
+	}
+
+	f.Log.Debugf("merging %s", filepath.Base(path))
+
+	if err := schema.Load(path, &f.Config); err != nil {
+		return f.invalid(err)
+	}
+
+	return nil
+}
+
+// environment returns the function's environment variables. Sources
+// are merged in the following order, later sources taking precedence:
+//
+//   - the names of Tables, as TABLE_<NAME> unless their env is set
+//   - git metadata (APEX_GIT_*) when deploying from a repository
+//   - the "environment" of function.json and function.<stage>.json
+//   - the .env file in the function directory
+//   - the .env.<stage> file for the active stage
+//   - the decrypted EncryptedEnvFile
+//   - variables set via SetEnv
+//
+// References to the outputs of other functions, such as
+// ${function:worker.arn}, are then resolved in the values.
+//
+// The variables are deployed per EnvMode, either in .env.json, taking
+// precedence over native variables set outside of apex, or natively,
+// replacing them. They are computed once per deploy, so that the
+// EncryptedEnvFile is decrypted once and every step deploys the same.
+func (f *Function) environment() (map[string]string, error) {
+	if f.vars != nil {
+		return f.vars, nil
+	}
+
+	vars := f.tablesEnv()
+
+	if f.Git != nil {
+		for k, v := range f.Git.Env() {
+			vars[k] = v
+		}
+	}
+
+	for k, v := range f.Environment {
+		vars[k] = v
+	}
+
+	files := []string{".env"}
+	if f.Stage != "" {
+		files = append(files, ".env."+f.Stage)
+	}
+
+	for _, name := range files {
+		m, err := env.ParseFile(filepath.Join(f.Path, name))
+
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		f.Log.Debugf("loaded %d variables from %s", len(m), name)
+		for k, v := range m {
+			vars[k] = v
+		}
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(f.Path, EncryptedEnvFile))
+
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	case f.Decrypter == nil:
+		return nil, fmt.Errorf("%s found but no decrypter is configured", EncryptedEnvFile)
+	default:
+		f.Log.Debugf("decrypting %s", EncryptedEnvFile)
+
+		decrypted, err := env.Decrypt(f.Decrypter, b)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range decrypted {
+			vars[k] = v
+		}
+	}
+
+	for k, v := range f.env {
+		vars[k] = v
+	}
+
+	for k, v := range vars {
+		if vars[k], err = f.interpolate(v); err != nil {
+			return nil, fmt.Errorf("%s: %s", k, err)
+		}
+	}
+
+	return vars, nil
+}
+
+// Zip returns the zipped contents of the function, written as they are read.
 func (f *Function) Zip() (io.Reader, error) {
-	buf := new(bytes.Buffer)
-	zip := archive.NewZipWriter(buf)
+	arch := f.Arch()
 
-	if r, ok := f.runtime.(runtime.CompiledRuntime); ok {
-		f.Log.Debugf("compiling")
-		if err := r.Build(f.Path); err != nil {
-			return nil, fmt.Errorf("compiling: %s", err)
+	if err := f.compile(arch); err != nil {
+		return nil, err
+	}
+
+	r, w := io.Pipe()
+
+	go func() {
+		w.CloseWithError(f.zip(w, arch))
+	}()
+
+	return r, nil
+}
+
+// compile builds the function for `arch` before it is zipped.
+func (f *Function) compile(arch string) error {
+	if f.CloudFrontFunction != nil {
+		return fmt.Errorf("%s is a CloudFront Function, which is deployed without a zip", f.Name)
+	}
+
+	if err := f.build(arch); err != nil {
+		if _, ok := err.(*ErrDeadline); ok {
+			return err
 		}
+		return fmt.Errorf("compiling: %s", err)
+	}
+
+	return nil
+}
+
+// zip writes the zipped contents of the function built for `arch` to `w`.
+func (f *Function) zip(w io.Writer, arch string) error {
+	zip := newZipWriter(w, f.Compression)
+
+	if err := f.addFiles(zip, arch); err != nil {
+		zip.Close()
+		return err
+	}
+
+	return zip.Close()
+}
+
+// addFiles adds the files of the function built for `arch` to `zip`.
+func (f *Function) addFiles(zip *zipWriter, arch string) error {
+	vars, err := f.environment()
+	if err != nil {
+		return err
 	}
 
-	if f.env != nil {
+	if len(vars) > 0 && !f.nativeEnv() {
 		f.Log.Debugf("adding .env.json")
 
-		b, err := json.Marshal(f.env)
+		b, err := json.Marshal(vars)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		zip.AddBytes(".env.json", b)
+		if err := zip.AddBytes(".env.json", b); err != nil {
+			return err
+		}
 	}
 
 	if f.runtime.Shimmed() {
 		f.Log.Debugf("adding nodejs shim")
-		zip.AddBytes("index.js", shim.MustAsset("index.js"))
-		zip.AddBytes("byline.js", shim.MustAsset("byline.js"))
+		for _, name := range shim.Assets {
+			b, err := shim.Load(f.ShimPath, name)
+			if err != nil {
+				return fmt.Errorf("loading shim: %s", err)
+			}
+			if err := zip.AddBytes(name, b); err != nil {
+				return err
+			}
+		}
+
+		if f.ShimOptions != nil {
+			b, err := json.Marshal(f.ShimOptions)
+			if err != nil {
+				return err
+			}
+			if err := zip.AddBytes("shim.json", b); err != nil {
+				return err
+			}
+		}
 	}
 
-	if err := zip.AddDir(f.Path); err != nil {
-		return nil, err
+	if r, ok := f.runtime.(runtime.CustomRuntime); ok {
+		f.Log.Debugf("adding custom runtime bootstrap")
+		for path, b := range r.Files() {
+			if err := zip.AddInfoBytes(path, executable{path, len(b)}, b); err != nil {
+				return err
+			}
+		}
 	}
 
-	if err := zip.Close(); err != nil {
-		return nil, err
+	dir := f.Path
+	if r, ok := f.runtime.(runtime.PackagedRuntime); ok {
+		dir = filepath.Join(f.Path, r.PackageDir())
 	}
 
-	return buf, nil
+	return f.addDir(zip, dir, f.renderer(arch))
+}
+
+// executable is the file info of an executable added to the zip.
+type executable struct {
+	name string
+	size int
 }
 
+func (e executable) Name() string       { return e.name }
+func (e executable) Size() int64        { return int64(e.size) }
+func (e executable) Mode() os.FileMode  { return 0755 }
+func (e executable) ModTime() time.Time { return time.Time{} }
+func (e executable) IsDir() bool        { return false }
+func (e executable) Sys() interface{}   { return nil }
+
 // ZipBytes returns the generated zip as bytes.
 func (f *Function) ZipBytes() ([]byte, error) {
+	zip, err := f.bundleZip(f.Arch())
+	if err != nil {
+		return nil, err
+	}
+	defer zip.Close()
+
+	return zip.read()
+}
+
+// bundleZip builds the function for `arch` and writes its zip to a
+// temporary file, adding the manifest when enabled.
+func (f *Function) bundleZip(arch string) (*bundle, error) {
 	f.Log.Debugf("creating zip")
 
-	r, err := f.Zip()
-	if err != nil {
+	if err := f.compile(arch); err != nil {
 		return nil, err
 	}
 
-	b, err := ioutil.ReadAll(r)
+	zip, err := spool(func(w io.Writer) error {
+		return f.zip(w, arch)
+	})
+
 	if err != nil {
 		return nil, err
 	}
 
-	f.Log.Infof("created zip (%s)", humanize.Bytes(uint64(len(b))))
-	return b, nil
+	if f.Manifest {
+		b, err := f.addManifest(zip)
+		zip.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("adding manifest: %s", err)
+		}
+
+		zip = b
+	}
+
+	f.Log.Infof("created zip (%s)", humanize.Bytes(uint64(zip.size)))
+	f.Metrics.Zip(f.Name, int(zip.size))
+	return zip, nil
+}
+
+// Package builds the zip and writes it to `path` without deploying, so the
+// artifact may be archived, scanned or deployed separately with DeployArtifact.
+func (f *Function) Package(path string) error {
+	f.emit(BuildStarted{Function: f.Name})
+
+	zip, err := f.bundleZip(f.Arch())
+	if err != nil {
+		return err
+	}
+	defer zip.Close()
+
+	f.emit(ZipCreated{Function: f.Name, Size: int(zip.size)})
+
+	if err := f.checkSize(zip); err != nil {
+		return err
+	}
+
+	f.Log.Infof("writing %s", path)
+	return zip.save(path)
 }
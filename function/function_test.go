@@ -1,16 +1,62 @@
 package function
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	_ "github.com/apex/apex/runtime/nodejs"
 
+	"github.com/apex/apex/git"
 	"github.com/apex/apex/mock"
+	"github.com/apex/apex/utils"
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/discard"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/appsync"
+	"github.com/aws/aws-sdk-go/service/appsync/appsynciface"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+	"github.com/aws/aws-sdk-go/service/cloudfront/cloudfrontiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/signer"
+	"github.com/aws/aws-sdk-go/service/signer/signeriface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 )
@@ -50,6 +96,37 @@ func TestFunction_Open_requireConfigValues(t *testing.T) {
 	assert.Contains(t, roleErr.Error(), "Role: zero value")
 }
 
+func TestFunction_Open_validationError(t *testing.T) {
+	fn := &Function{
+		Name: "foo",
+		Path: "_fixtures/invalidMemory",
+		Log:  log.Log,
+	}
+
+	err, ok := fn.Open().(*ErrValidation)
+	assert.True(t, ok)
+	assert.Equal(t, "foo", err.Function)
+	assert.Contains(t, err.Fields, "Memory")
+}
+
+func TestFunction_Open_limits(t *testing.T) {
+	fn := &Function{
+		Config: Config{
+			Memory:  64,
+			Timeout: 3,
+			Role:    "iamrole",
+		},
+		Path: "_fixtures/nodejsDefaultFile",
+		Name: "foo",
+		Log:  log.Log,
+	}
+	assert.Contains(t, fn.Open().Error(), "Memory: 64MB is outside the valid range of 128 to 10240MB")
+
+	fn.Memory = 128
+	fn.Timeout = 1000
+	assert.Contains(t, fn.Open().Error(), "Timeout: 1000s is outside the valid range of 1 to 900s")
+}
+
 func TestFunction_Open_detectRuntime(t *testing.T) {
 	fn := &Function{
 		Config: Config{
@@ -65,6 +142,147 @@ func TestFunction_Open_detectRuntime(t *testing.T) {
 	assert.Nil(t, fn.Open())
 }
 
+func TestFunction_Open_stageConfig(t *testing.T) {
+	fn := &Function{
+		Path:  "_fixtures/stageConfig",
+		Name:  "foo",
+		Stage: "production",
+		Log:   log.Log,
+	}
+
+	assert.Nil(t, fn.Open())
+	assert.Equal(t, int64(1024), fn.Memory)
+	assert.Equal(t, int64(3), fn.Timeout)
+	assert.Equal(t, map[string]string{"LOG_LEVEL": "info", "QUEUE": "jobs"}, fn.Environment)
+
+	fn = &Function{
+		Path:  "_fixtures/stageConfig",
+		Name:  "foo",
+		Stage: "development",
+		Log:   log.Log,
+	}
+
+	assert.Nil(t, fn.Open())
+	assert.Equal(t, int64(128), fn.Memory)
+	assert.Equal(t, "debug", fn.Environment["LOG_LEVEL"])
+}
+
+func TestFunction_Package(t *testing.T) {
+	fn := &Function{
+		Config: Config{
+			Memory:  128,
+			Timeout: 3,
+			Role:    "iamrole",
+		},
+		Path: "_fixtures/nodejsDefaultFile",
+		Name: "foo",
+		Log:  log.Log,
+	}
+	assert.Nil(t, fn.Open())
+
+	dir, err := ioutil.TempDir("", "apex-package")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "foo.zip")
+	assert.Nil(t, fn.Package(path))
+
+	r, err := zip.OpenReader(path)
+	assert.Nil(t, err)
+	r.Close()
+}
+
+func TestFunction_Zip_envFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex-env-files")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"index.js":     "",
+		".env":         "TOKEN=dev",
+		".env.prod":    "TOKEN=prod",
+		".env.staging": "TOKEN=staging",
+		".env.enc":     "ciphertext",
+	}
+
+	for name, s := range files {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(s), 0600))
+	}
+
+	fn := &Function{
+		Config:    Config{Memory: 128, Timeout: 3, Role: "iamrole"},
+		Path:      dir,
+		Name:      "foo",
+		Stage:     "prod",
+		Decrypter: &decrypter{vars: `{"KEY":"secret"}`},
+		Log:       log.Log,
+	}
+	assert.Nil(t, fn.Open())
+
+	for _, templates := range [][]string{nil, {"*.js"}} {
+		fn.Templates = templates
+
+		b, err := fn.ZipBytes()
+		assert.Nil(t, err)
+
+		r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		assert.Nil(t, err)
+
+		var names []string
+		for _, file := range r.File {
+			names = append(names, file.Name)
+		}
+		sort.Strings(names)
+
+		assert.Equal(t, []string{".env.json", "index.js"}, names)
+	}
+}
+
+func TestFunction_Arch(t *testing.T) {
+	fn := &Function{
+		Config: Config{
+			Architecture:        Arm64,
+			RegionArchitectures: map[string]string{"us-west-2": X86_64},
+		},
+	}
+	assert.Equal(t, Arm64, fn.Arch())
+	assert.Equal(t, []string{Arm64}, fn.targets())
+
+	fn.Region = "us-west-2"
+	assert.Equal(t, X86_64, fn.Arch())
+
+	fn.Targets = []string{X86_64, "mips"}
+	assert.Contains(t, fn.validateTargets().Error(), `Targets: invalid architecture "mips"`)
+}
+
+func TestFunction_rules_warm(t *testing.T) {
+	fn := &Function{
+		Config: Config{
+			Events: []*EventRule{{Name: "nightly", Schedule: "cron(0 0 * * ? *)"}},
+			Warm:   5,
+		},
+	}
+
+	rules := fn.rules()
+	assert.Equal(t, 2, len(rules))
+	assert.Equal(t, 1, len(fn.Events))
+	assert.Equal(t, "warm", rules[1].Name)
+	assert.Equal(t, "rate(5 minutes)", rules[1].Schedule)
+	assert.Equal(t, WarmSource, rules[1].Input["source"])
+
+	fn.Events = append(fn.Events, &EventRule{Name: "warm", Schedule: "rate(1 hour)"})
+	assert.Contains(t, fn.validateEvents().Error(), "reserved for keep-warm")
+}
+
+func TestFunction_validateSigning(t *testing.T) {
+	fn := &Function{Config: Config{Signing: &Signing{Profile: "apex"}}}
+	assert.EqualError(t, fn.validateSigning(), "Signing: profile and bucket are required")
+
+	fn.Signing.Bucket = "artifacts"
+	assert.Nil(t, fn.validateSigning())
+	assert.Equal(t, "apex/app_foo/", fn.Signing.prefix("app_foo"))
+}
+
 func TestFunction_Delete_success(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -79,7 +297,7 @@ func TestFunction_Delete_success(t *testing.T) {
 		Service:      serviceMock,
 		Log:          log.Log,
 	}
-	err := fn.Delete()
+	err := fn.Delete(DeleteOptions{Confirm: "testfn"})
 
 	assert.Nil(t, err)
 }
@@ -96,11 +314,280 @@ func TestFunction_Delete_failed(t *testing.T) {
 		Service:      serviceMock,
 		Log:          log.Log,
 	}
-	err := fn.Delete()
+	err := fn.Delete(DeleteOptions{Force: true})
 
 	assert.EqualError(t, err, "API err")
 }
 
+func TestFunction_Delete_unconfirmed(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      serviceMock,
+		Log:          log.Log,
+	}
+	err := fn.Delete(DeleteOptions{Confirm: "other"})
+
+	assert.EqualError(t, err, `refusing to delete testfn: confirmation "other" does not match function name`)
+}
+
+// pagedAliasService lists aliases a page at a time.
+type pagedAliasService struct {
+	lambdaiface.LambdaAPI
+	pages [][]*lambda.AliasConfiguration
+}
+
+func (s *pagedAliasService) ListAliasesPages(in *lambda.ListAliasesInput, fn func(*lambda.ListAliasesOutput, bool) bool) error {
+	for i, page := range s.pages {
+		if !fn(&lambda.ListAliasesOutput{Aliases: page}, i == len(s.pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func TestFunction_Delete_resources(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	serviceMock.EXPECT().GetFunction(gomock.Any()).Return(&lambda.GetFunctionOutput{
+		Configuration: &lambda.FunctionConfiguration{
+			FunctionArn: aws.String("arn:aws:lambda:us-west-2:123:function:testfn"),
+			Role:        aws.String("arn:aws:iam::123:role/lambda"),
+		},
+	}, nil)
+	serviceMock.EXPECT().ListEventSourceMappingsPages(&lambda.ListEventSourceMappingsInput{
+		FunctionName: aws.String("testfn"),
+	}, gomock.Any()).DoAndReturn(func(in *lambda.ListEventSourceMappingsInput, fn func(*lambda.ListEventSourceMappingsOutput, bool) bool) error {
+		fn(&lambda.ListEventSourceMappingsOutput{
+			EventSourceMappings: []*lambda.EventSourceMappingConfiguration{{UUID: aws.String("uuid")}},
+		}, false)
+		fn(&lambda.ListEventSourceMappingsOutput{
+			EventSourceMappings: []*lambda.EventSourceMappingConfiguration{{UUID: aws.String("paged")}},
+		}, true)
+		return nil
+	})
+	serviceMock.EXPECT().ListEventSourceMappingsPages(&lambda.ListEventSourceMappingsInput{
+		FunctionName: aws.String("testfn:current"),
+	}, gomock.Any()).DoAndReturn(func(in *lambda.ListEventSourceMappingsInput, fn func(*lambda.ListEventSourceMappingsOutput, bool) bool) error {
+		fn(&lambda.ListEventSourceMappingsOutput{
+			EventSourceMappings: []*lambda.EventSourceMappingConfiguration{{UUID: aws.String("uuid")}, {UUID: aws.String("alias")}},
+		}, true)
+		return nil
+	})
+	for _, uuid := range []string{"uuid", "paged", "alias"} {
+		serviceMock.EXPECT().DeleteEventSourceMapping(&lambda.DeleteEventSourceMappingInput{
+			UUID: aws.String(uuid),
+		})
+	}
+	serviceMock.EXPECT().DeleteAlias(&lambda.DeleteAliasInput{
+		FunctionName: aws.String("testfn"),
+		Name:         aws.String("current"),
+	})
+	serviceMock.EXPECT().DeleteAlias(&lambda.DeleteAliasInput{
+		FunctionName: aws.String("testfn"),
+		Name:         aws.String("staging"),
+	})
+	serviceMock.EXPECT().DeleteFunction(gomock.Any())
+
+	service := &pagedAliasService{LambdaAPI: serviceMock, pages: [][]*lambda.AliasConfiguration{
+		{{Name: aws.String("current"), AliasArn: aws.String("arn:aws:lambda:us-west-2:123:function:testfn:current")}},
+		{{Name: aws.String("staging"), AliasArn: aws.String("arn:aws:lambda:us-west-2:123:function:testfn:staging")}},
+	}}
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      service,
+		Log:          log.Log,
+	}
+	err := fn.Delete(DeleteOptions{Force: true, Resources: true})
+
+	assert.Nil(t, err)
+}
+
+func TestFunction_InvokeRaw(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	serviceMock.EXPECT().Invoke(gomock.Any()).Do(func(in *lambda.InvokeInput) {
+		assert.Equal(t, []byte{0xff, 0xd8}, in.Payload)
+	}).Return(&lambda.InvokeOutput{Payload: []byte("ok"), LogResult: aws.String("")}, nil)
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      serviceMock,
+		Log:          log.Log,
+	}
+
+	reply, _, err := fn.InvokeRaw([]byte{0xff, 0xd8}, nil, RequestResponse)
+	assert.Nil(t, err)
+
+	b, _ := ioutil.ReadAll(reply)
+	assert.Equal(t, "ok", string(b))
+}
+
+func TestFunction_InvokeWithOptions(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	serviceMock.EXPECT().Invoke(&lambda.InvokeInput{
+		ClientContext:  aws.String("e30="),
+		FunctionName:   aws.String("testfn"),
+		InvocationType: aws.String("RequestResponse"),
+		LogType:        aws.String("None"),
+		Qualifier:      aws.String("3"),
+		Payload:        []byte("{}"),
+	}).Return(&lambda.InvokeOutput{Payload: []byte("ok")}, nil)
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      serviceMock,
+		Log:          log.Log,
+	}
+
+	_, _, err := fn.InvokeWithOptions([]byte("{}"), InvokeOptions{
+		Qualifier:     "3",
+		ClientContext: "e30=",
+		NoLogs:        true,
+	})
+
+	assert.Nil(t, err)
+}
+
+func TestFunction_InvokeWithOptions_typedError(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	payload := `{
+		"errorType": "ApexError",
+		"errorMessage": "{\"code\":\"Throttled\",\"message\":\"too many requests\",\"retryable\":true,\"metadata\":{\"retryAfter\":5}}",
+		"stackTrace": []
+	}`
+
+	serviceMock.EXPECT().Invoke(gomock.Any()).Return(&lambda.InvokeOutput{
+		FunctionError: aws.String("Handled"),
+		Payload:       []byte(payload),
+	}, nil)
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      serviceMock,
+		Log:          log.Log,
+	}
+
+	_, _, err := fn.InvokeWithOptions([]byte("{}"), InvokeOptions{NoLogs: true})
+
+	e, ok := err.(*InvokeError)
+	assert.True(t, ok)
+	assert.Equal(t, "too many requests", e.Message)
+	assert.Equal(t, "Throttled", e.Type)
+	assert.Equal(t, "Throttled", e.Code)
+	assert.True(t, e.Retryable)
+	assert.Equal(t, map[string]interface{}{"retryAfter": float64(5)}, e.Metadata)
+	assert.True(t, e.Handled)
+}
+
+type deletedRoleService struct {
+	iamiface.IAMAPI
+	roles   map[string]*iam.Role
+	deleted []string
+}
+
+func (s *deletedRoleService) GetRole(in *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+	role, ok := s.roles[*in.RoleName]
+	if !ok {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, "not found", nil)
+	}
+	return &iam.GetRoleOutput{Role: role}, nil
+}
+
+func (s *deletedRoleService) ListAttachedRolePolicies(in *iam.ListAttachedRolePoliciesInput) (*iam.ListAttachedRolePoliciesOutput, error) {
+	return &iam.ListAttachedRolePoliciesOutput{}, nil
+}
+
+func (s *deletedRoleService) ListRolePolicies(in *iam.ListRolePoliciesInput) (*iam.ListRolePoliciesOutput, error) {
+	return &iam.ListRolePoliciesOutput{PolicyNames: []*string{aws.String("logs")}}, nil
+}
+
+func (s *deletedRoleService) DeleteRolePolicy(in *iam.DeleteRolePolicyInput) (*iam.DeleteRolePolicyOutput, error) {
+	return &iam.DeleteRolePolicyOutput{}, nil
+}
+
+func (s *deletedRoleService) DeleteRole(in *iam.DeleteRoleInput) (*iam.DeleteRoleOutput, error) {
+	delete(s.roles, *in.RoleName)
+	s.deleted = append(s.deleted, *in.RoleName)
+	return &iam.DeleteRoleOutput{}, nil
+}
+
+type deletedService struct {
+	lambdaiface.LambdaAPI
+	functions map[string]string
+}
+
+func (s *deletedService) GetFunction(in *lambda.GetFunctionInput) (*lambda.GetFunctionOutput, error) {
+	return &lambda.GetFunctionOutput{Configuration: &lambda.FunctionConfiguration{
+		FunctionArn: aws.String("arn:aws:lambda:us-west-2:123:function:" + *in.FunctionName),
+		Role:        aws.String(s.functions[*in.FunctionName]),
+	}}, nil
+}
+
+func (s *deletedService) DeleteFunction(in *lambda.DeleteFunctionInput) (*lambda.DeleteFunctionOutput, error) {
+	delete(s.functions, *in.FunctionName)
+	return &lambda.DeleteFunctionOutput{}, nil
+}
+
+func (s *deletedService) ListFunctionsPages(in *lambda.ListFunctionsInput, fn func(*lambda.ListFunctionsOutput, bool) bool) error {
+	page := &lambda.ListFunctionsOutput{}
+	for name, role := range s.functions {
+		page.Functions = append(page.Functions, &lambda.FunctionConfiguration{FunctionName: aws.String(name), Role: aws.String(role)})
+	}
+	fn(page, true)
+	return nil
+}
+
+func TestFunction_Delete_role(t *testing.T) {
+	tag := func(name string) []*iam.Tag {
+		return []*iam.Tag{{Key: aws.String(RoleTag), Value: aws.String(name)}}
+	}
+
+	roles := &deletedRoleService{roles: map[string]*iam.Role{
+		"project": {},
+		"foo":     {Tags: tag("foo")},
+		"bar":     {Tags: tag("bar")},
+	}}
+
+	service := &deletedService{functions: map[string]string{
+		"foo":    "arn:aws:iam::123:role/foo",
+		"bar":    "arn:aws:iam::123:role/bar",
+		"baz":    "arn:aws:iam::123:role/bar",
+		"shared": "arn:aws:iam::123:role/project",
+		"other":  "arn:aws:iam::123:role/project",
+	}}
+
+	opts := DeleteOptions{Force: true, Role: true}
+
+	for _, name := range []string{"shared", "bar", "foo"} {
+		fn := &Function{FunctionName: name, Service: service, IAM: roles, Log: log.Log}
+		assert.Nil(t, fn.Delete(opts))
+	}
+
+	assert.Equal(t, []string{"foo"}, roles.deleted)
+	assert.NotNil(t, roles.roles["project"])
+	assert.NotNil(t, roles.roles["bar"])
+
+	service.functions["again"] = "arn:aws:iam::123:role/foo"
+	fn := &Function{FunctionName: "again", Service: service, IAM: roles, Log: log.Log}
+	assert.Nil(t, fn.Delete(opts))
+	assert.Equal(t, []string{"foo"}, roles.deleted)
+}
+
 func TestFunction_Rollback_GetAlias_failed(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -191,70 +678,202 @@ func TestFunction_Rollback_previousVersion(t *testing.T) {
 	assert.Nil(t, err)
 }
 
-func TestFunction_Rollback_latestVersion(t *testing.T) {
+func TestFunction_Update_observer(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
 
-	deployedVersions := []*lambda.FunctionConfiguration{
-		&lambda.FunctionConfiguration{Version: aws.String("$LATEST")},
-		&lambda.FunctionConfiguration{Version: aws.String("1")},
-		&lambda.FunctionConfiguration{Version: aws.String("2")},
-	}
-	currentVersion := aws.String("1")
-	afterRollbackVersion := aws.String("2")
-	serviceMock.EXPECT().GetAlias(gomock.Any()).Return(&lambda.AliasConfiguration{FunctionVersion: currentVersion}, nil)
-	serviceMock.EXPECT().ListVersionsByFunction(gomock.Any()).Return(&lambda.ListVersionsByFunctionOutput{Versions: deployedVersions}, nil)
-	serviceMock.EXPECT().UpdateAlias(&lambda.UpdateAliasInput{
-		FunctionName:    aws.String("testfn"),
-		Name:            aws.String("current"),
-		FunctionVersion: afterRollbackVersion,
-	})
+	serviceMock.EXPECT().UpdateFunctionCode(gomock.Any()).Return(&lambda.FunctionConfiguration{Version: aws.String("3")}, nil)
+	serviceMock.EXPECT().UpdateAlias(gomock.Any()).Return(&lambda.AliasConfiguration{}, nil)
 
+	var events []DeployEvent
 	fn := &Function{
+		Name:         "testfn",
 		FunctionName: "testfn",
 		Service:      serviceMock,
 		Log:          log.Log,
+		Observer: DeployObserverFunc(func(e DeployEvent) {
+			events = append(events, e)
+		}),
 	}
-	err := fn.Rollback()
+
+	assert.Nil(t, fn.Update([]byte("zip")))
+	assert.Equal(t, []DeployEvent{
+		UploadStarted{Function: "testfn", Size: 3},
+		VersionPublished{Function: "testfn", Version: "3"},
+		AliasUpdated{Function: "testfn", Alias: "current", Version: "3"},
+	}, events)
+}
+
+func TestFunction_waitReady(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	readyInterval = time.Millisecond
+	defer func() { readyInterval = time.Second }()
+
+	gomock.InOrder(
+		serviceMock.EXPECT().GetFunctionConfiguration(gomock.Any()).Return(&lambda.FunctionConfiguration{
+			State:            aws.String(lambda.StateActive),
+			LastUpdateStatus: aws.String(lambda.LastUpdateStatusInProgress),
+		}, nil),
+		serviceMock.EXPECT().GetFunctionConfiguration(gomock.Any()).Return(&lambda.FunctionConfiguration{
+			State:            aws.String(lambda.StateActive),
+			LastUpdateStatus: aws.String(lambda.LastUpdateStatusSuccessful),
+		}, nil),
+	)
+
+	fn := &Function{FunctionName: "testfn", Service: serviceMock, Log: log.Log}
+	assert.Nil(t, fn.waitReady(&lambda.FunctionConfiguration{State: aws.String(lambda.StatePending)}))
+
+	err := fn.waitReady(&lambda.FunctionConfiguration{
+		State:       aws.String(lambda.StateFailed),
+		StateReason: aws.String("subnet not found"),
+	})
+	assert.EqualError(t, err, "function failed: subnet not found")
+}
+
+func TestFunction_retryConflict(t *testing.T) {
+	readyInterval = time.Millisecond
+	defer func() { readyInterval = time.Second }()
+
+	fn := &Function{Log: log.Log}
+	conflict := awserr.New("ResourceConflictException", "update in progress", nil)
+
+	calls := 0
+	err := fn.retryConflict(func() error {
+		if calls++; calls < 3 {
+			return conflict
+		}
+		return nil
+	})
 
 	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+
+	fn.ConflictTimeout = -1
+	assert.Equal(t, conflict, fn.retryConflict(func() error { return conflict }))
 }
 
-func TestFunction_Rollback_UpdateAlias_failed(t *testing.T) {
+func TestFunction_switchAlias_blueGreen(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
 
-	serviceMock.EXPECT().GetAlias(gomock.Any()).Return(&lambda.AliasConfiguration{FunctionVersion: aws.String("1")}, nil)
-	serviceMock.EXPECT().ListVersionsByFunction(gomock.Any()).Return(&lambda.ListVersionsByFunctionOutput{
-		Versions: []*lambda.FunctionConfiguration{
-			&lambda.FunctionConfiguration{Version: aws.String("$LATEST")},
-			&lambda.FunctionConfiguration{Version: aws.String("1")},
-			&lambda.FunctionConfiguration{Version: aws.String("2")},
-		},
-	}, nil)
-	serviceMock.EXPECT().UpdateAlias(gomock.Any()).Return(nil, errors.New("API err"))
+	gomock.InOrder(
+		serviceMock.EXPECT().GetAlias(&lambda.GetAliasInput{
+			FunctionName: aws.String("testfn"),
+			Name:         aws.String("current"),
+		}).Return(&lambda.AliasConfiguration{FunctionVersion: aws.String("2")}, nil),
+		serviceMock.EXPECT().UpdateAlias(&lambda.UpdateAliasInput{
+			FunctionName:    aws.String("testfn"),
+			Name:            aws.String("previous"),
+			FunctionVersion: aws.String("2"),
+		}),
+		serviceMock.EXPECT().UpdateAlias(&lambda.UpdateAliasInput{
+			FunctionName:    aws.String("testfn"),
+			Name:            aws.String("current"),
+			FunctionVersion: aws.String("3"),
+		}),
+	)
 
 	fn := &Function{
 		FunctionName: "testfn",
+		Config:       Config{BlueGreen: &BlueGreen{}},
 		Service:      serviceMock,
 		Log:          log.Log,
 	}
-	err := fn.Rollback()
 
-	assert.EqualError(t, err, "API err")
+	assert.Nil(t, fn.switchAlias("3"))
 }
 
-func TestFunction_RollbackVersion_GetAlias_failed(t *testing.T) {
+func TestFunction_Update_noPublish(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
 
-	serviceMock.EXPECT().GetAlias(&lambda.GetAliasInput{
-		FunctionName: aws.String("testfn"),
-		Name:         aws.String(CurrentAlias),
-	}).Return(nil, errors.New("API err"))
+	serviceMock.EXPECT().UpdateFunctionCode(&lambda.UpdateFunctionCodeInput{
+		FunctionName:  aws.String("testfn"),
+		Publish:       aws.Bool(false),
+		ZipFile:       []byte("zip"),
+		Architectures: []*string{aws.String("x86_64")},
+	}).Return(&lambda.FunctionConfiguration{Version: aws.String("$LATEST")}, nil)
+
+	fn := &Function{
+		FunctionName: "testfn",
+		NoPublish:    true,
+		Service:      serviceMock,
+		Log:          log.Log,
+	}
+
+	assert.Nil(t, fn.Update([]byte("zip")))
+}
+
+func TestFunction_Rollback_latestVersion(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	deployedVersions := []*lambda.FunctionConfiguration{
+		&lambda.FunctionConfiguration{Version: aws.String("$LATEST")},
+		&lambda.FunctionConfiguration{Version: aws.String("1")},
+		&lambda.FunctionConfiguration{Version: aws.String("2")},
+	}
+	currentVersion := aws.String("1")
+	afterRollbackVersion := aws.String("2")
+	serviceMock.EXPECT().GetAlias(gomock.Any()).Return(&lambda.AliasConfiguration{FunctionVersion: currentVersion}, nil)
+	serviceMock.EXPECT().ListVersionsByFunction(gomock.Any()).Return(&lambda.ListVersionsByFunctionOutput{Versions: deployedVersions}, nil)
+	serviceMock.EXPECT().UpdateAlias(&lambda.UpdateAliasInput{
+		FunctionName:    aws.String("testfn"),
+		Name:            aws.String("current"),
+		FunctionVersion: afterRollbackVersion,
+	})
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      serviceMock,
+		Log:          log.Log,
+	}
+	err := fn.Rollback()
+
+	assert.Nil(t, err)
+}
+
+func TestFunction_Rollback_UpdateAlias_failed(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	serviceMock.EXPECT().GetAlias(gomock.Any()).Return(&lambda.AliasConfiguration{FunctionVersion: aws.String("1")}, nil)
+	serviceMock.EXPECT().ListVersionsByFunction(gomock.Any()).Return(&lambda.ListVersionsByFunctionOutput{
+		Versions: []*lambda.FunctionConfiguration{
+			&lambda.FunctionConfiguration{Version: aws.String("$LATEST")},
+			&lambda.FunctionConfiguration{Version: aws.String("1")},
+			&lambda.FunctionConfiguration{Version: aws.String("2")},
+		},
+	}, nil)
+	serviceMock.EXPECT().UpdateAlias(gomock.Any()).Return(nil, errors.New("API err"))
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      serviceMock,
+		Log:          log.Log,
+	}
+	err := fn.Rollback()
+
+	assert.EqualError(t, err, "API err")
+}
+
+func TestFunction_RollbackVersion_GetAlias_failed(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	serviceMock.EXPECT().GetAlias(&lambda.GetAliasInput{
+		FunctionName: aws.String("testfn"),
+		Name:         aws.String(CurrentAlias),
+	}).Return(nil, errors.New("API err"))
 
 	fn := &Function{
 		FunctionName: "testfn",
@@ -280,7 +899,7 @@ func TestFunction_RollbackVersion_sameVersion(t *testing.T) {
 	}
 	err := fn.RollbackVersion("2")
 
-	assert.EqualError(t, err, "Specified version currently deployed.")
+	assert.Equal(t, ErrUnchanged, err)
 }
 
 func TestFunction_RollbackVersion_success(t *testing.T) {
@@ -306,3 +925,3175 @@ func TestFunction_RollbackVersion_success(t *testing.T) {
 
 	assert.Nil(t, err)
 }
+
+func TestFunction_DeployArtifact_unchanged(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	var buf bytes.Buffer
+	zip.NewWriter(&buf).Close()
+
+	file, err := ioutil.TempFile("", "apex-artifact")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+
+	file.Write(buf.Bytes())
+	file.Close()
+
+	serviceMock.EXPECT().GetFunction(gomock.Any()).Return(&lambda.GetFunctionOutput{
+		Configuration: &lambda.FunctionConfiguration{CodeSha256: aws.String(utils.Sha256(buf.Bytes()))},
+	}, nil)
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      serviceMock,
+		Log:          log.Log,
+	}
+
+	assert.Equal(t, ErrUnchanged, fn.DeployArtifact(file.Name()))
+}
+
+func TestFunction_Artifact(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("zip"))
+	}))
+	defer server.Close()
+
+	serviceMock.EXPECT().GetFunction(&lambda.GetFunctionInput{
+		FunctionName: aws.String("staging_foo"),
+		Qualifier:    aws.String("current"),
+	}).Return(&lambda.GetFunctionOutput{
+		Code:          &lambda.FunctionCodeLocation{Location: aws.String(server.URL)},
+		Configuration: &lambda.FunctionConfiguration{CodeSha256: aws.String(utils.Sha256([]byte("zip")))},
+	}, nil)
+
+	fn := &Function{FunctionName: "staging_foo", Service: serviceMock, Log: log.Log}
+
+	zip, err := fn.Artifact(CurrentAlias)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("zip"), zip)
+}
+
+func TestFunction_parseS3URI(t *testing.T) {
+	code, err := parseS3URI("s3://builds/foo/app.zip?versionId=3")
+	assert.Nil(t, err)
+	assert.Equal(t, "builds", *code.S3Bucket)
+	assert.Equal(t, "foo/app.zip", *code.S3Key)
+	assert.Equal(t, "3", *code.S3ObjectVersion)
+
+	_, err = parseS3URI("s3://builds")
+	assert.NotNil(t, err)
+}
+
+func TestFunction_validatePermissions(t *testing.T) {
+	fn := &Function{Config: Config{Permissions: []*Permission{{Name: "partner"}}}}
+	assert.EqualError(t, fn.validatePermissions(), `Permissions: "partner" requires a principal`)
+
+	fn.Permissions = append(fn.Permissions, &Permission{Name: "partner", Principal: "123456789012"})
+	fn.Permissions[0].Principal = "123456789012"
+	assert.EqualError(t, fn.validatePermissions(), `Permissions: duplicate name "partner"`)
+
+	fn.Permissions[1].Name = "s3"
+	assert.Nil(t, fn.validatePermissions())
+}
+
+func TestPermission_resolve(t *testing.T) {
+	functionArn := "arn:aws:lambda:us-west-2:123456789012:function:testfn:current"
+
+	p := &Permission{Name: "signup", Preset: "cognito", ID: "us-west-2_abc"}
+	assert.Nil(t, p.validatePreset())
+
+	r, err := p.resolve(functionArn)
+	assert.Nil(t, err)
+	assert.Equal(t, "cognito-idp.amazonaws.com", r.Principal)
+	assert.Equal(t, "arn:aws:cognito-idp:us-west-2:123456789012:userpool/us-west-2_abc", r.SourceArn)
+	assert.Equal(t, "lambda:InvokeFunction", r.action())
+
+	p = &Permission{Name: "skill", Preset: "alexa-skill", ID: "amzn1.ask.skill.1"}
+	r, err = p.resolve(functionArn)
+	assert.Nil(t, err)
+	assert.Equal(t, "alexa-appkit.amazon.com", r.Principal)
+	assert.Equal(t, "amzn1.ask.skill.1", r.EventSourceToken)
+	assert.Equal(t, "", r.SourceArn)
+
+	p = &Permission{Name: "cdn", Preset: "cloudfront", ID: "E2QWRUHAPOMQZL"}
+	r, err = p.resolve(functionArn)
+	assert.Nil(t, err)
+	assert.Equal(t, "lambda:InvokeFunctionUrl", r.action())
+	assert.Equal(t, "arn:aws:cloudfront::123456789012:distribution/E2QWRUHAPOMQZL", r.SourceArn)
+
+	fn := &Function{Config: Config{Permissions: []*Permission{{Name: "bot", Preset: "lex"}}}}
+	assert.EqualError(t, fn.validatePermissions(), `Permissions: "bot" preset "lex" requires an id`)
+
+	fn.Permissions[0].Preset = "lex-v3"
+	assert.EqualError(t, fn.validatePermissions(), `Permissions: "bot" unknown preset "lex-v3", must be one of alexa-skill, alexa-smart-home, cloudfront, cognito, lex, lex-v1`)
+
+	fn.Permissions[0] = &Permission{Name: "bot", Preset: "lex", ID: "BOT/ALIAS", Principal: "lex.amazonaws.com"}
+	assert.EqualError(t, fn.validatePermissions(), `Permissions: "bot" preset "lex" sets the principal, action and source`)
+}
+
+func TestPermission_statementID(t *testing.T) {
+	p := &Permission{Name: "s3", Principal: "s3.amazonaws.com"}
+	id := p.statementID()
+	assert.True(t, strings.HasPrefix(id, "apex.s3."))
+	assert.Equal(t, id, p.statementID())
+
+	p.SourceArn = "arn:aws:s3:::uploads"
+	assert.NotEqual(t, id, p.statementID())
+}
+
+func TestFunction_parsePolicy(t *testing.T) {
+	ids, err := parsePolicy(`{"Version":"2012-10-17","Statement":[{"Sid":"apex.s3.abc"},{"Sid":"apex-url"}]}`)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"apex.s3.abc": true, "apex-url": true}, ids)
+}
+
+func TestCommand_UnmarshalJSON(t *testing.T) {
+	var c Command
+	assert.Nil(t, json.Unmarshal([]byte(`"make build"`), &c))
+	assert.Equal(t, Command{"sh", "-c", "make build"}, c)
+
+	assert.Nil(t, json.Unmarshal([]byte(`["bazel", "build", "//:foo"]`), &c))
+	assert.Equal(t, Command{"bazel", "build", "//:foo"}, c)
+
+	assert.NotNil(t, json.Unmarshal([]byte(`[]`), &c))
+}
+
+func TestFunction_checkBudget(t *testing.T) {
+	fn := &Function{Config: Config{Memory: 2048, Timeout: 10, Budget: &Budget{MaxMemory: 1024}}, Log: log.Log}
+	assert.EqualError(t, fn.checkBudget(), "Memory: 2048MB exceeds the budget ceiling of 1024MB")
+
+	fn.OverrideBudget = true
+	assert.Nil(t, fn.checkBudget())
+}
+
+type alarmService struct {
+	cloudwatchiface.CloudWatchAPI
+	alarms map[string]*cloudwatch.PutMetricAlarmInput
+}
+
+func (s *alarmService) DescribeAlarmsPages(in *cloudwatch.DescribeAlarmsInput, fn func(*cloudwatch.DescribeAlarmsOutput, bool) bool) error {
+	page := &cloudwatch.DescribeAlarmsOutput{}
+	for name := range s.alarms {
+		if strings.HasPrefix(name, *in.AlarmNamePrefix) {
+			page.MetricAlarms = append(page.MetricAlarms, &cloudwatch.MetricAlarm{AlarmName: aws.String(name)})
+		}
+	}
+	fn(page, true)
+	return nil
+}
+
+func (s *alarmService) PutMetricAlarm(in *cloudwatch.PutMetricAlarmInput) (*cloudwatch.PutMetricAlarmOutput, error) {
+	s.alarms[*in.AlarmName] = in
+	return &cloudwatch.PutMetricAlarmOutput{}, nil
+}
+
+func (s *alarmService) DeleteAlarms(in *cloudwatch.DeleteAlarmsInput) (*cloudwatch.DeleteAlarmsOutput, error) {
+	for _, name := range in.AlarmNames {
+		delete(s.alarms, *name)
+	}
+	return &cloudwatch.DeleteAlarmsOutput{}, nil
+}
+
+func (s *alarmService) names() (names []string) {
+	for name := range s.alarms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestFunction_alarmInput(t *testing.T) {
+	fn := &Function{FunctionName: "app_foo", Config: Config{Timeout: 10}}
+
+	in := fn.alarmInput("apex/app_foo/errors", &Alarm{Type: AlarmErrors, Actions: []string{"arn:sns"}})
+	assert.Equal(t, "Errors", *in.MetricName)
+	assert.Equal(t, "Sum", *in.Statistic)
+	assert.Equal(t, 1.0, *in.Threshold)
+	assert.Equal(t, int64(300), *in.Period)
+	assert.Equal(t, int64(1), *in.EvaluationPeriods)
+	assert.Equal(t, "app_foo", *in.Dimensions[0].Value)
+	assert.Equal(t, "arn:sns", *in.OKActions[0])
+
+	in = fn.alarmInput("apex/app_foo/errorRate", &Alarm{Type: AlarmErrorRate, Period: 60})
+	assert.Nil(t, in.MetricName)
+	assert.Nil(t, in.Period)
+	assert.Equal(t, 5.0, *in.Threshold)
+	assert.Equal(t, "100 * errors / invocations", *in.Metrics[2].Expression)
+	assert.Equal(t, int64(60), *in.Metrics[0].MetricStat.Period)
+
+	in = fn.alarmInput("apex/app_foo/duration", &Alarm{Type: AlarmDuration})
+	assert.Equal(t, "Duration", *in.MetricName)
+	assert.Equal(t, "p99", *in.ExtendedStatistic)
+	assert.Equal(t, 8000.0, *in.Threshold)
+
+	in = fn.alarmInput("apex/app_foo/duration", &Alarm{Type: AlarmDuration, Statistic: "Average", Threshold: 500})
+	assert.Equal(t, "Average", *in.Statistic)
+	assert.Nil(t, in.ExtendedStatistic)
+	assert.Equal(t, 500.0, *in.Threshold)
+
+	in = fn.alarmInput("apex/app_foo/throttles", &Alarm{Type: AlarmThrottles, Threshold: 10, EvaluationPeriods: 3})
+	assert.Equal(t, "Throttles", *in.MetricName)
+	assert.Equal(t, 10.0, *in.Threshold)
+	assert.Equal(t, int64(3), *in.EvaluationPeriods)
+}
+
+func TestFunction_validateAlarms(t *testing.T) {
+	fn := &Function{Config: Config{Alarms: []*Alarm{{Type: AlarmErrors}, {Type: AlarmErrors, Name: "errors-high", Threshold: 10}}}}
+	assert.Nil(t, fn.validateAlarms())
+
+	fn.Alarms = []*Alarm{{Type: "latency"}}
+	assert.EqualError(t, fn.validateAlarms(), `Alarms: invalid type "latency", must be one of errors, errorRate, throttles, duration`)
+
+	fn.Alarms = []*Alarm{{Type: AlarmErrors}, {Type: AlarmErrors}}
+	assert.EqualError(t, fn.validateAlarms(), `Alarms: duplicate alarm "errors"`)
+
+	fn.Alarms = []*Alarm{{Type: AlarmErrors, Name: "budget-cost"}}
+	assert.EqualError(t, fn.validateAlarms(), `Alarms: alarm name "budget-cost" is reserved for budgets`)
+}
+
+func TestFunction_DeployAlarms(t *testing.T) {
+	cw := &alarmService{alarms: map[string]*cloudwatch.PutMetricAlarmInput{
+		"apex/app_foo/old":                {},
+		"apex/app_foo/budget-cost":        {},
+		"apex/app_foo/budget-invocations": {},
+		"apex/app_foo_worker/errors":      {},
+		"manual":                          {},
+	}}
+
+	fn := &Function{
+		FunctionName: "app_foo",
+		CloudWatch:   cw,
+		Log:          log.Log,
+		Config: Config{
+			Memory: 128,
+			Alarms: []*Alarm{{Type: AlarmErrors}, {Type: AlarmDuration, Name: "slow"}},
+			Budget: &Budget{Invocations: 3000},
+		},
+	}
+
+	assert.Nil(t, fn.DeployAlarms())
+	assert.Equal(t, []string{
+		"apex/app_foo/budget-invocations",
+		"apex/app_foo/errors",
+		"apex/app_foo/slow",
+		"apex/app_foo_worker/errors",
+		"manual",
+	}, cw.names())
+	assert.Equal(t, 100.0, *cw.alarms["apex/app_foo/budget-invocations"].Threshold)
+
+	fn.Alarms = nil
+	assert.Nil(t, fn.DeployAlarms())
+	assert.Equal(t, []string{"apex/app_foo/budget-invocations", "apex/app_foo_worker/errors", "manual"}, cw.names())
+
+	service := &deletedService{functions: map[string]string{"app_foo": "arn:aws:iam::123:role/lambda"}}
+	fn.Service = service
+	assert.Nil(t, fn.Delete(DeleteOptions{Force: true}))
+	assert.Equal(t, []string{"apex/app_foo_worker/errors", "manual"}, cw.names())
+}
+
+func TestFunction_budgetAlarms(t *testing.T) {
+	fn := &Function{FunctionName: "app_foo", Config: Config{Memory: 1024, Budget: &Budget{Invocations: 3000, Cost: 30}}}
+
+	alarms := fn.budgetAlarms()
+	assert.Len(t, alarms, 2)
+	assert.Equal(t, "apex/app_foo/budget-invocations", *alarms[0].AlarmName)
+	assert.Equal(t, 100.0, *alarms[0].Threshold)
+	assert.Equal(t, "apex/app_foo/budget-cost", *alarms[1].AlarmName)
+	assert.Equal(t, 1.0, *alarms[1].Threshold)
+	assert.Equal(t, "invocations * 2e-07 + duration / 1000 * 1 * 1.66667e-05", *alarms[1].Metrics[2].Expression)
+
+	fn.Region = "cn-north-1"
+	alarms = fn.budgetAlarms()
+	assert.Equal(t, "invocations * 1.36e-06 + duration / 1000 * 1 * 0.000113477", *alarms[1].Metrics[2].Expression)
+}
+
+func TestPartitionOf(t *testing.T) {
+	assert.Equal(t, PartitionAWS, PartitionOf("us-west-2"))
+	assert.Equal(t, PartitionGovCloud, PartitionOf("us-gov-west-1"))
+	assert.Equal(t, PartitionChina, PartitionOf("cn-northwest-1"))
+	assert.Equal(t, PartitionAWS, PartitionOf(""))
+
+	fn := &Function{Region: "us-gov-east-1"}
+	assert.Equal(t, PartitionGovCloud, fn.partition())
+
+	fn.Partition = PartitionAWS
+	assert.Equal(t, PartitionAWS, fn.partition())
+}
+
+func TestFunction_AliasName(t *testing.T) {
+	fn := &Function{}
+	assert.Equal(t, "current", fn.AliasName())
+	assert.Nil(t, fn.validateAlias())
+
+	fn.Alias = "live"
+	assert.Equal(t, "live", fn.AliasName())
+	assert.Nil(t, fn.validateAlias())
+
+	fn.Alias = "123"
+	assert.EqualError(t, fn.validateAlias(), `Alias: invalid name "123"`)
+
+	fn.Alias = "previous"
+	assert.EqualError(t, fn.validateAlias(), `Alias: name "previous" is reserved`)
+}
+
+func TestFunction_versionDescription(t *testing.T) {
+	os.Setenv("APEX_DEPLOYER", "ci")
+	defer os.Unsetenv("APEX_DEPLOYER")
+
+	fn := &Function{
+		Name: "foo",
+		Git:  &git.Info{Commit: "abc1234def", Message: "Fix retries"},
+		Config: Config{
+			VersionDescription: "{{.Function.Name}} {{.Git.Short}} by {{.Deployer}}: {{.Git.Message}}",
+		},
+	}
+
+	assert.Nil(t, fn.validateVersionDescription())
+
+	s, err := fn.versionDescription()
+	assert.Nil(t, err)
+	assert.Equal(t, "foo abc1234 by ci: Fix retries", s)
+
+	fn.VersionDescription = "{{.Missing"
+	assert.NotNil(t, fn.validateVersionDescription())
+}
+
+func TestFunction_Test(t *testing.T) {
+	fn := &Function{Path: ".", Log: log.Log}
+	assert.Nil(t, fn.Test())
+
+	fn.TestCommand = Command{"sh", "-c", `test "$APEX_FUNCTION_NAME" = foo`}
+	fn.Name = "foo"
+	assert.Nil(t, fn.Test())
+
+	fn.Name = "bar"
+	assert.EqualError(t, fn.Test(), "tests failed: exit status 1")
+}
+
+type requestLogs struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+}
+
+func (l *requestLogs) FilterLogEvents(in *cloudwatchlogs.FilterLogEventsInput) (*cloudwatchlogs.FilterLogEventsOutput, error) {
+	return &cloudwatchlogs.FilterLogEventsOutput{
+		Events: []*cloudwatchlogs.FilteredLogEvent{{LogStreamName: aws.String("stream"), Timestamp: aws.Int64(1)}},
+	}, nil
+}
+
+func (l *requestLogs) GetLogEvents(in *cloudwatchlogs.GetLogEventsInput) (*cloudwatchlogs.GetLogEventsOutput, error) {
+	var events []*cloudwatchlogs.OutputLogEvent
+	for _, s := range []string{"REPORT RequestId: a\n", "START RequestId: b\n", "hello\n", "REPORT RequestId: b\n", "START RequestId: c\n"} {
+		events = append(events, &cloudwatchlogs.OutputLogEvent{Message: aws.String(s)})
+	}
+	return &cloudwatchlogs.GetLogEventsOutput{Events: events}, nil
+}
+
+func TestFunction_RequestLogs(t *testing.T) {
+	fn := &Function{
+		FunctionName:   "testfn",
+		CloudWatchLogs: &requestLogs{},
+		Log:            log.Log,
+	}
+
+	b, err := fn.RequestLogs("b", time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, "START RequestId: b\nhello\nREPORT RequestId: b\n", string(b))
+}
+
+type logGroups struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	groups    map[string]map[string]string
+	retention map[string]int64
+	calls     []string
+}
+
+func (l *logGroups) CreateLogGroup(in *cloudwatchlogs.CreateLogGroupInput) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	l.calls = append(l.calls, "create "+*in.LogGroupName)
+	if _, ok := l.groups[*in.LogGroupName]; ok {
+		return nil, awserr.New("ResourceAlreadyExistsException", "exists", nil)
+	}
+	l.groups[*in.LogGroupName] = aws.StringValueMap(in.Tags)
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+func (l *logGroups) TagLogGroup(in *cloudwatchlogs.TagLogGroupInput) (*cloudwatchlogs.TagLogGroupOutput, error) {
+	l.calls = append(l.calls, "tag "+*in.LogGroupName)
+	for k, v := range in.Tags {
+		l.groups[*in.LogGroupName][k] = *v
+	}
+	return &cloudwatchlogs.TagLogGroupOutput{}, nil
+}
+
+func (l *logGroups) PutRetentionPolicy(in *cloudwatchlogs.PutRetentionPolicyInput) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	l.calls = append(l.calls, "retention "+*in.LogGroupName)
+	l.retention[*in.LogGroupName] = *in.RetentionInDays
+	return &cloudwatchlogs.PutRetentionPolicyOutput{}, nil
+}
+
+func TestFunction_DeployLogGroup(t *testing.T) {
+	logs := &logGroups{groups: map[string]map[string]string{}, retention: map[string]int64{}}
+
+	fn := &Function{
+		FunctionName:   "testfn",
+		CloudWatchLogs: logs,
+		Log:            log.Log,
+	}
+
+	assert.Nil(t, fn.DeployLogGroup())
+	assert.Equal(t, []string{"create /aws/lambda/testfn"}, logs.calls)
+	assert.Equal(t, map[string]string{}, logs.groups["/aws/lambda/testfn"])
+
+	fn.LogRetention = 14
+	fn.LogTags = map[string]string{"team": "core"}
+	assert.Nil(t, fn.DeployLogGroup())
+	assert.Equal(t, []string{"create /aws/lambda/testfn", "create /aws/lambda/testfn", "tag /aws/lambda/testfn", "retention /aws/lambda/testfn"}, logs.calls)
+	assert.Equal(t, map[string]string{"team": "core"}, logs.groups["/aws/lambda/testfn"])
+	assert.Equal(t, int64(14), logs.retention["/aws/lambda/testfn"])
+
+	fn.FunctionName = "other"
+	assert.Nil(t, fn.DeployLogGroup())
+	assert.Equal(t, map[string]string{"team": "core"}, logs.groups["/aws/lambda/other"])
+	assert.Equal(t, int64(14), logs.retention["/aws/lambda/other"])
+
+	fn.CloudWatchLogs = nil
+	assert.Nil(t, fn.DeployLogGroup())
+}
+
+type resolver map[string]string
+
+func (r resolver) Output(function, attr string) (string, error) {
+	v, ok := r[function+"."+attr]
+	if !ok {
+		return "", errors.New("not deployed")
+	}
+	return v, nil
+}
+
+func TestFunction_interpolate(t *testing.T) {
+	fn := &Function{
+		Log:      log.Log,
+		Resolver: resolver{"worker.arn": "arn:aws:lambda:us-west-2:123456789012:function:app_worker:current"},
+	}
+
+	s, err := fn.interpolate("queue=${function:worker.arn}")
+	assert.Nil(t, err)
+	assert.Equal(t, "queue=arn:aws:lambda:us-west-2:123456789012:function:app_worker:current", s)
+
+	v, err := fn.interpolateValue(map[string]interface{}{
+		"resources": []interface{}{"${function:worker.arn}"},
+		"size":      1.0,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"resources": []interface{}{"arn:aws:lambda:us-west-2:123456789012:function:app_worker:current"},
+		"size":      1.0,
+	}, v)
+
+	_, err = fn.interpolate("${function:api.url}")
+	assert.EqualError(t, err, "resolving ${function:api.url}: not deployed")
+
+	fn.Resolver = nil
+	_, err = fn.interpolate("${function:worker.arn}")
+	assert.EqualError(t, err, "cannot resolve ${function:worker.arn} outside of a project")
+}
+
+type parameters struct {
+	ssmiface.SSMAPI
+	values map[string]string
+}
+
+func (p *parameters) PutParameter(in *ssm.PutParameterInput) (*ssm.PutParameterOutput, error) {
+	p.values[*in.Name] = *in.Value
+	return &ssm.PutParameterOutput{}, nil
+}
+
+func TestFunction_DeployParameters(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	serviceMock.EXPECT().GetAlias(&lambda.GetAliasInput{
+		FunctionName: aws.String("app_foo"),
+		Name:         aws.String("current"),
+	}).Return(&lambda.AliasConfiguration{
+		AliasArn:        aws.String("arn:aws:lambda:us-west-2:123456789012:function:app_foo:current"),
+		FunctionVersion: aws.String("7"),
+	}, nil)
+
+	store := &parameters{values: map[string]string{}}
+
+	fn := &Function{
+		FunctionName:  "app_foo",
+		ParameterPath: "/app/prod/foo",
+		Service:       serviceMock,
+		SSM:           store,
+		Log:           log.Log,
+	}
+
+	assert.Nil(t, fn.DeployParameters())
+	assert.Equal(t, map[string]string{
+		"/app/prod/foo/arn":       "arn:aws:lambda:us-west-2:123456789012:function:app_foo",
+		"/app/prod/foo/alias-arn": "arn:aws:lambda:us-west-2:123456789012:function:app_foo:current",
+		"/app/prod/foo/version":   "7",
+	}, store.values)
+}
+
+func TestFunction_buildContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex-cache")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fn := &Function{
+		Name:       "foo",
+		Path:       "_fixtures/nodejsDefaultFile",
+		Stage:      "staging",
+		BuildCache: filepath.Join(dir, "foo"),
+		Log:        log.Log,
+	}
+
+	ctx, err := fn.buildContext(Arm64)
+	assert.Nil(t, err)
+	assert.Equal(t, "_fixtures/nodejsDefaultFile", ctx.Dir)
+	assert.Equal(t, Arm64, ctx.Arch)
+	assert.Equal(t, "staging", ctx.Stage)
+	assert.Equal(t, []string{"APEX_FUNCTION_NAME=foo", "APEX_ARCH=arm64"}, ctx.Env)
+	assert.Equal(t, filepath.Join(dir, "foo", "go-build"), ctx.Cache("go-build"))
+
+	info, err := os.Stat(ctx.CacheDir)
+	assert.Nil(t, err)
+	assert.True(t, info.IsDir())
+}
+
+func TestFunction_renderer(t *testing.T) {
+	os.Setenv("APEX_TEST_TEMPLATE", "yes")
+	defer os.Unsetenv("APEX_TEST_TEMPLATE")
+
+	fn := &Function{
+		Name:     "foo",
+		Stage:    "prod",
+		Region:   "us-west-2",
+		Log:      log.Log,
+		Resolver: resolver{"worker.arn": "arn:aws:lambda:us-west-2:123456789012:function:app_worker:current"},
+		Config:   Config{Templates: []string{"*.json", "conf/*.yml"}},
+	}
+
+	assert.Nil(t, fn.validateTemplates())
+	assert.True(t, fn.templated("config.json"))
+	assert.True(t, fn.templated("lib/config.json"))
+	assert.True(t, fn.templated("conf/app.yml"))
+	assert.False(t, fn.templated("lib/conf/app.yml"))
+	assert.False(t, fn.templated("index.js"))
+
+	render := fn.renderer(X86_64)
+
+	b, err := render("config.json", []byte(`{{.Stage}} {{.Region}} {{.Alias}} {{env "APEX_TEST_TEMPLATE"}} {{output "worker" "arn"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "prod us-west-2 current yes arn:aws:lambda:us-west-2:123456789012:function:app_worker:current", string(b))
+
+	b, err = render("index.js", []byte("`${x}` {{.Stage}}"))
+	assert.Nil(t, err)
+	assert.Equal(t, "`${x}` {{.Stage}}", string(b))
+
+	_, err = render("config.json", []byte(`{{output "api" "url"}}`))
+	assert.EqualError(t, err, `rendering config.json: template: config.json:1:2: executing "config.json" at <output "api" "url">: error calling output: not deployed`)
+
+	fn.Templates = []string{"[a-"}
+	assert.EqualError(t, fn.validateTemplates(), `Templates: invalid pattern "[a-"`)
+
+	fn.Templates = nil
+	assert.Nil(t, fn.renderer(X86_64))
+}
+
+func TestFunction_addManifest(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	file, _ := w.Create("index.js")
+	file.Write([]byte("exports.handle = () => {}"))
+	assert.Nil(t, w.Close())
+
+	fn := &Function{
+		Name: "foo",
+		Log:  log.Log,
+		Git:  &git.Info{Commit: "abc1234def", Time: time.Unix(1500000000, 0).UTC()},
+	}
+
+	first, err := fn.addManifest(newBundle(buf.Bytes()))
+	assert.Nil(t, err)
+	defer first.Close()
+
+	again, err := fn.addManifest(newBundle(buf.Bytes()))
+	assert.Nil(t, err)
+	defer again.Close()
+
+	assert.Equal(t, first.checksum(), again.checksum(), "rebuilds should be identical")
+
+	b, err := first.read()
+	assert.Nil(t, err)
+
+	m, err := ReadManifest(b)
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", m.Function)
+	assert.Equal(t, "abc1234def", m.Commit)
+	assert.Equal(t, time.Unix(1500000000, 0).UTC(), m.Built)
+	assert.Equal(t, map[string]string{"index.js": "1dfe5f8d724a33fe9b42eaffa8e1929e92a9f64f5c997bafd94dd28c2f32868d"}, m.Files)
+
+	_, err = ReadManifest(buf.Bytes())
+	assert.Equal(t, ErrNoManifest, err)
+
+	m.Files["index.js"] = "0000"
+	manifest, _ := json.Marshal(m)
+
+	buf.Reset()
+	w = zip.NewWriter(&buf)
+	file, _ = w.Create("index.js")
+	file.Write([]byte("exports.handle = () => {}"))
+	file, _ = w.Create(ManifestFile)
+	file.Write(manifest)
+	assert.Nil(t, w.Close())
+
+	_, err = ReadManifest(buf.Bytes())
+	assert.EqualError(t, err, "file index.js checksum 1dfe5f8d724a33fe9b42eaffa8e1929e92a9f64f5c997bafd94dd28c2f32868d does not match 0000")
+}
+
+type signingJobs struct {
+	signeriface.SignerAPI
+	job *signer.DescribeSigningJobOutput
+}
+
+func (s *signingJobs) DescribeSigningJob(in *signer.DescribeSigningJobInput) (*signer.DescribeSigningJobOutput, error) {
+	if *in.JobId != "job-1" {
+		return nil, errors.New("not found")
+	}
+	return s.job, nil
+}
+
+type signedService struct {
+	lambdaiface.LambdaAPI
+}
+
+func (s *signedService) GetFunction(in *lambda.GetFunctionInput) (*lambda.GetFunctionOutput, error) {
+	return &lambda.GetFunctionOutput{
+		Configuration: &lambda.FunctionConfiguration{
+			SigningJobArn:            aws.String("arn:aws:signer:us-west-2:123456789012:/signing-jobs/job-1"),
+			SigningProfileVersionArn: aws.String("arn:profile/prod/v1"),
+		},
+	}, nil
+}
+
+func (s *signedService) GetCodeSigningConfig(in *lambda.GetCodeSigningConfigInput) (*lambda.GetCodeSigningConfigOutput, error) {
+	return &lambda.GetCodeSigningConfigOutput{
+		CodeSigningConfig: &lambda.CodeSigningConfig{
+			AllowedPublishers: &lambda.AllowedPublishers{SigningProfileVersionArns: aws.StringSlice([]string{"arn:profile/prod/v1"})},
+		},
+	}, nil
+}
+
+func TestFunction_Signature(t *testing.T) {
+	expires := time.Now().Add(24 * time.Hour).UTC()
+	jobs := &signingJobs{job: &signer.DescribeSigningJobOutput{
+		ProfileName:        aws.String("prod"),
+		Status:             aws.String(signer.SigningStatusSucceeded),
+		SignatureExpiresAt: &expires,
+	}}
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      &signedService{},
+		Signer:       jobs,
+		Log:          log.Log,
+		Config:       Config{CodeSigningConfigArn: "arn:csc"},
+	}
+
+	sig, err := fn.Signature()
+	assert.Nil(t, err)
+	assert.Equal(t, "prod", sig.Profile)
+	assert.Equal(t, "arn:profile/prod/v1", sig.ProfileVersionArn)
+	assert.Equal(t, expires, sig.Expires)
+
+	jobs.job.RevocationRecord = &signer.SigningJobRevocationRecord{RevokedBy: aws.String("admin"), Reason: aws.String("leaked")}
+	_, err = fn.Signature()
+	assert.EqualError(t, err, "signature revoked by admin: leaked")
+
+	jobs.job.RevocationRecord = nil
+	jobs.job.SignatureExpiresAt = aws.Time(time.Unix(1500000000, 0).UTC())
+	_, err = fn.Signature()
+	assert.EqualError(t, err, "signature expired 2017-07-14T02:40:00Z")
+
+	fn.CodeSigningConfigArn = ""
+	sig, err = fn.Signature()
+	assert.Nil(t, sig)
+	assert.Nil(t, err)
+}
+
+// signedInfoService is a function without aliases whose code is signed.
+type signedInfoService struct {
+	signedService
+}
+
+func (s *signedInfoService) ListAliasesPages(in *lambda.ListAliasesInput, fn func(*lambda.ListAliasesOutput, bool) bool) error {
+	fn(&lambda.ListAliasesOutput{}, true)
+	return nil
+}
+
+func TestFunction_Info_signature(t *testing.T) {
+	expires := time.Now().Add(24 * time.Hour).UTC()
+	jobs := &signingJobs{job: &signer.DescribeSigningJobOutput{
+		ProfileName:        aws.String("prod"),
+		Status:             aws.String(signer.SigningStatusSucceeded),
+		SignatureExpiresAt: &expires,
+	}}
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      &signedInfoService{},
+		Signer:       jobs,
+		Log:          log.Log,
+		Config:       Config{CodeSigningConfigArn: "arn:csc"},
+	}
+
+	info, err := fn.Info()
+	assert.Nil(t, err)
+	assert.Equal(t, &SignatureInfo{
+		Profile:           "prod",
+		ProfileVersionARN: "arn:profile/prod/v1",
+		JobARN:            "arn:aws:signer:us-west-2:123456789012:/signing-jobs/job-1",
+		Expires:           &expires,
+	}, info.Signature)
+
+	jobs.job.RevocationRecord = &signer.SigningJobRevocationRecord{RevokedBy: aws.String("admin"), Reason: aws.String("leaked")}
+	info, err = fn.Info()
+	assert.Nil(t, err)
+	assert.Equal(t, "prod", info.Signature.Profile)
+	assert.Equal(t, "signature revoked by admin: leaked", info.Signature.Error)
+
+	b, err := json.Marshal(info.Signature)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"profile":"prod","profileVersionArn":"arn:profile/prod/v1","jobArn":"arn:aws:signer:us-west-2:123456789012:/signing-jobs/job-1","expires":"`+expires.Format(time.RFC3339Nano)+`","error":"signature revoked by admin: leaked"}`, string(b))
+
+	fn.CodeSigningConfigArn = ""
+	info, err = fn.Info()
+	assert.Nil(t, err)
+	assert.Nil(t, info.Signature)
+}
+
+type decrypter struct {
+	vars  string
+	err   error
+	calls int
+}
+
+func (d *decrypter) Decrypt(ciphertext []byte) ([]byte, error) {
+	d.calls++
+	return []byte(d.vars), d.err
+}
+
+func TestFunction_environment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex-env")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		".env":         "A=env\nB=env\nC=env\nD=env\n",
+		".env.prod":    "B=stage\nC=stage\nD=stage\n",
+		".env.staging": "C=staging\n",
+		".env.enc":     "ciphertext",
+	}
+
+	for name, s := range files {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(s), 0600))
+	}
+
+	fn := &Function{
+		Path:      dir,
+		Stage:     "prod",
+		Decrypter: &decrypter{vars: `{"C":"encrypted","D":"encrypted"}`},
+		Log:       log.Log,
+		Config:    Config{Environment: map[string]string{"A": "config", "CONFIG": "config"}},
+	}
+
+	fn.SetEnv("D", "flag")
+
+	vars, err := fn.environment()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"CONFIG": "config",
+		"A":      "env",
+		"B":      "stage",
+		"C":      "encrypted",
+		"D":      "flag",
+	}, vars)
+
+	fn.Decrypter = &decrypter{err: errors.New("access denied")}
+	_, err = fn.environment()
+	assert.EqualError(t, err, "decrypting env: access denied")
+
+	fn.Decrypter = &decrypter{vars: "C=encrypted"}
+	_, err = fn.environment()
+	assert.Error(t, err)
+
+	fn.Decrypter = nil
+	_, err = fn.environment()
+	assert.EqualError(t, err, ".env.enc found but no decrypter is configured")
+}
+
+type envService struct {
+	lambdaiface.LambdaAPI
+	remote  map[string]string
+	updated map[string]string
+}
+
+func (s *envService) GetFunction(in *lambda.GetFunctionInput) (*lambda.GetFunctionOutput, error) {
+	return &lambda.GetFunctionOutput{
+		Configuration: &lambda.FunctionConfiguration{
+			Environment: &lambda.EnvironmentResponse{Variables: aws.StringMap(s.remote)},
+		},
+	}, nil
+}
+
+func (s *envService) UpdateFunctionConfiguration(in *lambda.UpdateFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
+	s.updated = aws.StringValueMap(in.Environment.Variables)
+	return &lambda.FunctionConfiguration{CodeSha256: aws.String("abc")}, nil
+}
+
+func TestFunction_deployEnvironment(t *testing.T) {
+	service := &envService{remote: map[string]string{"OLD": "1"}}
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Path:         "_fixtures/nodejsDefaultFile",
+		Service:      service,
+		Log:          log.Log,
+		Config: Config{
+			EnvMode:     EnvFile,
+			Environment: map[string]string{"NAME": "foo"},
+		},
+	}
+
+	cfg, err := fn.deployEnvironment()
+	assert.Nil(t, err)
+	assert.Nil(t, cfg)
+	assert.Nil(t, service.updated)
+
+	fn.EnvMode = EnvNative
+	cfg, err = fn.deployEnvironment()
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", *cfg.CodeSha256)
+	assert.Equal(t, map[string]string{"NAME": "foo"}, service.updated)
+
+	service.remote, service.updated = service.updated, nil
+	cfg, err = fn.deployEnvironment()
+	assert.Nil(t, err)
+	assert.Nil(t, cfg)
+	assert.Nil(t, service.updated)
+
+	fn.Environment["AWS_REGION"] = "us-east-1"
+	_, err = fn.deployEnvironment()
+	assert.EqualError(t, err, `environment variable AWS_REGION is reserved by Lambda, use the "file" envMode to override it`)
+
+	fn.EnvMode = "json"
+	assert.EqualError(t, fn.validateEnvMode(), `EnvMode: invalid mode "json", must be one of file, native`)
+}
+
+type mappingService struct {
+	lambdaiface.LambdaAPI
+	states map[string]string
+}
+
+func (s *mappingService) ListEventSourceMappingsPages(in *lambda.ListEventSourceMappingsInput, fn func(*lambda.ListEventSourceMappingsOutput, bool) bool) error {
+	var page lambda.ListEventSourceMappingsOutput
+	for _, uuid := range []string{"a", "b"} {
+		page.EventSourceMappings = append(page.EventSourceMappings, &lambda.EventSourceMappingConfiguration{
+			UUID:           aws.String(uuid),
+			State:          aws.String(s.states[uuid]),
+			EventSourceArn: aws.String("arn:aws:sqs:us-west-2:123456789012:" + uuid),
+		})
+	}
+	fn(&page, true)
+	return nil
+}
+
+func (s *mappingService) UpdateEventSourceMapping(in *lambda.UpdateEventSourceMappingInput) (*lambda.EventSourceMappingConfiguration, error) {
+	state := "Disabling"
+	if *in.Enabled {
+		state = "Enabling"
+	}
+	s.states[*in.UUID] = state
+	return &lambda.EventSourceMappingConfiguration{UUID: in.UUID, State: aws.String(state)}, nil
+}
+
+func (s *mappingService) GetEventSourceMapping(in *lambda.GetEventSourceMappingInput) (*lambda.EventSourceMappingConfiguration, error) {
+	s.states[*in.UUID] = map[string]string{"Disabling": "Disabled", "Enabling": "Enabled"}[s.states[*in.UUID]]
+	return &lambda.EventSourceMappingConfiguration{UUID: in.UUID, State: aws.String(s.states[*in.UUID])}, nil
+}
+
+func TestFunction_pauseEventSources(t *testing.T) {
+	defer func(d time.Duration) { readyInterval = d }(readyInterval)
+	readyInterval = time.Millisecond
+
+	service := &mappingService{states: map[string]string{"a": "Enabled", "b": "Disabled"}}
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      service,
+		Log:          log.Log,
+	}
+
+	paused, err := fn.pauseEventSources()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, paused)
+	assert.Equal(t, map[string]string{"a": "Disabled", "b": "Disabled"}, service.states)
+
+	assert.Nil(t, fn.resumeEventSources(paused))
+	assert.Equal(t, map[string]string{"a": "Enabled", "b": "Disabled"}, service.states)
+}
+
+// resumeFailedService fails to enable event source mappings.
+type resumeFailedService struct {
+	mappingService
+}
+
+func (s *resumeFailedService) UpdateEventSourceMapping(in *lambda.UpdateEventSourceMappingInput) (*lambda.EventSourceMappingConfiguration, error) {
+	if *in.Enabled {
+		return nil, errors.New("boom")
+	}
+	return s.mappingService.UpdateEventSourceMapping(in)
+}
+
+func (s *resumeFailedService) UpdateFunctionConfiguration(in *lambda.UpdateFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
+	return &lambda.FunctionConfiguration{}, nil
+}
+
+func (s *resumeFailedService) GetFunctionUrlConfig(in *lambda.GetFunctionUrlConfigInput) (*lambda.GetFunctionUrlConfigOutput, error) {
+	return nil, awserr.New("ResourceNotFoundException", "not found", nil)
+}
+
+func (s *resumeFailedService) GetPolicy(in *lambda.GetPolicyInput) (*lambda.GetPolicyOutput, error) {
+	return nil, awserr.New("ResourceNotFoundException", "not found", nil)
+}
+
+// nativeEnvService is a function whose native environment is deployed.
+type nativeEnvService struct {
+	resumeFailedService
+}
+
+func (s *nativeEnvService) GetFunction(in *lambda.GetFunctionInput) (*lambda.GetFunctionOutput, error) {
+	return &lambda.GetFunctionOutput{
+		Configuration: &lambda.FunctionConfiguration{
+			Environment: &lambda.EnvironmentResponse{Variables: aws.StringMap(map[string]string{"TOKEN": "secret", "TABLE_USERS": "users"})},
+		},
+	}, nil
+}
+
+func TestFunction_deploy_environment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex-env")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, EncryptedEnvFile), []byte("ciphertext"), 0644))
+
+	decrypt := &decrypter{vars: `{"TOKEN":"secret"}`}
+
+	fn := &Function{
+		Config:       Config{Handler: "index.handle", EnvMode: EnvNative, Tables: []*Table{{Name: "users"}}},
+		FunctionName: "testfn",
+		Path:         dir,
+		Service:      &nativeEnvService{},
+		Decrypter:    decrypt,
+		Log:          log.Log,
+	}
+
+	var vars []map[string]string
+
+	err = fn.deploy(func() error {
+		for i := 0; i < 2; i++ {
+			v, err := fn.environment()
+			assert.Nil(t, err)
+			vars = append(vars, v)
+		}
+		return ErrUnchanged
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, decrypt.calls)
+	assert.Equal(t, map[string]string{"TOKEN": "secret", "TABLE_USERS": "users"}, vars[0])
+	assert.Equal(t, vars[0], vars[1])
+
+	_, err = fn.environment()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, decrypt.calls)
+}
+
+func TestFunction_deploy_resumeFailed(t *testing.T) {
+	defer func(d time.Duration) { readyInterval = d }(readyInterval)
+	readyInterval = time.Millisecond
+
+	service := &resumeFailedService{mappingService{states: map[string]string{"a": "Enabled", "b": "Disabled"}}}
+
+	fn := &Function{
+		Config:       Config{Handler: "index.handle", PauseEventSources: true},
+		FunctionName: "testfn",
+		Service:      service,
+		Log:          log.Log,
+	}
+
+	err := fn.deploy(func() error { return ErrUnchanged })
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, map[string]string{"a": "Disabled", "b": "Disabled"}, service.states)
+}
+
+type sourcesService struct {
+	lambdaiface.LambdaAPI
+	mappings []*lambda.EventSourceMappingConfiguration
+	created  []*lambda.CreateEventSourceMappingInput
+	updated  []*lambda.UpdateEventSourceMappingInput
+}
+
+func (s *sourcesService) ListEventSourceMappingsPages(in *lambda.ListEventSourceMappingsInput, fn func(*lambda.ListEventSourceMappingsOutput, bool) bool) error {
+	fn(&lambda.ListEventSourceMappingsOutput{EventSourceMappings: s.mappings}, true)
+	return nil
+}
+
+func (s *sourcesService) CreateEventSourceMapping(in *lambda.CreateEventSourceMappingInput) (*lambda.EventSourceMappingConfiguration, error) {
+	s.created = append(s.created, in)
+	return &lambda.EventSourceMappingConfiguration{}, nil
+}
+
+func (s *sourcesService) UpdateEventSourceMapping(in *lambda.UpdateEventSourceMappingInput) (*lambda.EventSourceMappingConfiguration, error) {
+	s.updated = append(s.updated, in)
+	return &lambda.EventSourceMappingConfiguration{}, nil
+}
+
+func TestFunction_DeployEventSources(t *testing.T) {
+	stream := "arn:aws:kinesis:us-west-2:123456789012:stream/clicks/consumer/apex:1"
+	table := "arn:aws:dynamodb:us-west-2:123456789012:table/users/stream/2020"
+	queue := "arn:aws:sqs:us-west-2:123456789012:jobs"
+
+	service := &sourcesService{
+		mappings: []*lambda.EventSourceMappingConfiguration{
+			{
+				UUID:                           aws.String("a"),
+				EventSourceArn:                 aws.String(stream),
+				FunctionArn:                    aws.String("arn:aws:lambda:us-west-2:123456789012:function:testfn:current"),
+				BatchSize:                      aws.Int64(100),
+				MaximumBatchingWindowInSeconds: aws.Int64(5),
+				ParallelizationFactor:          aws.Int64(4),
+				BisectBatchOnFunctionError:     aws.Bool(true),
+				TumblingWindowInSeconds:        aws.Int64(0),
+				MaximumRetryAttempts:           aws.Int64(-1),
+				MaximumRecordAgeInSeconds:      aws.Int64(-1),
+			},
+			{
+				UUID:                           aws.String("b"),
+				EventSourceArn:                 aws.String(table),
+				FunctionArn:                    aws.String("arn:aws:lambda:us-west-2:123456789012:function:testfn"),
+				BatchSize:                      aws.Int64(100),
+				MaximumBatchingWindowInSeconds: aws.Int64(0),
+				ParallelizationFactor:          aws.Int64(1),
+				BisectBatchOnFunctionError:     aws.Bool(false),
+				TumblingWindowInSeconds:        aws.Int64(0),
+				MaximumRetryAttempts:           aws.Int64(-1),
+				MaximumRecordAgeInSeconds:      aws.Int64(-1),
+			},
+		},
+	}
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      service,
+		Log:          log.Log,
+	}
+
+	fn.EventSources = []*EventSource{
+		{ARN: stream, BatchingWindow: 5, ParallelizationFactor: 4, BisectOnError: true},
+		{ARN: table, TumblingWindow: 60, MaxRetries: aws.Int64(2), ReportFailures: true},
+		{ARN: queue, BatchSize: 50, BatchingWindow: 10, Filters: []map[string]interface{}{{"body": map[string]interface{}{"type": []interface{}{"order"}}}}},
+	}
+
+	assert.Nil(t, fn.validateEventSources())
+	assert.Nil(t, fn.DeployEventSources())
+
+	assert.Len(t, service.updated, 1)
+	u := service.updated[0]
+	assert.Equal(t, "b", *u.UUID)
+	assert.Equal(t, "testfn:current", *u.FunctionName)
+	assert.Equal(t, int64(60), *u.TumblingWindowInSeconds)
+	assert.Equal(t, int64(2), *u.MaximumRetryAttempts)
+	assert.Equal(t, []string{"ReportBatchItemFailures"}, aws.StringValueSlice(u.FunctionResponseTypes))
+	assert.Equal(t, 0, len(u.FilterCriteria.Filters))
+
+	assert.Len(t, service.created, 1)
+	c := service.created[0]
+	assert.Equal(t, queue, *c.EventSourceArn)
+	assert.Equal(t, "testfn:current", *c.FunctionName)
+	assert.Equal(t, int64(50), *c.BatchSize)
+	assert.Equal(t, int64(10), *c.MaximumBatchingWindowInSeconds)
+	assert.Nil(t, c.StartingPosition)
+	assert.Nil(t, c.ParallelizationFactor)
+	assert.Equal(t, `{"body":{"type":["order"]}}`, *c.FilterCriteria.Filters[0].Pattern)
+
+	fn.EventSources = []*EventSource{{ARN: queue, BisectOnError: true, StartingPosition: "LATEST"}}
+	assert.EqualError(t, fn.validateEventSources(), `EventSources: "`+queue+`" startingPosition, bisectOnError only apply to streams`)
+
+	fn.EventSources = []*EventSource{{ARN: queue, BatchSize: 100}}
+	assert.EqualError(t, fn.validateEventSources(), `EventSources: "`+queue+`" batchSize over 10 requires a batchingWindow`)
+
+	fn.EventSources = []*EventSource{{ARN: stream, ParallelizationFactor: 11}}
+	assert.EqualError(t, fn.validateEventSources(), `EventSources: "`+stream+`" parallelizationFactor must be between 1 and 10`)
+
+	fn.EventSources = []*EventSource{{ARN: "arn:aws:sns:us-west-2:123456789012:topic"}}
+	assert.EqualError(t, fn.validateEventSources(), `EventSources: unsupported sns source "arn:aws:sns:us-west-2:123456789012:topic", must be a Kinesis stream, DynamoDB stream, SQS queue or Kafka cluster`)
+}
+
+func TestEventSource_validateFilters(t *testing.T) {
+	queue := &EventSource{ARN: "arn:aws:sqs:us-west-2:123456789012:jobs"}
+	stream := &EventSource{ARN: "arn:aws:kinesis:us-west-2:123456789012:stream/clicks"}
+
+	filter := func(s string) map[string]interface{} {
+		var m map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(s), &m))
+		return m
+	}
+
+	queue.Filters = []map[string]interface{}{
+		filter(`{"body": {"type": ["order"], "total": [{"numeric": [">", 100]}]}}`),
+		filter(`{"messageAttributes": {"priority": {"stringValue": ["high"]}}}`),
+	}
+	assert.Nil(t, queue.validateFilters())
+
+	stream.Filters = []map[string]interface{}{filter(`{"data": {"type": [{"prefix": "click"}]}, "partitionKey": ["a"]}`)}
+	assert.Nil(t, stream.validateFilters())
+
+	stream.Filters = []map[string]interface{}{filter(`{"body": {"type": ["order"]}}`)}
+	assert.EqualError(t, stream.validateFilters(), `filter 1 field "body" is not one of approximateArrivalTimestamp, data, encryptionType, kinesisSchemaVersion, partitionKey, sequenceNumber`)
+
+	queue.Filters = []map[string]interface{}{filter(`{"body": {"type": "order"}}`)}
+	assert.EqualError(t, queue.validateFilters(), `filter 1 field "body.type" must be a list of values, such as ["order"]`)
+
+	queue.Filters = []map[string]interface{}{filter(`{"body": {"type": ["order"]}}`), filter(`{}`)}
+	assert.EqualError(t, queue.validateFilters(), `filter 2 is empty`)
+
+	queue.Filters = make([]map[string]interface{}, 6)
+	assert.EqualError(t, queue.validateFilters(), `has 6 filters, the maximum is 5`)
+}
+
+func TestFunction_DeployEventSources_kafka(t *testing.T) {
+	cluster := "arn:aws:kafka:us-west-2:123456789012:cluster/events/abc"
+	secret := "arn:aws:secretsmanager:us-west-2:123456789012:secret:kafka"
+
+	service := &sourcesService{
+		mappings: []*lambda.EventSourceMappingConfiguration{
+			{
+				UUID:                           aws.String("a"),
+				EventSourceArn:                 aws.String(cluster),
+				Topics:                         aws.StringSlice([]string{"orders"}),
+				FunctionArn:                    aws.String("arn:aws:lambda:us-west-2:123456789012:function:testfn:current"),
+				MaximumBatchingWindowInSeconds: aws.Int64(0),
+				AmazonManagedKafkaEventSourceConfig: &lambda.AmazonManagedKafkaEventSourceConfig{
+					ConsumerGroupId: aws.String("orders"),
+				},
+			},
+		},
+	}
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      service,
+		Log:          log.Log,
+	}
+
+	fn.EventSources = []*EventSource{
+		{ARN: cluster, Topic: "orders", ConsumerGroup: "orders"},
+		{ARN: cluster, Topic: "refunds", Auth: "scram-512", Secret: secret},
+		{Brokers: []string{"kafka1:9092", "kafka2:9092"}, Topic: "orders", ConsumerGroup: "apex", Auth: "basic", Secret: secret, StartingPosition: "TRIM_HORIZON"},
+	}
+
+	assert.Nil(t, fn.validateEventSources())
+	assert.Nil(t, fn.DeployEventSources())
+	assert.Len(t, service.updated, 0)
+	assert.Len(t, service.created, 2)
+
+	c := service.created[0]
+	assert.Equal(t, cluster, *c.EventSourceArn)
+	assert.Equal(t, []string{"refunds"}, aws.StringValueSlice(c.Topics))
+	assert.Equal(t, "LATEST", *c.StartingPosition)
+	assert.Equal(t, "SASL_SCRAM_512_AUTH", *c.SourceAccessConfigurations[0].Type)
+	assert.Nil(t, c.FunctionResponseTypes)
+
+	c = service.created[1]
+	assert.Nil(t, c.EventSourceArn)
+	assert.Equal(t, []string{"kafka1:9092", "kafka2:9092"}, aws.StringValueSlice(c.SelfManagedEventSource.Endpoints["KAFKA_BOOTSTRAP_SERVERS"]))
+	assert.Equal(t, "apex", *c.SelfManagedKafkaEventSourceConfig.ConsumerGroupId)
+	assert.Equal(t, "BASIC_AUTH", *c.SourceAccessConfigurations[0].Type)
+	assert.Equal(t, secret, *c.SourceAccessConfigurations[0].URI)
+	assert.Equal(t, "TRIM_HORIZON", *c.StartingPosition)
+
+	fn.EventSources = []*EventSource{{ARN: cluster, Topic: "orders", Auth: "basic", Secret: secret}}
+	assert.EqualError(t, fn.validateEventSources(), `EventSources: "`+cluster+` orders" auth "basic" is not supported by MSK, must be scram-512 or tls`)
+
+	fn.EventSources = []*EventSource{{Brokers: []string{"kafka1:9092"}, Topic: "orders", Auth: "scram-256"}}
+	assert.EqualError(t, fn.validateEventSources(), `EventSources: "kafka1:9092 orders" auth "scram-256" requires a secret`)
+
+	fn.EventSources = []*EventSource{{Brokers: []string{"kafka1:9092"}, Topic: "orders", BisectOnError: true}}
+	assert.EqualError(t, fn.validateEventSources(), `EventSources: "kafka1:9092 orders" bisectOnError only apply to streams`)
+
+	fn.EventSources = []*EventSource{{ARN: "arn:aws:sqs:us-west-2:123456789012:jobs", Topic: "orders"}}
+	assert.EqualError(t, fn.validateEventSources(), `EventSources: "arn:aws:sqs:us-west-2:123456789012:jobs orders" topic only apply to Kafka`)
+}
+
+type edgeService struct {
+	lambdaiface.LambdaAPI
+	version string
+}
+
+func (s *edgeService) GetAlias(in *lambda.GetAliasInput) (*lambda.AliasConfiguration, error) {
+	return &lambda.AliasConfiguration{
+		AliasArn:        aws.String("arn:aws:lambda:us-east-1:123456789012:function:testfn:current"),
+		FunctionVersion: aws.String(s.version),
+	}, nil
+}
+
+type distributionService struct {
+	cloudfrontiface.CloudFrontAPI
+	config  *cloudfront.DistributionConfig
+	updates int
+	code    []byte
+	calls   []string
+}
+
+func (s *distributionService) GetDistributionConfig(in *cloudfront.GetDistributionConfigInput) (*cloudfront.GetDistributionConfigOutput, error) {
+	return &cloudfront.GetDistributionConfigOutput{ETag: aws.String("E1"), DistributionConfig: s.config}, nil
+}
+
+func (s *distributionService) DescribeFunction(in *cloudfront.DescribeFunctionInput) (*cloudfront.DescribeFunctionOutput, error) {
+	if s.code == nil {
+		return nil, awserr.New(cloudfront.ErrCodeNoSuchFunctionExists, "not found", nil)
+	}
+
+	return &cloudfront.DescribeFunctionOutput{
+		ETag: aws.String(aws.StringValue(in.Stage) + "-etag"),
+		FunctionSummary: &cloudfront.FunctionSummary{
+			FunctionConfig:   &cloudfront.FunctionConfig{Runtime: aws.String("cloudfront-js-2.0")},
+			FunctionMetadata: &cloudfront.FunctionMetadata{FunctionARN: aws.String("arn:aws:cloudfront::123456789012:function/" + *in.Name)},
+		},
+	}, nil
+}
+
+func (s *distributionService) GetFunction(in *cloudfront.GetFunctionInput) (*cloudfront.GetFunctionOutput, error) {
+	return &cloudfront.GetFunctionOutput{FunctionCode: s.code}, nil
+}
+
+func (s *distributionService) CreateFunction(in *cloudfront.CreateFunctionInput) (*cloudfront.CreateFunctionOutput, error) {
+	s.calls = append(s.calls, "create")
+	s.code = in.FunctionCode
+	return &cloudfront.CreateFunctionOutput{}, nil
+}
+
+func (s *distributionService) UpdateFunction(in *cloudfront.UpdateFunctionInput) (*cloudfront.UpdateFunctionOutput, error) {
+	s.calls = append(s.calls, "update "+*in.IfMatch)
+	s.code = in.FunctionCode
+	return &cloudfront.UpdateFunctionOutput{}, nil
+}
+
+func (s *distributionService) PublishFunction(in *cloudfront.PublishFunctionInput) (*cloudfront.PublishFunctionOutput, error) {
+	s.calls = append(s.calls, "publish "+*in.IfMatch)
+	return &cloudfront.PublishFunctionOutput{
+		FunctionSummary: &cloudfront.FunctionSummary{
+			FunctionMetadata: &cloudfront.FunctionMetadata{FunctionARN: aws.String("arn:aws:cloudfront::123456789012:function/" + *in.Name)},
+		},
+	}, nil
+}
+
+func (s *distributionService) UpdateDistribution(in *cloudfront.UpdateDistributionInput) (*cloudfront.UpdateDistributionOutput, error) {
+	s.updates++
+	s.config = in.DistributionConfig
+	return &cloudfront.UpdateDistributionOutput{}, nil
+}
+
+func TestFunction_DeployEdge(t *testing.T) {
+	cdn := &distributionService{
+		config: &cloudfront.DistributionConfig{
+			DefaultCacheBehavior: &cloudfront.DefaultCacheBehavior{},
+			CacheBehaviors: &cloudfront.CacheBehaviors{
+				Items: []*cloudfront.CacheBehavior{{PathPattern: aws.String("/api/*")}},
+			},
+		},
+	}
+
+	service := &edgeService{version: "3"}
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      service,
+		CloudFront:   cdn,
+		Log:          log.Log,
+	}
+
+	fn.Edge = &Edge{Distribution: "E2QWRUHAPOMQZL", PathPattern: "/api/*", EventType: "origin-request"}
+
+	assert.Nil(t, fn.DeployEdge())
+	assert.Nil(t, fn.DeployEdge())
+	assert.Equal(t, 1, cdn.updates)
+
+	assoc := cdn.config.CacheBehaviors.Items[0].LambdaFunctionAssociations
+	assert.Equal(t, int64(1), *assoc.Quantity)
+	assert.Equal(t, "arn:aws:lambda:us-east-1:123456789012:function:testfn:3", *assoc.Items[0].LambdaFunctionARN)
+	assert.Nil(t, cdn.config.DefaultCacheBehavior.LambdaFunctionAssociations)
+
+	service.version = "4"
+	assert.Nil(t, fn.DeployEdge())
+	assert.Equal(t, 2, cdn.updates)
+	assert.Equal(t, "arn:aws:lambda:us-east-1:123456789012:function:testfn:4", *assoc.Items[0].LambdaFunctionARN)
+
+	assoc.Items[0].LambdaFunctionARN = aws.String("arn:aws:lambda:us-east-1:123456789012:function:other:1")
+	assert.EqualError(t, fn.DeployEdge(), "origin-request of distribution E2QWRUHAPOMQZL is associated with arn:aws:lambda:us-east-1:123456789012:function:other:1")
+
+	assoc.Items[0].LambdaFunctionARN = aws.String("arn:aws:lambda:us-east-1:123456789012:function:testfn:4")
+	assert.Nil(t, fn.removeEdge("arn:aws:lambda:us-east-1:123456789012:function:testfn"))
+	assert.Equal(t, int64(0), *cdn.config.CacheBehaviors.Items[0].LambdaFunctionAssociations.Quantity)
+
+	fn.Edge.PathPattern = "/static/*"
+	assert.EqualError(t, fn.DeployEdge(), `distribution E2QWRUHAPOMQZL has no "/static/*" cache behavior`)
+}
+
+func TestFunction_validateEdge(t *testing.T) {
+	fn := &Function{Config: Config{Memory: 128, Timeout: 5}}
+	fn.Edge = &Edge{Distribution: "E2QWRUHAPOMQZL", EventType: "viewer-request"}
+	assert.Nil(t, fn.validateEdge())
+
+	fn.Timeout = 10
+	assert.EqualError(t, fn.validateEdge(), "Edge: timeout of 10s exceeds the 5s limit of viewer events")
+
+	fn.Edge.EventType = "origin-request"
+	assert.Nil(t, fn.validateEdge())
+
+	fn.EnvMode = EnvNative
+	assert.EqualError(t, fn.validateEdge(), `Edge: Lambda@Edge does not support environment variables, use the "file" envMode`)
+
+	fn.Region = "us-gov-west-1"
+	assert.EqualError(t, fn.validateEdge(), "Edge: Lambda@Edge is not available in the aws-us-gov partition")
+	fn.Region = ""
+
+	fn.EnvMode = ""
+	fn.Architecture = Arm64
+	assert.EqualError(t, fn.validateEdge(), "Edge: Lambda@Edge only supports the x86_64 architecture")
+
+	fn.Edge.EventType = "viewer"
+	assert.EqualError(t, fn.validateEdge(), `Edge: invalid eventType "viewer", must be one of viewer-request, viewer-response, origin-request, origin-response`)
+}
+
+func TestFunction_DeployCloudFrontFunction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex-cff")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	code := []byte("function handler(event) { return event.request }")
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "index.js"), code, 0644))
+
+	cdn := &distributionService{
+		config: &cloudfront.DistributionConfig{DefaultCacheBehavior: &cloudfront.DefaultCacheBehavior{}},
+	}
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Path:         dir,
+		CloudFront:   cdn,
+		Log:          log.Log,
+	}
+
+	fn.CloudFrontFunction = &CloudFrontFunction{Distribution: "E2QWRUHAPOMQZL", EventType: "viewer-request"}
+	assert.Nil(t, fn.validateCloudFrontFunction())
+
+	assert.Nil(t, fn.DeployCloudFrontFunction())
+	assert.Equal(t, []string{"create", "publish DEVELOPMENT-etag"}, cdn.calls)
+	assert.Equal(t, 1, cdn.updates)
+
+	assoc := cdn.config.DefaultCacheBehavior.FunctionAssociations
+	assert.Equal(t, int64(1), *assoc.Quantity)
+	assert.Equal(t, "arn:aws:cloudfront::123456789012:function/testfn", *assoc.Items[0].FunctionARN)
+
+	assert.Nil(t, fn.DeployCloudFrontFunction())
+	assert.Equal(t, []string{"create", "publish DEVELOPMENT-etag"}, cdn.calls)
+	assert.Equal(t, 1, cdn.updates)
+
+	cdn.code = []byte("function handler(event) { return event.response }")
+	assert.Nil(t, fn.DeployCloudFrontFunction())
+	assert.Equal(t, []string{"create", "publish DEVELOPMENT-etag", "update DEVELOPMENT-etag", "publish DEVELOPMENT-etag"}, cdn.calls)
+	assert.Equal(t, code, cdn.code)
+
+	fn.CloudFrontFunction.EventType = "origin-request"
+	assert.EqualError(t, fn.validateCloudFrontFunction(), `CloudFrontFunction: invalid eventType "origin-request", must be one of viewer-request, viewer-response`)
+
+	fn.CloudFrontFunction.EventType = "viewer-response"
+	fn.CloudFrontFunction.File = "missing.js"
+	assert.Contains(t, fn.validateCloudFrontFunction().Error(), "CloudFrontFunction: open ")
+}
+
+type graphQLService struct {
+	appsynciface.AppSyncAPI
+	source    *appsync.DataSource
+	resolvers map[string]*appsync.Resolver
+	calls     []string
+}
+
+func (s *graphQLService) GetDataSource(in *appsync.GetDataSourceInput) (*appsync.GetDataSourceOutput, error) {
+	if s.source == nil {
+		return nil, awserr.New(appsync.ErrCodeNotFoundException, "not found", nil)
+	}
+	return &appsync.GetDataSourceOutput{DataSource: s.source}, nil
+}
+
+func (s *graphQLService) CreateDataSource(in *appsync.CreateDataSourceInput) (*appsync.CreateDataSourceOutput, error) {
+	s.calls = append(s.calls, "create "+*in.Name)
+	s.source = &appsync.DataSource{Name: in.Name, ServiceRoleArn: in.ServiceRoleArn, LambdaConfig: in.LambdaConfig}
+	return &appsync.CreateDataSourceOutput{}, nil
+}
+
+func (s *graphQLService) GetResolver(in *appsync.GetResolverInput) (*appsync.GetResolverOutput, error) {
+	r, ok := s.resolvers[*in.TypeName+"."+*in.FieldName]
+	if !ok {
+		return nil, awserr.New(appsync.ErrCodeNotFoundException, "not found", nil)
+	}
+	return &appsync.GetResolverOutput{Resolver: r}, nil
+}
+
+func (s *graphQLService) CreateResolver(in *appsync.CreateResolverInput) (*appsync.CreateResolverOutput, error) {
+	name := *in.TypeName + "." + *in.FieldName
+	s.calls = append(s.calls, "create "+name)
+	s.resolvers[name] = &appsync.Resolver{
+		DataSourceName:          in.DataSourceName,
+		Kind:                    in.Kind,
+		MaxBatchSize:            aws.Int64(aws.Int64Value(in.MaxBatchSize)),
+		RequestMappingTemplate:  in.RequestMappingTemplate,
+		ResponseMappingTemplate: in.ResponseMappingTemplate,
+	}
+	return &appsync.CreateResolverOutput{}, nil
+}
+
+func (s *graphQLService) UpdateResolver(in *appsync.UpdateResolverInput) (*appsync.UpdateResolverOutput, error) {
+	name := *in.TypeName + "." + *in.FieldName
+	s.calls = append(s.calls, "update "+name)
+	s.resolvers[name].MaxBatchSize = in.MaxBatchSize
+	return &appsync.UpdateResolverOutput{}, nil
+}
+
+type roleService struct {
+	iamiface.IAMAPI
+	policies map[string]string
+}
+
+func (s *roleService) GetRole(in *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+	if s.policies == nil {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, "not found", nil)
+	}
+	return &iam.GetRoleOutput{Role: &iam.Role{Arn: aws.String("arn:aws:iam::123456789012:role/" + *in.RoleName)}}, nil
+}
+
+func (s *roleService) CreateRole(in *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	s.policies = map[string]string{}
+	return &iam.CreateRoleOutput{Role: &iam.Role{Arn: aws.String("arn:aws:iam::123456789012:role/" + *in.RoleName)}}, nil
+}
+
+func (s *roleService) PutRolePolicy(in *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+	s.policies[*in.PolicyName] = *in.PolicyDocument
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+func TestFunction_DeployGraphQL(t *testing.T) {
+	api := &graphQLService{resolvers: map[string]*appsync.Resolver{}}
+	roles := &roleService{}
+
+	fn := &Function{
+		FunctionName: "user-api",
+		Service:      &edgeService{version: "3"},
+		AppSync:      api,
+		IAM:          roles,
+		Log:          log.Log,
+	}
+
+	fn.GraphQL = &GraphQL{
+		API: "abc123",
+		Resolvers: []*GraphQLResolver{
+			{Type: "Query", Field: "user"},
+			{Type: "User", Field: "friends", MaxBatchSize: 10},
+		},
+	}
+
+	assert.Nil(t, fn.validateGraphQL())
+	assert.Nil(t, fn.DeployGraphQL())
+	assert.Equal(t, []string{"create user_api", "create Query.user", "create User.friends"}, api.calls)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/apex-appsync-user-api", *api.source.ServiceRoleArn)
+	assert.Equal(t, "arn:aws:lambda:us-east-1:123456789012:function:testfn:current", *api.source.LambdaConfig.LambdaFunctionArn)
+	assert.Contains(t, roles.policies["invoke"], `"Resource":["arn:aws:lambda:us-east-1:123456789012:function:testfn:current"]`)
+
+	fn.GraphQL.Resolvers[1].MaxBatchSize = 20
+	assert.Nil(t, fn.DeployGraphQL())
+	assert.Equal(t, []string{"create user_api", "create Query.user", "create User.friends", "update User.friends"}, api.calls)
+}
+
+func TestFunction_validateGraphQL(t *testing.T) {
+	fn := &Function{Config: Config{GraphQL: &GraphQL{}}, FunctionName: "user-api"}
+	assert.EqualError(t, fn.validateGraphQL(), "GraphQL: api is required")
+
+	fn.GraphQL.API = "abc123"
+	fn.GraphQL.Resolvers = []*GraphQLResolver{{Type: "Query", Field: "user"}, {Type: "Query", Field: "user"}}
+	assert.EqualError(t, fn.validateGraphQL(), "GraphQL: duplicate resolver Query.user")
+
+	fn.GraphQL.Resolvers = []*GraphQLResolver{{Type: "Query", Field: "user-by-id"}}
+	assert.EqualError(t, fn.validateGraphQL(), "GraphQL: invalid resolver Query.user-by-id")
+
+	fn.GraphQL.Resolvers = []*GraphQLResolver{{Type: "Query", Field: "user", Request: "request.vtl"}}
+	assert.EqualError(t, fn.validateGraphQL(), "GraphQL: resolver Query.user requires both request and response templates")
+
+	fn.GraphQL.Resolvers = nil
+	fn.GraphQL.DataSource = "user-api"
+	assert.EqualError(t, fn.validateGraphQL(), `GraphQL: invalid dataSource "user-api"`)
+}
+
+type tableService struct {
+	dynamodbiface.DynamoDBAPI
+	tables map[string]*dynamodb.TableDescription
+	ttl    map[string]string
+	calls  []string
+}
+
+func (s *tableService) DescribeTable(in *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	t, ok := s.tables[*in.TableName]
+	if !ok {
+		return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	return &dynamodb.DescribeTableOutput{Table: t}, nil
+}
+
+func (s *tableService) CreateTable(in *dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error) {
+	s.calls = append(s.calls, "create "+*in.TableName)
+	t := &dynamodb.TableDescription{
+		TableArn:              aws.String("arn:aws:dynamodb:us-west-2:123456789012:table/" + *in.TableName),
+		KeySchema:             in.KeySchema,
+		BillingModeSummary:    &dynamodb.BillingModeSummary{BillingMode: in.BillingMode},
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughputDescription{ReadCapacityUnits: aws.Int64(0), WriteCapacityUnits: aws.Int64(0)},
+	}
+	s.tables[*in.TableName] = t
+	return &dynamodb.CreateTableOutput{TableDescription: t}, nil
+}
+
+func (s *tableService) WaitUntilTableExistsWithContext(ctx aws.Context, in *dynamodb.DescribeTableInput, opts ...request.WaiterOption) error {
+	return nil
+}
+
+func (s *tableService) UpdateTable(in *dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error) {
+	s.calls = append(s.calls, "update "+*in.TableName)
+	t := s.tables[*in.TableName]
+	if in.BillingMode != nil {
+		t.BillingModeSummary.BillingMode = in.BillingMode
+	}
+	if p := in.ProvisionedThroughput; p != nil {
+		t.ProvisionedThroughput = &dynamodb.ProvisionedThroughputDescription{ReadCapacityUnits: p.ReadCapacityUnits, WriteCapacityUnits: p.WriteCapacityUnits}
+	}
+	return &dynamodb.UpdateTableOutput{}, nil
+}
+
+func (s *tableService) DescribeTimeToLive(in *dynamodb.DescribeTimeToLiveInput) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	d := &dynamodb.TimeToLiveDescription{TimeToLiveStatus: aws.String(dynamodb.TimeToLiveStatusDisabled)}
+	if name, ok := s.ttl[*in.TableName]; ok {
+		d = &dynamodb.TimeToLiveDescription{AttributeName: &name, TimeToLiveStatus: aws.String(dynamodb.TimeToLiveStatusEnabled)}
+	}
+	return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: d}, nil
+}
+
+func (s *tableService) UpdateTimeToLive(in *dynamodb.UpdateTimeToLiveInput) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	spec := in.TimeToLiveSpecification
+	s.calls = append(s.calls, "ttl "+*in.TableName)
+	if *spec.Enabled {
+		s.ttl[*in.TableName] = *spec.AttributeName
+	} else {
+		delete(s.ttl, *in.TableName)
+	}
+	return &dynamodb.UpdateTimeToLiveOutput{}, nil
+}
+
+func TestFunction_DeployTables(t *testing.T) {
+	db := &tableService{tables: map[string]*dynamodb.TableDescription{}, ttl: map[string]string{}}
+	roles := &roleService{policies: map[string]string{}}
+
+	fn := &Function{
+		Config:       Config{Role: "arn:aws:iam::123456789012:role/lambda_function"},
+		FunctionName: "testfn",
+		DynamoDB:     db,
+		IAM:          roles,
+		Log:          log.Log,
+	}
+
+	fn.Tables = []*Table{{
+		Name:         "users",
+		PartitionKey: &TableKey{Name: "id"},
+		TTL:          "expires",
+	}}
+
+	assert.Nil(t, fn.validateTables())
+	assert.Nil(t, fn.DeployTables())
+	assert.Nil(t, fn.DeployTables())
+	assert.Equal(t, []string{"create users", "ttl users"}, db.calls)
+	assert.Equal(t, "expires", db.ttl["users"])
+	assert.Contains(t, roles.policies["apex-tables-testfn"], `"Resource":["arn:aws:dynamodb:us-west-2:123456789012:table/users","arn:aws:dynamodb:us-west-2:123456789012:table/users/index/*"]`)
+
+	fn.Tables[0].BillingMode = "PROVISIONED"
+	fn.Tables[0].ReadCapacity = 5
+	fn.Tables[0].WriteCapacity = 5
+	fn.Tables[0].TTL = ""
+	assert.Nil(t, fn.DeployTables())
+	assert.Nil(t, fn.DeployTables())
+	assert.Equal(t, []string{"create users", "ttl users", "update users", "ttl users"}, db.calls)
+	assert.Equal(t, int64(5), *db.tables["users"].ProvisionedThroughput.ReadCapacityUnits)
+
+	fn.Tables[0].SortKey = &TableKey{Name: "created", Type: "N"}
+	assert.EqualError(t, fn.DeployTables(), "table users keys differ from its partitionKey and sortKey, the keys of existing tables cannot be changed")
+
+	fn.Tables[0].SortKey = nil
+	db.ttl["users"] = "expires"
+	fn.Tables[0].TTL = "expiresAt"
+	assert.EqualError(t, fn.DeployTables(), "table users ttl is enabled on expires, remove the ttl and deploy before changing its attribute to expiresAt")
+
+	vars, err := fn.environment()
+	assert.Nil(t, err)
+	assert.Equal(t, "users", vars["TABLE_USERS"])
+
+	other := &Function{
+		Config:       Config{Role: fn.Role},
+		FunctionName: "otherfn",
+		DynamoDB:     db,
+		IAM:          roles,
+		Log:          log.Log,
+	}
+
+	other.Tables = []*Table{{Name: "orders", PartitionKey: &TableKey{Name: "id"}}}
+
+	assert.Nil(t, other.DeployTables())
+	assert.Contains(t, roles.policies["apex-tables-otherfn"], `"Resource":["arn:aws:dynamodb:us-west-2:123456789012:table/orders","arn:aws:dynamodb:us-west-2:123456789012:table/orders/index/*"]`)
+	assert.Contains(t, roles.policies["apex-tables-testfn"], "table/users")
+}
+
+func TestFunction_validateTables(t *testing.T) {
+	fn := &Function{}
+
+	fn.Tables = []*Table{{Name: "user-events", PartitionKey: &TableKey{Name: "id"}}, {Name: "user.events", PartitionKey: &TableKey{Name: "id"}}}
+	assert.EqualError(t, fn.validateTables(), "Tables: user.events env TABLE_USER_EVENTS is used by another table")
+
+	fn.Tables = []*Table{{Name: "users"}}
+	assert.EqualError(t, fn.validateTables(), "Tables: users partitionKey is required")
+
+	fn.Tables = []*Table{{Name: "users", PartitionKey: &TableKey{Name: "id", Type: "SS"}}}
+	assert.EqualError(t, fn.validateTables(), `Tables: users key id has invalid type "SS", must be one of S, N, B`)
+
+	fn.Tables = []*Table{{Name: "users", PartitionKey: &TableKey{Name: "id"}, BillingMode: "PROVISIONED"}}
+	assert.EqualError(t, fn.validateTables(), "Tables: users readCapacity and writeCapacity are required for PROVISIONED tables")
+
+	fn.Tables = []*Table{{Name: "users", PartitionKey: &TableKey{Name: "id"}, ReadCapacity: 5}}
+	assert.EqualError(t, fn.validateTables(), "Tables: users readCapacity and writeCapacity only apply to PROVISIONED tables")
+}
+
+type eventRules struct {
+	eventbridgeiface.EventBridgeAPI
+	rules map[string]map[string]string
+}
+
+func (e *eventRules) PutRule(in *eventbridge.PutRuleInput) (*eventbridge.PutRuleOutput, error) {
+	if e.rules[*in.EventBusName] == nil {
+		e.rules[*in.EventBusName] = make(map[string]string)
+	}
+	e.rules[*in.EventBusName][*in.Name] = ""
+	return &eventbridge.PutRuleOutput{RuleArn: aws.String("arn:aws:events:us-west-2:123:rule/" + *in.Name)}, nil
+}
+
+func (e *eventRules) PutTargets(in *eventbridge.PutTargetsInput) (*eventbridge.PutTargetsOutput, error) {
+	e.rules[*in.EventBusName][*in.Rule] = *in.Targets[0].Arn
+	return &eventbridge.PutTargetsOutput{}, nil
+}
+
+// ListEventBuses returns a bus per page.
+func (e *eventRules) ListEventBuses(in *eventbridge.ListEventBusesInput) (*eventbridge.ListEventBusesOutput, error) {
+	var names []string
+	for name := range e.rules {
+		names = append(names, name)
+	}
+
+	name, next := onePage(names, in.NextToken)
+	res := &eventbridge.ListEventBusesOutput{NextToken: next}
+	if name != nil {
+		res.EventBuses = []*eventbridge.EventBus{{Name: name}}
+	}
+	return res, nil
+}
+
+// ListRuleNamesByTarget returns a rule per page.
+func (e *eventRules) ListRuleNamesByTarget(in *eventbridge.ListRuleNamesByTargetInput) (*eventbridge.ListRuleNamesByTargetOutput, error) {
+	var names []string
+	for name, target := range e.rules[*in.EventBusName] {
+		if target == *in.TargetArn {
+			names = append(names, name)
+		}
+	}
+
+	name, next := onePage(names, in.NextToken)
+	res := &eventbridge.ListRuleNamesByTargetOutput{NextToken: next}
+	if name != nil {
+		res.RuleNames = []*string{name}
+	}
+	return res, nil
+}
+
+// onePage returns the page of `names` at `token`, sorted one per page,
+// and the token of the next page.
+func onePage(names []string, token *string) (name, next *string) {
+	sort.Strings(names)
+
+	i := 0
+	if token != nil {
+		i, _ = strconv.Atoi(*token)
+	}
+
+	if i < len(names) {
+		name = aws.String(names[i])
+	}
+
+	if i+1 < len(names) {
+		next = aws.String(strconv.Itoa(i + 1))
+	}
+
+	return name, next
+}
+
+func (e *eventRules) ListTargetsByRule(in *eventbridge.ListTargetsByRuleInput) (*eventbridge.ListTargetsByRuleOutput, error) {
+	res := &eventbridge.ListTargetsByRuleOutput{}
+	if arn := e.rules[*in.EventBusName][*in.Rule]; arn != "" {
+		res.Targets = []*eventbridge.Target{{Id: aws.String("apex"), Arn: &arn}}
+	}
+	return res, nil
+}
+
+func (e *eventRules) RemoveTargets(in *eventbridge.RemoveTargetsInput) (*eventbridge.RemoveTargetsOutput, error) {
+	e.rules[*in.EventBusName][*in.Rule] = ""
+	return &eventbridge.RemoveTargetsOutput{}, nil
+}
+
+func (e *eventRules) DeleteRule(in *eventbridge.DeleteRuleInput) (*eventbridge.DeleteRuleOutput, error) {
+	delete(e.rules[*in.EventBusName], *in.Name)
+	return &eventbridge.DeleteRuleOutput{}, nil
+}
+
+type eventService struct {
+	lambdaiface.LambdaAPI
+	removed []string
+}
+
+func (s *eventService) GetAlias(in *lambda.GetAliasInput) (*lambda.AliasConfiguration, error) {
+	return &lambda.AliasConfiguration{AliasArn: aws.String("arn:aws:lambda:us-west-2:123:function:" + *in.FunctionName + ":current")}, nil
+}
+
+func (s *eventService) AddPermission(in *lambda.AddPermissionInput) (*lambda.AddPermissionOutput, error) {
+	return &lambda.AddPermissionOutput{}, nil
+}
+
+func (s *eventService) RemovePermission(in *lambda.RemovePermissionInput) (*lambda.RemovePermissionOutput, error) {
+	s.removed = append(s.removed, *in.StatementId)
+	return &lambda.RemovePermissionOutput{}, nil
+}
+
+func TestFunction_DeployEvents(t *testing.T) {
+	api := "arn:aws:lambda:us-west-2:123:function:api:current"
+	worker := "arn:aws:lambda:us-west-2:123:function:api-worker:current"
+
+	events := &eventRules{rules: map[string]map[string]string{
+		"default": {
+			"apex-api-a":           api,
+			"apex-api-b":           api,
+			"apex-api-worker-jobs": worker,
+			"manual":               api,
+		},
+		"legacy": {
+			"apex-api-legacy": api,
+		},
+	}}
+
+	service := &eventService{}
+
+	fn := &Function{
+		FunctionName: "api",
+		Service:      service,
+		EventBridge:  events,
+		Log:          log.Log,
+		Config: Config{Events: []*EventRule{
+			{Name: "nightly", Schedule: "rate(1 day)"},
+			{Name: "orders", Bus: "orders", Pattern: map[string]interface{}{"source": []string{"shop"}}},
+		}},
+	}
+
+	assert.Nil(t, fn.DeployEvents())
+	assert.Equal(t, map[string]map[string]string{
+		"default": {
+			"apex-api-nightly":     api,
+			"apex-api-worker-jobs": worker,
+			"manual":               api,
+		},
+		"legacy": {},
+		"orders": {
+			"apex-api-orders": api,
+		},
+	}, events.rules)
+
+	sort.Strings(service.removed)
+	assert.Equal(t, []string{"apex-api-a", "apex-api-b", "apex-api-legacy"}, service.removed)
+
+	fn.Events = nil
+	assert.Nil(t, fn.DeployEvents())
+	assert.Equal(t, map[string]string{"apex-api-worker-jobs": worker, "manual": api}, events.rules["default"])
+	assert.Equal(t, map[string]string{}, events.rules["orders"])
+}
+
+func TestFunction_deleteRules(t *testing.T) {
+	api := "arn:aws:lambda:us-west-2:123:function:api:current"
+	worker := "arn:aws:lambda:us-west-2:123:function:api-worker:current"
+
+	events := &eventRules{rules: map[string]map[string]string{
+		"default": {
+			"apex-api-a":           api,
+			"apex-api-b":           api,
+			"apex-api-worker-jobs": worker,
+		},
+		"orders": {
+			"apex-api-orders": api,
+		},
+	}}
+
+	fn := &Function{
+		FunctionName: "api",
+		EventBridge:  events,
+		Log:          log.Log,
+	}
+
+	assert.Nil(t, fn.deleteRules(api))
+	assert.Equal(t, map[string]map[string]string{
+		"default": {"apex-api-worker-jobs": worker},
+		"orders":  {},
+	}, events.rules)
+}
+
+func TestFunction_validateEvents(t *testing.T) {
+	fn := &Function{FunctionName: "api", Config: Config{Events: []*EventRule{{Name: "nightly", Schedule: "rate(1 day)"}}}}
+	assert.Nil(t, fn.validateEvents())
+
+	fn.Events[0].Name = strings.Repeat("n", 56)
+	assert.EqualError(t, fn.validateEvents(), fmt.Sprintf("Events: rule %q name apex-api-%s exceeds 64 characters", fn.Events[0].Name, fn.Events[0].Name))
+
+	fn.Events[0].Name = strings.Repeat("n", 55)
+	assert.Nil(t, fn.validateEvents())
+
+	fn.FunctionName = strings.Repeat("f", 56)
+	fn.Events = nil
+	fn.Warm = 5
+	assert.EqualError(t, fn.validateEvents(), fmt.Sprintf("Warm: rule name apex-%s-warm exceeds 64 characters", fn.FunctionName))
+
+	fn.FunctionName = "api"
+	fn.Events = []*EventRule{{Name: "warm", Schedule: "rate(1 day)"}}
+	assert.EqualError(t, fn.validateEvents(), `Events: rule name "warm" is reserved for keep-warm`)
+}
+
+type queueService struct {
+	sqsiface.SQSAPI
+	queues map[string]map[string]string
+	calls  []string
+}
+
+func (s *queueService) GetQueueUrl(in *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+	if _, ok := s.queues[*in.QueueName]; !ok {
+		return nil, awserr.New(sqs.ErrCodeQueueDoesNotExist, "not found", nil)
+	}
+	return &sqs.GetQueueUrlOutput{QueueUrl: in.QueueName}, nil
+}
+
+func (s *queueService) CreateQueue(in *sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error) {
+	s.calls = append(s.calls, "create "+*in.QueueName)
+	attrs := aws.StringValueMap(in.Attributes)
+	attrs[sqs.QueueAttributeNameQueueArn] = "arn:aws:sqs:us-west-2:123456789012:" + *in.QueueName
+	s.queues[*in.QueueName] = attrs
+	return &sqs.CreateQueueOutput{QueueUrl: in.QueueName}, nil
+}
+
+func (s *queueService) GetQueueAttributes(in *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+	return &sqs.GetQueueAttributesOutput{Attributes: aws.StringMap(s.queues[*in.QueueUrl])}, nil
+}
+
+func (s *queueService) SetQueueAttributes(in *sqs.SetQueueAttributesInput) (*sqs.SetQueueAttributesOutput, error) {
+	s.calls = append(s.calls, "update "+*in.QueueUrl)
+	for k, v := range in.Attributes {
+		s.queues[*in.QueueUrl][k] = *v
+	}
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+func TestFunction_DeployQueue(t *testing.T) {
+	queues := &queueService{queues: map[string]map[string]string{}}
+	roles := &roleService{policies: map[string]string{}}
+
+	fn := &Function{
+		Config:       Config{Role: "arn:aws:iam::123456789012:role/lambda_function", Timeout: 30},
+		FunctionName: "worker",
+		SQS:          queues,
+		IAM:          roles,
+		Log:          log.Log,
+	}
+
+	fn.Queue = &Queue{BatchSize: 5}
+
+	assert.Nil(t, fn.validateQueue())
+	assert.Nil(t, fn.DeployQueue())
+	assert.Nil(t, fn.DeployQueue())
+	assert.Equal(t, []string{"create worker-dlq", "create worker"}, queues.calls)
+	assert.Equal(t, "180", queues.queues["worker"][sqs.QueueAttributeNameVisibilityTimeout])
+	assert.Equal(t, `{"deadLetterTargetArn":"arn:aws:sqs:us-west-2:123456789012:worker-dlq","maxReceiveCount":5}`, queues.queues["worker"][sqs.QueueAttributeNameRedrivePolicy])
+	assert.Equal(t, &EventSource{ARN: "arn:aws:sqs:us-west-2:123456789012:worker", BatchSize: 5}, fn.queueSource)
+	assert.Contains(t, roles.policies["apex-queue-worker"], `"Resource":["arn:aws:sqs:us-west-2:123456789012:worker"]`)
+
+	queues.queues["worker"][sqs.QueueAttributeNameRedrivePolicy] = `{"maxReceiveCount":"5", "deadLetterTargetArn":"arn:aws:sqs:us-west-2:123456789012:worker-dlq"}`
+	assert.Nil(t, fn.DeployQueue())
+	assert.Equal(t, []string{"create worker-dlq", "create worker"}, queues.calls)
+
+	fn.Queue.MaxReceiveCount = 10
+	assert.Nil(t, fn.DeployQueue())
+	assert.Equal(t, []string{"create worker-dlq", "create worker", "update worker"}, queues.calls)
+
+	fn.Queue = &Queue{Name: "jobs", FIFO: true}
+	assert.Nil(t, fn.DeployQueue())
+	assert.Equal(t, "true", queues.queues["jobs.fifo"][sqs.QueueAttributeNameFifoQueue])
+	assert.Equal(t, "true", queues.queues["jobs-dlq.fifo"][sqs.QueueAttributeNameFifoQueue])
+
+	other := &Function{
+		Config:       Config{Role: fn.Role, Timeout: 30, Queue: &Queue{}},
+		FunctionName: "mailer",
+		SQS:          queues,
+		IAM:          roles,
+		Log:          log.Log,
+	}
+
+	assert.Nil(t, other.DeployQueue())
+	assert.Contains(t, roles.policies["apex-queue-mailer"], `"Resource":["arn:aws:sqs:us-west-2:123456789012:mailer"]`)
+	assert.Contains(t, roles.policies["apex-queue-worker"], `"Resource":["arn:aws:sqs:us-west-2:123456789012:jobs.fifo"]`)
+}
+
+func TestFunction_validateQueue(t *testing.T) {
+	fn := &Function{Config: Config{Timeout: 30, Queue: &Queue{VisibilityTimeout: 20}}}
+	assert.EqualError(t, fn.validateQueue(), "Queue: visibilityTimeout of 20s is less than the 30s function timeout")
+
+	fn.Timeout = 10000
+	fn.Queue = &Queue{}
+	assert.Equal(t, int64(MaxVisibilityTimeout), fn.visibilityTimeout())
+	assert.Nil(t, fn.validateQueue())
+
+	fn.Queue = &Queue{Name: "jobs.fifo"}
+	assert.EqualError(t, fn.validateQueue(), `Queue: invalid name "jobs.fifo"`)
+
+	fn.Queue = &Queue{FIFO: true, BatchSize: 20, BatchingWindow: 5}
+	assert.EqualError(t, fn.validateQueue(), "Queue: batchSize must be between 1 and 10000, or 10 for FIFO queues")
+}
+
+type subscriptionLogs struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	filters []*cloudwatchlogs.SubscriptionFilter
+	puts    int
+}
+
+func (l *subscriptionLogs) DescribeSubscriptionFilters(in *cloudwatchlogs.DescribeSubscriptionFiltersInput) (*cloudwatchlogs.DescribeSubscriptionFiltersOutput, error) {
+	return &cloudwatchlogs.DescribeSubscriptionFiltersOutput{SubscriptionFilters: l.filters}, nil
+}
+
+func (l *subscriptionLogs) PutSubscriptionFilter(in *cloudwatchlogs.PutSubscriptionFilterInput) (*cloudwatchlogs.PutSubscriptionFilterOutput, error) {
+	l.puts++
+	l.filters = []*cloudwatchlogs.SubscriptionFilter{{
+		FilterName:     in.FilterName,
+		FilterPattern:  in.FilterPattern,
+		DestinationArn: in.DestinationArn,
+		RoleArn:        in.RoleArn,
+		Distribution:   aws.String(cloudwatchlogs.DistributionByLogStream),
+	}}
+	return &cloudwatchlogs.PutSubscriptionFilterOutput{}, nil
+}
+
+type urlService struct {
+	lambdaiface.LambdaAPI
+	url         *lambda.GetFunctionUrlConfigOutput
+	permissions []*lambda.AddPermissionInput
+	calls       []string
+}
+
+func (s *urlService) GetFunctionUrlConfig(in *lambda.GetFunctionUrlConfigInput) (*lambda.GetFunctionUrlConfigOutput, error) {
+	if s.url == nil {
+		return nil, awserr.New("ResourceNotFoundException", "not found", nil)
+	}
+	return s.url, nil
+}
+
+func (s *urlService) CreateFunctionUrlConfig(in *lambda.CreateFunctionUrlConfigInput) (*lambda.CreateFunctionUrlConfigOutput, error) {
+	s.calls = append(s.calls, "create "+*in.Qualifier+" "+*in.AuthType)
+	s.url = &lambda.GetFunctionUrlConfigOutput{FunctionUrl: aws.String("https://abc.lambda-url.us-west-2.on.aws/"), AuthType: in.AuthType, Cors: in.Cors}
+	return &lambda.CreateFunctionUrlConfigOutput{FunctionUrl: s.url.FunctionUrl}, nil
+}
+
+func (s *urlService) UpdateFunctionUrlConfig(in *lambda.UpdateFunctionUrlConfigInput) (*lambda.UpdateFunctionUrlConfigOutput, error) {
+	s.calls = append(s.calls, "update "+*in.Qualifier+" "+*in.AuthType)
+	s.url.AuthType = in.AuthType
+	s.url.Cors = in.Cors
+	return &lambda.UpdateFunctionUrlConfigOutput{FunctionUrl: s.url.FunctionUrl}, nil
+}
+
+func (s *urlService) DeleteFunctionUrlConfig(in *lambda.DeleteFunctionUrlConfigInput) (*lambda.DeleteFunctionUrlConfigOutput, error) {
+	s.calls = append(s.calls, "delete "+*in.Qualifier)
+	s.url = nil
+	return &lambda.DeleteFunctionUrlConfigOutput{}, nil
+}
+
+func (s *urlService) AddPermission(in *lambda.AddPermissionInput) (*lambda.AddPermissionOutput, error) {
+	for _, p := range s.permissions {
+		if *p.StatementId == *in.StatementId {
+			return nil, awserr.New("ResourceConflictException", "exists", nil)
+		}
+	}
+	s.permissions = append(s.permissions, in)
+	return &lambda.AddPermissionOutput{}, nil
+}
+
+func TestFunction_DeployURL(t *testing.T) {
+	s := &urlService{}
+	fn := &Function{FunctionName: "testfn", Service: s, Log: log.Log}
+
+	assert.Nil(t, fn.DeployURL())
+	assert.Equal(t, []string(nil), s.calls)
+	assert.Equal(t, "", fn.Endpoint())
+
+	fn.URL = &URLConfig{CORS: &CORS{Origins: []string{"https://example.com"}, Methods: []string{"POST"}, MaxAge: 60}}
+	assert.Nil(t, fn.DeployURL())
+	assert.Equal(t, []string{"create current NONE"}, s.calls)
+	assert.Equal(t, "https://abc.lambda-url.us-west-2.on.aws/", fn.Endpoint())
+	assert.Equal(t, "https://example.com", *s.url.Cors.AllowOrigins[0])
+	assert.Equal(t, int64(60), *s.url.Cors.MaxAge)
+	assert.Equal(t, 1, len(s.permissions))
+	assert.Equal(t, "lambda:InvokeFunctionUrl", *s.permissions[0].Action)
+	assert.Equal(t, "*", *s.permissions[0].Principal)
+	assert.Equal(t, URLAuthNone, *s.permissions[0].FunctionUrlAuthType)
+	assert.Equal(t, "current", *s.permissions[0].Qualifier)
+
+	assert.Nil(t, fn.DeployURL())
+	assert.Equal(t, []string{"create current NONE", "update current NONE"}, s.calls)
+	assert.Equal(t, 1, len(s.permissions))
+
+	fn.URL = &URLConfig{Auth: URLAuthIAM}
+	fn.Alias = "live"
+	assert.Nil(t, fn.DeployURL())
+	assert.Equal(t, []string{"create current NONE", "update current NONE", "update live AWS_IAM"}, s.calls)
+	assert.Nil(t, s.url.Cors)
+	assert.Equal(t, 1, len(s.permissions))
+
+	fn.URL = nil
+	assert.Nil(t, fn.DeployURL())
+	assert.Equal(t, "delete live", s.calls[3])
+	assert.Nil(t, s.url)
+}
+
+func TestFunction_validateURL(t *testing.T) {
+	fn := &Function{}
+	assert.Nil(t, fn.validateURL())
+
+	fn.URL = &URLConfig{}
+	assert.Nil(t, fn.validateURL())
+
+	fn.URL.Auth = URLAuthIAM
+	assert.Nil(t, fn.validateURL())
+
+	fn.URL.Auth = "COGNITO"
+	assert.EqualError(t, fn.validateURL(), `URL: invalid auth "COGNITO", must be one of NONE, AWS_IAM`)
+}
+
+type permissionService struct {
+	lambdaiface.LambdaAPI
+	added []*lambda.AddPermissionInput
+}
+
+func (s *permissionService) AddPermission(in *lambda.AddPermissionInput) (*lambda.AddPermissionOutput, error) {
+	s.added = append(s.added, in)
+	return &lambda.AddPermissionOutput{}, nil
+}
+
+func TestFunction_DeployLogSubscription(t *testing.T) {
+	logs := &subscriptionLogs{}
+	service := &permissionService{}
+
+	fn := &Function{
+		FunctionName:   "testfn",
+		Service:        service,
+		CloudWatchLogs: logs,
+		Log:            log.Log,
+	}
+
+	fn.LogSubscription = &LogSubscription{
+		Destination: "arn:aws:firehose:us-west-2:123456789012:deliverystream/logs",
+		Role:        "arn:aws:iam::123456789012:role/cwl-firehose",
+	}
+
+	assert.Nil(t, fn.validateLogSubscription())
+	assert.Nil(t, fn.DeployLogSubscription())
+	assert.Nil(t, fn.DeployLogSubscription())
+	assert.Equal(t, 1, logs.puts)
+	assert.Equal(t, "apex", *logs.filters[0].FilterName)
+	assert.Empty(t, service.added)
+
+	fn.LogSubscription = &LogSubscription{Destination: "arn:aws:lambda:us-west-2:123456789012:function:shipper", Filter: "ERROR"}
+	assert.Nil(t, fn.validateLogSubscription())
+	assert.Nil(t, fn.DeployLogSubscription())
+	assert.Equal(t, 2, logs.puts)
+	assert.Equal(t, "ERROR", *logs.filters[0].FilterPattern)
+	assert.Equal(t, "arn:aws:logs:us-west-2:123456789012:log-group:/aws/lambda/testfn:*", *service.added[0].SourceArn)
+}
+
+func TestFunction_validateLogSubscription(t *testing.T) {
+	fn := &Function{FunctionName: "testfn"}
+
+	fn.LogSubscription = &LogSubscription{Destination: "arn:aws:kinesis:us-west-2:123456789012:stream/logs"}
+	assert.EqualError(t, fn.validateLogSubscription(), "LogSubscription: role is required for kinesis destinations")
+
+	fn.LogSubscription = &LogSubscription{Destination: "arn:aws:lambda:us-west-2:123456789012:function:testfn:current"}
+	assert.EqualError(t, fn.validateLogSubscription(), "LogSubscription: destination must not be the function itself")
+
+	fn.LogSubscription = &LogSubscription{Destination: "arn:aws:lambda:us-west-2:123456789012:function:shipper", Distribution: "Random"}
+	assert.EqualError(t, fn.validateLogSubscription(), "LogSubscription: distribution only applies to Kinesis destinations")
+
+	fn.LogSubscription = &LogSubscription{Destination: "arn:aws:sns:us-west-2:123456789012:logs"}
+	assert.EqualError(t, fn.validateLogSubscription(), `LogSubscription: unsupported sns destination "arn:aws:sns:us-west-2:123456789012:logs", must be a Kinesis stream, Firehose delivery stream, Lambda function or CloudWatch Logs destination`)
+}
+
+func TestFunction_validateLogging(t *testing.T) {
+	fn := &Function{FunctionName: "testfn"}
+	assert.Nil(t, fn.validateLogging())
+	assert.Nil(t, fn.loggingConfig())
+	assert.Equal(t, "/aws/lambda/testfn", fn.LogGroupName())
+
+	fn.Logging = &LoggingConfig{Format: "JSON", ApplicationLevel: "DEBUG", LogGroup: "/org/lambda"}
+	assert.Nil(t, fn.validateLogging())
+	assert.Equal(t, &lambda.LoggingConfig{LogFormat: aws.String("JSON"), ApplicationLogLevel: aws.String("DEBUG"), LogGroup: aws.String("/org/lambda")}, fn.loggingConfig())
+	assert.Equal(t, "/org/lambda", fn.LogGroupName())
+
+	fn.Logging = &LoggingConfig{SystemLevel: "INFO"}
+	assert.EqualError(t, fn.validateLogging(), "Logging: applicationLevel and systemLevel require the JSON format")
+
+	fn.Logging = &LoggingConfig{Format: "json"}
+	assert.EqualError(t, fn.validateLogging(), `Logging: invalid format "json", must be Text or JSON`)
+
+	fn.Logging = &LoggingConfig{Format: "JSON", SystemLevel: "TRACE"}
+	assert.EqualError(t, fn.validateLogging(), `Logging: invalid systemLevel "TRACE", must be one of DEBUG, INFO, WARN`)
+}
+
+type runtimeService struct {
+	lambdaiface.LambdaAPI
+	config *lambda.GetRuntimeManagementConfigOutput
+	puts   int
+}
+
+func (s *runtimeService) GetRuntimeManagementConfig(in *lambda.GetRuntimeManagementConfigInput) (*lambda.GetRuntimeManagementConfigOutput, error) {
+	return s.config, nil
+}
+
+func (s *runtimeService) PutRuntimeManagementConfig(in *lambda.PutRuntimeManagementConfigInput) (*lambda.PutRuntimeManagementConfigOutput, error) {
+	s.puts++
+	s.config = &lambda.GetRuntimeManagementConfigOutput{UpdateRuntimeOn: in.UpdateRuntimeOn, RuntimeVersionArn: in.RuntimeVersionArn}
+	return &lambda.PutRuntimeManagementConfigOutput{}, nil
+}
+
+func TestFunction_deployRuntimeManagement(t *testing.T) {
+	service := &runtimeService{config: &lambda.GetRuntimeManagementConfigOutput{UpdateRuntimeOn: aws.String("Auto")}}
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      service,
+		Log:          log.Log,
+	}
+
+	assert.Nil(t, fn.deployRuntimeManagement())
+	fn.RuntimeManagement = &RuntimeManagement{}
+	assert.Nil(t, fn.validateRuntimeManagement())
+	assert.Nil(t, fn.deployRuntimeManagement())
+	assert.Equal(t, 0, service.puts)
+
+	fn.RuntimeManagement = &RuntimeManagement{UpdateOn: "Manual", VersionArn: "arn:aws:lambda:us-west-2::runtime:0b1d9b6b6e5e"}
+	assert.Nil(t, fn.validateRuntimeManagement())
+	assert.Nil(t, fn.deployRuntimeManagement())
+	assert.Nil(t, fn.deployRuntimeManagement())
+	assert.Equal(t, 1, service.puts)
+	assert.Equal(t, "arn:aws:lambda:us-west-2::runtime:0b1d9b6b6e5e", *service.config.RuntimeVersionArn)
+
+	fn.RuntimeManagement = &RuntimeManagement{UpdateOn: "Manual"}
+	assert.EqualError(t, fn.validateRuntimeManagement(), "RuntimeManagement: the Manual updateOn mode requires a runtime versionArn")
+
+	fn.RuntimeManagement = &RuntimeManagement{UpdateOn: "FunctionUpdate", VersionArn: "arn:aws:lambda:us-west-2::runtime:0b1d9b6b6e5e"}
+	assert.EqualError(t, fn.validateRuntimeManagement(), "RuntimeManagement: versionArn requires the Manual updateOn mode")
+
+	fn.RuntimeManagement = &RuntimeManagement{UpdateOn: "Never"}
+	assert.EqualError(t, fn.validateRuntimeManagement(), `RuntimeManagement: invalid updateOn "Never", must be one of Auto, FunctionUpdate, Manual`)
+}
+
+type retryService struct {
+	lambdaiface.LambdaAPI
+	errs     []error
+	contexts []string
+}
+
+func (s *retryService) Invoke(in *lambda.InvokeInput) (*lambda.InvokeOutput, error) {
+	s.contexts = append(s.contexts, *in.ClientContext)
+
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return nil, err
+	}
+
+	return &lambda.InvokeOutput{Payload: []byte("ok")}, nil
+}
+
+func TestFunction_InvokeWithOptions_retry(t *testing.T) {
+	throttled := awserr.New("TooManyRequestsException", "rate exceeded", nil)
+	service := &retryService{errs: []error{throttled, throttled}}
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Service:      service,
+		Log:          log.Log,
+	}
+
+	opts := InvokeOptions{
+		NoLogs:  true,
+		Context: map[string]interface{}{"custom": map[string]interface{}{"tenant": "acme"}},
+		Retry:   &RetryPolicy{Attempts: 3, Backoff: time.Millisecond},
+	}
+
+	reply, _, err := fn.InvokeWithOptions([]byte("{}"), opts)
+	assert.Nil(t, err)
+	b, _ := ioutil.ReadAll(reply)
+	assert.Equal(t, "ok", string(b))
+	assert.Len(t, service.contexts, 3)
+	assert.Equal(t, service.contexts[0], service.contexts[2])
+
+	b, _ = base64.StdEncoding.DecodeString(service.contexts[0])
+	var cc struct {
+		Custom map[string]string `json:"custom"`
+	}
+	assert.Nil(t, json.Unmarshal(b, &cc))
+	assert.Equal(t, "acme", cc.Custom["tenant"])
+	assert.Len(t, cc.Custom["idempotencyKey"], 32)
+
+	service.errs = []error{throttled, throttled, throttled}
+	_, _, err = fn.InvokeWithOptions([]byte("{}"), opts)
+	assert.Equal(t, throttled, err)
+	assert.Len(t, service.errs, 0)
+
+	service.errs = []error{awserr.New("InvalidRequestContentException", "invalid", nil)}
+	_, _, err = fn.InvokeWithOptions([]byte("{}"), opts)
+	assert.EqualError(t, err, "InvalidRequestContentException: invalid")
+	assert.Len(t, service.contexts, 7)
+
+	opts = InvokeOptions{NoLogs: true, ClientContext: "e30=", IdempotencyKey: "order-42"}
+	_, _, err = fn.InvokeWithOptions([]byte("{}"), opts)
+	assert.Nil(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(`{"custom":{"idempotencyKey":"order-42"}}`)), service.contexts[7])
+}
+
+func TestFunction_deadlines(t *testing.T) {
+	fn := &Function{
+		Name:         "foo",
+		FunctionName: "testfn",
+		Config: Config{
+			Build:     Command{"sleep", "10"},
+			Deadlines: &Deadlines{Build: 1, Deploy: 60},
+		},
+		Log: log.Log,
+	}
+
+	start := time.Now()
+	assert.EqualError(t, fn.build(X86_64), "function foo: build exceeded its 1s deadline")
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	fn.deadline = time.Now().Add(-time.Second)
+
+	called := false
+	err := fn.retryConflict(func() error {
+		called = true
+		return nil
+	})
+
+	assert.EqualError(t, err, "function foo: deploy exceeded its 1m0s deadline")
+	assert.False(t, called)
+
+	err = fn.waitReady(&lambda.FunctionConfiguration{State: aws.String(lambda.StatePending)})
+	assert.EqualError(t, err, "function foo: deploy exceeded its 1m0s deadline, waiting for function to become ready (state Pending, last update )")
+
+	fn.deadline = time.Now().Add(time.Hour)
+	fn.Deadlines.Wait = 30
+
+	deadline, expired := fn.deadlineOf(StepWait, ReadyTimeout)
+	assert.True(t, deadline.Before(fn.deadline))
+	assert.Equal(t, &ErrDeadline{Function: "foo", Step: StepWait, Limit: 30 * time.Second}, expired)
+
+	fn.Deadlines.Build = -1
+	assert.EqualError(t, fn.validateDeadlines(), "Deadlines: build must not be negative")
+}
+
+type objectStore struct {
+	s3iface.S3API
+	mu       sync.Mutex
+	objects  map[string]*s3.HeadObjectOutput
+	bodies   map[string][]byte
+	uploads  map[string]map[int64][]byte
+	keys     map[string]string
+	fail     map[int64]int
+	uploaded []int64
+}
+
+func newObjectStore() *objectStore {
+	return &objectStore{
+		objects: make(map[string]*s3.HeadObjectOutput),
+		bodies:  make(map[string][]byte),
+		uploads: make(map[string]map[int64][]byte),
+		keys:    make(map[string]string),
+		fail:    make(map[int64]int),
+	}
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (s *objectStore) HeadObjectWithContext(ctx aws.Context, in *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	o, ok := s.objects[*in.Key]
+	if !ok {
+		return nil, awserr.New("NotFound", "Not Found", nil)
+	}
+	return o, nil
+}
+
+func (s *objectStore) PutObjectWithContext(ctx aws.Context, in *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	b, _ := ioutil.ReadAll(in.Body)
+	if checksum(b) != *in.ChecksumSHA256 {
+		return nil, awserr.New("BadDigest", "checksum mismatch", nil)
+	}
+
+	s.bodies[*in.Key] = b
+	s.objects[*in.Key] = &s3.HeadObjectOutput{
+		ContentLength:  aws.Int64(int64(len(b))),
+		ChecksumSHA256: in.ChecksumSHA256,
+		Metadata:       in.Metadata,
+		VersionId:      aws.String("v1"),
+	}
+
+	return &s3.PutObjectOutput{VersionId: aws.String("v1")}, nil
+}
+
+func (s *objectStore) ListMultipartUploadsWithContext(ctx aws.Context, in *s3.ListMultipartUploadsInput, opts ...request.Option) (*s3.ListMultipartUploadsOutput, error) {
+	out := &s3.ListMultipartUploadsOutput{}
+	for id, key := range s.keys {
+		out.Uploads = append(out.Uploads, &s3.MultipartUpload{
+			UploadId:          aws.String(id),
+			Key:               aws.String(key),
+			ChecksumAlgorithm: aws.String(s3.ChecksumAlgorithmSha256),
+			Initiated:         aws.Time(time.Now()),
+		})
+	}
+	return out, nil
+}
+
+func (s *objectStore) CreateMultipartUploadWithContext(ctx aws.Context, in *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	id := "upload-1"
+	s.keys[id] = *in.Key
+	s.uploads[id] = make(map[int64][]byte)
+	return &s3.CreateMultipartUploadOutput{UploadId: &id}, nil
+}
+
+func (s *objectStore) UploadPartWithContext(ctx aws.Context, in *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail[*in.PartNumber] > 0 {
+		s.fail[*in.PartNumber]--
+		return nil, awserr.New("RequestTimeout", "timed out", nil)
+	}
+
+	b, _ := ioutil.ReadAll(in.Body)
+	if checksum(b) != *in.ChecksumSHA256 {
+		return nil, awserr.New("BadDigest", "checksum mismatch", nil)
+	}
+
+	s.uploads[*in.UploadId][*in.PartNumber] = b
+	s.uploaded = append(s.uploaded, *in.PartNumber)
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("etag-%d", *in.PartNumber))}, nil
+}
+
+func (s *objectStore) ListPartsPagesWithContext(ctx aws.Context, in *s3.ListPartsInput, fn func(*s3.ListPartsOutput, bool) bool, opts ...request.Option) error {
+	out := &s3.ListPartsOutput{}
+	for n, b := range s.uploads[*in.UploadId] {
+		out.Parts = append(out.Parts, &s3.Part{
+			PartNumber:     aws.Int64(n),
+			ETag:           aws.String(fmt.Sprintf("etag-%d", n)),
+			ChecksumSHA256: aws.String(checksum(b)),
+			Size:           aws.Int64(int64(len(b))),
+		})
+	}
+	fn(out, true)
+	return nil
+}
+
+func (s *objectStore) CompleteMultipartUploadWithContext(ctx aws.Context, in *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	var body, digests []byte
+
+	for _, p := range in.MultipartUpload.Parts {
+		b := s.uploads[*in.UploadId][*p.PartNumber]
+		sum := sha256.Sum256(b)
+		body = append(body, b...)
+		digests = append(digests, sum[:]...)
+	}
+
+	composite := checksum(digests) + fmt.Sprintf("-%d", len(in.MultipartUpload.Parts))
+
+	s.bodies[*in.Key] = body
+	s.objects[*in.Key] = &s3.HeadObjectOutput{
+		ContentLength:  aws.Int64(int64(len(body))),
+		ChecksumSHA256: &composite,
+		Metadata:       map[string]*string{"Sha256": aws.String(fmt.Sprintf("%x", sha256.Sum256(body)))},
+		VersionId:      aws.String("v2"),
+	}
+
+	delete(s.uploads, *in.UploadId)
+	delete(s.keys, *in.UploadId)
+	return &s3.CompleteMultipartUploadOutput{VersionId: aws.String("v2")}, nil
+}
+
+func TestFunction_uploadArtifact(t *testing.T) {
+	store := newObjectStore()
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Config: Config{
+			Artifacts: &Artifacts{Bucket: "builds", PartSize: 5, Concurrency: 2},
+		},
+		S3:  store,
+		Log: log.Log,
+	}
+
+	zip := make([]byte, 12<<20)
+	for i := range zip {
+		zip[i] = byte(i * 7 % 251)
+	}
+
+	sum := sha256.Sum256(zip)
+	key := "apex/testfn/" + hex.EncodeToString(sum[:]) + ".zip"
+
+	b, err := spool(func(w io.Writer) error {
+		_, err := w.Write(zip)
+		return err
+	})
+	assert.Nil(t, err)
+	defer b.Close()
+
+	store.fail[3] = partAttempts
+	_, err = fn.uploadArtifact(b)
+	assert.EqualError(t, err, "uploading parts: part 3: RequestTimeout: timed out, retrying resumes the upload")
+	sort.Slice(store.uploaded, func(a, b int) bool { return store.uploaded[a] < store.uploaded[b] })
+	assert.Equal(t, []int64{1, 2}, store.uploaded)
+
+	store.uploaded = nil
+	store.fail[2] = 1
+
+	code, err := fn.uploadArtifact(b)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{3}, store.uploaded)
+	assert.Equal(t, &lambda.FunctionCode{S3Bucket: aws.String("builds"), S3Key: &key, S3ObjectVersion: aws.String("v2")}, code)
+	assert.True(t, bytes.Equal(zip, store.bodies[key]))
+
+	store.uploaded = nil
+	code, err = fn.uploadArtifact(b)
+	assert.Nil(t, err)
+	assert.Empty(t, store.uploaded)
+	assert.Equal(t, "v2", *code.S3ObjectVersion)
+
+	small := []byte("small zip")
+	code, err = fn.uploadArtifact(newBundle(small))
+	assert.Nil(t, err)
+	assert.Equal(t, small, store.bodies[*code.S3Key])
+	assert.Equal(t, "v1", *code.S3ObjectVersion)
+
+	store.objects[key].ChecksumSHA256 = aws.String("tampered-3")
+	u := &uploader{service: store, ctx: context.Background(), log: log.Log, bucket: "builds", key: key, body: b}
+	assert.EqualError(t, u.verify("expected-3", ""), "uploaded s3://builds/"+key+" checksum tampered-3 does not match expected-3")
+}
+
+func TestSpool(t *testing.T) {
+	b, err := spool(func(w io.Writer) error {
+		_, err := w.Write([]byte("zip"))
+		return err
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), b.size)
+	assert.Equal(t, utils.Sha256([]byte("zip")), b.checksum())
+
+	path := filepath.Join(os.TempDir(), "apex-spool-test.zip")
+	defer os.Remove(path)
+	assert.Nil(t, b.save(path))
+
+	name := b.file.Name()
+	assert.Nil(t, b.Close())
+	_, err = os.Stat(name)
+	assert.True(t, os.IsNotExist(err), "temporary file should be removed")
+
+	opened, err := openBundle(path)
+	assert.Nil(t, err)
+	assert.Equal(t, utils.Sha256([]byte("zip")), opened.checksum())
+	assert.Nil(t, opened.Close())
+
+	_, err = os.Stat(path)
+	assert.Nil(t, err, "opened file should be kept")
+
+	_, err = spool(func(w io.Writer) error {
+		return errors.New("boom")
+	})
+	assert.EqualError(t, err, "boom")
+}
+
+func TestFunction_validateCompression(t *testing.T) {
+	fn := &Function{}
+	assert.Nil(t, fn.validateCompression())
+
+	fn.Compression = &Compression{Level: 9, Store: []string{"*.png", "assets/*"}}
+	assert.Nil(t, fn.validateCompression())
+
+	fn.Compression = &Compression{Level: 10}
+	assert.EqualError(t, fn.validateCompression(), "Compression: level must be between 1 and 9")
+
+	fn.Compression = &Compression{Store: []string{"[a-"}}
+	assert.EqualError(t, fn.validateCompression(), `Compression: invalid store pattern "[a-"`)
+
+	fn.Compression = &Compression{Concurrency: -1}
+	assert.EqualError(t, fn.validateCompression(), "Compression: concurrency must not be negative")
+}
+
+func TestZipWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zip")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	large := bytes.Repeat([]byte("large file "), maxBuffered/10)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "large.txt"), large, 0644))
+
+	for i := 0; i < 20; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("file%02d.js", i))
+		assert.Nil(t, ioutil.WriteFile(name, bytes.Repeat([]byte{byte('a' + i)}, 1000*i), 0644))
+	}
+
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "assets"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "assets", "logo.png"), []byte("png png png png"), 0644))
+
+	write := func(c *Compression) []byte {
+		var buf bytes.Buffer
+		z := newZipWriter(&buf, c)
+		assert.Nil(t, z.AddBytes(".env.json", []byte(`{"FOO":"bar"}`)))
+		assert.Nil(t, z.AddDir(dir, nil))
+		assert.Nil(t, z.Close())
+		return buf.Bytes()
+	}
+
+	serial := write(&Compression{Concurrency: 1, Store: []string{"*.png"}})
+	parallel := write(&Compression{Concurrency: 8, Store: []string{"*.png"}})
+	assert.Equal(t, serial, parallel, "zips should not depend on concurrency")
+
+	r, err := zip.NewReader(bytes.NewReader(parallel), int64(len(parallel)))
+	assert.Nil(t, err)
+	assert.Len(t, r.File, 23)
+	assert.Equal(t, ".env.json", r.File[0].Name)
+
+	for _, file := range r.File {
+		rc, err := file.Open()
+		assert.Nil(t, err, file.Name)
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		assert.Nil(t, err, file.Name)
+
+		switch file.Name {
+		case ".env.json":
+			assert.Equal(t, `{"FOO":"bar"}`, string(b))
+		case "assets/logo.png":
+			assert.Equal(t, zip.Store, file.Method)
+			assert.Equal(t, "png png png png", string(b))
+		case "large.txt":
+			assert.Equal(t, zip.Deflate, file.Method)
+			assert.True(t, bytes.Equal(large, b))
+		default:
+			assert.Equal(t, zip.Deflate, file.Method)
+			want, _ := ioutil.ReadFile(filepath.Join(dir, file.Name))
+			assert.Equal(t, want, b)
+		}
+	}
+
+	smallest := write(&Compression{Level: 9})
+	stored := write(&Compression{Store: []string{"*"}})
+	assert.True(t, len(smallest) < len(stored))
+
+	var buf bytes.Buffer
+	z := newZipWriter(&buf, nil)
+	assert.Nil(t, z.AddFile("missing.js", filepath.Join(dir, "missing.js"), fileInfo(t, filepath.Join(dir, "file01.js"))))
+	err = z.Close()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "adding missing.js: open ")
+}
+
+func fileInfo(t *testing.T, path string) os.FileInfo {
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	return info
+}
+
+type regionalStore struct {
+	s3iface.S3API
+	objects map[string]*s3.HeadObjectOutput
+	copies  []string
+	puts    []string
+}
+
+func (s *regionalStore) HeadObject(in *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	return s.HeadObjectWithContext(context.Background(), in)
+}
+
+func (s *regionalStore) HeadObjectWithContext(ctx aws.Context, in *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	o, ok := s.objects[*in.Bucket+"/"+*in.Key]
+	if !ok {
+		return nil, awserr.New("NotFound", "Not Found", nil)
+	}
+	return o, nil
+}
+
+func (s *regionalStore) CopyObjectWithContext(ctx aws.Context, in *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	o, ok := s.objects[*in.CopySource]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "no such key", nil)
+	}
+
+	c := *o
+	c.VersionId = aws.String("copied")
+	s.objects[*in.Bucket+"/"+*in.Key] = &c
+	s.copies = append(s.copies, *in.CopySource)
+	return &s3.CopyObjectOutput{VersionId: c.VersionId}, nil
+}
+
+func (s *regionalStore) PutObjectWithContext(ctx aws.Context, in *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	b, _ := ioutil.ReadAll(in.Body)
+	s.objects[*in.Bucket+"/"+*in.Key] = &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(b))),
+		Metadata:      in.Metadata,
+		VersionId:     aws.String("put"),
+	}
+	s.puts = append(s.puts, *in.Bucket+"/"+*in.Key)
+	return &s3.PutObjectOutput{VersionId: aws.String("put")}, nil
+}
+
+func TestFunction_storeArtifact(t *testing.T) {
+	shared := newBundle([]byte("shared zip"))
+	key := "apex/store/" + shared.hexChecksum() + ".zip"
+
+	store := &regionalStore{objects: map[string]*s3.HeadObjectOutput{
+		"store-us-east-1/" + key: {
+			ContentLength: aws.Int64(shared.size),
+			Metadata:      map[string]*string{"Sha256": aws.String(shared.hexChecksum())},
+		},
+	}}
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Region:       "eu-west-1",
+		Config: Config{
+			Store: &Store{Bucket: "store-{region}", Regions: []string{"us-east-1", "eu-west-1"}},
+		},
+		S3:  store,
+		Log: log.Log,
+	}
+
+	code, err := fn.storeArtifact(shared)
+	assert.Nil(t, err)
+	assert.Equal(t, &lambda.FunctionCode{S3Bucket: aws.String("store-eu-west-1"), S3Key: &key, S3ObjectVersion: aws.String("copied")}, code)
+	assert.Equal(t, []string{"store-us-east-1/" + key}, store.copies)
+
+	other := &Function{
+		FunctionName: "other",
+		Region:       "eu-west-1",
+		Config:       fn.Config,
+		S3:           store,
+		Log:          log.Log,
+	}
+
+	code, err = other.storeArtifact(shared)
+	assert.Nil(t, err)
+	assert.Equal(t, key, *code.S3Key)
+	assert.Len(t, store.copies, 1, "stored zips should be reused")
+	assert.Empty(t, store.puts)
+
+	code, err = fn.storeArtifact(newBundle([]byte("new zip")))
+	assert.Nil(t, err)
+	assert.Equal(t, "put", *code.S3ObjectVersion)
+	assert.Equal(t, []string{"store-eu-west-1/" + *code.S3Key}, store.puts)
+}
+
+func TestFunction_deployStored(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	stored := newBundle([]byte("stored zip"))
+
+	fn := &Function{
+		FunctionName: "testfn",
+		Region:       "us-east-1",
+		Config:       Config{Store: &Store{Bucket: "store"}},
+		Service:      serviceMock,
+		S3: &regionalStore{objects: map[string]*s3.HeadObjectOutput{
+			"store/apex/store/" + stored.hexChecksum() + ".zip": {VersionId: aws.String("v3")},
+		}},
+		Log: log.Log,
+	}
+
+	serviceMock.EXPECT().GetFunction(gomock.Any()).Return(&lambda.GetFunctionOutput{
+		Configuration: &lambda.FunctionConfiguration{CodeSha256: aws.String(stored.checksum())},
+	}, nil)
+
+	assert.Equal(t, ErrUnchanged, fn.DeployArtifact(ChecksumPrefix+stored.checksum()))
+
+	missing := newBundle([]byte("missing zip"))
+	assert.EqualError(t, fn.DeployArtifact(ChecksumPrefix+missing.hexChecksum()), "sha256:"+missing.hexChecksum()+" is not stored in store")
+	assert.EqualError(t, fn.DeployArtifact("sha256:abc"), `invalid checksum "abc", must be a hex or base64 SHA256`)
+
+	fn.Store = nil
+	assert.EqualError(t, fn.DeployArtifact(ChecksumPrefix+stored.checksum()), "deploying stored zips requires a store")
+}
+
+func TestFunction_validateStore(t *testing.T) {
+	fn := &Function{Config: Config{Store: &Store{}}}
+	assert.EqualError(t, fn.validateStore(), "Store: bucket is required")
+
+	fn.Store = &Store{Bucket: "store", Regions: []string{"us-east-1"}}
+	assert.EqualError(t, fn.validateStore(), "Store: regions require a bucket with the {region} placeholder")
+
+	fn.Store = &Store{Bucket: "store-{region}", Regions: []string{"us-east-1"}}
+	assert.Nil(t, fn.validateStore())
+
+	fn.Artifacts = &Artifacts{Bucket: "builds"}
+	assert.EqualError(t, fn.validateStore(), "Store: cannot be used along with artifacts")
+}
+
+type copiedService struct {
+	lambdaiface.LambdaAPI
+	exists  bool
+	created *lambda.CreateFunctionInput
+	config  *lambda.UpdateFunctionConfigurationInput
+	code    []byte
+	aliases map[string]string
+}
+
+func (s *copiedService) GetFunction(in *lambda.GetFunctionInput) (*lambda.GetFunctionOutput, error) {
+	if !s.exists {
+		return nil, awserr.New("ResourceNotFoundException", "not found", nil)
+	}
+	return &lambda.GetFunctionOutput{}, nil
+}
+
+func (s *copiedService) CreateFunction(in *lambda.CreateFunctionInput) (*lambda.FunctionConfiguration, error) {
+	s.created = in
+	s.code = in.Code.ZipFile
+	return &lambda.FunctionConfiguration{Version: aws.String("1")}, nil
+}
+
+func (s *copiedService) UpdateFunctionCode(in *lambda.UpdateFunctionCodeInput) (*lambda.FunctionConfiguration, error) {
+	s.code = in.ZipFile
+	return &lambda.FunctionConfiguration{}, nil
+}
+
+func (s *copiedService) UpdateFunctionConfiguration(in *lambda.UpdateFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
+	s.config = in
+	return &lambda.FunctionConfiguration{CodeSha256: aws.String(utils.Sha256(s.code))}, nil
+}
+
+func (s *copiedService) PublishVersion(in *lambda.PublishVersionInput) (*lambda.FunctionConfiguration, error) {
+	return &lambda.FunctionConfiguration{Version: aws.String("8")}, nil
+}
+
+func (s *copiedService) CreateAlias(in *lambda.CreateAliasInput) (*lambda.AliasConfiguration, error) {
+	s.aliases[*in.Name] = *in.FunctionVersion
+	return &lambda.AliasConfiguration{}, nil
+}
+
+func (s *copiedService) UpdateAlias(in *lambda.UpdateAliasInput) (*lambda.AliasConfiguration, error) {
+	if _, ok := s.aliases[*in.Name]; !ok {
+		return nil, awserr.New("ResourceNotFoundException", "not found", nil)
+	}
+	s.aliases[*in.Name] = *in.FunctionVersion
+	return &lambda.AliasConfiguration{}, nil
+}
+
+func TestFunction_CopyTo(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("zip"))
+	}))
+	defer server.Close()
+
+	serviceMock.EXPECT().GetFunction(&lambda.GetFunctionInput{
+		FunctionName: aws.String("app_foo"),
+		Qualifier:    aws.String("current"),
+	}).Return(&lambda.GetFunctionOutput{
+		Code: &lambda.FunctionCodeLocation{Location: aws.String(server.URL)},
+		Configuration: &lambda.FunctionConfiguration{
+			CodeSha256:  aws.String(utils.Sha256([]byte("zip"))),
+			Handler:     aws.String("index.handle"),
+			Runtime:     aws.String("nodejs20.x"),
+			MemorySize:  aws.Int64(256),
+			Timeout:     aws.Int64(10),
+			Role:        aws.String("arn:aws:iam::111111111111:role/foo"),
+			Environment: &lambda.EnvironmentResponse{Variables: map[string]*string{"FOO": aws.String("bar")}},
+			Layers:      []*lambda.Layer{{Arn: aws.String("arn:aws:lambda:us-east-1:111111111111:layer:deps:3")}},
+		},
+	}, nil).Times(3)
+
+	fn := &Function{FunctionName: "app_foo", Service: serviceMock, Log: log.Log}
+
+	assert.EqualError(t, fn.CopyTo(&CopyTarget{Service: serviceMock}), "cannot copy app_foo to itself")
+
+	target := &copiedService{aliases: make(map[string]string)}
+	assert.Nil(t, fn.CopyTo(&CopyTarget{FunctionName: "app_bar", Service: target}))
+	assert.Equal(t, "app_bar", *target.created.FunctionName)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/foo", *target.created.Role)
+	assert.Equal(t, int64(256), *target.created.MemorySize)
+	assert.Equal(t, "bar", *target.created.Environment.Variables["FOO"])
+	assert.Equal(t, []*string{aws.String("arn:aws:lambda:us-east-1:111111111111:layer:deps:3")}, target.created.Layers)
+	assert.Equal(t, []byte("zip"), target.code)
+	assert.Equal(t, map[string]string{"current": "1"}, target.aliases)
+
+	err := fn.CopyTo(&CopyTarget{Service: target, Account: "222222222222"})
+	assert.EqualError(t, err, "copying to account 222222222222 requires a role, as arn:aws:iam::111111111111:role/foo is in another account")
+
+	target.exists = true
+	assert.Nil(t, fn.CopyTo(&CopyTarget{Service: target, Account: "222222222222", Role: "arn:aws:iam::222222222222:role/foo"}))
+	assert.Equal(t, "arn:aws:iam::222222222222:role/foo", *target.config.Role)
+	assert.Equal(t, "index.handle", *target.config.Handler)
+	assert.Equal(t, map[string]string{"current": "8"}, target.aliases)
+}
+
+type changedService struct {
+	lambdaiface.LambdaAPI
+	config  *lambda.UpdateFunctionConfigurationInput
+	aliases map[string]string
+}
+
+func (s *changedService) GetFunctionConfiguration(in *lambda.GetFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
+	cfg := &lambda.FunctionConfiguration{
+		Runtime:    aws.String("nodejs18.x"),
+		MemorySize: aws.Int64(128),
+		Timeout:    aws.Int64(3),
+		CodeSha256: aws.String("sum"),
+		Layers: []*lambda.Layer{
+			{Arn: aws.String("arn:aws:lambda:us-east-1:111111111111:layer:deps:3")},
+			{Arn: aws.String("arn:aws:lambda:us-east-1:111111111111:layer:tools:1")},
+		},
+	}
+
+	if s.config != nil {
+		cfg.Runtime = s.config.Runtime
+		cfg.Layers = nil
+		for _, arn := range s.config.Layers {
+			cfg.Layers = append(cfg.Layers, &lambda.Layer{Arn: arn})
+		}
+	}
+
+	return cfg, nil
+}
+
+func (s *changedService) UpdateFunctionConfiguration(in *lambda.UpdateFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
+	s.config = in
+	return &lambda.FunctionConfiguration{CodeSha256: aws.String("sum")}, nil
+}
+
+func (s *changedService) PublishVersion(in *lambda.PublishVersionInput) (*lambda.FunctionConfiguration, error) {
+	return &lambda.FunctionConfiguration{Version: aws.String("5"), CodeSha256: in.CodeSha256}, nil
+}
+
+func (s *changedService) UpdateAlias(in *lambda.UpdateAliasInput) (*lambda.AliasConfiguration, error) {
+	s.aliases[*in.Name] = *in.FunctionVersion
+	return &lambda.AliasConfiguration{}, nil
+}
+
+func TestFunction_ApplyChange(t *testing.T) {
+	s := &changedService{aliases: make(map[string]string)}
+	fn := &Function{FunctionName: "app_foo", Service: s, Log: log.Log}
+
+	change := &Change{
+		Runtime:      "nodejs22.x",
+		AddLayers:    []string{"arn:aws:lambda:us-east-1:111111111111:layer:deps:4", "arn:aws:lambda:us-east-1:111111111111:layer:otel:2"},
+		RemoveLayers: []string{"arn:aws:lambda:us-east-1:111111111111:layer:tools"},
+		Memory:       128,
+	}
+
+	updates, err := fn.PlanChange(change)
+	assert.Nil(t, err)
+	assert.Nil(t, s.config)
+
+	var lines []string
+	for _, u := range updates {
+		lines = append(lines, u.String())
+	}
+
+	assert.Equal(t, []string{
+		"runtime: nodejs18.x -> nodejs22.x",
+		"layers: arn:aws:lambda:us-east-1:111111111111:layer:deps:3, arn:aws:lambda:us-east-1:111111111111:layer:tools:1 -> arn:aws:lambda:us-east-1:111111111111:layer:deps:4, arn:aws:lambda:us-east-1:111111111111:layer:otel:2",
+	}, lines)
+
+	updates, err = fn.ApplyChange(change)
+	assert.Nil(t, err)
+	assert.Len(t, updates, 2)
+	assert.Equal(t, "nodejs22.x", *s.config.Runtime)
+	assert.Nil(t, s.config.MemorySize)
+	assert.Nil(t, s.config.Handler)
+	assert.Equal(t, map[string]string{"current": "5"}, s.aliases)
+
+	_, err = fn.ApplyChange(change)
+	assert.Equal(t, ErrUnchanged, err)
+
+	_, err = fn.ApplyChange(&Change{})
+	assert.EqualError(t, err, "no configuration change given")
+
+	_, err = fn.ApplyChange(&Change{AddLayers: []string{"arn:aws:lambda:us-east-1:111111111111:layer:deps"}})
+	assert.EqualError(t, err, "Layers: arn:aws:lambda:us-east-1:111111111111:layer:deps is not a layer version ARN")
+
+	fn.Budget = &Budget{MaxMemory: 512}
+	_, err = fn.PlanChange(&Change{Memory: 1024})
+	assert.EqualError(t, err, "Memory: 1024MB exceeds the budget ceiling of 512MB")
+}
+
+type snapshottedService struct {
+	lambdaiface.LambdaAPI
+	url string
+}
+
+func (s *snapshottedService) GetFunction(in *lambda.GetFunctionInput) (*lambda.GetFunctionOutput, error) {
+	code := "latest"
+	version := "$LATEST"
+	if in.Qualifier != nil {
+		code = "v" + *in.Qualifier
+		version = *in.Qualifier
+	}
+
+	return &lambda.GetFunctionOutput{
+		Code: &lambda.FunctionCodeLocation{Location: aws.String(s.url + "/" + code)},
+		Configuration: &lambda.FunctionConfiguration{
+			Version:    aws.String(version),
+			CodeSha256: aws.String(utils.Sha256([]byte(code))),
+			Handler:    aws.String("index.handle"),
+			Runtime:    aws.String("nodejs20.x"),
+			MemorySize: aws.Int64(256),
+			Role:       aws.String("arn:aws:iam::111111111111:role/foo"),
+		},
+		Concurrency: &lambda.PutFunctionConcurrencyOutput{ReservedConcurrentExecutions: aws.Int64(5)},
+		Tags:        map[string]*string{"team": aws.String("core")},
+	}, nil
+}
+
+func (s *snapshottedService) ListAliasesPages(in *lambda.ListAliasesInput, fn func(*lambda.ListAliasesOutput, bool) bool) error {
+	fn(&lambda.ListAliasesOutput{Aliases: []*lambda.AliasConfiguration{{
+		Name:            aws.String("current"),
+		FunctionVersion: aws.String("3"),
+		RoutingConfig:   &lambda.AliasRoutingConfiguration{AdditionalVersionWeights: map[string]*float64{"2": aws.Float64(0.1)}},
+	}}}, true)
+	return nil
+}
+
+func (s *snapshottedService) GetPolicy(in *lambda.GetPolicyInput) (*lambda.GetPolicyOutput, error) {
+	if in.Qualifier == nil {
+		return nil, awserr.New("ResourceNotFoundException", "not found", nil)
+	}
+
+	return &lambda.GetPolicyOutput{Policy: aws.String(`{"Statement":[{"Sid":"s3","Action":"lambda:InvokeFunction","Principal":{"Service":"s3.amazonaws.com"},"Condition":{"ArnLike":{"AWS:SourceArn":"arn:aws:s3:::bucket"}}}]}`)}, nil
+}
+
+func (s *snapshottedService) ListEventSourceMappingsPages(in *lambda.ListEventSourceMappingsInput, fn func(*lambda.ListEventSourceMappingsOutput, bool) bool) error {
+	fn(&lambda.ListEventSourceMappingsOutput{EventSourceMappings: []*lambda.EventSourceMappingConfiguration{{
+		UUID:           aws.String("1234"),
+		EventSourceArn: aws.String("arn:aws:sqs:us-east-1:111111111111:jobs"),
+		FunctionArn:    aws.String("arn:aws:lambda:us-east-1:111111111111:function:app_foo:current"),
+		BatchSize:      aws.Int64(10),
+		State:          aws.String("Enabled"),
+	}}}, true)
+	return nil
+}
+
+type restoredService struct {
+	copiedService
+	published   []string
+	aliases     map[string]*lambda.CreateAliasInput
+	permissions []*lambda.AddPermissionInput
+	mappings    []*lambda.CreateEventSourceMappingInput
+	concurrency int64
+}
+
+func (s *restoredService) CreateFunction(in *lambda.CreateFunctionInput) (*lambda.FunctionConfiguration, error) {
+	s.created = in
+	s.published = append(s.published, string(in.Code.ZipFile))
+	return &lambda.FunctionConfiguration{Version: aws.String("1")}, nil
+}
+
+func (s *restoredService) PublishVersion(in *lambda.PublishVersionInput) (*lambda.FunctionConfiguration, error) {
+	s.published = append(s.published, string(s.code))
+	return &lambda.FunctionConfiguration{Version: aws.String(fmt.Sprint(len(s.published)))}, nil
+}
+
+func (s *restoredService) CreateAlias(in *lambda.CreateAliasInput) (*lambda.AliasConfiguration, error) {
+	s.aliases[*in.Name] = in
+	return &lambda.AliasConfiguration{}, nil
+}
+
+func (s *restoredService) AddPermission(in *lambda.AddPermissionInput) (*lambda.AddPermissionOutput, error) {
+	s.permissions = append(s.permissions, in)
+	return &lambda.AddPermissionOutput{}, nil
+}
+
+func (s *restoredService) CreateEventSourceMapping(in *lambda.CreateEventSourceMappingInput) (*lambda.EventSourceMappingConfiguration, error) {
+	s.mappings = append(s.mappings, in)
+	return &lambda.EventSourceMappingConfiguration{}, nil
+}
+
+func (s *restoredService) PutFunctionConcurrency(in *lambda.PutFunctionConcurrencyInput) (*lambda.PutFunctionConcurrencyOutput, error) {
+	s.concurrency = *in.ReservedConcurrentExecutions
+	return &lambda.PutFunctionConcurrencyOutput{}, nil
+}
+
+func TestFunction_Snapshot(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.TrimPrefix(r.URL.Path, "/")))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "apex-snapshot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "foo.snapshot")
+
+	fn := &Function{FunctionName: "app_foo", Service: &snapshottedService{url: server.URL}, Log: log.Log}
+	assert.Nil(t, fn.Snapshot(path))
+
+	s, err := ReadSnapshot(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "app_foo", s.FunctionName)
+	assert.Equal(t, 2, len(s.Versions))
+	assert.Equal(t, "2", *s.Versions[0].Configuration.Version)
+	assert.Equal(t, 1, len(s.EventSources))
+
+	restored := &restoredService{aliases: make(map[string]*lambda.CreateAliasInput)}
+
+	fn = &Function{FunctionName: "app_bar", Service: restored, Log: log.Log}
+	assert.Nil(t, fn.Restore(path))
+	assert.Equal(t, []string{"v2", "v3"}, restored.published)
+	assert.Equal(t, "latest", string(restored.code))
+	assert.Equal(t, "core", *restored.created.Tags["team"])
+	assert.Equal(t, "2", *restored.aliases["current"].FunctionVersion)
+	assert.Equal(t, 0.1, *restored.aliases["current"].RoutingConfig.AdditionalVersionWeights["1"])
+	assert.Equal(t, 1, len(restored.permissions))
+	assert.Equal(t, "s3.amazonaws.com", *restored.permissions[0].Principal)
+	assert.Equal(t, "arn:aws:s3:::bucket", *restored.permissions[0].SourceArn)
+	assert.Equal(t, "current", *restored.permissions[0].Qualifier)
+	assert.Equal(t, "app_bar:current", *restored.mappings[0].FunctionName)
+	assert.Equal(t, true, *restored.mappings[0].Enabled)
+	assert.Equal(t, int64(5), restored.concurrency)
+
+	restored.exists = true
+	assert.EqualError(t, fn.Restore(path), "function app_bar already exists")
+}
+
+type infoService struct {
+	lambdaiface.LambdaAPI
+}
+
+func (s *infoService) GetFunction(in *lambda.GetFunctionInput) (*lambda.GetFunctionOutput, error) {
+	return &lambda.GetFunctionOutput{
+		Configuration: &lambda.FunctionConfiguration{
+			FunctionName:  aws.String("app_foo"),
+			FunctionArn:   aws.String("arn:aws-us-gov:lambda:us-gov-west-1:111111111111:function:app_foo"),
+			Runtime:       aws.String("nodejs20.x"),
+			Architectures: []*string{aws.String(Arm64)},
+			MemorySize:    aws.Int64(256),
+			Environment:   &lambda.EnvironmentResponse{Variables: map[string]*string{"TOKEN": aws.String("secret"), "API": aws.String("url")}},
+			LastModified:  aws.String("2024-05-01T10:00:00.000+0000"),
+		},
+	}, nil
+}
+
+func (s *infoService) ListAliasesPages(in *lambda.ListAliasesInput, fn func(*lambda.ListAliasesOutput, bool) bool) error {
+	fn(&lambda.ListAliasesOutput{Aliases: []*lambda.AliasConfiguration{{
+		Name:            aws.String("current"),
+		FunctionVersion: aws.String("4"),
+		RoutingConfig:   &lambda.AliasRoutingConfiguration{AdditionalVersionWeights: map[string]*float64{"5": aws.Float64(0.1)}},
+	}}}, true)
+	return nil
+}
+
+func TestFunction_Info(t *testing.T) {
+	fn := &Function{Name: "foo", FunctionName: "app_foo", Service: &infoService{}, Log: log.Log}
+
+	info, err := fn.Info()
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", info.Name)
+	assert.Equal(t, "us-gov-west-1", info.Region)
+	assert.Equal(t, PartitionGovCloud, info.Partition)
+	assert.Equal(t, Arm64, info.Architecture)
+	assert.Equal(t, []string{"API", "TOKEN"}, info.Environment)
+	assert.Equal(t, "/aws/lambda/app_foo", info.LogGroup)
+	assert.Equal(t, "https://console.amazonaws-us-gov.com/lambda/home?region=us-gov-west-1#/functions/app_foo", info.ConsoleURL)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), info.LastModified)
+	assert.Equal(t, []*AliasInfo{{Name: "current", Version: "4", Weights: map[string]float64{"5": 0.1}}}, info.Aliases)
+
+	b, err := json.Marshal(info.Aliases)
+	assert.Nil(t, err)
+	assert.Equal(t, `[{"name":"current","version":"4","weights":{"5":0.1}}]`, string(b))
+}
+
+type containerRuntime struct{}
+
+func (containerRuntime) BuildImage() string { return "builder:latest" }
+
+func (containerRuntime) BuildCommand(arch string) string { return "make build ARCH=" + arch }
+
+func TestFunction_dockerArgs(t *testing.T) {
+	fn := &Function{}
+
+	assert.Equal(t, []string{"run", "--rm",
+		"--platform", "linux/amd64",
+		"-v", "/src/api:/var/task",
+		"-w", "/var/task",
+		"builder:latest",
+		"sh", "-c", "make build ARCH=x86_64"}, fn.dockerArgs(containerRuntime{}, X86_64, "/src/api"))
+
+	fn.DockerImage = "custom:1"
+
+	assert.Equal(t, []string{"run", "--rm",
+		"--platform", "linux/arm64",
+		"-v", "/src/api:/var/task",
+		"-w", "/var/task",
+		"custom:1",
+		"sh", "-c", "make build ARCH=arm64"}, fn.dockerArgs(containerRuntime{}, Arm64, "/src/api"))
+}
@@ -0,0 +1,395 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/appsync"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// GraphQL attaches the function to an AppSync GraphQL API as a data
+// source, resolving the fields of Resolvers.
+type GraphQL struct {
+	// API id.
+	API string `json:"api"`
+
+	// DataSource name, defaulting to the function name
+	// with hyphens replaced by underscores.
+	DataSource string `json:"dataSource"`
+
+	// Role is the ARN of the service role AppSync assumes to invoke the
+	// function, defaulting to a role managed by apex.
+	Role string `json:"role"`
+
+	// Resolvers of the API resolved by the function.
+	Resolvers []*GraphQLResolver `json:"resolvers"`
+}
+
+// GraphQLResolver maps a field of the GraphQL schema to the function.
+type GraphQLResolver struct {
+	// Type such as "Query" or "Mutation".
+	Type string `json:"type"`
+
+	// Field of the type.
+	Field string `json:"field"`
+
+	// Request is the path of the VTL request mapping template, relative
+	// to the function directory. Without templates the resolver invokes
+	// the function directly with the AppSync context.
+	Request string `json:"request"`
+
+	// Response is the path of the VTL response mapping template.
+	Response string `json:"response"`
+
+	// MaxBatchSize batches the invocations of list fields, up to 2000.
+	MaxBatchSize int64 `json:"maxBatchSize"`
+}
+
+// graphQLName pattern for valid GraphQL type, field and data source names.
+var graphQLName = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// dataSource returns the data source name.
+func (f *Function) dataSource() string {
+	if f.GraphQL.DataSource != "" {
+		return f.GraphQL.DataSource
+	}
+	return strings.Replace(f.FunctionName, "-", "_", -1)
+}
+
+// appSyncRoleName returns the name of the managed AppSync service role.
+func (f *Function) appSyncRoleName() string {
+	return "apex-appsync-" + f.FunctionName
+}
+
+// validateGraphQL checks the API, data source and resolvers are valid,
+// and the fields are resolved once.
+func (f *Function) validateGraphQL() error {
+	g := f.GraphQL
+	if g == nil {
+		return nil
+	}
+
+	if g.API == "" {
+		return fmt.Errorf("GraphQL: api is required")
+	}
+
+	if !graphQLName.MatchString(f.dataSource()) {
+		return fmt.Errorf("GraphQL: invalid dataSource %q", f.dataSource())
+	}
+
+	fields := make(map[string]bool)
+
+	for _, r := range g.Resolvers {
+		if !graphQLName.MatchString(r.Type) || !graphQLName.MatchString(r.Field) {
+			return fmt.Errorf("GraphQL: invalid resolver %s.%s", r.Type, r.Field)
+		}
+
+		name := r.Type + "." + r.Field
+		if fields[name] {
+			return fmt.Errorf("GraphQL: duplicate resolver %s", name)
+		}
+		fields[name] = true
+
+		if (r.Request == "") != (r.Response == "") {
+			return fmt.Errorf("GraphQL: resolver %s requires both request and response templates", name)
+		}
+
+		if r.MaxBatchSize < 0 || r.MaxBatchSize > 2000 {
+			return fmt.Errorf("GraphQL: resolver %s maxBatchSize must be between 0 and 2000", name)
+		}
+	}
+
+	return nil
+}
+
+// DeployGraphQL creates or updates the AppSync data source of the current
+// alias and its resolvers. Resolvers no longer configured are left in place.
+func (f *Function) DeployGraphQL() error {
+	if f.GraphQL == nil {
+		return nil
+	}
+
+	if f.AppSync == nil {
+		f.Log.Debug("skipping GraphQL, no AppSync service")
+		return nil
+	}
+
+	alias, err := f.Service.GetAlias(&lambda.GetAliasInput{
+		FunctionName: &f.FunctionName,
+		Name:         aws.String(f.AliasName()),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	role, err := f.appSyncRole(aws.StringValue(alias.AliasArn))
+	if err != nil {
+		return err
+	}
+
+	if err := f.deployDataSource(aws.StringValue(alias.AliasArn), role); err != nil {
+		return err
+	}
+
+	for _, r := range f.GraphQL.Resolvers {
+		if err := f.deployResolver(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appSyncRole returns the service role of the data source, creating the
+// managed role allowed to invoke `arn` unless Role is configured.
+func (f *Function) appSyncRole(arn string) (string, error) {
+	if f.GraphQL.Role != "" {
+		return f.GraphQL.Role, nil
+	}
+
+	if f.IAM == nil {
+		return "", fmt.Errorf("GraphQL: role is required without an IAM service")
+	}
+
+	name := f.appSyncRoleName()
+
+	res, err := f.IAM.GetRole(&iam.GetRoleInput{RoleName: &name})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == iam.ErrCodeNoSuchEntityException {
+		f.Log.Infof("creating AppSync role %s", name)
+
+		trust, _ := json.Marshal(policyDocument([]string{"sts:AssumeRole"}, nil, "appsync.amazonaws.com"))
+
+		created, err := f.IAM.CreateRole(&iam.CreateRoleInput{
+			RoleName:                 &name,
+			AssumeRolePolicyDocument: aws.String(string(trust)),
+			Description:              aws.String(fmt.Sprintf("AppSync data source of %s managed by apex", f.FunctionName)),
+		})
+
+		if err != nil {
+			return "", err
+		}
+
+		res = &iam.GetRoleOutput{Role: created.Role}
+	} else if err != nil {
+		return "", err
+	}
+
+	policy, _ := json.Marshal(policyDocument([]string{"lambda:InvokeFunction"}, []string{arn}, ""))
+
+	_, err = f.IAM.PutRolePolicy(&iam.PutRolePolicyInput{
+		RoleName:       &name,
+		PolicyName:     aws.String("invoke"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(res.Role.Arn), nil
+}
+
+// policyDocument returns an IAM policy allowing `actions` on `resources`,
+// or a trust policy allowing service `principal` to perform them.
+func policyDocument(actions, resources []string, principal string) map[string]interface{} {
+	stmt := map[string]interface{}{
+		"Effect": "Allow",
+		"Action": actions,
+	}
+
+	if len(resources) > 0 {
+		stmt["Resource"] = resources
+	}
+
+	if principal != "" {
+		stmt["Principal"] = map[string]string{"Service": principal}
+	}
+
+	return map[string]interface{}{
+		"Version":   "2012-10-17",
+		"Statement": []interface{}{stmt},
+	}
+}
+
+// deployDataSource creates or updates the Lambda data source invoking
+// `arn` with service role `role`.
+func (f *Function) deployDataSource(arn, role string) error {
+	api, name := f.GraphQL.API, f.dataSource()
+
+	res, err := f.AppSync.GetDataSource(&appsync.GetDataSourceInput{
+		ApiId: &api,
+		Name:  &name,
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == appsync.ErrCodeNotFoundException {
+		f.Log.Infof("creating data source %s", name)
+
+		_, err := f.AppSync.CreateDataSource(&appsync.CreateDataSourceInput{
+			ApiId:          &api,
+			Name:           &name,
+			Type:           aws.String(appsync.DataSourceTypeAwsLambda),
+			ServiceRoleArn: &role,
+			LambdaConfig:   &appsync.LambdaDataSourceConfig{LambdaFunctionArn: &arn},
+			Description:    aws.String(fmt.Sprintf("%s managed by apex", f.FunctionName)),
+		})
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ds := res.DataSource
+	if aws.StringValue(ds.ServiceRoleArn) == role && ds.LambdaConfig != nil && aws.StringValue(ds.LambdaConfig.LambdaFunctionArn) == arn {
+		f.Log.Debugf("data source %s unchanged", name)
+		return nil
+	}
+
+	f.Log.Infof("updating data source %s", name)
+
+	_, err = f.AppSync.UpdateDataSource(&appsync.UpdateDataSourceInput{
+		ApiId:          &api,
+		Name:           &name,
+		Type:           aws.String(appsync.DataSourceTypeAwsLambda),
+		ServiceRoleArn: &role,
+		LambdaConfig:   &appsync.LambdaDataSourceConfig{LambdaFunctionArn: &arn},
+		Description:    ds.Description,
+	})
+
+	return err
+}
+
+// deployResolver creates or updates resolver `r` of the data source.
+func (f *Function) deployResolver(r *GraphQLResolver) error {
+	api, name := f.GraphQL.API, f.dataSource()
+
+	in := &appsync.CreateResolverInput{
+		ApiId:          &api,
+		TypeName:       &r.Type,
+		FieldName:      &r.Field,
+		DataSourceName: &name,
+		Kind:           aws.String(appsync.ResolverKindUnit),
+	}
+
+	if r.MaxBatchSize > 0 {
+		in.MaxBatchSize = &r.MaxBatchSize
+	}
+
+	if r.Request != "" {
+		request, err := ioutil.ReadFile(filepath.Join(f.Path, r.Request))
+		if err != nil {
+			return err
+		}
+
+		response, err := ioutil.ReadFile(filepath.Join(f.Path, r.Response))
+		if err != nil {
+			return err
+		}
+
+		in.RequestMappingTemplate = aws.String(string(request))
+		in.ResponseMappingTemplate = aws.String(string(response))
+	}
+
+	res, err := f.AppSync.GetResolver(&appsync.GetResolverInput{
+		ApiId:     &api,
+		TypeName:  &r.Type,
+		FieldName: &r.Field,
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == appsync.ErrCodeNotFoundException {
+		f.Log.Infof("creating resolver %s.%s", r.Type, r.Field)
+		_, err := f.AppSync.CreateResolver(in)
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	current := res.Resolver
+	if aws.StringValue(current.DataSourceName) == name &&
+		aws.StringValue(current.Kind) == appsync.ResolverKindUnit &&
+		aws.Int64Value(current.MaxBatchSize) == aws.Int64Value(in.MaxBatchSize) &&
+		aws.StringValue(current.RequestMappingTemplate) == aws.StringValue(in.RequestMappingTemplate) &&
+		aws.StringValue(current.ResponseMappingTemplate) == aws.StringValue(in.ResponseMappingTemplate) {
+		f.Log.Debugf("resolver %s.%s unchanged", r.Type, r.Field)
+		return nil
+	}
+
+	f.Log.Infof("updating resolver %s.%s", r.Type, r.Field)
+
+	_, err = f.AppSync.UpdateResolver(&appsync.UpdateResolverInput{
+		ApiId:                   in.ApiId,
+		TypeName:                in.TypeName,
+		FieldName:               in.FieldName,
+		DataSourceName:          in.DataSourceName,
+		Kind:                    in.Kind,
+		MaxBatchSize:            in.MaxBatchSize,
+		RequestMappingTemplate:  in.RequestMappingTemplate,
+		ResponseMappingTemplate: in.ResponseMappingTemplate,
+	})
+
+	return err
+}
+
+// deleteGraphQL removes the resolvers and data source of the function,
+// and the managed AppSync service role.
+func (f *Function) deleteGraphQL() error {
+	if f.GraphQL == nil || f.AppSync == nil {
+		return nil
+	}
+
+	api, name := f.GraphQL.API, f.dataSource()
+
+	for _, r := range f.GraphQL.Resolvers {
+		f.Log.Infof("deleting resolver %s.%s", r.Type, r.Field)
+
+		_, err := f.AppSync.DeleteResolver(&appsync.DeleteResolverInput{
+			ApiId:     &api,
+			TypeName:  &r.Type,
+			FieldName: &r.Field,
+		})
+
+		if e, ok := err.(awserr.Error); ok && e.Code() == appsync.ErrCodeNotFoundException {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	f.Log.Infof("deleting data source %s", name)
+
+	_, err := f.AppSync.DeleteDataSource(&appsync.DeleteDataSourceInput{
+		ApiId: &api,
+		Name:  &name,
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == appsync.ErrCodeNotFoundException {
+		err = nil
+	}
+
+	if err != nil || f.GraphQL.Role != "" || f.IAM == nil {
+		return err
+	}
+
+	err = f.deleteRole(f.appSyncRoleName())
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == iam.ErrCodeNoSuchEntityException {
+		return nil
+	}
+
+	return err
+}
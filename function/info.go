@@ -0,0 +1,272 @@
+package function
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// lastModifiedFormat is the format of the last modified time reported by Lambda.
+const lastModifiedFormat = "2006-01-02T15:04:05.000-0700"
+
+// Info is the deployed state of a function, as returned by Info. Its
+// fields and their JSON and YAML names are stable, unlike those of the
+// SDK output, so that it may be scripted against.
+type Info struct {
+	// Name of the function in the project.
+	Name string `json:"name" yaml:"name"`
+
+	// FunctionName of the function in Lambda.
+	FunctionName string `json:"functionName" yaml:"functionName"`
+
+	// ARN of the function, unqualified.
+	ARN string `json:"arn" yaml:"arn"`
+
+	// Region and Partition of the function.
+	Region    string `json:"region" yaml:"region"`
+	Partition string `json:"partition" yaml:"partition"`
+
+	// Description of the function.
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+
+	// Runtime and Handler of zip functions.
+	Runtime string `json:"runtime,omitempty" yaml:"runtime,omitempty"`
+	Handler string `json:"handler,omitempty" yaml:"handler,omitempty"`
+
+	// Image URI of image functions.
+	Image string `json:"image,omitempty" yaml:"image,omitempty"`
+
+	// Architecture of the function, "x86_64" or "arm64".
+	Architecture string `json:"architecture" yaml:"architecture"`
+
+	// Memory in MB, Timeout in seconds and ephemeral Storage in MB.
+	Memory  int64 `json:"memory" yaml:"memory"`
+	Timeout int64 `json:"timeout" yaml:"timeout"`
+	Storage int64 `json:"storage,omitempty" yaml:"storage,omitempty"`
+
+	// Role is the execution role ARN.
+	Role string `json:"role" yaml:"role"`
+
+	// CodeSha256 is the base64 checksum of the code, and CodeSize its size in bytes.
+	CodeSha256 string `json:"codeSha256" yaml:"codeSha256"`
+	CodeSize   int64  `json:"codeSize" yaml:"codeSize"`
+
+	// Layers are the layer version ARNs.
+	Layers []string `json:"layers,omitempty" yaml:"layers,omitempty"`
+
+	// Environment are the names of the environment variables, sorted.
+	// Their values are omitted, as they may be secrets.
+	Environment []string `json:"environment,omitempty" yaml:"environment,omitempty"`
+
+	// State and LastUpdateStatus of the function.
+	State            string `json:"state,omitempty" yaml:"state,omitempty"`
+	LastUpdateStatus string `json:"lastUpdateStatus,omitempty" yaml:"lastUpdateStatus,omitempty"`
+
+	// LastModified time of the function.
+	LastModified time.Time `json:"lastModified" yaml:"lastModified"`
+
+	// Concurrency reserved, if any.
+	Concurrency *int64 `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`
+
+	// Aliases of the function and the versions they route to.
+	Aliases []*AliasInfo `json:"aliases" yaml:"aliases"`
+
+	// LogGroup is the CloudWatch Logs group name.
+	LogGroup string `json:"logGroup" yaml:"logGroup"`
+
+	// ConsoleURL is the URL of the function in the AWS console.
+	ConsoleURL string `json:"consoleURL" yaml:"consoleURL"`
+
+	// Tags of the function.
+	Tags map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+
+	// Signature of the deployed code, when the function has
+	// a code signing config.
+	Signature *SignatureInfo `json:"signature,omitempty" yaml:"signature,omitempty"`
+}
+
+// SignatureInfo is the signature of the deployed code of a function.
+type SignatureInfo struct {
+	// Profile is the name of the signing profile, and ProfileVersionARN
+	// the ARN of the profile version the code was signed with.
+	Profile           string `json:"profile,omitempty" yaml:"profile,omitempty"`
+	ProfileVersionARN string `json:"profileVersionArn,omitempty" yaml:"profileVersionArn,omitempty"`
+
+	// JobARN is the ARN of the signing job.
+	JobARN string `json:"jobArn,omitempty" yaml:"jobArn,omitempty"`
+
+	// Expires is the time the signature expires, if ever.
+	Expires *time.Time `json:"expires,omitempty" yaml:"expires,omitempty"`
+
+	// Error is the reason the signature is invalid, such as unsigned,
+	// revoked or expired code, or empty when it is valid.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// AliasInfo is an alias of a function.
+type AliasInfo struct {
+	// Name of the alias.
+	Name string `json:"name" yaml:"name"`
+
+	// Version the alias routes to.
+	Version string `json:"version" yaml:"version"`
+
+	// Weights are the weights of the additional versions the alias
+	// routes to, such as during canary deploys.
+	Weights map[string]float64 `json:"weights,omitempty" yaml:"weights,omitempty"`
+}
+
+// Info returns the deployed state of the function, along with its aliases,
+// derived fields such as its log group and console URL, and the verified
+// signature of its code when it has a code signing config.
+func (f *Function) Info() (*Info, error) {
+	f.Log.Debug("fetching info")
+
+	res, err := f.getFunction()
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	info := newInfo(res)
+	info.Name = f.Name
+	info.LogGroup = f.LogGroupName()
+
+	err = f.Service.ListAliasesPages(&lambda.ListAliasesInput{
+		FunctionName: &f.FunctionName,
+	}, func(page *lambda.ListAliasesOutput, last bool) bool {
+		for _, a := range page.Aliases {
+			info.Aliases = append(info.Aliases, newAliasInfo(a))
+		}
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(info.Aliases, func(i, j int) bool {
+		return info.Aliases[i].Name < info.Aliases[j].Name
+	})
+
+	if sig, err := f.Signature(); sig != nil || err != nil {
+		info.Signature = newSignatureInfo(sig, err)
+	}
+
+	return info, nil
+}
+
+// newInfo returns the info of `res`, without aliases.
+func newInfo(res *lambda.GetFunctionOutput) *Info {
+	cfg := res.Configuration
+
+	info := &Info{
+		FunctionName:     aws.StringValue(cfg.FunctionName),
+		ARN:              aws.StringValue(cfg.FunctionArn),
+		Description:      aws.StringValue(cfg.Description),
+		Runtime:          aws.StringValue(cfg.Runtime),
+		Handler:          aws.StringValue(cfg.Handler),
+		Architecture:     X86_64,
+		Memory:           aws.Int64Value(cfg.MemorySize),
+		Timeout:          aws.Int64Value(cfg.Timeout),
+		Role:             aws.StringValue(cfg.Role),
+		CodeSha256:       aws.StringValue(cfg.CodeSha256),
+		CodeSize:         aws.Int64Value(cfg.CodeSize),
+		Layers:           aws.StringValueSlice(layerArns(cfg.Layers)),
+		State:            aws.StringValue(cfg.State),
+		LastUpdateStatus: aws.StringValue(cfg.LastUpdateStatus),
+		Aliases:          []*AliasInfo{},
+	}
+
+	if parts := strings.Split(info.ARN, ":"); len(parts) > 3 {
+		info.Partition = parts[1]
+		info.Region = parts[3]
+	}
+
+	info.ConsoleURL = consoleURL(info.Partition, info.Region, info.FunctionName)
+
+	if len(cfg.Architectures) > 0 {
+		info.Architecture = aws.StringValue(cfg.Architectures[0])
+	}
+
+	if cfg.EphemeralStorage != nil {
+		info.Storage = aws.Int64Value(cfg.EphemeralStorage.Size)
+	}
+
+	if res.Code != nil && aws.StringValue(cfg.PackageType) == lambda.PackageTypeImage {
+		info.Image = aws.StringValue(res.Code.ImageUri)
+	}
+
+	if cfg.Environment != nil {
+		for name := range cfg.Environment.Variables {
+			info.Environment = append(info.Environment, name)
+		}
+		sort.Strings(info.Environment)
+	}
+
+	if t, err := time.Parse(lastModifiedFormat, aws.StringValue(cfg.LastModified)); err == nil {
+		info.LastModified = t.UTC()
+	}
+
+	if res.Concurrency != nil {
+		info.Concurrency = res.Concurrency.ReservedConcurrentExecutions
+	}
+
+	if len(res.Tags) > 0 {
+		info.Tags = aws.StringValueMap(res.Tags)
+	}
+
+	return info
+}
+
+// newAliasInfo returns the info of alias `a`.
+func newAliasInfo(a *lambda.AliasConfiguration) *AliasInfo {
+	info := &AliasInfo{
+		Name:    aws.StringValue(a.Name),
+		Version: aws.StringValue(a.FunctionVersion),
+	}
+
+	if a.RoutingConfig != nil && len(a.RoutingConfig.AdditionalVersionWeights) > 0 {
+		info.Weights = aws.Float64ValueMap(a.RoutingConfig.AdditionalVersionWeights)
+	}
+
+	return info
+}
+
+// newSignatureInfo returns the info of signature `sig`, which may be
+// nil, and of `err` invalidating it.
+func newSignatureInfo(sig *Signature, err error) *SignatureInfo {
+	info := &SignatureInfo{}
+
+	if sig != nil {
+		info.Profile = sig.Profile
+		info.ProfileVersionARN = sig.ProfileVersionArn
+		info.JobARN = sig.JobArn
+
+		if !sig.Expires.IsZero() {
+			expires := sig.Expires.UTC()
+			info.Expires = &expires
+		}
+	}
+
+	if err != nil {
+		info.Error = err.Error()
+	}
+
+	return info
+}
+
+// consoleURL returns the URL of function `name` in the console of `partition`.
+func consoleURL(partition, region, name string) string {
+	switch partition {
+	case PartitionGovCloud:
+		return fmt.Sprintf("https://console.amazonaws-us-gov.com/lambda/home?region=%s#/functions/%s", region, name)
+	case PartitionChina:
+		return fmt.Sprintf("https://console.amazonaws.cn/lambda/home?region=%s#/functions/%s", region, name)
+	default:
+		return fmt.Sprintf("https://%s.console.aws.amazon.com/lambda/home?region=%s#/functions/%s", region, region, name)
+	}
+}
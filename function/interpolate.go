@@ -0,0 +1,135 @@
+package function
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Resolver resolves the outputs of other functions, such as
+// the functions of a project, referenced in configuration.
+type Resolver interface {
+	Output(function, attr string) (string, error)
+}
+
+// Output attributes which may be referenced.
+const (
+	OutputName    = "name"
+	OutputArn     = "arn"
+	OutputURL     = "url"
+	OutputVersion = "version"
+)
+
+// reference pattern of output references, for example ${function:worker.arn}.
+var reference = regexp.MustCompile(`\$\{function:([^.}]+)\.([^}]+)\}`)
+
+// Output returns the deployed value of `attr`, one of OutputName, OutputArn
+// for the ARN of the current alias, OutputURL, or OutputVersion for the
+// version served by the current alias.
+func (f *Function) Output(attr string) (string, error) {
+	switch attr {
+	case OutputName:
+		return f.FunctionName, nil
+	case OutputArn, OutputVersion:
+		alias, err := f.Service.GetAlias(&lambda.GetAliasInput{
+			FunctionName: &f.FunctionName,
+			Name:         aws.String(f.AliasName()),
+		})
+
+		if err != nil {
+			return "", notFound(err)
+		}
+
+		if attr == OutputVersion {
+			return aws.StringValue(alias.FunctionVersion), nil
+		}
+
+		return aws.StringValue(alias.AliasArn), nil
+	case OutputURL:
+		if f.url != "" {
+			return f.url, nil
+		}
+
+		res, err := f.Service.GetFunctionUrlConfig(&lambda.GetFunctionUrlConfigInput{
+			FunctionName: &f.FunctionName,
+			Qualifier:    aws.String(f.AliasName()),
+		})
+
+		if e, ok := err.(awserr.Error); ok && e.Code() == "ResourceNotFoundException" {
+			return "", fmt.Errorf("function %s has no url", f.Name)
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		return aws.StringValue(res.FunctionUrl), nil
+	default:
+		return "", fmt.Errorf("unknown output %q, expected %s, %s, %s or %s", attr, OutputName, OutputArn, OutputURL, OutputVersion)
+	}
+}
+
+// interpolate replaces output references in `s` with the values
+// resolved by Resolver, returning an error if any cannot be resolved.
+func (f *Function) interpolate(s string) (string, error) {
+	var err error
+
+	s = reference.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+
+		m := reference.FindStringSubmatch(ref)
+
+		if f.Resolver == nil {
+			err = fmt.Errorf("cannot resolve %s outside of a project", ref)
+			return ref
+		}
+
+		var v string
+		v, err = f.Resolver.Output(m[1], m[2])
+		if err != nil {
+			err = fmt.Errorf("resolving %s: %s", ref, err)
+			return ref
+		}
+
+		f.Log.Debugf("resolved %s to %s", ref, v)
+		return v
+	})
+
+	return s, err
+}
+
+// interpolateValue replaces output references in the strings
+// of `v`, a decoded JSON value, returning a copy.
+func (f *Function) interpolateValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return f.interpolate(v)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			e, err := f.interpolateValue(e)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = e
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			e, err := f.interpolateValue(e)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = e
+		}
+		return s, nil
+	default:
+		return v, nil
+	}
+}
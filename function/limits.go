@@ -0,0 +1,69 @@
+package function
+
+import (
+	"archive/zip"
+	"fmt"
+)
+
+// Limits imposed by Lambda.
+const (
+	MaxZipSize       = 50 << 20
+	MaxUnzippedSize  = 250 << 20
+	MaxPayloadSize   = 6 << 20
+	MaxTimeout       = 900
+	MinMemory        = 128
+	MaxMemory        = 10240
+	MaxHandlerLength = 128
+)
+
+// validateLimits checks memory, timeout and handler against Lambda's limits.
+func (f *Function) validateLimits() error {
+	if f.Memory < MinMemory || f.Memory > MaxMemory {
+		return fmt.Errorf("Memory: %dMB is outside the valid range of %d to %dMB", f.Memory, MinMemory, MaxMemory)
+	}
+
+	if f.Timeout < 1 || f.Timeout > MaxTimeout {
+		return fmt.Errorf("Timeout: %ds is outside the valid range of 1 to %ds", f.Timeout, MaxTimeout)
+	}
+
+	h := f.handler()
+
+	if h == "" {
+		return fmt.Errorf("Handler: required by the %s runtime", f.Runtime)
+	}
+
+	if len(h) > MaxHandlerLength {
+		return fmt.Errorf("Handler: %q exceeds %d characters", h, MaxHandlerLength)
+	}
+
+	return nil
+}
+
+// checkSize returns ErrTooLarge when `b` exceeds the zip size limit for
+// direct uploads, or of Lambda@Edge viewer events, or its contents exceed
+// the unzipped size limit.
+func (f *Function) checkSize(b *bundle) error {
+	if f.Edge != nil && f.Edge.viewer() && b.size > MaxEdgeViewerZipSize {
+		return &ErrTooLarge{Function: f.Name, Size: int(b.size), Limit: MaxEdgeViewerZipSize}
+	}
+
+	if f.Signing == nil && f.Artifacts == nil && f.Store == nil && b.size > MaxZipSize {
+		return &ErrTooLarge{Function: f.Name, Size: int(b.size), Limit: MaxZipSize}
+	}
+
+	r, err := zip.NewReader(b.r, b.size)
+	if err != nil {
+		return err
+	}
+
+	var size uint64
+	for _, file := range r.File {
+		size += file.UncompressedSize64
+	}
+
+	if size > MaxUnzippedSize {
+		return &ErrTooLarge{Function: f.Name, Size: int(size), Limit: MaxUnzippedSize}
+	}
+
+	return nil
+}
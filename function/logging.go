@@ -0,0 +1,101 @@
+package function
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// LoggingConfig is the Lambda logging configuration of the function.
+type LoggingConfig struct {
+	// Format of the logs, "Text" (the default) or "JSON".
+	Format string `json:"format"`
+
+	// ApplicationLevel is the minimum level of the function's JSON logs,
+	// one of "TRACE", "DEBUG", "INFO", "WARN", "ERROR" or "FATAL".
+	ApplicationLevel string `json:"applicationLevel"`
+
+	// SystemLevel is the minimum level of Lambda's JSON logs,
+	// one of "DEBUG", "INFO" or "WARN".
+	SystemLevel string `json:"systemLevel"`
+
+	// LogGroup the function logs to, defaulting to /aws/lambda/<function>.
+	// Custom log groups may be shared, and are not deleted with the function.
+	LogGroup string `json:"logGroup"`
+}
+
+// logGroupName pattern for valid log group names.
+var logGroupName = regexp.MustCompile(`^[.\-_/#A-Za-z0-9]{1,512}$`)
+
+// validateLogging checks the format and levels are supported, and
+// levels are only set with the JSON format which they filter.
+func (f *Function) validateLogging() error {
+	l := f.Logging
+	if l == nil {
+		return nil
+	}
+
+	switch l.Format {
+	case "", lambda.LogFormatText, lambda.LogFormatJson:
+	default:
+		return fmt.Errorf("Logging: invalid format %q, must be Text or JSON", l.Format)
+	}
+
+	switch l.ApplicationLevel {
+	case "", lambda.ApplicationLogLevelTrace, lambda.ApplicationLogLevelDebug, lambda.ApplicationLogLevelInfo, lambda.ApplicationLogLevelWarn, lambda.ApplicationLogLevelError, lambda.ApplicationLogLevelFatal:
+	default:
+		return fmt.Errorf("Logging: invalid applicationLevel %q, must be one of TRACE, DEBUG, INFO, WARN, ERROR, FATAL", l.ApplicationLevel)
+	}
+
+	switch l.SystemLevel {
+	case "", lambda.SystemLogLevelDebug, lambda.SystemLogLevelInfo, lambda.SystemLogLevelWarn:
+	default:
+		return fmt.Errorf("Logging: invalid systemLevel %q, must be one of DEBUG, INFO, WARN", l.SystemLevel)
+	}
+
+	if (l.ApplicationLevel != "" || l.SystemLevel != "") && l.Format != lambda.LogFormatJson {
+		return fmt.Errorf("Logging: applicationLevel and systemLevel require the JSON format")
+	}
+
+	if l.LogGroup != "" && !logGroupName.MatchString(l.LogGroup) {
+		return fmt.Errorf("Logging: invalid logGroup %q", l.LogGroup)
+	}
+
+	return nil
+}
+
+// loggingConfig returns the Lambda logging configuration, or nil when
+// not configured so that the current configuration is left unchanged.
+func (f *Function) loggingConfig() *lambda.LoggingConfig {
+	l := f.Logging
+	if l == nil {
+		return nil
+	}
+
+	c := &lambda.LoggingConfig{}
+
+	if l.Format != "" {
+		c.LogFormat = aws.String(l.Format)
+	}
+
+	if l.ApplicationLevel != "" {
+		c.ApplicationLogLevel = aws.String(l.ApplicationLevel)
+	}
+
+	if l.SystemLevel != "" {
+		c.SystemLogLevel = aws.String(l.SystemLevel)
+	}
+
+	if l.LogGroup != "" {
+		c.LogGroup = aws.String(l.LogGroup)
+	}
+
+	return c
+}
+
+// customLogGroup returns true if the function logs to a custom log group.
+func (f *Function) customLogGroup() bool {
+	return f.Logging != nil && f.Logging.LogGroup != ""
+}
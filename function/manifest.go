@@ -0,0 +1,210 @@
+package function
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ManifestFile is the path of the manifest within zips.
+const ManifestFile = ".apex/manifest.json"
+
+// Builder identifies the program building zips, recorded in manifests.
+var Builder = "apex"
+
+// ErrNoManifest is returned when a zip has no manifest.
+var ErrNoManifest = errors.New("no manifest")
+
+// Manifest describes the provenance of a zip, with the SHA-256
+// checksums of its files, embedded when Manifest is enabled.
+type Manifest struct {
+	Function string            `json:"function"`
+	Builder  string            `json:"builder"`
+	Built    time.Time         `json:"built"`
+	Commit   string            `json:"commit,omitempty"`
+	Dirty    bool              `json:"dirty,omitempty"`
+	Files    map[string]string `json:"files"`
+}
+
+// buildTime returns the time recorded in manifests, from SOURCE_DATE_EPOCH
+// or the commit time so rebuilds of unchanged code produce identical zips,
+// defaulting to the current time.
+func (f *Function) buildTime() time.Time {
+	if s := os.Getenv("SOURCE_DATE_EPOCH"); s != "" {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Unix(n, 0).UTC()
+		}
+	}
+
+	if f.Git != nil && !f.Git.Time.IsZero() {
+		return f.Git.Time
+	}
+
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// addManifest returns a copy of `b` with a manifest of its files added.
+func (f *Function) addManifest(b *bundle) (*bundle, error) {
+	r, err := zip.NewReader(b.r, b.size)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Function: f.Name,
+		Builder:  Builder,
+		Built:    f.buildTime(),
+	}
+
+	if f.Git != nil {
+		m.Commit = f.Git.Commit
+		m.Dirty = f.Git.Dirty
+	}
+
+	if m.Files, err = checksums(r); err != nil {
+		return nil, err
+	}
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := spool(func(dst io.Writer) error {
+		w := zip.NewWriter(dst)
+
+		for _, file := range r.File {
+			if err := w.Copy(file); err != nil {
+				return err
+			}
+		}
+
+		file, err := w.CreateHeader(&zip.FileHeader{Name: ManifestFile, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+
+		if _, err := file.Write(manifest); err != nil {
+			return err
+		}
+
+		return w.Close()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	f.Log.Debugf("added manifest of %d files", len(m.Files))
+	return out, nil
+}
+
+// ReadManifest returns the manifest of zip `b`, verifying the checksums
+// of its files, or ErrNoManifest when it has none.
+func ReadManifest(b []byte) (*Manifest, error) {
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+
+	var m *Manifest
+
+	for _, file := range r.File {
+		if file.Name != ManifestFile {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.NewDecoder(rc).Decode(&m)
+		rc.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("decoding manifest: %s", err)
+		}
+	}
+
+	if m == nil {
+		return nil, ErrNoManifest
+	}
+
+	sums, err := checksums(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range m.Files {
+		names = append(names, name)
+	}
+	for name := range sums {
+		if _, ok := m.Files[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		switch want, got := m.Files[name], sums[name]; {
+		case want == "":
+			return m, fmt.Errorf("file %s is not in the manifest", name)
+		case got == "":
+			return m, fmt.Errorf("file %s of the manifest is missing", name)
+		case want != got:
+			return m, fmt.Errorf("file %s checksum %s does not match %s", name, got, want)
+		}
+	}
+
+	return m, nil
+}
+
+// DeployedManifest returns the verified manifest of the zip deployed
+// to `qualifier`, such as the function's alias.
+func (f *Function) DeployedManifest(qualifier string) (*Manifest, error) {
+	b, err := f.Artifact(qualifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadManifest(b)
+}
+
+// checksums returns the SHA-256 checksums of the files of `r`, excluding the manifest.
+func checksums(r *zip.Reader) (map[string]string, error) {
+	sums := make(map[string]string)
+
+	for _, file := range r.File {
+		if file.Name == ManifestFile || file.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		h := sha256.New()
+		_, err = io.Copy(h, rc)
+		rc.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %s", file.Name, err)
+		}
+
+		sums[file.Name] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return sums, nil
+}
@@ -0,0 +1,64 @@
+package function
+
+// DeployEvent is an event emitted while deploying a function.
+type DeployEvent interface {
+	deployEvent()
+}
+
+// BuildStarted is emitted before the function's zip is built.
+type BuildStarted struct {
+	Function string
+}
+
+// ZipCreated is emitted once the function's zip is built.
+type ZipCreated struct {
+	Function string
+	Size     int
+}
+
+// UploadStarted is emitted before the zip is uploaded to create or update the function.
+type UploadStarted struct {
+	Function string
+	Size     int
+}
+
+// VersionPublished is emitted when a new function version is published.
+type VersionPublished struct {
+	Function string
+	Version  string
+}
+
+// AliasUpdated is emitted when an alias is pointed at a version.
+type AliasUpdated struct {
+	Function string
+	Alias    string
+	Version  string
+}
+
+func (BuildStarted) deployEvent()     {}
+func (ZipCreated) deployEvent()       {}
+func (UploadStarted) deployEvent()    {}
+func (VersionPublished) deployEvent() {}
+func (AliasUpdated) deployEvent()     {}
+
+// DeployObserver receives deploy events, allowing programs embedding
+// apex to report progress without parsing log output. Observers
+// may be called concurrently when deploying multiple functions.
+type DeployObserver interface {
+	Observe(DeployEvent)
+}
+
+// DeployObserverFunc implements DeployObserver.
+type DeployObserverFunc func(DeployEvent)
+
+// Observe calls the function.
+func (fn DeployObserverFunc) Observe(e DeployEvent) {
+	fn(e)
+}
+
+// emit sends `e` to the observer, if any.
+func (f *Function) emit(e DeployEvent) {
+	if f.Observer != nil {
+		f.Observer.Observe(e)
+	}
+}
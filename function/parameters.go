@@ -0,0 +1,60 @@
+package function
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// DeployParameters writes the function ARN, the current alias ARN and the
+// version it serves to SSM parameters "arn", "alias-arn" and "version" under
+// ParameterPath, allowing other systems to discover the deployed function.
+func (f *Function) DeployParameters() error {
+	if f.ParameterPath == "" {
+		return nil
+	}
+
+	if f.SSM == nil {
+		f.Log.Debug("skipping parameters, no SSM service")
+		return nil
+	}
+
+	alias, err := f.Service.GetAlias(&lambda.GetAliasInput{
+		FunctionName: &f.FunctionName,
+		Name:         aws.String(f.AliasName()),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	aliasArn := aws.StringValue(alias.AliasArn)
+
+	params := []struct {
+		name, value string
+	}{
+		{"arn", strings.TrimSuffix(aliasArn, ":"+f.AliasName())},
+		{"alias-arn", aliasArn},
+		{"version", aws.StringValue(alias.FunctionVersion)},
+	}
+
+	for _, p := range params {
+		name := strings.TrimSuffix(f.ParameterPath, "/") + "/" + p.name
+		f.Log.Debugf("writing parameter %s", name)
+
+		_, err := f.SSM.PutParameter(&ssm.PutParameterInput{
+			Name:      &name,
+			Value:     aws.String(p.value),
+			Type:      aws.String(ssm.ParameterTypeString),
+			Overwrite: aws.Bool(true),
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
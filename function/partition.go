@@ -0,0 +1,78 @@
+package function
+
+import (
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+// Partitions of AWS regions, which have separate accounts and ARNs.
+const (
+	PartitionAWS      = endpoints.AwsPartitionID
+	PartitionGovCloud = endpoints.AwsUsGovPartitionID
+	PartitionChina    = endpoints.AwsCnPartitionID
+)
+
+// Partitions are the supported partitions.
+var Partitions = []string{PartitionAWS, PartitionGovCloud, PartitionChina}
+
+// PartitionOf returns the partition of `region`, such as "aws-us-gov" for
+// "us-gov-west-1", defaulting to "aws".
+func PartitionOf(region string) string {
+	if p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		return p.ID()
+	}
+	return PartitionAWS
+}
+
+// Price is the public Lambda pricing of a partition.
+type Price struct {
+	// Currency the partition is billed in, such as "USD".
+	Currency string
+
+	// Request is the price of a request.
+	Request float64
+
+	// GBSecond is the price of a GB-second of compute by architecture.
+	GBSecond map[string]float64
+}
+
+// Prices are the public Lambda prices by partition, of us-east-1,
+// us-gov-west-1 and cn-north-1 respectively. China is billed in CNY.
+var Prices = map[string]*Price{
+	PartitionAWS: {
+		Currency: "USD",
+		Request:  RequestPrice,
+		GBSecond: GBSecondPrice,
+	},
+	PartitionGovCloud: {
+		Currency: "USD",
+		Request:  0.20 / 1e6,
+		GBSecond: map[string]float64{
+			X86_64: 0.0000200,
+			Arm64:  0.0000160,
+		},
+	},
+	PartitionChina: {
+		Currency: "CNY",
+		Request:  1.36 / 1e6,
+		GBSecond: map[string]float64{
+			X86_64: 0.000113477,
+			Arm64:  0.0000907816,
+		},
+	},
+}
+
+// PriceOf returns the prices of `partition`, defaulting to those of "aws".
+func PriceOf(partition string) *Price {
+	if p, ok := Prices[partition]; ok {
+		return p
+	}
+	return Prices[PartitionAWS]
+}
+
+// partition returns the configured Partition, defaulting to that of the region.
+func (f *Function) partition() string {
+	if f.Partition == "" {
+		return PartitionOf(f.Region)
+	}
+	return f.Partition
+}
@@ -0,0 +1,358 @@
+package function
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Permission is a resource-based policy statement granting a principal,
+// such as another account or an AWS service, access to the function.
+type Permission struct {
+	// Name of the statement, unique per function.
+	Name string `json:"name"`
+
+	// Principal is an account id, IAM ARN, or service such as "s3.amazonaws.com".
+	Principal string `json:"principal"`
+
+	// Action granted, defaulting to "lambda:InvokeFunction".
+	Action string `json:"action"`
+
+	// SourceArn restricts service principals to a resource.
+	SourceArn string `json:"sourceArn"`
+
+	// SourceAccount restricts service principals to an account.
+	SourceAccount string `json:"sourceAccount"`
+
+	// PrincipalOrgID restricts the principal to an organization.
+	PrincipalOrgID string `json:"principalOrgId"`
+
+	// EventSourceToken restricts Alexa principals to a skill id.
+	EventSourceToken string `json:"eventSourceToken,omitempty"`
+
+	// Preset is the name of a common invoker in PermissionPresets,
+	// setting the principal, action and source of the statement.
+	Preset string `json:"preset,omitempty"`
+
+	// ID of the resource of the preset, such as the user pool id
+	// of "cognito", or the skill id of "alexa-skill".
+	ID string `json:"id,omitempty"`
+}
+
+// PermissionPreset is a common invoker of functions. The SourceArn template
+// may reference the {partition}, {region} and {account} of the function,
+// and the {id} of the resource.
+type PermissionPreset struct {
+	Principal string
+	Action    string
+	SourceArn string
+	Token     bool
+}
+
+// PermissionPresets are the presets of Permissions.
+var PermissionPresets = map[string]PermissionPreset{
+	// Cognito user pool triggers, with the id of the user pool.
+	"cognito": {
+		Principal: "cognito-idp.amazonaws.com",
+		SourceArn: "arn:{partition}:cognito-idp:{region}:{account}:userpool/{id}",
+	},
+
+	// Alexa custom skills, with the skill id.
+	"alexa-skill": {
+		Principal: "alexa-appkit.amazon.com",
+		Token:     true,
+	},
+
+	// Alexa smart home skills, with the skill id.
+	"alexa-smart-home": {
+		Principal: "alexa-connectedhome.amazon.com",
+		Token:     true,
+	},
+
+	// Lex V2 bots, with the "BOTID/ALIASID" of the bot alias.
+	"lex": {
+		Principal: "lexv2.amazonaws.com",
+		SourceArn: "arn:{partition}:lex:{region}:{account}:bot-alias/{id}",
+	},
+
+	// Lex V1 bots, with the name of the intent.
+	"lex-v1": {
+		Principal: "lex.amazonaws.com",
+		SourceArn: "arn:{partition}:lex:{region}:{account}:intent:{id}:*",
+	},
+
+	// CloudFront distributions with the function URL as an origin
+	// using origin access control, with the distribution id.
+	"cloudfront": {
+		Principal: "cloudfront.amazonaws.com",
+		Action:    "lambda:InvokeFunctionUrl",
+		SourceArn: "arn:{partition}:cloudfront::{account}:distribution/{id}",
+	},
+}
+
+// permissionPrefix is the statement id prefix of managed permissions,
+// distinct from the "apex-" prefix of event rule statements.
+const permissionPrefix = "apex."
+
+// permissionName pattern for valid permission names.
+var permissionName = regexp.MustCompile(`^[\-_A-Za-z0-9]+$`)
+
+// action returns the action granted.
+func (p *Permission) action() string {
+	if p.Action == "" {
+		return "lambda:InvokeFunction"
+	}
+	return p.Action
+}
+
+// statementID returns the statement id of the permission, including a
+// checksum of its fields so that changes replace the statement.
+func (p *Permission) statementID() string {
+	b, _ := json.Marshal(p)
+	sum := sha256.Sum256(b)
+	return permissionPrefix + p.Name + "." + hex.EncodeToString(sum[:4])
+}
+
+// validatePermissions checks permissions are named uniquely and have a principal.
+func (f *Function) validatePermissions() error {
+	names := make(map[string]bool)
+
+	for _, p := range f.Permissions {
+		if !permissionName.MatchString(p.Name) {
+			return fmt.Errorf("Permissions: invalid name %q", p.Name)
+		}
+
+		if names[p.Name] {
+			return fmt.Errorf("Permissions: duplicate name %q", p.Name)
+		}
+		names[p.Name] = true
+
+		if p.Preset != "" {
+			if err := p.validatePreset(); err != nil {
+				return fmt.Errorf("Permissions: %q %s", p.Name, err)
+			}
+			continue
+		}
+
+		if p.Principal == "" {
+			return fmt.Errorf("Permissions: %q requires a principal", p.Name)
+		}
+	}
+
+	return nil
+}
+
+// validatePreset checks the preset exists, has the id of its
+// resource, and the fields it sets are not configured.
+func (p *Permission) validatePreset() error {
+	if _, ok := PermissionPresets[p.Preset]; !ok {
+		var names []string
+		for name := range PermissionPresets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown preset %q, must be one of %s", p.Preset, strings.Join(names, ", "))
+	}
+
+	if p.ID == "" {
+		return fmt.Errorf("preset %q requires an id", p.Preset)
+	}
+
+	if p.Principal != "" || p.Action != "" || p.SourceArn != "" || p.EventSourceToken != "" {
+		return fmt.Errorf("preset %q sets the principal, action and source", p.Preset)
+	}
+
+	return nil
+}
+
+// resolve returns the permission of the preset, if any, with
+// the partition, region and account of function ARN `arn`.
+func (p *Permission) resolve(functionArn string) (*Permission, error) {
+	if p.Preset == "" {
+		return p, nil
+	}
+
+	a, err := arn.Parse(functionArn)
+	if err != nil {
+		return nil, err
+	}
+
+	preset := PermissionPresets[p.Preset]
+
+	r := *p
+	r.Principal = preset.Principal
+	r.Action = preset.Action
+
+	if preset.Token {
+		r.EventSourceToken = p.ID
+	}
+
+	if preset.SourceArn != "" {
+		r.SourceArn = strings.NewReplacer(
+			"{partition}", a.Partition,
+			"{region}", a.Region,
+			"{account}", a.AccountID,
+			"{id}", p.ID,
+		).Replace(preset.SourceArn)
+	}
+
+	return &r, nil
+}
+
+// DeployPermissions reconciles the resource-based policy of the current
+// alias with Permissions, adding new or changed statements and removing
+// managed statements no longer configured. Statements added by event
+// rules, the function URL, or outside of apex are left untouched.
+func (f *Function) DeployPermissions() error {
+	existing, err := f.policyStatements()
+	if err != nil {
+		return err
+	}
+
+	configured := make(map[string]bool)
+
+	var functionArn string
+
+	for _, p := range f.Permissions {
+		id := p.statementID()
+		configured[id] = true
+
+		if existing[id] {
+			continue
+		}
+
+		if p.Preset != "" && functionArn == "" {
+			alias, err := f.Service.GetAlias(&lambda.GetAliasInput{
+				FunctionName: &f.FunctionName,
+				Name:         aws.String(f.AliasName()),
+			})
+
+			if err != nil {
+				return err
+			}
+
+			functionArn = aws.StringValue(alias.AliasArn)
+		}
+
+		p, err := p.resolve(functionArn)
+		if err != nil {
+			return err
+		}
+
+		if err := f.addPermission(id, p); err != nil {
+			return err
+		}
+	}
+
+	var stale []string
+	for id := range existing {
+		if strings.HasPrefix(id, permissionPrefix) && !configured[id] {
+			stale = append(stale, id)
+		}
+	}
+	sort.Strings(stale)
+
+	for _, id := range stale {
+		f.Log.Infof("removing permission %s", id)
+
+		_, err := f.Service.RemovePermission(&lambda.RemovePermissionInput{
+			FunctionName: &f.FunctionName,
+			Qualifier:    aws.String(f.AliasName()),
+			StatementId:  aws.String(id),
+		})
+
+		if e, ok := err.(awserr.Error); ok && e.Code() == "ResourceNotFoundException" {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addPermission adds statement `id` granting `p`.
+func (f *Function) addPermission(id string, p *Permission) error {
+	f.Log.Infof("adding permission %s for %s", p.Name, p.Principal)
+
+	in := &lambda.AddPermissionInput{
+		FunctionName: &f.FunctionName,
+		Qualifier:    aws.String(f.AliasName()),
+		StatementId:  aws.String(id),
+		Action:       aws.String(p.action()),
+		Principal:    aws.String(p.Principal),
+	}
+
+	if p.SourceArn != "" {
+		in.SourceArn = aws.String(p.SourceArn)
+	}
+
+	if p.SourceAccount != "" {
+		in.SourceAccount = aws.String(p.SourceAccount)
+	}
+
+	if p.PrincipalOrgID != "" {
+		in.PrincipalOrgID = aws.String(p.PrincipalOrgID)
+	}
+
+	if p.EventSourceToken != "" {
+		in.EventSourceToken = aws.String(p.EventSourceToken)
+	}
+
+	_, err := f.Service.AddPermission(in)
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == "ResourceConflictException" {
+		return nil
+	}
+
+	return err
+}
+
+// policyStatements returns the statement ids of the current alias policy.
+func (f *Function) policyStatements() (map[string]bool, error) {
+	res, err := f.Service.GetPolicy(&lambda.GetPolicyInput{
+		FunctionName: &f.FunctionName,
+		Qualifier:    aws.String(f.AliasName()),
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == "ResourceNotFoundException" {
+		return map[string]bool{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePolicy(aws.StringValue(res.Policy))
+}
+
+// parsePolicy returns the statement ids of policy document `s`.
+func parsePolicy(s string) (map[string]bool, error) {
+	var policy struct {
+		Statement []struct {
+			Sid string
+		}
+	}
+
+	if err := json.Unmarshal([]byte(s), &policy); err != nil {
+		return nil, fmt.Errorf("error parsing policy: %s", err)
+	}
+
+	ids := make(map[string]bool)
+	for _, stmt := range policy.Statement {
+		ids[stmt.Sid] = true
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,90 @@
+package function
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Artifact returns the zip deployed to `qualifier`, such as the function's alias,
+// verified against the checksum Lambda reports for it.
+func (f *Function) Artifact(qualifier string) ([]byte, error) {
+	zip, err := f.download(qualifier)
+	if err != nil {
+		return nil, err
+	}
+	defer zip.Close()
+
+	return zip.read()
+}
+
+// download writes the zip deployed to `qualifier` to a temporary file,
+// verified against the checksum Lambda reports for it.
+func (f *Function) download(qualifier string) (*bundle, error) {
+	res, err := f.Service.GetFunction(&lambda.GetFunctionInput{
+		FunctionName: &f.FunctionName,
+		Qualifier:    &qualifier,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return f.fetch(res, qualifier)
+}
+
+// fetch writes the zip of `res`, deployed to `qualifier`, to a temporary
+// file, verified against the checksum Lambda reports for it.
+func (f *Function) fetch(res *lambda.GetFunctionOutput, qualifier string) (*bundle, error) {
+	if res.Code == nil || res.Code.Location == nil {
+		return nil, fmt.Errorf("no code location for %s:%s", f.FunctionName, qualifier)
+	}
+
+	f.Log.Debugf("downloading %s:%s", f.FunctionName, qualifier)
+
+	r, err := http.Get(*res.Code.Location)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode >= 300 {
+		return nil, fmt.Errorf("downloading code: %s", r.Status)
+	}
+
+	zip, err := spool(func(w io.Writer) error {
+		_, err := io.Copy(w, r.Body)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if sum := zip.checksum(); sum != aws.StringValue(res.Configuration.CodeSha256) {
+		zip.Close()
+		return nil, fmt.Errorf("downloaded code checksum %s does not match %s", sum, aws.StringValue(res.Configuration.CodeSha256))
+	}
+
+	return zip, nil
+}
+
+// Promote deploys the exact zip serving the current alias of `source`,
+// typically the same function in another stage, account or region, in
+// place of building the function, followed by its own configuration.
+func (f *Function) Promote(source *Function) error {
+	zip, err := source.download(source.AliasName())
+	if err != nil {
+		return err
+	}
+	defer zip.Close()
+
+	f.Log.Infof("promoting %s (%s)", source.FunctionName, zip.checksum())
+
+	return f.deploy(func() error {
+		return f.deployZip(zip)
+	})
+}
@@ -0,0 +1,26 @@
+package function
+
+import (
+	"errors"
+	"time"
+
+	"github.com/apex/apex/logs"
+)
+
+// Query runs Logs Insights `query`, or the name of one of logs.Queries,
+// against the function's log group for the period `since` ago until now.
+func (f *Function) Query(query string, since time.Duration) ([]logs.Row, error) {
+	if f.CloudWatchLogs == nil {
+		return nil, errors.New("querying logs requires the CloudWatch Logs service")
+	}
+
+	q := &logs.Query{
+		Service:       f.CloudWatchLogs,
+		Log:           f.Log,
+		LogGroupNames: []string{f.LogGroupName()},
+		Query:         query,
+		Start:         time.Now().Add(-since),
+	}
+
+	return q.Run()
+}
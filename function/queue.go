@@ -0,0 +1,318 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// QueuePolicy prefixes the name of the inline policy of the function
+// role granting access to its queue, suffixed by the function name.
+const QueuePolicy = "apex-queue"
+
+// Queue defaults.
+const (
+	DefaultMaxReceiveCount     = 5
+	DefaultDeadLetterRetention = 1209600
+	MaxVisibilityTimeout       = 43200
+)
+
+// queueActions are the actions granted to the function on its queue,
+// required by the event source mapping.
+var queueActions = []string{
+	"sqs:ChangeMessageVisibility",
+	"sqs:DeleteMessage",
+	"sqs:GetQueueAttributes",
+	"sqs:ReceiveMessage",
+}
+
+// Queue is an SQS queue of the function, created or updated on deploy along
+// with its dead-letter queue, and mapped as an event source of the function.
+// Queues are never deleted by apex, as they hold messages.
+type Queue struct {
+	// Name of the queue, defaulting to the function name. The dead-letter
+	// queue is named after it with a "-dlq" suffix.
+	Name string `json:"name"`
+
+	// FIFO creates first-in-first-out queues, adding the ".fifo" suffix.
+	FIFO bool `json:"fifo"`
+
+	// VisibilityTimeout in seconds, defaulting to six times
+	// the function timeout as recommended by Lambda.
+	VisibilityTimeout int64 `json:"visibilityTimeout"`
+
+	// Retention of messages in seconds, defaulting to that of SQS.
+	Retention int64 `json:"retention"`
+
+	// MaxReceiveCount is the number of failed receives after which
+	// messages are moved to the dead-letter queue, defaulting to 5.
+	MaxReceiveCount int64 `json:"maxReceiveCount"`
+
+	// BatchSize is the maximum number of messages per batch.
+	BatchSize int64 `json:"batchSize"`
+
+	// BatchingWindow is the maximum number of seconds to gather
+	// messages before invoking the function, up to 300.
+	BatchingWindow int64 `json:"batchingWindow"`
+
+	// ReportFailures lets the function report the failed messages of a
+	// batch, so that only those are retried.
+	ReportFailures bool `json:"reportFailures"`
+}
+
+// queueName pattern for valid queue names, without the ".fifo" suffix.
+var queueName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,71}$`)
+
+// redrivePolicy is the redrive policy attribute of SQS queues.
+type redrivePolicy struct {
+	DeadLetterTargetArn string `json:"deadLetterTargetArn"`
+	MaxReceiveCount     int64  `json:"maxReceiveCount"`
+}
+
+// queueNames returns the names of the queue and its dead-letter queue.
+func (f *Function) queueNames() (queue, dlq string) {
+	name := f.Queue.Name
+	if name == "" {
+		name = f.FunctionName
+	}
+
+	queue, dlq = name, name+"-dlq"
+
+	if f.Queue.FIFO {
+		queue, dlq = queue+".fifo", dlq+".fifo"
+	}
+
+	return
+}
+
+// visibilityTimeout returns the visibility timeout of the queue.
+func (f *Function) visibilityTimeout() int64 {
+	if n := f.Queue.VisibilityTimeout; n != 0 {
+		return n
+	}
+
+	if n := 6 * f.Timeout; n < MaxVisibilityTimeout {
+		return n
+	}
+
+	return MaxVisibilityTimeout
+}
+
+// maxReceiveCount returns the receive count of the redrive policy.
+func (f *Function) maxReceiveCount() int64 {
+	if f.Queue.MaxReceiveCount == 0 {
+		return DefaultMaxReceiveCount
+	}
+	return f.Queue.MaxReceiveCount
+}
+
+// validateQueue checks the queue name and options are within the limits
+// of SQS and Lambda, and messages remain invisible while processed.
+func (f *Function) validateQueue() error {
+	q := f.Queue
+	if q == nil {
+		return nil
+	}
+
+	if q.Name != "" && !queueName.MatchString(q.Name) {
+		return fmt.Errorf("Queue: invalid name %q", q.Name)
+	}
+
+	if n := q.VisibilityTimeout; n < 0 || n > MaxVisibilityTimeout {
+		return fmt.Errorf("Queue: visibilityTimeout must be between 0 and %d seconds", MaxVisibilityTimeout)
+	}
+
+	if n := f.visibilityTimeout(); n < f.Timeout {
+		return fmt.Errorf("Queue: visibilityTimeout of %ds is less than the %ds function timeout", n, f.Timeout)
+	}
+
+	if n := q.Retention; n != 0 && (n < 60 || n > DefaultDeadLetterRetention) {
+		return fmt.Errorf("Queue: retention must be between 60 and %d seconds", DefaultDeadLetterRetention)
+	}
+
+	if n := q.MaxReceiveCount; n < 0 || n > 1000 {
+		return fmt.Errorf("Queue: maxReceiveCount must be between 1 and 1000")
+	}
+
+	if q.BatchSize < 0 || q.BatchSize > 10000 || q.FIFO && q.BatchSize > 10 {
+		return fmt.Errorf("Queue: batchSize must be between 1 and 10000, or 10 for FIFO queues")
+	}
+
+	if q.BatchingWindow < 0 || q.BatchingWindow > 300 {
+		return fmt.Errorf("Queue: batchingWindow must be between 0 and 300 seconds")
+	}
+
+	if q.BatchSize > 10 && q.BatchingWindow == 0 {
+		return fmt.Errorf("Queue: batchSize over 10 requires a batchingWindow")
+	}
+
+	return nil
+}
+
+// DeployQueue creates or updates the queue of the function and its
+// dead-letter queue, and grants the function role access to the queue.
+// Queues are deployed before the code, so that the permissions of the
+// role have propagated by the time DeployEventSources maps the queue.
+func (f *Function) DeployQueue() error {
+	if f.Queue == nil {
+		return nil
+	}
+
+	if f.SQS == nil {
+		f.Log.Debug("skipping queue, no SQS service")
+		return nil
+	}
+
+	name, dlqName := f.queueNames()
+
+	dlq, err := f.deployQueue(dlqName, map[string]string{
+		sqs.QueueAttributeNameMessageRetentionPeriod: strconv.Itoa(DefaultDeadLetterRetention),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	redrive, _ := json.Marshal(redrivePolicy{
+		DeadLetterTargetArn: dlq,
+		MaxReceiveCount:     f.maxReceiveCount(),
+	})
+
+	attrs := map[string]string{
+		sqs.QueueAttributeNameVisibilityTimeout: strconv.FormatInt(f.visibilityTimeout(), 10),
+		sqs.QueueAttributeNameRedrivePolicy:     string(redrive),
+	}
+
+	if f.Queue.Retention != 0 {
+		attrs[sqs.QueueAttributeNameMessageRetentionPeriod] = strconv.FormatInt(f.Queue.Retention, 10)
+	}
+
+	arn, err := f.deployQueue(name, attrs)
+	if err != nil {
+		return err
+	}
+
+	f.queueSource = &EventSource{
+		ARN:            arn,
+		BatchSize:      f.Queue.BatchSize,
+		BatchingWindow: f.Queue.BatchingWindow,
+		ReportFailures: f.Queue.ReportFailures,
+	}
+
+	return f.deployQueuePolicy(arn)
+}
+
+// deployQueue creates queue `name` with `attrs`, or updates the attributes
+// of the existing queue which differ, returning its ARN.
+func (f *Function) deployQueue(name string, attrs map[string]string) (string, error) {
+	res, err := f.SQS.GetQueueUrl(&sqs.GetQueueUrlInput{
+		QueueName: &name,
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == sqs.ErrCodeQueueDoesNotExist {
+		f.Log.Infof("creating queue %s", name)
+
+		create := aws.StringMap(attrs)
+		if f.Queue.FIFO {
+			create[sqs.QueueAttributeNameFifoQueue] = aws.String("true")
+		}
+
+		created, err := f.SQS.CreateQueue(&sqs.CreateQueueInput{
+			QueueName:  &name,
+			Attributes: create,
+		})
+
+		if err != nil {
+			return "", err
+		}
+
+		res = &sqs.GetQueueUrlOutput{QueueUrl: created.QueueUrl}
+	} else if err != nil {
+		return "", err
+	}
+
+	current, err := f.SQS.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       res.QueueUrl,
+		AttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameAll}),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	remote := aws.StringValueMap(current.Attributes)
+	changed := make(map[string]string)
+
+	for k, v := range attrs {
+		if !sameQueueAttribute(k, remote[k], v) {
+			changed[k] = v
+		}
+	}
+
+	if len(changed) == 0 {
+		f.Log.Debugf("queue %s unchanged", name)
+		return remote[sqs.QueueAttributeNameQueueArn], nil
+	}
+
+	f.Log.Infof("updating queue %s %s", name, strings.Join(sortedKeys(changed), ", "))
+
+	_, err = f.SQS.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		QueueUrl:   res.QueueUrl,
+		Attributes: aws.StringMap(changed),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return remote[sqs.QueueAttributeNameQueueArn], nil
+}
+
+// sameQueueAttribute returns true if values `a` and `b` of queue
+// attribute `name` are equal, comparing redrive policies as JSON,
+// whose maxReceiveCount SQS may return as a string.
+func sameQueueAttribute(name, a, b string) bool {
+	if name != sqs.QueueAttributeNameRedrivePolicy {
+		return a == b
+	}
+
+	var pa, pb map[string]interface{}
+	if json.Unmarshal([]byte(a), &pa) != nil || json.Unmarshal([]byte(b), &pb) != nil || len(pa) != len(pb) {
+		return false
+	}
+
+	for k, v := range pa {
+		if fmt.Sprint(v) != fmt.Sprint(pb[k]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// deployQueuePolicy grants the function role access to queue `arn`.
+func (f *Function) deployQueuePolicy(arn string) error {
+	if f.IAM == nil {
+		f.Log.Debug("skipping queue policy, no IAM service")
+		return nil
+	}
+
+	policy, _ := json.Marshal(policyDocument(queueActions, []string{arn}, ""))
+
+	f.Log.Debugf("putting queue policy of role %s", f.roleName())
+
+	_, err := f.IAM.PutRolePolicy(&iam.PutRolePolicyInput{
+		RoleName:       aws.String(f.roleName()),
+		PolicyName:     aws.String(QueuePolicy + "-" + f.FunctionName),
+		PolicyDocument: aws.String(string(policy)),
+	})
+
+	return err
+}
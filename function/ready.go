@@ -0,0 +1,69 @@
+package function
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// ReadyTimeout is how long to wait for a function to become ready after
+// it is created or updated, such as while VPC networking is attached.
+var ReadyTimeout = 5 * time.Minute
+
+// readyInterval is the initial interval between readiness checks,
+// doubled after each check up to readyMaxInterval.
+var readyInterval = time.Second
+
+// readyMaxInterval is the maximum interval between readiness checks.
+const readyMaxInterval = 10 * time.Second
+
+// ready returns true when `cfg` is active and its last update succeeded.
+// Unset fields are considered ready, as reported by dry-run stubs.
+func ready(cfg *lambda.FunctionConfiguration) bool {
+	state := aws.StringValue(cfg.State)
+	update := aws.StringValue(cfg.LastUpdateStatus)
+	return (state == "" || state == lambda.StateActive) && (update == "" || update == lambda.LastUpdateStatusSuccessful)
+}
+
+// waitReady polls the function configuration, starting with `cfg`, until it
+// is ready, returning an error if it fails or the Wait deadline, ReadyTimeout
+// by default, is exceeded.
+func (f *Function) waitReady(cfg *lambda.FunctionConfiguration) error {
+	deadline, expired := f.deadlineOf(StepWait, ReadyTimeout)
+	interval := readyInterval
+
+	for !ready(cfg) {
+		if aws.StringValue(cfg.State) == lambda.StateFailed {
+			return fmt.Errorf("function failed: %s", aws.StringValue(cfg.StateReason))
+		}
+
+		if aws.StringValue(cfg.LastUpdateStatus) == lambda.LastUpdateStatusFailed {
+			return fmt.Errorf("function update failed: %s", aws.StringValue(cfg.LastUpdateStatusReason))
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			expired.Reason = fmt.Sprintf("waiting for function to become ready (state %s, last update %s)", aws.StringValue(cfg.State), aws.StringValue(cfg.LastUpdateStatus))
+			return expired
+		}
+
+		f.Log.Debugf("waiting %s for function to become ready (state %s, last update %s)", interval, aws.StringValue(cfg.State), aws.StringValue(cfg.LastUpdateStatus))
+		time.Sleep(interval)
+
+		if interval *= 2; interval > readyMaxInterval {
+			interval = readyMaxInterval
+		}
+
+		var err error
+		cfg, err = f.Service.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+			FunctionName: &f.FunctionName,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
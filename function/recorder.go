@@ -0,0 +1,62 @@
+package function
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Invocation is a recorded synchronous invocation of a function.
+type Invocation struct {
+	// Function is the name of the function within the project.
+	Function string `json:"function"`
+
+	// Qualifier invoked, and the Version which executed it.
+	Qualifier string `json:"qualifier"`
+	Version   string `json:"version"`
+
+	// Event is the payload of the invocation.
+	Event []byte `json:"event"`
+
+	// Reply of the function, or the Error message when it failed.
+	Reply []byte `json:"reply,omitempty"`
+	Error string `json:"error,omitempty"`
+
+	Duration time.Duration `json:"duration"`
+	Time     time.Time     `json:"time"`
+}
+
+// Recorder records invocations, for example to replay
+// them against new versions for regression testing.
+type Recorder interface {
+	Record(*Invocation) error
+}
+
+// recordInvocation records invocation `in` with its result `res`
+// and function error `ierr`, if any, with the Recorder. Failures
+// to record are logged, as they must not fail the invocation.
+func (f *Function) recordInvocation(in *lambda.InvokeInput, res *lambda.InvokeOutput, ierr *InvokeError, start time.Time) {
+	if f.Recorder == nil {
+		return
+	}
+
+	i := &Invocation{
+		Function:  f.Name,
+		Qualifier: aws.StringValue(in.Qualifier),
+		Version:   aws.StringValue(res.ExecutedVersion),
+		Event:     in.Payload,
+		Duration:  time.Since(start),
+		Time:      start,
+	}
+
+	if ierr != nil {
+		i.Error = ierr.Message
+	} else {
+		i.Reply = res.Payload
+	}
+
+	if err := f.Recorder.Record(i); err != nil {
+		f.Log.Warnf("error recording invocation: %s", err)
+	}
+}
@@ -0,0 +1,101 @@
+package function
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// RequestLogsTimeout is the maximum time waited for the logs of an
+// invocation to be delivered to CloudWatch Logs.
+var RequestLogsTimeout = 30 * time.Second
+
+// requestLogsInterval is the interval between polls for invocation logs.
+var requestLogsInterval = time.Second
+
+// RequestLogs returns the complete logs of invocation `requestID` made
+// at or after `start`, from its START line through its REPORT line,
+// waiting up to RequestLogsTimeout for them to be delivered.
+func (f *Function) RequestLogs(requestID string, start time.Time) ([]byte, error) {
+	if f.CloudWatchLogs == nil {
+		return nil, errors.New("fetching request logs requires the CloudWatch Logs service")
+	}
+
+	deadline := time.Now().Add(RequestLogsTimeout)
+	startTime := aws.Int64(start.Add(-time.Second).UnixNano() / int64(time.Millisecond))
+	group := f.LogGroupName()
+
+	var buf bytes.Buffer
+	var stream *string
+	var token *string
+
+	for {
+		if stream == nil {
+			res, err := f.CloudWatchLogs.FilterLogEvents(&cloudwatchlogs.FilterLogEventsInput{
+				LogGroupName:  &group,
+				FilterPattern: aws.String(fmt.Sprintf(`"START RequestId: %s"`, requestID)),
+				StartTime:     startTime,
+			})
+
+			if err != nil {
+				return nil, err
+			}
+
+			if len(res.Events) > 0 {
+				stream = res.Events[0].LogStreamName
+				startTime = res.Events[0].Timestamp
+			}
+		}
+
+		if stream != nil {
+			res, err := f.CloudWatchLogs.GetLogEvents(&cloudwatchlogs.GetLogEventsInput{
+				LogGroupName:  &group,
+				LogStreamName: stream,
+				StartTime:     startTime,
+				StartFromHead: aws.Bool(true),
+				NextToken:     token,
+			})
+
+			if err != nil {
+				return nil, err
+			}
+
+			token = res.NextForwardToken
+
+			if done := collect(&buf, res.Events, requestID); done {
+				return buf.Bytes(), nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return buf.Bytes(), fmt.Errorf("timed out waiting for the logs of request %s", requestID)
+		}
+
+		time.Sleep(requestLogsInterval)
+	}
+}
+
+// collect writes the messages of `events` belonging to request `id` to `buf`,
+// returning true once its REPORT line is reached.
+func collect(buf *bytes.Buffer, events []*cloudwatchlogs.OutputLogEvent, id string) bool {
+	for _, e := range events {
+		msg := aws.StringValue(e.Message)
+
+		if buf.Len() == 0 && !strings.HasPrefix(msg, "START RequestId: "+id) {
+			continue
+		}
+
+		buf.WriteString(msg)
+
+		if strings.HasPrefix(msg, "REPORT RequestId: "+id) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,121 @@
+package function
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// Retry defaults.
+const (
+	DefaultRetryBackoff    = 100 * time.Millisecond
+	DefaultRetryMaxBackoff = 5 * time.Second
+)
+
+// IdempotencyKeyField is the field of the "custom" client context
+// object set to the idempotency key of invocations.
+const IdempotencyKeyField = "idempotencyKey"
+
+// retryableCodes are the error codes of invocations which may be retried.
+var retryableCodes = map[string]bool{
+	"TooManyRequestsException": true,
+	"EC2ThrottledException":    true,
+	"ServiceException":         true,
+	request.CanceledErrorCode:  true,
+}
+
+// RetryPolicy retries invocations which timed out or were throttled, as
+// well as typed errors the function reports as retryable.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts, including the first.
+	Attempts int
+
+	// Backoff before the first retry, doubled on each
+	// retry, defaulting to DefaultRetryBackoff.
+	Backoff time.Duration
+
+	// MaxBackoff between retries, defaulting to DefaultRetryMaxBackoff.
+	MaxBackoff time.Duration
+}
+
+// backoff returns the backoff before the first retry.
+func (p *RetryPolicy) backoff() time.Duration {
+	if p == nil || p.Backoff == 0 {
+		return DefaultRetryBackoff
+	}
+	return p.Backoff
+}
+
+// maxBackoff returns the maximum backoff.
+func (p *RetryPolicy) maxBackoff() time.Duration {
+	if p == nil || p.MaxBackoff == 0 {
+		return DefaultRetryMaxBackoff
+	}
+	return p.MaxBackoff
+}
+
+// retry returns true if failed `attempt` with `err` should be retried.
+func (p *RetryPolicy) retry(attempt int, err error) bool {
+	return p != nil && attempt < p.Attempts && retryable(err)
+}
+
+// retryable returns true if `err` is a timeout, throttle or
+// transient service error, or a retryable function error.
+func retryable(err error) bool {
+	switch e := err.(type) {
+	case awserr.Error:
+		return retryableCodes[e.Code()]
+	case *InvokeError:
+		return e.Retryable || strings.Contains(e.Message, "Task timed out after")
+	default:
+		return false
+	}
+}
+
+// newIdempotencyKey returns a random idempotency key.
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// withIdempotencyKey returns base64 encoded client context `cc` with
+// `key` set as the IdempotencyKeyField of its "custom" object.
+func withIdempotencyKey(cc, key string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(cc)
+	if err != nil {
+		return "", err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return "", errors.New("client context must be a JSON object to carry an idempotency key")
+	}
+
+	if m == nil {
+		m = make(map[string]interface{})
+	}
+
+	custom, ok := m["custom"].(map[string]interface{})
+	if !ok {
+		custom = make(map[string]interface{})
+	}
+
+	custom[IdempotencyKeyField] = key
+	m["custom"] = custom
+
+	if b, err = json.Marshal(m); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,99 @@
+package function
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// RuntimeManagement controls when Lambda updates the runtime version
+// of the function, for environments requiring change control.
+type RuntimeManagement struct {
+	// UpdateOn is "Auto" (the default) to update runtimes as Lambda
+	// releases them, "FunctionUpdate" to update them on deploy, or
+	// "Manual" to pin the runtime to VersionArn.
+	UpdateOn string `json:"updateOn"`
+
+	// VersionArn of the runtime version pinned by the Manual mode, such as
+	// "arn:aws:lambda:us-west-2::runtime:<hash>", as shown in the
+	// INIT_START log line of the function.
+	VersionArn string `json:"versionArn"`
+}
+
+// updateOn returns the update mode, defaulting to Auto.
+func (r *RuntimeManagement) updateOn() string {
+	if r.UpdateOn == "" {
+		return lambda.UpdateRuntimeOnAuto
+	}
+	return r.UpdateOn
+}
+
+// validateRuntimeManagement checks the update mode is supported,
+// and a runtime version is pinned by the Manual mode only.
+func (f *Function) validateRuntimeManagement() error {
+	r := f.RuntimeManagement
+	if r == nil {
+		return nil
+	}
+
+	switch r.updateOn() {
+	case lambda.UpdateRuntimeOnAuto, lambda.UpdateRuntimeOnFunctionUpdate:
+		if r.VersionArn != "" {
+			return fmt.Errorf("RuntimeManagement: versionArn requires the Manual updateOn mode")
+		}
+	case lambda.UpdateRuntimeOnManual:
+		if !strings.HasPrefix(r.VersionArn, "arn:") || !strings.Contains(r.VersionArn, ":runtime:") {
+			return fmt.Errorf("RuntimeManagement: the Manual updateOn mode requires a runtime versionArn")
+		}
+	default:
+		return fmt.Errorf("RuntimeManagement: invalid updateOn %q, must be one of Auto, FunctionUpdate, Manual", r.UpdateOn)
+	}
+
+	return nil
+}
+
+// deployRuntimeManagement updates the runtime management configuration
+// of $LATEST when it differs. Removing RuntimeManagement leaves the
+// current configuration in place.
+func (f *Function) deployRuntimeManagement() error {
+	r := f.RuntimeManagement
+	if r == nil {
+		return nil
+	}
+
+	res, err := f.Service.GetRuntimeManagementConfig(&lambda.GetRuntimeManagementConfigInput{
+		FunctionName: &f.FunctionName,
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == "ResourceNotFoundException" {
+		res, err = &lambda.GetRuntimeManagementConfigOutput{}, nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if aws.StringValue(res.UpdateRuntimeOn) == r.updateOn() && aws.StringValue(res.RuntimeVersionArn) == r.VersionArn {
+		f.Log.Debug("runtime management unchanged")
+		return nil
+	}
+
+	f.Log.Infof("updating runtime management to %s", r.updateOn())
+
+	in := &lambda.PutRuntimeManagementConfigInput{
+		FunctionName:    &f.FunctionName,
+		UpdateRuntimeOn: aws.String(r.updateOn()),
+	}
+
+	if r.VersionArn != "" {
+		in.RuntimeVersionArn = &r.VersionArn
+	}
+
+	return f.retryConflict(func() error {
+		_, err := f.Service.PutRuntimeManagementConfig(in)
+		return err
+	})
+}
@@ -0,0 +1,225 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/signer"
+)
+
+// Signing configures signing of the function's zip with AWS Signer.
+type Signing struct {
+	// Profile is the Signer signing profile name.
+	Profile string `json:"profile"`
+
+	// Bucket is a versioned S3 bucket storing the unsigned and signed zips.
+	Bucket string `json:"bucket"`
+
+	// Prefix of objects stored in Bucket, defaulting to "apex/".
+	Prefix string `json:"prefix"`
+}
+
+// prefix returns the object prefix for function `name`.
+func (s *Signing) prefix(name string) string {
+	if s.Prefix == "" {
+		return "apex/" + name + "/"
+	}
+	return s.Prefix + name + "/"
+}
+
+// validateSigning checks the signing configuration is complete.
+func (f *Function) validateSigning() error {
+	if f.Signing == nil {
+		return nil
+	}
+
+	if f.Signing.Profile == "" || f.Signing.Bucket == "" {
+		return errors.New("Signing: profile and bucket are required")
+	}
+
+	return nil
+}
+
+// checkSigning fails when the function's code signing config enforces
+// signatures but signing is not configured, rather than letting the
+// deploy fail mid-way.
+func (f *Function) checkSigning() error {
+	if f.CodeSigningConfigArn == "" || f.Signing != nil {
+		return nil
+	}
+
+	res, err := f.Service.GetCodeSigningConfig(&lambda.GetCodeSigningConfigInput{
+		CodeSigningConfigArn: &f.CodeSigningConfigArn,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	policy := aws.StringValue(res.CodeSigningConfig.CodeSigningPolicies.UntrustedArtifactOnDeployment)
+
+	if policy == lambda.CodeSigningPolicyEnforce {
+		return fmt.Errorf("code signing is enforced by %s, configure signing to sign the zip", f.CodeSigningConfigArn)
+	}
+
+	f.Log.Warnf("deploying unsigned code, %s policy is %s", f.CodeSigningConfigArn, policy)
+	return nil
+}
+
+// sign uploads `zip` to the signing bucket and signs it, returning
+// the location of the signed zip, or nil when signing is disabled.
+func (f *Function) sign(zip *bundle) (*lambda.FunctionCode, error) {
+	if f.Signing == nil {
+		return nil, nil
+	}
+
+	if f.Signer == nil || f.S3 == nil {
+		f.Log.Debug("skipping signing, no Signer or S3 service")
+		return nil, nil
+	}
+
+	key := f.Signing.prefix(f.FunctionName) + zip.checksum()[:16] + ".zip"
+	f.Log.Infof("uploading zip to s3://%s/%s", f.Signing.Bucket, key)
+
+	version, err := f.upload(f.Signing.Bucket, key, zip)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == "" {
+		return nil, fmt.Errorf("signing bucket %s must have versioning enabled", f.Signing.Bucket)
+	}
+
+	f.Log.Infof("signing with profile %s", f.Signing.Profile)
+
+	job, err := f.Signer.StartSigningJob(&signer.StartSigningJobInput{
+		ProfileName: &f.Signing.Profile,
+		Source: &signer.Source{
+			S3: &signer.S3Source{
+				BucketName: &f.Signing.Bucket,
+				Key:        &key,
+				Version:    &version,
+			},
+		},
+		Destination: &signer.Destination{
+			S3: &signer.S3Destination{
+				BucketName: &f.Signing.Bucket,
+				Prefix:     aws.String(f.Signing.prefix(f.FunctionName) + "signed-"),
+			},
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	in := &signer.DescribeSigningJobInput{JobId: job.JobId}
+
+	ctx, cancel, expired := f.stepContext(StepWait)
+	defer cancel()
+
+	expired.Reason = fmt.Sprintf("waiting for signing job %s", *job.JobId)
+
+	if err := f.Signer.WaitUntilSuccessfulSigningJobWithContext(ctx, in); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, expired
+		}
+		return nil, fmt.Errorf("signing job %s: %s", *job.JobId, err)
+	}
+
+	res, err := f.Signer.DescribeSigningJob(in)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lambda.FunctionCode{
+		S3Bucket: res.SignedObject.S3.BucketName,
+		S3Key:    res.SignedObject.S3.Key,
+	}, nil
+}
+
+// Signature of the deployed code, from its signing job.
+type Signature struct {
+	JobArn            string
+	Profile           string
+	ProfileVersionArn string
+	Signed            time.Time
+	Expires           time.Time
+}
+
+// Signature verifies the signature of the deployed code, returning it with
+// an error when the code is unsigned, the signing job did not succeed, was
+// revoked or has expired, or when its profile is not an allowed publisher
+// of the code signing config. Nil is returned when the function has no
+// code signing config.
+func (f *Function) Signature() (*Signature, error) {
+	if f.CodeSigningConfigArn == "" {
+		return nil, nil
+	}
+
+	if f.Signer == nil {
+		f.Log.Debug("skipping signature verification, no Signer service")
+		return nil, nil
+	}
+
+	info, err := f.getFunction()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := info.Configuration
+	if cfg.SigningJobArn == nil {
+		return nil, errors.New("deployed code is unsigned")
+	}
+
+	arn := aws.StringValue(cfg.SigningJobArn)
+
+	job, err := f.Signer.DescribeSigningJob(&signer.DescribeSigningJobInput{
+		JobId: aws.String(arn[strings.LastIndex(arn, "/")+1:]),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &Signature{
+		JobArn:            arn,
+		Profile:           aws.StringValue(job.ProfileName),
+		ProfileVersionArn: aws.StringValue(cfg.SigningProfileVersionArn),
+		Signed:            aws.TimeValue(job.CompletedAt),
+		Expires:           aws.TimeValue(job.SignatureExpiresAt),
+	}
+
+	if status := aws.StringValue(job.Status); status != signer.SigningStatusSucceeded {
+		return sig, fmt.Errorf("signing job %s", strings.ToLower(status))
+	}
+
+	if r := job.RevocationRecord; r != nil {
+		return sig, fmt.Errorf("signature revoked by %s: %s", aws.StringValue(r.RevokedBy), aws.StringValue(r.Reason))
+	}
+
+	if !sig.Expires.IsZero() && sig.Expires.Before(time.Now()) {
+		return sig, fmt.Errorf("signature expired %s", sig.Expires.Format(time.RFC3339))
+	}
+
+	res, err := f.Service.GetCodeSigningConfig(&lambda.GetCodeSigningConfigInput{
+		CodeSigningConfigArn: &f.CodeSigningConfigArn,
+	})
+
+	if err != nil {
+		return sig, err
+	}
+
+	for _, allowed := range res.CodeSigningConfig.AllowedPublishers.SigningProfileVersionArns {
+		if aws.StringValue(allowed) == sig.ProfileVersionArn {
+			return sig, nil
+		}
+	}
+
+	return sig, fmt.Errorf("signing profile %s is not allowed by %s", sig.ProfileVersionArn, f.CodeSigningConfigArn)
+}
@@ -0,0 +1,791 @@
+package function
+
+import (
+	"archive/zip"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// snapshotFile is the name of the state of the function in snapshot bundles.
+const snapshotFile = "snapshot.json"
+
+// Snapshot is the remote state of a function captured by Snapshot, stored
+// in a zip bundle as snapshot.json along with the zips of its versions
+// under code/, named after their checksum.
+type Snapshot struct {
+	// FunctionName of the function snapshotted.
+	FunctionName string `json:"functionName"`
+
+	// Time of the snapshot.
+	Time time.Time `json:"time"`
+
+	// Latest is the unpublished $LATEST version.
+	Latest *SnapshotVersion `json:"latest"`
+
+	// Versions are the published versions aliases route to, oldest first.
+	Versions []*SnapshotVersion `json:"versions"`
+
+	// Aliases of the function.
+	Aliases []*lambda.AliasConfiguration `json:"aliases"`
+
+	// Policies are the resource-based policy documents of the function,
+	// by alias, or "" for the unqualified function.
+	Policies map[string]string `json:"policies"`
+
+	// EventSources are the event source mappings of the function and its aliases.
+	EventSources []*lambda.EventSourceMappingConfiguration `json:"eventSources"`
+
+	// Concurrency reserved, if any.
+	Concurrency *int64 `json:"concurrency,omitempty"`
+
+	// Tags of the function.
+	Tags map[string]*string `json:"tags,omitempty"`
+}
+
+// SnapshotVersion is a version of a function captured by Snapshot.
+type SnapshotVersion struct {
+	// Configuration of the version.
+	Configuration *lambda.FunctionConfiguration `json:"configuration"`
+
+	// Code is the name of the zip of the version in the bundle.
+	Code string `json:"code,omitempty"`
+
+	// Image is the container image URI of image functions.
+	Image string `json:"image,omitempty"`
+}
+
+// Snapshot writes the remote state of the function to a bundle at
+// `path`: the code and configuration of $LATEST and of the versions its
+// aliases route to, its aliases, resource-based policies, event source
+// mappings, reserved concurrency and tags, so that it may be recreated
+// with Restore, such as after its deletion or under another name.
+func (f *Function) Snapshot(path string) error {
+	f.Log.Infof("snapshotting to %s", path)
+
+	codes := make(map[string]*bundle)
+
+	defer func() {
+		for _, b := range codes {
+			b.Close()
+		}
+	}()
+
+	s, err := f.snapshot(codes)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := writeSnapshot(file, s, codes); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// snapshot returns the remote state of the function, downloading the
+// zips of its versions to `codes`.
+func (f *Function) snapshot(codes map[string]*bundle) (*Snapshot, error) {
+	res, err := f.Service.GetFunction(&lambda.GetFunctionInput{
+		FunctionName: &f.FunctionName,
+	})
+
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	s := &Snapshot{
+		FunctionName: f.FunctionName,
+		Time:         time.Now(),
+		Tags:         res.Tags,
+		Policies:     make(map[string]string),
+	}
+
+	if res.Concurrency != nil {
+		s.Concurrency = res.Concurrency.ReservedConcurrentExecutions
+	}
+
+	if s.Latest, err = f.snapshotVersion(res, "$LATEST", codes); err != nil {
+		return nil, err
+	}
+
+	err = f.Service.ListAliasesPages(&lambda.ListAliasesInput{
+		FunctionName: &f.FunctionName,
+	}, func(page *lambda.ListAliasesOutput, last bool) bool {
+		s.Aliases = append(s.Aliases, page.Aliases...)
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, version := range aliasVersions(s.Aliases) {
+		res, err := f.Service.GetFunction(&lambda.GetFunctionInput{
+			FunctionName: &f.FunctionName,
+			Qualifier:    &version,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := f.snapshotVersion(res, version, codes)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Versions = append(s.Versions, v)
+	}
+
+	qualifiers := []string{""}
+	for _, a := range s.Aliases {
+		qualifiers = append(qualifiers, aws.StringValue(a.Name))
+	}
+
+	for _, q := range qualifiers {
+		if err := f.snapshotQualifier(s, q); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// snapshotVersion returns the version of `res`, deployed to `qualifier`,
+// downloading its zip to `codes` unless already downloaded.
+func (f *Function) snapshotVersion(res *lambda.GetFunctionOutput, qualifier string, codes map[string]*bundle) (*SnapshotVersion, error) {
+	v := &SnapshotVersion{Configuration: res.Configuration}
+
+	if aws.StringValue(res.Configuration.PackageType) == lambda.PackageTypeImage {
+		v.Image = aws.StringValue(res.Code.ImageUri)
+		return v, nil
+	}
+
+	sum, err := parseChecksum(aws.StringValue(res.Configuration.CodeSha256))
+	if err != nil {
+		return nil, err
+	}
+
+	v.Code = "code/" + hex.EncodeToString(sum) + ".zip"
+
+	if codes[v.Code] != nil {
+		return v, nil
+	}
+
+	zip, err := f.fetch(res, qualifier)
+	if err != nil {
+		return nil, err
+	}
+
+	codes[v.Code] = zip
+	return v, nil
+}
+
+// snapshotQualifier adds the policy and event source mappings of
+// `qualifier`, an alias or "" for the unqualified function, to `s`.
+func (f *Function) snapshotQualifier(s *Snapshot, qualifier string) error {
+	name := f.FunctionName
+	in := &lambda.GetPolicyInput{FunctionName: &name}
+
+	if qualifier != "" {
+		name += ":" + qualifier
+		in.Qualifier = &qualifier
+	}
+
+	res, err := f.Service.GetPolicy(in)
+
+	switch e, ok := err.(awserr.Error); {
+	case ok && e.Code() == "ResourceNotFoundException":
+	case err != nil:
+		return err
+	default:
+		s.Policies[qualifier] = aws.StringValue(res.Policy)
+	}
+
+	return f.Service.ListEventSourceMappingsPages(&lambda.ListEventSourceMappingsInput{
+		FunctionName: &name,
+	}, func(page *lambda.ListEventSourceMappingsOutput, last bool) bool {
+		for _, m := range page.EventSourceMappings {
+			if !hasMapping(s.EventSources, aws.StringValue(m.UUID)) {
+				s.EventSources = append(s.EventSources, m)
+			}
+		}
+		return true
+	})
+}
+
+// hasMapping returns true if `mappings` include the mapping `uuid`.
+func hasMapping(mappings []*lambda.EventSourceMappingConfiguration, uuid string) bool {
+	for _, m := range mappings {
+		if aws.StringValue(m.UUID) == uuid {
+			return true
+		}
+	}
+	return false
+}
+
+// aliasVersions returns the published versions `aliases` route to, oldest first.
+func aliasVersions(aliases []*lambda.AliasConfiguration) []string {
+	seen := make(map[string]bool)
+
+	for _, a := range aliases {
+		seen[aws.StringValue(a.FunctionVersion)] = true
+
+		if a.RoutingConfig != nil {
+			for v := range a.RoutingConfig.AdditionalVersionWeights {
+				seen[v] = true
+			}
+		}
+	}
+
+	delete(seen, "$LATEST")
+
+	var versions []string
+	for v := range seen {
+		versions = append(versions, v)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		a, _ := strconv.Atoi(versions[i])
+		b, _ := strconv.Atoi(versions[j])
+		return a < b
+	})
+
+	return versions
+}
+
+// writeSnapshot writes the bundle of `s` and its zips `codes` to `w`.
+func writeSnapshot(w io.Writer, s *Snapshot, codes map[string]*bundle) error {
+	z := zip.NewWriter(w)
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fw, err := z.Create(snapshotFile)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fw.Write(b); err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range codes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fw, err := z.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(fw, codes[name].reader()); err != nil {
+			return err
+		}
+	}
+
+	return z.Close()
+}
+
+// ReadSnapshot returns the state of the snapshot bundle at `path`.
+func ReadSnapshot(path string) (*Snapshot, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return readSnapshot(&r.Reader)
+}
+
+// readSnapshot returns the state of snapshot bundle `r`.
+func readSnapshot(r *zip.Reader) (*Snapshot, error) {
+	file, err := snapshotEntry(r, snapshotFile)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var s Snapshot
+	if err := json.NewDecoder(rc).Decode(&s); err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %s", err)
+	}
+
+	if s.Latest == nil || s.Latest.Configuration == nil {
+		return nil, fmt.Errorf("invalid snapshot: missing latest version")
+	}
+
+	return &s, nil
+}
+
+// snapshotEntry returns the file `name` of snapshot bundle `r`.
+func snapshotEntry(r *zip.Reader, name string) (*zip.File, error) {
+	for _, file := range r.File {
+		if file.Name == name {
+			return file, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid snapshot: missing %s", name)
+}
+
+// Restore recreates the function from the snapshot bundle at `path`,
+// written by Snapshot, such as to recover it after its deletion or to
+// clone it under another FunctionName. Versions are published again in
+// order, so their numbers may differ, and aliases are pointed at the
+// versions corresponding to those of the snapshot. The function must not
+// exist, and the role, layers and event sources of the snapshot must be
+// available to it.
+func (f *Function) Restore(path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	s, err := readSnapshot(&r.Reader)
+	if err != nil {
+		return err
+	}
+
+	if f.Locker != nil {
+		if err := f.Locker.Lock(f.FunctionName); err != nil {
+			return err
+		}
+		defer f.unlock()
+	}
+
+	_, err = f.Service.GetFunction(&lambda.GetFunctionInput{
+		FunctionName: &f.FunctionName,
+	})
+
+	switch notFound(err) {
+	case nil:
+		return fmt.Errorf("function %s already exists", f.FunctionName)
+	case ErrFunctionNotFound:
+	default:
+		return err
+	}
+
+	f.Log.Infof("restoring %s snapshotted %s", s.FunctionName, s.Time.Format(time.RFC3339))
+
+	versions := map[string]string{"$LATEST": "$LATEST"}
+
+	for i, v := range s.Versions {
+		version, err := f.restoreVersion(&r.Reader, v, s.Tags, i == 0, true)
+		if err != nil {
+			return err
+		}
+
+		versions[aws.StringValue(v.Configuration.Version)] = version
+	}
+
+	if _, err := f.restoreVersion(&r.Reader, s.Latest, s.Tags, len(s.Versions) == 0, false); err != nil {
+		return err
+	}
+
+	for _, a := range s.Aliases {
+		if err := f.restoreAlias(a, versions); err != nil {
+			return err
+		}
+	}
+
+	for qualifier, policy := range s.Policies {
+		if err := f.restorePolicy(qualifier, policy); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range s.EventSources {
+		if err := f.restoreEventSource(m, versions); err != nil {
+			return err
+		}
+	}
+
+	if s.Concurrency != nil {
+		f.Log.Infof("reserving concurrency of %d", *s.Concurrency)
+
+		_, err := f.Service.PutFunctionConcurrency(&lambda.PutFunctionConcurrencyInput{
+			FunctionName:                 &f.FunctionName,
+			ReservedConcurrentExecutions: s.Concurrency,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreVersion creates or updates the function with the code and
+// configuration of `v`, returning the version published, if `publish`.
+func (f *Function) restoreVersion(r *zip.Reader, v *SnapshotVersion, tags map[string]*string, create, publish bool) (string, error) {
+	code, err := f.restoreCode(r, v)
+	if err != nil {
+		return "", err
+	}
+
+	cfg := v.Configuration
+
+	if create {
+		f.Log.Info("creating function")
+
+		in := &lambda.CreateFunctionInput{
+			FunctionName:     &f.FunctionName,
+			Code:             code,
+			PackageType:      cfg.PackageType,
+			Description:      cfg.Description,
+			Handler:          cfg.Handler,
+			MemorySize:       cfg.MemorySize,
+			Timeout:          cfg.Timeout,
+			Runtime:          cfg.Runtime,
+			Role:             cfg.Role,
+			Architectures:    cfg.Architectures,
+			EphemeralStorage: cfg.EphemeralStorage,
+			LoggingConfig:    cfg.LoggingConfig,
+			DeadLetterConfig: cfg.DeadLetterConfig,
+			KMSKeyArn:        cfg.KMSKeyArn,
+			Layers:           layerArns(cfg.Layers),
+			Tags:             tags,
+			Publish:          &publish,
+		}
+
+		if cfg.Environment != nil {
+			in.Environment = &lambda.Environment{Variables: cfg.Environment.Variables}
+		}
+
+		if cfg.TracingConfig != nil {
+			in.TracingConfig = &lambda.TracingConfig{Mode: cfg.TracingConfig.Mode}
+		}
+
+		if cfg.VpcConfig != nil && len(cfg.VpcConfig.SubnetIds) > 0 {
+			in.VpcConfig = &lambda.VpcConfig{SubnetIds: cfg.VpcConfig.SubnetIds, SecurityGroupIds: cfg.VpcConfig.SecurityGroupIds}
+		}
+
+		if cfg.ImageConfigResponse != nil && cfg.ImageConfigResponse.ImageConfig != nil {
+			in.ImageConfig = cfg.ImageConfigResponse.ImageConfig
+		}
+
+		created, err := f.Service.CreateFunction(in)
+		if err != nil {
+			return "", err
+		}
+
+		return aws.StringValue(created.Version), f.waitReady(created)
+	}
+
+	var updated *lambda.FunctionConfiguration
+
+	err = f.retryConflict(func() (err error) {
+		updated, err = f.Service.UpdateFunctionCode(&lambda.UpdateFunctionCodeInput{
+			FunctionName:    &f.FunctionName,
+			ZipFile:         code.ZipFile,
+			S3Bucket:        code.S3Bucket,
+			S3Key:           code.S3Key,
+			S3ObjectVersion: code.S3ObjectVersion,
+			ImageUri:        code.ImageUri,
+			Architectures:   cfg.Architectures,
+		})
+		return err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := f.waitReady(updated); err != nil {
+		return "", err
+	}
+
+	in := &lambda.UpdateFunctionConfigurationInput{
+		FunctionName:     &f.FunctionName,
+		Description:      cfg.Description,
+		Handler:          cfg.Handler,
+		MemorySize:       cfg.MemorySize,
+		Timeout:          cfg.Timeout,
+		Runtime:          cfg.Runtime,
+		Role:             cfg.Role,
+		EphemeralStorage: cfg.EphemeralStorage,
+		LoggingConfig:    cfg.LoggingConfig,
+		DeadLetterConfig: cfg.DeadLetterConfig,
+		KMSKeyArn:        cfg.KMSKeyArn,
+		Layers:           layerArns(cfg.Layers),
+		Environment:      &lambda.Environment{Variables: map[string]*string{}},
+	}
+
+	if in.Layers == nil {
+		in.Layers = []*string{}
+	}
+
+	if cfg.Environment != nil {
+		in.Environment.Variables = cfg.Environment.Variables
+	}
+
+	if cfg.TracingConfig != nil {
+		in.TracingConfig = &lambda.TracingConfig{Mode: cfg.TracingConfig.Mode}
+	}
+
+	if cfg.VpcConfig != nil {
+		in.VpcConfig = &lambda.VpcConfig{SubnetIds: cfg.VpcConfig.SubnetIds, SecurityGroupIds: cfg.VpcConfig.SecurityGroupIds}
+	}
+
+	err = f.retryConflict(func() (err error) {
+		updated, err = f.Service.UpdateFunctionConfiguration(in)
+		return err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := f.waitReady(updated); err != nil {
+		return "", err
+	}
+
+	if !publish {
+		return "$LATEST", nil
+	}
+
+	var published *lambda.FunctionConfiguration
+
+	err = f.retryConflict(func() (err error) {
+		published, err = f.Service.PublishVersion(&lambda.PublishVersionInput{
+			FunctionName: &f.FunctionName,
+			CodeSha256:   updated.CodeSha256,
+			Description:  cfg.Description,
+		})
+		return err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	f.Log.Infof("published version %s of snapshotted version %s", aws.StringValue(published.Version), aws.StringValue(cfg.Version))
+	return aws.StringValue(published.Version), nil
+}
+
+// restoreCode returns the code of `v`, read from the zip of snapshot
+// bundle `r` and uploaded as for deploys when Artifacts or a Store are
+// configured.
+func (f *Function) restoreCode(r *zip.Reader, v *SnapshotVersion) (*lambda.FunctionCode, error) {
+	if v.Image != "" {
+		return &lambda.FunctionCode{ImageUri: &v.Image}, nil
+	}
+
+	file, err := snapshotEntry(r, v.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	zip, err := spool(func(w io.Writer) error {
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		_, err = io.Copy(w, rc)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	defer zip.Close()
+
+	if sum := zip.checksum(); sum != aws.StringValue(v.Configuration.CodeSha256) {
+		return nil, fmt.Errorf("snapshotted code checksum %s does not match %s", sum, aws.StringValue(v.Configuration.CodeSha256))
+	}
+
+	return f.code(zip)
+}
+
+// restoreAlias creates alias `a`, routing to the restored `versions`.
+func (f *Function) restoreAlias(a *lambda.AliasConfiguration, versions map[string]string) error {
+	version, ok := versions[aws.StringValue(a.FunctionVersion)]
+	if !ok {
+		return fmt.Errorf("alias %s routes to version %s missing from the snapshot", aws.StringValue(a.Name), aws.StringValue(a.FunctionVersion))
+	}
+
+	f.Log.Infof("creating alias %s to version %s", aws.StringValue(a.Name), version)
+
+	in := &lambda.CreateAliasInput{
+		FunctionName:    &f.FunctionName,
+		Name:            a.Name,
+		Description:     a.Description,
+		FunctionVersion: &version,
+	}
+
+	if a.RoutingConfig != nil && len(a.RoutingConfig.AdditionalVersionWeights) > 0 {
+		weights := make(map[string]*float64)
+		for v, w := range a.RoutingConfig.AdditionalVersionWeights {
+			weights[versions[v]] = w
+		}
+		in.RoutingConfig = &lambda.AliasRoutingConfiguration{AdditionalVersionWeights: weights}
+	}
+
+	_, err := f.Service.CreateAlias(in)
+	return err
+}
+
+// policyStatement is a statement of a resource-based policy.
+type policyStatement struct {
+	Sid       string
+	Action    string
+	Principal json.RawMessage
+	Condition map[string]map[string]string
+}
+
+// restorePolicy adds the statements of policy document `policy` to
+// `qualifier`, an alias or "" for the unqualified function.
+func (f *Function) restorePolicy(qualifier, policy string) error {
+	var doc struct {
+		Statement []*policyStatement
+	}
+
+	if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+		return fmt.Errorf("error parsing policy: %s", err)
+	}
+
+	for _, stmt := range doc.Statement {
+		in, err := stmt.permission()
+		if err != nil {
+			return fmt.Errorf("statement %s: %s", stmt.Sid, err)
+		}
+
+		in.FunctionName = &f.FunctionName
+		if qualifier != "" {
+			in.Qualifier = aws.String(qualifier)
+		}
+
+		f.Log.Infof("adding permission %s for %s", stmt.Sid, aws.StringValue(in.Principal))
+
+		if _, err := f.Service.AddPermission(in); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// permission returns the input adding the statement.
+func (s *policyStatement) permission() (*lambda.AddPermissionInput, error) {
+	in := &lambda.AddPermissionInput{
+		StatementId: &s.Sid,
+		Action:      &s.Action,
+	}
+
+	var principal string
+	var principals map[string]string
+
+	switch {
+	case json.Unmarshal(s.Principal, &principal) == nil:
+		in.Principal = &principal
+	case json.Unmarshal(s.Principal, &principals) == nil:
+		for _, k := range []string{"Service", "AWS"} {
+			if v, ok := principals[k]; ok {
+				in.Principal = aws.String(v)
+			}
+		}
+	}
+
+	if in.Principal == nil {
+		return nil, fmt.Errorf("unsupported principal %s", s.Principal)
+	}
+
+	for _, values := range s.Condition {
+		for k, v := range values {
+			v := v
+
+			switch strings.ToLower(k) {
+			case "aws:sourcearn":
+				in.SourceArn = &v
+			case "aws:sourceaccount":
+				in.SourceAccount = &v
+			case "aws:principalorgid":
+				in.PrincipalOrgID = &v
+			case "lambda:eventsourcetoken":
+				in.EventSourceToken = &v
+			case "lambda:functionurlauthtype":
+				in.FunctionUrlAuthType = &v
+			}
+		}
+	}
+
+	return in, nil
+}
+
+// restoreEventSource creates mapping `m` for the restored function, or
+// its alias or version of `versions`.
+func (f *Function) restoreEventSource(m *lambda.EventSourceMappingConfiguration, versions map[string]string) error {
+	name := f.FunctionName
+
+	if parts := strings.Split(aws.StringValue(m.FunctionArn), ":"); len(parts) == 8 {
+		qualifier := parts[7]
+		if v, ok := versions[qualifier]; ok {
+			qualifier = v
+		}
+		name += ":" + qualifier
+	}
+
+	f.Log.Infof("creating event source %s", mappingID(m))
+
+	state := aws.StringValue(m.State)
+
+	_, err := f.Service.CreateEventSourceMapping(&lambda.CreateEventSourceMappingInput{
+		FunctionName:                        &name,
+		Enabled:                             aws.Bool(state != "Disabled" && state != "Disabling"),
+		EventSourceArn:                      m.EventSourceArn,
+		BatchSize:                           m.BatchSize,
+		BisectBatchOnFunctionError:          m.BisectBatchOnFunctionError,
+		DestinationConfig:                   m.DestinationConfig,
+		FilterCriteria:                      m.FilterCriteria,
+		FunctionResponseTypes:               m.FunctionResponseTypes,
+		MaximumBatchingWindowInSeconds:      m.MaximumBatchingWindowInSeconds,
+		MaximumRecordAgeInSeconds:           m.MaximumRecordAgeInSeconds,
+		MaximumRetryAttempts:                m.MaximumRetryAttempts,
+		ParallelizationFactor:               m.ParallelizationFactor,
+		Queues:                              m.Queues,
+		ScalingConfig:                       m.ScalingConfig,
+		SelfManagedEventSource:              m.SelfManagedEventSource,
+		SourceAccessConfigurations:          m.SourceAccessConfigurations,
+		StartingPosition:                    m.StartingPosition,
+		StartingPositionTimestamp:           m.StartingPositionTimestamp,
+		Topics:                              m.Topics,
+		TumblingWindowInSeconds:             m.TumblingWindowInSeconds,
+		AmazonManagedKafkaEventSourceConfig: m.AmazonManagedKafkaEventSourceConfig,
+		SelfManagedKafkaEventSourceConfig:   m.SelfManagedKafkaEventSourceConfig,
+		DocumentDBEventSourceConfig:         m.DocumentDBEventSourceConfig,
+	})
+
+	return err
+}
@@ -0,0 +1,199 @@
+package function
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ChecksumPrefix prefixes the checksums of stored zips deployed with DeployArtifact.
+const ChecksumPrefix = "sha256:"
+
+// Store is a content-addressed S3 store of zips, which are stored under
+// their SHA256 checksum rather than per function. Identical code deployed
+// by several functions is uploaded once, and any zip stored may be
+// deployed again by its checksum, such as to roll back to a release
+// whose version was deleted.
+type Store struct {
+	// Bucket zips are stored in, in the region of the function. The
+	// "{region}" placeholder is replaced by the region, for a bucket
+	// per region.
+	Bucket string `json:"bucket"`
+
+	// Prefix of objects, defaulting to "apex/store/".
+	Prefix string `json:"prefix"`
+
+	// Regions whose buckets zips are copied from, within S3, rather than
+	// uploaded again when deploying to another region.
+	Regions []string `json:"regions"`
+}
+
+// bucket returns the bucket of `region`.
+func (s *Store) bucket(region string) string {
+	return strings.Replace(s.Bucket, "{region}", region, -1)
+}
+
+// key returns the object key of the zip with hex checksum `sum`.
+func (s *Store) key(sum string) string {
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = "apex/store/"
+	}
+	return prefix + sum + ".zip"
+}
+
+// validateStore checks the bucket is set, and not used along with Artifacts.
+func (f *Function) validateStore() error {
+	s := f.Store
+	if s == nil {
+		return nil
+	}
+
+	if s.Bucket == "" {
+		return errors.New("Store: bucket is required")
+	}
+
+	if f.Artifacts != nil {
+		return errors.New("Store: cannot be used along with artifacts")
+	}
+
+	if len(s.Regions) > 0 && !strings.Contains(s.Bucket, "{region}") {
+		return errors.New("Store: regions require a bucket with the {region} placeholder")
+	}
+
+	return nil
+}
+
+// storeArtifact stores `zip` unless already stored, copying it from the
+// bucket of another of the store's Regions when present there, and
+// returns its code location.
+func (f *Function) storeArtifact(zip *bundle) (*lambda.FunctionCode, error) {
+	ctx, cancel, expired := f.stepContext(StepUpload)
+	defer cancel()
+
+	bucket := f.Store.bucket(f.Region)
+	key := f.Store.key(zip.hexChecksum())
+	u := f.uploader(ctx, bucket, key, zip)
+
+	version, ok, err := u.uploaded(zip.hexChecksum())
+
+	if err == nil && !ok {
+		version, ok = f.copyStored(ctx, bucket, key)
+	}
+
+	if err == nil && !ok {
+		version, err = u.send()
+	}
+
+	if err != nil {
+		return nil, deadlineErr(ctx, err, expired)
+	}
+
+	code := &lambda.FunctionCode{
+		S3Bucket: &bucket,
+		S3Key:    &key,
+	}
+
+	if version != "" {
+		code.S3ObjectVersion = &version
+	}
+
+	return code, nil
+}
+
+// copyStored copies `key` to `bucket` from the bucket of the first of the
+// store's Regions storing it, returning the version of the copy. Failures
+// are logged, as the zip is uploaded instead.
+func (f *Function) copyStored(ctx context.Context, bucket, key string) (string, bool) {
+	for _, region := range f.Store.Regions {
+		src := f.Store.bucket(region)
+		if src == bucket {
+			continue
+		}
+
+		res, err := f.S3.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+			Bucket:            &bucket,
+			Key:               &key,
+			CopySource:        aws.String(path.Join(src, key)),
+			ChecksumAlgorithm: aws.String(s3.ChecksumAlgorithmSha256),
+		})
+
+		if err != nil {
+			f.Log.Debugf("not copying from s3://%s/%s: %s", src, key, err)
+			continue
+		}
+
+		f.Log.Infof("copied s3://%s/%s to %s", src, key, bucket)
+		return aws.StringValue(res.VersionId), true
+	}
+
+	return "", false
+}
+
+// deployStored creates or updates the function with the stored zip of `checksum`.
+func (f *Function) deployStored(checksum string) error {
+	if f.Store == nil {
+		return errors.New("deploying stored zips requires a store")
+	}
+
+	if f.S3 == nil {
+		return errors.New("deploying stored zips requires an S3 service")
+	}
+
+	if f.Signing != nil {
+		return errors.New("signing requires a local artifact")
+	}
+
+	sum, err := parseChecksum(checksum)
+	if err != nil {
+		return err
+	}
+
+	bucket := f.Store.bucket(f.Region)
+	key := f.Store.key(hex.EncodeToString(sum))
+
+	res, err := f.S3.HeadObject(&s3.HeadObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+
+	if e, ok := err.(awserr.Error); ok && (e.Code() == "NotFound" || e.Code() == s3.ErrCodeNoSuchKey) {
+		return fmt.Errorf("%s%s is not stored in %s", ChecksumPrefix, checksum, bucket)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	code := &lambda.FunctionCode{
+		S3Bucket:        &bucket,
+		S3Key:           &key,
+		S3ObjectVersion: res.VersionId,
+	}
+
+	return f.deployS3Code(code, base64.StdEncoding.EncodeToString(sum))
+}
+
+// parseChecksum returns the SHA256 checksum `s`, either hex or base64
+// as reported by Lambda for deployed code.
+func parseChecksum(s string) ([]byte, error) {
+	if b, err := hex.DecodeString(s); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+
+	return nil, fmt.Errorf("invalid checksum %q, must be a hex or base64 SHA256", s)
+}
@@ -0,0 +1,90 @@
+package function
+
+import (
+	"encoding/base64"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Stream is the response of a response-streaming invocation, yielding
+// chunks as they arrive. An InvokeError is returned from Read when the
+// function fails mid-stream.
+type Stream struct {
+	r      *io.PipeReader
+	events *lambda.InvokeWithResponseStreamEventStream
+	logs   string
+}
+
+// Read from the response.
+func (s *Stream) Read(b []byte) (int, error) {
+	return s.r.Read(b)
+}
+
+// Close the stream.
+func (s *Stream) Close() error {
+	s.r.Close()
+	return s.events.Close()
+}
+
+// Logs returns the log tail, available once the response is read to completion.
+func (s *Stream) Logs() io.Reader {
+	return base64.NewDecoder(base64.StdEncoding, strings.NewReader(s.logs))
+}
+
+// InvokeStream invokes the remote Lambda function with `payload`,
+// returning its streamed response. The Type and Timeout options
+// do not apply to streamed invocations.
+func (f *Function) InvokeStream(payload []byte, opts InvokeOptions) (*Stream, error) {
+	clientContext, err := opts.clientContext()
+	if err != nil {
+		return nil, err
+	}
+
+	logType := "Tail"
+	if opts.NoLogs {
+		logType = "None"
+	}
+
+	res, err := f.Service.InvokeWithResponseStream(&lambda.InvokeWithResponseStreamInput{
+		ClientContext: &clientContext,
+		FunctionName:  &f.FunctionName,
+		LogType:       &logType,
+		Qualifier:     aws.String(opts.qualifier(f.AliasName())),
+		Payload:       payload,
+	})
+
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	r, w := io.Pipe()
+	s := &Stream{r: r, events: res.GetStream()}
+
+	go func() {
+		for event := range s.events.Events() {
+			switch e := event.(type) {
+			case *lambda.InvokeResponseStreamUpdate:
+				if _, err := w.Write(e.Payload); err != nil {
+					return
+				}
+			case *lambda.InvokeWithResponseStreamCompleteEvent:
+				s.logs = aws.StringValue(e.LogResult)
+
+				if e.ErrorCode != nil {
+					w.CloseWithError(&InvokeError{
+						Type:    *e.ErrorCode,
+						Message: aws.StringValue(e.ErrorDetails),
+					})
+					return
+				}
+			}
+		}
+
+		w.CloseWithError(s.events.Err())
+	}()
+
+	return s, nil
+}
@@ -0,0 +1,195 @@
+package function
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// DefaultLogSubscriptionName is the default name of subscription filters.
+const DefaultLogSubscriptionName = "apex"
+
+// LogSubscription is a subscription filter of the function's log group,
+// shipping its log events to a central pipeline.
+type LogSubscription struct {
+	// Destination ARN of a Kinesis stream, Firehose delivery stream,
+	// Lambda function or cross-account CloudWatch Logs destination.
+	Destination string `json:"destination"`
+
+	// Role ARN CloudWatch Logs assumes to write to Kinesis
+	// and Firehose destinations.
+	Role string `json:"role"`
+
+	// Filter pattern of the shipped log events, defaulting to all events.
+	Filter string `json:"filter"`
+
+	// Name of the subscription filter, defaulting to "apex".
+	Name string `json:"name"`
+
+	// Distribution of log events across Kinesis shards,
+	// "ByLogStream" (the default) or "Random".
+	Distribution string `json:"distribution"`
+}
+
+// name returns the subscription filter name.
+func (s *LogSubscription) name() string {
+	if s.Name == "" {
+		return DefaultLogSubscriptionName
+	}
+	return s.Name
+}
+
+// service returns the service of the destination ARN.
+func (s *LogSubscription) service() string {
+	a, err := arn.Parse(s.Destination)
+	if err != nil {
+		return ""
+	}
+	return a.Service
+}
+
+// validateLogSubscription checks the destination is supported, and
+// has a role when CloudWatch Logs requires one to write to it.
+func (f *Function) validateLogSubscription() error {
+	s := f.LogSubscription
+	if s == nil {
+		return nil
+	}
+
+	switch s.service() {
+	case "kinesis", "firehose":
+		if s.Role == "" {
+			return fmt.Errorf("LogSubscription: role is required for %s destinations", s.service())
+		}
+	case "lambda", "logs":
+		if s.Role != "" {
+			return fmt.Errorf("LogSubscription: role only applies to Kinesis and Firehose destinations")
+		}
+	case "":
+		return fmt.Errorf("LogSubscription: invalid destination %q", s.Destination)
+	default:
+		return fmt.Errorf("LogSubscription: unsupported %s destination %q, must be a Kinesis stream, Firehose delivery stream, Lambda function or CloudWatch Logs destination", s.service(), s.Destination)
+	}
+
+	switch s.Distribution {
+	case "":
+	case cloudwatchlogs.DistributionByLogStream, cloudwatchlogs.DistributionRandom:
+		if s.service() != "kinesis" {
+			return fmt.Errorf("LogSubscription: distribution only applies to Kinesis destinations")
+		}
+	default:
+		return fmt.Errorf("LogSubscription: invalid distribution %q, must be ByLogStream or Random", s.Distribution)
+	}
+
+	if a, _ := arn.Parse(s.Destination); a.Service == "lambda" {
+		if name := "function:" + f.FunctionName; a.Resource == name || strings.HasPrefix(a.Resource, name+":") {
+			return fmt.Errorf("LogSubscription: destination must not be the function itself")
+		}
+	}
+
+	return nil
+}
+
+// DeployLogSubscription creates or updates the subscription filter of
+// the log group, created by DeployLogGroup. Lambda destinations are
+// allowed to be invoked by CloudWatch Logs for the log group. Filters
+// no longer configured are left in place.
+func (f *Function) DeployLogSubscription() error {
+	s := f.LogSubscription
+	if s == nil {
+		return nil
+	}
+
+	if f.CloudWatchLogs == nil {
+		f.Log.Debug("skipping log subscription, no CloudWatchLogs service")
+		return nil
+	}
+
+	group := f.LogGroupName()
+	name := s.name()
+
+	res, err := f.CloudWatchLogs.DescribeSubscriptionFilters(&cloudwatchlogs.DescribeSubscriptionFiltersInput{
+		LogGroupName:     &group,
+		FilterNamePrefix: &name,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, c := range res.SubscriptionFilters {
+		if aws.StringValue(c.FilterName) != name {
+			continue
+		}
+
+		if aws.StringValue(c.DestinationArn) == s.Destination &&
+			aws.StringValue(c.FilterPattern) == s.Filter &&
+			aws.StringValue(c.RoleArn) == s.Role &&
+			(s.Distribution == "" || aws.StringValue(c.Distribution) == s.Distribution) {
+			f.Log.Debugf("log subscription %s unchanged", name)
+			return nil
+		}
+	}
+
+	if s.service() == "lambda" {
+		if err := f.allowLogSubscription(); err != nil {
+			return err
+		}
+	}
+
+	f.Log.Infof("deploying log subscription %s to %s", name, s.Destination)
+
+	in := &cloudwatchlogs.PutSubscriptionFilterInput{
+		LogGroupName:   &group,
+		FilterName:     &name,
+		FilterPattern:  &s.Filter,
+		DestinationArn: &s.Destination,
+	}
+
+	if s.Role != "" {
+		in.RoleArn = &s.Role
+	}
+
+	if s.Distribution != "" {
+		in.Distribution = &s.Distribution
+	}
+
+	_, err = f.CloudWatchLogs.PutSubscriptionFilter(in)
+	return err
+}
+
+// allowLogSubscription permits CloudWatch Logs to invoke the Lambda
+// destination with the events of the function's log group.
+func (f *Function) allowLogSubscription() error {
+	dest, _ := arn.Parse(f.LogSubscription.Destination)
+
+	source := arn.ARN{
+		Partition: dest.Partition,
+		Service:   "logs",
+		Region:    dest.Region,
+		AccountID: dest.AccountID,
+		Resource:  "log-group:" + f.LogGroupName() + ":*",
+	}
+
+	f.Log.Debugf("allowing CloudWatch Logs to invoke %s", f.LogSubscription.Destination)
+
+	_, err := f.Service.AddPermission(&lambda.AddPermissionInput{
+		FunctionName:  &f.LogSubscription.Destination,
+		StatementId:   aws.String("apex-logs-" + f.FunctionName),
+		Action:        aws.String("lambda:InvokeFunction"),
+		Principal:     aws.String("logs.amazonaws.com"),
+		SourceArn:     aws.String(source.String()),
+		SourceAccount: &dest.AccountID,
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == lambda.ErrCodeResourceConflictException {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,394 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+// TablesPolicy prefixes the name of the inline policy of the function
+// role granting access to its tables, which is suffixed by the function
+// name so that functions sharing a role do not replace each other's.
+const TablesPolicy = "apex-tables"
+
+// tableActions are the actions granted to the function on its tables.
+var tableActions = []string{
+	"dynamodb:BatchGetItem",
+	"dynamodb:BatchWriteItem",
+	"dynamodb:ConditionCheckItem",
+	"dynamodb:DeleteItem",
+	"dynamodb:DescribeTable",
+	"dynamodb:GetItem",
+	"dynamodb:PutItem",
+	"dynamodb:Query",
+	"dynamodb:Scan",
+	"dynamodb:UpdateItem",
+}
+
+// Table is a DynamoDB table of the function, created or updated on deploy.
+// Tables are never deleted by apex, as they hold data.
+type Table struct {
+	// Name of the table.
+	Name string `json:"name"`
+
+	// PartitionKey of the table.
+	PartitionKey *TableKey `json:"partitionKey"`
+
+	// SortKey of the table, if any.
+	SortKey *TableKey `json:"sortKey"`
+
+	// BillingMode is "PAY_PER_REQUEST" (the default) or "PROVISIONED".
+	BillingMode string `json:"billingMode"`
+
+	// ReadCapacity and WriteCapacity of PROVISIONED tables.
+	ReadCapacity  int64 `json:"readCapacity"`
+	WriteCapacity int64 `json:"writeCapacity"`
+
+	// TTL attribute expiring items, if any.
+	TTL string `json:"ttl"`
+
+	// Env is the environment variable set to the table name, defaulting
+	// to TABLE_ followed by the upper-cased name, such as TABLE_USERS.
+	Env string `json:"env"`
+}
+
+// TableKey is a key attribute of a table.
+type TableKey struct {
+	// Name of the attribute.
+	Name string `json:"name"`
+
+	// Type of the attribute, "S" (the default), "N" or "B".
+	Type string `json:"type"`
+}
+
+// tableName pattern for valid table names.
+var tableName = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,255}$`)
+
+// envName pattern for valid environment variable names.
+var envName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// env returns the environment variable of the table name.
+func (t *Table) env() string {
+	if t.Env != "" {
+		return t.Env
+	}
+	return "TABLE_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(t.Name))
+}
+
+// billingMode returns the billing mode, defaulting to PAY_PER_REQUEST.
+func (t *Table) billingMode() string {
+	if t.BillingMode == "" {
+		return dynamodb.BillingModePayPerRequest
+	}
+	return t.BillingMode
+}
+
+// keys returns the key schema and attribute definitions of the table.
+func (t *Table) keys() (schema []*dynamodb.KeySchemaElement, attrs []*dynamodb.AttributeDefinition) {
+	add := func(k *TableKey, kind string) {
+		if k == nil {
+			return
+		}
+
+		typ := k.Type
+		if typ == "" {
+			typ = dynamodb.ScalarAttributeTypeS
+		}
+
+		schema = append(schema, &dynamodb.KeySchemaElement{AttributeName: aws.String(k.Name), KeyType: aws.String(kind)})
+		attrs = append(attrs, &dynamodb.AttributeDefinition{AttributeName: aws.String(k.Name), AttributeType: aws.String(typ)})
+	}
+
+	add(t.PartitionKey, dynamodb.KeyTypeHash)
+	add(t.SortKey, dynamodb.KeyTypeRange)
+	return
+}
+
+// throughput returns the provisioned throughput of PROVISIONED tables.
+func (t *Table) throughput() *dynamodb.ProvisionedThroughput {
+	if t.billingMode() != dynamodb.BillingModeProvisioned {
+		return nil
+	}
+
+	return &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(t.ReadCapacity),
+		WriteCapacityUnits: aws.Int64(t.WriteCapacity),
+	}
+}
+
+// validateTables checks the tables and their environment variables are unique,
+// and their keys and billing modes are valid.
+func (f *Function) validateTables() error {
+	names := make(map[string]bool)
+	vars := make(map[string]bool)
+
+	for _, t := range f.Tables {
+		if !tableName.MatchString(t.Name) {
+			return fmt.Errorf("Tables: invalid name %q", t.Name)
+		}
+
+		if names[t.Name] {
+			return fmt.Errorf("Tables: duplicate table %s", t.Name)
+		}
+		names[t.Name] = true
+
+		if !envName.MatchString(t.env()) {
+			return fmt.Errorf("Tables: %s env %q is not a valid variable name", t.Name, t.env())
+		}
+
+		if vars[t.env()] {
+			return fmt.Errorf("Tables: %s env %s is used by another table", t.Name, t.env())
+		}
+		vars[t.env()] = true
+
+		if t.PartitionKey == nil {
+			return fmt.Errorf("Tables: %s partitionKey is required", t.Name)
+		}
+
+		for _, k := range []*TableKey{t.PartitionKey, t.SortKey} {
+			if k == nil {
+				continue
+			}
+
+			if k.Name == "" {
+				return fmt.Errorf("Tables: %s key name is required", t.Name)
+			}
+
+			switch k.Type {
+			case "", dynamodb.ScalarAttributeTypeS, dynamodb.ScalarAttributeTypeN, dynamodb.ScalarAttributeTypeB:
+			default:
+				return fmt.Errorf("Tables: %s key %s has invalid type %q, must be one of S, N, B", t.Name, k.Name, k.Type)
+			}
+		}
+
+		if t.SortKey != nil && t.SortKey.Name == t.PartitionKey.Name {
+			return fmt.Errorf("Tables: %s sortKey must differ from the partitionKey", t.Name)
+		}
+
+		switch t.billingMode() {
+		case dynamodb.BillingModePayPerRequest:
+			if t.ReadCapacity != 0 || t.WriteCapacity != 0 {
+				return fmt.Errorf("Tables: %s readCapacity and writeCapacity only apply to PROVISIONED tables", t.Name)
+			}
+		case dynamodb.BillingModeProvisioned:
+			if t.ReadCapacity < 1 || t.WriteCapacity < 1 {
+				return fmt.Errorf("Tables: %s readCapacity and writeCapacity are required for PROVISIONED tables", t.Name)
+			}
+		default:
+			return fmt.Errorf("Tables: %s has invalid billingMode %q, must be one of PAY_PER_REQUEST, PROVISIONED", t.Name, t.BillingMode)
+		}
+	}
+
+	return nil
+}
+
+// tablesEnv returns the environment variables of the table names.
+func (f *Function) tablesEnv() map[string]string {
+	vars := make(map[string]string)
+	for _, t := range f.Tables {
+		vars[t.env()] = t.Name
+	}
+	return vars
+}
+
+// DeployTables creates or updates the tables of the function, and grants
+// the function role access to them. Tables are deployed before the code,
+// so that new code never runs against a missing table. The keys of
+// existing tables cannot be changed.
+func (f *Function) DeployTables() error {
+	if len(f.Tables) == 0 {
+		return nil
+	}
+
+	if f.DynamoDB == nil {
+		f.Log.Debug("skipping tables, no DynamoDB service")
+		return nil
+	}
+
+	var arns []string
+
+	for _, t := range f.Tables {
+		arn, err := f.deployTable(t)
+		if err != nil {
+			return err
+		}
+
+		if err := f.deployTTL(t); err != nil {
+			return err
+		}
+
+		arns = append(arns, arn, arn+"/index/*")
+	}
+
+	return f.deployTablesPolicy(arns)
+}
+
+// deployTable creates or updates table `t`, returning its ARN.
+func (f *Function) deployTable(t *Table) (string, error) {
+	schema, attrs := t.keys()
+
+	res, err := f.DynamoDB.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: &t.Name,
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == dynamodb.ErrCodeResourceNotFoundException {
+		f.Log.Infof("creating table %s", t.Name)
+
+		created, err := f.DynamoDB.CreateTable(&dynamodb.CreateTableInput{
+			TableName:             &t.Name,
+			KeySchema:             schema,
+			AttributeDefinitions:  attrs,
+			BillingMode:           aws.String(t.billingMode()),
+			ProvisionedThroughput: t.throughput(),
+		})
+
+		if err != nil {
+			return "", err
+		}
+
+		ctx, cancel, expired := f.stepContext(StepWait)
+		defer cancel()
+
+		expired.Reason = fmt.Sprintf("waiting for table %s to exist", t.Name)
+
+		if err := f.DynamoDB.WaitUntilTableExistsWithContext(ctx, &dynamodb.DescribeTableInput{TableName: &t.Name}); err != nil {
+			return "", deadlineErr(ctx, err, expired)
+		}
+
+		return aws.StringValue(created.TableDescription.TableArn), nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	table := res.Table
+	arn := aws.StringValue(table.TableArn)
+
+	if !sameKeys(table.KeySchema, schema) {
+		return "", fmt.Errorf("table %s keys differ from its partitionKey and sortKey, the keys of existing tables cannot be changed", t.Name)
+	}
+
+	mode := dynamodb.BillingModeProvisioned
+	if s := table.BillingModeSummary; s != nil && s.BillingMode != nil {
+		mode = *s.BillingMode
+	}
+
+	in := &dynamodb.UpdateTableInput{TableName: &t.Name}
+
+	if mode != t.billingMode() {
+		in.BillingMode = aws.String(t.billingMode())
+		in.AttributeDefinitions = attrs
+	}
+
+	if p := t.throughput(); p != nil {
+		current := table.ProvisionedThroughput
+		if in.BillingMode != nil || current == nil || aws.Int64Value(current.ReadCapacityUnits) != t.ReadCapacity || aws.Int64Value(current.WriteCapacityUnits) != t.WriteCapacity {
+			in.ProvisionedThroughput = p
+		}
+	}
+
+	if in.BillingMode == nil && in.ProvisionedThroughput == nil {
+		f.Log.Debugf("table %s unchanged", t.Name)
+		return arn, nil
+	}
+
+	f.Log.Infof("updating table %s", t.Name)
+
+	if _, err := f.DynamoDB.UpdateTable(in); err != nil {
+		return "", err
+	}
+
+	return arn, nil
+}
+
+// sameKeys returns true if key schemas `a` and `b` are equal.
+func sameKeys(a, b []*dynamodb.KeySchemaElement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if aws.StringValue(a[i].AttributeName) != aws.StringValue(b[i].AttributeName) || aws.StringValue(a[i].KeyType) != aws.StringValue(b[i].KeyType) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// deployTTL enables or disables the TTL of table `t`. DynamoDB does not
+// allow changing the attribute of an enabled TTL in a single step.
+func (f *Function) deployTTL(t *Table) error {
+	res, err := f.DynamoDB.DescribeTimeToLive(&dynamodb.DescribeTimeToLiveInput{
+		TableName: &t.Name,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	var current string
+	if d := res.TimeToLiveDescription; d != nil && aws.StringValue(d.TimeToLiveStatus) == dynamodb.TimeToLiveStatusEnabled {
+		current = aws.StringValue(d.AttributeName)
+	}
+
+	if current == t.TTL {
+		f.Log.Debugf("table %s ttl unchanged", t.Name)
+		return nil
+	}
+
+	if current != "" && t.TTL != "" {
+		return fmt.Errorf("table %s ttl is enabled on %s, remove the ttl and deploy before changing its attribute to %s", t.Name, current, t.TTL)
+	}
+
+	spec := &dynamodb.TimeToLiveSpecification{
+		AttributeName: aws.String(t.TTL),
+		Enabled:       aws.Bool(t.TTL != ""),
+	}
+
+	if t.TTL == "" {
+		f.Log.Infof("disabling table %s ttl", t.Name)
+		spec.AttributeName = &current
+	} else {
+		f.Log.Infof("enabling table %s ttl on %s", t.Name, t.TTL)
+	}
+
+	_, err = f.DynamoDB.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName:               &t.Name,
+		TimeToLiveSpecification: spec,
+	})
+
+	return err
+}
+
+// roleName returns the name of the function role.
+func (f *Function) roleName() string {
+	return f.Role[strings.LastIndex(f.Role, "/")+1:]
+}
+
+// deployTablesPolicy grants the function role access to table `arns`.
+func (f *Function) deployTablesPolicy(arns []string) error {
+	if f.IAM == nil {
+		f.Log.Debug("skipping tables policy, no IAM service")
+		return nil
+	}
+
+	policy, _ := json.Marshal(policyDocument(tableActions, arns, ""))
+
+	f.Log.Debugf("putting tables policy of role %s", f.roleName())
+
+	_, err := f.IAM.PutRolePolicy(&iam.PutRolePolicyInput{
+		RoleName:       aws.String(f.roleName()),
+		PolicyName:     aws.String(TablesPolicy + "-" + f.FunctionName),
+		PolicyDocument: aws.String(string(policy)),
+	})
+
+	return err
+}
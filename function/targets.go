@@ -0,0 +1,159 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apex/apex/runtime"
+)
+
+// validArch returns true if `arch` is a supported architecture.
+func validArch(arch string) bool {
+	return arch == X86_64 || arch == Arm64
+}
+
+// validateTargets checks Targets and RegionArchitectures are supported architectures.
+func (f *Function) validateTargets() error {
+	for _, arch := range f.Targets {
+		if !validArch(arch) {
+			return fmt.Errorf("Targets: invalid architecture %q, must be one of %s, %s", arch, X86_64, Arm64)
+		}
+	}
+
+	for region, arch := range f.RegionArchitectures {
+		if !validArch(arch) {
+			return fmt.Errorf("RegionArchitectures: invalid architecture %q for %s, must be one of %s, %s", arch, region, X86_64, Arm64)
+		}
+	}
+
+	return nil
+}
+
+// targets returns the architectures to build, defaulting to Arch().
+func (f *Function) targets() []string {
+	if len(f.Targets) == 0 {
+		return []string{f.Arch()}
+	}
+	return f.Targets
+}
+
+// build compiles the function for `arch` with the Build command when set,
+// or if the runtime requires compilation, within a build container when
+// Docker is enabled. Builds exceeding the Build deadline are killed, and
+// the partial artifacts of compiled runtimes cleaned.
+func (f *Function) build(arch string) error {
+	ctx, cancel, expired := f.stepContext(StepBuild)
+	defer cancel()
+
+	err := f.buildWith(ctx, arch)
+	if err == nil || ctx.Err() != context.DeadlineExceeded {
+		return err
+	}
+
+	f.Log.Warn("build exceeded its deadline, cleaning up")
+
+	if err := f.Clean(); err != nil {
+		f.Log.Warnf("error cleaning: %s", err)
+	}
+
+	return expired
+}
+
+// buildWith compiles the function for `arch`, killing commands when `ctx` is done.
+func (f *Function) buildWith(ctx context.Context, arch string) error {
+	if len(f.Build) > 0 {
+		return f.runBuild(ctx, arch)
+	}
+
+	if r, ok := f.runtime.(runtime.ContainerRuntime); ok && f.Docker {
+		return f.buildContainer(ctx, r, arch)
+	}
+
+	r, ok := f.runtime.(runtime.CompiledRuntime)
+	if !ok {
+		return nil
+	}
+
+	bc, err := f.buildContext(arch)
+	if err != nil {
+		return err
+	}
+
+	bc.Context = ctx
+
+	f.Log.Debugf("compiling for %s", arch)
+	return r.Build(bc)
+}
+
+// buildContext returns the context of a runtime build for `arch`, with
+// the environment of commands, APEX_ARCH, and the BuildCache directory.
+func (f *Function) buildContext(arch string) (*runtime.BuildContext, error) {
+	ctx := &runtime.BuildContext{
+		Dir:   f.Path,
+		Arch:  arch,
+		Stage: f.Stage,
+		Env:   f.commandEnv("APEX_ARCH=" + arch),
+		Log:   f.Log,
+	}
+
+	if f.BuildCache == "" {
+		return ctx, nil
+	}
+
+	dir, err := filepath.Abs(f.BuildCache)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating build cache: %s", err)
+	}
+
+	ctx.CacheDir = dir
+	return ctx, nil
+}
+
+// PackageTargets builds a zip for each of the function's Targets in one
+// pass, writing them to `dir` as "<name>-<arch>.zip" and returning their paths.
+func (f *Function) PackageTargets(dir string) ([]string, error) {
+	var paths []string
+
+	for _, arch := range f.targets() {
+		f.emit(BuildStarted{Function: f.Name})
+
+		path, err := f.packageTarget(dir, arch)
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// packageTarget builds a zip for `arch`, writing it to `dir` and returning its path.
+func (f *Function) packageTarget(dir, arch string) (string, error) {
+	zip, err := f.bundleZip(arch)
+	if err != nil {
+		return "", err
+	}
+	defer zip.Close()
+
+	f.emit(ZipCreated{Function: f.Name, Size: int(zip.size)})
+
+	if err := f.checkSize(zip); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.zip", f.Name, arch))
+	f.Log.Infof("writing %s", path)
+
+	if err := zip.save(path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
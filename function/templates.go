@@ -0,0 +1,109 @@
+package function
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/apex/apex/git"
+)
+
+// Source is the data of source files matched by Templates, rendered into
+// the zip at build time, such as {{.Stage}}, {{.Region}}, {{env "NAME"}}
+// for build and environment variables, or {{output "worker" "arn"}} for
+// the outputs of other functions.
+type Source struct {
+	Function *Function
+	Git      *git.Info
+	Stage    string
+	Region   string
+	Alias    string
+	Arch     string
+}
+
+// validateTemplates checks Templates are valid glob patterns.
+func (f *Function) validateTemplates() error {
+	for _, pattern := range f.Templates {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Templates: invalid pattern %q", pattern)
+		}
+	}
+
+	return nil
+}
+
+// templated returns true if the source file `name`, relative to the
+// function directory, matches Templates.
+func (f *Function) templated(name string) bool {
+	return matchAny(f.Templates, name)
+}
+
+// matchAny returns true if file `name` matches any of `patterns`.
+// Patterns without a slash match the base name of files in any directory.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		s := name
+		if !strings.Contains(pattern, "/") {
+			s = path.Base(name)
+		}
+
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// renderer returns a function rendering the source files matching
+// Templates, built for `arch`, or nil when there are none.
+func (f *Function) renderer(arch string) renderFunc {
+	if len(f.Templates) == 0 {
+		return nil
+	}
+
+	data := Source{
+		Function: f,
+		Git:      f.Git,
+		Stage:    f.Stage,
+		Region:   f.Region,
+		Alias:    f.AliasName(),
+		Arch:     arch,
+	}
+
+	if data.Git == nil {
+		data.Git = new(git.Info)
+	}
+
+	funcs := template.FuncMap{
+		"env": f.buildVar,
+		"output": func(name, attr string) (string, error) {
+			if f.Resolver == nil {
+				return "", fmt.Errorf("cannot resolve the %s of %s outside of a project", attr, name)
+			}
+			return f.Resolver.Output(name, attr)
+		},
+	}
+
+	return func(name string, b []byte) ([]byte, error) {
+		if !f.templated(name) {
+			return b, nil
+		}
+
+		f.Log.Debugf("rendering %s", name)
+
+		t, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("rendering %s: %s", name, err)
+		}
+
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("rendering %s: %s", name, err)
+		}
+
+		return buf.Bytes(), nil
+	}
+}
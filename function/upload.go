@@ -0,0 +1,488 @@
+package function
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/dustin/go-humanize"
+)
+
+// Upload defaults.
+const (
+	DefaultPartSize    = 16
+	DefaultConcurrency = 4
+	MinPartSize        = 5
+	MaxParts           = 10000
+)
+
+// partAttempts is the number of attempts to upload each part.
+const partAttempts = 3
+
+// Artifacts is an S3 bucket zips are uploaded to and deployed from, rather
+// than uploaded to Lambda directly, lifting the 50MB limit of direct
+// uploads. Zips larger than a part are uploaded in parts, in parallel,
+// resuming the parts uploaded by a previous attempt. Parts and objects are
+// verified with SHA256 checksums. The bucket should have a lifecycle rule
+// aborting incomplete multipart uploads after a few days.
+type Artifacts struct {
+	// Bucket zips are uploaded to.
+	Bucket string `json:"bucket"`
+
+	// Prefix of objects, defaulting to "apex/".
+	Prefix string `json:"prefix"`
+
+	// PartSize of multipart uploads in megabytes, defaulting to 16.
+	PartSize int64 `json:"partSize"`
+
+	// Concurrency is the number of parts uploaded in parallel, defaulting to 4.
+	Concurrency int `json:"concurrency"`
+}
+
+// prefix returns the object prefix for function `name`.
+func (a *Artifacts) prefix(name string) string {
+	if a.Prefix == "" {
+		return "apex/" + name + "/"
+	}
+	return a.Prefix + name + "/"
+}
+
+// partSize returns the part size in bytes.
+func (a *Artifacts) partSize() int {
+	if a == nil || a.PartSize == 0 {
+		return DefaultPartSize << 20
+	}
+	return int(a.PartSize) << 20
+}
+
+// concurrency returns the number of parts uploaded in parallel.
+func (a *Artifacts) concurrency() int {
+	if a == nil || a.Concurrency == 0 {
+		return DefaultConcurrency
+	}
+	return a.Concurrency
+}
+
+// validateArtifacts checks the bucket is set, and parts are within the limits of S3.
+func (f *Function) validateArtifacts() error {
+	a := f.Artifacts
+	if a == nil {
+		return nil
+	}
+
+	if a.Bucket == "" {
+		return fmt.Errorf("Artifacts: bucket is required")
+	}
+
+	if a.PartSize != 0 && (a.PartSize < MinPartSize || a.PartSize > 5<<10) {
+		return fmt.Errorf("Artifacts: partSize must be between %d and %d megabytes", MinPartSize, 5<<10)
+	}
+
+	if a.Concurrency < 0 {
+		return fmt.Errorf("Artifacts: concurrency must not be negative")
+	}
+
+	return nil
+}
+
+// uploadArtifact uploads `zip` to the Artifacts bucket under its
+// checksum, returning its code location.
+func (f *Function) uploadArtifact(zip *bundle) (*lambda.FunctionCode, error) {
+	key := f.Artifacts.prefix(f.FunctionName) + zip.hexChecksum() + ".zip"
+
+	version, err := f.upload(f.Artifacts.Bucket, key, zip)
+	if err != nil {
+		return nil, err
+	}
+
+	code := &lambda.FunctionCode{
+		S3Bucket: &f.Artifacts.Bucket,
+		S3Key:    &key,
+	}
+
+	if version != "" {
+		code.S3ObjectVersion = &version
+	}
+
+	return code, nil
+}
+
+// upload `b` to `bucket` at `key`, returning the object version, if any.
+// Objects already uploaded with the same checksum are not uploaded again.
+func (f *Function) upload(bucket, key string, b *bundle) (string, error) {
+	ctx, cancel, expired := f.stepContext(StepUpload)
+	defer cancel()
+
+	version, err := f.uploader(ctx, bucket, key, b).upload()
+	return version, deadlineErr(ctx, err, expired)
+}
+
+// uploader returns an uploader of `b` to `bucket` at `key`, with the
+// part size and concurrency of Artifacts.
+func (f *Function) uploader(ctx context.Context, bucket, key string, b *bundle) *uploader {
+	return &uploader{
+		service:     f.S3,
+		ctx:         ctx,
+		log:         f.Log,
+		bucket:      bucket,
+		key:         key,
+		body:        b,
+		partSize:    f.Artifacts.partSize(),
+		concurrency: f.Artifacts.concurrency(),
+	}
+}
+
+// checksumKey is the metadata key of the hex SHA256 checksum of objects.
+const checksumKey = "Sha256"
+
+// uploader uploads an object in parts, read from the
+// body as they are uploaded.
+type uploader struct {
+	service     s3iface.S3API
+	ctx         context.Context
+	log         log.Interface
+	bucket      string
+	key         string
+	body        *bundle
+	partSize    int
+	concurrency int
+}
+
+// upload the object unless already uploaded.
+func (u *uploader) upload() (string, error) {
+	if version, ok, err := u.uploaded(u.body.hexChecksum()); err != nil || ok {
+		return version, err
+	}
+
+	return u.send()
+}
+
+// send the object, in parts when larger than a part.
+func (u *uploader) send() (string, error) {
+	u.log.Infof("uploading %s to s3://%s/%s", humanize.Bytes(uint64(u.body.size)), u.bucket, u.key)
+
+	if u.body.size <= int64(u.partSize) {
+		return u.put()
+	}
+
+	return u.multipart()
+}
+
+// uploaded returns the version of the object when it
+// was already uploaded with the hex checksum `sum`.
+func (u *uploader) uploaded(sum string) (string, bool, error) {
+	res, err := u.service.HeadObjectWithContext(u.ctx, &s3.HeadObjectInput{
+		Bucket: &u.bucket,
+		Key:    &u.key,
+	})
+
+	if e, ok := err.(awserr.Error); ok && (e.Code() == "NotFound" || e.Code() == s3.ErrCodeNoSuchKey) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+
+	for k, v := range res.Metadata {
+		if strings.EqualFold(k, checksumKey) && aws.StringValue(v) == sum && aws.Int64Value(res.ContentLength) == u.body.size {
+			u.log.Infof("s3://%s/%s already uploaded", u.bucket, u.key)
+			return aws.StringValue(res.VersionId), true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// put uploads the object in a single request, which
+// S3 verifies against the checksum of the body.
+func (u *uploader) put() (string, error) {
+	res, err := u.service.PutObjectWithContext(u.ctx, &s3.PutObjectInput{
+		Bucket:            &u.bucket,
+		Key:               &u.key,
+		Body:              u.body.reader(),
+		ChecksumAlgorithm: aws.String(s3.ChecksumAlgorithmSha256),
+		ChecksumSHA256:    aws.String(u.body.checksum()),
+		Metadata:          map[string]*string{checksumKey: aws.String(u.body.hexChecksum())},
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(res.VersionId), nil
+}
+
+// part of a multipart upload.
+type part struct {
+	number int64
+	offset int64
+	size   int64
+	sum    string
+	etag   string
+}
+
+// parts returns the parts of the body, reading each to compute its checksum.
+func (u *uploader) parts() ([]*part, error) {
+	var parts []*part
+
+	for offset := int64(0); offset < u.body.size; offset += int64(u.partSize) {
+		size := int64(u.partSize)
+		if offset+size > u.body.size {
+			size = u.body.size - offset
+		}
+
+		h := sha256.New()
+		if _, err := io.Copy(h, u.body.section(offset, size)); err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, &part{
+			number: int64(len(parts) + 1),
+			offset: offset,
+			size:   size,
+			sum:    base64.StdEncoding.EncodeToString(h.Sum(nil)),
+		})
+	}
+
+	return parts, nil
+}
+
+// multipart uploads the object in parts, resuming an incomplete upload
+// of the key, whose parts with matching checksums are reused. Failed
+// uploads are left incomplete, so that a retry resumes them.
+func (u *uploader) multipart() (string, error) {
+	if n := (u.body.size + int64(u.partSize) - 1) / int64(u.partSize); n > MaxParts {
+		return "", fmt.Errorf("%d parts exceed the limit of %d, increase the part size", n, MaxParts)
+	}
+
+	parts, err := u.parts()
+	if err != nil {
+		return "", err
+	}
+
+	id, err := u.resume(parts)
+	if err != nil {
+		return "", err
+	}
+
+	if id == "" {
+		res, err := u.service.CreateMultipartUploadWithContext(u.ctx, &s3.CreateMultipartUploadInput{
+			Bucket:            &u.bucket,
+			Key:               &u.key,
+			ChecksumAlgorithm: aws.String(s3.ChecksumAlgorithmSha256),
+			Metadata:          map[string]*string{checksumKey: aws.String(u.body.hexChecksum())},
+		})
+
+		if err != nil {
+			return "", err
+		}
+
+		id = aws.StringValue(res.UploadId)
+	}
+
+	if err := u.uploadParts(id, parts); err != nil {
+		return "", fmt.Errorf("uploading parts: %s, retrying resumes the upload", err)
+	}
+
+	var completed []*s3.CompletedPart
+	var digests []byte
+
+	for _, p := range parts {
+		completed = append(completed, &s3.CompletedPart{
+			PartNumber:     aws.Int64(p.number),
+			ETag:           aws.String(p.etag),
+			ChecksumSHA256: aws.String(p.sum),
+		})
+
+		d, _ := base64.StdEncoding.DecodeString(p.sum)
+		digests = append(digests, d...)
+	}
+
+	res, err := u.service.CompleteMultipartUploadWithContext(u.ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          &u.bucket,
+		Key:             &u.key,
+		UploadId:        &id,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	composite := sha256.Sum256(digests)
+	want := fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(composite[:]), len(parts))
+
+	if err := u.verify(want, aws.StringValue(res.VersionId)); err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(res.VersionId), nil
+}
+
+// resume returns the id of an incomplete upload of the key with SHA256
+// checksums, setting the etag of its parts matching `parts`, or an
+// empty string when there is none.
+func (u *uploader) resume(parts []*part) (string, error) {
+	res, err := u.service.ListMultipartUploadsWithContext(u.ctx, &s3.ListMultipartUploadsInput{
+		Bucket: &u.bucket,
+		Prefix: &u.key,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	var upload *s3.MultipartUpload
+
+	for _, m := range res.Uploads {
+		if aws.StringValue(m.Key) != u.key || aws.StringValue(m.ChecksumAlgorithm) != s3.ChecksumAlgorithmSha256 {
+			continue
+		}
+
+		if upload == nil || aws.TimeValue(m.Initiated).After(aws.TimeValue(upload.Initiated)) {
+			upload = m
+		}
+	}
+
+	if upload == nil {
+		return "", nil
+	}
+
+	reused := 0
+
+	err = u.service.ListPartsPagesWithContext(u.ctx, &s3.ListPartsInput{
+		Bucket:   &u.bucket,
+		Key:      &u.key,
+		UploadId: upload.UploadId,
+	}, func(page *s3.ListPartsOutput, last bool) bool {
+		for _, uploaded := range page.Parts {
+			n := aws.Int64Value(uploaded.PartNumber)
+			if n < 1 || n > int64(len(parts)) {
+				continue
+			}
+
+			p := parts[n-1]
+			if aws.StringValue(uploaded.ChecksumSHA256) == p.sum && aws.Int64Value(uploaded.Size) == p.size {
+				p.etag = aws.StringValue(uploaded.ETag)
+				reused++
+			}
+		}
+		return true
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	u.log.Infof("resuming upload with %d of %d parts uploaded", reused, len(parts))
+	return aws.StringValue(upload.UploadId), nil
+}
+
+// uploadParts uploads the parts of upload `id` without an etag in
+// parallel, retrying each part, returning the first error, if any.
+func (u *uploader) uploadParts(id string, parts []*part) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var first error
+
+	sem := make(chan struct{}, u.concurrency)
+
+	for _, p := range parts {
+		if p.etag != "" {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(p *part) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := u.uploadPart(id, p)
+
+			mu.Lock()
+			if err != nil && first == nil {
+				first = err
+			}
+			mu.Unlock()
+		}(p)
+	}
+
+	wg.Wait()
+	return first
+}
+
+// uploadPart uploads part `p` of upload `id`, which S3 verifies
+// against its checksum, retrying up to partAttempts times.
+func (u *uploader) uploadPart(id string, p *part) error {
+	var err error
+
+	for attempt := 1; attempt <= partAttempts; attempt++ {
+		if u.ctx.Err() != nil {
+			return u.ctx.Err()
+		}
+
+		var res *s3.UploadPartOutput
+
+		res, err = u.service.UploadPartWithContext(u.ctx, &s3.UploadPartInput{
+			Bucket:            &u.bucket,
+			Key:               &u.key,
+			UploadId:          &id,
+			PartNumber:        aws.Int64(p.number),
+			Body:              u.body.section(p.offset, p.size),
+			ContentLength:     aws.Int64(p.size),
+			ChecksumAlgorithm: aws.String(s3.ChecksumAlgorithmSha256),
+			ChecksumSHA256:    aws.String(p.sum),
+		})
+
+		if err == nil {
+			p.etag = aws.StringValue(res.ETag)
+			u.log.Debugf("uploaded part %d", p.number)
+			return nil
+		}
+
+		u.log.Warnf("attempt %d uploading part %d failed: %s", attempt, p.number, err)
+	}
+
+	return fmt.Errorf("part %d: %s", p.number, err)
+}
+
+// verify the size and composite checksum `want` of version `version`.
+func (u *uploader) verify(want, version string) error {
+	in := &s3.HeadObjectInput{
+		Bucket:       &u.bucket,
+		Key:          &u.key,
+		ChecksumMode: aws.String(s3.ChecksumModeEnabled),
+	}
+
+	if version != "" {
+		in.VersionId = &version
+	}
+
+	res, err := u.service.HeadObjectWithContext(u.ctx, in)
+	if err != nil {
+		return err
+	}
+
+	if n := aws.Int64Value(res.ContentLength); n != u.body.size {
+		return fmt.Errorf("uploaded s3://%s/%s is %d bytes, expected %d", u.bucket, u.key, n, u.body.size)
+	}
+
+	if got := aws.StringValue(res.ChecksumSHA256); got != "" && got != want {
+		return fmt.Errorf("uploaded s3://%s/%s checksum %s does not match %s", u.bucket, u.key, got, want)
+	}
+
+	return nil
+}
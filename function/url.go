@@ -0,0 +1,166 @@
+package function
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// URL auth types.
+const (
+	URLAuthNone = "NONE"
+	URLAuthIAM  = "AWS_IAM"
+)
+
+// URLConfig is the function URL configuration, providing an HTTPS
+// endpoint for webhooks without API Gateway.
+type URLConfig struct {
+	// Auth type, "NONE" (default) or "AWS_IAM".
+	Auth string `json:"auth"`
+
+	// CORS configuration.
+	CORS *CORS `json:"cors"`
+}
+
+// CORS is the cross-origin resource sharing configuration of a function URL.
+type CORS struct {
+	Origins       []string `json:"origins"`
+	Methods       []string `json:"methods"`
+	Headers       []string `json:"headers"`
+	ExposeHeaders []string `json:"exposeHeaders"`
+	Credentials   bool     `json:"credentials"`
+	MaxAge        int64    `json:"maxAge"`
+}
+
+// auth returns the auth type.
+func (c *URLConfig) auth() string {
+	if c.Auth == "" {
+		return URLAuthNone
+	}
+	return c.Auth
+}
+
+// cors returns the Lambda CORS configuration.
+func (c *URLConfig) cors() *lambda.Cors {
+	if c.CORS == nil {
+		return nil
+	}
+
+	return &lambda.Cors{
+		AllowOrigins:     aws.StringSlice(c.CORS.Origins),
+		AllowMethods:     aws.StringSlice(c.CORS.Methods),
+		AllowHeaders:     aws.StringSlice(c.CORS.Headers),
+		ExposeHeaders:    aws.StringSlice(c.CORS.ExposeHeaders),
+		AllowCredentials: aws.Bool(c.CORS.Credentials),
+		MaxAge:           aws.Int64(c.CORS.MaxAge),
+	}
+}
+
+// validateURL checks the URL auth type is valid.
+func (f *Function) validateURL() error {
+	if f.URL == nil {
+		return nil
+	}
+
+	switch f.URL.auth() {
+	case URLAuthNone, URLAuthIAM:
+		return nil
+	default:
+		return fmt.Errorf("URL: invalid auth %q, must be one of %s, %s", f.URL.Auth, URLAuthNone, URLAuthIAM)
+	}
+}
+
+// DeployURL creates or updates the function URL of the current alias,
+// or removes it when no longer configured.
+func (f *Function) DeployURL() error {
+	existing, err := f.Service.GetFunctionUrlConfig(&lambda.GetFunctionUrlConfigInput{
+		FunctionName: &f.FunctionName,
+		Qualifier:    aws.String(f.AliasName()),
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == "ResourceNotFoundException" {
+		existing, err = nil, nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if f.URL == nil {
+		if existing == nil {
+			return nil
+		}
+
+		f.Log.Info("deleting url")
+
+		_, err := f.Service.DeleteFunctionUrlConfig(&lambda.DeleteFunctionUrlConfigInput{
+			FunctionName: &f.FunctionName,
+			Qualifier:    aws.String(f.AliasName()),
+		})
+
+		return err
+	}
+
+	if existing == nil {
+		f.Log.Info("creating url")
+
+		res, err := f.Service.CreateFunctionUrlConfig(&lambda.CreateFunctionUrlConfigInput{
+			FunctionName: &f.FunctionName,
+			Qualifier:    aws.String(f.AliasName()),
+			AuthType:     aws.String(f.URL.auth()),
+			Cors:         f.URL.cors(),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if res != nil {
+			f.url = *res.FunctionUrl
+		}
+	} else {
+		f.Log.Debug("updating url")
+
+		res, err := f.Service.UpdateFunctionUrlConfig(&lambda.UpdateFunctionUrlConfigInput{
+			FunctionName: &f.FunctionName,
+			Qualifier:    aws.String(f.AliasName()),
+			AuthType:     aws.String(f.URL.auth()),
+			Cors:         f.URL.cors(),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		f.url = *existing.FunctionUrl
+		if res != nil {
+			f.url = *res.FunctionUrl
+		}
+	}
+
+	if f.URL.auth() != URLAuthNone {
+		return nil
+	}
+
+	_, err = f.Service.AddPermission(&lambda.AddPermissionInput{
+		FunctionName:        &f.FunctionName,
+		Qualifier:           aws.String(f.AliasName()),
+		StatementId:         aws.String("apex-url"),
+		Action:              aws.String("lambda:InvokeFunctionUrl"),
+		Principal:           aws.String("*"),
+		FunctionUrlAuthType: aws.String(URLAuthNone),
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == "ResourceConflictException" {
+		return nil
+	}
+
+	return err
+}
+
+// Endpoint returns the function URL after a deploy, if any.
+func (f *Function) Endpoint() string {
+	return f.url
+}
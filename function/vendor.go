@@ -0,0 +1,127 @@
+package function
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apex/apex/runtime"
+)
+
+// addDir adds `dir` to `zip`, rendering the files matched by `render`
+// when non-nil. When the runtime resolves dependencies outside of the
+// function directory they are vendored in its place.
+func (f *Function) addDir(zip *zipWriter, dir string, render renderFunc) error {
+	r, ok := f.runtime.(runtime.DependencyRuntime)
+	if !ok {
+		return addSource(zip, dir, render)
+	}
+
+	deps, err := r.Dependencies(dir)
+	if err != nil {
+		return err
+	}
+
+	if deps == nil {
+		return addSource(zip, dir, render)
+	}
+
+	if err := addTree(zip, "", dir, true, render); err != nil {
+		return err
+	}
+
+	var paths []string
+	for path := range deps {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		f.Log.Debugf("vendoring %s from %s", path, deps[path])
+		if err := addTree(zip, path, deps[path], true, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renderFunc renders the contents of source file `name`.
+type renderFunc func(name string, b []byte) ([]byte, error)
+
+// addSource adds the source files in `dir` to `zip`, rendering them with `render` when non-nil.
+func addSource(zip *zipWriter, dir string, render renderFunc) error {
+	if render == nil {
+		return zip.AddDir(dir, envSource)
+	}
+	return addTree(zip, "", dir, false, render)
+}
+
+// addTree adds the files in `dir` to `zip` under `prefix`, resolving
+// symlinked files, skipping node_modules directories when `vendored`,
+// and rendering files with `render` when non-nil. The env files of the
+// function directory, added without a prefix, are skipped.
+func addTree(zip *zipWriter, prefix, dir string, vendored bool, render renderFunc) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if vendored && info.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if info, err = os.Stat(path); err != nil || info.IsDir() {
+				return err
+			}
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(filepath.Join(prefix, rel))
+
+		if prefix == "" && envSource(name) {
+			return nil
+		}
+
+		if render != nil {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			if b, err = render(name, b); err != nil {
+				return err
+			}
+
+			return zip.AddInfoBytes(name, rendered{info, len(b)}, b)
+		}
+
+		return zip.AddFile(name, path, info)
+	})
+}
+
+// envSource returns true if `name` is an env file the environment of
+// the function is read from, such as .env, .env.<stage> or .env.enc,
+// which must not be shipped as they hold the variables of every stage,
+// in plaintext or encrypted. The generated .env.json is not.
+func envSource(name string) bool {
+	return name == ".env" || strings.HasPrefix(name, ".env.") && name != ".env.json"
+}
+
+// rendered is the file info of a rendered file, keeping its mode.
+type rendered struct {
+	os.FileInfo
+	size int
+}
+
+func (r rendered) Size() int64 { return int64(r.size) }
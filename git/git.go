@@ -0,0 +1,140 @@
+// Package git provides repository metadata used to stamp deploys.
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Info describes the state of a repository's working tree,
+// and the subject and committer time of the HEAD commit.
+type Info struct {
+	Commit  string
+	Branch  string
+	Tag     string
+	Dirty   bool
+	Message string
+	Time    time.Time
+}
+
+// Describe returns the Info of the repository containing `dir`.
+func Describe(dir string) (*Info, error) {
+	commit, err := run(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	branch, err := run(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	if branch == "HEAD" {
+		branch = ""
+	}
+
+	status, err := run(dir, "status", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+
+	// no tag at HEAD is not an error
+	tag, _ := run(dir, "describe", "--tags", "--exact-match", "HEAD")
+
+	message, err := run(dir, "log", "-1", "--format=%s")
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := run(dir, "log", "-1", "--format=%ct")
+	if err != nil {
+		return nil, err
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing commit time %q: %s", timestamp, err)
+	}
+
+	return &Info{
+		Commit:  commit,
+		Branch:  branch,
+		Tag:     tag,
+		Dirty:   status != "",
+		Message: message,
+		Time:    time.Unix(unix, 0).UTC(),
+	}, nil
+}
+
+// Short returns the abbreviated commit SHA.
+func (i *Info) Short() string {
+	if len(i.Commit) > 7 {
+		return i.Commit[:7]
+	}
+	return i.Commit
+}
+
+// String returns a description such as "abc1234 v1.2.0 (master, dirty)".
+func (i *Info) String() string {
+	s := i.Short()
+
+	if i.Tag != "" {
+		s += " " + i.Tag
+	}
+
+	var extra []string
+
+	if i.Branch != "" {
+		extra = append(extra, i.Branch)
+	}
+
+	if i.Dirty {
+		extra = append(extra, "dirty")
+	}
+
+	if len(extra) > 0 {
+		s += " (" + strings.Join(extra, ", ") + ")"
+	}
+
+	return s
+}
+
+// Env returns the metadata as environment variables.
+func (i *Info) Env() map[string]string {
+	return map[string]string{
+		"APEX_GIT_COMMIT": i.Commit,
+		"APEX_GIT_BRANCH": i.Branch,
+		"APEX_GIT_TAG":    i.Tag,
+		"APEX_GIT_DIRTY":  strconv.FormatBool(i.Dirty),
+	}
+}
+
+// Tags returns the metadata as resource tags.
+func (i *Info) Tags() map[string]string {
+	return map[string]string{
+		"apex:git-commit": i.Commit,
+		"apex:git-branch": i.Branch,
+		"apex:git-tag":    i.Tag,
+		"apex:git-dirty":  strconv.FormatBool(i.Dirty),
+	}
+}
+
+// run git with `args` in `dir`, returning trimmed stdout.
+func run(dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
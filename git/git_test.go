@@ -0,0 +1,28 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfo_String(t *testing.T) {
+	i := &Info{Commit: "abc1234def", Branch: "master", Tag: "v1.2.0", Dirty: true}
+	assert.Equal(t, "abc1234 v1.2.0 (master, dirty)", i.String())
+
+	i = &Info{Commit: "abc1234def"}
+	assert.Equal(t, "abc1234", i.String())
+}
+
+func TestInfo_Env(t *testing.T) {
+	i := &Info{Commit: "abc1234def", Branch: "master"}
+	env := i.Env()
+	assert.Equal(t, "abc1234def", env["APEX_GIT_COMMIT"])
+	assert.Equal(t, "master", env["APEX_GIT_BRANCH"])
+	assert.Equal(t, "false", env["APEX_GIT_DIRTY"])
+}
+
+func TestDescribe_notRepository(t *testing.T) {
+	_, err := Describe("/")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,246 @@
+// Package lock implements deploy locks backed by DynamoDB conditional
+// writes, preventing concurrent deploys of the same function. Locks are
+// renewed while held, so that long deploys do not outlive them.
+//
+// The table must have a string partition key named "name".
+package lock
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// DefaultTTL is the duration after which a lock which is no longer
+// renewed, such as that of a killed deploy, is considered stale. Held
+// locks are renewed every third of it.
+const DefaultTTL = 15 * time.Minute
+
+// ErrLocked is returned when a lock is held by another owner.
+type ErrLocked struct {
+	Name     string
+	Owner    string
+	Acquired time.Time
+}
+
+// Error message.
+func (e *ErrLocked) Error() string {
+	return fmt.Sprintf("%s is locked by %s since %s, use `apex unlock` if the lock is stale", e.Name, e.Owner, e.Acquired.Format(time.RFC3339))
+}
+
+// Lock implements function.Locker with a DynamoDB table.
+type Lock struct {
+	Service dynamodbiface.DynamoDBAPI
+	Log     log.Interface
+	Table   string
+	Owner   string
+	TTL     time.Duration
+	now     func() time.Time
+
+	mu       sync.Mutex
+	renewals map[string]chan struct{}
+}
+
+// defaults applies configuration defaults.
+func (l *Lock) defaults() {
+	if l.Owner == "" {
+		l.Owner = DefaultOwner()
+	}
+
+	if l.TTL == 0 {
+		l.TTL = DefaultTTL
+	}
+
+	if l.now == nil {
+		l.now = time.Now
+	}
+}
+
+// DefaultOwner returns "user@host:pid" identifying this process.
+func DefaultOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s@%s:%d", os.Getenv("USER"), host, os.Getpid())
+}
+
+// Lock acquires the lock `name`, taking over locks which have
+// expired, or returns ErrLocked when held by another owner.
+func (l *Lock) Lock(name string) error {
+	l.defaults()
+	now := l.now()
+
+	l.Log.Debugf("acquiring lock %s as %s", name, l.Owner)
+
+	_, err := l.Service.PutItem(&dynamodb.PutItemInput{
+		TableName: &l.Table,
+		Item: map[string]*dynamodb.AttributeValue{
+			"name":     {S: &name},
+			"owner":    {S: &l.Owner},
+			"acquired": number(now.Unix()),
+			"expires":  number(now.Add(l.TTL).Unix()),
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#name) OR #expires < :now OR #owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{
+			"#name":    aws.String("name"),
+			"#expires": aws.String("expires"),
+			"#owner":   aws.String("owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now":   number(now.Unix()),
+			":owner": {S: &l.Owner},
+		},
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return l.locked(name)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.renewals == nil {
+		l.renewals = make(map[string]chan struct{})
+	}
+
+	if l.renewals[name] == nil {
+		stop := make(chan struct{})
+		l.renewals[name] = stop
+		go l.renew(name, stop)
+	}
+
+	return nil
+}
+
+// renew extends the expiry of lock `name` every third of the TTL until
+// `stop` is closed or the lock is taken over.
+func (l *Lock) renew(name string, stop chan struct{}) {
+	t := time.NewTicker(l.TTL / 3)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+
+		l.Log.Debugf("renewing lock %s", name)
+
+		_, err := l.Service.UpdateItem(&dynamodb.UpdateItemInput{
+			TableName:           &l.Table,
+			Key:                 map[string]*dynamodb.AttributeValue{"name": {S: &name}},
+			UpdateExpression:    aws.String("SET #expires = :expires"),
+			ConditionExpression: aws.String("#owner = :owner"),
+			ExpressionAttributeNames: map[string]*string{
+				"#expires": aws.String("expires"),
+				"#owner":   aws.String("owner"),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":expires": number(l.now().Add(l.TTL).Unix()),
+				":owner":   {S: &l.Owner},
+			},
+		})
+
+		if e, ok := err.(awserr.Error); ok && e.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			l.Log.Warnf("lock %s was taken over by another owner", name)
+			return
+		}
+
+		if err != nil {
+			l.Log.Warnf("error renewing lock %s: %s", name, err)
+		}
+	}
+}
+
+// stopRenewal stops renewing lock `name`, if renewed.
+func (l *Lock) stopRenewal(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if stop, ok := l.renewals[name]; ok {
+		close(stop)
+		delete(l.renewals, name)
+	}
+}
+
+// Unlock releases the lock `name` if held by this owner.
+func (l *Lock) Unlock(name string) error {
+	l.defaults()
+	l.stopRenewal(name)
+
+	l.Log.Debugf("releasing lock %s", name)
+
+	_, err := l.Service.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName:           &l.Table,
+		Key:                 map[string]*dynamodb.AttributeValue{"name": {S: &name}},
+		ConditionExpression: aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: &l.Owner},
+		},
+	})
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		l.Log.Warnf("lock %s was taken over by another owner", name)
+		return nil
+	}
+
+	return err
+}
+
+// ForceUnlock releases the lock `name` regardless of its owner.
+func (l *Lock) ForceUnlock(name string) error {
+	l.Log.Infof("force unlocking %s", name)
+	l.stopRenewal(name)
+
+	_, err := l.Service.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: &l.Table,
+		Key:       map[string]*dynamodb.AttributeValue{"name": {S: &name}},
+	})
+
+	return err
+}
+
+// locked returns ErrLocked describing the current holder of `name`.
+func (l *Lock) locked(name string) error {
+	res, err := l.Service.GetItem(&dynamodb.GetItemInput{
+		TableName:      &l.Table,
+		Key:            map[string]*dynamodb.AttributeValue{"name": {S: &name}},
+		ConsistentRead: aws.Bool(true),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	e := &ErrLocked{Name: name}
+
+	if v := res.Item["owner"]; v != nil {
+		e.Owner = aws.StringValue(v.S)
+	}
+
+	if v := res.Item["acquired"]; v != nil {
+		n, _ := strconv.ParseInt(aws.StringValue(v.N), 10, 64)
+		e.Acquired = time.Unix(n, 0)
+	}
+
+	return e
+}
+
+// number returns a numeric attribute value.
+func number(n int64) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(n, 10))}
+}
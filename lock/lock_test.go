@@ -0,0 +1,132 @@
+package lock
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// table is an in-memory table which evaluates the lock condition.
+type table struct {
+	dynamodbiface.DynamoDBAPI
+	mu      sync.Mutex
+	items   map[string]map[string]*dynamodb.AttributeValue
+	renewed int
+}
+
+func (t *table) PutItem(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	name := *in.Item["name"].S
+	now := *in.ExpressionAttributeValues[":now"].N
+	owner := *in.ExpressionAttributeValues[":owner"].S
+
+	if item, ok := t.items[name]; ok && *item["expires"].N >= now && *item["owner"].S != owner {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "condition failed", nil)
+	}
+
+	t.items[name] = in.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (t *table) UpdateItem(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	item, ok := t.items[*in.Key["name"].S]
+	if !ok || *item["owner"].S != *in.ExpressionAttributeValues[":owner"].S {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "condition failed", nil)
+	}
+
+	item["expires"] = in.ExpressionAttributeValues[":expires"]
+	t.renewed++
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+// renewals returns the number of renewals.
+func (t *table) renewals() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.renewed
+}
+
+func (t *table) GetItem(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return &dynamodb.GetItemOutput{Item: t.items[*in.Key["name"].S]}, nil
+}
+
+func (t *table) DeleteItem(in *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.items, *in.Key["name"].S)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func newLock(t *table, owner string, now time.Time) *Lock {
+	return &Lock{
+		Service: t,
+		Log:     log.Log,
+		Table:   "locks",
+		Owner:   owner,
+		now:     func() time.Time { return now },
+	}
+}
+
+func TestLock_Lock(t *testing.T) {
+	db := &table{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+	now := time.Unix(1000, 0)
+
+	assert.Nil(t, newLock(db, "tj", now).Lock("foo"))
+
+	err := newLock(db, "bob", now).Lock("foo")
+	e, ok := err.(*ErrLocked)
+	assert.True(t, ok)
+	assert.Equal(t, "tj", e.Owner)
+	assert.Equal(t, now, e.Acquired)
+
+	assert.Nil(t, newLock(db, "bob", now).Lock("bar"))
+}
+
+func TestLock_Lock_stale(t *testing.T) {
+	db := &table{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+	now := time.Unix(1000, 0)
+
+	assert.Nil(t, newLock(db, "tj", now).Lock("foo"))
+	assert.Nil(t, newLock(db, "bob", now.Add(DefaultTTL+time.Second)).Lock("foo"))
+	assert.Equal(t, aws.String("bob"), db.items["foo"]["owner"].S)
+}
+
+func TestLock_ForceUnlock(t *testing.T) {
+	db := &table{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+	now := time.Unix(1000, 0)
+
+	assert.Nil(t, newLock(db, "tj", now).Lock("foo"))
+	assert.Nil(t, newLock(db, "bob", now).ForceUnlock("foo"))
+	assert.Nil(t, newLock(db, "bob", now).Lock("foo"))
+}
+
+func TestLock_renew(t *testing.T) {
+	db := &table{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+	l := &Lock{Service: db, Log: log.Log, Table: "locks", Owner: "tj", TTL: 30 * time.Millisecond}
+	assert.Nil(t, l.Lock("foo"))
+
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, db.renewals() > 0)
+
+	assert.Nil(t, l.Unlock("foo"))
+	renewals := db.renewals()
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, renewals, db.renewals())
+}
@@ -0,0 +1,300 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Line kinds.
+const (
+	Start  = "start"
+	End    = "end"
+	Report = "report"
+	Output = "output"
+)
+
+// Line is a parsed Lambda log line.
+type Line struct {
+	Kind      string
+	RequestID string
+	Level     string
+	Timestamp string
+	Message   string
+}
+
+// uuid pattern for request ids.
+const uuid = `[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`
+
+var (
+	platform = regexp.MustCompile(`^(START|END|REPORT) RequestId: (` + uuid + `)\s?(.*)$`)
+	nodejs   = regexp.MustCompile(`^(\S+Z)\t(` + uuid + `)\t([A-Z]+)\t(.*)$`)
+	python   = regexp.MustCompile(`^\[([A-Z]+)\]\t(\S+Z)\t(` + uuid + `)\t(.*)$`)
+	legacy   = regexp.MustCompile(`^(\S+Z)\t(` + uuid + `)\t(.*)$`)
+)
+
+// Parse a log line, recognizing the platform START, END and REPORT lines,
+// the Node.js and Python runtime formats, and JSON lines with "level"
+// and "message" or "msg" fields.
+func Parse(s string) *Line {
+	s = strings.TrimRight(s, "\r\n")
+
+	if m := platform.FindStringSubmatch(s); m != nil {
+		return &Line{Kind: strings.ToLower(m[1]), RequestID: m[2], Message: m[3]}
+	}
+
+	if m := nodejs.FindStringSubmatch(s); m != nil {
+		return parseMessage(&Line{Kind: Output, Timestamp: m[1], RequestID: m[2], Level: m[3]}, m[4])
+	}
+
+	if m := python.FindStringSubmatch(s); m != nil {
+		return parseMessage(&Line{Kind: Output, Level: m[1], Timestamp: m[2], RequestID: m[3]}, m[4])
+	}
+
+	if m := legacy.FindStringSubmatch(s); m != nil {
+		return parseMessage(&Line{Kind: Output, Timestamp: m[1], RequestID: m[2]}, m[3])
+	}
+
+	return parseMessage(&Line{Kind: Output}, s)
+}
+
+// parseMessage sets the message of `l`, unwrapping JSON log lines.
+func parseMessage(l *Line, s string) *Line {
+	l.Message = s
+
+	if !strings.HasPrefix(s, "{") {
+		return l
+	}
+
+	var v map[string]interface{}
+	if json.Unmarshal([]byte(s), &v) != nil {
+		return l
+	}
+
+	if level, ok := v["level"].(string); ok {
+		l.Level = strings.ToUpper(level)
+		delete(v, "level")
+	}
+
+	if id, ok := v["requestId"].(string); ok {
+		l.RequestID = id
+		delete(v, "requestId")
+	}
+
+	if ts, ok := v["timestamp"].(string); ok {
+		l.Timestamp = ts
+		delete(v, "timestamp")
+	}
+
+	for _, key := range []string{"message", "msg"} {
+		if msg, ok := v[key].(string); ok {
+			delete(v, key)
+			l.Message = msg
+
+			var keys []string
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				l.Message += fmt.Sprintf(" %s=%v", k, v[k])
+			}
+
+			break
+		}
+	}
+
+	return l
+}
+
+// Colors.
+const (
+	none    = 0
+	red     = 31
+	green   = 32
+	yellow  = 33
+	blue    = 34
+	magenta = 35
+	cyan    = 36
+	gray    = 90
+)
+
+// prefixColors are assigned to prefixes in turn.
+var prefixColors = []int{cyan, magenta, green, yellow, blue}
+
+// levelColors maps levels to colors.
+var levelColors = map[string]int{
+	"TRACE":    gray,
+	"DEBUG":    gray,
+	"INFO":     blue,
+	"WARN":     yellow,
+	"WARNING":  yellow,
+	"ERROR":    red,
+	"FATAL":    red,
+	"CRITICAL": red,
+}
+
+// Formatter is a writer which outputs prettified log lines, grouped by request id.
+type Formatter struct {
+	w       io.Writer
+	color   bool
+	buf     bytes.Buffer
+	request string
+	prefix  string
+}
+
+// NewFormatter returns a formatter writing to `w`, colorized when `color` is true.
+func NewFormatter(w io.Writer, color bool) *Formatter {
+	return &Formatter{w: w, color: color}
+}
+
+// SetPrefix sets the prefix of each line, such as the function name, colored
+// by index `i` so that lines of several formatters may be told apart.
+func (f *Formatter) SetPrefix(s string, i int) {
+	f.prefix = f.paint(prefixColors[i%len(prefixColors)], s) + " "
+}
+
+// Write implements io.Writer, formatting complete lines.
+func (f *Formatter) Write(b []byte) (int, error) {
+	f.buf.Write(b)
+
+	for {
+		i := bytes.IndexByte(f.buf.Bytes(), '\n')
+		if i == -1 {
+			break
+		}
+
+		line := string(f.buf.Next(i + 1))
+		if err := f.format(Parse(line)); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// Flush formats any remaining partial line.
+func (f *Formatter) Flush() error {
+	if f.buf.Len() == 0 {
+		return nil
+	}
+
+	line := f.buf.String()
+	f.buf.Reset()
+	return f.format(Parse(line))
+}
+
+// format writes line `l`.
+func (f *Formatter) format(l *Line) error {
+	if l.RequestID != "" && l.RequestID != f.request {
+		f.request = l.RequestID
+		if _, err := fmt.Fprintf(f.w, "\n%s  %s\n", f.prefix, f.paint(gray, "request "+l.RequestID)); err != nil {
+			return err
+		}
+	}
+
+	var err error
+
+	switch l.Kind {
+	case Start, End:
+	case Report:
+		_, err = fmt.Fprintf(f.w, "%s  %s\n", f.prefix, f.paint(gray, summary(l.Message)))
+	default:
+		if l.Message == "" {
+			return nil
+		}
+
+		level := l.Level
+		if level == "" {
+			level = "-"
+		}
+
+		_, err = fmt.Fprintf(f.w, "%s  %s %s\n", f.prefix, f.paint(levelColors[level], fmt.Sprintf("%5s", level)), l.Message)
+	}
+
+	return err
+}
+
+// paint `s` with `color` when colors are enabled.
+func (f *Formatter) paint(color int, s string) string {
+	if !f.color || color == none {
+		return s
+	}
+	return fmt.Sprintf("\033[%dm%s\033[0m", color, s)
+}
+
+// summary of the REPORT line fields.
+func summary(s string) string {
+	var parts []string
+
+	for _, field := range strings.Split(s, "\t") {
+		kv := strings.SplitN(field, ": ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch strings.TrimSpace(kv[0]) {
+		case "Duration", "Billed Duration", "Max Memory Used", "Init Duration":
+			parts = append(parts, strings.ToLower(strings.TrimSpace(kv[0]))+" "+strings.TrimSpace(kv[1]))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Usage is the resource usage of an invocation, reported by REPORT lines.
+type Usage struct {
+	Duration       time.Duration
+	BilledDuration time.Duration
+	InitDuration   time.Duration
+	MemorySize     int
+	MaxMemoryUsed  int
+}
+
+// ParseUsage parses the message of a REPORT line, with
+// durations in milliseconds and memory in megabytes.
+func ParseUsage(s string) Usage {
+	var u Usage
+
+	for _, field := range strings.Split(s, "\t") {
+		kv := strings.SplitN(field, ": ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		value := strings.Fields(kv[1])
+		if len(value) == 0 {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value[0], 64)
+		if err != nil {
+			continue
+		}
+
+		ms := time.Duration(math.Round(n * float64(time.Millisecond)))
+
+		switch strings.TrimSpace(kv[0]) {
+		case "Duration":
+			u.Duration = ms
+		case "Billed Duration":
+			u.BilledDuration = ms
+		case "Init Duration":
+			u.InitDuration = ms
+		case "Memory Size":
+			u.MemorySize = int(n)
+		case "Max Memory Used":
+			u.MaxMemoryUsed = int(n)
+		}
+	}
+
+	return u
+}
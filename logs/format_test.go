@@ -0,0 +1,60 @@
+package logs
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	l := Parse("START RequestId: 6f1c0c1e-1a0a-4f1e-9c3a-9e0d7f6c7e9b Version: 3\n")
+	assert.Equal(t, Start, l.Kind)
+	assert.Equal(t, "6f1c0c1e-1a0a-4f1e-9c3a-9e0d7f6c7e9b", l.RequestID)
+
+	l = Parse("2024-01-02T03:04:05.678Z\t6f1c0c1e-1a0a-4f1e-9c3a-9e0d7f6c7e9b\tWARN\tslow query\n")
+	assert.Equal(t, "WARN", l.Level)
+	assert.Equal(t, "slow query", l.Message)
+
+	l = Parse("[ERROR]\t2024-01-02T03:04:05.678Z\t6f1c0c1e-1a0a-4f1e-9c3a-9e0d7f6c7e9b\tboom\n")
+	assert.Equal(t, "ERROR", l.Level)
+	assert.Equal(t, "boom", l.Message)
+
+	l = Parse(`{"level":"info","msg":"hello","user":"tj"}`)
+	assert.Equal(t, "INFO", l.Level)
+	assert.Equal(t, "hello user=tj", l.Message)
+}
+
+func TestFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFormatter(&buf, false)
+
+	f.Write([]byte("START RequestId: 6f1c0c1e-1a0a-4f1e-9c3a-9e0d7f6c7e9b Version: 3\n2024-01-02T03:04:05.678Z\t6f1c0c1e-1a0a-4f1e-9c3a-9e0d7f6c7e9b\tINFO\thello\n"))
+	f.Write([]byte("END RequestId: 6f1c0c1e-1a0a-4f1e-9c3a-9e0d7f6c7e9b\nREPORT RequestId: 6f1c0c1e-1a0a-4f1e-9c3a-9e0d7f6c7e9b\tDuration: 12.34 ms\tBilled Duration: 13 ms\tMemory Size: 128 MB\tMax Memory Used: 64 MB\t"))
+	f.Flush()
+
+	assert.Equal(t, "\n  request 6f1c0c1e-1a0a-4f1e-9c3a-9e0d7f6c7e9b\n   INFO hello\n  duration 12.34 ms, billed duration 13 ms, max memory used 64 MB\n", buf.String())
+}
+
+func TestFormatter_SetPrefix(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFormatter(&buf, false)
+	f.SetPrefix("foo", 0)
+
+	f.Write([]byte("2024-01-02T03:04:05.678Z\t6f1c0c1e-1a0a-4f1e-9c3a-9e0d7f6c7e9b\tINFO\thello\n"))
+
+	assert.Equal(t, "\nfoo   request 6f1c0c1e-1a0a-4f1e-9c3a-9e0d7f6c7e9b\nfoo    INFO hello\n", buf.String())
+}
+
+func TestParseUsage(t *testing.T) {
+	l := Parse("REPORT RequestId: 6f1c0c1e-1a0a-4f1e-9c3a-9e0d7f6c7e9b\tDuration: 12.34 ms\tBilled Duration: 13 ms\tMemory Size: 128 MB\tMax Memory Used: 64 MB\tInit Duration: 150.5 ms\t")
+	assert.Equal(t, Report, l.Kind)
+
+	u := ParseUsage(l.Message)
+	assert.Equal(t, 12340*time.Microsecond, u.Duration)
+	assert.Equal(t, 13*time.Millisecond, u.BilledDuration)
+	assert.Equal(t, 150500*time.Microsecond, u.InitDuration)
+	assert.Equal(t, 128, u.MemorySize)
+	assert.Equal(t, 64, u.MaxMemoryUsed)
+}
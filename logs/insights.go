@@ -0,0 +1,146 @@
+package logs
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+)
+
+// Queries are saved Logs Insights query templates by name.
+var Queries = map[string]string{
+	"errors": `fields @timestamp, @requestId, @message
+| filter @message like /(?i)(error|exception|task timed out)/
+| sort @timestamp desc`,
+
+	"slowest": `filter @type = "REPORT"
+| fields @timestamp, @requestId, @duration, @billedDuration, @maxMemoryUsed / 1000 / 1000 as memoryMB
+| sort @duration desc`,
+
+	"cold-starts": `filter @type = "REPORT" and ispresent(@initDuration)
+| fields @timestamp, @requestId, @initDuration, @duration
+| sort @initDuration desc`,
+
+	"memory": `filter @type = "REPORT"
+| stats max(@maxMemoryUsed / 1000 / 1000) as maxMemoryMB, avg(@maxMemoryUsed / 1000 / 1000) as avgMemoryMB, max(@memorySize / 1000 / 1000) as memorySizeMB by bin(1h)`,
+
+	"recent": `fields @timestamp, @requestId, @message
+| sort @timestamp desc`,
+}
+
+// QueryNames returns the sorted names of the saved queries.
+func QueryNames() (list []string) {
+	for name := range Queries {
+		list = append(list, name)
+	}
+
+	sort.Strings(list)
+	return list
+}
+
+// Row is a query result row of fields by name.
+type Row map[string]string
+
+// Query runs Logs Insights queries against log groups.
+type Query struct {
+	Service       cloudwatchlogsiface.CloudWatchLogsAPI
+	Log           log.Interface
+	LogGroupNames []string
+
+	// Query string, or the name of one of Queries.
+	Query string
+
+	// Start and End of the queried period, defaulting to the last hour.
+	Start time.Time
+	End   time.Time
+
+	// Limit of rows returned, defaulting to 100.
+	Limit int64
+
+	// PollInterval for query results, defaulting to one second.
+	PollInterval time.Duration
+}
+
+// Run the query and wait for its completion, returning the resulting rows.
+func (q *Query) Run() ([]Row, error) {
+	query := q.Query
+	if s, ok := Queries[query]; ok {
+		query = s
+	}
+
+	end := q.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	start := q.Start
+	if start.IsZero() {
+		start = end.Add(-time.Hour)
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = 100
+	}
+
+	interval := q.PollInterval
+	if interval == 0 {
+		interval = time.Second
+	}
+
+	q.Log.Debugf("querying %v from %s to %s", q.LogGroupNames, start, end)
+
+	res, err := q.Service.StartQuery(&cloudwatchlogs.StartQueryInput{
+		LogGroupNames: aws.StringSlice(q.LogGroupNames),
+		QueryString:   &query,
+		StartTime:     aws.Int64(start.Unix()),
+		EndTime:       aws.Int64(end.Unix()),
+		Limit:         &limit,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		out, err := q.Service.GetQueryResults(&cloudwatchlogs.GetQueryResultsInput{
+			QueryId: res.QueryId,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch status := aws.StringValue(out.Status); status {
+		case cloudwatchlogs.QueryStatusComplete:
+			return rows(out.Results), nil
+		case cloudwatchlogs.QueryStatusScheduled, cloudwatchlogs.QueryStatusRunning:
+			time.Sleep(interval)
+		default:
+			return nil, fmt.Errorf("query %s", status)
+		}
+	}
+}
+
+// rows returns the rows of query `results`, omitting the @ptr field.
+func rows(results [][]*cloudwatchlogs.ResultField) []Row {
+	var list []Row
+
+	for _, fields := range results {
+		row := make(Row)
+
+		for _, f := range fields {
+			if name := aws.StringValue(f.Field); name != "@ptr" {
+				row[name] = aws.StringValue(f.Value)
+			}
+		}
+
+		list = append(list, row)
+	}
+
+	return list
+}
@@ -0,0 +1,57 @@
+package logs
+
+import (
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type insights struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	query  string
+	polled int
+}
+
+func (i *insights) StartQuery(in *cloudwatchlogs.StartQueryInput) (*cloudwatchlogs.StartQueryOutput, error) {
+	i.query = *in.QueryString
+	return &cloudwatchlogs.StartQueryOutput{QueryId: aws.String("1")}, nil
+}
+
+func (i *insights) GetQueryResults(in *cloudwatchlogs.GetQueryResultsInput) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	i.polled++
+
+	if i.polled == 1 {
+		return &cloudwatchlogs.GetQueryResultsOutput{Status: aws.String(cloudwatchlogs.QueryStatusRunning)}, nil
+	}
+
+	return &cloudwatchlogs.GetQueryResultsOutput{
+		Status: aws.String(cloudwatchlogs.QueryStatusComplete),
+		Results: [][]*cloudwatchlogs.ResultField{{
+			{Field: aws.String("@requestId"), Value: aws.String("abc")},
+			{Field: aws.String("@duration"), Value: aws.String("1500")},
+			{Field: aws.String("@ptr"), Value: aws.String("ptr")},
+		}},
+	}, nil
+}
+
+func TestQuery_Run(t *testing.T) {
+	service := &insights{}
+
+	q := &Query{
+		Service:       service,
+		Log:           log.Log,
+		LogGroupNames: []string{"/aws/lambda/app_foo"},
+		Query:         "slowest",
+		PollInterval:  1,
+	}
+
+	rows, err := q.Run()
+	assert.Nil(t, err)
+	assert.Equal(t, Queries["slowest"], service.query)
+	assert.Equal(t, 2, service.polled)
+	assert.Equal(t, []Row{{"@requestId": "abc", "@duration": "1500"}}, rows)
+}
@@ -1,4 +1,4 @@
-// Package logs implements AWS CloudWatchLogs tailing.
+// Package logs implements AWS CloudWatchLogs tailing and querying.
 package logs
 
 import (
@@ -0,0 +1,74 @@
+package logs
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// Event is a log event of a named log group.
+type Event struct {
+	Name string
+	*cloudwatchlogs.FilteredLogEvent
+}
+
+// Multi tails several log groups at once, interleaving their events.
+type Multi struct {
+	// Logs to tail by name, such as the function name.
+	Logs map[string]*Logs
+
+	mu  sync.Mutex
+	err error
+}
+
+// Tail logs, make sure to check Err() after the returned channel closes.
+func (m *Multi) Tail() <-chan *Event {
+	ch := make(chan *Event)
+	var wg sync.WaitGroup
+
+	for name, l := range m.Logs {
+		name, l := name, l
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for event := range l.Tail() {
+				ch <- &Event{Name: name, FilteredLogEvent: event}
+			}
+
+			if err := l.Err(); err != nil {
+				m.mu.Lock()
+				if m.err == nil {
+					m.err = err
+				}
+				m.mu.Unlock()
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+
+	return ch
+}
+
+// Names returns the sorted names of the tailed logs.
+func (m *Multi) Names() (list []string) {
+	for name := range m.Logs {
+		list = append(list, name)
+	}
+
+	sort.Strings(list)
+	return list
+}
+
+// Err returns the first error, if any, during processing.
+func (m *Multi) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
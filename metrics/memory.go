@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Memory is a Registry keeping metrics in memory, served in the
+// Prometheus text exposition format.
+type Memory struct {
+	mu      sync.Mutex
+	metrics []*metric
+}
+
+// metric is a counter or histogram and its series.
+type metric struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+// series of a metric with label values.
+type series struct {
+	values  []string
+	value   float64
+	sum     float64
+	count   uint64
+	buckets []uint64
+}
+
+// Counter implementation.
+func (m *Memory) Counter(name, help string, labels []string) Counter {
+	return &memoryCounter{m, m.register(name, help, "counter", labels, nil)}
+}
+
+// Histogram implementation.
+func (m *Memory) Histogram(name, help string, buckets []float64, labels []string) Histogram {
+	return &memoryHistogram{m, m.register(name, help, "histogram", labels, buckets)}
+}
+
+// register a metric.
+func (m *Memory) register(name, help, kind string, labels []string, buckets []float64) *metric {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	v := &metric{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+
+	m.metrics = append(m.metrics, v)
+	return v
+}
+
+// get returns the series with label `values`, creating it when missing.
+func (v *metric) get(values []string) *series {
+	key := strings.Join(values, "\xff")
+
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{values: values, buckets: make([]uint64, len(v.buckets))}
+		v.series[key] = s
+	}
+
+	return s
+}
+
+// memoryCounter is a Counter of Memory.
+type memoryCounter struct {
+	m *Memory
+	v *metric
+}
+
+// Inc implementation.
+func (c *memoryCounter) Inc(values ...string) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	c.v.get(values).value++
+}
+
+// memoryHistogram is a Histogram of Memory.
+type memoryHistogram struct {
+	m *Memory
+	v *metric
+}
+
+// Observe implementation.
+func (h *memoryHistogram) Observe(n float64, values ...string) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+
+	s := h.v.get(values)
+	s.sum += n
+	s.count++
+
+	for i, le := range h.v.buckets {
+		if n <= le {
+			s.buckets[i]++
+		}
+	}
+}
+
+// WriteTo writes the metrics to `w` in the Prometheus text format.
+func (m *Memory) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+
+	for _, v := range m.metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n", v.name, v.help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", v.name, v.kind)
+
+		var keys []string
+		for k := range v.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			s := v.series[k]
+
+			if v.kind == "counter" {
+				fmt.Fprintf(&b, "%s%s %s\n", v.name, labels(v.labels, s.values), number(s.value))
+				continue
+			}
+
+			for i, le := range v.buckets {
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", v.name, labels(v.labels, s.values, "le", number(le)), s.buckets[i])
+			}
+
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", v.name, labels(v.labels, s.values, "le", "+Inf"), s.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", v.name, labels(v.labels, s.values), number(s.sum))
+			fmt.Fprintf(&b, "%s_count%s %d\n", v.name, labels(v.labels, s.values), s.count)
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics for scraping.
+func (m *Memory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+// escape label values.
+var escape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels returns the label set of `names` and `values`,
+// followed by the `extra` name and value pairs.
+func labels(names, values []string, extra ...string) string {
+	var pairs []string
+
+	for i, name := range names {
+		var value string
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escape.Replace(value)))
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escape.Replace(extra[i+1])))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// number formats `n` as a sample value.
+func number(n float64) string {
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
@@ -0,0 +1,116 @@
+// Package metrics records Prometheus-style metrics of deploys, zips,
+// invocations and AWS API errors, for programs embedding apex such as
+// deployment services. Metrics are created in a Registry, which may
+// adapt a metrics library such as the Prometheus client, or be Memory.
+package metrics
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Statuses of operations.
+const (
+	Success = "success"
+	Failure = "failure"
+)
+
+// Registry creates metrics with the given label names.
+type Registry interface {
+	Counter(name, help string, labels []string) Counter
+	Histogram(name, help string, buckets []float64, labels []string) Histogram
+}
+
+// Counter is a cumulative metric.
+type Counter interface {
+	// Inc increments the counter of the series with label `values`.
+	Inc(values ...string)
+}
+
+// Histogram is a metric counting observations in buckets.
+type Histogram interface {
+	// Observe `v` in the series with label `values`.
+	Observe(v float64, values ...string)
+}
+
+// Buckets of the histograms.
+var (
+	DurationBuckets = []float64{1, 2, 5, 10, 30, 60, 120, 300, 600}
+	SizeBuckets     = []float64{1 << 10, 1 << 14, 1 << 18, 1 << 20, 1 << 22, 1 << 24, 1 << 26, 1 << 28}
+	LatencyBuckets  = []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+)
+
+// Metrics of apex operations. The methods of a nil
+// Metrics do nothing, so metrics are optional.
+type Metrics struct {
+	deployDuration Histogram
+	zipSize        Histogram
+	invokeDuration Histogram
+	awsErrors      Counter
+}
+
+// New returns metrics created in `r`.
+func New(r Registry) *Metrics {
+	return &Metrics{
+		deployDuration: r.Histogram("apex_deploy_duration_seconds", "Duration of function deploys.", DurationBuckets, []string{"function", "status"}),
+		zipSize:        r.Histogram("apex_zip_size_bytes", "Size of function zips.", SizeBuckets, []string{"function"}),
+		invokeDuration: r.Histogram("apex_invoke_duration_seconds", "Latency of function invocations.", LatencyBuckets, []string{"function", "status"}),
+		awsErrors:      r.Counter("apex_aws_errors_total", "AWS API request errors.", []string{"service", "operation", "code"}),
+	}
+}
+
+// Status returns Failure when `err` is non-nil, otherwise Success.
+func Status(err error) string {
+	if err != nil {
+		return Failure
+	}
+	return Success
+}
+
+// Deploy records a deploy of `function` taking `d`.
+func (m *Metrics) Deploy(function string, d time.Duration, status string) {
+	if m == nil {
+		return
+	}
+	m.deployDuration.Observe(d.Seconds(), function, status)
+}
+
+// Zip records a zip of `function` of `size` bytes.
+func (m *Metrics) Zip(function string, size int) {
+	if m == nil {
+		return
+	}
+	m.zipSize.Observe(float64(size), function)
+}
+
+// Invoke records an invocation of `function` taking `d`.
+func (m *Metrics) Invoke(function string, d time.Duration, status string) {
+	if m == nil {
+		return
+	}
+	m.invokeDuration.Observe(d.Seconds(), function, status)
+}
+
+// Instrument counts the failed requests of clients created from `s`
+// afterwards by service, operation and error code.
+func (m *Metrics) Instrument(s *session.Session) {
+	if m == nil {
+		return
+	}
+
+	s.Handlers.Complete.PushBack(func(r *request.Request) {
+		if r.Error == nil {
+			return
+		}
+
+		code := "Unknown"
+		if e, ok := r.Error.(awserr.Error); ok {
+			code = e.Code()
+		}
+
+		m.awsErrors.Inc(r.ClientInfo.ServiceName, r.Operation.Name, code)
+	})
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_nil(t *testing.T) {
+	var m *Metrics
+	m.Deploy("api", time.Second, Success)
+	m.Zip("api", 1024)
+	m.Invoke("api", time.Second, Success)
+}
+
+func TestMemory(t *testing.T) {
+	r := new(Memory)
+	m := New(r)
+
+	m.Deploy("api", 3*time.Second, Status(nil))
+	m.Deploy("api", 45*time.Second, Status(errors.New("boom")))
+	m.Invoke("api", 20*time.Millisecond, Success)
+
+	var buf bytes.Buffer
+	_, err := r.WriteTo(&buf)
+	assert.Nil(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE apex_deploy_duration_seconds histogram\n")
+	assert.Contains(t, out, `apex_deploy_duration_seconds_bucket{function="api",status="success",le="2"} 0`)
+	assert.Contains(t, out, `apex_deploy_duration_seconds_bucket{function="api",status="success",le="5"} 1`)
+	assert.Contains(t, out, `apex_deploy_duration_seconds_bucket{function="api",status="failure",le="+Inf"} 1`)
+	assert.Contains(t, out, `apex_deploy_duration_seconds_sum{function="api",status="failure"} 45`)
+	assert.Contains(t, out, `apex_invoke_duration_seconds_count{function="api",status="success"} 1`)
+	assert.Contains(t, out, "# TYPE apex_aws_errors_total counter\n")
+}
+
+func TestMetrics_Instrument(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-Errortype", "ResourceNotFoundException")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"Type": "User", "Message": "Function not found"}`))
+	}))
+	defer server.Close()
+
+	s := session.Must(session.NewSession(aws.NewConfig().
+		WithRegion("us-west-2").
+		WithEndpoint(server.URL).
+		WithMaxRetries(0).
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", ""))))
+
+	r := new(Memory)
+	New(r).Instrument(s)
+
+	_, err := lambda.New(s).GetFunction(&lambda.GetFunctionInput{
+		FunctionName: aws.String("app_foo"),
+	})
+	assert.NotNil(t, err)
+
+	var buf bytes.Buffer
+	r.WriteTo(&buf)
+
+	lines := strings.Split(buf.String(), "\n")
+	assert.Contains(t, lines, `apex_aws_errors_total{service="lambda",operation="GetFunction",code="ResourceNotFoundException"} 1`)
+}
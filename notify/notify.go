@@ -0,0 +1,232 @@
+// Package notify posts deploy and rollback notifications to
+// webhooks, Slack incoming webhooks and SNS topics.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+)
+
+// Event kinds.
+const (
+	Deploy   = "deploy"
+	Rollback = "rollback"
+)
+
+// Event statuses.
+const (
+	Success = "success"
+	Failure = "failure"
+)
+
+// Event is a deploy or rollback of a function.
+type Event struct {
+	Kind       string    `json:"kind"`
+	Status     string    `json:"status"`
+	Project    string    `json:"project"`
+	Function   string    `json:"function"`
+	Stage      string    `json:"stage,omitempty"`
+	Version    string    `json:"version,omitempty"`
+	CodeSha256 string    `json:"codeSha256,omitempty"`
+	Commit     string    `json:"commit,omitempty"`
+	User       string    `json:"user,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// String representation, for example "deploy of app foo version 3 by tj succeeded".
+func (e *Event) String() string {
+	s := fmt.Sprintf("%s of %s %s", e.Kind, e.Project, e.Function)
+
+	if e.Stage != "" {
+		s += " (" + e.Stage + ")"
+	}
+
+	if e.Version != "" {
+		s += " version " + e.Version
+	}
+
+	if e.Commit != "" {
+		s += " at " + short(e.Commit)
+	}
+
+	if e.User != "" {
+		s += " by " + e.User
+	}
+
+	if e.Status == Failure {
+		return s + " failed: " + e.Error
+	}
+
+	return s + " succeeded"
+}
+
+// Notifier sends events.
+type Notifier interface {
+	Notify(*Event) error
+}
+
+// Config is a notification destination, exactly one of Webhook, Slack or SNS.
+type Config struct {
+	// Webhook URL receiving events as JSON.
+	Webhook string `json:"webhook"`
+
+	// Slack incoming webhook URL.
+	Slack string `json:"slack"`
+
+	// SNS topic ARN.
+	SNS string `json:"sns"`
+
+	// Failures only, when true.
+	Failures bool `json:"failures"`
+}
+
+// Notifier returns the notifier of the destination, publishing to SNS
+// topics with `service`. Nil is returned for SNS topics when `service`
+// is nil, as in dry-runs.
+func (c *Config) Notifier(service snsiface.SNSAPI) (Notifier, error) {
+	var n Notifier
+	var set int
+
+	if c.Webhook != "" {
+		n = &Webhook{URL: c.Webhook}
+		set++
+	}
+
+	if c.Slack != "" {
+		n = &Slack{URL: c.Slack}
+		set++
+	}
+
+	if c.SNS != "" {
+		if service != nil {
+			n = &SNS{Service: service, Topic: c.SNS}
+		}
+		set++
+	}
+
+	if set != 1 {
+		return nil, errors.New("notifications require exactly one of webhook, slack or sns")
+	}
+
+	if n != nil && c.Failures {
+		n = &failures{n}
+	}
+
+	return n, nil
+}
+
+// Webhook posts events as JSON.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify implements Notifier.
+func (w *Webhook) Notify(e *Event) error {
+	return post(w.Client, w.URL, e)
+}
+
+// Slack posts events to an incoming webhook.
+type Slack struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify implements Notifier.
+func (s *Slack) Notify(e *Event) error {
+	icon := ":white_check_mark:"
+	if e.Status == Failure {
+		icon = ":x:"
+	}
+
+	return post(s.Client, s.URL, map[string]string{
+		"text": icon + " " + e.String(),
+	})
+}
+
+// SNS publishes events as JSON to a topic.
+type SNS struct {
+	Service snsiface.SNSAPI
+	Topic   string
+}
+
+// Notify implements Notifier.
+func (s *SNS) Notify(e *Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	subject := e.String()
+	if len(subject) > 100 {
+		subject = subject[:100]
+	}
+
+	_, err = s.Service.Publish(&sns.PublishInput{
+		TopicArn: &s.Topic,
+		Subject:  aws.String(subject),
+		Message:  aws.String(string(b)),
+	})
+
+	return err
+}
+
+// failures sends failure events only.
+type failures struct {
+	Notifier
+}
+
+// Notify implements Notifier.
+func (f *failures) Notify(e *Event) error {
+	if e.Status != Failure {
+		return nil
+	}
+	return f.Notifier.Notify(e)
+}
+
+// post `v` as JSON to `endpoint`. Errors omit the endpoint,
+// as Slack webhook URLs are secrets.
+func post(client *http.Client, endpoint string, v interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Post(endpoint, "application/json", bytes.NewReader(b))
+	if e, ok := err.(*url.Error); ok {
+		return fmt.Errorf("posting notification: %s", e.Err)
+	}
+
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("posting notification: %s", res.Status)
+	}
+
+	return nil
+}
+
+// short returns the abbreviated commit.
+func short(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvent_String(t *testing.T) {
+	e := &Event{
+		Kind:     Deploy,
+		Status:   Success,
+		Project:  "app",
+		Function: "foo",
+		Stage:    "prod",
+		Version:  "3",
+		Commit:   "abc1234def",
+		User:     "tj",
+	}
+
+	assert.Equal(t, "deploy of app foo (prod) version 3 at abc1234 by tj succeeded", e.String())
+
+	e.Status = Failure
+	e.Error = "boom"
+	assert.Equal(t, "deploy of app foo (prod) version 3 at abc1234 by tj failed: boom", e.String())
+}
+
+func TestConfig_Notifier(t *testing.T) {
+	_, err := (&Config{}).Notifier(nil)
+	assert.EqualError(t, err, "notifications require exactly one of webhook, slack or sns")
+
+	_, err = (&Config{Webhook: "http://example.com", Slack: "http://example.com"}).Notifier(nil)
+	assert.NotNil(t, err)
+
+	n, err := (&Config{SNS: "arn:aws:sns:us-west-2:123456789012:deploys"}).Notifier(nil)
+	assert.Nil(t, err)
+	assert.Nil(t, n)
+}
+
+func TestSlack_Notify(t *testing.T) {
+	var bodies []map[string]string
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+	}))
+	defer s.Close()
+
+	n, err := (&Config{Slack: s.URL, Failures: true}).Notifier(nil)
+	assert.Nil(t, err)
+
+	e := &Event{Kind: Rollback, Status: Success, Project: "app", Function: "foo"}
+	assert.Nil(t, n.Notify(e))
+	assert.Len(t, bodies, 0)
+
+	e.Status = Failure
+	e.Error = "boom"
+	assert.Nil(t, n.Notify(e))
+	assert.Equal(t, []map[string]string{{"text": ":x: rollback of app foo failed: boom"}}, bodies)
+}
+
+func TestWebhook_Notify_status(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer s.Close()
+
+	w := &Webhook{URL: s.URL}
+	assert.EqualError(t, w.Notify(&Event{}), "posting notification: 403 Forbidden")
+}
@@ -0,0 +1,283 @@
+// Package offline queues the mutating operations of functions to a local
+// directory, so that they may be prepared and reviewed without credentials
+// or network access, such as on an air-gapped machine, and applied later.
+// Deploys are queued with the zip built for them, so that the code which
+// was reviewed is the code which ships.
+//
+// Operations are stored as JSON lines in <dir>/operations.json, in the
+// order they were queued, along with zips named after their checksum.
+package offline
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apex/apex/function"
+)
+
+// Operation kinds.
+const (
+	Deploy    = "deploy"
+	Delete    = "delete"
+	Rollback  = "rollback"
+	Configure = "configure"
+)
+
+// operations is the name of the file of queued operations.
+const operations = "operations.json"
+
+// Operation is a mutating operation of a function, queued to be applied later.
+type Operation struct {
+	// Kind of operation, such as "deploy".
+	Kind string `json:"kind"`
+
+	// Function name, as in the project.
+	Function string `json:"function"`
+
+	// Stage the operation was queued for.
+	Stage string `json:"stage,omitempty"`
+
+	// Artifact is the name of the zip of deploys in the queue directory.
+	Artifact string `json:"artifact,omitempty"`
+
+	// Env are environment variables set for deploys, in addition to
+	// those of env files read when applied.
+	Env map[string]string `json:"env,omitempty"`
+
+	// Version rolled back to, or the previous version when empty.
+	Version string `json:"version,omitempty"`
+
+	// Delete options of deletes.
+	Delete *function.DeleteOptions `json:"delete,omitempty"`
+
+	// Change of configuration applied.
+	Change *function.Change `json:"change,omitempty"`
+
+	// User who queued the operation.
+	User string `json:"user,omitempty"`
+
+	// Time the operation was queued.
+	Time time.Time `json:"time"`
+}
+
+// String returns a description of the operation.
+func (o *Operation) String() string {
+	s := fmt.Sprintf("%s %s", o.Kind, o.Function)
+
+	switch {
+	case o.Artifact != "":
+		sum := strings.TrimSuffix(o.Artifact, ".zip")
+		if len(sum) > 12 {
+			sum = sum[:12]
+		}
+		s += " with " + sum
+	case o.Kind == Rollback && o.Version != "":
+		s += " to version " + o.Version
+	case o.Kind == Rollback:
+		s += " to the previous version"
+	}
+
+	if o.Stage != "" {
+		s += fmt.Sprintf(" (%s)", o.Stage)
+	}
+
+	if o.User != "" {
+		s += " by " + o.User
+	}
+
+	return s
+}
+
+// Queue of operations in directory Path, created on first add.
+type Queue struct {
+	Path string
+}
+
+// Add appends `op` to the queue, defaulting its User to the deployer and
+// its Time to now.
+func (q *Queue) Add(op *Operation) error {
+	if op.User == "" {
+		op.User = function.Deployer()
+	}
+
+	if op.Time.IsZero() {
+		op.Time = time.Now()
+	}
+
+	if err := os.MkdirAll(q.Path, 0755); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(q.Path, operations), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// AddArtifact appends deploy `op` to the queue, with the zip written by
+// `write` to the path given, which is stored under its checksum.
+func (q *Queue) AddArtifact(op *Operation, write func(path string) error) error {
+	if err := os.MkdirAll(q.Path, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(q.Path, ".artifact-*.zip")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp.Name()); err != nil {
+		return err
+	}
+
+	sum, err := checksum(tmp.Name())
+	if err != nil {
+		return err
+	}
+
+	op.Artifact = sum + ".zip"
+
+	if err := os.Rename(tmp.Name(), filepath.Join(q.Path, op.Artifact)); err != nil {
+		return err
+	}
+
+	return q.Add(op)
+}
+
+// ArtifactPath returns the path of the zip of deploy `op`, verifying it
+// is unchanged since it was queued.
+func (q *Queue) ArtifactPath(op *Operation) (string, error) {
+	path := filepath.Join(q.Path, op.Artifact)
+
+	sum, err := checksum(path)
+	if err != nil {
+		return "", err
+	}
+
+	if sum+".zip" != op.Artifact {
+		return "", fmt.Errorf("artifact %s was modified after it was queued", op.Artifact)
+	}
+
+	return path, nil
+}
+
+// List returns the queued operations, in the order they were queued.
+func (q *Queue) List() ([]*Operation, error) {
+	b, err := ioutil.ReadFile(filepath.Join(q.Path, operations))
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []*Operation
+	s := bufio.NewScanner(bytes.NewReader(b))
+
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+
+		var op Operation
+		if err := json.Unmarshal(s.Bytes(), &op); err != nil {
+			return nil, fmt.Errorf("%s line %d: %s", operations, line, err)
+		}
+
+		ops = append(ops, &op)
+	}
+
+	return ops, s.Err()
+}
+
+// Done removes the first `n` operations from the queue once applied,
+// along with the zips no longer referenced by those remaining.
+func (q *Queue) Done(n int) error {
+	ops, err := q.List()
+	if err != nil {
+		return err
+	}
+
+	if n > len(ops) {
+		n = len(ops)
+	}
+
+	applied, remaining := ops[:n], ops[n:]
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	referenced := make(map[string]bool)
+
+	for _, op := range remaining {
+		if err := enc.Encode(op); err != nil {
+			return err
+		}
+
+		referenced[op.Artifact] = true
+	}
+
+	path := filepath.Join(q.Path, operations)
+
+	if err := ioutil.WriteFile(path+".tmp", buf.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	for _, op := range applied {
+		if op.Artifact == "" || referenced[op.Artifact] {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(q.Path, op.Artifact)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checksum returns the hex SHA256 checksum of the file at `path`.
+func checksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
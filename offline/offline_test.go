@@ -0,0 +1,65 @@
+package offline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apex/apex/function"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offline")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	q := &Queue{Path: filepath.Join(dir, "queue")}
+
+	ops, err := q.List()
+	assert.Nil(t, err)
+	assert.Empty(t, ops)
+
+	write := func(path string) error {
+		return ioutil.WriteFile(path, []byte("zip"), 0644)
+	}
+
+	assert.Nil(t, q.AddArtifact(&Operation{Kind: Deploy, Function: "foo", Stage: "prod", User: "tj"}, write))
+	assert.Nil(t, q.Add(&Operation{Kind: Delete, Function: "bar", Delete: &function.DeleteOptions{Force: true, Resources: true}}))
+	assert.Nil(t, q.AddArtifact(&Operation{Kind: Deploy, Function: "baz"}, write))
+	assert.Nil(t, q.Add(&Operation{Kind: Configure, Function: "foo", Change: &function.Change{Runtime: "nodejs22.x"}}))
+
+	ops, err = q.List()
+	assert.Nil(t, err)
+	assert.Len(t, ops, 4)
+	assert.Equal(t, "deploy foo with 4a70fe9aa643 (prod) by tj", ops[0].String())
+	assert.True(t, ops[1].Delete.Resources)
+	assert.Equal(t, ops[0].Artifact, ops[2].Artifact)
+	assert.Equal(t, "nodejs22.x", ops[3].Change.Runtime)
+	assert.False(t, ops[3].Time.IsZero())
+
+	path, err := q.ArtifactPath(ops[0])
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(q.Path, ops[0].Artifact), path)
+
+	assert.Nil(t, q.Done(1))
+	_, err = os.Stat(path)
+	assert.Nil(t, err, "artifact referenced by baz")
+
+	assert.Nil(t, q.Done(2))
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	ops, err = q.List()
+	assert.Nil(t, err)
+	assert.Len(t, ops, 1)
+	assert.Equal(t, Configure, ops[0].Kind)
+
+	assert.Nil(t, q.AddArtifact(&Operation{Kind: Deploy, Function: "foo"}, write))
+	ops, _ = q.List()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(q.Path, ops[1].Artifact), []byte("tampered"), 0644))
+
+	_, err = q.ArtifactPath(ops[1])
+	assert.EqualError(t, err, "artifact "+ops[1].Artifact+" was modified after it was queued")
+}
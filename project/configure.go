@@ -0,0 +1,104 @@
+package project
+
+import (
+	"sync"
+
+	"github.com/apex/apex/function"
+	"github.com/apex/apex/offline"
+	"github.com/apex/apex/trail"
+	"github.com/tj/go-sync/semaphore"
+)
+
+// PlanChange returns the updates change `c` makes to the deployed
+// configuration of functions `names`, by function name, without applying
+// them. Failures to plan a function are returned together as *Errors.
+func (p *Project) PlanChange(names []string, c *function.Change) (map[string][]*function.Update, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	plan := make(map[string][]*function.Update)
+
+	errs := p.eachFunction(names, func(fn *function.Function) error {
+		updates, err := fn.PlanChange(c)
+
+		if err == nil && len(updates) > 0 {
+			mu.Lock()
+			plan[fn.Name] = updates
+			mu.Unlock()
+		}
+
+		return err
+	})
+
+	return plan, errs
+}
+
+// ApplyChange applies change `c` to the deployed configuration of
+// functions `names` in one pass, without building or deploying their code,
+// recording each change in the trailTable. A failed function does not
+// abort the change of others, failures are returned together as *Errors.
+// The change is queued instead in offline mode.
+func (p *Project) ApplyChange(names []string, c *function.Change) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	if p.Offline != nil {
+		return p.queue(offline.Configure, names, func(op *offline.Operation) {
+			op.Change = c
+		})
+	}
+
+	p.Log.Debugf("configuring %d functions", len(names))
+
+	return p.eachFunction(names, func(fn *function.Function) error {
+		return p.applyChange(fn, c)
+	})
+}
+
+// applyChange applies change `c` to `fn`, recording it in the trailTable.
+func (p *Project) applyChange(fn *function.Function, c *function.Change) error {
+	_, err := fn.ApplyChange(c)
+	if err == function.ErrUnchanged {
+		return nil
+	}
+
+	version, _ := fn.Published()
+	p.recordOperation(trail.Configure, fn, version, err)
+	return err
+}
+
+// eachFunction calls `fn` with functions `names` concurrently, warning of
+// those which do not exist, and returns failures together as *Errors.
+func (p *Project) eachFunction(names []string, fn func(*function.Function) error) error {
+	sem := make(semaphore.Semaphore, p.Concurrency)
+	results := make([]error, len(names))
+
+	for i, name := range names {
+		f, err := p.FunctionByName(name)
+
+		if err == ErrNotFound {
+			p.Log.Warnf("function %q does not exist", name)
+			continue
+		}
+
+		i := i
+		sem.Acquire()
+
+		go func() {
+			defer sem.Release()
+			results[i] = fn(f)
+		}()
+	}
+
+	sem.Wait()
+
+	errs := &Errors{Total: len(names)}
+	for i, name := range names {
+		errs.add(name, results[i])
+	}
+
+	return errs.err()
+}
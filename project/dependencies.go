@@ -0,0 +1,148 @@
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// checkDependencies returns an error when a function depends
+// on a function which does not exist, or dependencies are cyclic.
+func (p *Project) checkDependencies() error {
+	deps := make(map[string][]string)
+
+	for _, fn := range p.Functions {
+		deps[fn.Name] = fn.DependsOn
+	}
+
+	for _, fn := range p.Functions {
+		for _, name := range fn.DependsOn {
+			if _, ok := deps[name]; !ok {
+				return fmt.Errorf("function %s depends on %q which does not exist", fn.Name, name)
+			}
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	state := make(map[string]int)
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		path = append(path, name)
+
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("functions have cyclic dependencies: %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		for _, dep := range deps[name] {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+
+		return nil
+	}
+
+	for _, fn := range p.Functions {
+		if err := visit(fn.Name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeployOrder groups `names` into stages which are deployed in order, each
+// function following the functions it depends on. Functions within a stage
+// are independent of each other and may be deployed concurrently, and
+// dependencies not in `names` are assumed to be deployed already.
+func (p *Project) DeployOrder(names []string) [][]string {
+	pending := make(map[string][]string)
+
+	for _, name := range names {
+		pending[name] = nil
+	}
+
+	for _, name := range names {
+		fn, err := p.FunctionByName(name)
+		if err != nil {
+			continue
+		}
+
+		for _, dep := range fn.DependsOn {
+			if _, ok := pending[dep]; ok {
+				pending[name] = append(pending[name], dep)
+			}
+		}
+	}
+
+	var stages [][]string
+	done := make(map[string]bool)
+
+	for len(done) < len(pending) {
+		var stage []string
+
+		for _, name := range names {
+			if done[name] || !all(pending[name], done) {
+				continue
+			}
+			stage = append(stage, name)
+		}
+
+		// cycles are rejected by checkDependencies, but guard
+		// against looping forever when functions are set directly
+		if len(stage) == 0 {
+			for _, name := range names {
+				if !done[name] {
+					stage = append(stage, name)
+				}
+			}
+		}
+
+		for _, name := range stage {
+			done[name] = true
+		}
+
+		stages = append(stages, stage)
+	}
+
+	return stages
+}
+
+// failedDependency returns the first dependency of function `name` which failed.
+func (p *Project) failedDependency(name string, failed map[string]bool) string {
+	fn, err := p.FunctionByName(name)
+	if err != nil {
+		return ""
+	}
+
+	deps := append([]string(nil), fn.DependsOn...)
+	sort.Strings(deps)
+
+	for _, dep := range deps {
+		if failed[dep] {
+			return dep
+		}
+	}
+
+	return ""
+}
+
+// all returns true when every name is in `set`.
+func all(names []string, set map[string]bool) bool {
+	for _, name := range names {
+		if !set[name] {
+			return false
+		}
+	}
+	return true
+}
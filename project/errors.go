@@ -0,0 +1,72 @@
+package project
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FunctionError is the failure of an operation on a single function.
+type FunctionError struct {
+	// Function name.
+	Function string
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error message.
+func (e *FunctionError) Error() string {
+	return fmt.Sprintf("function %s: %s", e.Function, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *FunctionError) Unwrap() error {
+	return e.Err
+}
+
+// Errors is returned by operations on many functions, such as Deploy and
+// Delete, which continue past failures rather than aborting on the first.
+type Errors struct {
+	// Total is the number of functions operated on.
+	Total int
+
+	// Failed are the functions which failed, in the order given.
+	Failed []*FunctionError
+}
+
+// Error message.
+func (e *Errors) Error() string {
+	lines := []string{fmt.Sprintf("%d of %d functions failed", len(e.Failed), e.Total)}
+	for _, err := range e.Failed {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Partial returns true when some, but not all, functions failed.
+func (e *Errors) Partial() bool {
+	return len(e.Failed) < e.Total
+}
+
+// Functions returns the names of the functions which failed.
+func (e *Errors) Functions() (names []string) {
+	for _, err := range e.Failed {
+		names = append(names, err.Function)
+	}
+	return
+}
+
+// add records the failure of function `name`, ignoring nil errors.
+func (e *Errors) add(name string, err error) {
+	if err != nil {
+		e.Failed = append(e.Failed, &FunctionError{Function: name, Err: err})
+	}
+}
+
+// err returns nil when no functions failed.
+func (e *Errors) err() error {
+	if len(e.Failed) == 0 {
+		return nil
+	}
+	return e
+}
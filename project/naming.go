@@ -0,0 +1,75 @@
+package project
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxFunctionNameLength is the maximum length of Lambda function names.
+const MaxFunctionNameLength = 64
+
+// functionName pattern for valid Lambda function names.
+var functionName = regexp.MustCompile(`^[A-Za-z0-9\-_]+$`)
+
+// namePlaceholders maps the nameTemplate shorthand to template actions.
+var namePlaceholders = strings.NewReplacer(
+	"{project}", "{{.Project.Name}}",
+	"{function}", "{{.Function.Name}}",
+	"{stage}", "{{.Project.Stage}}",
+	"{region}", "{{.Project.Region}}",
+)
+
+// expandName returns nameTemplate `s` with shorthand placeholders such as
+// "{project}_{function}_{stage}" expanded to template actions.
+func expandName(s string) string {
+	return namePlaceholders.Replace(s)
+}
+
+// applyNamePolicy returns `name` with NamePrefix and NameSuffix applied,
+// unless already present, and separators left by empty placeholders such
+// as an unset stage trimmed.
+func (p *Project) applyNamePolicy(name string) (string, error) {
+	name = strings.Trim(name, "_-")
+
+	if !strings.HasPrefix(name, p.NamePrefix) {
+		name = p.NamePrefix + name
+	}
+
+	if !strings.HasSuffix(name, p.NameSuffix) {
+		name = name + p.NameSuffix
+	}
+
+	if !functionName.MatchString(name) {
+		return "", fmt.Errorf("invalid function name %q, must contain only letters, numbers, hyphens and underscores", name)
+	}
+
+	if len(name) > MaxFunctionNameLength {
+		return "", fmt.Errorf("function name %q exceeds %d characters", name, MaxFunctionNameLength)
+	}
+
+	return name, nil
+}
+
+// checkNameCollisions returns an error if two functions share a FunctionName.
+func (p *Project) checkNameCollisions() error {
+	names := make(map[string]string)
+
+	for _, fn := range p.Functions {
+		if other, ok := names[fn.FunctionName]; ok {
+			return fmt.Errorf("functions %s and %s are both named %q, check nameTemplate", other, fn.Name, fn.FunctionName)
+		}
+		names[fn.FunctionName] = fn.Name
+	}
+
+	return nil
+}
+
+// repeatedSlashes matches the separators left by empty placeholders in parameterPath.
+var repeatedSlashes = regexp.MustCompile(`/{2,}`)
+
+// cleanParameterPath returns parameterPath `s` without repeated or trailing
+// slashes, such as those left by an unset stage.
+func cleanParameterPath(s string) string {
+	return strings.TrimSuffix(repeatedSlashes.ReplaceAllString(s, "/"), "/")
+}
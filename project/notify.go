@@ -0,0 +1,66 @@
+package project
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apex/apex/function"
+	"github.com/apex/apex/notify"
+)
+
+// loadNotifiers prepares the configured notification destinations.
+func (p *Project) loadNotifiers() error {
+	p.notifiers = nil
+
+	for i, c := range p.Notifications {
+		n, err := c.Notifier(p.SNS)
+		if err != nil {
+			return fmt.Errorf("notifications[%d]: %s", i, err)
+		}
+
+		if n == nil {
+			p.Log.Debugf("skipping notifications[%d], no SNS service", i)
+			continue
+		}
+
+		p.notifiers = append(p.notifiers, n)
+	}
+
+	return nil
+}
+
+// notify sends a `kind` event of `fn` to the configured destinations.
+// Failing to notify is logged as a warning rather than failing the
+// deploy or rollback, which has already happened.
+func (p *Project) notify(kind string, fn *function.Function, version, codeSha256 string, err error) {
+	if len(p.notifiers) == 0 {
+		return
+	}
+
+	e := &notify.Event{
+		Kind:       kind,
+		Status:     notify.Success,
+		Project:    p.Name,
+		Function:   fn.Name,
+		Stage:      p.Stage,
+		Version:    version,
+		CodeSha256: codeSha256,
+		User:       function.Deployer(),
+		Time:       time.Now().UTC(),
+	}
+
+	if p.Git != nil {
+		e.Commit = p.Git.Commit
+	}
+
+	if err != nil {
+		e.Status = notify.Failure
+		e.Error = err.Error()
+	}
+
+	for _, n := range p.notifiers {
+		if err := n.Notify(e); err != nil {
+			p.Log.Warnf("error sending %s notification of %s: %s", kind, fn.Name, err)
+		}
+	}
+}
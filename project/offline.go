@@ -0,0 +1,152 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apex/apex/function"
+	"github.com/apex/apex/notify"
+	"github.com/apex/apex/offline"
+	"github.com/apex/apex/trail"
+)
+
+// queueDeploy builds the zips of functions `names` and queues their
+// deploys, along with the environment variables set, continuing past
+// failures, which are returned together as *Errors.
+func (p *Project) queueDeploy(names []string) error {
+	p.Log.Debugf("queueing deploys of %d functions", len(names))
+	errs := &Errors{Total: len(names)}
+
+	for _, name := range names {
+		fn, err := p.FunctionByName(name)
+
+		if err == ErrNotFound {
+			p.Log.Warnf("function %q does not exist", name)
+			continue
+		}
+
+		op := &offline.Operation{
+			Kind:     offline.Deploy,
+			Function: name,
+			Stage:    p.Stage,
+			Env:      p.env,
+		}
+
+		err = p.Offline.AddArtifact(op, fn.Package)
+		if err == nil {
+			fn.Log.Infof("queued %s", op)
+		}
+
+		errs.add(name, err)
+	}
+
+	return errs.err()
+}
+
+// queue queues operations of `kind` of functions `names`, set up by `set`.
+func (p *Project) queue(kind string, names []string, set func(op *offline.Operation)) error {
+	for _, name := range names {
+		fn, err := p.FunctionByName(name)
+
+		if err == ErrNotFound {
+			p.Log.Warnf("function %q does not exist", name)
+			continue
+		}
+
+		op := &offline.Operation{
+			Kind:     kind,
+			Function: name,
+			Stage:    p.Stage,
+		}
+
+		set(op)
+
+		if err := p.Offline.Add(op); err != nil {
+			return err
+		}
+
+		fn.Log.Infof("queued %s", op)
+	}
+
+	return nil
+}
+
+// Apply applies the operations queued in `q` in the order they were
+// queued, removing each from the queue once applied. Applying stops at
+// the first failure, so that it may be resumed once resolved. Operations
+// queued for another stage are refused.
+func (p *Project) Apply(q *offline.Queue) error {
+	if p.Offline != nil {
+		return errors.New("cannot apply queued operations in offline mode")
+	}
+
+	ops, err := q.List()
+	if err != nil {
+		return err
+	}
+
+	p.Log.Debugf("applying %d queued operations", len(ops))
+
+	for _, op := range ops {
+		if err := p.apply(q, op); err != nil {
+			return fmt.Errorf("%s: %s", op, err)
+		}
+
+		if err := q.Done(1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply queued operation `op`.
+func (p *Project) apply(q *offline.Queue, op *offline.Operation) error {
+	if op.Stage != p.Stage {
+		return fmt.Errorf("queued for stage %q, not %q", op.Stage, p.Stage)
+	}
+
+	fn, err := p.FunctionByName(op.Function)
+	if err != nil {
+		return err
+	}
+
+	switch op.Kind {
+	case offline.Deploy:
+		path, err := q.ArtifactPath(op)
+		if err != nil {
+			return err
+		}
+
+		for name, value := range op.Env {
+			fn.SetEnv(name, value)
+		}
+
+		err = fn.DeployPackage(path)
+		version, sha := fn.Published()
+		p.notify(notify.Deploy, fn, version, sha, err)
+		p.recordOperation(trail.Deploy, fn, version, err)
+		return err
+	case offline.Delete:
+		opts := function.DeleteOptions{Force: true}
+		if op.Delete != nil {
+			opts = *op.Delete
+		}
+
+		err := fn.Delete(opts)
+		p.recordOperation(trail.Delete, fn, "", err)
+		return err
+	case offline.Rollback:
+		if err := p.Rollback(op.Function, op.Version); err != function.ErrUnchanged {
+			return err
+		}
+		return nil
+	case offline.Configure:
+		if op.Change == nil {
+			return errors.New("missing change")
+		}
+		return p.applyChange(fn, op.Change)
+	default:
+		return fmt.Errorf("unsupported operation %q", op.Kind)
+	}
+}
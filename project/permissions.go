@@ -0,0 +1,224 @@
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apex/apex/function"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// ErrPermissions is returned when the credentials lack
+// permissions required to deploy.
+type ErrPermissions struct {
+	// Denied actions, such as "lambda:UpdateFunctionCode on arn:aws:lambda:...".
+	Denied []string
+}
+
+// Error message.
+func (e *ErrPermissions) Error() string {
+	return fmt.Sprintf("credentials lack permissions required to deploy:\n  %s", strings.Join(e.Denied, "\n  "))
+}
+
+// account is the caller identity permissions are simulated for.
+type account struct {
+	partition string
+	id        string
+	region    string
+	principal string
+}
+
+// arn returns the ARN of `resource` of `service`, in the
+// project region unless the service is global.
+func (a *account) arn(service, resource string) string {
+	region := a.region
+	if service == "iam" {
+		region = ""
+	}
+	return fmt.Sprintf("arn:%s:%s:%s:%s:%s", a.partition, service, region, a.id, resource)
+}
+
+// CheckPermissions simulates the IAM actions a deploy of functions `names`
+// requires against the policies of the current credentials, returning those
+// denied, such as "lambda:UpdateFunctionCode on arn:aws:lambda:...". The
+// simulation requires iam:SimulatePrincipalPolicy, and does not account
+// for the session policies of assumed roles.
+func (p *Project) CheckPermissions(names []string) ([]string, error) {
+	a, err := p.account()
+	if err != nil {
+		return nil, err
+	}
+
+	if a.principal == "" {
+		p.Log.Debug("skipping permission check for the root user")
+		return nil, nil
+	}
+
+	required := make(map[string]map[string]bool)
+
+	require := func(resource string, actions ...string) {
+		if required[resource] == nil {
+			required[resource] = make(map[string]bool)
+		}
+		for _, action := range actions {
+			required[resource][action] = true
+		}
+	}
+
+	for _, name := range names {
+		fn, err := p.FunctionByName(name)
+		if err != nil {
+			continue
+		}
+		p.requirePermissions(a, fn, require)
+	}
+
+	tables := map[string][]string{
+		p.LockTable:    {"dynamodb:PutItem", "dynamodb:DeleteItem"},
+		p.ReleaseTable: {"dynamodb:PutItem", "dynamodb:Query"},
+		p.TrailTable:   {"dynamodb:PutItem"},
+	}
+
+	for table, actions := range tables {
+		if table != "" {
+			require(a.arn("dynamodb", "table/"+table), actions...)
+		}
+	}
+
+	var denied []string
+
+	for resource, set := range required {
+		var actions []string
+		for action := range set {
+			actions = append(actions, action)
+		}
+		sort.Strings(actions)
+
+		in := &iam.SimulatePrincipalPolicyInput{
+			PolicySourceArn: aws.String(a.principal),
+			ActionNames:     aws.StringSlice(actions),
+			ResourceArns:    aws.StringSlice([]string{resource}),
+		}
+
+		err := p.IAM.SimulatePrincipalPolicyPages(in, func(page *iam.SimulatePolicyResponse, last bool) bool {
+			for _, r := range page.EvaluationResults {
+				if aws.StringValue(r.EvalDecision) != iam.PolicyEvaluationDecisionTypeAllowed {
+					denied = append(denied, fmt.Sprintf("%s on %s", aws.StringValue(r.EvalActionName), resource))
+				}
+			}
+			return true
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(denied)
+	return denied, nil
+}
+
+// requirePermissions adds the actions required to deploy `fn` to `require`.
+func (p *Project) requirePermissions(a *account, fn *function.Function, require func(string, ...string)) {
+	arn := a.arn("lambda", "function:"+fn.FunctionName)
+
+	require(arn,
+		"lambda:GetFunction",
+		"lambda:CreateFunction",
+		"lambda:UpdateFunctionCode",
+		"lambda:UpdateFunctionConfiguration",
+		"lambda:PublishVersion",
+		"lambda:GetAlias",
+		"lambda:CreateAlias",
+		"lambda:UpdateAlias")
+
+	role := fn.Role
+	if !strings.HasPrefix(role, "arn:") {
+		role = a.arn("iam", "role/"+role)
+	}
+	require(role, "iam:PassRole")
+
+	if fn.LogRetention > 0 {
+		require(a.arn("logs", "log-group:"+fn.LogGroupName()), "logs:CreateLogGroup", "logs:PutRetentionPolicy")
+	}
+
+	if len(fn.Alarms) > 0 || fn.Budget != nil {
+		require("*", "cloudwatch:PutMetricAlarm")
+	}
+
+	if len(fn.Events) > 0 || fn.Warm > 0 {
+		require(a.arn("events", "rule/*"), "events:PutRule", "events:PutTargets")
+		require("*", "events:ListEventBuses", "events:ListRuleNamesByTarget")
+		require(arn, "lambda:AddPermission")
+	}
+
+	if len(fn.Permissions) > 0 {
+		require(arn, "lambda:AddPermission", "lambda:GetPolicy")
+	}
+
+	if fn.URL != nil {
+		require(arn, "lambda:GetFunctionUrlConfig", "lambda:CreateFunctionUrlConfig", "lambda:UpdateFunctionUrlConfig")
+	}
+
+	if fn.ParameterPath != "" {
+		require(a.arn("ssm", "parameter"+fn.ParameterPath+"/*"), "ssm:PutParameter")
+	}
+}
+
+// account returns the caller identity, with the IAM principal of
+// assumed-role sessions, or an empty principal for the root user.
+func (p *Project) account() (*account, error) {
+	res, err := p.STS.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	caller := aws.StringValue(res.Arn)
+	parts := strings.SplitN(caller, ":", 6)
+	if len(parts) != 6 {
+		return nil, fmt.Errorf("unexpected caller arn %q", caller)
+	}
+
+	a := &account{
+		partition: parts[1],
+		id:        aws.StringValue(res.Account),
+		region:    p.Region,
+	}
+
+	switch resource := parts[5]; {
+	case resource == "root":
+	case strings.HasPrefix(resource, "assumed-role/"):
+		role := strings.Split(resource, "/")[1]
+		a.principal = fmt.Sprintf("arn:%s:iam::%s:role/%s", a.partition, a.id, role)
+	default:
+		a.principal = caller
+	}
+
+	return a, nil
+}
+
+// checkPermissions returns *ErrPermissions listing the permissions the current
+// credentials lack to deploy functions `names`, when IAM and STS services
+// are available. Failing to simulate is logged as a warning, as the
+// credentials may be allowed to deploy without being allowed to simulate.
+func (p *Project) checkPermissions(names []string) error {
+	if p.IAM == nil || p.STS == nil {
+		p.Log.Debug("skipping permission check, no IAM or STS service")
+		return nil
+	}
+
+	denied, err := p.CheckPermissions(names)
+	if err != nil {
+		p.Log.Warnf("unable to check permissions: %s", err)
+		return nil
+	}
+
+	if len(denied) == 0 {
+		return nil
+	}
+
+	return &ErrPermissions{Denied: denied}
+}
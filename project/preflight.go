@@ -0,0 +1,78 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// QuotaWarnRatio is the fraction of an account quota in use above which
+// Preflight warns that deploys may fail.
+const QuotaWarnRatio = 0.9
+
+// MinUnreservedConcurrency is the unreserved concurrency Lambda requires an
+// account to keep, below which functions without reservations are throttled.
+const MinUnreservedConcurrency = 100
+
+// Preflight checks the account's code storage and concurrency quotas, and
+// the reserved concurrency of functions `names`, returning warnings for
+// quotas which are exceeded or nearly so, as Lambda otherwise reports
+// these opaquely as deploy failures or throttled invocations.
+func (p *Project) Preflight(names []string) ([]string, error) {
+	res, err := p.Service.GetAccountSettings(&lambda.GetAccountSettingsInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	limit, usage := res.AccountLimit, res.AccountUsage
+
+	used := aws.Int64Value(usage.TotalCodeSize)
+	total := aws.Int64Value(limit.TotalCodeSize)
+
+	if total > 0 && float64(used) >= float64(total)*QuotaWarnRatio {
+		warnings = append(warnings, fmt.Sprintf("code storage is %.0f%% used (%d of %d bytes), remove old versions to publish new ones", float64(used)/float64(total)*100, used, total))
+	}
+
+	unreserved := aws.Int64Value(limit.UnreservedConcurrentExecutions)
+
+	if unreserved < MinUnreservedConcurrency {
+		warnings = append(warnings, fmt.Sprintf("only %d of %d concurrent executions are unreserved, functions without reserved concurrency may be throttled", unreserved, aws.Int64Value(limit.ConcurrentExecutions)))
+	}
+
+	for _, name := range names {
+		fn, err := p.FunctionByName(name)
+		if err != nil {
+			continue
+		}
+
+		c, err := p.Service.GetFunctionConcurrency(&lambda.GetFunctionConcurrencyInput{
+			FunctionName: &fn.FunctionName,
+		})
+
+		if err != nil {
+			p.Log.Debugf("error fetching concurrency of %s: %s", name, err)
+			continue
+		}
+
+		if c.ReservedConcurrentExecutions != nil && *c.ReservedConcurrentExecutions == 0 {
+			warnings = append(warnings, fmt.Sprintf("function %s has a reserved concurrency of 0, all invocations will be throttled", name))
+		}
+	}
+
+	return warnings, nil
+}
+
+// preflight logs the warnings of Preflight, which does not block deploys.
+func (p *Project) preflight(names []string) {
+	warnings, err := p.Preflight(names)
+	if err != nil {
+		p.Log.Debugf("skipping preflight checks: %s", err)
+		return
+	}
+
+	for _, w := range warnings {
+		p.Log.Warn(w)
+	}
+}
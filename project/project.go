@@ -3,18 +3,42 @@ package project
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/template"
+	"time"
 
-	"gopkg.in/validator.v2"
-
+	"github.com/apex/apex/config"
+	"github.com/apex/apex/env"
 	"github.com/apex/apex/function"
+	"github.com/apex/apex/git"
+	"github.com/apex/apex/lock"
+	"github.com/apex/apex/metrics"
+	"github.com/apex/apex/notify"
+	"github.com/apex/apex/offline"
+	"github.com/apex/apex/release"
+	"github.com/apex/apex/runtime"
+	"github.com/apex/apex/trail"
 	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/service/appsync/appsynciface"
+	"github.com/aws/aws-sdk-go/service/cloudfront/cloudfrontiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sfn/sfniface"
+	"github.com/aws/aws-sdk-go/service/signer/signeriface"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
 	"github.com/tj/go-sync/semaphore"
 )
 
@@ -26,28 +50,88 @@ const (
 )
 
 // ErrNotFound is returned when a function cannot be found.
-var ErrNotFound = errors.New("project: no function found")
+var ErrNotFound = function.ErrFunctionNotFound
 
 // Config for project.
 type Config struct {
-	Name         string `json:"name" validate:"nonzero"`
-	Description  string `json:"description"`
-	Runtime      string `json:"runtime"`
-	Memory       int64  `json:"memory"`
-	Timeout      int64  `json:"timeout"`
-	Role         string `json:"role"`
-	NameTemplate string `json:"nameTemplate"`
+	Name         string   `json:"name" validate:"nonzero"`
+	Description  string   `json:"description"`
+	Runtime      string   `json:"runtime"`
+	Memory       int64    `json:"memory"`
+	Timeout      int64    `json:"timeout"`
+	Role         string   `json:"role"`
+	NameTemplate string   `json:"nameTemplate"`
+	NamePrefix   string   `json:"namePrefix"`
+	NameSuffix   string   `json:"nameSuffix"`
+	EnvDecrypt   []string `json:"envDecrypt"`
+	EnvMode      string   `json:"envMode"`
+	LogRetention int64    `json:"logRetention"`
+	Warm         int64    `json:"warm"`
+	Docker       bool     `json:"docker"`
+	LockTable    string   `json:"lockTable"`
+	LockTTL      int64    `json:"lockTTL"`
+	ReleaseTable string   `json:"releaseTable"`
+	TrailTable   string   `json:"trailTable"`
+	Shim         string   `json:"shim"`
+	BuildCache   string   `json:"buildCache"`
+
+	StateMachineRole   string `json:"stateMachineRole"`
+	ConflictTimeout    int64  `json:"conflictTimeout"`
+	RequireTests       bool   `json:"requireTests"`
+	AuditLevel         string `json:"auditLevel"`
+	Alias              string `json:"alias"`
+	VersionDescription string `json:"versionDescription"`
+	ParameterPath      string `json:"parameterPath"`
+	Partition          string `json:"partition"`
+
+	Notifications   []*notify.Config          `json:"notifications"`
+	PreferRuntimes  []string                  `json:"preferRuntimes"`
+	LogSubscription *function.LogSubscription `json:"logSubscription"`
+	Deadlines       *function.Deadlines       `json:"deadlines"`
+	Artifacts       *function.Artifacts       `json:"artifacts"`
+	Compression     *function.Compression     `json:"compression"`
+	Store           *function.Store           `json:"store"`
 }
 
 // Project represents zero or more Lambda functions.
 type Project struct {
 	Config
-	Path         string
-	Concurrency  int
-	Log          log.Interface
-	Service      lambdaiface.LambdaAPI
-	Functions    []*function.Function
-	nameTemplate *template.Template
+	Path           string
+	Stage          string
+	Region         string
+	OverrideBudget bool
+	NoPublish      bool
+	Concurrency    int
+	Log            log.Interface
+	Service        lambdaiface.LambdaAPI
+	EdgeService    lambdaiface.LambdaAPI
+	AppSync        appsynciface.AppSyncAPI
+	CloudFront     cloudfrontiface.CloudFrontAPI
+	CloudWatch     cloudwatchiface.CloudWatchAPI
+	CloudWatchLogs cloudwatchlogsiface.CloudWatchLogsAPI
+	EventBridge    eventbridgeiface.EventBridgeAPI
+	IAM            iamiface.IAMAPI
+	DynamoDB       dynamodbiface.DynamoDBAPI
+	S3             s3iface.S3API
+	Signer         signeriface.SignerAPI
+	StepFunctions  sfniface.SFNAPI
+	SQS            sqsiface.SQSAPI
+	SSM            ssmiface.SSMAPI
+	SNS            snsiface.SNSAPI
+	STS            stsiface.STSAPI
+	Decrypter      env.Decrypter
+	Observer       function.DeployObserver
+	Offline        *offline.Queue
+	Metrics        *metrics.Metrics
+	Git            *git.Info
+	Functions      []*function.Function
+	lock           *lock.Lock
+	releases       *release.Store
+	trail          *trail.Store
+	nameTemplate   *template.Template
+	parameterPath  *template.Template
+	notifiers      []notify.Notifier
+	env            map[string]string
 }
 
 // defaults applies configuration defaults.
@@ -64,29 +148,105 @@ func (p *Project) defaults() {
 	}
 }
 
-// Open the project.json file and prime the config.
+// Open the project.json file and prime the config. The project.yaml,
+// project.yml and project.toml formats are supported as alternatives.
+// The partition defaults to that of the region, such as "aws-us-gov"
+// for GovCloud regions.
 func (p *Project) Open() error {
 	p.defaults()
 
-	f, err := os.Open(filepath.Join(p.Path, "project.json"))
+	schema := &config.Schema{
+		Enums: map[string][]string{
+			"runtime":    runtime.Names(),
+			"auditLevel": runtime.AuditLevels,
+			"partition":  function.Partitions,
+		},
+	}
+
+	path, err := config.Find(p.Path, "project")
 	if err != nil {
 		return err
 	}
 
-	if err := json.NewDecoder(f).Decode(&p.Config); err != nil {
+	if err := schema.Load(path, &p.Config); err != nil {
 		return err
 	}
 
-	if err := validator.Validate(&p.Config); err != nil {
+	if err := schema.Validate(path, &p.Config); err != nil {
 		return err
 	}
 
-	t, err := template.New("nameTemplate").Parse(p.NameTemplate)
+	if p.Partition == "" {
+		p.Partition = function.PartitionOf(p.Region)
+	}
+
+	if p.Region != "" && function.PartitionOf(p.Region) != p.Partition {
+		return fmt.Errorf("partition %q does not include region %s", p.Partition, p.Region)
+	}
+
+	if info, err := git.Describe(p.Path); err == nil {
+		p.Git = info
+	} else {
+		p.Log.Debugf("no git metadata: %s", err)
+	}
+
+	if p.LockTable != "" && p.DynamoDB != nil {
+		p.lock = &lock.Lock{
+			Service: p.DynamoDB,
+			Log:     p.Log,
+			Table:   p.LockTable,
+			TTL:     time.Duration(p.LockTTL) * time.Second,
+		}
+	}
+
+	if p.ReleaseTable != "" && p.DynamoDB != nil {
+		p.releases = &release.Store{
+			Service: p.DynamoDB,
+			Log:     p.Log,
+			Table:   p.ReleaseTable,
+		}
+	}
+
+	if p.TrailTable != "" && p.DynamoDB != nil {
+		p.trail = &trail.Store{
+			Service: p.DynamoDB,
+			Log:     p.Log,
+			Table:   p.TrailTable,
+		}
+	}
+
+	if len(p.EnvDecrypt) > 0 {
+		p.Decrypter = env.Command(p.EnvDecrypt)
+	}
+
+	t, err := template.New("nameTemplate").Parse(expandName(p.NameTemplate))
 	if err != nil {
 		return err
 	}
 	p.nameTemplate = t
 
+	if p.ParameterPath != "" {
+		if !strings.HasPrefix(p.ParameterPath, "/") {
+			return fmt.Errorf("parameterPath %q must begin with /", p.ParameterPath)
+		}
+
+		t, err := template.New("parameterPath").Parse(expandName(p.ParameterPath))
+		if err != nil {
+			return err
+		}
+		p.parameterPath = t
+	}
+
+	if p.Shim != "" {
+		if info, err := os.Stat(filepath.Join(p.Path, p.Shim)); err != nil || !info.IsDir() {
+			return fmt.Errorf("shim %q must be a directory of shim assets", p.Shim)
+		}
+	}
+
+	if err := p.loadNotifiers(); err != nil {
+		return err
+	}
+
 	return p.loadFunctions()
 }
 
@@ -99,35 +259,81 @@ func (p *Project) DeployAndClean(names []string) error {
 	return p.Clean(names)
 }
 
-// Deploy functions and their configurations.
+// Deploy functions and their configurations, followed by state machines,
+// warning first of account quotas which the deploy may exceed, and failing
+// when the credentials lack permissions the deploy requires. When
+// RequireTests is set the tests of every function must pass first, and
+// when AuditLevel is set their dependencies must have no vulnerabilities
+// of that severity or higher. Functions are deployed after the functions
+// they depend on. A failed function does not abort the deploy of others,
+// though its dependents are skipped, failures are returned together as
+// *Errors and state machines are not deployed. In offline mode the zips
+// of functions are built and their deploys queued instead, after tests
+// and audits.
 func (p *Project) Deploy(names []string) error {
+	if p.RequireTests {
+		if err := p.Test(names); err != nil {
+			return err
+		}
+	}
+
+	if p.AuditLevel != "" {
+		if err := p.Audit(names, p.AuditLevel); err != nil {
+			return err
+		}
+	}
+
+	if p.Offline != nil {
+		return p.queueDeploy(names)
+	}
+
 	p.Log.Debugf("deploying %d functions", len(names))
+	p.preflight(names)
 
-	sem := make(semaphore.Semaphore, p.Concurrency)
-	errs := make(chan error)
+	if err := p.checkPermissions(names); err != nil {
+		return err
+	}
+
+	results := make(map[string]error)
+	failed := make(map[string]bool)
 
-	go func() {
-		for _, name := range names {
-			name := name
+	for _, stage := range p.DeployOrder(names) {
+		sem := make(semaphore.Semaphore, p.Concurrency)
+		errs := make([]error, len(stage))
+
+		for i, name := range stage {
+			if dep := p.failedDependency(name, failed); dep != "" {
+				errs[i] = fmt.Errorf("skipped, dependency %s failed", dep)
+				continue
+			}
+
+			i, name := i, name
 			sem.Acquire()
 
 			go func() {
 				defer sem.Release()
-				errs <- p.deploy(name)
+				errs[i] = p.deploy(name)
 			}()
 		}
 
 		sem.Wait()
-		close(errs)
-	}()
 
-	for err := range errs {
-		if err != nil {
-			return err
+		for i, name := range stage {
+			results[name] = errs[i]
+			failed[name] = errs[i] != nil
 		}
 	}
 
-	return nil
+	errs := &Errors{Total: len(names)}
+	for _, name := range names {
+		errs.add(name, results[name])
+	}
+
+	if err := errs.err(); err != nil {
+		return err
+	}
+
+	return p.DeployStateMachines()
 }
 
 // deploy function by `name`.
@@ -143,7 +349,88 @@ func (p *Project) deploy(name string) error {
 		return err
 	}
 
-	return fn.Deploy()
+	err = fn.Deploy()
+	version, sha := fn.Published()
+	p.notify(notify.Deploy, fn, version, sha, err)
+	p.recordOperation(trail.Deploy, fn, version, err)
+	return err
+}
+
+// Test runs the tests of functions, stopping at the first failure.
+func (p *Project) Test(names []string) error {
+	p.Log.Debugf("testing %d functions", len(names))
+
+	for _, name := range names {
+		fn, err := p.FunctionByName(name)
+
+		if err == ErrNotFound {
+			p.Log.Warnf("function %q does not exist", name)
+			continue
+		}
+
+		if err := fn.Test(); err != nil {
+			return fmt.Errorf("function %s: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Audit scans the dependencies of functions for vulnerabilities of
+// at least severity `level`, stopping at the first with findings.
+func (p *Project) Audit(names []string, level string) error {
+	p.Log.Debugf("auditing %d functions", len(names))
+
+	for _, name := range names {
+		fn, err := p.FunctionByName(name)
+
+		if err == ErrNotFound {
+			p.Log.Warnf("function %q does not exist", name)
+			continue
+		}
+
+		if err := fn.Audit(level); err != nil {
+			return fmt.Errorf("function %s: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Promote deploys the code serving functions `names` in `source`, such as
+// the project opened for another stage, account or region, so that what
+// was tested there is what ships, rather than rebuilding.
+func (p *Project) Promote(names []string, source *Project) error {
+	p.Log.Debugf("promoting %d functions", len(names))
+
+	for _, name := range names {
+		fn, err := p.FunctionByName(name)
+
+		if err == ErrNotFound {
+			p.Log.Warnf("function %q does not exist", name)
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		src, err := source.FunctionByName(name)
+		if err != nil {
+			return fmt.Errorf("function %s: %s in source", name, err)
+		}
+
+		err = fn.Promote(src)
+		version, sha := fn.Published()
+		p.notify(notify.Deploy, fn, version, sha, err)
+		p.recordOperation(trail.Deploy, fn, version, err)
+
+		if err != nil {
+			return fmt.Errorf("function %s: %s", name, err)
+		}
+	}
+
+	return nil
 }
 
 // Clean up function build artifacts.
@@ -165,9 +452,18 @@ func (p *Project) Clean(names []string) error {
 	return nil
 }
 
-// Delete functions.
-func (p *Project) Delete(names []string) error {
+// Delete functions with the given options, recording each delete in the
+// trailTable and continuing past failures, which are returned together as
+// *Errors. Deletes are queued instead in offline mode.
+func (p *Project) Delete(names []string, opts function.DeleteOptions) error {
+	if p.Offline != nil {
+		return p.queue(offline.Delete, names, func(op *offline.Operation) {
+			op.Delete = &opts
+		})
+	}
+
 	p.Log.Debugf("deleting %d functions", len(names))
+	errs := &Errors{Total: len(names)}
 
 	for _, name := range names {
 		fn, err := p.FunctionByName(name)
@@ -177,7 +473,64 @@ func (p *Project) Delete(names []string) error {
 			continue
 		}
 
-		if err := fn.Delete(); err != nil {
+		err = fn.Delete(opts)
+		p.recordOperation(trail.Delete, fn, "", err)
+		errs.add(name, err)
+	}
+
+	return errs.err()
+}
+
+// Rollback function `name` to `version`, or to the previous version
+// when empty, notifying the configured destinations and recording
+// the rollback in the trailTable. The rollback is queued instead in
+// offline mode, the previous version resolved when applied.
+func (p *Project) Rollback(name, version string) error {
+	if p.Offline != nil {
+		return p.queue(offline.Rollback, []string{name}, func(op *offline.Operation) {
+			op.Version = version
+		})
+	}
+
+	fn, err := p.FunctionByName(name)
+	if err != nil {
+		return err
+	}
+
+	if version == "" {
+		err = fn.Rollback()
+	} else {
+		err = fn.RollbackVersion(version)
+	}
+
+	if err == function.ErrUnchanged {
+		return err
+	}
+
+	if err == nil && version == "" {
+		version, _ = fn.Output(function.OutputVersion)
+	}
+
+	p.notify(notify.Rollback, fn, version, "", err)
+	p.recordOperation(trail.Rollback, fn, version, err)
+	return err
+}
+
+// Unlock forcibly releases the deploy locks of the given functions.
+func (p *Project) Unlock(names []string) error {
+	if p.lock == nil {
+		return errors.New("deploy locking requires lockTable to be configured")
+	}
+
+	for _, name := range names {
+		fn, err := p.FunctionByName(name)
+
+		if err == ErrNotFound {
+			p.Log.Warnf("function %q does not exist", name)
+			continue
+		}
+
+		if err := p.lock.ForceUnlock(fn.FunctionName); err != nil {
 			return err
 		}
 	}
@@ -185,6 +538,20 @@ func (p *Project) Delete(names []string) error {
 	return nil
 }
 
+// History returns up to `limit` recorded releases of function `name`, most recent first.
+func (p *Project) History(name string, limit int) ([]*release.Release, error) {
+	if p.releases == nil {
+		return nil, errors.New("release history requires releaseTable to be configured")
+	}
+
+	fn, err := p.FunctionByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.releases.History(fn.FunctionName, limit)
+}
+
 // FunctionByName returns a function by `name` or returns ErrNotFound.
 func (p *Project) FunctionByName(name string) (*function.Function, error) {
 	for _, fn := range p.Functions {
@@ -196,6 +563,18 @@ func (p *Project) FunctionByName(name string) (*function.Function, error) {
 	return nil, ErrNotFound
 }
 
+// Output returns output `attr` of function `name`, resolving references
+// such as ${function:worker.arn} in the configuration of other functions.
+// List the function in dependsOn when both are deployed together.
+func (p *Project) Output(name, attr string) (string, error) {
+	fn, err := p.FunctionByName(name)
+	if err != nil {
+		return "", fmt.Errorf("function %q: %s", name, err)
+	}
+
+	return fn.Output(attr)
+}
+
 // FunctionDirNames returns a list of function directory names.
 func (p *Project) FunctionDirNames() (list []string, err error) {
 	dir := filepath.Join(p.Path, "functions")
@@ -225,6 +604,11 @@ func (p *Project) FunctionNames() (list []string) {
 
 // SetEnv sets environment variable `name` to `value` on every function in project.
 func (p *Project) SetEnv(name, value string) {
+	if p.env == nil {
+		p.env = make(map[string]string)
+	}
+	p.env[name] = value
+
 	for _, fn := range p.Functions {
 		fn.SetEnv(name, value)
 	}
@@ -249,7 +633,11 @@ func (p *Project) loadFunctions() error {
 		p.Functions = append(p.Functions, fn)
 	}
 
-	return nil
+	if err := p.checkNameCollisions(); err != nil {
+		return err
+	}
+
+	return p.checkDependencies()
 }
 
 // loadFunction returns the function in the ./functions/<name> directory.
@@ -259,15 +647,77 @@ func (p *Project) loadFunction(name string) (*function.Function, error) {
 
 	fn := &function.Function{
 		Config: function.Config{
-			Runtime: p.Config.Runtime,
-			Memory:  p.Config.Memory,
-			Timeout: p.Config.Timeout,
-			Role:    p.Config.Role,
+			Runtime:            p.Config.Runtime,
+			Memory:             p.Config.Memory,
+			Timeout:            p.Config.Timeout,
+			Role:               p.Config.Role,
+			LogRetention:       p.Config.LogRetention,
+			Warm:               p.Config.Warm,
+			Docker:             p.Config.Docker,
+			ConflictTimeout:    p.Config.ConflictTimeout,
+			Alias:              p.Config.Alias,
+			VersionDescription: p.Config.VersionDescription,
+			EnvMode:            p.Config.EnvMode,
 		},
-		Name:    name,
-		Path:    dir,
-		Service: p.Service,
-		Log:     p.Log,
+		Name:           name,
+		Path:           dir,
+		Stage:          p.Stage,
+		Region:         p.Region,
+		Partition:      p.Partition,
+		PreferRuntimes: p.PreferRuntimes,
+		OverrideBudget: p.OverrideBudget,
+		NoPublish:      p.NoPublish,
+		Service:        p.Service,
+		AppSync:        p.AppSync,
+		CloudFront:     p.CloudFront,
+		CloudWatch:     p.CloudWatch,
+		CloudWatchLogs: p.CloudWatchLogs,
+		EventBridge:    p.EventBridge,
+		DynamoDB:       p.DynamoDB,
+		IAM:            p.IAM,
+		S3:             p.S3,
+		Signer:         p.Signer,
+		SQS:            p.SQS,
+		SSM:            p.SSM,
+		Decrypter:      p.Decrypter,
+		Observer:       p.Observer,
+		Metrics:        p.Metrics,
+		Git:            p.Git,
+		Resolver:       p,
+		Log:            p.Log,
+	}
+
+	if s := p.Config.LogSubscription; s != nil {
+		c := *s
+		fn.LogSubscription = &c
+	}
+
+	if d := p.Config.Deadlines; d != nil {
+		c := *d
+		fn.Deadlines = &c
+	}
+
+	if a := p.Config.Artifacts; a != nil {
+		c := *a
+		fn.Artifacts = &c
+	}
+
+	if z := p.Config.Compression; z != nil {
+		c := *z
+		fn.Compression = &c
+	}
+
+	if s := p.Config.Store; s != nil {
+		c := *s
+		fn.Store = &c
+	}
+
+	if p.lock != nil {
+		fn.Locker = p.lock
+	}
+
+	if p.releases != nil {
+		fn.Releases = p.releases
 	}
 
 	if name, err := p.name(fn); err == nil {
@@ -276,15 +726,58 @@ func (p *Project) loadFunction(name string) (*function.Function, error) {
 		return nil, err
 	}
 
+	if p.Shim != "" {
+		fn.ShimPath = filepath.Join(p.Path, p.Shim)
+	}
+
+	if p.BuildCache != "" {
+		fn.BuildCache = filepath.Join(p.Path, p.BuildCache, name)
+	}
+
+	if p.parameterPath != nil {
+		path, err := p.render(p.parameterPath, fn)
+		if err != nil {
+			return nil, err
+		}
+		fn.ParameterPath = cleanParameterPath(path)
+	}
+
 	if err := fn.Open(); err != nil {
 		return nil, err
 	}
 
+	p.edge(fn)
 	return fn, nil
 }
 
-// name returns the computed name for `fn`, using the nameTemplate.
+// edge switches Lambda@Edge function `fn` to function.EdgeRegion and the
+// EdgeService, without the regional services of the project's region.
+func (p *Project) edge(fn *function.Function) {
+	if fn.Edge == nil || p.Region == function.EdgeRegion || p.EdgeService == nil {
+		return
+	}
+
+	p.Log.Debugf("deploying %s to %s", fn.Name, function.EdgeRegion)
+	fn.Region = function.EdgeRegion
+	fn.Service = p.EdgeService
+	fn.CloudWatch = nil
+	fn.CloudWatchLogs = nil
+	fn.EventBridge = nil
+}
+
+// name returns the computed name for `fn`, using the nameTemplate
+// followed by the NamePrefix and NameSuffix policies.
 func (p *Project) name(fn *function.Function) (string, error) {
+	name, err := p.render(p.nameTemplate, fn)
+	if err != nil {
+		return "", err
+	}
+
+	return p.applyNamePolicy(name)
+}
+
+// render returns template `t` executed against the project and `fn`.
+func (p *Project) render(t *template.Template, fn *function.Function) (string, error) {
 	data := struct {
 		Project  *Project
 		Function *function.Function
@@ -293,12 +786,7 @@ func (p *Project) name(fn *function.Function) (string, error) {
 		Function: fn,
 	}
 
-	name, err := render(p.nameTemplate, data)
-	if err != nil {
-		return "", err
-	}
-
-	return name, nil
+	return render(t, data)
 }
 
 // render returns a string by executing template `t` against the given value `v`.
@@ -1,11 +1,25 @@
 package project_test
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
 	"testing"
 
+	_ "github.com/apex/apex/runtime/nodejs"
+
+	"github.com/apex/apex/function"
+	"github.com/apex/apex/offline"
 	"github.com/apex/apex/project"
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/discard"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,3 +36,336 @@ func TestProject_Open_requireConfigValues(t *testing.T) {
 
 	assert.Contains(t, nameErr.Error(), "Name: zero value")
 }
+
+func TestProject_Open_nameTemplate(t *testing.T) {
+	p := &project.Project{
+		Path:  "_fixtures/naming",
+		Stage: "prod",
+		Log:   log.Log,
+	}
+
+	assert.Nil(t, p.Open())
+
+	fn, err := p.FunctionByName("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, "acme-app_foo_prod", fn.FunctionName)
+	assert.Equal(t, "/app/prod/foo", fn.ParameterPath)
+
+	p = &project.Project{
+		Path: "_fixtures/naming",
+		Log:  log.Log,
+	}
+
+	assert.Nil(t, p.Open())
+
+	fn, err = p.FunctionByName("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, "acme-app_foo", fn.FunctionName)
+	assert.Equal(t, "/app/foo", fn.ParameterPath)
+}
+
+func TestProject_Open_nameCollision(t *testing.T) {
+	p := &project.Project{
+		Path: "_fixtures/collision",
+		Log:  log.Log,
+	}
+
+	assert.EqualError(t, p.Open(), `functions bar and foo are both named "app", check nameTemplate`)
+}
+
+type quotaService struct {
+	lambdaiface.LambdaAPI
+}
+
+func (s *quotaService) GetAccountSettings(*lambda.GetAccountSettingsInput) (*lambda.GetAccountSettingsOutput, error) {
+	return &lambda.GetAccountSettingsOutput{
+		AccountLimit: &lambda.AccountLimit{
+			TotalCodeSize:                  aws.Int64(100),
+			ConcurrentExecutions:           aws.Int64(1000),
+			UnreservedConcurrentExecutions: aws.Int64(1000),
+		},
+		AccountUsage: &lambda.AccountUsage{TotalCodeSize: aws.Int64(95)},
+	}, nil
+}
+
+func (s *quotaService) GetFunctionConcurrency(in *lambda.GetFunctionConcurrencyInput) (*lambda.GetFunctionConcurrencyOutput, error) {
+	return &lambda.GetFunctionConcurrencyOutput{ReservedConcurrentExecutions: aws.Int64(0)}, nil
+}
+
+func TestProject_Preflight(t *testing.T) {
+	p := &project.Project{
+		Service:   &quotaService{},
+		Log:       log.Log,
+		Functions: []*function.Function{{Name: "foo", FunctionName: "app_foo"}},
+	}
+
+	warnings, err := p.Preflight([]string{"foo"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"code storage is 95% used (95 of 100 bytes), remove old versions to publish new ones",
+		"function foo has a reserved concurrency of 0, all invocations will be throttled",
+	}, warnings)
+}
+
+type deleteService struct {
+	lambdaiface.LambdaAPI
+	deleted []string
+}
+
+func (s *deleteService) DeleteFunction(in *lambda.DeleteFunctionInput) (*lambda.DeleteFunctionOutput, error) {
+	if *in.FunctionName == "app_bar" {
+		return nil, errors.New("boom")
+	}
+	s.deleted = append(s.deleted, *in.FunctionName)
+	return &lambda.DeleteFunctionOutput{}, nil
+}
+
+func TestProject_Delete_errors(t *testing.T) {
+	s := &deleteService{}
+	p := &project.Project{
+		Log: log.Log,
+		Functions: []*function.Function{
+			{Name: "foo", FunctionName: "app_foo", Service: s, Log: log.Log},
+			{Name: "bar", FunctionName: "app_bar", Service: s, Log: log.Log},
+			{Name: "baz", FunctionName: "app_baz", Service: s, Log: log.Log},
+		},
+	}
+
+	err := p.Delete([]string{"foo", "bar", "baz"}, function.DeleteOptions{Force: true})
+	assert.Equal(t, []string{"app_foo", "app_baz"}, s.deleted)
+
+	e, ok := err.(*project.Errors)
+	assert.True(t, ok)
+	assert.True(t, e.Partial())
+	assert.Equal(t, []string{"bar"}, e.Functions())
+	assert.EqualError(t, err, "1 of 3 functions failed\n  function bar: boom")
+}
+
+func TestProject_Open_cyclicDependencies(t *testing.T) {
+	p := &project.Project{
+		Path: "_fixtures/cyclic",
+		Log:  log.Log,
+	}
+
+	assert.EqualError(t, p.Open(), "functions have cyclic dependencies: api -> worker -> queue -> api")
+}
+
+func TestProject_DeployOrder(t *testing.T) {
+	p := &project.Project{
+		Log: log.Log,
+		Functions: []*function.Function{
+			{Name: "api", Config: function.Config{DependsOn: []string{"worker", "auth"}}},
+			{Name: "worker", Config: function.Config{DependsOn: []string{"queue"}}},
+			{Name: "queue"},
+			{Name: "auth"},
+			{Name: "cron"},
+		},
+	}
+
+	assert.Equal(t, [][]string{
+		{"queue", "auth", "cron"},
+		{"worker"},
+		{"api"},
+	}, p.DeployOrder([]string{"api", "worker", "queue", "auth", "cron"}))
+
+	assert.Equal(t, [][]string{
+		{"worker", "auth"},
+		{"api"},
+	}, p.DeployOrder([]string{"api", "worker", "auth"}))
+}
+
+type callerService struct {
+	stsiface.STSAPI
+}
+
+func (s *callerService) GetCallerIdentity(*sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{
+		Account: aws.String("123456789012"),
+		Arn:     aws.String("arn:aws:sts::123456789012:assumed-role/deployer/ci"),
+	}, nil
+}
+
+type simulateService struct {
+	iamiface.IAMAPI
+	principals []string
+}
+
+func (s *simulateService) SimulatePrincipalPolicyPages(in *iam.SimulatePrincipalPolicyInput, fn func(*iam.SimulatePolicyResponse, bool) bool) error {
+	s.principals = append(s.principals, *in.PolicySourceArn)
+
+	page := &iam.SimulatePolicyResponse{}
+	for _, action := range in.ActionNames {
+		decision := iam.PolicyEvaluationDecisionTypeAllowed
+		if *action == "iam:PassRole" || *action == "lambda:PublishVersion" {
+			decision = iam.PolicyEvaluationDecisionTypeImplicitDeny
+		}
+
+		page.EvaluationResults = append(page.EvaluationResults, &iam.EvaluationResult{
+			EvalActionName: action,
+			EvalDecision:   aws.String(decision),
+		})
+	}
+
+	fn(page, true)
+	return nil
+}
+
+func TestProject_CheckPermissions(t *testing.T) {
+	s := &simulateService{}
+	p := &project.Project{
+		Region: "us-west-2",
+		IAM:    s,
+		STS:    &callerService{},
+		Log:    log.Log,
+		Functions: []*function.Function{
+			{Name: "foo", FunctionName: "app_foo", Config: function.Config{Role: "lambda"}},
+		},
+	}
+
+	denied, err := p.CheckPermissions([]string{"foo"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"iam:PassRole on arn:aws:iam::123456789012:role/lambda",
+		"lambda:PublishVersion on arn:aws:lambda:us-west-2:123456789012:function:app_foo",
+	}, denied)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/deployer", s.principals[0])
+}
+
+func TestProject_Select(t *testing.T) {
+	p := &project.Project{
+		Functions: []*function.Function{
+			{Name: "user_a", Config: function.Config{Groups: []string{"api"}}},
+			{Name: "user_b", Config: function.Config{Groups: []string{"api", "workers"}}},
+			{Name: "worker", Config: function.Config{Groups: []string{"workers"}}},
+		},
+	}
+
+	names, err := p.Select(nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"user_a", "user_b", "worker"}, names)
+
+	names, err = p.Select([]string{"user_*", "worker", "user_b"}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"user_a", "user_b", "worker"}, names)
+
+	names, err = p.Select(nil, []string{"workers"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"user_b", "worker"}, names)
+
+	names, err = p.Select([]string{"user_*"}, []string{"workers"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"user_b"}, names)
+
+	names, err = p.Select([]string{"missing"}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"missing"}, names)
+
+	_, err = p.Select([]string{"user_a"}, []string{"workers"})
+	assert.EqualError(t, err, "function user_a is not in group workers")
+
+	_, err = p.Select([]string{"admin_*"}, nil)
+	assert.EqualError(t, err, `no functions match "admin_*"`)
+
+	_, err = p.Select(nil, []string{"cron"})
+	assert.EqualError(t, err, `no functions in group "cron"`)
+}
+
+type configService struct {
+	lambdaiface.LambdaAPI
+}
+
+func (s *configService) GetFunctionConfiguration(in *lambda.GetFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
+	if *in.FunctionName == "app_bar" {
+		return nil, errors.New("boom")
+	}
+
+	runtime := "nodejs18.x"
+	if *in.FunctionName == "app_baz" {
+		runtime = "nodejs22.x"
+	}
+
+	return &lambda.FunctionConfiguration{Runtime: &runtime}, nil
+}
+
+func TestProject_PlanChange(t *testing.T) {
+	s := &configService{}
+	p := &project.Project{
+		Log:         log.Log,
+		Concurrency: 2,
+		Functions: []*function.Function{
+			{Name: "foo", FunctionName: "app_foo", Service: s, Log: log.Log},
+			{Name: "bar", FunctionName: "app_bar", Service: s, Log: log.Log},
+			{Name: "baz", FunctionName: "app_baz", Service: s, Log: log.Log},
+		},
+	}
+
+	plan, err := p.PlanChange([]string{"foo", "bar", "baz"}, &function.Change{Runtime: "nodejs22.x"})
+	assert.EqualError(t, err, "1 of 3 functions failed\n  function bar: boom")
+	assert.Equal(t, map[string][]*function.Update{
+		"foo": {{Field: "runtime", From: "nodejs18.x", To: "nodejs22.x"}},
+	}, plan)
+
+	_, err = p.PlanChange([]string{"foo"}, &function.Change{Memory: 64})
+	assert.EqualError(t, err, "Memory: 64MB is outside the valid range of 128 to 10240MB")
+}
+
+func TestProject_Open_partition(t *testing.T) {
+	p := &project.Project{
+		Path:   "_fixtures/naming",
+		Region: "us-gov-west-1",
+		Log:    log.Log,
+	}
+
+	assert.Nil(t, p.Open())
+	assert.Equal(t, "aws-us-gov", p.Partition)
+
+	fn, err := p.FunctionByName("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, "aws-us-gov", fn.Partition)
+
+	p = &project.Project{
+		Path:   "_fixtures/naming",
+		Region: "cn-north-1",
+		Log:    log.Log,
+	}
+
+	p.Partition = "aws"
+	assert.EqualError(t, p.Open(), `partition "aws" does not include region cn-north-1`)
+}
+
+func TestProject_Apply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offline")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	s := &deleteService{}
+	q := &offline.Queue{Path: dir}
+	p := &project.Project{
+		Log:     log.Log,
+		Offline: q,
+		Functions: []*function.Function{
+			{Name: "foo", FunctionName: "app_foo", Service: s, Log: log.Log},
+			{Name: "bar", FunctionName: "app_bar", Service: s, Log: log.Log},
+			{Name: "baz", FunctionName: "app_baz", Service: s, Log: log.Log},
+		},
+	}
+
+	assert.Nil(t, p.Delete([]string{"foo", "bar", "baz"}, function.DeleteOptions{Force: true}))
+	assert.Empty(t, s.deleted)
+
+	assert.EqualError(t, p.Apply(q), "cannot apply queued operations in offline mode")
+
+	p.Offline = nil
+	err = p.Apply(q)
+	assert.Contains(t, err.Error(), "delete bar")
+	assert.Contains(t, err.Error(), ": boom")
+	assert.Equal(t, []string{"app_foo"}, s.deleted)
+
+	ops, err := q.List()
+	assert.Nil(t, err)
+	assert.Len(t, ops, 2)
+	assert.Equal(t, "bar", ops[0].Function)
+
+	p.Stage = "prod"
+	assert.Contains(t, p.Apply(q).Error(), `queued for stage "", not "prod"`)
+}
@@ -0,0 +1,94 @@
+package project
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Select returns the names of functions matching `selectors` and in any of
+// `groups`, defaulting to all functions. Selectors are function names or
+// glob patterns such as "user_*". Names are returned as given even when
+// the function does not exist, while patterns and groups matching no
+// functions are an error.
+func (p *Project) Select(selectors, groups []string) ([]string, error) {
+	for _, g := range groups {
+		if !p.hasGroup(g) {
+			return nil, fmt.Errorf("no functions in group %q", g)
+		}
+	}
+
+	if len(selectors) == 0 {
+		selectors = []string{"*"}
+	}
+
+	var list []string
+	seen := make(map[string]bool)
+
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			list = append(list, name)
+		}
+	}
+
+	for _, s := range selectors {
+		if !isPattern(s) {
+			if fn, err := p.FunctionByName(s); err == nil && !inGroups(fn.Groups, groups) {
+				return nil, fmt.Errorf("function %s is not in group %s", s, strings.Join(groups, " or "))
+			}
+			add(s)
+			continue
+		}
+
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q", s)
+		}
+
+		n := len(list)
+
+		for _, fn := range p.Functions {
+			if ok, _ := path.Match(s, fn.Name); ok && inGroups(fn.Groups, groups) {
+				add(fn.Name)
+			}
+		}
+
+		if len(list) == n && s != "*" {
+			return nil, fmt.Errorf("no functions match %q", s)
+		}
+	}
+
+	return list, nil
+}
+
+// hasGroup returns true if any function is in group `name`.
+func (p *Project) hasGroup(name string) bool {
+	for _, fn := range p.Functions {
+		if inGroups(fn.Groups, []string{name}) {
+			return true
+		}
+	}
+	return false
+}
+
+// inGroups returns true if `list` contains any of `groups`, or when `groups` is empty.
+func inGroups(list, groups []string) bool {
+	if len(groups) == 0 {
+		return true
+	}
+
+	for _, g := range groups {
+		for _, s := range list {
+			if s == g {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isPattern returns true if `s` is a glob pattern.
+func isPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
@@ -0,0 +1,81 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/apex/apex/statemachine"
+)
+
+// StateMachines returns the state machines defined in ./statemachines/<name>.json,
+// deployed as "<project>_<name>".
+func (p *Project) StateMachines() ([]*statemachine.StateMachine, error) {
+	paths, err := filepath.Glob(filepath.Join(p.Path, "statemachines", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*statemachine.StateMachine
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+
+		list = append(list, &statemachine.StateMachine{
+			Name:    fmt.Sprintf("%s_%s", p.Name, name),
+			Path:    path,
+			Role:    p.StateMachineRole,
+			Service: p.StepFunctions,
+			Log:     p.Log.WithField("statemachine", name),
+		})
+	}
+
+	return list, nil
+}
+
+// DeployStateMachines creates or updates the project's state machines,
+// resolving "${function:<name>}" placeholders to the ARN of the
+// function's current alias.
+func (p *Project) DeployStateMachines() error {
+	machines, err := p.StateMachines()
+	if err != nil {
+		return err
+	}
+
+	if len(machines) == 0 {
+		return nil
+	}
+
+	if p.StepFunctions == nil {
+		p.Log.Debug("skipping state machines, no Step Functions service")
+		return nil
+	}
+
+	if p.StateMachineRole == "" {
+		return errors.New("stateMachineRole is required to deploy state machines")
+	}
+
+	for _, m := range machines {
+		if err := m.Deploy(p.functionArn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// functionArn returns the ARN of the current alias of function `name`.
+func (p *Project) functionArn(name string) (string, error) {
+	fn, err := p.FunctionByName(name)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := fn.Info()
+	if err != nil {
+		return "", err
+	}
+
+	return info.ARN + ":" + fn.AliasName(), nil
+}
@@ -0,0 +1,31 @@
+package project
+
+import (
+	"github.com/apex/apex/function"
+	"github.com/apex/apex/trail"
+)
+
+// recordOperation records `operation` of `fn` in the trailTable, if any.
+// Failing to record is logged as a warning, as the operation has already
+// been run.
+func (p *Project) recordOperation(operation string, fn *function.Function, version string, err error) {
+	if p.trail == nil {
+		return
+	}
+
+	e := &trail.Entry{
+		Function:  fn.FunctionName,
+		Operation: operation,
+		Stage:     p.Stage,
+		Version:   version,
+		User:      function.Deployer(),
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	if err := p.trail.Record(e); err != nil {
+		p.Log.Warnf("error recording %s of %s: %s", operation, fn.Name, err)
+	}
+}
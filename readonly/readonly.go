@@ -0,0 +1,67 @@
+// Package readonly guards AWS sessions against mutating requests, which
+// are skipped and logged as planned changes, while reads are sent as usual.
+package readonly
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// reads are the operation name prefixes which do not mutate resources.
+var reads = []string{
+	"BatchGet",
+	"Describe",
+	"Filter",
+	"Get",
+	"Head",
+	"List",
+	"Lookup",
+	"Query",
+	"Scan",
+	"Simulate",
+}
+
+// Mutating returns true unless `operation` is known to be read-only.
+func Mutating(operation string) bool {
+	for _, prefix := range reads {
+		if strings.HasPrefix(operation, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// Guard skips the mutating requests of clients created from `s`
+// afterwards, logging each to `l` as a planned change. Skipped
+// requests succeed with empty outputs.
+func Guard(s *session.Session, l log.Interface) {
+	s.Handlers.Validate.PushFront(func(r *request.Request) {
+		if !Mutating(r.Operation.Name) {
+			return
+		}
+
+		l.Infof("plan: %s %s", r.ClientInfo.ServiceName, r.Operation.Name)
+		skip(r)
+	})
+}
+
+// skip `r`, leaving its output empty.
+func skip(r *request.Request) {
+	r.Handlers.Send.Clear()
+	r.Handlers.UnmarshalMeta.Clear()
+	r.Handlers.ValidateResponse.Clear()
+	r.Handlers.Unmarshal.Clear()
+	r.Handlers.Retry.Clear()
+
+	r.HTTPResponse = &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+	}
+}
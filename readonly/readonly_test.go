@@ -0,0 +1,60 @@
+package readonly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMutating(t *testing.T) {
+	assert.False(t, Mutating("GetFunction"))
+	assert.False(t, Mutating("ListAliases"))
+	assert.False(t, Mutating("SimulatePrincipalPolicy"))
+	assert.True(t, Mutating("UpdateFunctionCode"))
+	assert.True(t, Mutating("PutParameter"))
+	assert.True(t, Mutating("Invoke"))
+}
+
+func TestGuard(t *testing.T) {
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"AliasArn": "arn:aws:lambda:us-west-2:123456789012:function:app_foo:current"}`))
+	}))
+	defer server.Close()
+
+	s := session.Must(session.NewSession(aws.NewConfig().
+		WithRegion("us-west-2").
+		WithEndpoint(server.URL).
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", ""))))
+
+	Guard(s, log.Log)
+	svc := lambda.New(s)
+
+	out, err := svc.UpdateAlias(&lambda.UpdateAliasInput{
+		FunctionName:    aws.String("app_foo"),
+		Name:            aws.String("current"),
+		FunctionVersion: aws.String("2"),
+	})
+
+	assert.Nil(t, err)
+	assert.Nil(t, out.AliasArn)
+	assert.Equal(t, 0, requests)
+
+	alias, err := svc.GetAlias(&lambda.GetAliasInput{
+		FunctionName: aws.String("app_foo"),
+		Name:         aws.String("current"),
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "arn:aws:lambda:us-west-2:123456789012:function:app_foo:current", aws.StringValue(alias.AliasArn))
+	assert.Equal(t, 1, requests)
+}
@@ -0,0 +1,275 @@
+// Package record stores the invocations of functions recorded during
+// testing, in a local directory or S3, and replays their events against
+// other versions, comparing the replies for regression testing of
+// handler changes.
+//
+// Invocations are stored as JSON objects named after their time in unix
+// nanoseconds, under a prefix per function: <dest>/<function>/<time>.json.
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apex/apex/function"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// Store records invocations, and lists those of a function.
+type Store interface {
+	function.Recorder
+	List(fn string) ([]*function.Invocation, error)
+}
+
+// Open returns the store of `dest`, an s3://bucket/prefix
+// URI or a local directory, created on first record.
+func Open(dest string, service s3iface.S3API) (Store, error) {
+	if !strings.HasPrefix(dest, "s3://") {
+		return &Dir{Path: dest}, nil
+	}
+
+	if service == nil {
+		return nil, fmt.Errorf("no S3 service to record to %s", dest)
+	}
+
+	bucket := strings.TrimPrefix(dest, "s3://")
+	prefix := ""
+
+	if i := strings.Index(bucket, "/"); i != -1 {
+		bucket, prefix = bucket[:i], strings.Trim(bucket[i+1:], "/")
+	}
+
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid recording destination %q, missing bucket", dest)
+	}
+
+	return &S3{Service: service, Bucket: bucket, Prefix: prefix}, nil
+}
+
+// Dir stores invocations in a local directory.
+type Dir struct {
+	Path string
+}
+
+// Record invocation `i`.
+func (d *Dir) Record(i *function.Invocation) error {
+	dir := filepath.Join(d.Path, i.Function)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, name(i)), b, 0644)
+}
+
+// List the invocations of function `fn`, oldest first.
+func (d *Dir) List(fn string) ([]*function.Invocation, error) {
+	files, err := ioutil.ReadDir(filepath.Join(d.Path, fn))
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*function.Invocation
+
+	for _, f := range files {
+		if filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(d.Path, fn, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		i, err := unmarshal(b, f.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, i)
+	}
+
+	sortByTime(list)
+	return list, nil
+}
+
+// S3 stores invocations in an S3 bucket.
+type S3 struct {
+	Service s3iface.S3API
+	Bucket  string
+	Prefix  string
+}
+
+// Record invocation `i`.
+func (s *S3) Record(i *function.Invocation) error {
+	b, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Service.PutObject(&s3.PutObjectInput{
+		Bucket:      &s.Bucket,
+		Key:         aws.String(path.Join(s.Prefix, i.Function, name(i))),
+		Body:        bytes.NewReader(b),
+		ContentType: aws.String("application/json"),
+	})
+
+	return err
+}
+
+// List the invocations of function `fn`, oldest first.
+func (s *S3) List(fn string) ([]*function.Invocation, error) {
+	var keys []string
+
+	err := s.Service.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: &s.Bucket,
+		Prefix: aws.String(path.Join(s.Prefix, fn) + "/"),
+	}, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, o := range page.Contents {
+			if path.Ext(*o.Key) == ".json" {
+				keys = append(keys, *o.Key)
+			}
+		}
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*function.Invocation
+
+	for _, key := range keys {
+		res, err := s.Service.GetObject(&s3.GetObjectInput{
+			Bucket: &s.Bucket,
+			Key:    aws.String(key),
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		i, err := unmarshal(b, key)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, i)
+	}
+
+	sortByTime(list)
+	return list, nil
+}
+
+// Result is the result of replaying a recorded invocation.
+type Result struct {
+	Recorded *function.Invocation
+	Reply    []byte
+	Error    string
+	Duration time.Duration
+}
+
+// Match returns true if the replay replied or failed as recorded,
+// comparing JSON replies regardless of formatting and key order.
+func (r *Result) Match() bool {
+	return r.Error == r.Recorded.Error && Equal(r.Reply, r.Recorded.Reply)
+}
+
+// Replay the events of `invocations` against `qualifier` of `fn`,
+// defaulting to its alias, returning the result of each in order.
+// Function errors are part of the results, however other errors
+// abort the replay.
+func Replay(fn *function.Function, invocations []*function.Invocation, qualifier string) ([]*Result, error) {
+	var results []*Result
+
+	for _, i := range invocations {
+		r := &Result{Recorded: i}
+
+		start := time.Now()
+
+		reply, _, err := fn.InvokeWithOptions(i.Event, function.InvokeOptions{
+			Qualifier: qualifier,
+			NoLogs:    true,
+		})
+
+		r.Duration = time.Since(start)
+
+		switch e := err.(type) {
+		case nil:
+			if r.Reply, err = ioutil.ReadAll(reply); err != nil {
+				return nil, err
+			}
+		case *function.InvokeError:
+			r.Error = e.Message
+		default:
+			return nil, err
+		}
+
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+// Equal returns true if replies `a` and `b` are equal, as JSON
+// values when both are valid JSON, otherwise byte for byte.
+func Equal(a, b []byte) bool {
+	var va, vb interface{}
+
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
+
+// name returns the object name of invocation `i`.
+func name(i *function.Invocation) string {
+	return fmt.Sprintf("%d.json", i.Time.UnixNano())
+}
+
+// unmarshal the invocation `b` read from `name`.
+func unmarshal(b []byte, name string) (*function.Invocation, error) {
+	var i function.Invocation
+
+	if err := json.Unmarshal(b, &i); err != nil {
+		return nil, fmt.Errorf("parsing recording %s: %s", name, err)
+	}
+
+	return &i, nil
+}
+
+// sortByTime sorts `list` by time, oldest first.
+func sortByTime(list []*function.Invocation) {
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].Time.Before(list[b].Time)
+	})
+}
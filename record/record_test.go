@@ -0,0 +1,146 @@
+package record
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/apex/apex/function"
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+type replies struct {
+	lambdaiface.LambdaAPI
+	replies map[string]string
+}
+
+func (s *replies) Invoke(in *lambda.InvokeInput) (*lambda.InvokeOutput, error) {
+	reply, ok := s.replies[string(in.Payload)]
+	if !ok {
+		return &lambda.InvokeOutput{
+			FunctionError: aws.String("Unhandled"),
+			Payload:       []byte(`{"errorMessage":"unknown event"}`),
+		}, nil
+	}
+
+	return &lambda.InvokeOutput{
+		ExecutedVersion: in.Qualifier,
+		Payload:         []byte(reply),
+	}, nil
+}
+
+func TestOpen(t *testing.T) {
+	s, err := Open("/tmp/recordings", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, &Dir{Path: "/tmp/recordings"}, s)
+
+	s, err = Open("s3://bucket/apex/recordings/", &s3.S3{})
+	assert.Nil(t, err)
+	assert.Equal(t, "bucket", s.(*S3).Bucket)
+	assert.Equal(t, "apex/recordings", s.(*S3).Prefix)
+
+	_, err = Open("s3://bucket", nil)
+	assert.EqualError(t, err, "no S3 service to record to s3://bucket")
+}
+
+func TestDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	d := &Dir{Path: dir}
+
+	list, err := d.List("foo")
+	assert.Nil(t, err)
+	assert.Empty(t, list)
+
+	second := &function.Invocation{Function: "foo", Event: []byte(`{"n":2}`), Time: time.Unix(2, 0)}
+	first := &function.Invocation{Function: "foo", Event: []byte(`{"n":1}`), Reply: []byte(`"ok"`), Time: time.Unix(1, 0)}
+
+	assert.Nil(t, d.Record(second))
+	assert.Nil(t, d.Record(first))
+	assert.Nil(t, d.Record(&function.Invocation{Function: "bar", Time: time.Unix(3, 0)}))
+
+	list, err = d.List("foo")
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, `{"n":1}`, string(list[0].Event))
+	assert.Equal(t, `"ok"`, string(list[0].Reply))
+	assert.Equal(t, `{"n":2}`, string(list[1].Event))
+}
+
+func TestReplay(t *testing.T) {
+	fn := &function.Function{
+		Name:         "foo",
+		FunctionName: "app_foo",
+		Service: &replies{replies: map[string]string{
+			`{"n":1}`: `{"a": 1, "b": 2}`,
+			`{"n":2}`: `{"a":2}`,
+		}},
+		Log: log.Log,
+	}
+
+	recorded := []*function.Invocation{
+		{Event: []byte(`{"n":1}`), Reply: []byte(`{"b":2,"a":1}`)},
+		{Event: []byte(`{"n":2}`), Reply: []byte(`{"a":1}`)},
+		{Event: []byte(`{"n":3}`), Error: "unknown event"},
+		{Event: []byte(`{"n":1}`), Error: "boom"},
+	}
+
+	results, err := Replay(fn, recorded, "5")
+	assert.Nil(t, err)
+	assert.Len(t, results, 4)
+
+	var matches []bool
+	for _, r := range results {
+		matches = append(matches, r.Match())
+	}
+
+	assert.Equal(t, []bool{true, false, true, false}, matches)
+	assert.Equal(t, `{"a":2}`, string(results[1].Reply))
+	assert.Equal(t, "unknown event", results[2].Error)
+}
+
+func TestEqual(t *testing.T) {
+	assert.True(t, Equal([]byte(`{"a":[1,2]}`), []byte(`{ "a": [1, 2] }`)))
+	assert.False(t, Equal([]byte(`{"a":[1,2]}`), []byte(`{"a":[2,1]}`)))
+	assert.True(t, Equal([]byte("raw"), []byte("raw")))
+	assert.False(t, Equal([]byte("raw"), []byte(`"raw"`)))
+	assert.True(t, Equal(nil, nil))
+}
+
+func TestFunction_Recorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fn := &function.Function{
+		Name:         "foo",
+		FunctionName: "app_foo",
+		Service:      &replies{replies: map[string]string{`{"n":1}`: `"ok"`}},
+		Recorder:     &Dir{Path: dir},
+		Log:          log.Log,
+	}
+
+	_, _, err = fn.InvokeWithOptions([]byte(`{"n":1}`), function.InvokeOptions{Qualifier: "3", NoLogs: true})
+	assert.Nil(t, err)
+
+	_, _, err = fn.InvokeWithOptions([]byte(`{"n":2}`), function.InvokeOptions{Qualifier: "3", NoLogs: true})
+	assert.EqualError(t, err, "unknown event")
+
+	list, err := (&Dir{Path: dir}).List("foo")
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+
+	assert.Equal(t, `{"n":1}`, string(list[0].Event))
+	assert.Equal(t, "3", list[0].Qualifier)
+	assert.Equal(t, "3", list[0].Version)
+	assert.Equal(t, `"ok"`, string(list[0].Reply))
+	assert.Equal(t, "unknown event", list[1].Error)
+}
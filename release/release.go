@@ -0,0 +1,282 @@
+// Package release records deployed function versions in DynamoDB,
+// providing per-function history for auditing and rollbacks.
+//
+// The table must have a string partition key named "function"
+// and a numeric sort key named "version".
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/apex/apex/function"
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Release is a deployed version of a function.
+type Release struct {
+	Function   string
+	Version    string
+	CodeSha256 string
+	Commit     string
+	Author     string
+	Deployed   time.Time
+	Config     map[string]interface{}
+	Changes    []*Change
+}
+
+// Change is a configuration field changed by a release.
+type Change struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// String representation.
+func (c *Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Field, encode(c.From), encode(c.To))
+}
+
+// Store implements function.Releases with a DynamoDB table.
+type Store struct {
+	Service dynamodbiface.DynamoDBAPI
+	Log     log.Interface
+	Table   string
+	Author  string
+}
+
+// Record the release of `version`, diffing its configuration
+// against the previous release.
+func (s *Store) Record(f *function.Function, version, codeSha256 string) error {
+	config, err := configMap(f.Config)
+	if err != nil {
+		return err
+	}
+
+	r := &Release{
+		Function:   f.FunctionName,
+		Version:    version,
+		CodeSha256: codeSha256,
+		Author:     s.author(),
+		Deployed:   time.Now(),
+		Config:     config,
+	}
+
+	if f.Git != nil {
+		r.Commit = f.Git.Commit
+	}
+
+	prev, err := s.query(f.FunctionName, version, 1)
+	if err != nil {
+		return err
+	}
+
+	if len(prev) > 0 {
+		r.Changes = Diff(prev[0].Config, r.Config)
+	}
+
+	item, err := marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.Log.Debugf("recording release %s of %s", version, f.FunctionName)
+
+	_, err = s.Service.PutItem(&dynamodb.PutItemInput{
+		TableName: &s.Table,
+		Item:      item,
+	})
+
+	return err
+}
+
+// Previous returns the version released before `version`.
+func (s *Store) Previous(f *function.Function, version string) (string, error) {
+	list, err := s.query(f.FunctionName, version, 1)
+	if err != nil || len(list) == 0 {
+		return "", err
+	}
+
+	return list[0].Version, nil
+}
+
+// History returns up to `limit` releases of the function
+// named `name`, most recent first. Zero is unlimited.
+func (s *Store) History(name string, limit int) ([]*Release, error) {
+	return s.query(name, "", limit)
+}
+
+// query releases of function `name` before `version` (when non-empty), most recent first.
+func (s *Store) query(name, version string, limit int) ([]*Release, error) {
+	in := &dynamodb.QueryInput{
+		TableName:              &s.Table,
+		KeyConditionExpression: aws.String("#function = :function"),
+		ExpressionAttributeNames: map[string]*string{
+			"#function": aws.String("function"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":function": {S: &name},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	if version != "" {
+		if _, err := strconv.Atoi(version); err != nil {
+			return nil, nil
+		}
+
+		in.KeyConditionExpression = aws.String("#function = :function AND #version < :version")
+		in.ExpressionAttributeNames["#version"] = aws.String("version")
+		in.ExpressionAttributeValues[":version"] = &dynamodb.AttributeValue{N: &version}
+	}
+
+	var list []*Release
+	var err error
+
+	err = s.Service.QueryPages(in, func(page *dynamodb.QueryOutput, last bool) bool {
+		for _, item := range page.Items {
+			var r *Release
+
+			if r, err = unmarshal(item); err != nil {
+				return false
+			}
+
+			list = append(list, r)
+
+			if limit > 0 && len(list) == limit {
+				return false
+			}
+		}
+		return true
+	})
+
+	return list, err
+}
+
+// author of releases, defaulting to $USER.
+func (s *Store) author() string {
+	if s.Author != "" {
+		return s.Author
+	}
+
+	if u := os.Getenv("USER"); u != "" {
+		return u
+	}
+
+	return "unknown"
+}
+
+// Diff returns the changed fields between configurations `a` and `b`.
+func Diff(a, b map[string]interface{}) (changes []*Change) {
+	fields := make(map[string]bool)
+
+	for k := range a {
+		fields[k] = true
+	}
+
+	for k := range b {
+		fields[k] = true
+	}
+
+	for k := range fields {
+		if encode(a[k]) != encode(b[k]) {
+			changes = append(changes, &Change{Field: k, From: a[k], To: b[k]})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes
+}
+
+// configMap returns the JSON representation of `c` as a map.
+func configMap(c function.Config) (map[string]interface{}, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	return m, json.Unmarshal(b, &m)
+}
+
+// encode returns the JSON encoding of `v`.
+func encode(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// marshal `r` to a DynamoDB item.
+func marshal(r *Release) (map[string]*dynamodb.AttributeValue, error) {
+	config, err := json.Marshal(r.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := json.Marshal(r.Changes)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]*dynamodb.AttributeValue{
+		"function":   {S: &r.Function},
+		"version":    {N: &r.Version},
+		"codeSha256": {S: aws.String(r.CodeSha256)},
+		"commit":     {S: aws.String(r.Commit)},
+		"author":     {S: aws.String(r.Author)},
+		"deployed":   {N: aws.String(strconv.FormatInt(r.Deployed.Unix(), 10))},
+		"config":     {S: aws.String(string(config))},
+		"changes":    {S: aws.String(string(changes))},
+	}, nil
+}
+
+// unmarshal a DynamoDB item to a release.
+func unmarshal(item map[string]*dynamodb.AttributeValue) (*Release, error) {
+	str := func(name string) string {
+		if v := item[name]; v != nil {
+			return aws.StringValue(v.S)
+		}
+		return ""
+	}
+
+	num := func(name string) string {
+		if v := item[name]; v != nil {
+			return aws.StringValue(v.N)
+		}
+		return ""
+	}
+
+	deployed, _ := strconv.ParseInt(num("deployed"), 10, 64)
+
+	r := &Release{
+		Function:   str("function"),
+		Version:    num("version"),
+		CodeSha256: str("codeSha256"),
+		Commit:     str("commit"),
+		Author:     str("author"),
+		Deployed:   time.Unix(deployed, 0),
+	}
+
+	if s := str("config"); s != "" {
+		if err := json.Unmarshal([]byte(s), &r.Config); err != nil {
+			return nil, err
+		}
+	}
+
+	if s := str("changes"); s != "" {
+		if err := json.Unmarshal([]byte(s), &r.Changes); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,39 @@
+package release
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	a := map[string]interface{}{"memory": 128.0, "timeout": 3.0, "role": "a"}
+	b := map[string]interface{}{"memory": 256.0, "timeout": 3.0, "description": "foo"}
+
+	changes := Diff(a, b)
+	assert.Equal(t, 3, len(changes))
+	assert.Equal(t, "description: null -> \"foo\"", changes[0].String())
+	assert.Equal(t, "memory: 128 -> 256", changes[1].String())
+	assert.Equal(t, "role: \"a\" -> null", changes[2].String())
+}
+
+func TestMarshal(t *testing.T) {
+	r := &Release{
+		Function:   "app_foo",
+		Version:    "3",
+		CodeSha256: "sha",
+		Commit:     "abc",
+		Author:     "tj",
+		Deployed:   time.Unix(1000, 0),
+		Config:     map[string]interface{}{"memory": 128.0},
+		Changes:    []*Change{{Field: "memory", From: 64.0, To: 128.0}},
+	}
+
+	item, err := marshal(r)
+	assert.Nil(t, err)
+
+	out, err := unmarshal(item)
+	assert.Nil(t, err)
+	assert.Equal(t, r, out)
+}
@@ -0,0 +1,215 @@
+// Package repl implements an interactive prompt for invoking functions.
+package repl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/apex/apex/function"
+	applogs "github.com/apex/apex/logs"
+	"github.com/apex/apex/project"
+	"github.com/chzyer/readline"
+)
+
+// help output.
+const help = `
+  Enter a JSON event to invoke the selected function, events
+  may span multiple lines. The following commands are available:
+
+    :use <name>   Select the function to invoke
+    :list         List functions
+    :help         Output help
+    :quit         Exit
+`
+
+// REPL reads events from an interactive prompt and invokes the selected
+// function, pretty-printing the reply and logs.
+type REPL struct {
+	// Project containing the functions.
+	Project *project.Project
+
+	// Function selected, if any.
+	Function *function.Function
+
+	// HistoryFile persists the prompt history when set.
+	HistoryFile string
+
+	// Out is where replies and logs are written.
+	Out io.Writer
+
+	// Color enables colorized log output.
+	Color bool
+
+	rl  *readline.Instance
+	buf bytes.Buffer
+}
+
+// Start the REPL, returning when the user exits.
+func (r *REPL) Start() error {
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:                 r.prompt(),
+		HistoryFile:            r.HistoryFile,
+		DisableAutoSaveHistory: true,
+		Stdout:                 r.Out,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	r.rl = rl
+	defer rl.Close()
+
+	fmt.Fprintf(r.Out, "\n  Type :help for commands.\n\n")
+
+	for {
+		line, err := rl.Readline()
+
+		if err == readline.ErrInterrupt {
+			r.reset()
+			continue
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if r.buf.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			rl.SaveHistory(line)
+
+			if quit := r.command(strings.Fields(line)); quit {
+				return nil
+			}
+
+			continue
+		}
+
+		r.input(line)
+	}
+}
+
+// input appends `line` to the pending event, invoking once it is complete.
+func (r *REPL) input(line string) {
+	if r.buf.Len() == 0 && strings.TrimSpace(line) == "" {
+		return
+	}
+
+	r.buf.WriteString(line + "\n")
+
+	var v interface{}
+	err := json.NewDecoder(bytes.NewReader(r.buf.Bytes())).Decode(&v)
+
+	if err == io.ErrUnexpectedEOF {
+		r.rl.SetPrompt("... ")
+		return
+	}
+
+	event := strings.TrimSpace(r.buf.String())
+	r.rl.SaveHistory(strings.Join(strings.Fields(event), " "))
+	r.reset()
+
+	if err != nil {
+		fmt.Fprintf(r.Out, "  error parsing event: %s\n", err)
+		return
+	}
+
+	r.invoke([]byte(event))
+}
+
+// reset discards the pending event.
+func (r *REPL) reset() {
+	r.buf.Reset()
+	r.rl.SetPrompt(r.prompt())
+}
+
+// prompt returns the prompt for the selected function.
+func (r *REPL) prompt() string {
+	if r.Function == nil {
+		return "apex> "
+	}
+	return r.Function.Name + "> "
+}
+
+// command executes `args`, returning true to exit.
+func (r *REPL) command(args []string) bool {
+	switch args[0] {
+	case ":quit", ":q", ":exit":
+		return true
+	case ":help":
+		fmt.Fprint(r.Out, help+"\n")
+	case ":list":
+		for _, fn := range r.Project.Functions {
+			fmt.Fprintf(r.Out, "  - %s\n", fn.Name)
+		}
+	case ":use":
+		if len(args) != 2 {
+			fmt.Fprintf(r.Out, "  usage: :use <name>\n")
+			break
+		}
+
+		fn, err := r.Project.FunctionByName(args[1])
+		if err != nil {
+			fmt.Fprintf(r.Out, "  error: %s %q\n", err, args[1])
+			break
+		}
+
+		r.Function = fn
+		r.rl.SetPrompt(r.prompt())
+	default:
+		fmt.Fprintf(r.Out, "  unknown command %s, try :help\n", args[0])
+	}
+
+	return false
+}
+
+// invoke the selected function with `event`.
+func (r *REPL) invoke(event []byte) {
+	if r.Function == nil {
+		fmt.Fprintf(r.Out, "  no function selected, try :use <name>\n")
+		return
+	}
+
+	reply, logs, err := r.Function.InvokeWithOptions(event, function.InvokeOptions{})
+
+	if e, ok := err.(*function.InvokeError); ok {
+		fmt.Fprintf(r.Out, "  %s: %s\n", e.Type, e.Message)
+		if e.Retryable {
+			fmt.Fprintf(r.Out, "    (retryable)\n")
+		}
+		for _, line := range e.Stack {
+			fmt.Fprintf(r.Out, "    %s\n", line)
+		}
+		return
+	}
+
+	if err != nil {
+		fmt.Fprintf(r.Out, "  error: %s\n", err)
+		return
+	}
+
+	f := applogs.NewFormatter(r.Out, r.Color)
+	io.Copy(f, logs)
+	f.Flush()
+
+	b, err := ioutil.ReadAll(reply)
+	if err != nil {
+		fmt.Fprintf(r.Out, "  error reading reply: %s\n", err)
+		return
+	}
+
+	var out bytes.Buffer
+	if json.Indent(&out, b, "", "  ") != nil {
+		out.Reset()
+		out.Write(b)
+	}
+
+	fmt.Fprintf(r.Out, "%s\n", out.String())
+}
@@ -0,0 +1,55 @@
+package bun
+
+import (
+	"path/filepath"
+
+	"github.com/apex/apex/runtime"
+	"github.com/apex/apex/runtime/provided"
+)
+
+func init() {
+	runtime.Register("bun", func() runtime.Runtime {
+		return new(Runtime)
+	})
+}
+
+type Runtime struct{}
+
+func (r *Runtime) Name() string {
+	return provided.Name
+}
+
+func (r *Runtime) Handler() string {
+	return provided.Handler
+}
+
+func (r *Runtime) Shimmed() bool {
+	return false
+}
+
+func (r *Runtime) DefaultFile() string {
+	return "bun.lock*"
+}
+
+// Score the bun lockfile as a marker, outranking the entry
+// files of other JavaScript runtimes, supported by package.json.
+func (r *Runtime) Score(dir string) int {
+	return runtime.Score(dir, runtime.ScoreMarker, "bun.lock*", "package.json")
+}
+
+// Files uses a bundled "bun" binary, or the one at /opt/bun
+// provided by the bun-lambda layer.
+func (r *Runtime) Files() map[string][]byte {
+	return provided.Files("bun", "/opt/bun", "run")
+}
+
+// TestCommand runs "bun test" when the function has *.test.* or *_test.* files.
+func (r *Runtime) TestCommand(dir string) []string {
+	for _, pattern := range []string{"*.test.*", "*_test.*"} {
+		if matches, _ := filepath.Glob(filepath.Join(dir, pattern)); len(matches) > 0 {
+			return []string{"bun", "test"}
+		}
+	}
+
+	return nil
+}
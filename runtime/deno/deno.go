@@ -0,0 +1,55 @@
+package deno
+
+import (
+	"path/filepath"
+
+	"github.com/apex/apex/runtime"
+	"github.com/apex/apex/runtime/provided"
+)
+
+func init() {
+	runtime.Register("deno", func() runtime.Runtime {
+		return new(Runtime)
+	})
+}
+
+type Runtime struct{}
+
+func (r *Runtime) Name() string {
+	return provided.Name
+}
+
+func (r *Runtime) Handler() string {
+	return provided.Handler
+}
+
+func (r *Runtime) Shimmed() bool {
+	return false
+}
+
+func (r *Runtime) DefaultFile() string {
+	return "deno.json*"
+}
+
+// Score the deno configuration as a marker, outranking
+// the entry files of other JavaScript runtimes.
+func (r *Runtime) Score(dir string) int {
+	return runtime.Score(dir, runtime.ScoreMarker, "deno.json*")
+}
+
+// Files uses a bundled "deno" binary, or the one at /opt/bin/deno
+// provided by a Deno layer.
+func (r *Runtime) Files() map[string][]byte {
+	return provided.Files("deno", "/opt/bin/deno", "run --allow-all --no-prompt")
+}
+
+// TestCommand runs "deno test" when the function has *.test.* or *_test.* files.
+func (r *Runtime) TestCommand(dir string) []string {
+	for _, pattern := range []string{"*.test.*", "*_test.*"} {
+		if matches, _ := filepath.Glob(filepath.Join(dir, pattern)); len(matches) > 0 {
+			return []string{"deno", "test", "--allow-all"}
+		}
+	}
+
+	return nil
+}
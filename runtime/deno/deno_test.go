@@ -0,0 +1,36 @@
+package deno
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apex/apex/runtime"
+	"github.com/apex/apex/runtime/provided"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntime_detect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex-deno")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "main.ts"), nil, 0644))
+	_, err = runtime.Detect(dir)
+	assert.EqualError(t, err, "runtime not detected")
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "deno.jsonc"), nil, 0644))
+	assert.Equal(t, []runtime.Candidate{{Name: "deno", Score: runtime.ScoreMarker}}, runtime.Candidates(dir))
+}
+
+func TestRuntime_Files(t *testing.T) {
+	files := new(Runtime).Files()
+	assert.Len(t, files, 2)
+	assert.NotNil(t, files[provided.LoopFile])
+
+	bootstrap := string(files["bootstrap"])
+	assert.Contains(t, bootstrap, `bin="$LAMBDA_TASK_ROOT/deno"`)
+	assert.Contains(t, bootstrap, `[ -x "$bin" ] || bin="/opt/bin/deno"`)
+	assert.Contains(t, bootstrap, `exec "$bin" run --allow-all --no-prompt "$LAMBDA_TASK_ROOT/`+provided.LoopFile+`"`)
+}
@@ -0,0 +1,79 @@
+package dotnet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apex/apex/runtime"
+)
+
+func init() {
+	runtime.Register("dotnet", func() runtime.Runtime {
+		return new(Runtime)
+	})
+}
+
+// rids maps Lambda architectures to .NET runtime identifiers.
+var rids = map[string]string{
+	"x86_64": "linux-x64",
+	"arm64":  "linux-arm64",
+}
+
+// output directory of "dotnet publish".
+const output = "publish"
+
+type Runtime struct{}
+
+func (r *Runtime) Name() string {
+	return "dotnet8"
+}
+
+// Handler must be configured in the form "<assembly>::<type>::<method>".
+func (r *Runtime) Handler() string {
+	return ""
+}
+
+func (r *Runtime) Shimmed() bool {
+	return false
+}
+
+func (r *Runtime) DefaultFile() string {
+	return "*.csproj"
+}
+
+// Build for the context's architecture, using the "nuget" directory
+// of the build cache as the NuGet packages folder when configured.
+func (r *Runtime) Build(ctx *runtime.BuildContext) error {
+	if _, ok := rids[ctx.Arch]; !ok {
+		return fmt.Errorf("unsupported architecture %q", ctx.Arch)
+	}
+
+	cmd := ctx.Command("sh", "-c", r.BuildCommand(ctx.Arch))
+
+	if dir := ctx.Cache("nuget"); dir != "" {
+		cmd.Env = append(cmd.Env, "NUGET_PACKAGES="+dir)
+	}
+
+	return cmd.Run()
+}
+
+func (r *Runtime) BuildImage() string {
+	return "public.ecr.aws/sam/build-dotnet8"
+}
+
+func (r *Runtime) BuildCommand(arch string) string {
+	return fmt.Sprintf("dotnet publish -c Release -r %s --self-contained false -o %s", rids[arch], output)
+}
+
+func (r *Runtime) PackageDir() string {
+	return output
+}
+
+func (r *Runtime) Clean(dir string) error {
+	return os.RemoveAll(filepath.Join(dir, output))
+}
+
+func (r *Runtime) TestCommand(dir string) []string {
+	return []string{"dotnet", "test"}
+}
@@ -0,0 +1,54 @@
+package dotnet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apex/apex/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntime_detect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex-dotnet")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = runtime.Detect(dir)
+	assert.EqualError(t, err, "runtime not detected")
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "Function.csproj"), nil, 0644))
+
+	name, err := runtime.Detect(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, "dotnet", name)
+}
+
+func TestRuntime_BuildCommand(t *testing.T) {
+	r := new(Runtime)
+	assert.Equal(t, "dotnet publish -c Release -r linux-x64 --self-contained false -o publish", r.BuildCommand("x86_64"))
+	assert.Equal(t, "dotnet publish -c Release -r linux-arm64 --self-contained false -o publish", r.BuildCommand("arm64"))
+}
+
+func TestRuntime_Build(t *testing.T) {
+	err := new(Runtime).Build(&runtime.BuildContext{Arch: "s390x"})
+	assert.EqualError(t, err, `unsupported architecture "s390x"`)
+}
+
+func TestRuntime_Clean(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex-dotnet")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	r := new(Runtime)
+	assert.Equal(t, "publish", r.PackageDir())
+
+	out := filepath.Join(dir, r.PackageDir())
+	assert.Nil(t, os.MkdirAll(out, 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(out, "Function.dll"), nil, 0644))
+
+	assert.Nil(t, r.Clean(dir))
+	_, err = os.Stat(out)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -3,17 +3,42 @@ package golang
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/apex/apex/runtime"
 )
 
 func init() {
-	runtime.Register("golang", new(Runtime))
+	runtime.Register("golang", func() runtime.Runtime {
+		return new(Runtime)
+	})
 }
 
-type Runtime struct{}
+// goarchs maps Lambda architectures to GOARCH values.
+var goarchs = map[string]string{
+	"x86_64": "amd64",
+	"arm64":  "arm64",
+}
+
+type Runtime struct {
+	options runtime.GoOptions
+	pkg     string
+}
+
+// Configure the build, which is module-aware when the
+// function directory is within a Go module.
+func (r *Runtime) Configure(dir string, options runtime.GoOptions) {
+	r.options = options
+	r.pkg = "main.go"
+
+	for d, _ := filepath.Abs(dir); filepath.Dir(d) != d; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			r.pkg = "."
+			break
+		}
+	}
+}
 
 func (r *Runtime) Name() string {
 	return "nodejs"
@@ -27,13 +52,85 @@ func (r *Runtime) Handler() string {
 	return "index.handle"
 }
 
-func (r *Runtime) Build(dir string) error {
-	s := fmt.Sprintf("cd %s && GOOS=linux GOARCH=amd64 go build -o main main.go", dir)
-	cmd := exec.Command("sh", "-c", s)
-	cmd.Stderr = os.Stderr
+// Build for the context's architecture, using the "go-build"
+// directory of the build cache as GOCACHE when configured.
+func (r *Runtime) Build(ctx *runtime.BuildContext) error {
+	if _, ok := goarchs[ctx.Arch]; !ok {
+		return fmt.Errorf("unsupported architecture %q", ctx.Arch)
+	}
+
+	cmd := ctx.Command("sh", "-c", r.BuildCommand(ctx.Arch))
+
+	if dir := ctx.Cache("go-build"); dir != "" {
+		cmd.Env = append(cmd.Env, "GOCACHE="+dir)
+	}
+
 	return cmd.Run()
 }
 
+func (r *Runtime) BuildImage() string {
+	return "public.ecr.aws/sam/build-go1.x"
+}
+
+func (r *Runtime) BuildCommand(arch string) string {
+	cgo := 0
+	if r.options.CGO {
+		cgo = 1
+	}
+
+	args := []string{fmt.Sprintf("CGO_ENABLED=%d GOOS=linux GOARCH=%s go build", cgo, goarchs[arch])}
+
+	if len(r.options.Tags) > 0 {
+		args = append(args, "-tags", quote(strings.Join(r.options.Tags, ",")))
+	}
+
+	if r.options.LDFlags != "" {
+		args = append(args, "-ldflags", quote(r.options.LDFlags))
+	}
+
+	if r.options.TrimPath {
+		args = append(args, "-trimpath")
+	}
+
+	pkg := r.pkg
+	if pkg == "" {
+		pkg = "main.go"
+	}
+
+	return strings.Join(append(args, "-o", "main", pkg), " ")
+}
+
+// quote `s` for the shell.
+func quote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// TestCommand runs the tests of the module's packages within the function
+// directory, or of the function's package outside of a module.
+func (r *Runtime) TestCommand(dir string) []string {
+	args := []string{"go", "test"}
+
+	if len(r.options.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(r.options.Tags, ","))
+	}
+
+	if r.pkg == "." {
+		return append(args, "./...")
+	}
+
+	return append(args, ".")
+}
+
+// AuditCommand runs govulncheck, which reports vulnerabilities reachable
+// from the function regardless of `level`, as severities are not assigned.
+func (r *Runtime) AuditCommand(dir, level string) []string {
+	if r.pkg == "." {
+		return []string{"govulncheck", "./..."}
+	}
+
+	return []string{"govulncheck", "."}
+}
+
 func (r *Runtime) Clean(dir string) error {
 	return os.Remove(filepath.Join(dir, "main"))
 }
@@ -41,3 +138,8 @@ func (r *Runtime) Clean(dir string) error {
 func (r *Runtime) DefaultFile() string {
 	return "main.go"
 }
+
+// Score main.go, supported by go.mod.
+func (r *Runtime) Score(dir string) int {
+	return runtime.Score(dir, runtime.ScoreEntry, "main.go", "go.mod")
+}
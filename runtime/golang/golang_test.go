@@ -0,0 +1,33 @@
+package golang
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apex/apex/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntime_BuildCommand(t *testing.T) {
+	r := new(Runtime)
+	assert.Equal(t, "CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -o main main.go", r.BuildCommand("x86_64"))
+
+	dir, err := ioutil.TempDir("", "apex-golang")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	r.Configure(dir, runtime.GoOptions{
+		Tags:     []string{"lambda", "prod"},
+		LDFlags:  "-s -w -X 'main.version=1.0'",
+		CGO:      true,
+		TrimPath: true,
+	})
+
+	assert.Equal(t, `CGO_ENABLED=1 GOOS=linux GOARCH=arm64 go build -tags 'lambda,prod' -ldflags '-s -w -X '\''main.version=1.0'\''' -trimpath -o main main.go`, r.BuildCommand("arm64"))
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module foo\n"), 0644))
+	r.Configure(dir, runtime.GoOptions{})
+	assert.Equal(t, "CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -o main .", r.BuildCommand("x86_64"))
+}
@@ -1,11 +1,17 @@
 package nodejs
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/apex/apex/runtime"
 )
 
 func init() {
-	runtime.Register("nodejs", new(Runtime))
+	runtime.Register("nodejs", func() runtime.Runtime {
+		return new(Runtime)
+	})
 }
 
 type Runtime struct{}
@@ -25,3 +31,50 @@ func (r *Runtime) Shimmed() bool {
 func (r *Runtime) DefaultFile() string {
 	return "index.js"
 }
+
+// Score index.js, supported by package.json.
+func (r *Runtime) Score(dir string) int {
+	return runtime.Score(dir, runtime.ScoreEntry, "index.js", "package.json")
+}
+
+func (r *Runtime) BuildImage() string {
+	return "public.ecr.aws/sam/build-nodejs18.x"
+}
+
+func (r *Runtime) BuildCommand(arch string) string {
+	return "test ! -f package.json || npm install --production"
+}
+
+func (r *Runtime) TestCommand(dir string) []string {
+	return NPMTest(dir)
+}
+
+// NPMTest returns "npm test" when the package.json in `dir` defines a test
+// script other than the placeholder generated by "npm init".
+func NPMTest(dir string) []string {
+	m, err := readManifest(dir)
+	if err != nil {
+		return nil
+	}
+
+	script := m.Scripts["test"]
+	if script == "" || strings.Contains(script, "no test specified") {
+		return nil
+	}
+
+	return []string{"npm", "test"}
+}
+
+func (r *Runtime) AuditCommand(dir, level string) []string {
+	return NPMAudit(dir, level)
+}
+
+// NPMAudit returns "npm audit" of production dependencies when
+// the function in `dir` has a package-lock.json.
+func NPMAudit(dir, level string) []string {
+	if _, err := os.Stat(filepath.Join(dir, "package-lock.json")); err != nil {
+		return nil
+	}
+
+	return []string{"npm", "audit", "--omit=dev", "--audit-level=" + level}
+}
@@ -0,0 +1,35 @@
+package nodejs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntime_TestCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex-nodejs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, new(Runtime).TestCommand(dir))
+
+	write(t, filepath.Join(dir, "package.json"), `{ "scripts": { "test": "echo \"Error: no test specified\" && exit 1" } }`)
+	assert.Nil(t, new(Runtime).TestCommand(dir))
+
+	write(t, filepath.Join(dir, "package.json"), `{ "scripts": { "test": "node --test" } }`)
+	assert.Equal(t, []string{"npm", "test"}, new(Runtime).TestCommand(dir))
+}
+
+func TestRuntime_AuditCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex-nodejs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, new(Runtime).AuditCommand(dir, "high"))
+
+	write(t, filepath.Join(dir, "package-lock.json"), `{}`)
+	assert.Equal(t, []string{"npm", "audit", "--omit=dev", "--audit-level=high"}, new(Runtime).AuditCommand(dir, "high"))
+}
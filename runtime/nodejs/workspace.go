@@ -0,0 +1,113 @@
+package nodejs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// manifest is a package.json file.
+type manifest struct {
+	Dependencies         map[string]string `json:"dependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	Workspaces           json.RawMessage   `json:"workspaces"`
+	Scripts              map[string]string `json:"scripts"`
+}
+
+// readManifest reads the package.json in `dir`.
+func readManifest(dir string) (*manifest, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%s: %s", filepath.Join(dir, "package.json"), err)
+	}
+
+	return &m, nil
+}
+
+// Dependencies returns the directory of each package in the production
+// dependency graph, by zip path such as "node_modules/foo", when the
+// function is part of an npm, yarn or pnpm workspace. Packages are resolved
+// as Node does, walking up to the workspace root's node_modules and
+// following workspace symlinks, and are flattened by name with the first
+// resolved winning. Nil is returned outside of workspaces.
+func (r *Runtime) Dependencies(dir string) (map[string]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "package.json")); err != nil || !inWorkspace(dir) {
+		return nil, nil
+	}
+
+	deps := make(map[string]string)
+	return deps, resolve(dir, deps)
+}
+
+// resolve the dependencies of the package in `dir` into `deps`.
+func resolve(dir string, deps map[string]string) error {
+	m, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, list := range []map[string]string{m.Dependencies, m.OptionalDependencies} {
+		for name := range list {
+			key := "node_modules/" + name
+			if _, ok := deps[key]; ok {
+				continue
+			}
+
+			path, err := lookup(dir, name)
+			if err != nil {
+				if _, ok := m.OptionalDependencies[name]; ok {
+					continue
+				}
+				return err
+			}
+
+			deps[key] = path
+
+			if err := resolve(path, deps); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// lookup returns the real directory of package `name` required from `dir`.
+func lookup(dir, name string) (string, error) {
+	for d := dir; ; d = filepath.Dir(d) {
+		if path, err := filepath.EvalSymlinks(filepath.Join(d, "node_modules", name)); err == nil {
+			return path, nil
+		}
+
+		if filepath.Dir(d) == d {
+			return "", fmt.Errorf("dependency %q of %s not found, install it first", name, dir)
+		}
+	}
+}
+
+// inWorkspace returns true if a parent of `dir` is a workspace root.
+func inWorkspace(dir string) bool {
+	for d := filepath.Dir(dir); filepath.Dir(d) != d; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "pnpm-workspace.yaml")); err == nil {
+			return true
+		}
+
+		if m, err := readManifest(d); err == nil && len(m.Workspaces) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
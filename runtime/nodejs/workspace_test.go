@@ -0,0 +1,41 @@
+package nodejs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func write(t *testing.T, path, s string) {
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.Nil(t, ioutil.WriteFile(path, []byte(s), 0644))
+}
+
+func TestRuntime_Dependencies(t *testing.T) {
+	root, err := ioutil.TempDir("", "apex-workspace")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	root, _ = filepath.EvalSymlinks(root)
+
+	write(t, filepath.Join(root, "package.json"), `{ "workspaces": ["packages/*", "functions/*"] }`)
+	write(t, filepath.Join(root, "packages/lib/package.json"), `{ "dependencies": { "left-pad": "1" } }`)
+	write(t, filepath.Join(root, "node_modules/left-pad/package.json"), `{}`)
+	write(t, filepath.Join(root, "node_modules/unused/package.json"), `{}`)
+	write(t, filepath.Join(root, "functions/foo/package.json"), `{ "dependencies": { "lib": "*" } }`)
+	assert.Nil(t, os.Symlink(filepath.Join(root, "packages/lib"), filepath.Join(root, "node_modules/lib")))
+
+	deps, err := new(Runtime).Dependencies(filepath.Join(root, "functions/foo"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"node_modules/lib":      filepath.Join(root, "packages/lib"),
+		"node_modules/left-pad": filepath.Join(root, "node_modules/left-pad"),
+	}, deps)
+
+	deps, err = new(Runtime).Dependencies(filepath.Join(root, "packages/missing"))
+	assert.Nil(t, err)
+	assert.Nil(t, deps)
+}
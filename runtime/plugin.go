@@ -0,0 +1,140 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// PluginPrefix is the executable name prefix of runtime plugins,
+// for example "apex-runtime-deno" provides the "deno" runtime.
+const PluginPrefix = "apex-runtime-"
+
+// PluginPathEnv is the environment variable listing directories searched
+// for runtime plugins, in addition to PATH.
+const PluginPathEnv = "APEX_RUNTIME_PATH"
+
+// PluginInfo is the JSON output of "<plugin> info".
+type PluginInfo struct {
+	Name        string `json:"name"`
+	Handler     string `json:"handler"`
+	Shimmed     bool   `json:"shimmed"`
+	DefaultFile string `json:"defaultFile"`
+	Compiled    bool   `json:"compiled"`
+}
+
+// plugin is a runtime implemented by an external executable.
+type plugin struct {
+	path string
+	info PluginInfo
+}
+
+// Name implementation.
+func (p *plugin) Name() string {
+	return p.info.Name
+}
+
+// Handler implementation.
+func (p *plugin) Handler() string {
+	return p.info.Handler
+}
+
+// Shimmed implementation.
+func (p *plugin) Shimmed() bool {
+	return p.info.Shimmed
+}
+
+// DefaultFile implementation.
+func (p *plugin) DefaultFile() string {
+	return p.info.DefaultFile
+}
+
+// compiledPlugin is a plugin runtime requiring compilation,
+// performed by "<plugin> build <dir> <arch>" with the build
+// environment, APEX_STAGE and APEX_CACHE_DIR.
+type compiledPlugin struct {
+	*plugin
+}
+
+// Build implementation.
+func (p *compiledPlugin) Build(ctx *BuildContext) error {
+	cmd := ctx.Command(p.path, "build", ctx.Dir, ctx.Arch)
+	cmd.Dir = ""
+	cmd.Env = append(cmd.Env, "APEX_STAGE="+ctx.Stage, "APEX_CACHE_DIR="+ctx.CacheDir)
+	return cmd.Run()
+}
+
+// Clean implementation.
+func (p *compiledPlugin) Clean(dir string) error {
+	cmd := exec.Command(p.path, "clean", dir)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// LoadPlugin registers the runtime plugin executable at `path`,
+// named by the executable name without PluginPrefix.
+func LoadPlugin(path string) error {
+	name := strings.TrimPrefix(filepath.Base(path), PluginPrefix)
+
+	b, err := exec.Command(path, "info").Output()
+	if err != nil {
+		return fmt.Errorf("runtime plugin %s: %s", name, err)
+	}
+
+	p := &plugin{path: path}
+
+	if err := json.Unmarshal(b, &p.info); err != nil {
+		return fmt.Errorf("runtime plugin %s: invalid info: %s", name, err)
+	}
+
+	if p.info.Name == "" || p.info.Handler == "" {
+		return fmt.Errorf("runtime plugin %s: name and handler are required", name)
+	}
+
+	Register(name, func() Runtime {
+		if p.info.Compiled {
+			return &compiledPlugin{p}
+		}
+		return p
+	})
+
+	return nil
+}
+
+// LoadPlugins registers the runtime plugins found in the APEX_RUNTIME_PATH
+// directories and PATH. Built-in runtimes take precedence, as do plugins
+// found earlier in the search path.
+func LoadPlugins() error {
+	dirs := filepath.SplitList(os.Getenv(PluginPathEnv))
+	dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, file := range files {
+			name := strings.TrimPrefix(file.Name(), PluginPrefix)
+
+			if name == file.Name() || file.IsDir() || file.Mode()&0111 == 0 {
+				continue
+			}
+
+			if _, ok := runtimes[name]; ok {
+				continue
+			}
+
+			if err := LoadPlugin(filepath.Join(dir, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPlugin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex-runtime")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, PluginPrefix+"deno")
+	script := `#!/bin/sh
+echo '{"name": "provided.al2", "handler": "main.handler", "defaultFile": "deno.json", "compiled": true}'
+`
+	assert.Nil(t, ioutil.WriteFile(path, []byte(script), 0755))
+	assert.Nil(t, LoadPlugin(path))
+
+	r, err := ByName("deno")
+	assert.Nil(t, err)
+	assert.Equal(t, "provided.al2", r.Name())
+	assert.Equal(t, "main.handler", r.Handler())
+
+	_, ok := r.(CompiledRuntime)
+	assert.True(t, ok)
+}
@@ -0,0 +1,103 @@
+// Package provided implements support for JavaScript runtimes such as Deno
+// and Bun, built on the provided.al2 custom runtime.
+package provided
+
+import "fmt"
+
+// Name of the Lambda custom runtime.
+const Name = "provided.al2"
+
+// Handler is the default handler, the "handler" export of main.ts or main.js.
+const Handler = "main.handler"
+
+// LoopFile is the name of the runtime loop module in the zip.
+const LoopFile = "_apex_runtime.mjs"
+
+// bootstrap script, preferring a binary bundled in the zip to one provided by a layer.
+const bootstrap = `#!/bin/sh
+set -e
+export DENO_DIR="${DENO_DIR:-/tmp/deno}"
+bin="$LAMBDA_TASK_ROOT/%s"
+[ -x "$bin" ] || bin="%s"
+exec "$bin" %s "$LAMBDA_TASK_ROOT/%s"
+`
+
+// loop is the runtime loop, which polls the Lambda runtime API for events
+// and invokes the "<file>.<export>" handler with each.
+const loop = `import process from 'node:process'
+import { existsSync } from 'node:fs'
+
+const env = process.env
+const api = 'http://' + env.AWS_LAMBDA_RUNTIME_API + '/2018-06-01/runtime'
+
+function error(err) {
+  return JSON.stringify({
+    errorType: err.name || 'Error',
+    errorMessage: err.message || String(err),
+    stackTrace: String(err.stack || '').split('\n').slice(1).map(s => s.trim())
+  })
+}
+
+async function load() {
+  const i = env._HANDLER.lastIndexOf('.')
+  const file = env.LAMBDA_TASK_ROOT + '/' + env._HANDLER.slice(0, i)
+  const name = env._HANDLER.slice(i + 1)
+
+  for (const ext of ['.ts', '.js', '.mjs']) {
+    if (existsSync(file + ext)) {
+      const fn = (await import('file://' + file + ext))[name]
+      if (typeof fn != 'function') throw new Error('handler ' + env._HANDLER + ' is not a function')
+      return fn
+    }
+  }
+
+  throw new Error('handler ' + env._HANDLER + ' not found')
+}
+
+let handler
+
+try {
+  handler = await load()
+} catch (err) {
+  await fetch(api + '/init/error', { method: 'POST', body: error(err) })
+  process.exit(1)
+}
+
+while (true) {
+  const next = await fetch(api + '/invocation/next')
+  const id = next.headers.get('lambda-runtime-aws-request-id')
+  const deadline = Number(next.headers.get('lambda-runtime-deadline-ms'))
+  const event = await next.json()
+
+  env._X_AMZN_TRACE_ID = next.headers.get('lambda-runtime-trace-id') || ''
+
+  const context = {
+    awsRequestId: id,
+    functionName: env.AWS_LAMBDA_FUNCTION_NAME,
+    functionVersion: env.AWS_LAMBDA_FUNCTION_VERSION,
+    invokedFunctionArn: next.headers.get('lambda-runtime-invoked-function-arn'),
+    getRemainingTimeInMillis: () => deadline - Date.now()
+  }
+
+  try {
+    const res = event && event.source == 'apex.warm' ? null : await handler(event, context)
+    await fetch(api + '/invocation/' + id + '/response', { method: 'POST', body: JSON.stringify(res === undefined ? null : res) })
+  } catch (err) {
+    await fetch(api + '/invocation/' + id + '/error', {
+      method: 'POST',
+      headers: { 'Lambda-Runtime-Function-Error-Type': 'Unhandled' },
+      body: error(err)
+    })
+  }
+}
+`
+
+// Files returns the "bootstrap" executable and runtime loop to be added to the
+// zip. The bootstrap runs the loop with `args` using the `bin` executable bundled
+// with the function, falling back to the `layer` path, typically under /opt.
+func Files(bin, layer, args string) map[string][]byte {
+	return map[string][]byte{
+		"bootstrap": []byte(fmt.Sprintf(bootstrap, bin, layer, args, LoopFile)),
+		LoopFile:    []byte(loop),
+	}
+}
@@ -1,11 +1,15 @@
 package python
 
 import (
+	"path/filepath"
+
 	"github.com/apex/apex/runtime"
 )
 
 func init() {
-	runtime.Register("python", new(Runtime))
+	runtime.Register("python", func() runtime.Runtime {
+		return new(Runtime)
+	})
 }
 
 type Runtime struct{}
@@ -25,3 +29,38 @@ func (r *Runtime) Shimmed() bool {
 func (r *Runtime) DefaultFile() string {
 	return "main.py"
 }
+
+// Score main.py, supported by requirements.txt or pyproject.toml.
+func (r *Runtime) Score(dir string) int {
+	return runtime.Score(dir, runtime.ScoreEntry, "main.py", "requirements.txt", "pyproject.toml")
+}
+
+func (r *Runtime) BuildImage() string {
+	return "lambci/lambda:build-python2.7"
+}
+
+func (r *Runtime) BuildCommand(arch string) string {
+	return "test ! -f requirements.txt || pip install -r requirements.txt -t ."
+}
+
+// TestCommand runs pytest when the function has test_*.py
+// or *_test.py files, or a tests directory.
+func (r *Runtime) TestCommand(dir string) []string {
+	for _, pattern := range []string{"test_*.py", "*_test.py", "tests"} {
+		if matches, _ := filepath.Glob(filepath.Join(dir, pattern)); len(matches) > 0 {
+			return []string{"python", "-m", "pytest"}
+		}
+	}
+
+	return nil
+}
+
+// AuditCommand runs pip-audit against requirements.txt, which reports
+// any known vulnerability regardless of `level`.
+func (r *Runtime) AuditCommand(dir, level string) []string {
+	if matches, _ := filepath.Glob(filepath.Join(dir, "requirements.txt")); len(matches) == 0 {
+		return nil
+	}
+
+	return []string{"pip-audit", "-r", "requirements.txt"}
+}
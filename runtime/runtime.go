@@ -3,13 +3,23 @@
 package runtime
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apex/log"
 )
 
 // Registered runtimes.
-var runtimes = make(map[string]Runtime)
+var runtimes = make(map[string]Factory)
+
+// Factory returns a new Runtime.
+type Factory func() Runtime
 
 // Runtime is a language runtime.
 type Runtime interface {
@@ -17,50 +27,302 @@ type Runtime interface {
 	// since Go must be run as a shim, this is "nodejs", not "golang".
 	Name() string
 
-	// Handler returns the handler name for the runtime in the form "<file>.<func>".
+	// Handler returns the handler name for the runtime in the form "<file>.<func>",
+	// or an empty string when the handler must be configured in function.json.
 	Handler() string
 
 	// Shimmed returns true if the program should be shimmed.
 	Shimmed() bool
 
-	// DefaultFile returns default name for a file with handler
+	// DefaultFile returns default name for a file with handler, or a glob
+	// pattern such as "*.csproj"
 	DefaultFile() string
 }
 
+// BuildContext describes a build of a function, so runtimes may
+// build for other architectures and reuse caches between builds.
+type BuildContext struct {
+	// Dir is the function directory.
+	Dir string
+
+	// Arch is the Lambda architecture, such as "x86_64" or "arm64".
+	Arch string
+
+	// Stage is the active stage, which may be empty.
+	Stage string
+
+	// CacheDir is a directory persisted between builds of the function,
+	// or empty when build caching is not configured.
+	CacheDir string
+
+	// Env is the environment of the build in the form "KEY=value",
+	// added to that of the current process.
+	Env []string
+
+	// Log is the build logger.
+	Log log.Interface
+
+	// Context kills the commands of the build when done, such as on the
+	// build deadline, or never when nil.
+	Context context.Context
+}
+
+// Command returns a command running `name` with `args` in the function
+// directory with the build environment, writing its output to stderr.
+func (c *BuildContext) Command(name string, args ...string) *exec.Cmd {
+	ctx := c.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = c.Dir
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), c.Env...)
+	return cmd
+}
+
+// Cache returns the directory `name` within CacheDir, or an
+// empty string when build caching is not configured.
+func (c *BuildContext) Cache(name string) string {
+	if c.CacheDir == "" {
+		return ""
+	}
+	return filepath.Join(c.CacheDir, name)
+}
+
 // CompiledRuntime is a language runtime requiring compilation.
 type CompiledRuntime interface {
-	// Build performs a build using language-specific conventions
-	// such as "main.go" for the build target.
-	Build(dir string) error
+	// Build performs a build described by `ctx`, using language-specific
+	// conventions such as "main.go" for the build target. Runtimes return
+	// an error for architectures they are unable to build for.
+	Build(ctx *BuildContext) error
 
 	// Clean removes the build artifacts after deployment.
 	Clean(dir string) error
 }
 
-// Register runtime by `name`.
-func Register(name string, runtime Runtime) {
-	runtimes[name] = runtime
+// ContainerRuntime is a runtime able to build inside a container image
+// matching the Lambda execution environment, so native extensions are
+// compiled for Lambda regardless of the host OS.
+type ContainerRuntime interface {
+	// BuildImage returns the default build image.
+	BuildImage() string
+
+	// BuildCommand returns the shell command performing a build
+	// for Lambda architecture `arch` in the function directory.
+	BuildCommand(arch string) string
+}
+
+// PackagedRuntime is a runtime whose build output is zipped
+// in place of the function directory.
+type PackagedRuntime interface {
+	// PackageDir returns the build output directory,
+	// relative to the function directory.
+	PackageDir() string
+}
+
+// CustomRuntime is a runtime built on a Lambda custom runtime, supplying
+// the "bootstrap" executable and its supporting files.
+type CustomRuntime interface {
+	// Files returns the files added to the zip, which are made executable.
+	Files() map[string][]byte
+}
+
+// DependencyRuntime is a runtime resolving dependencies which live outside
+// of the function directory, such as in a monorepo workspace.
+type DependencyRuntime interface {
+	// Dependencies returns the real directories of the dependencies by their path
+	// in the zip, or nil when the function directory should be zipped as-is.
+	Dependencies(dir string) (map[string]string, error)
+}
+
+// TestRuntime is a runtime with a native test command.
+type TestRuntime interface {
+	// TestCommand returns the command running the tests of the function
+	// in `dir`, or nil when the function has no tests.
+	TestCommand(dir string) []string
+}
+
+// AuditLevels are the vulnerability severities, from lowest to highest.
+var AuditLevels = []string{"low", "moderate", "high", "critical"}
+
+// AuditRuntime is a runtime with a dependency vulnerability scanner.
+type AuditRuntime interface {
+	// AuditCommand returns the command scanning the dependencies of the
+	// function in `dir` for vulnerabilities of at least severity `level`,
+	// exiting non-zero on findings, or nil when there is nothing to scan.
+	AuditCommand(dir, level string) []string
+}
+
+// GoOptions configures builds of Go functions.
+type GoOptions struct {
+	// Tags are the build tags.
+	Tags []string `json:"tags"`
+
+	// LDFlags are the linker flags, such as "-s -w".
+	LDFlags string `json:"ldflags"`
+
+	// CGO enables cgo, which is disabled by default.
+	CGO bool `json:"cgo"`
+
+	// TrimPath removes file system paths from the binary.
+	TrimPath bool `json:"trimpath"`
+}
+
+// GoRuntime is a runtime applying GoOptions.
+type GoRuntime interface {
+	// Configure the build of the function in `dir` with `options`.
+	Configure(dir string, options GoOptions)
+}
+
+// Register runtime `factory` by `name`, replacing any
+// runtime previously registered with the same name.
+func Register(name string, factory Factory) {
+	runtimes[name] = factory
 }
 
 // ByName returns the runtime by `name`.
 func ByName(name string) (Runtime, error) {
-	v, ok := runtimes[name]
+	factory, ok := runtimes[name]
 
 	if !ok {
-		return nil, errors.New("invalid runtime")
+		return nil, fmt.Errorf("invalid runtime %q, must be one of %s", name, strings.Join(Names(), ", "))
+	}
+
+	return factory(), nil
+}
+
+// Names returns the sorted names of registered runtimes.
+func Names() (list []string) {
+	for name := range runtimes {
+		list = append(list, name)
 	}
 
-	return v, nil
+	sort.Strings(list)
+	return list
 }
 
-// Detect returns the name of runtime based on DefaultFile. Function iterates over all runtimes and checks if
-// DefaultFile exists in specified directory.
-func Detect(path string) (string, error) {
-	for name, r := range runtimes {
-		if _, err := os.Stat(filepath.Join(path, r.DefaultFile())); err == nil {
-			return name, nil
+// Detection confidence scores.
+const (
+	// ScoreEntry is the score of a DefaultFile match, such as "main.py".
+	ScoreEntry = 50
+
+	// ScoreMarker is the score of a file specific to the runtime's
+	// toolchain, such as "bun.lockb", outranking entry files.
+	ScoreMarker = 70
+
+	// ScoreSupport is added for each supporting file, such as "package.json".
+	ScoreSupport = 10
+)
+
+// Scorer is a runtime scoring its confidence that the function in `dir`
+// is written for it, or zero when it does not match. Runtimes which are
+// not a Scorer score ScoreEntry when their DefaultFile matches.
+type Scorer interface {
+	Score(dir string) int
+}
+
+// Score returns `base` plus ScoreSupport for each of the `support` patterns
+// matching in `dir`, or zero when `pattern` does not match.
+func Score(dir string, base int, pattern string, support ...string) int {
+	if !match(dir, pattern) {
+		return 0
+	}
+
+	score := base
+	for _, s := range support {
+		if match(dir, s) {
+			score += ScoreSupport
+		}
+	}
+
+	return score
+}
+
+// match returns true if glob `pattern` matches a file in `dir`.
+func match(dir, pattern string) bool {
+	matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+	return len(matches) > 0
+}
+
+// Candidate is a runtime detected for a function.
+type Candidate struct {
+	Name  string
+	Score int
+}
+
+// Candidates returns the runtimes matching the function in `path`,
+// ranked by score and then by name.
+func Candidates(path string) []Candidate {
+	var list []Candidate
+
+	for _, name := range Names() {
+		r := runtimes[name]()
+
+		var score int
+		if s, ok := r.(Scorer); ok {
+			score = s.Score(path)
+		} else if match(path, r.DefaultFile()) {
+			score = ScoreEntry
+		}
+
+		if score > 0 {
+			list = append(list, Candidate{Name: name, Score: score})
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Score > list[j].Score
+	})
+
+	return list
+}
+
+// AmbiguousError is returned by Detect when the highest scoring runtimes tie.
+type AmbiguousError struct {
+	Candidates []Candidate
+}
+
+// Error implementation.
+func (e *AmbiguousError) Error() string {
+	var names []string
+	for _, c := range e.Candidates {
+		names = append(names, c.Name)
+	}
+
+	return fmt.Sprintf("ambiguous runtime, detected %s", strings.Join(names, " and "))
+}
+
+// Detect returns the name of the highest scoring runtime of Candidates. When
+// several tie, the first of them in `prefer` is returned, otherwise an
+// *AmbiguousError listing them.
+func Detect(path string, prefer ...string) (string, error) {
+	list := Candidates(path)
+
+	if len(list) == 0 {
+		return "", errors.New("runtime not detected")
+	}
+
+	top := list[:1]
+	for _, c := range list[1:] {
+		if c.Score == list[0].Score {
+			top = append(top, c)
+		}
+	}
+
+	if len(top) == 1 {
+		return top[0].Name, nil
+	}
+
+	for _, name := range prefer {
+		for _, c := range top {
+			if c.Name == name {
+				return name, nil
+			}
 		}
 	}
 
-	return "", errors.New("runtime not detected")
+	return "", &AmbiguousError{Candidates: top}
 }
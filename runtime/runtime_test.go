@@ -0,0 +1,71 @@
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fake is a runtime matching its default file.
+type fake struct {
+	file string
+}
+
+func (f *fake) Name() string        { return "fake" }
+func (f *fake) Handler() string     { return "main.handle" }
+func (f *fake) Shimmed() bool       { return false }
+func (f *fake) DefaultFile() string { return f.file }
+
+// scored is a fake runtime scoring its default file as a marker.
+type scored struct {
+	fake
+}
+
+func (s *scored) Score(dir string) int {
+	return Score(dir, ScoreMarker, s.file, "fake.json")
+}
+
+func TestDetect(t *testing.T) {
+	Register("fake-a", func() Runtime { return &fake{file: "a.fake"} })
+	Register("fake-b", func() Runtime { return &fake{file: "b.fake"} })
+	Register("fake-c", func() Runtime { return &scored{fake{file: "c.fake"}} })
+
+	dir, err := ioutil.TempDir("", "apex-detect")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	touch := func(name string) {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	_, err = Detect(dir)
+	assert.EqualError(t, err, "runtime not detected")
+
+	touch("a.fake")
+	name, err := Detect(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, "fake-a", name)
+
+	touch("b.fake")
+	_, err = Detect(dir)
+	assert.EqualError(t, err, "ambiguous runtime, detected fake-a and fake-b")
+
+	name, err = Detect(dir, "fake-c", "fake-b")
+	assert.Nil(t, err)
+	assert.Equal(t, "fake-b", name)
+
+	touch("c.fake")
+	touch("fake.json")
+	assert.Equal(t, []Candidate{
+		{Name: "fake-c", Score: ScoreMarker + ScoreSupport},
+		{Name: "fake-a", Score: ScoreEntry},
+		{Name: "fake-b", Score: ScoreEntry},
+	}, Candidates(dir))
+
+	name, err = Detect(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, "fake-c", name)
+}
@@ -0,0 +1,82 @@
+package typescript
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apex/apex/runtime"
+	"github.com/apex/apex/runtime/nodejs"
+)
+
+func init() {
+	runtime.Register("typescript", func() runtime.Runtime {
+		return new(Runtime)
+	})
+}
+
+// output directory of the bundle.
+const output = "dist"
+
+// Runtime bundles index.ts and its dependencies with esbuild into a single
+// minified file with a source map, leaving the AWS SDK to the Lambda runtime.
+type Runtime struct{}
+
+func (r *Runtime) Name() string {
+	return "nodejs20.x"
+}
+
+func (r *Runtime) Handler() string {
+	return "index.handler"
+}
+
+func (r *Runtime) Shimmed() bool {
+	return false
+}
+
+func (r *Runtime) DefaultFile() string {
+	return "index.ts"
+}
+
+// Score index.ts, supported by tsconfig.json and package.json.
+func (r *Runtime) Score(dir string) int {
+	return runtime.Score(dir, runtime.ScoreEntry, "index.ts", "tsconfig.json", "package.json")
+}
+
+// Build performs the same build for every architecture, using the "npm"
+// directory of the build cache as the npm cache when configured.
+func (r *Runtime) Build(ctx *runtime.BuildContext) error {
+	cmd := ctx.Command("sh", "-c", r.BuildCommand(ctx.Arch))
+
+	if dir := ctx.Cache("npm"); dir != "" {
+		cmd.Env = append(cmd.Env, "npm_config_cache="+dir)
+	}
+
+	return cmd.Run()
+}
+
+func (r *Runtime) BuildImage() string {
+	return "public.ecr.aws/sam/build-nodejs20.x"
+}
+
+func (r *Runtime) BuildCommand(arch string) string {
+	return fmt.Sprintf("npx --yes esbuild index.ts --bundle --platform=node --target=node20 "+
+		"--minify --sourcemap --external:aws-sdk '--external:@aws-sdk/*' "+
+		"'--banner:js=process.setSourceMapsEnabled(true);' --outfile=%s/index.js", output)
+}
+
+func (r *Runtime) PackageDir() string {
+	return output
+}
+
+func (r *Runtime) Clean(dir string) error {
+	return os.RemoveAll(filepath.Join(dir, output))
+}
+
+func (r *Runtime) TestCommand(dir string) []string {
+	return nodejs.NPMTest(dir)
+}
+
+func (r *Runtime) AuditCommand(dir, level string) []string {
+	return nodejs.NPMAudit(dir, level)
+}
@@ -0,0 +1,48 @@
+package typescript
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apex/apex/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntime_detect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex-typescript")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	touch := func(name string) {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	touch("index.ts")
+	name, err := runtime.Detect(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, "typescript", name)
+
+	touch("index.js")
+	touch("package.json")
+	_, err = runtime.Detect(dir)
+	assert.EqualError(t, err, "ambiguous runtime, detected nodejs and typescript")
+
+	touch("tsconfig.json")
+	assert.Equal(t, []runtime.Candidate{
+		{Name: "typescript", Score: runtime.ScoreEntry + 2*runtime.ScoreSupport},
+		{Name: "nodejs", Score: runtime.ScoreEntry + runtime.ScoreSupport},
+	}, runtime.Candidates(dir))
+}
+
+func TestRuntime_BuildCommand(t *testing.T) {
+	r := new(Runtime)
+	cmd := "npx --yes esbuild index.ts --bundle --platform=node --target=node20 " +
+		"--minify --sourcemap --external:aws-sdk '--external:@aws-sdk/*' " +
+		"'--banner:js=process.setSourceMapsEnabled(true);' --outfile=dist/index.js"
+
+	assert.Equal(t, cmd, r.BuildCommand("x86_64"))
+	assert.Equal(t, cmd, r.BuildCommand("arm64"))
+	assert.Equal(t, "dist", r.PackageDir())
+}
@@ -0,0 +1,265 @@
+// Package server serves the operations of a project over an HTTP API
+// authenticated with a bearer token, so platforms and ChatOps bots may
+// deploy, invoke, roll back and query the logs of functions without
+// shelling out to apex.
+//
+// Routes:
+//
+//	GET  /functions                   list functions
+//	POST /functions/<name>/deploy     deploy a function
+//	POST /functions/<name>/invoke     invoke with the request body as the payload
+//	POST /functions/<name>/rollback   roll back, to ?version=<n> when given
+//	GET  /functions/<name>/logs       run a Logs Insights ?query=<q> over ?since=<d>
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/apex/function"
+	"github.com/apex/apex/project"
+	"github.com/apex/log"
+)
+
+// Server is an http.Handler serving the operations of Project.
+type Server struct {
+	Project *project.Project
+	Log     log.Interface
+
+	// Token is the bearer token required of requests,
+	// all requests are refused when empty.
+	Token string
+
+	// mu serializes deploys and rollbacks, which change the state of
+	// functions, with invocations and log queries reading it.
+	mu sync.RWMutex
+}
+
+// Error is the JSON body of error responses, with the details
+// of function errors when an invocation fails.
+type Error struct {
+	Error     string                 `json:"error"`
+	Type      string                 `json:"type,omitempty"`
+	Code      string                 `json:"code,omitempty"`
+	Retryable bool                   `json:"retryable,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Function is a function of the project.
+type Function struct {
+	Name    string `json:"name"`
+	Runtime string `json:"runtime"`
+}
+
+// Release is the version of a function deployed or rolled back to.
+type Release struct {
+	Function string `json:"function"`
+	Version  string `json:"version,omitempty"`
+}
+
+// ServeHTTP implementation.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		s.error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if len(parts) == 1 && parts[0] == "functions" {
+		s.route(w, r, http.MethodGet, s.list)
+		return
+	}
+
+	if len(parts) != 3 || parts[0] != "functions" {
+		s.error(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	fn, err := s.Project.FunctionByName(parts[1])
+	if err != nil {
+		s.error(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	switch parts[2] {
+	case "deploy":
+		s.route(w, r, http.MethodPost, func(w http.ResponseWriter, r *http.Request) { s.deploy(w, r, fn) })
+	case "invoke":
+		s.route(w, r, http.MethodPost, func(w http.ResponseWriter, r *http.Request) { s.invoke(w, r, fn) })
+	case "rollback":
+		s.route(w, r, http.MethodPost, func(w http.ResponseWriter, r *http.Request) { s.rollback(w, r, fn) })
+	case "logs":
+		s.route(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) { s.logs(w, r, fn) })
+	default:
+		s.error(w, http.StatusNotFound, "not found")
+	}
+}
+
+// authorized returns true if `r` bears the token.
+func (s *Server) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return s.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+// route `r` to `h` when its method is `method`.
+func (s *Server) route(w http.ResponseWriter, r *http.Request, method string, h http.HandlerFunc) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		s.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	h(w, r)
+}
+
+// list functions.
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	list := []Function{}
+	for _, fn := range s.Project.Functions {
+		list = append(list, Function{Name: fn.Name, Runtime: fn.Runtime})
+	}
+
+	s.json(w, http.StatusOK, list)
+}
+
+// deploy `fn`.
+func (s *Server) deploy(w http.ResponseWriter, r *http.Request, fn *function.Function) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Log.Infof("deploying %s", fn.Name)
+
+	if err := s.Project.DeployAndClean([]string{fn.Name}); err != nil {
+		s.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	version, _ := fn.Published()
+	s.json(w, http.StatusOK, Release{Function: fn.Name, Version: version})
+}
+
+// invoke `fn` with the request body, responding with its reply. Function
+// errors respond with 502 Bad Gateway and the details of the error.
+func (s *Server) invoke(w http.ResponseWriter, r *http.Request, fn *function.Function) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	payload, err := ioutil.ReadAll(io.LimitReader(r.Body, function.MaxPayloadSize+1))
+	if err != nil {
+		s.error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts := function.InvokeOptions{
+		Qualifier: r.URL.Query().Get("qualifier"),
+		NoLogs:    true,
+	}
+
+	reply, _, err := fn.InvokeWithOptions(payload, opts)
+
+	if e, ok := err.(*function.InvokeError); ok {
+		s.json(w, http.StatusBadGateway, Error{
+			Error:     e.Message,
+			Type:      e.Type,
+			Code:      e.Code,
+			Retryable: e.Retryable,
+			Metadata:  e.Metadata,
+		})
+		return
+	}
+
+	if _, ok := err.(*function.ErrTooLarge); ok {
+		s.error(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+
+	if err != nil {
+		s.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	io.Copy(w, reply)
+}
+
+// rollback `fn` to the ?version, or to the previous version.
+func (s *Server) rollback(w http.ResponseWriter, r *http.Request, fn *function.Function) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version := r.URL.Query().Get("version")
+	s.Log.Infof("rolling back %s", fn.Name)
+
+	err := s.Project.Rollback(fn.Name, version)
+
+	if err == function.ErrUnchanged {
+		s.error(w, http.StatusConflict, fmt.Sprintf("version %s is already the current version", version))
+		return
+	}
+
+	if err != nil {
+		s.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if version == "" {
+		version, _ = fn.Output(function.OutputVersion)
+	}
+
+	s.json(w, http.StatusOK, Release{Function: fn.Name, Version: version})
+}
+
+// logs runs the ?query of `fn`, defaulting to "recent", over the ?since
+// duration, defaulting to an hour, responding with the result rows.
+func (s *Server) logs(w http.ResponseWriter, r *http.Request, fn *function.Function) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	q := r.URL.Query().Get("query")
+	if q == "" {
+		q = "recent"
+	}
+
+	since := time.Hour
+
+	if v := r.URL.Query().Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			s.error(w, http.StatusBadRequest, fmt.Sprintf("invalid since %q", v))
+			return
+		}
+		since = d
+	}
+
+	rows, err := fn.Query(q, since)
+	if err != nil {
+		s.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.json(w, http.StatusOK, rows)
+}
+
+// error responds with `status` and message `msg`.
+func (s *Server) error(w http.ResponseWriter, status int, msg string) {
+	s.json(w, status, Error{Error: msg})
+}
+
+// json responds with `status` and `v` encoded as JSON.
+func (s *Server) json(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.Log.Warnf("error writing response: %s", err)
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apex/apex/function"
+	"github.com/apex/apex/mock"
+	"github.com/apex/apex/project"
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// request `s` with `method` and `path`, and the body `body`.
+func request(s *Server, token, method, path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestServer(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	serviceMock := mock_lambdaiface.NewMockLambdaAPI(mockCtrl)
+
+	fn := &function.Function{
+		Config:       function.Config{Runtime: "nodejs"},
+		Name:         "api",
+		FunctionName: "app_api",
+		Service:      serviceMock,
+		Log:          log.Log,
+	}
+
+	s := &Server{
+		Project: &project.Project{Functions: []*function.Function{fn}, Log: log.Log},
+		Log:     log.Log,
+		Token:   "secret",
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request(s, "", "GET", "/functions", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, request(s, "wrong", "GET", "/functions", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, request(&Server{Log: log.Log}, "", "GET", "/functions", "").Code)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		w := request(s, "secret", "GET", "/functions", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `[{"name":"api","runtime":"nodejs"}]`+"\n", w.Body.String())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, request(s, "secret", "POST", "/functions/worker/deploy", "").Code)
+		assert.Equal(t, http.StatusNotFound, request(s, "secret", "POST", "/functions/api/publish", "").Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		w := request(s, "secret", "GET", "/functions/api/invoke", "")
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		assert.Equal(t, "POST", w.Header().Get("Allow"))
+	})
+
+	t.Run("invoke", func(t *testing.T) {
+		serviceMock.EXPECT().Invoke(gomock.Any()).Do(func(in *lambda.InvokeInput) {
+			assert.Equal(t, `{"name":"tj"}`, string(in.Payload))
+			assert.Equal(t, "3", aws.StringValue(in.Qualifier))
+		}).Return(&lambda.InvokeOutput{Payload: []byte(`"hello tj"`)}, nil)
+
+		w := request(s, "secret", "POST", "/functions/api/invoke?qualifier=3", `{"name":"tj"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"hello tj"`, w.Body.String())
+	})
+
+	t.Run("invoke error", func(t *testing.T) {
+		serviceMock.EXPECT().Invoke(gomock.Any()).Return(&lambda.InvokeOutput{
+			FunctionError: aws.String("Handled"),
+			Payload:       []byte(`{"errorType": "TypeError", "errorMessage": "boom"}`),
+		}, nil)
+
+		w := request(s, "secret", "POST", "/functions/api/invoke", `{}`)
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		assert.Equal(t, `{"error":"boom","type":"TypeError"}`+"\n", w.Body.String())
+	})
+
+	t.Run("invoke during deploy", func(t *testing.T) {
+		serviceMock.EXPECT().Invoke(gomock.Any()).Return(&lambda.InvokeOutput{Payload: []byte(`null`)}, nil)
+
+		s.mu.Lock()
+		done := make(chan *httptest.ResponseRecorder)
+		go func() { done <- request(s, "secret", "POST", "/functions/api/invoke", `{}`) }()
+
+		select {
+		case <-done:
+			t.Fatal("invoked during deploy")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		s.mu.Unlock()
+		assert.Equal(t, http.StatusOK, (<-done).Code)
+	})
+
+	t.Run("logs", func(t *testing.T) {
+		w := request(s, "secret", "GET", "/functions/api/logs?since=yesterday", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, `{"error":"invalid since \"yesterday\""}`+"\n", w.Body.String())
+	})
+}
@@ -0,0 +1,201 @@
+// Package shadow invokes the current alias of a function and a candidate
+// version with the same events, comparing their replies, latency and
+// memory usage, to report on the candidate before it is promoted.
+//
+// Both are invoked with production events, so functions with side
+// effects should only be compared with events safe to process twice.
+package shadow
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/apex/apex/function"
+	"github.com/apex/apex/logs"
+	"github.com/apex/apex/record"
+)
+
+// Sample is the outcome of an invocation.
+type Sample struct {
+	// Reply of the function, or the Error message when it failed.
+	Reply []byte
+	Error string
+
+	// Duration reported by Lambda, or the round-trip
+	// duration when the report is unavailable.
+	Duration time.Duration
+
+	// Memory is the maximum memory used in megabytes,
+	// zero when the report is unavailable.
+	Memory int
+}
+
+// Comparison of the current and candidate outcomes of an event.
+type Comparison struct {
+	Event     []byte
+	Current   Sample
+	Candidate Sample
+}
+
+// Match returns true if the candidate replied or failed as the current
+// alias did, comparing JSON replies regardless of formatting and key order.
+func (c *Comparison) Match() bool {
+	return c.Current.Error == c.Candidate.Error && record.Equal(c.Current.Reply, c.Candidate.Reply)
+}
+
+// Stats summarizes the samples of the current alias or the candidate.
+type Stats struct {
+	P50       time.Duration
+	P99       time.Duration
+	MaxMemory int
+	Errors    int
+}
+
+// Report is the comparison of a candidate version with the current alias.
+type Report struct {
+	Function    string
+	Current     string
+	Candidate   string
+	Comparisons []*Comparison
+}
+
+// Mismatches returns the comparisons whose replies differ.
+func (r *Report) Mismatches() (v []*Comparison) {
+	for _, c := range r.Comparisons {
+		if !c.Match() {
+			v = append(v, c)
+		}
+	}
+	return
+}
+
+// CurrentStats returns the stats of the current alias.
+func (r *Report) CurrentStats() Stats {
+	var samples []Sample
+	for _, c := range r.Comparisons {
+		samples = append(samples, c.Current)
+	}
+	return stats(samples)
+}
+
+// CandidateStats returns the stats of the candidate.
+func (r *Report) CandidateStats() Stats {
+	var samples []Sample
+	for _, c := range r.Comparisons {
+		samples = append(samples, c.Candidate)
+	}
+	return stats(samples)
+}
+
+// Compare invokes the alias of `fn` and version `candidate` with each
+// of `events` in turn. Function errors are part of the comparisons,
+// however other errors abort the comparison.
+func Compare(fn *function.Function, events [][]byte, candidate string) (*Report, error) {
+	r := &Report{
+		Function:  fn.Name,
+		Current:   fn.AliasName(),
+		Candidate: candidate,
+	}
+
+	for _, e := range events {
+		current, err := invoke(fn, e, r.Current)
+		if err != nil {
+			return nil, err
+		}
+
+		cand, err := invoke(fn, e, candidate)
+		if err != nil {
+			return nil, err
+		}
+
+		r.Comparisons = append(r.Comparisons, &Comparison{
+			Event:     e,
+			Current:   current,
+			Candidate: cand,
+		})
+	}
+
+	return r, nil
+}
+
+// invoke `qualifier` of `fn` with `event`, reading
+// the usage from the REPORT line of the log tail.
+func invoke(fn *function.Function, event []byte, qualifier string) (Sample, error) {
+	var s Sample
+
+	start := time.Now()
+
+	reply, tail, err := fn.InvokeWithOptions(event, function.InvokeOptions{
+		Qualifier: qualifier,
+	})
+
+	s.Duration = time.Since(start)
+
+	switch e := err.(type) {
+	case nil:
+	case *function.InvokeError:
+		s.Error = e.Message
+		return s, nil
+	default:
+		return s, err
+	}
+
+	if s.Reply, err = ioutil.ReadAll(reply); err != nil {
+		return s, err
+	}
+
+	b, err := ioutil.ReadAll(tail)
+	if err != nil {
+		return s, err
+	}
+
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if l := logs.Parse(string(line)); l.Kind == logs.Report {
+			u := logs.ParseUsage(l.Message)
+			s.Duration = u.Duration
+			s.Memory = u.MaxMemoryUsed
+		}
+	}
+
+	return s, nil
+}
+
+// stats of `samples`.
+func stats(samples []Sample) (s Stats) {
+	if len(samples) == 0 {
+		return
+	}
+
+	var durations []time.Duration
+
+	for _, sample := range samples {
+		durations = append(durations, sample.Duration)
+
+		if sample.Error != "" {
+			s.Errors++
+		}
+
+		if sample.Memory > s.MaxMemory {
+			s.MaxMemory = sample.Memory
+		}
+	}
+
+	sort.Slice(durations, func(a, b int) bool {
+		return durations[a] < durations[b]
+	})
+
+	s.P50 = percentile(durations, 50)
+	s.P99 = percentile(durations, 99)
+	return
+}
+
+// percentile `p` of sorted `durations`, using the nearest rank.
+func percentile(durations []time.Duration, p int) time.Duration {
+	i := (len(durations)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return durations[i]
+}
@@ -0,0 +1,81 @@
+package shadow
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/apex/apex/function"
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type versions struct {
+	lambdaiface.LambdaAPI
+}
+
+func (s *versions) Invoke(in *lambda.InvokeInput) (*lambda.InvokeOutput, error) {
+	current := *in.Qualifier == "current"
+	event := string(in.Payload)
+
+	if event == `"fail"` && !current {
+		return &lambda.InvokeOutput{
+			FunctionError: aws.String("Unhandled"),
+			Payload:       []byte(`{"errorMessage":"boom"}`),
+		}, nil
+	}
+
+	report := "REPORT RequestId: 6f1c0c1e-1a0a-4f1e-9c3a-9e0d7f6c7e9b\tDuration: 10.00 ms\tBilled Duration: 10 ms\tMemory Size: 128 MB\tMax Memory Used: 60 MB\t\n"
+	reply := `{"ok": true}`
+
+	if !current {
+		report = "REPORT RequestId: 6f1c0c1e-1a0a-4f1e-9c3a-9e0d7f6c7e9b\tDuration: 20.00 ms\tBilled Duration: 20 ms\tMemory Size: 128 MB\tMax Memory Used: 90 MB\t\n"
+		reply = `{"ok":true}`
+	}
+
+	return &lambda.InvokeOutput{
+		Payload:   []byte(reply),
+		LogResult: aws.String(base64.StdEncoding.EncodeToString([]byte(report))),
+	}, nil
+}
+
+func TestCompare(t *testing.T) {
+	fn := &function.Function{
+		Name:         "foo",
+		FunctionName: "app_foo",
+		Service:      &versions{},
+		Log:          log.Log,
+	}
+
+	r, err := Compare(fn, [][]byte{[]byte(`{}`), []byte(`"fail"`), []byte(`{}`)}, "8")
+	assert.Nil(t, err)
+	assert.Equal(t, "current", r.Current)
+	assert.Equal(t, "8", r.Candidate)
+	assert.Len(t, r.Comparisons, 3)
+
+	mismatches := r.Mismatches()
+	assert.Len(t, mismatches, 1)
+	assert.Equal(t, `"fail"`, string(mismatches[0].Event))
+	assert.Equal(t, "boom", mismatches[0].Candidate.Error)
+
+	assert.Equal(t, Stats{P50: 10 * time.Millisecond, P99: 10 * time.Millisecond, MaxMemory: 60}, r.CurrentStats())
+
+	s := r.CandidateStats()
+	assert.Equal(t, 20*time.Millisecond, s.P99)
+	assert.Equal(t, 90, s.MaxMemory)
+	assert.Equal(t, 1, s.Errors)
+}
+
+func TestPercentile(t *testing.T) {
+	var d []time.Duration
+	for i := 1; i <= 200; i++ {
+		d = append(d, time.Duration(i))
+	}
+
+	assert.Equal(t, time.Duration(100), percentile(d, 50))
+	assert.Equal(t, time.Duration(198), percentile(d, 99))
+	assert.Equal(t, time.Duration(1), percentile(d[:1], 99))
+}
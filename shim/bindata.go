@@ -90,7 +90,7 @@ func bylineJs() (*asset, error) {
 	return a, nil
 }
 
-var _indexJs = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x74\x92\x41\xef\xda\x30\x0c\xc5\xef\xf9\x14\x16\xd2\xd4\x96\xb1\x54\xbb\x32\x71\xda\x65\xdb\x01\x0e\x3b\x6e\xd3\x14\x1a\x03\x91\x4a\xd2\x25\x29\x14\x21\xbe\xfb\xec\x24\x68\x45\xda\xff\x54\xf5\xf9\xe7\xe7\x67\xb7\xe2\xa2\x3c\x74\x27\xd3\x6b\xd8\x80\xc7\x3f\xa3\xf1\x58\x57\x49\xf8\x3d\x78\xd7\x61\x08\x55\x93\xa0\xfd\xad\x37\x16\xe7\x94\x6c\xb3\x46\x80\x68\x97\x4b\x01\x4b\xf8\xec\x6c\xc4\x29\xc2\xc1\x79\x88\x27\x4c\x2c\x86\x28\xa9\xd6\x8a\x3c\x2b\x4e\xff\xe8\x34\xb7\x8c\x49\x3d\x7b\x63\x95\xbf\xc1\xd7\x76\x37\x6b\x61\x80\xe6\xa6\x50\x32\x0c\xea\x6a\x79\xf6\x59\x19\x5b\xad\xe0\x0e\x21\x6a\xe3\xd6\xf0\xa3\x1a\xcc\x80\xa4\x3c\x9f\xc5\x57\x52\x1d\xbd\xff\x05\x0f\x8a\xc9\x9a\x74\xd4\x4f\x8a\xf3\x04\x1d\x46\xdb\x45\x43\x0a\x09\xcd\x5d\x00\x74\xce\x06\xd7\xa3\x4c\x40\xe1\xd6\xf0\x2e\x10\xcb\x08\x11\x4f\x5f\x9c\x4c\xac\x3f\x36\xe2\xd5\x97\xc4\xb9\x6d\xe7\x34\xfe\xd7\x97\xb8\x62\x9b\x90\x37\x7c\xcb\xa1\xb6\x78\xe5\x43\x7f\xd0\xd8\x9b\xb3\x89\xa8\xe1\xdb\xf7\xdd\x96\x37\x77\xe3\xfc\xb8\xf4\x46\x87\xca\x5f\xa5\x4e\x99\x32\x42\x4e\x0c\x72\x40\xad\xa2\x9a\x07\x64\x34\x05\xe4\xfe\x73\x38\x52\x3f\x7b\xcb\x41\xf9\x80\xb9\xca\xe9\xe3\x24\xb5\x23\x53\x22\xf2\x0a\x2b\x86\xe5\x45\xf5\x23\xbe\x44\xfd\xa2\xac\xee\x11\xf0\x82\x36\x86\x12\x0d\xa7\xc1\x79\x7a\x3b\xe5\xda\x66\x76\x75\xc6\xf8\x04\xe9\xb7\x69\xe0\x9e\x67\xf1\xd7\xce\x92\x28\x87\xe1\x45\x8c\x95\x57\x4f\xdb\xd7\x29\x60\x88\xde\xd8\xa3\x39\xdc\x6a\x6e\x02\x58\x24\xaf\xc5\x3a\x8f\x5e\x65\xad\xb8\x90\xfa\xf4\x03\xfa\x0f\xde\x57\x3f\x6d\xd5\x7c\x12\x8f\xbf\x01\x00\x00\xff\xff\x77\x79\xf1\xb5\xfe\x02\x00\x00")
+var _indexJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\x03\x8d\x57\xdb\x6e\x1b\x37\x10\x7d\xd7\x57\x4c\x0c\x34\xda\x8d\xe5\xb5\x9b\xfa\xc9\xa8\x0b\x24\x8d\x93\xb8\xc8\x0d\x71\x8a\x14\x70\x8d\x98\xda\xa5\x24\xc6\x2b\xae\x42\xae\x2c\xab\x86\xff\xbd\x67\x86\xdc\x8b\x25\x15\xe8\x8b\x25\x71\x66\x0e\x67\xce\x5c\x38\x1e\xdc\x2a\x47\xf9\xcc\x94\x05\x9d\x92\xd3\x3f\x96\xc6\xe9\x64\x28\x07\xdf\x16\xae\xca\xb5\xf7\xc3\x74\xc0\x4a\xe3\x75\x69\xac\xee\x6b\x65\x87\xe1\x0c\x0a\x83\xc3\x67\xcf\x06\xf4\x8c\x2e\x66\x66\x4e\xd5\xa2\x36\x95\xf5\x23\x5a\x39\x53\xd7\xda\x52\x5d\x91\x87\x20\xfb\xee\x2b\x0b\x1c\x52\x0b\x7d\x97\x41\x9d\x2d\xe8\x80\x26\x4e\xcd\x8d\x9d\x9e\xd0\x1e\xa3\xed\xd1\xa4\x72\x64\xf5\x8a\x7f\x1c\x14\xba\x34\x73\x53\xeb\x82\xfe\xb8\xf8\xf8\x61\x44\x10\xed\x95\xda\x4e\xeb\x99\xe8\x09\x02\x89\x8c\x16\x4e\x4f\xcc\x1d\x34\x71\x83\xa9\x3d\x1d\x1f\x8c\xd7\xb5\xa6\xb1\x99\x1e\x68\x5b\x18\x65\x29\x18\xc6\x5b\x9d\xf6\xb5\x72\xf5\x09\x7f\x59\xa8\x15\xbc\x9c\xe9\xc8\x44\x0c\x9c\x56\x33\x38\x6f\x6a\xd2\x77\xc0\x1b\xd1\x44\x19\xf8\x34\x8d\x77\x2e\x18\xd4\x4e\xc9\xd8\xdb\x2a\x57\x31\x62\xa7\x80\xe2\x00\x85\xdb\xd8\x8a\x15\x04\xb7\xb2\xb5\x42\x3c\x2e\x5e\xee\x67\xcb\xba\xa8\x56\xf6\x8b\x99\xeb\x6a\x09\x27\xe6\xa6\x2c\x8d\xd7\xd0\x2b\x3c\xf3\xb5\x52\xb8\x97\x89\xd8\xf2\x2a\x5e\x0f\x9d\x89\xb1\xc6\xcf\x76\x39\x42\xe0\xf9\xe2\xfc\xcd\x97\xb3\xcf\xef\xa1\x7e\x38\x90\x04\xc6\xb4\x20\x83\xf7\x1d\xe5\x43\x49\xe0\xa8\x63\x63\xa2\x4a\xaf\x47\xdb\x0e\x3e\x3f\x3a\x3a\xa2\x87\xc1\xa0\x76\x6b\xba\x1f\x10\x09\xe2\xad\x76\xce\x14\xda\x3f\xae\x8a\x36\xd7\x28\x0c\x92\x20\x12\xd6\xbe\x81\x87\x9d\x49\xda\xf8\x73\x79\x73\x05\xf3\xf6\x1c\x3f\x07\x0f\x84\x38\xf2\x19\x25\x38\x4b\xe9\xfe\xa1\x2d\xaf\x4f\x5b\xa1\x0a\xb3\xfa\x0e\xe9\xe6\xac\x17\x59\x17\x6e\x43\xcb\x29\x03\xf0\x81\x85\xda\xf9\x2b\xfc\x3e\x6a\xf1\x7e\x7f\x94\x6e\xf6\x74\x6c\xac\x42\x84\xe7\x87\x1f\xfb\x50\x50\x90\x2f\xbe\xae\x16\x8b\x00\x2a\x3c\x0d\x06\x93\xa5\xcd\xc5\x0f\xa9\xa1\x24\x15\x6e\x58\x1f\x2a\x92\xb6\x2c\x08\x40\xcb\x1c\x05\x00\xa6\x59\x81\x80\x54\x98\xea\x84\x2e\x87\x0b\xb3\x60\xfe\x9b\xcf\xe8\x4b\x06\x39\x82\xbf\x1a\x89\xb2\xb6\xb7\x27\xf4\x71\xfc\x5d\xe7\x75\xa6\xbc\x37\x53\x9b\xdc\x3f\x74\xba\x10\x03\x96\x5e\x7c\x3a\xfb\xeb\xdb\xc5\xdb\xf3\xf7\xdf\x5e\x7f\x7e\xf1\xfe\xfc\xc3\x9b\x93\x86\xe2\x2c\xa6\x9b\x1e\x38\x21\xf8\x13\x7d\xcc\x2a\x38\x86\x6b\x2a\x87\x9b\x9b\x48\x84\xf4\xe0\x24\xb8\xf5\x55\xa9\x33\x51\x89\x9a\x27\xf4\x93\x87\x36\x2b\x89\x4e\xeb\x04\xaa\x3d\xf9\x79\x17\x3e\x04\x7d\xf8\xbc\x2a\x74\xc4\x37\x13\x4a\x1a\x4a\xd3\xc7\x48\x47\xe9\x4e\x0f\x20\x8a\x0e\x08\x4c\x8b\xf2\xa4\x89\x34\xd6\x71\xba\xc3\x2f\x92\x06\x8e\xc3\xad\x4d\x3a\xcb\x75\x81\x46\xa0\xfd\x1e\x66\xcc\x66\x1b\x8d\xd3\xaa\x48\x24\x24\x24\x06\xfd\xd0\x8b\x67\xee\xa7\x31\x1c\x19\xa5\xf5\x1d\x32\x1f\x6b\xef\x12\xb2\xcc\x14\x57\x9d\x9b\x10\xa7\x00\xab\x97\xce\xfe\x07\xbb\x3c\x8d\x20\xd0\xdc\xe0\x4b\x7b\x63\xd1\x83\xfd\x72\x97\xd8\x03\x6c\xf0\x14\x03\x52\x63\xca\xed\xba\x11\x97\x65\x45\x65\x75\x12\x6e\x60\x47\x83\xed\xad\x2a\x97\x3a\x06\xd7\x35\xd7\x19\x6b\x51\x35\xe9\x5c\xb8\x86\xf2\x75\x46\x5f\xd6\x0b\x4c\x55\x01\xf1\xa4\x9c\x86\xc2\xa2\x72\x3c\x93\x15\x7e\x5b\xb6\x7d\x81\x89\x1e\xec\x57\xa6\x9e\xf1\xd0\x32\x0e\xae\x61\xe6\x95\x5e\xa6\x33\x46\x30\xb3\x5b\xf0\x0c\xe0\x91\x36\x07\xf7\x6a\xaa\x47\x6c\x0c\x94\x77\x6a\x3e\x2e\x14\xc6\x56\xb9\x8e\xe8\x5e\xd4\xac\x9a\x63\x18\x45\x65\xdc\x55\xa0\x71\x54\x7e\xc3\x5e\x06\x7f\x62\x97\xb6\x6d\xd8\x85\x2a\x8d\x26\xa4\x73\xc8\x72\xfe\x2a\x38\xd4\x66\xa0\x15\xc4\x79\x86\xef\x48\x1e\x9e\x9e\xc0\x45\xc2\x8e\x23\xe1\x0e\xc4\x9a\xc9\x3a\xd9\xc2\x61\x0a\x71\x90\xb1\x97\x30\x1c\xb6\x2c\x0c\xa5\x64\xe4\x0e\xc8\x7b\x1c\xbf\x86\xdd\xce\x81\x2d\xb4\x5d\xa3\xcc\x30\x36\xaf\x37\x83\x92\xba\x0d\xb2\x10\x56\x3b\x53\x8d\x10\x1a\x01\xd3\x38\x5b\x9a\x5a\x40\x1d\x64\x7d\xd3\x5d\xf5\x12\x6a\xe5\xa1\xe7\xe2\x67\x94\xba\xbc\x0f\xf1\xcd\x6d\xd8\xc7\x7c\x74\xd5\x9c\xae\xc1\x87\x56\xf3\x2d\x1f\xa5\x43\x82\x0c\xcd\x61\x3b\xfa\x37\x67\xd0\x93\x53\x30\x15\x5e\xe2\x61\xe3\x71\xd8\x23\xa2\x79\x2a\x63\xa3\x50\xb5\xea\x8f\x0d\x56\x88\x7d\x86\xf8\x6d\xc8\xcd\x42\x39\xaf\x83\x28\x44\xf7\x10\x3e\x02\xf7\x12\x58\x4c\xed\x78\x39\x41\x86\x5e\x2e\x27\x13\xed\x32\x55\x96\x55\xce\x23\x66\xc0\x83\x98\xef\xdc\x79\x65\x3e\x43\x03\xc6\x3b\x1f\xd9\xa3\x73\x91\xb7\xe4\x12\x87\x18\x44\xac\x75\x25\x58\x84\x8d\xc1\x94\x9a\x12\x08\xb2\x10\x22\xfd\x76\x4a\xc7\x4d\x98\xc1\x15\x6f\xfe\xe1\x6a\x61\x1d\x26\xed\xcf\x73\x5b\xff\xf2\xfc\xe5\x59\x33\xf2\x02\x6b\x3d\x84\x5f\xe9\x18\xb3\x89\xad\x52\x1a\xc3\xe2\x66\x27\x09\x6c\xe0\x4b\x93\xeb\xe4\x78\xd4\x19\x64\x75\x75\x21\xf5\x9b\xa4\x69\x03\x1f\x42\xe9\xe9\x37\xca\x81\xc1\xcd\xb1\xf0\x15\x4b\x9c\xde\x55\x10\x61\x3c\xf0\x9c\xda\xda\x51\x36\x8b\x83\x17\x41\xdd\x75\x25\xb3\x20\xbb\xe0\x29\x6d\xb7\x98\x30\xf9\x7f\x0b\x27\x36\x59\x33\x99\x8d\xcd\xc2\x55\x82\xbe\x4f\xc3\xbf\xc3\x0e\xd2\x95\x41\x55\xac\xbb\x3c\x72\x41\x8b\x6a\x1a\xc5\x33\xae\xfd\x8d\x32\x39\x66\x21\x0b\x02\x74\x9b\x2e\x86\x8a\x29\x1a\x91\xe4\x6e\xcb\x8b\x8d\x72\x61\x90\x91\xb8\x70\x95\xf6\x19\x7e\xe3\x54\xae\x27\xcb\xb2\x5d\xba\x50\x54\x65\xe5\x39\x66\x41\x23\xcf\x14\xab\x7a\xf7\x2e\x18\xb6\x40\xb4\xe7\xae\xb1\xc2\x13\x53\xb6\xd7\x98\x91\x26\x3f\x5c\xee\x71\x41\xec\x57\xbc\x14\x7b\x6f\xc9\xa9\xdd\x52\x3f\x0e\x0c\x77\xc8\xb3\xe8\x75\x1d\x57\xc3\xa4\x67\xbd\xf9\x86\x13\xb6\x94\x26\x8d\x1b\x1b\x65\x9a\x2d\x2d\x16\x76\x80\xf1\x0b\xdb\xbc\xb7\x0d\x25\x6f\xe1\x38\x1a\x49\xdf\x6a\xdb\xfa\xae\xef\xe4\x5d\xc8\x66\x41\x76\xda\xdb\x58\x58\x6d\xd4\xac\x82\xdd\xe8\x91\x73\x7a\xfa\x34\xe0\x64\xbe\x5a\xba\x1c\x86\x5c\x47\xf2\x2f\xc8\x4a\xb9\xf9\x66\x29\x45\x90\xcc\x2f\xf3\x5c\xeb\x10\xac\x94\x4f\x23\xc8\x51\x16\x63\xbc\x41\x5f\xb1\xa1\xfb\xd7\x95\x3b\x9b\x2f\xea\xf5\x19\x5f\xf0\xae\xaa\x16\xdd\x66\x18\x2a\xca\x70\x3d\xc5\x16\x0c\x0b\xe8\xfe\xbe\xd4\x4a\x37\x7f\x79\x53\x0c\xd8\x6c\x14\x2a\x27\xb8\xb4\x67\x8a\xbd\x13\x40\x84\x2d\x70\x4f\xa2\xc0\x41\x08\x37\x9c\x45\x4b\x9c\x36\x18\xb1\x7d\xff\x05\xf4\x78\x8c\x55\xd9\x0d\x00\x00")
 
 func indexJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -105,7 +105,7 @@ func indexJs() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "index.js", size: 766, mode: os.FileMode(420), modTime: time.Unix(1450569510, 0)}
+	info := bindataFileInfo{name: "index.js", size: 3545, mode: os.FileMode(420), modTime: time.Unix(1792146353, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -3,47 +3,161 @@ var child = require('child_process')
 var byline = require('./byline')
 
 /**
- * Context for the request.
+ * Shim options, written to shim.json by apex.
+ *
+ *  - framing: "line" for newline-delimited JSON, or "length" for
+ *    JSON prefixed by its 4-byte big-endian length
+ *  - restart: respawn the child process when it exits, failing
+ *    pending invocations, rather than exiting the container
+ *  - shutdownTimeout: milliseconds to wait for the child process
+ *    to finish pending invocations on SIGTERM
  */
 
-var ctx
+var options = { framing: 'line', restart: false, shutdownTimeout: 2000 }
+
+try {
+  var overrides = require('./shim.json')
+  for (var k in overrides) options[k] = overrides[k]
+} catch (err) {}
+
+/**
+ * Pending invocation contexts by id.
+ */
+
+var pending = {}
+var nextID = 0
 
 /**
  * Child process for binary I/O.
  */
 
-var proc = child.spawn('./main', { stdio: ['pipe', 'pipe', process.stderr] })
+var proc
+var stopping = false
 
-proc.on('error', function(err){
-  console.error('error: %s', err)
-  process.exit(1)
-})
+function spawn() {
+  proc = child.spawn('./main', {
+    stdio: ['pipe', 'pipe', process.stderr],
+    env: Object.assign({}, process.env, { APEX_SHIM_FRAMING: options.framing })
+  })
 
-proc.on('exit', function(code){
-  console.error('exit: %s', code)
-  process.exit(1)
-})
+  proc.on('error', function(err){
+    console.error('error: %s', err)
+    process.exit(1)
+  })
+
+  proc.on('exit', function(code){
+    if (stopping) process.exit(0)
+    console.error('exit: %s', code)
+    if (!options.restart) process.exit(1)
+    fail('child process exited: ' + code)
+    spawn()
+  })
+
+  read(proc.stdout, function(msg){
+    var ctx = pending[msg.id]
+    if (!ctx) return console.error('error: response to unknown invocation %s', msg.id)
+    delete pending[msg.id]
+    ctx.done(error(msg), msg.value)
+  })
+}
+
+/**
+ * Error of response `msg`. Typed errors are reported as an
+ * ApexError with their details JSON-encoded in the message,
+ * as Lambda only reports the name, message and stack of errors.
+ */
+
+function error(msg) {
+  if (!msg.errorDetails) return msg.error
+  var err = new Error(JSON.stringify(msg.errorDetails))
+  err.name = 'ApexError'
+  return err
+}
+
+/**
+ * Fail pending invocations with `reason`.
+ */
+
+function fail(reason) {
+  for (var id in pending) {
+    pending[id].fail(reason)
+    delete pending[id]
+  }
+}
+
+/**
+ * Read framed JSON messages from `stream`.
+ */
+
+function read(stream, fn) {
+  if (options.framing !== 'length') {
+    byline(stream).on('data', function(line){
+      fn(JSON.parse(line))
+    })
+    return
+  }
+
+  var buf = Buffer.alloc(0)
+
+  stream.on('data', function(chunk){
+    buf = Buffer.concat([buf, chunk])
+
+    while (buf.length >= 4) {
+      var size = buf.readUInt32BE(0)
+      if (buf.length < 4 + size) break
+      fn(JSON.parse(buf.slice(4, 4 + size).toString()))
+      buf = buf.slice(4 + size)
+    }
+  })
+}
 
 /**
- * Newline-delimited JSON stdout.
+ * Write framed JSON message `msg` to the child process.
  */
 
-var out = byline(proc.stdout)
+function write(msg) {
+  var json = JSON.stringify(msg)
 
-out.on('data', function(line){
-  var msg = JSON.parse(line)
-  ctx.done(msg.error, msg.value)
+  if (options.framing !== 'length') {
+    return proc.stdin.write(json + '\n')
+  }
+
+  var body = Buffer.from(json)
+  var head = Buffer.alloc(4)
+  head.writeUInt32BE(body.length, 0)
+  proc.stdin.write(Buffer.concat([head, body]))
+}
+
+/**
+ * Graceful shutdown, closing stdin so that the child process
+ * finishes pending invocations and exits.
+ */
+
+process.on('SIGTERM', function(){
+  stopping = true
+  proc.stdin.end()
+  setTimeout(function(){ process.exit(0) }, options.shutdownTimeout).unref()
 })
 
+spawn()
+
 /**
  * Handle events.
  */
 
 exports.handle = function(event, context) {
-  ctx = context
+  if (event && event.source === 'apex.warm') {
+    return context.succeed()
+  }
+
+  context.callbackWaitsForEmptyEventLoop = false
+
+  var id = String(nextID++)
+  pending[id] = context
 
-  proc.stdin.write(JSON.stringify({
+  write({
+    "id": id,
     "event": event,
     "context": context
-  })+'\n');
-}
\ No newline at end of file
+  })
+}
@@ -2,3 +2,62 @@
 
 // Package shim provides a shim for running arbitrary languages on Lambda.
 package shim
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Assets are the names of the shim assets added to function zips.
+var Assets = []string{"index.js", "byline.js"}
+
+// Load returns asset `name` from directory `dir` when present, allowing
+// projects to patch the shim, or the embedded asset otherwise.
+func Load(dir, name string) ([]byte, error) {
+	if dir != "" {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if !os.IsNotExist(err) {
+			return b, err
+		}
+	}
+
+	return Asset(name)
+}
+
+// Framings of messages exchanged with the child process.
+const (
+	FramingLine   = "line"
+	FramingLength = "length"
+)
+
+// Options configure the shim, and are added to function zips as shim.json.
+type Options struct {
+	// Framing of messages, FramingLine for newline-delimited JSON, or
+	// FramingLength for JSON prefixed by its 4-byte big-endian length.
+	Framing string `json:"framing,omitempty"`
+
+	// Restart the child process when it exits, failing pending
+	// invocations, rather than exiting the container.
+	Restart bool `json:"restart,omitempty"`
+
+	// ShutdownTimeout is the number of milliseconds to wait for the
+	// child process to finish pending invocations on shutdown.
+	ShutdownTimeout int64 `json:"shutdownTimeout,omitempty"`
+}
+
+// Validate the options.
+func (o *Options) Validate() error {
+	switch o.Framing {
+	case "", FramingLine, FramingLength:
+	default:
+		return fmt.Errorf("invalid framing %q, must be %q or %q", o.Framing, FramingLine, FramingLength)
+	}
+
+	if o.ShutdownTimeout < 0 {
+		return fmt.Errorf("shutdownTimeout must not be negative")
+	}
+
+	return nil
+}
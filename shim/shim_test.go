@@ -0,0 +1,30 @@
+package shim
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shim")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "index.js"), []byte("// patched"), 0644))
+
+	b, err := Load(dir, "index.js")
+	assert.Nil(t, err)
+	assert.Equal(t, "// patched", string(b))
+
+	b, err = Load(dir, "byline.js")
+	assert.Nil(t, err)
+	assert.Equal(t, MustAsset("byline.js"), b)
+
+	b, err = Load("", "index.js")
+	assert.Nil(t, err)
+	assert.Equal(t, MustAsset("index.js"), b)
+}
@@ -0,0 +1,288 @@
+package sqs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/apex/apex/function"
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// Invoker invokes a function with an event, returning its reply.
+type Invoker interface {
+	Invoke(event []byte) (reply []byte, err error)
+}
+
+// FunctionInvoker invokes a remote function.
+type FunctionInvoker struct {
+	Function *function.Function
+
+	// Logs, when set, receives the function's log tail.
+	Logs io.Writer
+}
+
+// Invoke implementation.
+func (i *FunctionInvoker) Invoke(event []byte) ([]byte, error) {
+	reply, logs, err := i.Function.InvokeWithOptions(event, function.InvokeOptions{NoLogs: i.Logs == nil})
+	if err != nil {
+		return nil, err
+	}
+
+	if i.Logs != nil && logs != nil {
+		io.Copy(i.Logs, logs)
+	}
+
+	return ioutil.ReadAll(reply)
+}
+
+// CommandInvoker invokes a local command speaking the apex stdio
+// protocol, such as "go run main.go" for a Go function.
+type CommandInvoker struct {
+	Command string
+	Dir     string
+}
+
+// Invoke implementation.
+func (i *CommandInvoker) Invoke(event []byte) ([]byte, error) {
+	in, err := json.Marshal(map[string]interface{}{
+		"event":   json.RawMessage(event),
+		"context": map[string]string{},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command("sh", "-c", i.Command)
+	cmd.Dir = i.Dir
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	var out struct {
+		Error string          `json:"error"`
+		Value json.RawMessage `json:"value"`
+	}
+
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("decoding output: %s", err)
+	}
+
+	if out.Error != "" {
+		return nil, errors.New(out.Error)
+	}
+
+	return out.Value, nil
+}
+
+// Poller receives messages from an SQS queue and invokes a function with
+// them in Lambda's SQS event envelope, deleting the messages processed
+// successfully. Failed messages become visible again once their
+// visibility timeout expires, as with an event source mapping.
+type Poller struct {
+	// Service is the SQS client.
+	Service sqsiface.SQSAPI
+
+	// QueueURL is the queue polled.
+	QueueURL string
+
+	// Invoker invokes the function.
+	Invoker Invoker
+
+	// BatchSize is the maximum number of messages per event, defaulting to 10.
+	BatchSize int64
+
+	// WaitTime is the long polling wait in seconds, defaulting to 20.
+	WaitTime int64
+
+	// VisibilityTimeout in seconds, defaulting to the queue's.
+	VisibilityTimeout int64
+
+	Log log.Interface
+
+	arn    string
+	region string
+}
+
+// Start polling until receiving from the queue fails.
+func (p *Poller) Start() error {
+	for {
+		if _, err := p.Poll(); err != nil {
+			return err
+		}
+	}
+}
+
+// Poll receives a batch of messages and invokes the function with them,
+// returning the number of messages received.
+func (p *Poller) Poll() (int, error) {
+	if err := p.init(); err != nil {
+		return 0, err
+	}
+
+	in := &sqs.ReceiveMessageInput{
+		QueueUrl:              &p.QueueURL,
+		MaxNumberOfMessages:   aws.Int64(10),
+		WaitTimeSeconds:       aws.Int64(20),
+		AttributeNames:        aws.StringSlice([]string{sqs.QueueAttributeNameAll}),
+		MessageAttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameAll}),
+	}
+
+	if p.BatchSize > 0 {
+		in.MaxNumberOfMessages = &p.BatchSize
+	}
+
+	if p.WaitTime > 0 {
+		in.WaitTimeSeconds = &p.WaitTime
+	}
+
+	if p.VisibilityTimeout > 0 {
+		in.VisibilityTimeout = &p.VisibilityTimeout
+	}
+
+	res, err := p.Service.ReceiveMessage(in)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(res.Messages) == 0 {
+		return 0, nil
+	}
+
+	p.Log.Infof("received %d messages", len(res.Messages))
+	event := p.event(res.Messages)
+
+	b, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+
+	reply, err := p.Invoker.Invoke(b)
+	if err != nil {
+		p.Log.Errorf("error invoking function, messages will be retried: %s", err)
+		return len(res.Messages), nil
+	}
+
+	return len(res.Messages), p.delete(event, failures(reply))
+}
+
+// init fetches the queue ARN for the event envelope.
+func (p *Poller) init() error {
+	if p.arn != "" {
+		return nil
+	}
+
+	res, err := p.Service.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       &p.QueueURL,
+		AttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameQueueArn}),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	p.arn = aws.StringValue(res.Attributes[sqs.QueueAttributeNameQueueArn])
+
+	if parts := strings.Split(p.arn, ":"); len(parts) > 3 {
+		p.region = parts[3]
+	}
+
+	return nil
+}
+
+// event returns the SQS event for `messages`.
+func (p *Poller) event(messages []*sqs.Message) *Event {
+	event := &Event{}
+
+	for _, m := range messages {
+		r := &Record{
+			MessageID:         aws.StringValue(m.MessageId),
+			ReceiptHandle:     aws.StringValue(m.ReceiptHandle),
+			Body:              aws.StringValue(m.Body),
+			Attributes:        aws.StringValueMap(m.Attributes),
+			MessageAttributes: make(map[string]*MessageAttribute),
+			MD5OfBody:         aws.StringValue(m.MD5OfBody),
+			EventSource:       "aws:sqs",
+			EventSourceARN:    p.arn,
+			AWSRegion:         p.region,
+		}
+
+		for k, v := range m.MessageAttributes {
+			r.MessageAttributes[k] = &MessageAttribute{
+				StringValue: v.StringValue,
+				BinaryValue: v.BinaryValue,
+				DataType:    aws.StringValue(v.DataType),
+			}
+		}
+
+		event.Records = append(event.Records, r)
+	}
+
+	return event
+}
+
+// delete the messages of `event` which have not failed.
+func (p *Poller) delete(event *Event, failed map[string]bool) error {
+	in := &sqs.DeleteMessageBatchInput{QueueUrl: &p.QueueURL}
+
+	for _, r := range event.Records {
+		if failed[r.MessageID] {
+			continue
+		}
+
+		in.Entries = append(in.Entries, &sqs.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(r.MessageID),
+			ReceiptHandle: aws.String(r.ReceiptHandle),
+		})
+	}
+
+	if len(failed) > 0 {
+		p.Log.Warnf("%d messages failed and will be retried", len(failed))
+	}
+
+	if len(in.Entries) == 0 {
+		return nil
+	}
+
+	res, err := p.Service.DeleteMessageBatch(in)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range res.Failed {
+		p.Log.Warnf("error deleting message %s: %s", aws.StringValue(e.Id), aws.StringValue(e.Message))
+	}
+
+	return nil
+}
+
+// failures returns the message ids of a partial batch response `reply`.
+func failures(reply []byte) map[string]bool {
+	var res Response
+	failed := make(map[string]bool)
+
+	if json.Unmarshal(reply, &res) != nil {
+		return failed
+	}
+
+	for _, f := range res.BatchItemFailures {
+		failed[f.ItemIdentifier] = true
+	}
+
+	return failed
+}
@@ -0,0 +1,74 @@
+package sqs
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type queue struct {
+	sqsiface.SQSAPI
+	messages []*sqs.Message
+	deleted  []string
+}
+
+func (q *queue) GetQueueAttributes(in *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+	return &sqs.GetQueueAttributesOutput{
+		Attributes: aws.StringMap(map[string]string{"QueueArn": "arn:aws:sqs:us-west-2:123456789012:jobs"}),
+	}, nil
+}
+
+func (q *queue) ReceiveMessage(in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	return &sqs.ReceiveMessageOutput{Messages: q.messages}, nil
+}
+
+func (q *queue) DeleteMessageBatch(in *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+	for _, e := range in.Entries {
+		q.deleted = append(q.deleted, *e.Id)
+	}
+	return &sqs.DeleteMessageBatchOutput{}, nil
+}
+
+type invoker func([]byte) ([]byte, error)
+
+func (i invoker) Invoke(event []byte) ([]byte, error) {
+	return i(event)
+}
+
+func TestPoller_Poll(t *testing.T) {
+	q := &queue{
+		messages: []*sqs.Message{
+			{MessageId: aws.String("a"), ReceiptHandle: aws.String("ra"), Body: aws.String("hello")},
+			{MessageId: aws.String("b"), ReceiptHandle: aws.String("rb"), Body: aws.String("world")},
+		},
+	}
+
+	var event Event
+
+	p := &Poller{
+		Service:  q,
+		QueueURL: "https://sqs.us-west-2.amazonaws.com/123456789012/jobs",
+		Log:      log.Log,
+		Invoker: invoker(func(b []byte) ([]byte, error) {
+			assert.Nil(t, json.Unmarshal(b, &event))
+			return []byte(`{"batchItemFailures":[{"itemIdentifier":"b"}]}`), nil
+		}),
+	}
+
+	n, err := p.Poll()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+
+	assert.Equal(t, 2, len(event.Records))
+	assert.Equal(t, "hello", event.Records[0].Body)
+	assert.Equal(t, "aws:sqs", event.Records[0].EventSource)
+	assert.Equal(t, "arn:aws:sqs:us-west-2:123456789012:jobs", event.Records[0].EventSourceARN)
+	assert.Equal(t, "us-west-2", event.Records[0].AWSRegion)
+
+	assert.Equal(t, []string{"a"}, q.deleted)
+}
@@ -0,0 +1,59 @@
+// Package sqs provides structs for working with AWS SQS events, and
+// a poller feeding messages from a real queue to a function.
+package sqs
+
+import (
+	"encoding/json"
+
+	"github.com/apex/apex"
+)
+
+// HandlerFunc unmarshals SQS events before passing control. The
+// response reports the messages which failed, and should be retried.
+type HandlerFunc func(*Event, *apex.Context) (*Response, error)
+
+// Handle implements apex.Handler.
+func (h HandlerFunc) Handle(data json.RawMessage, ctx *apex.Context) (interface{}, error) {
+	var event Event
+
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+
+	return h(&event, ctx)
+}
+
+// Event represents an SQS event with one or more records.
+type Event struct {
+	Records []*Record `json:"Records"`
+}
+
+// Record represents a single SQS message.
+type Record struct {
+	MessageID         string                       `json:"messageId"`
+	ReceiptHandle     string                       `json:"receiptHandle"`
+	Body              string                       `json:"body"`
+	Attributes        map[string]string            `json:"attributes"`
+	MessageAttributes map[string]*MessageAttribute `json:"messageAttributes"`
+	MD5OfBody         string                       `json:"md5OfBody"`
+	EventSource       string                       `json:"eventSource"`
+	EventSourceARN    string                       `json:"eventSourceARN"`
+	AWSRegion         string                       `json:"awsRegion"`
+}
+
+// MessageAttribute represents a message attribute.
+type MessageAttribute struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BinaryValue []byte  `json:"binaryValue,omitempty"`
+	DataType    string  `json:"dataType"`
+}
+
+// Response represents a partial batch response.
+type Response struct {
+	BatchItemFailures []*BatchItemFailure `json:"batchItemFailures"`
+}
+
+// BatchItemFailure identifies a failed message.
+type BatchItemFailure struct {
+	ItemIdentifier string `json:"itemIdentifier"`
+}
@@ -0,0 +1,127 @@
+// Package statemachine deploys Step Functions state machines whose
+// definitions reference project functions.
+package statemachine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/sfn/sfniface"
+)
+
+// placeholder matches "${function:<name>}" references to project functions.
+var placeholder = regexp.MustCompile(`\$\{function:([\w-]+)\}`)
+
+// Resolver returns the ARN of function `name`.
+type Resolver func(name string) (string, error)
+
+// Resolve replaces the function placeholders of `definition` using `resolve`.
+func Resolve(definition string, resolve Resolver) (string, error) {
+	var err error
+
+	out := placeholder.ReplaceAllStringFunc(definition, func(s string) string {
+		name := placeholder.FindStringSubmatch(s)[1]
+
+		arn, e := resolve(name)
+		if e != nil && err == nil {
+			err = fmt.Errorf("resolving %s: %s", s, e)
+		}
+
+		return arn
+	})
+
+	return out, err
+}
+
+// StateMachine is an Amazon States Language definition on disk,
+// in which "${function:<name>}" placeholders reference project functions.
+type StateMachine struct {
+	// Name of the deployed state machine.
+	Name string
+
+	// Path of the definition.
+	Path string
+
+	// Role is the execution role ARN.
+	Role string
+
+	Service sfniface.SFNAPI
+	Log     log.Interface
+}
+
+// Deploy creates or updates the state machine, with placeholders resolved by `resolve`.
+func (m *StateMachine) Deploy(resolve Resolver) error {
+	b, err := ioutil.ReadFile(m.Path)
+	if err != nil {
+		return err
+	}
+
+	definition, err := Resolve(string(b), resolve)
+	if err != nil {
+		return err
+	}
+
+	arn, err := m.arn()
+	if err != nil {
+		return err
+	}
+
+	if arn == "" {
+		m.Log.Infof("creating state machine %s", m.Name)
+
+		_, err = m.Service.CreateStateMachine(&sfn.CreateStateMachineInput{
+			Name:       &m.Name,
+			Definition: &definition,
+			RoleArn:    &m.Role,
+		})
+
+		return err
+	}
+
+	m.Log.Infof("updating state machine %s", m.Name)
+
+	_, err = m.Service.UpdateStateMachine(&sfn.UpdateStateMachineInput{
+		StateMachineArn: &arn,
+		Definition:      &definition,
+		RoleArn:         &m.Role,
+	})
+
+	return err
+}
+
+// Delete the state machine, if it exists.
+func (m *StateMachine) Delete() error {
+	arn, err := m.arn()
+	if err != nil || arn == "" {
+		return err
+	}
+
+	m.Log.Infof("deleting state machine %s", m.Name)
+
+	_, err = m.Service.DeleteStateMachine(&sfn.DeleteStateMachineInput{
+		StateMachineArn: &arn,
+	})
+
+	return err
+}
+
+// arn returns the ARN of the deployed state machine, or an empty string.
+func (m *StateMachine) arn() (string, error) {
+	var arn string
+
+	err := m.Service.ListStateMachinesPages(&sfn.ListStateMachinesInput{}, func(page *sfn.ListStateMachinesOutput, last bool) bool {
+		for _, s := range page.StateMachines {
+			if aws.StringValue(s.Name) == m.Name {
+				arn = aws.StringValue(s.StateMachineArn)
+				return false
+			}
+		}
+		return true
+	})
+
+	return arn, err
+}
@@ -0,0 +1,24 @@
+package statemachine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	resolve := func(name string) (string, error) {
+		if name == "missing" {
+			return "", errors.New("function not found")
+		}
+		return "arn:aws:lambda:us-west-2:123456789012:function:app_" + name + ":current", nil
+	}
+
+	s, err := Resolve(`{"Resource": "${function:foo}", "Next": "${function:bar_baz}"}`, resolve)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"Resource": "arn:aws:lambda:us-west-2:123456789012:function:app_foo:current", "Next": "arn:aws:lambda:us-west-2:123456789012:function:app_bar_baz:current"}`, s)
+
+	_, err = Resolve(`{"Resource": "${function:missing}"}`, resolve)
+	assert.Equal(t, "resolving ${function:missing}: function not found", err.Error())
+}
@@ -0,0 +1,95 @@
+// Package trail records who ran which operations against functions,
+// such as deploys, rollbacks and deletes, in DynamoDB, providing an
+// audit trail independent of CloudTrail.
+//
+// The table must have a string partition key named "function"
+// and a numeric sort key named "time" in unix nanoseconds.
+package trail
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Operations.
+const (
+	Deploy    = "deploy"
+	Rollback  = "rollback"
+	Delete    = "delete"
+	Configure = "configure"
+)
+
+// Entry is an operation run against a function.
+type Entry struct {
+	Function  string
+	Operation string
+	Stage     string
+	Version   string
+	User      string
+	Host      string
+	Error     string
+	Time      time.Time
+}
+
+// Store records entries in a DynamoDB table.
+type Store struct {
+	Service dynamodbiface.DynamoDBAPI
+	Log     log.Interface
+	Table   string
+}
+
+// Record entry `e`, defaulting its Host to the hostname and Time to now.
+func (s *Store) Record(e *Entry) error {
+	if e.Host == "" {
+		e.Host, _ = os.Hostname()
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	s.Log.Debugf("recording %s of %s", e.Operation, e.Function)
+
+	_, err := s.Service.PutItem(&dynamodb.PutItemInput{
+		TableName: &s.Table,
+		Item:      marshal(e),
+	})
+
+	return err
+}
+
+// marshal `e` to a DynamoDB item, omitting empty attributes.
+func marshal(e *Entry) map[string]*dynamodb.AttributeValue {
+	item := map[string]*dynamodb.AttributeValue{
+		"function":  {S: aws.String(e.Function)},
+		"time":      {N: aws.String(strconv.FormatInt(e.Time.UnixNano(), 10))},
+		"operation": {S: aws.String(e.Operation)},
+		"status":    {S: aws.String("success")},
+	}
+
+	if e.Error != "" {
+		item["status"] = &dynamodb.AttributeValue{S: aws.String("failure")}
+	}
+
+	attrs := map[string]string{
+		"stage":   e.Stage,
+		"version": e.Version,
+		"user":    e.User,
+		"host":    e.Host,
+		"error":   e.Error,
+	}
+
+	for k, v := range attrs {
+		if v != "" {
+			item[k] = &dynamodb.AttributeValue{S: aws.String(v)}
+		}
+	}
+
+	return item
+}
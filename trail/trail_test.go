@@ -0,0 +1,49 @@
+package trail
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type table struct {
+	dynamodbiface.DynamoDBAPI
+	items []map[string]*dynamodb.AttributeValue
+}
+
+func (t *table) PutItem(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	t.items = append(t.items, in.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestStore_Record(t *testing.T) {
+	db := &table{}
+	s := &Store{Service: db, Log: log.Log, Table: "operations"}
+
+	err := s.Record(&Entry{
+		Function:  "app_foo",
+		Operation: Rollback,
+		Version:   "3",
+		User:      "tj",
+		Host:      "ci-1",
+		Error:     "boom",
+		Time:      time.Unix(1, 5),
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]*dynamodb.AttributeValue{{
+		"function":  {S: aws.String("app_foo")},
+		"time":      {N: aws.String("1000000005")},
+		"operation": {S: aws.String("rollback")},
+		"status":    {S: aws.String("failure")},
+		"version":   {S: aws.String("3")},
+		"user":      {S: aws.String("tj")},
+		"host":      {S: aws.String("ci-1")},
+		"error":     {S: aws.String("boom")},
+	}}, db.items)
+}